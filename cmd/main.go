@@ -1,28 +1,136 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/secrails/secrails-sizing-agent/internal/agent"
 	"github.com/secrails/secrails-sizing-agent/internal/cli"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/server"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
 )
 
+// tracingShutdownTimeout bounds how long exit waits for pending spans
+const tracingShutdownTimeout = 10 * time.Second
+
 func main() {
-	// Create CLI handler
-	cliHandler := cli.New()
+	os.Exit(run())
+}
+
+// run executes the agent and returns the process exit code. It returns rather
+// than exits so that traces are flushed first.
+func run() int {
+	command, args, legacy := cli.Route(os.Args[1:])
+	if legacy {
+		fmt.Fprintln(os.Stderr, cli.LegacyNotice)
+	}
+
+	// Commands that neither scan nor connect
+	if offline := cli.Offline(command); offline != nil {
+		if err := offline(args); err != nil {
+			return errorExitCode(err)
+		}
+		return 0
+	}
 
-	// Get configuration from flags or prompts
-	config, err := cliHandler.GetConfig()
+	// Get configuration from flags or prompts; the serve command takes the
+	// base configuration of its scans from its own flags
+	var config *agent.Config
+	var serveOptions *server.Options
+	var err error
+	if command == cli.CommandServe {
+		serveOptions, err = cli.ParseServe(args)
+		if err == nil {
+			config = &serveOptions.Base
+		}
+	} else {
+		config, err = cli.New().GetConfig(command, args)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return errorExitCode(err)
+	}
+
+	logging.SetRedaction(!config.NoRedact)
+
+	// --quiet keeps informational log lines off stderr as well, unless
+	// --log-level says otherwise
+	logLevel := config.LogLevel
+	if logLevel == "" && config.Quiet {
+		logLevel = "warn"
+	}
+	if logLevel != "" {
+		if err := logging.InitLogger(logLevel); err != nil {
+			return errorExitCode(err)
+		}
 	}
 
+	// Export traces when an OTLP endpoint is configured, unless offline
+	shutdownTracing := func(context.Context) error { return nil }
+	if config.TraceExport() {
+		shutdownTracing, err = tracing.Setup(context.Background(), config.OTelEndpoint)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to flush traces: %v\n", err)
+		}
+	}()
+
+	// Stop on SIGINT/SIGTERM; a second signal kills the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	// Create and run the agent with the configuration
-	sizingAgent := agent.New(config)
-	if err := sizingAgent.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	switch {
+	case serveOptions != nil:
+		err = server.New(*serveOptions).ListenAndServe(ctx)
+	case config.Schedule != "":
+		err = agent.New(config).RunScheduled(ctx)
+	default:
+		err = agent.New(config).Run(ctx)
+	}
+	return exitCode(err)
+}
+
+// exitCode returns the process exit code for the outcome of a scan:
+// ExitThresholdExceeded when it succeeded beyond a threshold
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, agent.ErrThresholdExceeded):
+		return agent.ExitThresholdExceeded
+	default:
+		return errorExitCode(err)
+	}
+}
+
+// errorExitCode reports err with its remediation hint and returns the exit
+// code for its class, or 0 when help was requested
+func errorExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if hint := sizingerrors.Hint(err); hint != "" {
+		fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
 	}
+	return sizingerrors.ExitCode(err)
 }
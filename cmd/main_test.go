@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", want: 0},
+		{name: "threshold exceeded", err: agent.ErrThresholdExceeded, want: agent.ExitThresholdExceeded},
+		{name: "failure", err: errors.New("connect failed"), want: sizingerrors.ExitCode(errors.New("connect failed"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+	if agent.ExitThresholdExceeded != 3 {
+		t.Errorf("ExitThresholdExceeded = %d, want 3", agent.ExitThresholdExceeded)
+	}
+}
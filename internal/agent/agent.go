@@ -2,147 +2,860 @@ package agent
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/secrails/secrails-sizing-agent/internal/anonymize"
+	"github.com/secrails/secrails-sizing-agent/internal/cache"
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/estimate"
+	"github.com/secrails/secrails-sizing-agent/internal/history"
+	"github.com/secrails/secrails-sizing-agent/internal/identity"
+	"github.com/secrails/secrails-sizing-agent/internal/inventory"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
 	"github.com/secrails/secrails-sizing-agent/internal/models"
 	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/httpclient"
+	"github.com/secrails/secrails-sizing-agent/internal/report"
+	"github.com/secrails/secrails-sizing-agent/internal/sink"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
+	"github.com/secrails/secrails-sizing-agent/internal/update"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
 )
 
+// ExitThresholdExceeded is the process exit code used when a scan succeeds
+// but exceeds a configured threshold
+const ExitThresholdExceeded = 3
+
+// ErrThresholdExceeded is returned by Run when a configured threshold is
+// exceeded; results have still been written
+var ErrThresholdExceeded = errors.New("threshold exceeded")
+
+// logWarningLimit caps the logged warnings and errors kept for the results
+const logWarningLimit = 200
+
 // Agent represents the Secrails cloud sizing agent
 type Agent struct {
-	config          *Config
-	providerManager *providers.ProviderManager
+	config *Config
+
+	// getProvider builds the cloud provider; tests replace it
+	getProvider func(config.ProviderConfig) (providers.Provider, error)
+
+	// progress receives banners and status lines, on stderr so that they
+	// never mix with the results
+	progress report.Progress
+
+	// log receives the log entries of the agent and its providers
+	log *zap.Logger
+
+	// update is the latest release lookup of Run, nil without UpdateCheck
+	update *update.Check
+
+	// metrics is the exporter started once by RunScheduled and shared by its
+	// scans; when nil, each run starts and closes its own
+	metrics *metricsExporter
 }
 
 func New(config *Config) *Agent {
+	progress := report.NewProgress(os.Stderr, report.Options{ASCII: config.ASCII})
+	if config.Quiet {
+		progress = report.Quiet(progress)
+	}
+	log := logging.GetLogger()
+	if config.Logger != nil {
+		log = logging.Wrap(config.Logger)
+	}
 	return &Agent{
-		config:          config,
-		providerManager: providers.NewManager(config.Verbose),
+		config:      config,
+		getProvider: providers.NewManager(config.Verbose).GetProvider,
+		progress:    progress,
+		log:         log,
 	}
 }
 
-// Run executes the main sizing logic
-func (a *Agent) Run() error {
+// Run executes the main sizing logic; cancelling ctx aborts the scan
+func (a *Agent) Run(ctx context.Context) error {
 	if a.config.Provider == "" {
 		return fmt.Errorf("no provider specified")
 	}
 
-	fmt.Printf("\n🚀 Secrails Sizing Agent\n")
-	fmt.Printf("Selected cloud provider: %s\n", strings.ToUpper(a.config.Provider))
+	a.progress.Start(a.config.Provider)
+	if a.config.UpdateCheck {
+		a.startUpdateCheck(ctx)
+		defer a.updateNotice()
+	}
+
+	if a.config.DryRun {
+		return a.dryRun(ctx)
+	}
+	if err := a.expandOutput(); err != nil {
+		return err
+	}
+	if a.config.AccountsOnly {
+		return a.listAccounts(ctx)
+	}
 
-	ctx := context.Background()
+	ctx, span := tracing.Start(ctx, "Scan")
+	span.SetString("provider", a.config.Provider)
+	err := a.run(ctx)
+	span.End(err)
+	return err
+}
+
+// Scan connects to the provider and counts resources without writing any
+// output. The result carries the estimate and threshold checks, and is
+// anonymized when configured; per-type stats are kept only with Stats. When
+// some resource types fail, Scan returns the result together with a
+// *errors.PartialResultError.
+func (a *Agent) Scan(ctx context.Context) (*models.SizingResult, error) {
+	if a.config.Provider == "" {
+		return nil, fmt.Errorf("no provider specified")
+	}
+
+	ctx, span := tracing.Start(ctx, "Scan")
+	span.SetString("provider", a.config.Provider)
+	result, err := a.scan(ctx, nil)
+	span.End(err)
+	if result != nil && !a.config.Stats {
+		result = result.WithoutStats()
+	}
+	return result, err
+}
+
+// run performs one scan within the root trace span and writes its output
+func (a *Agent) run(ctx context.Context) error {
+	exporter := a.metrics
+	if exporter == nil {
+		var err error
+		if exporter, err = a.startMetrics(); err != nil {
+			return err
+		}
+		defer exporter.close()
+	}
+
+	// NDJSON output streams every count as the scan completes it, so the
+	// output is opened before scanning
+	var stream *report.CountStream
+	var counts models.CountSink
+	closeOutput := func() error { return nil }
+	if a.config.OutputFormat == report.FormatNDJSON {
+		var out io.Writer
+		var err error
+		out, closeOutput, err = a.openOutput()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = closeOutput() }()
+		stream = report.NewCountStream(out, report.Options{Stats: a.config.Stats})
+		counts = stream
+	}
 
+	// Warnings logged during the scan are repeated in the results, where
+	// they are not lost in the scrollback
+	capture := logging.StartCapture(logWarningLimit)
+	scanStart := time.Now()
+	result, partialErr := a.scan(ctx, counts)
+	logged, dropped := capture.Stop()
+	if result == nil {
+		return partialErr
+	}
+	scanDuration := time.Since(scanStart)
+	if a.config.OutputFile != "" {
+		result.OutputFile = a.sinkOptions().Path(a.config.OutputFile)
+	}
+	result.LogWarnings = logWarnings(logged, result.Warnings, a.config.Anonymize)
+	result.LogWarningsDropped = dropped
+
+	if stream != nil {
+		if err := stream.WriteSummary(result); err != nil {
+			return err
+		}
+		if err := closeOutput(); err != nil {
+			return err
+		}
+		if a.config.OutputFile != "" {
+			a.savedOutput("Results")
+		}
+	} else if a.config.SplitByTenant {
+		if err := a.outputSplitByTenant(result); err != nil {
+			return err
+		}
+	} else if err := a.outputResults(result); err != nil {
+		return err
+	}
+	if a.config.SplitByAccount {
+		if err := a.outputSplit(result); err != nil {
+			return err
+		}
+	}
+
+	if a.config.History != "" {
+		if err := history.Append(a.config.History, history.FromResult(result)); err != nil {
+			return err
+		}
+		a.progress.Status("✓ Scan appended to history: %s", a.config.History)
+	}
+
+	if exporter != nil {
+		recordResult(result, scanDuration)
+		exporter.publish(ctx)
+	}
+
+	// An incomplete scan fails as such, even over a threshold: the exit code
+	// for a breach is kept for scans that completed
+	if result.Thresholds != nil && result.Thresholds.Exceeded() {
+		a.printThresholdViolations(result)
+		if partialErr == nil {
+			return ErrThresholdExceeded
+		}
+	}
+
+	return partialErr
+}
+
+// logWarnings converts captured log entries for the result, leaving out
+// those that only repeat one of the result's warnings. Anonymized results
+// keep the messages but not the fields, which may name accounts.
+func logWarnings(entries []logging.Entry, resultWarnings []string, anonymized bool) []models.LogWarning {
+	var warnings []models.LogWarning
+	for _, entry := range entries {
+		if len(entry.Fields) == 0 && slices.Contains(resultWarnings, entry.Message) {
+			continue
+		}
+		warning := models.LogWarning{Level: entry.Level, Message: entry.Message, Fields: entry.Fields}
+		if anonymized {
+			warning.Fields = nil
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings
+}
+
+// scan connects to the provider, counts resources and derives the estimate.
+// counts, if not nil, receives every resource count as it completes.
+func (a *Agent) scan(ctx context.Context, counts models.CountSink) (*models.SizingResult, error) {
+	providerConfig, err := a.newProviderConfig()
+	if err != nil {
+		return nil, err
+	}
+	eta := newETATracker(counts, a.progress)
+	providerConfig.Counts = eta
+	defer a.logAPICalls(providerConfig.APICalls)
+	scope := a.typeScope(providerConfig.Definitions)
+	if note := models.SmallScanScope(scope); note != "" {
+		a.progress.Status("Note: %s", note)
+	}
+
+	weights, err := estimate.LoadWeights(a.config.Weights)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load weights: %w", err)
+	}
+
+	// The inventory is anonymized as it streams, with the result's tokens
+	var anonymizer *anonymize.Anonymizer
+	if a.config.Anonymize {
+		if anonymizer, err = anonymize.New(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Open the inventory file so collectors can stream resources into it
+	var inventoryWriter *inventory.Writer
+	if a.config.Inventory {
+		inventoryWriter, err = inventory.NewWriter(a.inventoryPath(), a.config.InventoryFormat, a.sinkOptions())
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = inventoryWriter.Close() }()
+		providerConfig.Inventory = inventoryWriter
+		if anonymizer != nil {
+			providerConfig.Inventory = anonymizer.Sink(inventoryWriter)
+		}
+	}
+
+	if a.config.DebugDump != "" {
+		dumper, err := debugdump.New(a.config.DebugDump, int64(a.config.DebugDumpMaxMB)<<20, a.config.DebugDumpRedact)
+		if err != nil {
+			return nil, err
+		}
+		a.progress.Warn("--debug-dump writes every raw API response to %s. Tag values are redacted, but resource "+
+			"names, IDs and other properties are not: review the files before sharing them.", a.config.DebugDump)
+		defer func() {
+			if err := dumper.Close(); err != nil {
+				a.log.Warn("Failed to close the debug dump", zap.Error(err))
+				return
+			}
+			a.progress.Status("✓ Debug dump of %d API responses saved to: %s", dumper.Count(), a.config.DebugDump)
+		}()
+		providerConfig.DebugDump = dumper
+	}
+
+	var result *models.SizingResult
+	switch {
+	case len(a.config.Profiles) > 0:
+		result, err = a.scanTargets(ctx, providerConfig, a.profileTargets(), profileConcurrency, eta)
+	case len(a.config.AzureTenants) > 0:
+		result, err = a.scanTargets(ctx, providerConfig, a.tenantTargets(), a.config.TenantConcurrency, eta)
+	default:
+		result, err = a.countResources(ctx, providerConfig, eta)
+	}
+
+	// Some types failing still yields a result; it is reported once written
+	var partial *sizingerrors.PartialResultError
+	var partialErr error
+	if errors.As(err, &partial) && result != nil {
+		partialErr = err
+		result.CredentialsExpired = sizingerrors.CredentialsExpired(err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to count resources: %w", err)
+	}
+	if a.config.IncludeIdentity == identity.ProviderOkta {
+		a.countOkta(ctx, providerConfig, result)
+	}
+
+	if inventoryWriter != nil {
+		if err := inventoryWriter.Close(); err != nil {
+			return nil, err
+		}
+		a.progress.Status("\n✓ Inventory of %d resources saved to: %s", inventoryWriter.Count(), a.sinkOptions().Path(a.inventoryPath()))
+	}
+
+	if scope != nil || result.Scope != nil {
+		if result.Scope != nil {
+			scope = result.Scope
+		}
+		scope.ResourceTypes, scope.DefaultResourceTypes = a.typeCounts(providerConfig.Definitions)
+		result.Scope = scope
+		if note := models.SmallScanScope(scope); note != "" {
+			a.log.Info("Scan scope", zap.String("scope", note))
+		}
+	}
+	result.AgentVersion = version.Get()
+	result.LatestVersion = a.update.Latest()
+	result.Offline = a.config.Offline
+	result.APICalls = providerConfig.APICalls.Counts()
+	result.TagFilters = a.config.Tags
+	if !a.config.AccountFilter.Empty() {
+		result.AccountFilter = &a.config.AccountFilter
+	}
+	if a.config.GroupAccountsByTag != "" {
+		result.AccountGroupTag = a.config.GroupAccountsByTag
+		result.AccountGroups = models.GroupAccountsByTag(result, a.config.GroupAccountsByTag)
+	}
+	if a.config.ManagementGroups && a.config.Provider == "azure" {
+		result.ManagementGroups = models.GroupByManagementGroup(result)
+	}
+	result.BillableTypes = a.billableTypes(providerConfig.Definitions)
+	result.BillableWorkloads = models.CountBillable(result.ResourceCounts, result.BillableTypes)
+	for _, warning := range models.SanityWarnings(result) {
+		a.log.Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	result.Estimate = weights.Estimate(result)
+	result.WeightsFile = weights.Source
+	result.Thresholds = a.checkThresholds(result)
+
+	if anonymizer != nil {
+		if err := a.anonymize(anonymizer, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, partialErr
+}
+
+// countOkta adds the users, groups and applications of the Okta org to
+// result. It runs after the infrastructure scan and only ever adds warnings,
+// so an unreachable org or a rejected token leaves the scan intact.
+func (a *Agent) countOkta(ctx context.Context, providerConfig config.ProviderConfig, result *models.SizingResult) {
+	a.progress.Status("Counting Okta users, groups and applications...")
+	client, err := identity.NewOktaClient(a.config.OktaOrgURL, a.config.OktaAPIToken, providerConfig.HTTPClient, providerConfig.APICalls)
+	if err != nil {
+		a.log.Warn("Failed to count Okta identities", zap.Error(err))
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Okta identities not counted: %v", err))
+		return
+	}
+
+	identityCtx, cancel := context.WithTimeout(logging.NewContext(ctx, a.log), identity.Timeout)
+	defer cancel()
+	directory, counts, warnings := client.CountIdentities(identityCtx)
+	result.Warnings = append(result.Warnings, warnings...)
+	if directory == nil {
+		return
+	}
+	for _, count := range counts {
+		count.TagFilterNotApplied = len(a.config.Tags) > 0
+		if providerConfig.Counts != nil {
+			if err := providerConfig.Counts.WriteCount(count); err != nil {
+				a.log.Warn("Failed to stream resource count", zap.String("type", string(count.Type)), zap.Error(err))
+			}
+		}
+		result.TotalResources += count.TotalResources
+	}
+	result.ResourceCounts = append(result.ResourceCounts, counts...)
+	result.Directories = append(result.Directories, *directory)
+}
+
+// dryRun connects and discovers like a scan, then writes the plan of the
+// count instead of counting
+func (a *Agent) dryRun(ctx context.Context) error {
+	providerConfig, err := a.newProviderConfig()
+	if err != nil {
+		return err
+	}
+	defer a.logAPICalls(providerConfig.APICalls)
+
+	cloudProvider, err := a.connect(ctx, providerConfig)
+	if err != nil {
+		return err
+	}
+	defer a.closeProvider(cloudProvider)
+
+	plan, err := cloudProvider.Plan()
+	if err != nil {
+		return fmt.Errorf("failed to plan the scan: %w", err)
+	}
+	if a.config.IncludeIdentity == identity.ProviderOkta {
+		for _, planned := range identity.PlanOkta() {
+			plan.AddType(planned)
+		}
+	}
+	return a.writeOutput(func(out io.Writer) error {
+		return report.WritePlan(out, a.config.OutputFormat, plan, report.Options{Verbose: a.config.Verbose, ASCII: a.config.ASCII})
+	}, "Scan plan")
+}
+
+// listAccounts connects and writes the discovered accounts or subscriptions
+// without counting any resources
+func (a *Agent) listAccounts(ctx context.Context) error {
+	providerConfig, err := a.newProviderConfig()
+	if err != nil {
+		return err
+	}
+	defer a.logAPICalls(providerConfig.APICalls)
+
+	cloudProvider, err := a.connect(ctx, providerConfig)
+	if err != nil {
+		return err
+	}
+	defer a.closeProvider(cloudProvider)
+
+	list := cloudProvider.Accounts()
+	return a.writeOutput(func(out io.Writer) error {
+		return report.WriteAccounts(out, a.config.OutputFormat, list)
+	}, "Account list")
+}
+
+// newProviderConfig loads the resource definitions and builds the provider
+// settings. Definitions load before any cloud call so a bad override file
+// fails fast.
+func (a *Agent) newProviderConfig() (config.ProviderConfig, error) {
+	definitions, err := models.LoadDefinitions(a.config.ResourceDefinitions)
+	if err != nil {
+		return config.ProviderConfig{}, fmt.Errorf("failed to load resource definitions: %w", err)
+	}
+	if len(definitions.ForProvider(a.config.Provider)) == 0 && a.config.ResourceDefinitions != "" {
+		return config.ProviderConfig{}, fmt.Errorf("no %s resource types left to count: %s disables or replaces all of them",
+			a.config.Provider, a.config.ResourceDefinitions)
+	}
+
+	httpClient, err := httpclient.New(a.config.CABundle, a.config.Proxy)
+	if err != nil {
+		return config.ProviderConfig{}, fmt.Errorf("failed to configure HTTP client: %w", err)
+	}
+
+	providerConfig := config.ProviderConfig{
+		Provider:         a.config.Provider,
+		Definitions:      definitions.ForProvider(a.config.Provider),
+		Profile:          a.config.Profile,
+		Region:           a.config.Region,
+		Regions:          a.config.Regions,
+		SubscriptionIDs:  a.config.Subscriptions,
+		Kubeconfig:       a.config.Kubeconfig,
+		KubeContexts:     a.config.KubeContexts,
+		OCIAuth:          a.config.OCIAuth,
+		OCIConfigFile:    a.config.OCIConfigFile,
+		OCIProfile:       a.config.OCIProfile,
+		TenantID:         a.config.TenantID,
+		AssumeRoleARN:    a.config.AssumeRoleARN,
+		ExternalID:       a.config.ExternalID,
+		MFASerial:        a.config.MFASerial,
+		MFATokenProvider: a.config.MFATokenProvider,
+		AzureAuth:        a.config.AzureAuth,
+
+		AzureClientID:              a.config.AzureClientID,
+		AzureClientCertificatePath: a.config.AzureClientCertificatePath,
+		AzureFederatedTokenFile:    a.config.AzureFederatedTokenFile,
+		IncludeIdentity:            a.config.IncludeIdentity == identity.ProviderEntra,
+		ManagementGroups:           a.config.ManagementGroups,
+		IncludeSuspended:           a.config.IncludeSuspended,
+		NoRegionPrecheck:           a.config.NoRegionPrecheck,
+		ExcludeNoise:               a.config.ExcludeNoise,
+		StrictDefinitions:          a.config.StrictDefinitions,
+		DeepRegistries:             a.config.DeepRegistries,
+		DeepStorage:                a.config.DeepStorage,
+		DeepBackup:                 a.config.DeepBackup,
+		RetryFailed:                a.config.RetryFailed,
+		PageSize:                   a.config.PageSize,
+		MaxPages:                   a.config.MaxPages,
+		TypeTimeout:                a.config.TypeTimeout,
+		RegionFailureThreshold:     a.config.RegionFailureThreshold,
+		TagFilters:                 a.config.Tags,
+		AccountFilter:              a.config.AccountFilter,
+		AWSEndpointURL:             a.config.AWSEndpointURL,
+		AWSEndpoints:               a.config.AWSEndpoints,
+		AzureEndpointURL:           a.config.AzureEndpointURL,
+		AzureAuthorityHost:         a.config.AzureAuthorityHost,
+		HTTPClient:                 httpClient,
+		APICalls:                   metrics.NewAPICallCounter(),
+		Logger:                     a.log,
+	}
+
+	if a.config.CacheDir != "" && !a.config.NoCache {
+		providerConfig.Cache = cache.New(a.config.CacheDir, a.config.CacheTTL)
+	}
+
+	return providerConfig, nil
+}
+
+// countResources connects the provider and counts its resources, starting
+// eta with its plan
+func (a *Agent) countResources(ctx context.Context, providerConfig config.ProviderConfig, eta *etaTracker) (*models.SizingResult, error) {
+	cloudProvider, err := a.connect(ctx, providerConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer a.closeProvider(cloudProvider)
+	eta.start(a.plans(cloudProvider), 1)
+
+	countCtx, span := tracing.Start(ctx, "CountResources")
+	span.SetString("provider", cloudProvider.Name())
+	result, err := cloudProvider.CountResources(countCtx)
+	if result != nil {
+		span.SetInt("total_resources", result.TotalResources)
+	}
+	span.End(err)
+	return result, err
+}
+
+// connect builds the provider and connects it; the caller closes it
+func (a *Agent) connect(ctx context.Context, providerConfig config.ProviderConfig) (providers.Provider, error) {
 	// Get the appropriate provider from the manager
-	cloudProvider, err := a.providerManager.GetProvider(a.config.Provider)
+	cloudProvider, err := a.getProvider(providerConfig)
 	if err != nil {
-		return fmt.Errorf("failed to initialize provider: %w", err)
+		return nil, fmt.Errorf("failed to initialize provider: %w", err)
 	}
 
 	// Connect to the cloud provider
-	if err := cloudProvider.Connect(ctx); err != nil {
-		return fmt.Errorf("failed to connect to %s: %w", cloudProvider.Name(), err)
+	connectCtx, span := tracing.Start(ctx, "Connect")
+	span.SetString("provider", cloudProvider.Name())
+	err = cloudProvider.Connect(connectCtx)
+	span.End(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", cloudProvider.Name(), err)
+	}
+	return cloudProvider, nil
+}
+
+// plans returns the plans of the connected providers for the scan
+// estimate, leaving out those that cannot plan
+func (a *Agent) plans(cloudProviders ...providers.Provider) []*models.ScanPlan {
+	var plans []*models.ScanPlan
+	for _, cloudProvider := range cloudProviders {
+		plan, err := cloudProvider.Plan()
+		if err != nil || plan == nil {
+			a.log.Debug("No scan estimate", zap.String("provider", cloudProvider.Name()), zap.Error(err))
+			continue
+		}
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// closeProvider closes the provider connection, warning on failure
+func (a *Agent) closeProvider(cloudProvider providers.Provider) {
+	if err := cloudProvider.Close(); err != nil {
+		a.progress.Warn("failed to close provider connection: %v", err)
+	}
+}
+
+// logAPICalls logs how many API calls the scan made, with the count of every
+// operation at debug level
+func (a *Agent) logAPICalls(calls *metrics.APICallCounter) {
+	a.log.Info("API calls made", zap.Int("total", calls.Total()), zap.String("user_agent", version.UserAgent()))
+	for _, call := range calls.Counts() {
+		a.log.Debug("API calls", zap.String("service", call.Service),
+			zap.String("operation", call.Operation), zap.Int("count", call.Count))
 	}
+}
 
-	defer func() {
-		if err := cloudProvider.Close(); err != nil {
-			fmt.Printf("⚠️  Warning: failed to close provider connection: %v\n", err)
+// billableTypes returns the types counted as billable workloads: those of
+// --billable-types if set, otherwise the definitions marked billable
+func (a *Agent) billableTypes(definitions []models.ResourceDefinition) []models.ResourceType {
+	if len(a.config.BillableTypes) == 0 {
+		return models.BillableTypes(definitions)
+	}
+
+	defined := make(map[models.ResourceType]bool, len(definitions))
+	for _, def := range definitions {
+		defined[def.ResourceType()] = true
+	}
+	types := make([]models.ResourceType, 0, len(a.config.BillableTypes))
+	for _, t := range a.config.BillableTypes {
+		if !defined[models.ResourceType(t)] {
+			a.progress.Warn("--billable-types: %s is not a counted %s resource type", t, a.config.Provider)
 		}
-	}()
+		types = append(types, models.ResourceType(t))
+	}
+	return types
+}
+
+// typeScope returns the scope of a scan counting definitions when they are
+// fewer than the built-in types of the provider, or nil
+func (a *Agent) typeScope(definitions []models.ResourceDefinition) *models.ScanScope {
+	types, defaults := a.typeCounts(definitions)
+	if types >= defaults {
+		return nil
+	}
+	return &models.ScanScope{ResourceTypes: types, DefaultResourceTypes: defaults}
+}
 
-	// Count resources
-	result, err := cloudProvider.CountResources(ctx)
+// typeCounts returns the number of definitions and of built-in types of the
+// provider
+func (a *Agent) typeCounts(definitions []models.ResourceDefinition) (types, defaults int) {
+	builtIn, err := models.DefaultDefinitions()
 	if err != nil {
-		return fmt.Errorf("failed to count resources: %w", err)
+		return len(definitions), len(definitions)
+	}
+	return len(definitions), len(builtIn.ForProvider(a.config.Provider))
+}
+
+// checkThresholds compares the final counts against the configured limits
+func (a *Agent) checkThresholds(result *models.SizingResult) *models.Thresholds {
+	if a.config.MaxResources == 0 && a.config.MaxAccounts == 0 {
+		return nil
 	}
 
-	return a.outputResults(result)
+	thresholds := &models.Thresholds{
+		MaxResources: a.config.MaxResources,
+		MaxAccounts:  a.config.MaxAccounts,
+	}
+	if thresholds.MaxResources > 0 && result.TotalResources > thresholds.MaxResources {
+		thresholds.ResourcesExceeded = true
+	}
+	if thresholds.MaxAccounts > 0 && result.TotalAccounts > thresholds.MaxAccounts {
+		thresholds.AccountsExceeded = true
+	}
+	return thresholds
 }
 
-// outputResults formats and outputs the counting results
-func (a *Agent) outputResults(result *models.SizingResult) error {
-	switch a.config.OutputFormat {
-	case "json":
-		return a.outputJSON(result)
-	default: // table format
-		return a.outputTable(result)
-	}
-}
-
-// outputTable prints results in a table format
-func (a *Agent) outputTable(result *models.SizingResult) error {
-	fmt.Println("\n=================================")
-	fmt.Printf("Provider: %s\n", result.Provider)
-	fmt.Printf("Total Resources: %d\n", result.TotalResources)
-	fmt.Printf("Accounts/Subscriptions: %d\n", len(result.AccountCounts))
-
-	// Show per-account breakdown
-	if len(result.AccountCounts) > 0 {
-		fmt.Println("---------------------------------")
-		fmt.Println("Per Account/Subscription:")
-		for _, account := range result.AccountCounts {
-			fmt.Printf("  %-30s: %d resources\n", account.Name, account.ResourceCount)
-		}
-	}
-
-	// Show resource breakdown with better formatting
-	fmt.Println("---------------------------------")
-	fmt.Println("Resource Breakdown:")
-	for _, rc := range result.ResourceCounts {
-		if rc.TotalResources > 0 {
-			fmt.Printf("  %-30s: %d\n", rc.DisplayName, rc.TotalResources)
-			// Optionally show top regions
-			if len(rc.ByLocation) > 0 && a.config.Verbose {
-				fmt.Printf("    Regions: ")
-				count := 0
-				for loc, cnt := range rc.ByLocation {
-					if count > 0 {
-						fmt.Printf(", ")
-					}
-					fmt.Printf("%s(%d)", loc, cnt)
-					count++
-					if count >= 3 {
-						break
-					}
-				}
-				fmt.Println()
-			}
+// printThresholdViolations explains which limits were exceeded
+func (a *Agent) printThresholdViolations(result *models.SizingResult) {
+	if result.Thresholds.ResourcesExceeded {
+		a.progress.Status("\n✗ Threshold exceeded: %d resources (limit %d)",
+			result.TotalResources, result.Thresholds.MaxResources)
+	}
+	if result.Thresholds.AccountsExceeded {
+		a.progress.Status("✗ Threshold exceeded: %d accounts/subscriptions (limit %d)",
+			result.TotalAccounts, result.Thresholds.MaxAccounts)
+	}
+}
+
+// anonymize replaces account identifiers in the result before any output and
+// optionally writes the mapping, which also covers the inventory, to a local
+// file
+func (a *Agent) anonymize(anonymizer *anonymize.Anonymizer, result *models.SizingResult) error {
+	anonymizer.Apply(result)
+
+	if a.config.AnonymizeMap != "" {
+		if err := anonymizer.WriteMapping(a.config.AnonymizeMap); err != nil {
+			return err
 		}
+		a.progress.Status("\n✓ Anonymization map saved to: %s", a.config.AnonymizeMap)
+	}
+	return nil
+}
+
+// expandOutput replaces an --output template with the file of this scan,
+// named by its provider, format and start time
+func (a *Agent) expandOutput() error {
+	name := OutputName{Provider: a.config.Provider, Format: a.config.OutputFormat, Timestamp: time.Now()}
+	path, err := ExpandOutputPath(a.config.OutputFile, name, a.sinkOptions())
+	if err != nil || path == a.config.OutputFile {
+		return err
 	}
+	config := *a.config
+	config.OutputFile = path
+	a.config = &config
+	a.progress.Status("Output file: %s", a.sinkOptions().Path(path))
+	return nil
+}
 
-	fmt.Println("=================================")
-	fmt.Printf("Timestamp: %s\n", result.Timestamp)
+// inventoryPath returns the inventory file path, deriving it from the output
+// file when not set explicitly
+func (a *Agent) inventoryPath() string {
+	if a.config.InventoryOutput != "" {
+		return a.config.InventoryOutput
+	}
+	ext := "." + a.config.InventoryFormat
+	if a.config.OutputFile == "" {
+		return "inventory" + ext
+	}
+	base := strings.TrimSuffix(a.config.OutputFile, filepath.Ext(a.config.OutputFile))
+	return base + ".inventory" + ext
+}
 
-	// Don't claim file is saved if it's not
-	if a.config.OutputFile != "" {
-		// Actually implement file saving or remove this
-		// return saveTableToFile(a.config.OutputFile, result)
+// outputResults renders the result in the configured format to the output
+// file, or to stdout
+func (a *Agent) outputResults(result *models.SizingResult) error {
+	return a.writeOutput(func(out io.Writer) error {
+		return a.writeResults(out, result)
+	}, "Results")
+}
+
+// outputSplit writes the part of the result of each account or
+// subscription to its own file beside the output file, with the estimate
+// recomputed for that part
+func (a *Agent) outputSplit(result *models.SizingResult) error {
+	weights, err := estimate.LoadWeights(a.config.Weights)
+	if err != nil {
+		return fmt.Errorf("failed to load weights: %w", err)
 	}
 
+	parts := report.SplitByAccount(result)
+	for _, part := range parts {
+		part.Estimate = weights.Estimate(part)
+		path := report.SplitPath(a.config.OutputFile, part.AccountCounts[0].ID)
+		if err := writeFile(path, a.sinkOptions(), func(out io.Writer) error {
+			return a.writeResults(out, part)
+		}); err != nil {
+			return err
+		}
+	}
+	a.progress.Status("✓ Results of %d accounts/subscriptions saved to: %s",
+		len(parts), a.sinkOptions().Path(report.SplitPath(a.config.OutputFile, "<account>")))
 	return nil
 }
 
-// outputJSON outputs results in JSON format
-func (a *Agent) outputJSON(result *models.SizingResult) error {
-	// Marshal the result to JSON with indentation
-	jsonData, err := json.MarshalIndent(result, "", "  ")
+// outputSplitByTenant writes each tenant's results to its own file instead
+// of the merged results
+func (a *Agent) outputSplitByTenant(result *models.SizingResult) error {
+	weights, err := estimate.LoadWeights(a.config.Weights)
 	if err != nil {
-		return fmt.Errorf("failed to marshal results to JSON: %w", err)
+		return fmt.Errorf("failed to load weights: %w", err)
 	}
 
-	// If output file is specified, write to file
-	if a.config.OutputFile != "" {
-		err = os.WriteFile(a.config.OutputFile, jsonData, 0644)
-		if err != nil {
-			return fmt.Errorf("failed to write JSON to file: %w", err)
+	parts := report.SplitByTenant(result)
+	for _, part := range parts {
+		part.Estimate = weights.Estimate(part)
+		if a.config.ManagementGroups {
+			part.ManagementGroups = models.GroupByManagementGroup(part)
 		}
-		fmt.Printf("\n✓ Results saved to: %s\n", a.config.OutputFile)
-	} else {
-		// Otherwise print to stdout
-		fmt.Println(string(jsonData))
+		path := report.SplitPath(a.config.OutputFile, part.AccountCounts[0].Tenant)
+		if err := writeFile(path, a.sinkOptions(), func(out io.Writer) error {
+			return a.writeResults(out, part)
+		}); err != nil {
+			return err
+		}
+	}
+	a.progress.Status("✓ Results of %d tenants saved to: %s",
+		len(parts), a.sinkOptions().Path(report.SplitPath(a.config.OutputFile, "<tenant>")))
+	return nil
+}
+
+// openOutput opens the output file, or stdout when none is set, for output
+// written while the scan runs. closeOut may be called more than once.
+func (a *Agent) openOutput() (out io.Writer, closeOut func() error, err error) {
+	if a.config.OutputFile == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+
+	file, err := sink.Create(a.config.OutputFile, a.sinkOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+	var once sync.Once
+	var closeErr error
+	return file, func() error {
+		once.Do(func() { closeErr = file.Close() })
+		return closeErr
+	}, nil
+}
+
+// writeOutput runs write on the output file, or on stdout when none is
+// set, and reports what was saved
+func (a *Agent) writeOutput(write func(io.Writer) error, what string) error {
+	if a.config.OutputFile == "" {
+		return write(os.Stdout)
 	}
 
+	if err := writeFile(a.config.OutputFile, a.sinkOptions(), write); err != nil {
+		return err
+	}
+	a.savedOutput(what)
 	return nil
 }
+
+// savedOutput reports the output file written, and how to read it back
+// when it was compressed or encrypted
+func (a *Agent) savedOutput(what string) {
+	options := a.sinkOptions()
+	path := options.Path(a.config.OutputFile)
+	a.progress.Status("\n✓ %s saved to: %s", what, path)
+
+	switch {
+	case options.Passphrase != "":
+		key := "<passphrase>"
+		if a.config.EncryptKeySource != "" {
+			key = a.config.EncryptKeySource
+		}
+		a.progress.Status("  Decrypt with: sizing-agent decrypt --key %s --output %s %s", key, a.config.OutputFile, path)
+	case options.Compress:
+		a.progress.Status("  Decompress with: gunzip %s", path)
+	}
+}
+
+// sinkOptions returns how output files are compressed and encrypted
+func (a *Agent) sinkOptions() sink.Options {
+	return sink.Options{Compress: a.config.Compress, Passphrase: a.config.EncryptKey}
+}
+
+// writeFile runs write on a new file at path, or at options.Path(path) when
+// compressed or encrypted
+func writeFile(path string, options sink.Options, write func(io.Writer) error) error {
+	file, err := sink.Create(path, options)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeResults renders the result to out with the configured reporter
+func (a *Agent) writeResults(out io.Writer, result *models.SizingResult) error {
+	reporter, err := report.New(a.config.OutputFormat, out, a.progress, report.Options{
+		Verbose:    a.config.Verbose,
+		LegacyJSON: a.config.LegacyJSON,
+		Stats:      a.config.Stats,
+		ASCII:      a.config.ASCII,
+		Language:   report.Locale(),
+	})
+	if err != nil {
+		return err
+	}
+	return reporter.Write(result)
+}
@@ -0,0 +1,452 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/internal/sink"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// fakeProvider returns a fixed result without any cloud calls
+type fakeProvider struct{}
+
+func (fakeProvider) Name() string                  { return "AWS" }
+func (fakeProvider) Connect(context.Context) error { return nil }
+func (fakeProvider) Close() error                  { return nil }
+func (fakeProvider) CountResources(context.Context) (*models.SizingResult, error) {
+	return &models.SizingResult{
+		SchemaVersion: models.SchemaVersion,
+		Provider:      "AWS",
+		Timestamp:     time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+		ResourceCounts: []*models.ResourceCount{{
+			Provider: "aws", Type: "ec2:instance", DisplayName: "EC2 Instances", TotalResources: 2,
+			ByLocation: map[string]int{"us-east-1": 2}, ByAccount: map[string]int{"111111111111": 2},
+		}},
+		AccountCounts:  []models.AccountCount{{ID: "111111111111", Status: "ACTIVE", ResourceCount: 2}},
+		TotalResources: 2,
+		TotalAccounts:  1,
+	}, nil
+}
+
+func (fakeProvider) Accounts() *models.AccountList {
+	return models.NewAccountList("AWS", models.AccountSourceOrganizations,
+		[]models.AccountCount{{ID: "111111111111", Name: "prod-main", Status: "ACTIVE"}})
+}
+
+func (fakeProvider) Plan() (*models.ScanPlan, error) {
+	plan := &models.ScanPlan{
+		Provider: "AWS",
+		Accounts: []models.AccountCount{{ID: "111111111111", Status: "ACTIVE"}},
+		Regions:  []string{"us-east-1"},
+	}
+	plan.AddType(models.PlannedType{
+		Type: "ec2:instance", DisplayName: "EC2 Instances", CountMethod: models.CountMethodServiceAPI,
+		API: "ec2:DescribeInstances", EstimatedCalls: 1,
+	})
+	return plan, nil
+}
+
+// captureOutput redirects the process stdout and stderr to temporary files
+// while fn runs and returns what was written to each
+func captureOutput(t *testing.T, fn func()) (stdout, stderr string) {
+	t.Helper()
+	dir := t.TempDir()
+	outFile, err := os.Create(dir + "/stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errFile, err := os.Create(dir + "/stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savedOut, savedErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outFile, errFile
+	defer func() { os.Stdout, os.Stderr = savedOut, savedErr }()
+	fn()
+
+	out, _ := os.ReadFile(outFile.Name())
+	errOut, _ := os.ReadFile(errFile.Name())
+	_ = outFile.Close()
+	_ = errFile.Close()
+	return string(out), string(errOut)
+}
+
+func TestRunJSONStdout(t *testing.T) {
+	tests := []struct {
+		name       string
+		quiet      bool
+		wantStderr string
+	}{
+		{name: "default", wantStderr: "Secrails Sizing Agent"},
+		{name: "quiet", quiet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runErr error
+			stdout, stderr := captureOutput(t, func() {
+				agent := New(&Config{Provider: "aws", OutputFormat: "json", Quiet: tt.quiet})
+				agent.getProvider = func(cfg config.ProviderConfig) (providers.Provider, error) {
+					cfg.APICalls.Add("STS", "GetCallerIdentity")
+					return fakeProvider{}, nil
+				}
+				runErr = agent.Run(context.Background())
+			})
+			if runErr != nil {
+				t.Fatalf("Run() error = %v", runErr)
+			}
+
+			// stdout holds exactly one JSON document and nothing else
+			decoder := json.NewDecoder(strings.NewReader(stdout))
+			var result models.SizingResult
+			if err := decoder.Decode(&result); err != nil {
+				t.Fatalf("stdout is not JSON: %v\n%s", err, stdout)
+			}
+			var extra json.RawMessage
+			if err := decoder.Decode(&extra); !errors.Is(err, io.EOF) {
+				t.Errorf("stdout holds more than one JSON value:\n%s", stdout)
+			}
+			if result.TotalResources != 2 || result.BillableWorkloads != 2 {
+				t.Errorf("result total_resources = %d, billable_workloads = %d, want 2 and 2",
+					result.TotalResources, result.BillableWorkloads)
+			}
+			if result.AgentVersion == "" || len(result.APICalls) != 1 {
+				t.Errorf("result agent_version = %q, api_calls = %+v", result.AgentVersion, result.APICalls)
+			}
+
+			if tt.wantStderr == "" && stderr != "" {
+				t.Errorf("quiet run wrote to stderr:\n%s", stderr)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr %q does not contain %q", stderr, tt.wantStderr)
+			}
+			if bytes.ContainsRune([]byte(stdout), '🚀') {
+				t.Error("banner written to stdout")
+			}
+		})
+	}
+}
+
+// warningProvider logs a warning while counting, as collectors do when a
+// count degrades
+type warningProvider struct{ fakeProvider }
+
+func (p warningProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
+	logging.Warn("Reached max pages", zap.String("region", "us-east-1"))
+	return p.fakeProvider.CountResources(ctx)
+}
+
+func TestRunLogWarnings(t *testing.T) {
+	tests := []struct {
+		name      string
+		anonymize bool
+		want      []models.LogWarning
+	}{
+		{
+			name: "fields",
+			want: []models.LogWarning{{Level: "warn", Message: "Reached max pages", Fields: map[string]string{"region": "us-east-1"}}},
+		},
+		{
+			name:      "anonymized",
+			anonymize: true,
+			want:      []models.LogWarning{{Level: "warn", Message: "Reached max pages"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runErr error
+			stdout, _ := captureOutput(t, func() {
+				agent := New(&Config{Provider: "aws", OutputFormat: "json", Quiet: true, Anonymize: tt.anonymize})
+				agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+					return warningProvider{}, nil
+				}
+				runErr = agent.Run(context.Background())
+			})
+			if runErr != nil {
+				t.Fatalf("Run() error = %v", runErr)
+			}
+
+			var result models.SizingResult
+			if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+				t.Fatalf("stdout is not JSON: %v\n%s", err, stdout)
+			}
+			if !reflect.DeepEqual(result.LogWarnings, tt.want) {
+				t.Errorf("log_warnings = %+v, want %+v", result.LogWarnings, tt.want)
+			}
+		})
+	}
+}
+
+// inventoryProvider streams one resource to the inventory while counting
+type inventoryProvider struct {
+	fakeProvider
+	inventory models.ResourceSink
+}
+
+func (p inventoryProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
+	err := p.inventory.Write(models.Resource{
+		ID: "arn:aws:ec2:us-east-1:111111111111:instance/i-0abc", Name: "payroll-db", Type: "ec2:instance",
+		Provider: "aws", Region: "us-east-1", Account: "111111111111", Tags: map[string]string{"owner": "jane"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.fakeProvider.CountResources(ctx)
+}
+
+func TestRunAnonymizesInventory(t *testing.T) {
+	dir := t.TempDir()
+	output, inventoryOutput := dir+"/sizing.json", dir+"/inventory.ndjson"
+	var runErr error
+	captureOutput(t, func() {
+		agent := New(&Config{Provider: "aws", OutputFormat: "json", OutputFile: output, Quiet: true, Anonymize: true,
+			Inventory: true, InventoryOutput: inventoryOutput, InventoryFormat: "ndjson"})
+		agent.getProvider = func(cfg config.ProviderConfig) (providers.Provider, error) {
+			return inventoryProvider{inventory: cfg.Inventory}, nil
+		}
+		runErr = agent.Run(context.Background())
+	})
+	if runErr != nil {
+		t.Fatalf("Run() error = %v", runErr)
+	}
+
+	data, err := os.ReadFile(inventoryOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leaked := range []string{"111111111111", "payroll-db", "jane"} {
+		if bytes.Contains(data, []byte(leaked)) {
+			t.Errorf("inventory contains %q:\n%s", leaked, data)
+		}
+	}
+
+	var resource models.Resource
+	if err := json.Unmarshal(data, &resource); err != nil {
+		t.Fatal(err)
+	}
+	resultData, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result models.SizingResult
+	if err := json.Unmarshal(resultData, &result); err != nil {
+		t.Fatal(err)
+	}
+	if resource.Account != result.AccountCounts[0].ID {
+		t.Errorf("inventory account %s does not match the result's %s", resource.Account, result.AccountCounts[0].ID)
+	}
+}
+
+func TestBillableTypes(t *testing.T) {
+	definitions := []models.ResourceDefinition{
+		{Type: "ec2:instance", Billable: true},
+		{Type: "s3:bucket", Billable: true},
+		{Type: "ec2:security-group"},
+	}
+
+	tests := []struct {
+		name     string
+		override []string
+		want     []models.ResourceType
+	}{
+		{name: "definitions", want: []models.ResourceType{"ec2:instance", "s3:bucket"}},
+		{name: "override", override: []string{"ec2:security-group"}, want: []models.ResourceType{"ec2:security-group"}},
+		{name: "override with unknown type", override: []string{"ec2:instance", "ec2:volume"},
+			want: []models.ResourceType{"ec2:instance", "ec2:volume"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := New(&Config{Provider: "aws", Quiet: true, BillableTypes: tt.override})
+			got := agent.billableTypes(definitions)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("billableTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	var runErr error
+	stdout, _ := captureOutput(t, func() {
+		agent := New(&Config{Provider: "aws", OutputFormat: "json", DryRun: true, Quiet: true})
+		agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+			return fakeProvider{}, nil
+		}
+		runErr = agent.Run(context.Background())
+	})
+	if runErr != nil {
+		t.Fatalf("Run() error = %v", runErr)
+	}
+
+	// stdout holds the plan, not a result
+	var plan map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stdout), &plan); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, stdout)
+	}
+	if _, ok := plan["resource_types"]; !ok {
+		t.Errorf("plan has no resource_types:\n%s", stdout)
+	}
+	if _, ok := plan["total_resources"]; ok {
+		t.Errorf("dry run wrote a sizing result:\n%s", stdout)
+	}
+	if string(plan["estimated_api_calls"]) != "1" {
+		t.Errorf("estimated_api_calls = %s, want 1", plan["estimated_api_calls"])
+	}
+}
+
+func TestRunAccountsOnly(t *testing.T) {
+	var runErr error
+	stdout, _ := captureOutput(t, func() {
+		agent := New(&Config{Provider: "aws", OutputFormat: "csv", AccountsOnly: true, Quiet: true})
+		agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+			return fakeProvider{}, nil
+		}
+		runErr = agent.Run(context.Background())
+	})
+	if runErr != nil {
+		t.Fatalf("Run() error = %v", runErr)
+	}
+
+	want := "provider,source,tenant_id,id,name,email,status\nAWS,organizations,,111111111111,prod-main,,ACTIVE\n"
+	if stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+}
+
+func TestRunSplitByAccount(t *testing.T) {
+	output := t.TempDir() + "/sizing.json"
+	var runErr error
+	captureOutput(t, func() {
+		agent := New(&Config{Provider: "aws", OutputFormat: "json", OutputFile: output, SplitByAccount: true, Quiet: true})
+		agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+			return fakeProvider{}, nil
+		}
+		runErr = agent.Run(context.Background())
+	})
+	if runErr != nil {
+		t.Fatalf("Run() error = %v", runErr)
+	}
+
+	data, err := os.ReadFile(strings.TrimSuffix(output, ".json") + "-111111111111.json")
+	if err != nil {
+		t.Fatalf("account result not written: %v", err)
+	}
+	var result models.SizingResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalResources != 2 || len(result.AccountCounts) != 1 {
+		t.Errorf("account result total_resources = %d, account_counts = %+v", result.TotalResources, result.AccountCounts)
+	}
+	if result.Estimate == nil || result.Estimate.WorkloadUnits == 0 {
+		t.Errorf("account result estimate = %+v, want one computed for the account", result.Estimate)
+	}
+}
+
+func TestRunCompressedEncrypted(t *testing.T) {
+	for _, format := range []string{"json", "ndjson"} {
+		t.Run(format, func(t *testing.T) {
+			output := t.TempDir() + "/sizing." + format
+			var runErr error
+			_, stderr := captureOutput(t, func() {
+				agent := New(&Config{
+					Provider: "aws", OutputFormat: format, OutputFile: output,
+					Compress: true, EncryptKey: "secret", EncryptKeySource: "file:key.txt",
+				})
+				agent.getProvider = func(cfg config.ProviderConfig) (providers.Provider, error) {
+					if cfg.Counts == nil {
+						return fakeProvider{}, nil
+					}
+					return streamingProvider{counts: cfg.Counts}, nil
+				}
+				runErr = agent.Run(context.Background())
+			})
+			if runErr != nil {
+				t.Fatalf("Run() error = %v", runErr)
+			}
+
+			written := output + ".gz.enc"
+			in, _, err := sink.Open(written, "secret")
+			if err != nil {
+				t.Fatalf("output not written to %s: %v", written, err)
+			}
+			defer in.Close()
+			data, err := io.ReadAll(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(data, []byte(`"total_resources":2`)) && !bytes.Contains(data, []byte(`"total_resources": 2`)) {
+				t.Errorf("decrypted output does not hold the result:\n%s", data)
+			}
+			want := "decrypt --key file:key.txt --output " + output + " " + written
+			if !strings.Contains(stderr, want) {
+				t.Errorf("stderr %q does not contain %q", stderr, want)
+			}
+		})
+	}
+}
+
+func TestRunNDJSONStreamsCounts(t *testing.T) {
+	var runErr error
+	stdout, _ := captureOutput(t, func() {
+		agent := New(&Config{Provider: "aws", OutputFormat: "ndjson", Quiet: true})
+		agent.getProvider = func(cfg config.ProviderConfig) (providers.Provider, error) {
+			return streamingProvider{counts: cfg.Counts}, nil
+		}
+		runErr = agent.Run(context.Background())
+	})
+	if runErr != nil {
+		t.Fatalf("Run() error = %v", runErr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("stdout = %q, want a count line and a summary line", stdout)
+	}
+	var count struct {
+		Record        string                `json:"record"`
+		ResourceCount *models.ResourceCount `json:"resource_count"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &count); err != nil || count.Record != "resource_count" {
+		t.Errorf("first line = %q, want the streamed count", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `{"record":"summary"`) {
+		t.Errorf("last line = %q, want the summary", lines[1])
+	}
+}
+
+// streamingProvider passes its count to the count sink while counting, as
+// the real providers do
+type streamingProvider struct {
+	fakeProvider
+	counts models.CountSink
+}
+
+func (p streamingProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
+	result, err := p.fakeProvider.CountResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, rc := range result.ResourceCounts {
+		if err := p.counts.WriteCount(rc); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
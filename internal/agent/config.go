@@ -1,9 +1,289 @@
 package agent
 
+import (
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"go.uber.org/zap"
+)
+
 // Config holds the configuration for the sizing agent
 type Config struct {
 	Provider     string
 	OutputFormat string
 	OutputFile   string
 	Verbose      bool
+
+	// Quiet drops banners and status lines; warnings and errors remain
+	Quiet bool
+
+	// LogLevel is the level of the log lines written to stderr; empty keeps
+	// the default, warn with Quiet and info otherwise
+	LogLevel string
+
+	// NoRedact logs account IDs, GUIDs, ARNs, names and tags unmasked
+	NoRedact bool
+
+	// Logger receives the log entries of the scan instead of the default
+	// logger, masked and captured like its own; nil keeps the default
+	Logger *zap.Logger
+
+	// ASCII replaces the emoji and other symbols of the table and progress
+	// output
+	ASCII bool
+
+	// LegacyJSON writes the untagged version 1 JSON layout for consumers
+	// that have not migrated yet; deprecated
+	LegacyJSON bool
+
+	// DryRun authenticates and discovers, then writes the scan plan instead
+	// of counting
+	DryRun bool
+
+	// Stats writes per-type durations, pages and retries with the result
+	Stats bool
+
+	// AccountsOnly authenticates and discovers, then writes the account or
+	// subscription list without counting
+	AccountsOnly bool
+
+	// SplitByAccount also writes the part of the result of each account or
+	// subscription to its own file beside OutputFile
+	SplitByAccount bool
+
+	// Compress gzips the output and inventory files
+	Compress bool
+
+	// EncryptKey encrypts the output and inventory files with this
+	// passphrase; EncryptKeySource is the file: or env: value it was read
+	// from, if any, and is named in the decryption instructions instead of
+	// the passphrase
+	EncryptKey       string
+	EncryptKeySource string
+
+	// ResourceDefinitions is an optional YAML file that merges with or
+	// replaces the embedded resource definitions; StrictDefinitions fails
+	// the scan on types the provider does not know, rather than warning
+	ResourceDefinitions string
+	StrictDefinitions   bool
+
+	// Inventory enables listing individual resources in addition to counts
+	Inventory       bool
+	InventoryFormat string
+	InventoryOutput string
+
+	// DebugDump is a directory receiving every raw API response of the
+	// scan, at most DebugDumpMaxMB megabytes, with tag values and the
+	// DebugDumpRedact fields redacted
+	DebugDump       string
+	DebugDumpMaxMB  int
+	DebugDumpRedact []string
+
+	// Anonymize replaces account identifiers and names in the result;
+	// AnonymizeMap optionally records the mapping to a local file
+	Anonymize    bool
+	AnonymizeMap string
+
+	// Weights is an optional YAML file overriding the workload-unit weights
+	Weights string
+
+	// MaxResources and MaxAccounts fail the run with ExitThresholdExceeded
+	// when exceeded; zero disables the check
+	MaxResources int
+	MaxAccounts  int
+
+	// Profile is the AWS shared config profile; empty uses AWS_PROFILE or the
+	// default credential chain
+	Profile string
+
+	// Profiles scans each of these AWS profiles with its own credentials
+	// and merges the results; it replaces Profile
+	Profiles []string
+
+	// Region is the AWS region used for discovery calls; empty falls back to
+	// the AWS config file, AWS_REGION/AWS_DEFAULT_REGION, then us-east-1
+	Region string
+
+	// Regions limits an AWS scan to these regions, bypassing region discovery
+	// and the accessibility check
+	Regions []string
+
+	// Subscriptions limits an Azure scan to these subscription IDs
+	Subscriptions []string
+
+	// AccountFilter limits the scan to the discovered AWS accounts or Azure
+	// subscriptions matching its name patterns and tags
+	AccountFilter models.AccountFilter
+
+	// Kubeconfig is the kubeconfig file of a Kubernetes scan, and
+	// KubeContexts the contexts to scan instead of the current one
+	Kubeconfig   string
+	KubeContexts []string
+
+	// OCIAuth is the OCI authentication method, and OCIConfigFile and
+	// OCIProfile the config file and profile it reads
+	OCIAuth       string
+	OCIConfigFile string
+	OCIProfile    string
+
+	// AssumeRoleARN is an AWS role to assume for the scan, with ExternalID
+	// and MFASerial passed along if set
+	AssumeRoleARN string
+	ExternalID    string
+	MFASerial     string
+
+	// MFATokenProvider reads MFA codes; set only when running interactively
+	MFATokenProvider func() (string, error)
+
+	// CABundle adds PEM root certificates trusted by both SDKs, for
+	// proxies that intercept TLS
+	CABundle string
+
+	// Proxy sends SDK requests through this URL instead of HTTPS_PROXY
+	Proxy string
+
+	// AWSEndpointURL, AWSEndpoints and AzureEndpointURL send API calls to
+	// other URLs than the public cloud endpoints (see config.ProviderConfig)
+	AWSEndpointURL   string
+	AWSEndpoints     map[string]string
+	AzureEndpointURL string
+
+	// AzureAuthorityHost is the Microsoft Entra ID URL Azure tokens are
+	// requested from
+	AzureAuthorityHost string
+
+	// TenantID pins Azure credentials to this tenant
+	TenantID string
+
+	// AzureAuth selects the Azure authentication method (see azure.AuthMethods)
+	AzureAuth string
+
+	// Azure service principal settings, set from the config file; secrets
+	// and passwords only ever come from the environment
+	AzureClientID              string
+	AzureClientCertificatePath string
+	AzureFederatedTokenFile    string
+
+	// AzureTenants scans each of these tenants with its own credentials and
+	// merges the results, or writes one result per tenant with
+	// SplitByTenant; it replaces TenantID
+	AzureTenants []AzureTenant
+
+	// TenantConcurrency bounds the tenants of AzureTenants counted at once
+	TenantConcurrency int
+
+	// SplitByTenant writes each tenant's results to its own file beside
+	// OutputFile instead of the merged results
+	SplitByTenant bool
+
+	// Tags limits the scan to resources carrying all of these tags
+	Tags []models.TagFilter
+
+	// GroupAccountsByTag subtotals the resources per value of this
+	// account/subscription tag
+	GroupAccountsByTag string
+
+	// BillableTypes replaces the definitions marked billable as the types
+	// counted as billable workloads
+	BillableTypes []string
+
+	// CacheDir keeps account/subscription and region discovery from earlier
+	// runs for CacheTTL; nothing is cached when it is empty or with NoCache
+	CacheDir string
+	CacheTTL time.Duration
+	NoCache  bool
+
+	// UpdateCheck looks up the latest agent release, cached in CacheDir,
+	// when Run starts: an outdated build gets a notice and the release is
+	// recorded in the result
+	UpdateCheck bool
+
+	// Offline keeps the scan to the cloud providers' APIs, turning off the
+	// update check and ruling out metrics push and trace export; see
+	// ApplyOffline
+	Offline bool
+
+	// MetricsPushURL pushes Prometheus metrics to a Pushgateway after the
+	// scan; MetricsListen serves them on /metrics until scraped once after
+	// the scan or MetricsListenTimeout elapses
+	MetricsPushURL       string
+	MetricsListen        string
+	MetricsListenTimeout time.Duration
+
+	// OTelEndpoint enables OTLP/HTTP trace export to this URL; the standard
+	// OTEL_EXPORTER_OTLP_* variables also enable it
+	OTelEndpoint string
+
+	// History appends a summary line per completed scan to this file
+	History string
+
+	// Schedule keeps the agent resident and scans on this interval or cron
+	// expression; empty runs a single scan
+	Schedule string
+
+	// IncludeIdentity adds the users, groups and applications of an
+	// identity provider directory: okta, or entra on Azure
+	IncludeIdentity string
+
+	// OktaOrgURL is the Okta org counted with IncludeIdentity okta, and
+	// OktaAPIToken the token it is read with
+	OktaOrgURL   string
+	OktaAPIToken string
+
+	// ManagementGroups adds subtotals by management group (Azure only)
+	ManagementGroups bool
+
+	// IncludeSuspended counts suspended AWS accounts in the account total
+	IncludeSuspended bool
+
+	// NoRegionPrecheck scans AWS regions the probe found empty as well
+	NoRegionPrecheck bool
+
+	// ExcludeNoise leaves cloud-managed resources out of the counts
+	ExcludeNoise bool
+
+	// DeepRegistries counts the images and repositories inside container
+	// registries
+	DeepRegistries bool
+
+	// DeepStorage counts the contents of Azure storage accounts
+	DeepStorage bool
+
+	// DeepBackup counts the backup and replicated items of Azure Recovery
+	// Services vaults
+	DeepBackup bool
+
+	// RetryFailed is the number of passes retrying the resource types whose
+	// count failed (0-3)
+	RetryFailed int
+
+	// PageSize is the page size of the AWS tagging API and Azure Resource
+	// Graph, and MaxPages the pages read per type before its count stops
+	// as truncated; 0 keeps the provider's default
+	PageSize int
+	MaxPages int
+
+	// TypeTimeout bounds each attempt at counting a resource type; 0
+	// leaves it unbounded
+	TypeTimeout time.Duration
+
+	// RegionFailureThreshold is the number of consecutive failed counts
+	// after which an AWS region is skipped for the remaining types; 0
+	// never skips
+	RegionFailureThreshold int
+}
+
+// AzureTenant is one tenant of a multi-tenant Azure scan and the
+// credentials to sign in to it; settings left empty keep the scan's
+type AzureTenant struct {
+	TenantID      string
+	AzureAuth     string
+	Subscriptions []string
+
+	// Service principal settings; the secret is read from the environment
+	// variable ClientSecretEnv names
+	ClientID              string
+	ClientCertificatePath string
+	FederatedTokenFile    string
+	ClientSecretEnv       string
 }
@@ -0,0 +1,230 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/report"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Rough bounds of the time one API call takes, paging, throttling and
+// retries included. The plan counts the least number of calls, so the
+// high bound allows for the extra pages of large estates.
+const (
+	callLatencyLow  = 300 * time.Millisecond
+	callLatencyHigh = 1500 * time.Millisecond
+)
+
+// longScan is the estimated duration from which the estimate is printed
+// before counting
+const longScan = time.Minute
+
+// The progress line shows the time left once etaAfter resource types are
+// counted, then at most every etaInterval
+const (
+	etaAfter    = 3
+	etaInterval = 30 * time.Second
+)
+
+// scanEstimate is the expected size and duration of a scan, derived from the
+// plans of its providers
+type scanEstimate struct {
+	calls    int
+	regions  int
+	accounts int
+	// noun names the accounts of the provider, e.g. "subscriptions"
+	noun      string
+	low, high time.Duration
+}
+
+// estimateScan estimates a scan counting plans, of which parallel are
+// counted at once
+func estimateScan(plans []*models.ScanPlan, parallel int) scanEstimate {
+	estimate := scanEstimate{noun: "accounts"}
+	regions := make(map[string]bool)
+	concurrency := 0
+	for _, plan := range plans {
+		estimate.calls += plan.EstimatedAPICalls
+		estimate.accounts += len(plan.Accounts)
+		for _, region := range plan.Regions {
+			regions[region] = true
+		}
+		concurrency = max(concurrency, plan.Concurrency)
+		switch plan.Provider {
+		case "Azure":
+			estimate.noun = "subscriptions"
+		case "Kubernetes":
+			estimate.noun = "clusters"
+		case "OCI":
+			estimate.noun = "compartments"
+		}
+	}
+	estimate.regions = len(regions)
+
+	// Calls are spread over the concurrency of each provider, and over the
+	// providers counted at once
+	inFlight := max(concurrency, 1) * max(min(parallel, len(plans)), 1)
+	estimate.low = callLatencyLow * time.Duration(estimate.calls) / time.Duration(inFlight)
+	estimate.high = callLatencyHigh * time.Duration(estimate.calls) / time.Duration(inFlight)
+	return estimate
+}
+
+// String describes the estimate, e.g. "about 3,400 API calls across 17
+// regions, estimated 12-20 minutes at current concurrency"
+func (e scanEstimate) String() string {
+	scope := ""
+	switch {
+	case e.regions > 0 && e.accounts > 1:
+		scope = fmt.Sprintf(" across %d regions and %d %s", e.regions, e.accounts, e.noun)
+	case e.regions > 0:
+		scope = fmt.Sprintf(" across %d regions", e.regions)
+	case e.accounts > 0:
+		scope = fmt.Sprintf(" across %d %s", e.accounts, e.noun)
+	}
+	return fmt.Sprintf("about %s API calls%s, estimated %s at current concurrency",
+		roundCalls(e.calls), scope, durationRange(e.low, e.high))
+}
+
+// roundCalls rounds calls to two significant digits, with thousands
+// separators, as the estimate is no more precise
+func roundCalls(calls int) string {
+	if calls >= 100 {
+		scale := math.Pow(10, math.Floor(math.Log10(float64(calls)))-1)
+		calls = int(math.Round(float64(calls)/scale) * scale)
+	}
+	return message.NewPrinter(language.English).Sprintf("%d", calls)
+}
+
+// durationRange renders a range of durations in whole minutes, e.g.
+// "12-20 minutes", with a hyphen that --ascii output needs no replacement
+// for
+func durationRange(low, high time.Duration) string {
+	if high < time.Minute {
+		return "under a minute"
+	}
+	lowMinutes := max(int(low/time.Minute), 1)
+	highMinutes := int(math.Ceil(high.Minutes()))
+	if lowMinutes >= highMinutes {
+		return fmt.Sprintf("about %s", minutes(highMinutes))
+	}
+	return fmt.Sprintf("%d-%s", lowMinutes, minutes(highMinutes))
+}
+
+// minutes renders a number of minutes
+func minutes(n int) string {
+	if n == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", n)
+}
+
+// timeLeft extrapolates the time left from the elapsed time, in which done
+// of total units of work, calls or types, were completed
+func timeLeft(elapsed time.Duration, done, total int) time.Duration {
+	if done <= 0 || done >= total {
+		return 0
+	}
+	return elapsed * time.Duration(total-done) / time.Duration(done)
+}
+
+// etaTracker passes resource counts on to the next sink and, once the plan
+// is known, reports in the progress how many types are counted and the time
+// left, weighing each type by its planned calls
+type etaTracker struct {
+	next     models.CountSink
+	progress report.Progress
+	now      func() time.Time
+
+	mu      sync.Mutex
+	started time.Time
+	// planned holds the calls of each type over all plans, and the number
+	// of plans counting it
+	planned    map[models.ResourceType]plannedCalls
+	types      int
+	totalCalls int
+	done       int
+	doneCalls  int
+	reported   time.Time
+}
+
+// plannedCalls are the calls a resource type is planned with in times
+// plans, e.g. one per AWS profile of a multi-profile scan
+type plannedCalls struct {
+	calls int
+	times int
+}
+
+// newETATracker returns a tracker passing counts on to next, if not nil
+func newETATracker(next models.CountSink, progress report.Progress) *etaTracker {
+	return &etaTracker{next: next, progress: progress, now: time.Now}
+}
+
+// start prints the estimate of plans when the scan looks long, and starts
+// tracking the types they count
+func (t *etaTracker) start(plans []*models.ScanPlan, parallel int) {
+	estimate := estimateScan(plans, parallel)
+	if estimate.high >= longScan {
+		t.progress.Status("Scan size: %s", estimate)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = t.now()
+	t.planned = make(map[models.ResourceType]plannedCalls)
+	for _, plan := range plans {
+		for _, planned := range plan.ResourceTypes {
+			calls := t.planned[planned.Type]
+			calls.calls += planned.EstimatedCalls
+			calls.times++
+			t.planned[planned.Type] = calls
+			t.types++
+		}
+		t.totalCalls += plan.EstimatedAPICalls
+	}
+}
+
+func (t *etaTracker) WriteCount(count *models.ResourceCount) error {
+	t.track(count.Type)
+	if t.next == nil {
+		return nil
+	}
+	return t.next.WriteCount(count)
+}
+
+// track records that a count of resourceType completed. Counts of types
+// outside the plans, such as the contents of deep scans, are not tracked.
+func (t *etaTracker) track(resourceType models.ResourceType) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	calls, ok := t.planned[resourceType]
+	if !ok {
+		return
+	}
+	t.done++
+	t.doneCalls += calls.calls / calls.times
+
+	now := t.now()
+	if t.done < etaAfter || t.done >= t.types || now.Sub(t.reported) < etaInterval {
+		return
+	}
+	t.reported = now
+	// Weigh by types alone while the counted ones planned no calls
+	left := timeLeft(now.Sub(t.started), t.done, t.types)
+	if t.doneCalls > 0 {
+		left = timeLeft(now.Sub(t.started), t.doneCalls, t.totalCalls)
+	}
+	t.progress.Status("Counted %d of %d resource types, %s left", t.done, t.types, durationLeft(left))
+}
+
+// durationLeft renders the time left, rounded up to the minute
+func durationLeft(left time.Duration) string {
+	if left < time.Minute {
+		return "under a minute"
+	}
+	return "about " + minutes(int(math.Ceil(left.Minutes())))
+}
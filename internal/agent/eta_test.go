@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// recordingProgress keeps the status lines it is given
+type recordingProgress struct {
+	lines []string
+}
+
+func (p *recordingProgress) Start(string) {}
+
+func (p *recordingProgress) Status(format string, args ...any) {
+	p.lines = append(p.lines, fmt.Sprintf(format, args...))
+}
+
+func (p *recordingProgress) Warn(format string, args ...any) {
+	p.lines = append(p.lines, "warning: "+fmt.Sprintf(format, args...))
+}
+
+// recordingSink keeps the types of the counts it is given
+type recordingSink struct {
+	types []models.ResourceType
+}
+
+func (s *recordingSink) WriteCount(count *models.ResourceCount) error {
+	s.types = append(s.types, count.Type)
+	return nil
+}
+
+// regionNames returns n distinct region names
+func regionNames(n int) []string {
+	regions := make([]string, n)
+	for i := range regions {
+		regions[i] = fmt.Sprintf("region-%d", i)
+	}
+	return regions
+}
+
+func TestEstimateScan(t *testing.T) {
+	tests := []struct {
+		name     string
+		plans    []*models.ScanPlan
+		parallel int
+		want     string
+	}{
+		{
+			name: "AWS regions",
+			plans: []*models.ScanPlan{{Provider: "AWS", Accounts: make([]models.AccountCount, 1),
+				Regions: regionNames(17), EstimatedAPICalls: 3412, Concurrency: 10}},
+			parallel: 1,
+			want:     "about 3,400 API calls across 17 regions, estimated 1-9 minutes at current concurrency",
+		},
+		{
+			name: "AWS organization",
+			plans: []*models.ScanPlan{{Provider: "AWS", Accounts: make([]models.AccountCount, 40),
+				Regions: regionNames(4), EstimatedAPICalls: 160, Concurrency: 10}},
+			parallel: 1,
+			want:     "about 160 API calls across 4 regions and 40 accounts, estimated under a minute at current concurrency",
+		},
+		{
+			name: "Azure tenants counted together",
+			plans: []*models.ScanPlan{
+				{Provider: "Azure", Accounts: make([]models.AccountCount, 12), EstimatedAPICalls: 450, Concurrency: 5},
+				{Provider: "Azure", Accounts: make([]models.AccountCount, 12), EstimatedAPICalls: 450, Concurrency: 5},
+			},
+			parallel: 2,
+			want:     "about 900 API calls across 24 subscriptions, estimated 1-3 minutes at current concurrency",
+		},
+		{
+			name:     "unknown concurrency",
+			plans:    []*models.ScanPlan{{Provider: "Kubernetes", Accounts: make([]models.AccountCount, 2), EstimatedAPICalls: 90}},
+			parallel: 1,
+			want:     "about 90 API calls across 2 clusters, estimated 1-3 minutes at current concurrency",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateScan(tt.plans, tt.parallel).String(); got != tt.want {
+				t.Errorf("estimateScan() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundCalls(t *testing.T) {
+	tests := map[int]string{0: "0", 7: "7", 99: "99", 164: "160", 1234: "1,200", 3456: "3,500", 99500: "100,000"}
+	for calls, want := range tests {
+		if got := roundCalls(calls); got != want {
+			t.Errorf("roundCalls(%d) = %q, want %q", calls, got, want)
+		}
+	}
+}
+
+func TestDurationRange(t *testing.T) {
+	tests := []struct {
+		low, high time.Duration
+		want      string
+	}{
+		{low: 10 * time.Second, high: 50 * time.Second, want: "under a minute"},
+		{low: 12*time.Minute + 40*time.Second, high: 19*time.Minute + 10*time.Second, want: "12-20 minutes"},
+		{low: 70 * time.Second, high: 80 * time.Second, want: "1-2 minutes"},
+		{low: 55 * time.Second, high: 60 * time.Second, want: "about 1 minute"},
+	}
+	for _, tt := range tests {
+		if got := durationRange(tt.low, tt.high); got != tt.want {
+			t.Errorf("durationRange(%s, %s) = %q, want %q", tt.low, tt.high, got, tt.want)
+		}
+	}
+}
+
+func TestTimeLeft(t *testing.T) {
+	tests := []struct {
+		elapsed     time.Duration
+		done, total int
+		want        time.Duration
+	}{
+		{elapsed: 10 * time.Minute, done: 1, total: 4, want: 30 * time.Minute},
+		{elapsed: 6 * time.Minute, done: 300, total: 400, want: 2 * time.Minute},
+		{elapsed: time.Minute, done: 0, total: 4, want: 0},
+		{elapsed: time.Minute, done: 4, total: 4, want: 0},
+	}
+	for _, tt := range tests {
+		if got := timeLeft(tt.elapsed, tt.done, tt.total); got != tt.want {
+			t.Errorf("timeLeft(%s, %d, %d) = %s, want %s", tt.elapsed, tt.done, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestETATracker(t *testing.T) {
+	progress := &recordingProgress{}
+	next := &recordingSink{}
+	tracker := newETATracker(next, progress)
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	plan := &models.ScanPlan{Provider: "AWS", Accounts: make([]models.AccountCount, 1), Regions: regionNames(20), Concurrency: 10}
+	for _, resourceType := range []models.ResourceType{"ec2:instance", "rds:db", "s3:bucket", "lambda:function", "ecs:cluster"} {
+		plan.AddType(models.PlannedType{Type: resourceType, EstimatedCalls: 200})
+	}
+	tracker.start([]*models.ScanPlan{plan}, 1)
+
+	write := func(elapsed time.Duration, resourceType models.ResourceType) {
+		now = time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC).Add(elapsed)
+		if err := tracker.WriteCount(&models.ResourceCount{Type: resourceType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(time.Minute, "ec2:instance")
+	write(90*time.Second, "microsoft.storage/storageaccounts/blobservices/containers")
+	write(2*time.Minute, "rds:db")
+	write(3*time.Minute, "s3:bucket")
+	write(3*time.Minute+10*time.Second, "lambda:function")
+	write(5*time.Minute, "ecs:cluster")
+
+	want := []string{
+		"Scan size: about 1,000 API calls across 20 regions, estimated 1-3 minutes at current concurrency",
+		"Counted 3 of 5 resource types, about 2 minutes left",
+	}
+	if !reflect.DeepEqual(progress.lines, want) {
+		t.Errorf("progress = %q, want %q", progress.lines, want)
+	}
+	if len(next.types) != 6 {
+		t.Errorf("next sink received %d counts, want all 6", len(next.types))
+	}
+}
+
+func TestETATrackerShortScan(t *testing.T) {
+	progress := &recordingProgress{}
+	tracker := newETATracker(nil, progress)
+	plan := &models.ScanPlan{Provider: "Azure", Accounts: make([]models.AccountCount, 1), Concurrency: 5}
+	plan.AddType(models.PlannedType{Type: "microsoft.compute/virtualmachines", EstimatedCalls: 1})
+	tracker.start([]*models.ScanPlan{plan}, 1)
+
+	if err := tracker.WriteCount(&models.ResourceCount{Type: "microsoft.compute/virtualmachines"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(progress.lines) != 0 {
+		t.Errorf("progress = %q, want no estimate for a short scan", progress.lines)
+	}
+}
@@ -0,0 +1,301 @@
+//go:build integration
+
+package agent
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeInstance is an EC2 instance returned by DescribeInstances
+type fakeInstance struct {
+	id, instanceType, state string
+}
+
+// fakeAWS answers the AWS API calls of a scan from canned data. JSON
+// protocol calls are routed by X-Amz-Target, query protocol calls by Action,
+// and the region is read from the request signature.
+type fakeAWS struct {
+	accountID string
+	alias     string
+	regions   []string
+
+	// denied regions refuse tagging API calls
+	denied map[string]bool
+
+	// instances by region
+	instances map[string][]fakeInstance
+
+	// tagged holds the ARNs of tagging API types by region and type
+	tagged map[string]map[string][]string
+
+	// pageSize limits GetResources pages so that pagination is exercised
+	pageSize int
+
+	mu         sync.Mutex
+	unexpected []string
+}
+
+var credentialScope = regexp.MustCompile(`Credential=[^/]+/[0-9]+/([^/]+)/`)
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	match := credentialScope.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		f.fail(w, "unsigned request %s %s", r.Method, r.URL)
+		return
+	}
+	region := match[1]
+
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		f.serveJSON(w, r, region, target[strings.LastIndex(target, ".")+1:])
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		f.fail(w, "unreadable form: %v", err)
+		return
+	}
+	f.serveQuery(w, region, r.Form.Get("Action"))
+}
+
+func (f *fakeAWS) serveJSON(w http.ResponseWriter, r *http.Request, region, operation string) {
+	switch operation {
+	case "DescribeOrganization":
+		writeAWSJSONError(w, "AWSOrganizationsNotInUseException", "Your account is not a member of an organization.")
+	case "GetResources":
+		if f.denied[region] {
+			writeAWSJSONError(w, "AccessDeniedException", "Access denied by a service control policy")
+			return
+		}
+		var input struct {
+			ResourceTypeFilters []string
+			PaginationToken     string
+			ResourcesPerPage    int
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			f.fail(w, "unreadable GetResources input: %v", err)
+			return
+		}
+		arns := f.taggedARNs(region, input.ResourceTypeFilters)
+		start, _ := strconv.Atoi(input.PaginationToken)
+		end := min(start+min(input.ResourcesPerPage, f.pageSize), len(arns))
+
+		type mapping struct {
+			ResourceARN string
+			Tags        []struct{}
+		}
+		output := struct {
+			ResourceTagMappingList []mapping
+			PaginationToken        string
+		}{ResourceTagMappingList: []mapping{}}
+		for _, arn := range arns[start:end] {
+			output.ResourceTagMappingList = append(output.ResourceTagMappingList, mapping{ResourceARN: arn, Tags: []struct{}{}})
+		}
+		if end < len(arns) {
+			output.PaginationToken = strconv.Itoa(end)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_ = json.NewEncoder(w).Encode(output)
+	default:
+		f.fail(w, "unexpected call %s in %s", operation, region)
+	}
+}
+
+// taggedARNs returns the ARNs of the given types in region, or of every type
+// when none is given, as the region probe asks
+func (f *fakeAWS) taggedARNs(region string, types []string) []string {
+	if len(types) == 0 {
+		for resourceType := range f.tagged[region] {
+			types = append(types, resourceType)
+		}
+		sort.Strings(types)
+	}
+	var arns []string
+	for _, resourceType := range types {
+		arns = append(arns, f.tagged[region][resourceType]...)
+	}
+	return arns
+}
+
+func (f *fakeAWS) serveQuery(w http.ResponseWriter, region, action string) {
+	var body strings.Builder
+	switch action {
+	case "GetCallerIdentity":
+		fmt.Fprintf(&body, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">`+
+			`<GetCallerIdentityResult><Arn>arn:aws:iam::%[1]s:user/scanner</Arn><UserId>AIDAEXAMPLE</UserId>`+
+			`<Account>%[1]s</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`, f.accountID)
+	case "ListAccountAliases":
+		fmt.Fprintf(&body, `<ListAccountAliasesResponse><ListAccountAliasesResult>`+
+			`<AccountAliases><member>%s</member></AccountAliases><IsTruncated>false</IsTruncated>`+
+			`</ListAccountAliasesResult></ListAccountAliasesResponse>`, f.alias)
+	case "DescribeRegions":
+		body.WriteString(`<DescribeRegionsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><regionInfo>`)
+		for _, name := range f.regions {
+			fmt.Fprintf(&body, `<item><regionName>%s</regionName><optInStatus>opt-in-not-required</optInStatus></item>`, name)
+		}
+		body.WriteString(`</regionInfo></DescribeRegionsResponse>`)
+	case "DescribeInstances":
+		body.WriteString(`<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><reservationSet>`)
+		for _, instance := range f.instances[region] {
+			fmt.Fprintf(&body, `<item><reservationId>r-%[1]s</reservationId><instancesSet><item>`+
+				`<instanceId>%[1]s</instanceId><instanceType>%[2]s</instanceType>`+
+				`<instanceState><name>%[3]s</name></instanceState></item></instancesSet></item>`,
+				instance.id, instance.instanceType, instance.state)
+		}
+		body.WriteString(`</reservationSet></DescribeInstancesResponse>`)
+	default:
+		f.fail(w, "unexpected call %s in %s", action, region)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write([]byte(body.String()))
+}
+
+// fail records a request the fake cannot answer and rejects it with an error
+// the SDKs do not retry
+func (f *fakeAWS) fail(w http.ResponseWriter, format string, args ...any) {
+	f.mu.Lock()
+	f.unexpected = append(f.unexpected, fmt.Sprintf(format, args...))
+	f.mu.Unlock()
+	http.Error(w, "unexpected request", http.StatusNotImplemented)
+}
+
+func writeAWSJSONError(w http.ResponseWriter, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"__type": code, "Message": message})
+}
+
+// fakeSubscription is a subscription returned by the subscriptions API
+type fakeSubscription struct {
+	id, name, state string
+}
+
+// fakeAzure answers the Microsoft Entra ID and Azure Resource Manager calls
+// of a scan from canned data
+type fakeAzure struct {
+	tenantID      string
+	subscriptions []fakeSubscription
+
+	// rows holds the Resource Graph rows of each lower-cased type, found in
+	// the query by name
+	rows map[string][]map[string]any
+
+	// tiers holds the Defender plan tiers of each subscription
+	tiers map[string][]string
+
+	// pageSize limits Resource Graph pages so that pagination is exercised
+	pageSize int
+
+	mu         sync.Mutex
+	unexpected []string
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	base := "https://" + r.Host
+	path := r.URL.Path
+
+	switch {
+	case strings.HasSuffix(path, "/.well-known/openid-configuration"):
+		writeJSON(w, map[string]string{
+			"token_endpoint":         base + "/" + f.tenantID + "/oauth2/v2.0/token",
+			"authorization_endpoint": base + "/" + f.tenantID + "/oauth2/v2.0/authorize",
+			"issuer":                 base + "/" + f.tenantID + "/v2.0",
+		})
+		return
+	case strings.HasSuffix(path, "/oauth2/v2.0/token"):
+		payload, _ := json.Marshal(map[string]string{"tid": f.tenantID})
+		token := "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+		writeJSON(w, map[string]any{"token_type": "Bearer", "access_token": token, "expires_in": 3600})
+		return
+	}
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		f.fail(w, "unauthenticated request %s %s", r.Method, path)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && path == "/subscriptions":
+		var value []map[string]string
+		for _, sub := range f.subscriptions {
+			value = append(value, map[string]string{
+				"id":             "/subscriptions/" + sub.id,
+				"subscriptionId": sub.id,
+				"displayName":    sub.name,
+				"state":          sub.state,
+			})
+		}
+		writeJSON(w, map[string]any{"value": value})
+	case r.Method == http.MethodPost && path == "/providers/Microsoft.ResourceGraph/resources":
+		f.serveResourceGraph(w, r)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/providers/Microsoft.Security/pricings"):
+		subscriptionID := strings.Split(path, "/")[2]
+		var value []map[string]any
+		for i, tier := range f.tiers[subscriptionID] {
+			value = append(value, map[string]any{
+				"name":       fmt.Sprintf("Plan%d", i),
+				"properties": map[string]string{"pricingTier": tier},
+			})
+		}
+		writeJSON(w, map[string]any{"value": value})
+	default:
+		f.fail(w, "unexpected request %s %s", r.Method, path)
+	}
+}
+
+func (f *fakeAzure) serveResourceGraph(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query   string `json:"query"`
+		Options struct {
+			SkipToken string `json:"$skipToken"`
+		} `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		f.fail(w, "unreadable Resource Graph request: %v", err)
+		return
+	}
+
+	var rows []map[string]any
+	found := false
+	for resourceType, typeRows := range f.rows {
+		if strings.Contains(strings.ToLower(request.Query), `"`+resourceType+`"`) {
+			rows, found = typeRows, true
+		}
+	}
+	if !found {
+		f.fail(w, "unexpected query %s", request.Query)
+		return
+	}
+
+	start, _ := strconv.Atoi(request.Options.SkipToken)
+	end := min(start+f.pageSize, len(rows))
+	response := map[string]any{
+		"totalRecords":    len(rows),
+		"count":           end - start,
+		"resultTruncated": "false",
+		"data":            rows[start:end],
+	}
+	if end < len(rows) {
+		response["$skipToken"] = strconv.Itoa(end)
+	}
+	writeJSON(w, response)
+}
+
+func (f *fakeAzure) fail(w http.ResponseWriter, format string, args ...any) {
+	f.mu.Lock()
+	f.unexpected = append(f.unexpected, fmt.Sprintf(format, args...))
+	f.mu.Unlock()
+	http.Error(w, `{"error":{"code":"Unexpected","message":"unexpected request"}}`, http.StatusNotImplemented)
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,280 @@
+//go:build integration
+
+// The integration tests run whole scans through the real AWS and Azure
+// providers against fake cloud endpoints served over TLS, with the CA bundle
+// and endpoint settings a user would give. Run them with:
+//
+//	go test -tags integration ./internal/agent/
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+const integrationDefinitions = `mode: replace
+aws:
+  - type: ec2:instance
+    display_name: EC2 Instances
+    category: Compute
+    count_method: service_api
+    billable: true
+  - type: s3:bucket
+    display_name: S3 Buckets
+    category: Storage
+    count_method: tagging_api
+  - type: iam:role
+    display_name: IAM Roles
+    category: IAM
+    count_method: tagging_api
+    global: true
+azure:
+  - type: microsoft.compute/virtualmachines
+    display_name: Virtual Machines
+    category: Compute
+    count_method: resource_graph
+    billable: true
+    state_field: properties.extended.instanceView.powerState.code
+  - type: microsoft.storage/storageaccounts
+    display_name: Storage Accounts
+    category: Storage
+    count_method: resource_graph
+  - type: microsoft.security/pricings
+    display_name: Defender for Cloud Plans (enabled)
+    category: Security
+    count_method: security_pricing
+`
+
+func TestIntegrationAWS(t *testing.T) {
+	fake := &fakeAWS{
+		accountID: "123456789012",
+		alias:     "acme-prod",
+		regions:   []string{"us-east-1", "eu-west-1", "ap-south-1"},
+		denied:    map[string]bool{"ap-south-1": true},
+		instances: map[string][]fakeInstance{
+			"us-east-1": {
+				{id: "i-1", instanceType: "t3.micro", state: "running"},
+				{id: "i-2", instanceType: "t3.micro", state: "running"},
+				{id: "i-3", instanceType: "t3.micro", state: "terminated"},
+			},
+			"eu-west-1": {{id: "i-4", instanceType: "m5.large", state: "stopped"}},
+		},
+		tagged: map[string]map[string][]string{
+			"us-east-1": {
+				"s3:bucket": {"arn:aws:s3:::logs", "arn:aws:s3:::assets"},
+				"iam:role":  {"arn:aws:iam::123456789012:role/a", "arn:aws:iam::123456789012:role/b", "arn:aws:iam::123456789012:role/c"},
+			},
+			"eu-west-1": {
+				"s3:bucket": {"arn:aws:s3:::eu-1", "arn:aws:s3:::eu-2", "arn:aws:s3:::eu-3"},
+			},
+		},
+		pageSize: 2,
+	}
+	endpoint, caBundle := serveTLS(t, fake)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	// The SDK refuses a CA bundle of its own on top of --ca-bundle
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	result := runIntegration(t, &Config{
+		Provider:       "aws",
+		Region:         "us-east-1",
+		AWSEndpointURL: endpoint,
+		CABundle:       caBundle,
+	})
+	if len(fake.unexpected) > 0 {
+		t.Errorf("unexpected AWS calls: %q", fake.unexpected)
+	}
+
+	if result.Provider != "AWS" || result.TotalResources != 11 || result.TotalAccounts != 1 {
+		t.Errorf("result provider %s, %d resources in %d accounts, want AWS, 11 in 1",
+			result.Provider, result.TotalResources, result.TotalAccounts)
+	}
+	if want := []string{"123456789012=acme-prod"}; !reflect.DeepEqual(accountNames(result), want) {
+		t.Errorf("accounts = %v, want %v", accountNames(result), want)
+	}
+	if len(result.SkippedRegions) != 1 || result.SkippedRegions[0].Region != "ap-south-1" {
+		t.Errorf("skipped regions = %+v, want ap-south-1", result.SkippedRegions)
+	}
+
+	counts := countsByType(result)
+	assertCount(t, counts, "ec2:instance", 3, map[string]int{"us-east-1": 2, "eu-west-1": 1})
+	assertCount(t, counts, "s3:bucket", 5, map[string]int{"us-east-1": 2, "eu-west-1": 3})
+	assertCount(t, counts, "iam:role", 3, map[string]int{"us-east-1": 3})
+	if ec2 := counts["ec2:instance"]; ec2 != nil && !reflect.DeepEqual(ec2.ByState, map[string]int{"running": 2, "stopped": 1}) {
+		t.Errorf("ec2:instance by state = %v", ec2.ByState)
+	}
+	if iam := counts["iam:role"]; iam != nil && !iam.Global {
+		t.Error("iam:role is not marked global")
+	}
+	if ec2 := counts["ec2:instance"]; ec2 != nil && ec2.CanonicalType != models.ResourceTypeVirtualMachine {
+		t.Errorf("ec2:instance canonical type = %q, want %q", ec2.CanonicalType, models.ResourceTypeVirtualMachine)
+	}
+}
+
+func TestIntegrationAzure(t *testing.T) {
+	const tenantID = "72f988bf-0000-0000-0000-000000000001"
+	const subA, subB = "00000000-0000-0000-0000-00000000000a", "00000000-0000-0000-0000-00000000000b"
+	fake := &fakeAzure{
+		tenantID: tenantID,
+		subscriptions: []fakeSubscription{
+			{id: subA, name: "Production", state: "Enabled"},
+			{id: subB, name: "Development", state: "Enabled"},
+			{id: "00000000-0000-0000-0000-00000000000c", name: "Retired", state: "Disabled"},
+		},
+		rows: map[string][]map[string]any{
+			"microsoft.compute/virtualmachines": {
+				{"location": "eastus", "subscriptionId": subA, "state": "PowerState/running", "count": 2},
+				{"location": "eastus", "subscriptionId": subA, "state": "PowerState/deallocated", "count": 1},
+				{"location": "westeurope", "subscriptionId": subB, "state": "PowerState/running", "count": 1},
+			},
+			"microsoft.storage/storageaccounts": {
+				{"location": "eastus", "subscriptionId": subA, "count": 3},
+				{"location": "westeurope", "subscriptionId": subB, "count": 2},
+			},
+		},
+		tiers:    map[string][]string{subA: {"Standard", "Standard", "Free"}, subB: {"Free"}},
+		pageSize: 2,
+	}
+	endpoint, caBundle := serveTLS(t, fake)
+
+	t.Setenv("AZURE_CLIENT_SECRET", "secret")
+	t.Setenv("AZURE_SUBSCRIPTION_ID", "")
+
+	result := runIntegration(t, &Config{
+		Provider:           "azure",
+		AzureAuth:          "sp",
+		TenantID:           tenantID,
+		AzureClientID:      "11111111-0000-0000-0000-000000000001",
+		AzureEndpointURL:   endpoint,
+		AzureAuthorityHost: endpoint,
+		CABundle:           caBundle,
+	})
+	if len(fake.unexpected) > 0 {
+		t.Errorf("unexpected Azure calls: %q", fake.unexpected)
+	}
+
+	if result.Provider != "Azure" || result.TotalResources != 11 || result.TotalAccounts != 2 {
+		t.Errorf("result provider %s, %d resources in %d subscriptions, want Azure, 11 in 2",
+			result.Provider, result.TotalResources, result.TotalAccounts)
+	}
+	if want := []string{subA + "=Production", subB + "=Development"}; !reflect.DeepEqual(accountNames(result), want) {
+		t.Errorf("subscriptions = %v, want %v", accountNames(result), want)
+	}
+
+	counts := countsByType(result)
+	assertCount(t, counts, "microsoft.compute/virtualmachines", 4, map[string]int{"eastus": 3, "westeurope": 1})
+	assertCount(t, counts, "microsoft.storage/storageaccounts", 5, map[string]int{"eastus": 3, "westeurope": 2})
+	assertCount(t, counts, "microsoft.security/pricings", 2, map[string]int{"global": 2})
+	if vms := counts["microsoft.compute/virtualmachines"]; vms != nil &&
+		!reflect.DeepEqual(vms.ByState, map[string]int{"running": 3, "deallocated": 1}) {
+		t.Errorf("virtual machines by state = %v", vms.ByState)
+	}
+
+	combined := models.CountByCanonicalType(result)
+	if len(combined) == 0 || combined[len(combined)-1].Type != models.ResourceTypeVirtualMachine || combined[len(combined)-1].TotalResources != 4 {
+		t.Errorf("counts by canonical type = %+v, want 4 virtual machines last", combined)
+	}
+}
+
+// runIntegration runs the agent with the integration definitions and JSON
+// output to a file, and returns the result read back from that file after
+// checking it against the published schema
+func runIntegration(t *testing.T, cfg *Config) *models.SizingResult {
+	t.Helper()
+	dir := t.TempDir()
+	cfg.ResourceDefinitions = filepath.Join(dir, "definitions.yaml")
+	if err := os.WriteFile(cfg.ResourceDefinitions, []byte(integrationDefinitions), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.OutputFormat = "json"
+	cfg.OutputFile = filepath.Join(dir, "sizing.json")
+	cfg.Quiet = true
+
+	var runErr error
+	captureOutput(t, func() { runErr = New(cfg).Run(context.Background()) })
+	if runErr != nil {
+		t.Fatalf("Run() error = %v", runErr)
+	}
+
+	data, err := os.ReadFile(cfg.OutputFile)
+	if err != nil {
+		t.Fatalf("output not written: %v", err)
+	}
+	violations, err := models.ValidateResult(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, violation := range violations {
+		t.Errorf("output violates the schema: %s", violation)
+	}
+
+	var result models.SizingResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("output is not a result: %v", err)
+	}
+	return &result
+}
+
+// serveTLS serves handler over TLS for the duration of the test and returns
+// its URL and a CA bundle file trusting it
+func serveTLS(t *testing.T, handler http.Handler) (url, caBundle string) {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	caBundle = filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caBundle, certificate, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return server.URL, caBundle
+}
+
+// accountNames returns the accounts of result as id=name
+func accountNames(result *models.SizingResult) []string {
+	var names []string
+	for _, account := range result.AccountCounts {
+		names = append(names, account.ID+"="+account.Name)
+	}
+	return names
+}
+
+func countsByType(result *models.SizingResult) map[string]*models.ResourceCount {
+	counts := make(map[string]*models.ResourceCount)
+	for _, count := range result.ResourceCounts {
+		counts[string(count.Type)] = count
+	}
+	return counts
+}
+
+func assertCount(t *testing.T, counts map[string]*models.ResourceCount, resourceType string, total int, byLocation map[string]int) {
+	t.Helper()
+	count, ok := counts[resourceType]
+	if !ok {
+		t.Errorf("%s was not counted", resourceType)
+		return
+	}
+	if count.TotalResources != total {
+		t.Errorf("%s total = %d, want %d", resourceType, count.TotalResources, total)
+	}
+	if !reflect.DeepEqual(count.ByLocation, byLocation) {
+		t.Errorf("%s by location = %v, want %v", resourceType, count.ByLocation, byLocation)
+	}
+}
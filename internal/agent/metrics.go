@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/report"
+)
+
+// metricsExporter publishes the scan metrics once the scan is done
+type metricsExporter struct {
+	progress report.Progress
+	recorder *metrics.Prometheus
+	server   *metrics.Server
+	pushURL  string
+	timeout  time.Duration
+
+	// resident is set for the exporter of scheduled mode, whose endpoint
+	// stays open between scans instead of waiting for a final scrape
+	resident bool
+}
+
+// startMetrics installs a Prometheus recorder when a metrics flag is set and
+// starts the scrape endpoint. It returns nil when metrics are disabled; the
+// caller closes the exporter it returns.
+func (a *Agent) startMetrics() (*metricsExporter, error) {
+	if a.config.MetricsPushURL == "" && a.config.MetricsListen == "" {
+		return nil, nil
+	}
+
+	exporter := &metricsExporter{
+		progress: a.progress,
+		recorder: metrics.NewPrometheus(),
+		pushURL:  a.config.MetricsPushURL,
+		timeout:  a.config.MetricsListenTimeout,
+	}
+
+	if a.config.MetricsListen != "" {
+		server, err := metrics.Listen(a.config.MetricsListen, exporter.recorder.Gatherer())
+		if err != nil {
+			return nil, err
+		}
+		exporter.server = server
+		a.progress.Status("Serving metrics on http://%s/metrics", server.Addr())
+	}
+
+	metrics.SetRecorder(exporter.recorder)
+	return exporter, nil
+}
+
+// recordResult records the scan duration and resource totals. Account labels
+// use the IDs as output, so they are anonymized when the result is.
+func recordResult(result *models.SizingResult, duration time.Duration) {
+	provider := strings.ToLower(result.Provider)
+	metrics.ScanCompleted(provider, duration)
+	metrics.ResourcesFound(provider, metrics.AllAccounts, result.TotalResources)
+
+	byAccount := make(map[string]int)
+	for _, rc := range result.ResourceCounts {
+		for account, count := range rc.ByAccount {
+			byAccount[account] += count
+		}
+	}
+	for account, count := range byAccount {
+		metrics.ResourcesFound(provider, account, count)
+	}
+}
+
+// close shuts the scrape endpoint down, if still open, and uninstalls the
+// recorder. It is a no-op on a nil exporter.
+func (e *metricsExporter) close() {
+	if e == nil {
+		return
+	}
+	if e.server != nil {
+		e.server.Close()
+	}
+	metrics.SetRecorder(nil)
+}
+
+// publish pushes the metrics and waits for the final scrape. Failures are
+// reported but never fail the run.
+func (e *metricsExporter) publish(ctx context.Context) {
+	if e.pushURL != "" {
+		if err := e.recorder.Push(ctx, e.pushURL); err != nil {
+			e.progress.Warn("%v", err)
+		} else {
+			e.progress.Status("✓ Metrics pushed to %s", e.pushURL)
+		}
+	}
+
+	if e.server != nil && !e.resident {
+		e.progress.Status("Waiting up to %s for metrics to be scraped from http://%s/metrics", e.timeout, e.server.Addr())
+		if !e.server.Wait(ctx, e.timeout) {
+			e.progress.Warn("metrics were not scraped before the timeout")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+// freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+	return addr
+}
+
+func TestFailedRunReleasesMetricsAddress(t *testing.T) {
+	addr := freeAddr(t)
+	errConnect := errors.New("connect failed")
+
+	// The second run can only bind the address if the first released it
+	for range 2 {
+		agent := New(&Config{Provider: "aws", OutputFormat: "json", MetricsListen: addr, Quiet: true})
+		agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+			return nil, errConnect
+		}
+		if err := agent.Run(context.Background()); !errors.Is(err, errConnect) {
+			t.Fatalf("Run() error = %v, want %v", err, errConnect)
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("metrics address still bound after the failed runs: %v", err)
+	}
+	_ = listener.Close()
+}
+
+func TestScheduledRunsShareMetricsEndpoint(t *testing.T) {
+	cfg := &Config{Provider: "aws", OutputFormat: "json", MetricsListen: freeAddr(t), Quiet: true}
+	scheduler := New(cfg)
+	exporter, err := scheduler.startMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter.resident = true
+	defer exporter.close()
+
+	errConnect := errors.New("connect failed")
+	for range 2 {
+		agent := New(cfg)
+		agent.metrics = exporter
+		agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+			return nil, errConnect
+		}
+		if err := agent.Run(context.Background()); !errors.Is(err, errConnect) {
+			t.Fatalf("Run() error = %v, want %v", err, errConnect)
+		}
+	}
+
+	resp, err := http.Get("http://" + exporter.server.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("metrics endpoint closed by a scheduled scan: %v", err)
+	}
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,36 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ApplyOffline turns off, with Offline, every optional feature that calls
+// an endpoint other than the cloud providers' APIs: the update check, and
+// trace export configured by OTEL_* variables, which TraceExport reports.
+// Such features requested explicitly are never dropped silently; ApplyOffline
+// returns an error naming their flags instead.
+func (c *Config) ApplyOffline() error {
+	if !c.Offline {
+		return nil
+	}
+	var requested []string
+	if c.MetricsPushURL != "" {
+		requested = append(requested, "--metrics-push-url")
+	}
+	if c.OTelEndpoint != "" {
+		requested = append(requested, "--otel-endpoint")
+	}
+	if len(requested) > 0 {
+		return fmt.Errorf("--offline cannot be used with %s: an offline scan calls no endpoint but the cloud provider's",
+			strings.Join(requested, " or "))
+	}
+	c.UpdateCheck = false
+	return nil
+}
+
+// TraceExport reports whether traces may be exported, to OTelEndpoint or
+// the endpoint of the OTEL_* variables; never with Offline
+func (c *Config) TraceExport() bool {
+	return !c.Offline
+}
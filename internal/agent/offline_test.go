@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyOffline(t *testing.T) {
+	config := &Config{Offline: true, UpdateCheck: true, MetricsListen: ":9090"}
+	if err := config.ApplyOffline(); err != nil {
+		t.Fatal(err)
+	}
+	if config.UpdateCheck || config.TraceExport() {
+		t.Errorf("offline UpdateCheck = %v, TraceExport() = %v, want both off", config.UpdateCheck, config.TraceExport())
+	}
+
+	config = &Config{Offline: true, MetricsPushURL: "https://push.example.com", OTelEndpoint: "https://otel.example.com"}
+	err := config.ApplyOffline()
+	if err == nil || !strings.Contains(err.Error(), "--metrics-push-url or --otel-endpoint") {
+		t.Errorf("ApplyOffline() = %v, want both requested features named", err)
+	}
+
+	config = &Config{UpdateCheck: true, MetricsPushURL: "https://push.example.com"}
+	if err := config.ApplyOffline(); err != nil || !config.UpdateCheck || !config.TraceExport() {
+		t.Errorf("ApplyOffline() without Offline = %v, UpdateCheck = %v", err, config.UpdateCheck)
+	}
+}
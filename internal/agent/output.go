@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/sink"
+)
+
+// maxOutputSuffix bounds the -1, -2... suffixes tried when an expanded
+// output file name is taken
+const maxOutputSuffix = 1000
+
+// outputTokens are the shorthand placeholders of --output and the template
+// actions they stand for
+var outputTokens = strings.NewReplacer(
+	"%p", "{{.Provider}}",
+	"%f", "{{.Format}}",
+	"%d", `{{.Timestamp.Format "2006-01-02"}}`,
+	"%t", `{{.Timestamp.Format "`+outputTimestampFormat+`"}}`,
+	"%%", "%",
+)
+
+// OutputName is the data an --output template is expanded with
+type OutputName struct {
+	Provider string
+	Format   string
+
+	// Timestamp is the UTC start of the scan
+	Timestamp time.Time
+}
+
+// ParseOutputTemplate parses an --output path holding Go template actions,
+// such as {{.Provider}}, or the shorthands %p (provider), %f (format),
+// %d (date), %t (timestamp) and %% (a literal %). It returns nil for a
+// plain path. The template is tried on sample data, so that unknown fields
+// fail before scanning.
+func ParseOutputTemplate(path string) (*template.Template, error) {
+	text := outputTokens.Replace(path)
+	if text == path && !strings.Contains(path, "{{") {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --output template %q: %w", path, err)
+	}
+	sample, err := expandTemplate(tmpl, OutputName{Provider: "aws", Format: "json", Timestamp: time.Now().UTC()})
+	if err != nil {
+		return nil, fmt.Errorf("invalid --output template %q: %w", path, err)
+	}
+	if sample == "" {
+		return nil, fmt.Errorf("invalid --output template %q: it expands to an empty path", path)
+	}
+	return tmpl, nil
+}
+
+// expandTemplate executes tmpl with name
+func expandTemplate(tmpl *template.Template, name OutputName) (string, error) {
+	var path strings.Builder
+	if err := tmpl.Execute(&path, name); err != nil {
+		return "", err
+	}
+	return path.String(), nil
+}
+
+// ExpandOutputPath expands the --output template path with name, creates
+// its directory and appends -1, -2... before the extension while the file,
+// as options would name it, already exists. A plain path is returned as
+// is, without checks, so that it is overwritten as before.
+func ExpandOutputPath(path string, name OutputName, options sink.Options) (string, error) {
+	tmpl, err := ParseOutputTemplate(path)
+	if err != nil || tmpl == nil {
+		return path, err
+	}
+	name.Timestamp = name.Timestamp.UTC()
+	expanded, err := expandTemplate(tmpl, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand --output %q: %w", path, err)
+	}
+
+	if dir := filepath.Dir(expanded); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("failed to create the output directory: %w", err)
+		}
+	}
+
+	ext := filepath.Ext(expanded)
+	base := strings.TrimSuffix(expanded, ext)
+	candidate := expanded
+	for i := 1; ; i++ {
+		if _, err := os.Stat(options.Path(candidate)); os.IsNotExist(err) {
+			return candidate, nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to check the output file: %w", err)
+		}
+		if i > maxOutputSuffix {
+			return "", fmt.Errorf("output files %s to %s already exist", expanded, candidate)
+		}
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/sink"
+)
+
+func TestParseOutputTemplate(t *testing.T) {
+	tests := []struct {
+		path      string
+		templated bool
+		wantErr   bool
+	}{
+		{path: "results.json"},
+		{path: "100%.json"},
+		{path: "reports/{{.Provider}}.json", templated: true},
+		{path: `{{.Provider}}-{{.Timestamp.Format "2006-01-02"}}.json`, templated: true},
+		{path: "%p-%d.%f", templated: true},
+		{path: "100%%.json", templated: true},
+		{path: "{{.Account}}.json", wantErr: true},
+		{path: "{{.Provider}.json", wantErr: true},
+		{path: `{{if false}}x{{end}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseOutputTemplate(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseOutputTemplate(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+		}
+		if (tmpl != nil) != tt.templated {
+			t.Errorf("ParseOutputTemplate(%q) templated = %v, want %v", tt.path, tmpl != nil, tt.templated)
+		}
+	}
+}
+
+func TestExpandOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	name := OutputName{Provider: "aws", Format: "json", Timestamp: time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)}
+	template := filepath.Join(dir, "reports", "%p-%d.json")
+	want := filepath.Join(dir, "reports", "aws-2024-03-01.json")
+
+	for _, suffix := range []string{"", "-1", "-2"} {
+		path, err := ExpandOutputPath(template, name, sink.Options{})
+		if err != nil {
+			t.Fatalf("ExpandOutputPath() error = %v", err)
+		}
+		if wantPath := want[:len(want)-len(".json")] + suffix + ".json"; path != wantPath {
+			t.Fatalf("ExpandOutputPath() = %q, want %q", path, wantPath)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The file as compressed output names it is the one that must be free
+	path, err := ExpandOutputPath(filepath.Join(dir, "%p.json"), name, sink.Options{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".gz", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := ExpandOutputPath(filepath.Join(dir, "%p.json"), name, sink.Options{Compress: true}); again != filepath.Join(dir, "aws-1.json") {
+		t.Errorf("ExpandOutputPath() with compression = %q, want the -1 suffix", again)
+	}
+
+	if plain, _ := ExpandOutputPath(want, name, sink.Options{}); plain != want {
+		t.Errorf("ExpandOutputPath(%q) = %q, want the plain path kept", want, plain)
+	}
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/report"
+)
+
+// minScheduleInterval is the shortest accepted --schedule interval
+const minScheduleInterval = time.Minute
+
+// outputTimestampFormat is appended to output file names of scheduled scans
+const outputTimestampFormat = "20060102T150405Z"
+
+// Schedule yields the start times of scheduled scans
+type Schedule interface {
+	// Next returns the first start time after t
+	Next(t time.Time) time.Time
+}
+
+// intervalSchedule starts a scan every interval
+type intervalSchedule time.Duration
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// ParseSchedule parses a --schedule value: either a duration such as "24h" or
+// a standard five-field cron expression such as "0 2 * * *"
+func ParseSchedule(spec string) (Schedule, error) {
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval < minScheduleInterval {
+			return nil, fmt.Errorf("schedule interval %s is shorter than %s", interval, minScheduleInterval)
+		}
+		return intervalSchedule(interval), nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q, expected a duration or cron expression: %w", spec, err)
+	}
+	return schedule, nil
+}
+
+// RunScheduled stays resident and runs a scan on every tick of the configured
+// schedule until ctx is cancelled. Intervals start with an immediate scan, cron
+// expressions wait for their first match. Each scan connects afresh and writes
+// timestamped output files, unless --output is a template; a failed scan is
+// reported and the next tick runs as usual. A tick that arrives while a scan is still running is skipped.
+// The metrics endpoint and recorder, if any, are started once and serve every
+// scan.
+func (a *Agent) RunScheduled(ctx context.Context) error {
+	schedule, err := ParseSchedule(a.config.Schedule)
+	if err != nil {
+		return err
+	}
+	exporter, err := a.startMetrics()
+	if err != nil {
+		return err
+	}
+	if exporter != nil {
+		exporter.resident = true
+		a.metrics = exporter
+		defer exporter.close()
+	}
+
+	a.progress.Status("\n🕒 Scheduled mode: %s", a.config.Schedule)
+	runSchedule(ctx, schedule, time.Now(), a.progress, a.runIteration)
+	a.progress.Status("\n🛑 Scheduler stopped")
+	return nil
+}
+
+// runSchedule calls scan on every tick of schedule until ctx is cancelled,
+// then waits for a running scan to return. Scans never overlap.
+func runSchedule(ctx context.Context, schedule Schedule, now time.Time, progress report.Progress,
+	scan func(ctx context.Context, iteration int, start time.Time)) {
+	next := now
+	if _, ok := schedule.(intervalSchedule); !ok {
+		next = schedule.Next(now)
+		progress.Status("Next scan at %s", next.Format(time.RFC3339))
+	}
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	done := make(chan struct{}, 1)
+	running := false
+	iteration := 0
+	for {
+		select {
+		case <-ctx.Done():
+			if running {
+				progress.Status("\nShutting down, waiting for the running scan to stop...")
+				<-done
+			}
+			return
+
+		case <-done:
+			running = false
+
+		case tick := <-timer.C:
+			if running {
+				progress.Warn("skipping the scan due at %s, scan #%d is still running",
+					tick.Format(time.RFC3339), iteration)
+			} else {
+				iteration++
+				running = true
+				go func(iteration int, start time.Time) {
+					scan(ctx, iteration, start)
+					done <- struct{}{}
+				}(iteration, tick)
+			}
+
+			next = schedule.Next(tick)
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// runIteration runs one scheduled scan with timestamped output files and
+// reports its outcome without returning it, so the daemon keeps going
+func (a *Agent) runIteration(ctx context.Context, iteration int, start time.Time) {
+	a.progress.Status("\n========== Scan #%d started at %s ==========", iteration, start.Format(time.RFC3339))
+
+	// An --output template names each scan's files itself, and the
+	// inventory file is derived from the expanded name
+	config := *a.config
+	templated, _ := ParseOutputTemplate(a.config.OutputFile)
+	if templated == nil {
+		config.OutputFile = timestampedPath(a.config.OutputFile, start)
+	}
+	if a.config.Inventory && (templated == nil || a.config.InventoryOutput != "") {
+		config.InventoryOutput = timestampedPath(a.inventoryPath(), start)
+	}
+	config.AnonymizeMap = timestampedPath(a.config.AnonymizeMap, start)
+
+	agent := New(&config)
+	agent.metrics = a.metrics
+	err := runRecovered(ctx, agent)
+	duration := time.Since(start).Round(time.Second)
+
+	switch {
+	case err == nil:
+		a.progress.Status("\n========== Scan #%d finished in %s ==========", iteration, duration)
+	case errors.Is(err, ErrThresholdExceeded):
+		a.progress.Status("\n========== Scan #%d finished in %s, threshold exceeded ==========", iteration, duration)
+	case sizingerrors.ExitCode(err) == sizingerrors.ExitPartialResult:
+		a.progress.Warn("%v", err)
+		a.progress.Status("\n========== Scan #%d finished in %s with incomplete results ==========", iteration, duration)
+	case ctx.Err() != nil:
+		a.progress.Status("\n========== Scan #%d cancelled after %s ==========", iteration, duration)
+	default:
+		a.progress.Status("Error: %v", err)
+		a.progress.Status("\n========== Scan #%d failed after %s ==========", iteration, duration)
+	}
+}
+
+// runRecovered runs one scan, turning a panic into an error
+func runRecovered(ctx context.Context, agent *Agent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scan panicked: %v", r)
+		}
+	}()
+	return agent.Run(ctx)
+}
+
+// timestampedPath inserts the UTC start time before the extension of path,
+// leaving an empty path empty
+func timestampedPath(path string, start time.Time) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + start.UTC().Format(outputTimestampFormat) + ext
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/report"
+)
+
+func TestParseSchedule(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		spec     string
+		wantNext time.Time
+		wantErr  bool
+	}{
+		{name: "interval", spec: "24h", wantNext: start.Add(24 * time.Hour)},
+		{name: "minutes", spec: "90m", wantNext: start.Add(90 * time.Minute)},
+		{name: "daily cron", spec: "0 2 * * *", wantNext: time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{name: "cron descriptor", spec: "@hourly", wantNext: time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{name: "too short", spec: "30s", wantErr: true},
+		{name: "garbage", spec: "every day", wantErr: true},
+		{name: "six fields", spec: "0 0 2 * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedule(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := schedule.Next(start); !got.Equal(tt.wantNext) {
+				t.Errorf("Next() = %s, want %s", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestTimestampedPath(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 30, 5, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "", want: ""},
+		{path: "results.json", want: "results-20240301T093005Z.json"},
+		{path: "out/sizing", want: "out/sizing-20240301T093005Z"},
+		{path: "out/results.inventory.ndjson", want: "out/results.inventory-20240301T093005Z.ndjson"},
+	}
+
+	for _, tt := range tests {
+		if got := timestampedPath(tt.path, start); got != tt.want {
+			t.Errorf("timestampedPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRunScheduleNeverOverlaps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var mu sync.Mutex
+	active, maxActive, iterations := 0, 0, 0
+	scan := func(ctx context.Context, iteration int, _ time.Time) {
+		mu.Lock()
+		active++
+		iterations = iteration
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		// Outlast several ticks, and stop the daemon during the third scan
+		select {
+		case <-time.After(30 * time.Millisecond):
+		case <-ctx.Done():
+		}
+		if iteration == 3 {
+			cancel()
+		}
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		runSchedule(ctx, intervalSchedule(5*time.Millisecond), time.Now(), report.NewProgress(io.Discard, report.Options{}), scan)
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runSchedule() did not return after cancellation")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxActive != 1 {
+		t.Errorf("%d scans ran concurrently, want 1", maxActive)
+	}
+	if active != 0 {
+		t.Errorf("runSchedule() returned with %d scans still running", active)
+	}
+	if iterations != 3 {
+		t.Errorf("ran %d scans, want 3", iterations)
+	}
+}
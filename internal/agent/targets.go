@@ -0,0 +1,238 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// profileConcurrency bounds the profiles counted at once; each already
+// counts several types in parallel
+const profileConcurrency = 2
+
+// scanTarget is one set of credentials of a multi-target scan: an AWS
+// profile of --profiles or an Azure tenant of azure_tenants
+type scanTarget struct {
+	// name labels the target in status lines, warnings and errors, e.g.
+	// "profile dev"
+	name string
+	// configure adapts the scan's provider settings to the target
+	configure func(*config.ProviderConfig)
+	// claim records the target in its result's accounts
+	claim func(*models.SizingResult)
+
+	provider providers.Provider
+	result   *models.SizingResult
+	err      error
+}
+
+// profileTargets returns a target per profile of --profiles
+func (a *Agent) profileTargets() []*scanTarget {
+	targets := make([]*scanTarget, 0, len(a.config.Profiles))
+	for _, profile := range a.config.Profiles {
+		targets = append(targets, &scanTarget{
+			name:      "profile " + profile,
+			configure: func(cfg *config.ProviderConfig) { cfg.Profile = profile },
+			claim: func(result *models.SizingResult) {
+				for i := range result.AccountCounts {
+					result.AccountCounts[i].Profile = profile
+				}
+			},
+		})
+	}
+	return targets
+}
+
+// tenantTargets returns a target per tenant of azure_tenants
+func (a *Agent) tenantTargets() []*scanTarget {
+	targets := make([]*scanTarget, 0, len(a.config.AzureTenants))
+	for _, tenant := range a.config.AzureTenants {
+		targets = append(targets, &scanTarget{
+			name: "tenant " + tenant.TenantID,
+			configure: func(cfg *config.ProviderConfig) {
+				cfg.TenantID = tenant.TenantID
+				if tenant.AzureAuth != "" {
+					cfg.AzureAuth = tenant.AzureAuth
+				}
+				if len(tenant.Subscriptions) > 0 {
+					cfg.SubscriptionIDs = tenant.Subscriptions
+				}
+				cfg.AzureClientID = tenant.ClientID
+				cfg.AzureClientCertificatePath = tenant.ClientCertificatePath
+				cfg.AzureFederatedTokenFile = tenant.FederatedTokenFile
+				cfg.AzureClientSecretEnv = tenant.ClientSecretEnv
+			},
+			claim: func(result *models.SizingResult) { qualifyAccounts(result, tenant.TenantID) },
+		})
+	}
+	return targets
+}
+
+// qualifyAccounts prefixes the account IDs of result, in its accounts and
+// per-type breakdowns, with their tenant
+func qualifyAccounts(result *models.SizingResult, tenant string) {
+	for i := range result.AccountCounts {
+		result.AccountCounts[i].ID = tenant + "/" + result.AccountCounts[i].ID
+		result.AccountCounts[i].Tenant = tenant
+	}
+	for _, rc := range result.ResourceCounts {
+		if len(rc.ByAccount) == 0 {
+			continue
+		}
+		byAccount := make(map[string]int, len(rc.ByAccount))
+		for id, count := range rc.ByAccount {
+			byAccount[tenant+"/"+id] = count
+		}
+		rc.ByAccount = byAccount
+	}
+}
+
+// scanTargets scans every target with a provider of its own and merges the
+// results. Targets connect one after the other, as each may ask for an MFA
+// code or sign in interactively, and count concurrency at a time. A target
+// that fails is left out and reported in the returned partial error, one
+// whose accounts the account filter excludes in a warning; the scan fails
+// only when no target could be counted. eta starts with the plans
+// of the connected targets.
+func (a *Agent) scanTargets(ctx context.Context, providerConfig config.ProviderConfig, targets []*scanTarget, concurrency int,
+	eta *etaTracker) (*models.SizingResult, error) {
+	var warnings []string
+	claimed := make(map[string]string)
+	for _, target := range targets {
+		targetConfig := providerConfig
+		target.configure(&targetConfig)
+		a.progress.Status("Connecting with %s...", target.name)
+		target.provider, target.err = a.connect(ctx, targetConfig)
+		if errors.Is(target.err, models.ErrAccountsExcluded) {
+			warning := fmt.Sprintf("%s was not scanned: %v", target.name, target.err)
+			a.log.Warn(warning)
+			warnings = append(warnings, warning)
+			continue
+		}
+		if target.err != nil {
+			a.log.Error("Failed to scan", zap.String("target", target.name), zap.Error(target.err))
+			continue
+		}
+
+		// A target reaching only accounts an earlier one reaches adds
+		// nothing but duplicates
+		if earlier := claimedBy(target.provider.Accounts(), claimed, target.name); earlier != "" {
+			warning := fmt.Sprintf("%s reaches the same accounts as %s and was not scanned", target.name, earlier)
+			a.log.Warn(warning)
+			warnings = append(warnings, warning)
+			a.closeProvider(target.provider)
+			target.provider = nil
+		}
+	}
+
+	var connected []providers.Provider
+	for _, target := range targets {
+		if target.provider != nil {
+			connected = append(connected, target.provider)
+		}
+	}
+	eta.start(a.plans(connected...), concurrency)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(concurrency, 1))
+	for _, target := range targets {
+		if target.provider == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			defer a.closeProvider(target.provider)
+
+			countCtx, span := tracing.Start(ctx, "CountResources")
+			span.SetString("provider", target.provider.Name())
+			span.SetString("target", target.name)
+			target.result, target.err = target.provider.CountResources(countCtx)
+			span.End(target.err)
+			if target.err != nil && target.result == nil {
+				a.log.Error("Failed to scan", zap.String("target", target.name), zap.Error(target.err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	return mergeTargets(targets, warnings)
+}
+
+// claimedBy returns the earlier target that claimed every account of list,
+// or "" after claiming the accounts for name
+func claimedBy(list *models.AccountList, claimed map[string]string, name string) string {
+	if list == nil || len(list.Accounts) == 0 {
+		return ""
+	}
+	earlier := claimed[list.Accounts[0].ID]
+	for _, account := range list.Accounts {
+		if claimed[account.ID] == "" || claimed[account.ID] != earlier {
+			earlier = ""
+			break
+		}
+	}
+	if earlier != "" {
+		return earlier
+	}
+	for _, account := range list.Accounts {
+		if claimed[account.ID] == "" {
+			claimed[account.ID] = name
+		}
+	}
+	return ""
+}
+
+// mergeTargets merges the results of the targets in order, recording which
+// target counted each account, and collects the failures
+func mergeTargets(targets []*scanTarget, warnings []string) (*models.SizingResult, error) {
+	partial := &sizingerrors.PartialResultError{}
+	var results []*models.SizingResult
+	var excluded []error
+	for _, target := range targets {
+		var failed *sizingerrors.PartialResultError
+		switch {
+		case errors.Is(target.err, models.ErrAccountsExcluded):
+			excluded = append(excluded, target.err)
+			continue
+		case target.result != nil && errors.As(target.err, &failed):
+			for _, resourceType := range failed.Failed {
+				partial.Failed = append(partial.Failed, fmt.Sprintf("%s (%s)", resourceType, target.name))
+			}
+			partial.Errs = append(partial.Errs, failed.Errs...)
+		case target.err != nil:
+			partial.FailedScans = append(partial.FailedScans, target.name)
+			partial.Errs = append(partial.Errs, target.err)
+			warnings = append(warnings, fmt.Sprintf("%s could not be scanned: %v", target.name, target.err))
+			continue
+		case target.result == nil:
+			continue
+		}
+		target.claim(target.result)
+		results = append(results, target.result)
+	}
+
+	if len(results) == 0 && len(partial.Errs) == 0 {
+		return nil, errors.Join(excluded...)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("all %d scans failed: %w", len(targets), errors.Join(partial.Errs...))
+	}
+	// The results already carry the warnings of their failed types
+	result := models.MergeResults(results...)
+	result.Warnings = append(result.Warnings, warnings...)
+	if len(partial.Errs) > 0 {
+		return result, partial
+	}
+	return result, nil
+}
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+// accountProvider counts resources in a single account
+type accountProvider struct {
+	fakeProvider
+	account   string
+	resources int
+}
+
+func (p accountProvider) CountResources(context.Context) (*models.SizingResult, error) {
+	return &models.SizingResult{
+		Provider: "AWS",
+		ResourceCounts: []*models.ResourceCount{{
+			Provider: "aws", Type: "ec2:instance", DisplayName: "EC2 Instances", TotalResources: p.resources,
+			ByAccount: map[string]int{p.account: p.resources},
+		}},
+		AccountCounts:  []models.AccountCount{{ID: p.account, Status: "ACTIVE", ResourceCount: p.resources}},
+		TotalResources: p.resources,
+		TotalAccounts:  1,
+	}, nil
+}
+
+func (p accountProvider) Accounts() *models.AccountList {
+	return models.NewAccountList("AWS", models.AccountSourceCurrentAccount, []models.AccountCount{{ID: p.account}})
+}
+
+// failingProvider cannot connect
+type failingProvider struct{ fakeProvider }
+
+func (failingProvider) Connect(context.Context) error {
+	return &sizingerrors.AuthError{Provider: "aws", Err: errors.New("expired token")}
+}
+
+func TestScanTargetsProfiles(t *testing.T) {
+	agent := New(&Config{Provider: "aws", Profiles: []string{"prod", "staging", "dev", "sandbox"}, Quiet: true})
+	var connected []string
+	agent.getProvider = func(cfg config.ProviderConfig) (providers.Provider, error) {
+		connected = append(connected, cfg.Profile)
+		switch cfg.Profile {
+		case "prod":
+			return accountProvider{account: "111111111111", resources: 10}, nil
+		case "staging":
+			// Another role in the prod account
+			return accountProvider{account: "111111111111", resources: 10}, nil
+		case "dev":
+			return accountProvider{account: "222222222222", resources: 3}, nil
+		}
+		return failingProvider{}, nil
+	}
+
+	result, err := agent.Scan(context.Background())
+
+	var partial *sizingerrors.PartialResultError
+	if !errors.As(err, &partial) || !reflect.DeepEqual(partial.FailedScans, []string{"profile sandbox"}) {
+		t.Fatalf("Scan() error = %v, want the sandbox profile failed", err)
+	}
+	if want := []string{"prod", "staging", "dev", "sandbox"}; !reflect.DeepEqual(connected, want) {
+		t.Errorf("connected profiles = %v, want %v", connected, want)
+	}
+	if result.TotalResources != 13 || result.TotalAccounts != 2 {
+		t.Errorf("totals = %d resources in %d accounts, want 13 in 2", result.TotalResources, result.TotalAccounts)
+	}
+	profiles := map[string]string{}
+	for _, account := range result.AccountCounts {
+		profiles[account.ID] = account.Profile
+	}
+	if want := map[string]string{"111111111111": "prod", "222222222222": "dev"}; !reflect.DeepEqual(profiles, want) {
+		t.Errorf("account profiles = %v, want %v", profiles, want)
+	}
+	for _, warning := range []string{
+		"profile staging reaches the same accounts as profile prod and was not scanned",
+		"profile sandbox could not be scanned: failed to connect to AWS: expired token",
+	} {
+		if !slices.Contains(result.Warnings, warning) {
+			t.Errorf("warnings = %q, want %q", result.Warnings, warning)
+		}
+	}
+}
+
+func TestScanTargetsAllFail(t *testing.T) {
+	agent := New(&Config{Provider: "aws", Profiles: []string{"prod", "dev"}, Quiet: true})
+	agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+		return failingProvider{}, nil
+	}
+
+	result, err := agent.Scan(context.Background())
+	if result != nil || err == nil {
+		t.Fatalf("Scan() = %v, %v, want an error", result, err)
+	}
+	if code := sizingerrors.ExitCode(err); code != sizingerrors.ExitAuth {
+		t.Errorf("ExitCode() = %d, want %d", code, sizingerrors.ExitAuth)
+	}
+}
+
+// excludedProvider's accounts all fall outside the account filter
+type excludedProvider struct{ fakeProvider }
+
+func (excludedProvider) Connect(context.Context) error {
+	return fmt.Errorf("%w: the resources of account 222222222222 would be counted but it does not match --account-filter prod-*", models.ErrAccountsExcluded)
+}
+
+func TestScanTargetsAccountFilter(t *testing.T) {
+	agent := New(&Config{Provider: "aws", Profiles: []string{"prod", "dev"}, Quiet: true,
+		AccountFilter: models.AccountFilter{Names: []string{"prod-*"}}})
+	agent.getProvider = func(cfg config.ProviderConfig) (providers.Provider, error) {
+		if cfg.Profile == "dev" {
+			return excludedProvider{}, nil
+		}
+		return accountProvider{account: "111111111111", resources: 10}, nil
+	}
+
+	result, err := agent.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error = %v, want the dev profile skipped", err)
+	}
+	if result.TotalAccounts != 1 || result.AccountFilter == nil {
+		t.Errorf("TotalAccounts = %d, AccountFilter = %v, want 1 account and the filter", result.TotalAccounts, result.AccountFilter)
+	}
+	warning := "profile dev was not scanned: failed to connect to AWS: the account filters exclude every account to scan: " +
+		"the resources of account 222222222222 would be counted but it does not match --account-filter prod-*"
+	if !slices.Contains(result.Warnings, warning) {
+		t.Errorf("warnings = %q, want %q", result.Warnings, warning)
+	}
+
+	agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+		return excludedProvider{}, nil
+	}
+	if _, err := agent.Scan(context.Background()); !errors.Is(err, models.ErrAccountsExcluded) {
+		t.Errorf("Scan() error = %v, want ErrAccountsExcluded when every profile is excluded", err)
+	}
+}
+
+// concurrencyProvider records the most counts running at once
+type concurrencyProvider struct {
+	accountProvider
+	running, peak *atomic.Int32
+}
+
+func (p concurrencyProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
+	n := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return p.accountProvider.CountResources(ctx)
+}
+
+func TestScanTargetsTenants(t *testing.T) {
+	for _, concurrency := range []int32{1, 2} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			agent := New(&Config{
+				Provider: "azure",
+				AzureTenants: []AzureTenant{
+					{TenantID: "tenant-a", ClientID: "app-a", ClientSecretEnv: "TENANT_A_SECRET"},
+					{TenantID: "tenant-b", AzureAuth: "cli", Subscriptions: []string{"sub-b"}},
+					{TenantID: "tenant-c"},
+				},
+				TenantConcurrency: int(concurrency),
+				Quiet:             true,
+			})
+			var running, peak atomic.Int32
+			var configs []config.ProviderConfig
+			agent.getProvider = func(cfg config.ProviderConfig) (providers.Provider, error) {
+				configs = append(configs, cfg)
+				account := "sub-" + cfg.TenantID[len("tenant-"):]
+				return concurrencyProvider{accountProvider{account: account, resources: 2}, &running, &peak}, nil
+			}
+
+			result, err := agent.Scan(context.Background())
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+
+			if configs[0].AzureClientID != "app-a" || configs[0].AzureClientSecretEnv != "TENANT_A_SECRET" {
+				t.Errorf("tenant-a credentials = %+v", configs[0])
+			}
+			if configs[1].AzureAuth != "cli" || !reflect.DeepEqual(configs[1].SubscriptionIDs, []string{"sub-b"}) {
+				t.Errorf("tenant-b settings = %+v", configs[1])
+			}
+			if peak.Load() != concurrency {
+				t.Errorf("%d tenants counted at once, want %d", peak.Load(), concurrency)
+			}
+
+			if result.TotalResources != 6 || result.TotalAccounts != 3 {
+				t.Errorf("totals = %d resources in %d accounts, want 6 in 3", result.TotalResources, result.TotalAccounts)
+			}
+			account := result.AccountCounts[1]
+			if account.ID != "tenant-b/sub-b" || account.Tenant != "tenant-b" {
+				t.Errorf("account = %+v, want tenant-b/sub-b of tenant-b", account)
+			}
+			if got := result.ResourceCounts[0].ByAccount["tenant-b/sub-b"]; got != 2 {
+				t.Errorf("ByAccount = %v, want tenant-qualified IDs", result.ResourceCounts[0].ByAccount)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+func TestCheckThresholds(t *testing.T) {
+	result := &models.SizingResult{TotalResources: 100, TotalAccounts: 4}
+	tests := []struct {
+		name                      string
+		maxResources, maxAccounts int
+		want                      *models.Thresholds
+	}{
+		{name: "disabled"},
+		{name: "resources under", maxResources: 101,
+			want: &models.Thresholds{MaxResources: 101}},
+		{name: "resources at the limit", maxResources: 100,
+			want: &models.Thresholds{MaxResources: 100}},
+		{name: "resources over", maxResources: 99,
+			want: &models.Thresholds{MaxResources: 99, ResourcesExceeded: true}},
+		{name: "accounts at the limit", maxAccounts: 4,
+			want: &models.Thresholds{MaxAccounts: 4}},
+		{name: "accounts over", maxAccounts: 3,
+			want: &models.Thresholds{MaxAccounts: 3, AccountsExceeded: true}},
+		{name: "both over", maxResources: 10, maxAccounts: 1,
+			want: &models.Thresholds{MaxResources: 10, MaxAccounts: 1, ResourcesExceeded: true, AccountsExceeded: true}},
+		{name: "accounts over, resources disabled", maxAccounts: 1,
+			want: &models.Thresholds{MaxAccounts: 1, AccountsExceeded: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := New(&Config{Provider: "aws", Quiet: true, MaxResources: tt.maxResources, MaxAccounts: tt.maxAccounts})
+			if got := agent.checkThresholds(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkThresholds() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// partialProvider counts like fakeProvider but fails one resource type
+type partialProvider struct{ fakeProvider }
+
+func (p partialProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
+	result, err := p.fakeProvider.CountResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result, &sizingerrors.PartialResultError{Failed: []string{"s3:bucket"}}
+}
+
+func TestRunThresholdsPartialScan(t *testing.T) {
+	var runErr error
+	captureOutput(t, func() {
+		agent := New(&Config{Provider: "aws", OutputFormat: "json", Quiet: true, MaxResources: 1})
+		agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+			return partialProvider{}, nil
+		}
+		runErr = agent.Run(context.Background())
+	})
+
+	var partial *sizingerrors.PartialResultError
+	if !errors.As(runErr, &partial) || errors.Is(runErr, ErrThresholdExceeded) {
+		t.Errorf("Run() error = %v, want the partial scan error rather than the threshold breach", runErr)
+	}
+}
+
+func TestRunThresholds(t *testing.T) {
+	// fakeProvider counts 2 resources in 1 account
+	tests := []struct {
+		name         string
+		maxResources int
+		maxAccounts  int
+		wantErr      error
+	}{
+		{name: "disabled"},
+		{name: "at the limits", maxResources: 2, maxAccounts: 1},
+		{name: "resources breached", maxResources: 1, maxAccounts: 1, wantErr: ErrThresholdExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runErr error
+			captureOutput(t, func() {
+				agent := New(&Config{Provider: "aws", OutputFormat: "json", Quiet: true,
+					MaxResources: tt.maxResources, MaxAccounts: tt.maxAccounts})
+				agent.getProvider = func(config.ProviderConfig) (providers.Provider, error) {
+					return fakeProvider{}, nil
+				}
+				runErr = agent.Run(context.Background())
+			})
+			if !errors.Is(runErr, tt.wantErr) {
+				t.Errorf("Run() error = %v, want %v", runErr, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/secrails/secrails-sizing-agent/internal/providers/httpclient"
+	"github.com/secrails/secrails-sizing-agent/internal/update"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"go.uber.org/zap"
+)
+
+// startUpdateCheck looks up the latest release in the background, through
+// the scan's proxy and CA bundle. It never fails the scan: without an HTTP
+// client the check is skipped.
+func (a *Agent) startUpdateCheck(ctx context.Context) {
+	client, err := httpclient.New(a.config.CABundle, a.config.Proxy)
+	if err != nil {
+		a.log.Debug("Skipping the update check", zap.Error(err))
+		return
+	}
+	a.update = update.Start(ctx, update.Options{CacheDir: a.config.CacheDir, Client: client})
+}
+
+// updateNotice tells once, after the scan, that a newer release is
+// available, if the check found one in time
+func (a *Agent) updateNotice() {
+	if notice := a.update.Notice(version.Get()); notice != "" {
+		a.progress.Status("\n%s", notice)
+	}
+}
@@ -0,0 +1,244 @@
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// tokenLength is the number of hex characters kept from each hash
+const tokenLength = 16
+
+// Anonymizer replaces account and resource identifiers with salted hashes
+// and their names with sequential placeholders. The same input always maps to
+// the same token for the lifetime of an Anonymizer, which is safe for
+// concurrent use.
+type Anonymizer struct {
+	salt []byte
+
+	mu            sync.Mutex
+	ids           map[string]string
+	names         map[string]string
+	groups        map[string]string
+	resourceNames map[string]string
+}
+
+// Mapping records the original value behind every token
+type Mapping struct {
+	IDs   map[string]string `json:"ids"`
+	Names map[string]string `json:"names"`
+}
+
+// New creates an anonymizer with a random per-run salt
+func New() (*Anonymizer, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate anonymization salt: %w", err)
+	}
+	return NewWithSalt(salt), nil
+}
+
+// NewWithSalt creates an anonymizer with the given salt
+func NewWithSalt(salt []byte) *Anonymizer {
+	return &Anonymizer{
+		salt:          salt,
+		ids:           make(map[string]string),
+		names:         make(map[string]string),
+		groups:        make(map[string]string),
+		resourceNames: make(map[string]string),
+	}
+}
+
+// ID returns the token for an account or subscription ID
+func (a *Anonymizer) ID(id string) string {
+	return a.hash("acct-", id)
+}
+
+// ResourceID returns the token for a resource ID, such as an ARN or an Azure
+// resource ID, which embeds the account and the resource's name
+func (a *Anonymizer) ResourceID(id string) string {
+	return a.hash("res-", id)
+}
+
+// Name returns the placeholder for an account or subscription name
+func (a *Anonymizer) Name(name string) string {
+	return a.placeholder(a.names, "account-", name)
+}
+
+// GroupName returns the placeholder for a management group name. The
+// unassigned bucket keeps its name.
+func (a *Anonymizer) GroupName(name string) string {
+	if name == models.UnassignedManagementGroup {
+		return name
+	}
+	return a.placeholder(a.groups, "group-", name)
+}
+
+// Resource anonymizes an inventory record in place: its ID, name and
+// account are replaced, and its tags, which often name people and projects,
+// are dropped
+func (a *Anonymizer) Resource(resource *models.Resource) {
+	resource.ID = a.ResourceID(resource.ID)
+	resource.Name = a.placeholder(a.resourceNames, "resource-", resource.Name)
+	resource.Account = a.ID(resource.Account)
+	resource.Tags = nil
+}
+
+// Sink returns a sink anonymizing every resource before writing it to next
+func (a *Anonymizer) Sink(next models.ResourceSink) models.ResourceSink {
+	return sink{anonymizer: a, next: next}
+}
+
+// sink anonymizes inventory records on their way to the inventory file
+type sink struct {
+	anonymizer *Anonymizer
+	next       models.ResourceSink
+}
+
+func (s sink) Write(resource models.Resource) error {
+	s.anonymizer.Resource(&resource)
+	return s.next.Write(resource)
+}
+
+// hash returns prefix followed by the salted hash of value, recording it in
+// the ID mapping
+func (a *Anonymizer) hash(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if token, ok := a.ids[value]; ok {
+		return token
+	}
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(value))
+	token := prefix + hex.EncodeToString(mac.Sum(nil))[:tokenLength]
+	a.ids[value] = token
+	return token
+}
+
+// placeholder returns prefix followed by the position of value among the
+// values of tokens, recording it there
+func (a *Anonymizer) placeholder(tokens map[string]string, prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if token, ok := tokens[value]; ok {
+		return token
+	}
+	token := fmt.Sprintf("%s%d", prefix, len(tokens)+1)
+	tokens[value] = token
+	return token
+}
+
+// Apply anonymizes every account reference in the result in place
+func (a *Anonymizer) Apply(result *models.SizingResult) {
+	result.OrganizationID = a.ID(result.OrganizationID)
+	result.AssumedRoleARN = a.arn(result.AssumedRoleARN)
+
+	for i := range result.AccountCounts {
+		result.AccountCounts[i].ID = a.ID(result.AccountCounts[i].ID)
+		result.AccountCounts[i].Name = a.Name(result.AccountCounts[i].Name)
+		result.AccountCounts[i].Email = ""
+		result.AccountCounts[i].Tags = nil
+		result.AccountCounts[i].Tenant = a.ID(result.AccountCounts[i].Tenant)
+		for j := range result.AccountCounts[i].ManagementGroupPath {
+			a.group(&result.AccountCounts[i].ManagementGroupPath[j])
+		}
+	}
+
+	for i := range result.ExcludedAccounts {
+		result.ExcludedAccounts[i].ID = a.ID(result.ExcludedAccounts[i].ID)
+		result.ExcludedAccounts[i].Name = a.Name(result.ExcludedAccounts[i].Name)
+		result.ExcludedAccounts[i].Email = ""
+		result.ExcludedAccounts[i].Tags = nil
+		result.ExcludedAccounts[i].Tenant = a.ID(result.ExcludedAccounts[i].Tenant)
+	}
+
+	for i := range result.Directories {
+		result.Directories[i].ID = a.ID(result.Directories[i].ID)
+		result.Directories[i].Name = a.Name(result.Directories[i].Name)
+	}
+
+	for i := range result.ManagementGroups {
+		total := &result.ManagementGroups[i]
+		if total.ID != models.UnassignedManagementGroup {
+			total.ID = a.ID(total.ID)
+		}
+		total.Name = a.GroupName(total.Name)
+		for j := range total.Path {
+			total.Path[j] = a.GroupName(total.Path[j])
+		}
+	}
+
+	for _, rc := range result.ResourceCounts {
+		if len(rc.ByAccount) == 0 {
+			continue
+		}
+		byAccount := make(map[string]int, len(rc.ByAccount))
+		for id, count := range rc.ByAccount {
+			byAccount[a.ID(id)] += count
+		}
+		rc.ByAccount = byAccount
+	}
+}
+
+// arn replaces the account of an ARN, arn:partition:service:region:account:
+// resource, with its token. A value that is not an ARN is hashed whole.
+func (a *Anonymizer) arn(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" {
+		return a.ResourceID(arn)
+	}
+	parts[4] = a.ID(parts[4])
+	return strings.Join(parts, ":")
+}
+
+// group anonymizes a management group reference
+func (a *Anonymizer) group(ref *models.ManagementGroupRef) {
+	ref.ID = a.ID(ref.ID)
+	ref.Name = a.GroupName(ref.Name)
+}
+
+// Mapping returns the token to original value mapping
+func (a *Anonymizer) Mapping() Mapping {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	m := Mapping{
+		IDs:   make(map[string]string, len(a.ids)),
+		Names: make(map[string]string, len(a.names)+len(a.groups)+len(a.resourceNames)),
+	}
+	for original, token := range a.ids {
+		m.IDs[token] = original
+	}
+	for _, names := range []map[string]string{a.names, a.groups, a.resourceNames} {
+		for original, token := range names {
+			m.Names[token] = original
+		}
+	}
+	return m
+}
+
+// WriteMapping writes the mapping as JSON to a file readable only by the
+// current user
+func (a *Anonymizer) WriteMapping(path string) error {
+	data, err := json.MarshalIndent(a.Mapping(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal anonymization map: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write anonymization map: %w", err)
+	}
+	return nil
+}
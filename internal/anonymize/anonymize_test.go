@@ -0,0 +1,146 @@
+package anonymize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestApply(t *testing.T) {
+	result := &models.SizingResult{
+		AccountCounts: []models.AccountCount{
+			{ID: "111111111111", Name: "Production", Email: "aws-prod@example.com", Tags: map[string]string{"owner": "jane"}},
+			{ID: "222222222222", Name: "Staging"},
+		},
+		Directories: []models.AccountCount{{ID: "acme.okta.com", Name: "acme.okta.com"}},
+		ResourceCounts: []*models.ResourceCount{
+			{Type: "ec2:instance", ByAccount: map[string]int{"111111111111": 3, "222222222222": 1}},
+			{Type: "s3:bucket", ByAccount: map[string]int{"111111111111": 5}},
+		},
+	}
+
+	a := NewWithSalt([]byte("salt"))
+	a.Apply(result)
+
+	prodID := result.AccountCounts[0].ID
+	if prodID == "111111111111" || !strings.HasPrefix(prodID, "acct-") {
+		t.Errorf("account ID not anonymized: %s", prodID)
+	}
+	if result.AccountCounts[0].Name != "account-1" || result.AccountCounts[1].Name != "account-2" {
+		t.Errorf("unexpected names: %+v", result.AccountCounts)
+	}
+
+	if directory := result.Directories[0]; !strings.HasPrefix(directory.ID, "acct-") || directory.Name != "account-3" {
+		t.Errorf("directory not anonymized: %+v", directory)
+	}
+
+	if result.AccountCounts[0].Email != "" {
+		t.Errorf("account email not removed: %s", result.AccountCounts[0].Email)
+	}
+	if result.AccountCounts[0].Tags != nil {
+		t.Errorf("account tags not removed: %v", result.AccountCounts[0].Tags)
+	}
+
+	// Cross-references must use the same token
+	if result.ResourceCounts[0].ByAccount[prodID] != 3 || result.ResourceCounts[1].ByAccount[prodID] != 5 {
+		t.Errorf("ByAccount not consistently anonymized: %+v", result.ResourceCounts)
+	}
+	if _, ok := result.ResourceCounts[0].ByAccount["111111111111"]; ok {
+		t.Error("raw account ID left in ByAccount")
+	}
+
+	mapping := a.Mapping()
+	if mapping.IDs[prodID] != "111111111111" || mapping.Names["account-2"] != "Staging" {
+		t.Errorf("unexpected mapping: %+v", mapping)
+	}
+}
+
+func TestApplyAssumedRole(t *testing.T) {
+	result := &models.SizingResult{
+		AssumedRoleARN: "arn:aws:iam::111111111111:role/Audit",
+		AccountCounts:  []models.AccountCount{{ID: "111111111111"}},
+	}
+
+	NewWithSalt([]byte("salt")).Apply(result)
+
+	want := "arn:aws:iam::" + result.AccountCounts[0].ID + ":role/Audit"
+	if result.AssumedRoleARN != want {
+		t.Errorf("AssumedRoleARN = %q, want %q", result.AssumedRoleARN, want)
+	}
+}
+
+func TestIDDependsOnSalt(t *testing.T) {
+	if NewWithSalt([]byte("a")).ID("123") == NewWithSalt([]byte("b")).ID("123") {
+		t.Error("expected different tokens for different salts")
+	}
+	a := NewWithSalt([]byte("a"))
+	if a.ID("123") != a.ID("123") {
+		t.Error("expected stable token for the same input")
+	}
+}
+
+func TestApplyManagementGroups(t *testing.T) {
+	prod := models.ManagementGroupRef{ID: "mg-prod", Name: "Production"}
+	result := &models.SizingResult{
+		AccountCounts: []models.AccountCount{{ID: "sub-1", ManagementGroupPath: []models.ManagementGroupRef{prod}}},
+		ManagementGroups: []models.ManagementGroupTotal{
+			{ID: "mg-prod", Name: "Production", Path: []string{"Production"}},
+			{ID: models.UnassignedManagementGroup, Name: models.UnassignedManagementGroup,
+				Path: []string{models.UnassignedManagementGroup}},
+		},
+	}
+
+	a := NewWithSalt([]byte("salt"))
+	a.Apply(result)
+
+	ref := result.AccountCounts[0].ManagementGroupPath[0]
+	if ref.ID == "mg-prod" || ref.Name != "group-1" {
+		t.Errorf("management group path not anonymized: %+v", ref)
+	}
+	total := result.ManagementGroups[0]
+	if total.ID != ref.ID || total.Name != "group-1" || total.Path[0] != "group-1" {
+		t.Errorf("management group total not anonymized consistently: %+v", total)
+	}
+	if result.ManagementGroups[1].Name != models.UnassignedManagementGroup {
+		t.Errorf("unassigned bucket renamed: %+v", result.ManagementGroups[1])
+	}
+	if a.Mapping().Names["group-1"] != "Production" {
+		t.Errorf("unexpected mapping: %+v", a.Mapping())
+	}
+}
+
+// resourceRecorder keeps the resources written to it
+type resourceRecorder struct{ resources []models.Resource }
+
+func (r *resourceRecorder) Write(resource models.Resource) error {
+	r.resources = append(r.resources, resource)
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	a := NewWithSalt([]byte("salt"))
+	recorder := &resourceRecorder{}
+	arn := "arn:aws:ec2:us-east-1:111111111111:instance/i-0abc"
+	err := a.Sink(recorder).Write(models.Resource{
+		ID: arn, Name: "payroll-db", Type: "ec2:instance", Region: "us-east-1",
+		Account: "111111111111", Tags: map[string]string{"owner": "jane"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := recorder.resources[0]
+	if !strings.HasPrefix(got.ID, "res-") || got.Name != "resource-1" || got.Tags != nil {
+		t.Errorf("resource not anonymized: %+v", got)
+	}
+	if got.Account != a.ID("111111111111") {
+		t.Errorf("account = %s, want the result's token %s", got.Account, a.ID("111111111111"))
+	}
+	if got.Type != "ec2:instance" || got.Region != "us-east-1" {
+		t.Errorf("type or region changed: %+v", got)
+	}
+	if mapping := a.Mapping(); mapping.IDs[got.ID] != arn || mapping.Names["resource-1"] != "payroll-db" {
+		t.Errorf("resource missing from the mapping: %+v", mapping)
+	}
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+
+	"go.uber.org/zap"
+)
+
+// DefaultTTL is how long discovery results are reused by default
+const DefaultTTL = time.Hour
+
+// formatVersion is bumped whenever the file layout changes; files written
+// with another version are ignored
+const formatVersion = 5
+
+// unsafeKeyChars are replaced when a key is turned into a file name
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Account is the cached part of an account or subscription: every field of
+// models.AccountCount but its resource counts, which a scan fills in
+type Account struct {
+	ID                  string                      `json:"id"`
+	Name                string                      `json:"name,omitempty"`
+	Email               string                      `json:"email,omitempty"`
+	Status              string                      `json:"status,omitempty"`
+	Tags                map[string]string           `json:"tags,omitempty"`
+	Offer               string                      `json:"offer,omitempty"`
+	ManagementGroupPath []models.ManagementGroupRef `json:"management_group_path,omitempty"`
+	Profile             string                      `json:"profile,omitempty"`
+	Tenant              string                      `json:"tenant,omitempty"`
+}
+
+// Discovery is the outcome of a provider's account and region discovery
+type Discovery struct {
+	Accounts []Account `json:"accounts"`
+	Regions  []string  `json:"regions,omitempty"`
+
+	// Source is how the accounts were found, e.g. "organizations"
+	Source string `json:"source,omitempty"`
+
+	// OrganizationID and ManagementAccount describe the AWS organization of
+	// the caller's account
+	OrganizationID    string `json:"organization_id,omitempty"`
+	ManagementAccount bool   `json:"management_account,omitempty"`
+}
+
+// entry is the on-disk layout of a cache file
+type entry struct {
+	Version   int        `json:"version"`
+	StoredAt  time.Time  `json:"stored_at"`
+	Discovery *Discovery `json:"discovery"`
+}
+
+// Cache stores discovery results as JSON files in a local directory. A nil
+// *Cache is valid and caches nothing.
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// New returns a cache in dir whose entries expire after ttl
+func New(dir string, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Cache{dir: dir, ttl: ttl, now: time.Now}
+}
+
+// DefaultDir returns $XDG_CACHE_HOME/secrails-sizing-agent, falling back to
+// the platform's user cache directory
+func DefaultDir() string {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		var err error
+		if base, err = os.UserCacheDir(); err != nil {
+			base = os.TempDir()
+		}
+	}
+	return filepath.Join(base, "secrails-sizing-agent")
+}
+
+// Load returns the discovery stored under key if it exists and has not
+// expired. Missing, stale or unreadable files are a cache miss.
+func (c *Cache) Load(key string) (*Discovery, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Debug("Ignoring unreadable discovery cache", zap.String("path", path), zap.Error(err))
+		}
+		return nil, false
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || e.Version != formatVersion || e.Discovery == nil {
+		logging.Debug("Ignoring corrupt discovery cache", zap.String("path", path), zap.Error(err))
+		return nil, false
+	}
+	if age := c.now().Sub(e.StoredAt); age < 0 || age > c.ttl {
+		logging.Debug("Ignoring stale discovery cache", zap.String("path", path), zap.Duration("age", age))
+		return nil, false
+	}
+
+	logging.Debug("Using cached discovery", zap.String("path", path), zap.Time("stored_at", e.StoredAt))
+	return e.Discovery, true
+}
+
+// Store saves discovery under key. The file is written atomically and is
+// readable only by the current user.
+func (c *Cache) Store(key string, discovery *Discovery) error {
+	if c == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry{Version: formatVersion, StoredAt: c.now(), Discovery: discovery})
+	if err != nil {
+		return fmt.Errorf("failed to encode discovery cache: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".discovery-*")
+	if err != nil {
+		return fmt.Errorf("failed to write discovery cache: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write discovery cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write discovery cache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("failed to write discovery cache: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, unsafeKeyChars.ReplaceAllString(key, "_")+".json")
+}
+
+// FromAccounts keeps the cacheable fields of accounts
+func FromAccounts(accounts []models.AccountCount) []Account {
+	cached := make([]Account, len(accounts))
+	for i, account := range accounts {
+		cached[i] = Account{
+			ID:                  account.ID,
+			Name:                account.Name,
+			Email:               account.Email,
+			Status:              account.Status,
+			Tags:                account.Tags,
+			Offer:               account.Offer,
+			ManagementGroupPath: account.ManagementGroupPath,
+			Profile:             account.Profile,
+			Tenant:              account.Tenant,
+		}
+	}
+	return cached
+}
+
+// AccountCounts converts the cached accounts back into account counts
+func (d *Discovery) AccountCounts() []models.AccountCount {
+	accounts := make([]models.AccountCount, len(d.Accounts))
+	for i, account := range d.Accounts {
+		accounts[i] = models.AccountCount{
+			ID:                  account.ID,
+			Name:                account.Name,
+			Email:               account.Email,
+			Status:              account.Status,
+			Tags:                account.Tags,
+			Offer:               account.Offer,
+			ManagementGroupPath: account.ManagementGroupPath,
+			Profile:             account.Profile,
+			Tenant:              account.Tenant,
+		}
+	}
+	return accounts
+}
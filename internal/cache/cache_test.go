@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+var testDiscovery = &Discovery{
+	Accounts: []Account{
+		{ID: "111111111111", Name: "prod", Status: "ACTIVE"},
+		{ID: "222222222222", Name: "old", Status: "SUSPENDED"},
+	},
+	Regions: []string{"us-east-1", "eu-west-1"},
+}
+
+// newTestCache returns a cache in a temporary directory with a settable clock
+func newTestCache(t *testing.T, now *time.Time) *Cache {
+	t.Helper()
+	c := New(t.TempDir(), time.Hour)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestStoreAndLoad(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestCache(t, &now)
+
+	if err := c.Store("aws-123456789012", testDiscovery); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(59 * time.Minute)
+	got, ok := c.Load("aws-123456789012")
+	if !ok {
+		t.Fatal("expected a cache hit within the TTL")
+	}
+	if !reflect.DeepEqual(got, testDiscovery) {
+		t.Errorf("Load() = %+v, want %+v", got, testDiscovery)
+	}
+
+	if _, ok := c.Load("aws-999999999999"); ok {
+		t.Error("expected a miss for another account")
+	}
+}
+
+func TestLoadExpired(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestCache(t, &now)
+
+	if err := c.Store("azure-tenant", testDiscovery); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(61 * time.Minute)
+	if _, ok := c.Load("azure-tenant"); ok {
+		t.Error("expected a miss after the TTL")
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestCache(t, &now)
+
+	tests := map[string]string{
+		"not json":      "{not json",
+		"wrong version": `{"version": 99, "stored_at": "2026-01-01T12:00:00Z", "discovery": {"accounts": []}}`,
+		"no discovery":  `{"version": 1, "stored_at": "2026-01-01T12:00:00Z"}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := os.WriteFile(c.path(name), []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := c.Load(name); ok {
+				t.Error("expected a miss for a corrupt file")
+			}
+		})
+	}
+}
+
+func TestStoreFilePermissionsAndName(t *testing.T) {
+	now := time.Now()
+	c := newTestCache(t, &now)
+
+	if err := c.Store("azure-../../etc", testDiscovery); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("cache directory has %d entries, want 1", len(entries))
+	}
+	if name := entries[0].Name(); name != "azure-.._.._etc.json" {
+		t.Errorf("cache file name = %q, want the key sanitized", name)
+	}
+
+	info, err := entries[0].Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		t.Errorf("cache file mode = %v, want owner-only", perm)
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	if err := c.Store("key", testDiscovery); err != nil {
+		t.Errorf("Store() on nil cache = %v", err)
+	}
+	if _, ok := c.Load("key"); ok {
+		t.Error("Load() on nil cache should miss")
+	}
+}
+
+func TestAccountRoundTrip(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newTestCache(t, &now)
+	accounts := []models.AccountCount{
+		{ID: "111111111111", Name: "prod", Email: "root@example.com", Status: "ACTIVE", Profile: "prod-sso"},
+		{
+			ID: "tenant-1/sub-1", Name: "dev", Status: "Enabled", Tenant: "tenant-1",
+			Tags: map[string]string{"environment": "dev"}, Offer: "MSDN_2014-09-01",
+			ManagementGroupPath: []models.ManagementGroupRef{{ID: "mg-root", Name: "Root"}, {ID: "mg-dev", Name: "Dev"}},
+		},
+	}
+
+	// Every field survives the file, while the counts of a scan are dropped
+	counted := append([]models.AccountCount(nil), accounts...)
+	counted[0].ResourceCount = 42
+	counted[0].ByType = map[models.ResourceType]int{models.ResourceTypeCache: 42}
+	if err := c.Store("accounts", &Discovery{Accounts: FromAccounts(counted)}); err != nil {
+		t.Fatal(err)
+	}
+	discovery, ok := c.Load("accounts")
+	if !ok {
+		t.Fatal("expected a cache hit")
+	}
+	if got := discovery.AccountCounts(); !reflect.DeepEqual(got, accounts) {
+		t.Errorf("round trip = %+v, want %+v", got, accounts)
+	}
+}
@@ -2,91 +2,753 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	"github.com/secrails/secrails-sizing-agent/internal/cache"
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+	"github.com/secrails/secrails-sizing-agent/internal/identity"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/aws"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/azure"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/counting"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/oci"
+	"github.com/secrails/secrails-sizing-agent/internal/report"
+	"github.com/secrails/secrails-sizing-agent/internal/sink"
+	"github.com/secrails/secrails-sizing-agent/internal/update"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
 )
 
 // CLI handles command-line interface interactions
 type CLI struct {
 	reader *bufio.Reader
+
+	// interactive is set when stdin is a terminal, enabling the wizard
+	interactive bool
 }
 
 // New creates a new CLI handler
 func New() *CLI {
 	return &CLI{
-		reader: bufio.NewReader(os.Stdin),
+		reader:      bufio.NewReader(os.Stdin),
+		interactive: isTerminal(os.Stdin),
+	}
+}
+
+// GetConfig parses the arguments of the scan or accounts command and/or
+// prompts the user to build the configuration
+func (c *CLI) GetConfig(command string, args []string) (*agent.Config, error) {
+	if command == CommandAccounts {
+		args = slices.Concat([]string{"--accounts-only"}, args)
 	}
+	return c.getConfig(flag.NewFlagSet(command, flag.ContinueOnError), args)
 }
 
-// GetConfig parses flags and/or prompts user to build configuration
-func (c *CLI) GetConfig() (*agent.Config, error) {
+// getConfig builds the configuration from args, parsed with fs. The
+// provider may also be given as an argument, as in
+// "sizing-agent scan azure --format json"; --provider wins over it, and the
+// wizard only asks when neither is given.
+func (c *CLI) getConfig(fs *flag.FlagSet, args []string) (*agent.Config, error) {
 	config := &agent.Config{
 		OutputFormat: "table", // default
 	}
 
-	// Parse command-line flags
-	flag.StringVar(&config.Provider, "provider", "", "Cloud provider (aws or azure)")
-	flag.StringVar(&config.OutputFormat, "format", "table", "Output format (json, yaml, table, csv)")
-	flag.StringVar(&config.OutputFile, "output", "", "Output file path")
-	flag.BoolVar(&config.Verbose, "verbose", false, "Enable verbose output")
-	flag.Parse()
+	flags := defineScanFlags(fs, config)
+	arguments, err := parseInterspersed(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(arguments) > 1 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(arguments[1:], " "))
+	}
+	positional := ""
+	if len(arguments) == 1 {
+		positional = arguments[0]
+	}
+
+	config.Regions = splitList(*flags.regions)
+	config.Profiles = splitList(*flags.profiles)
+	config.Subscriptions = splitList(*flags.subscriptions)
+	config.KubeContexts = splitList(*flags.contexts)
+	config.BillableTypes = splitList(*flags.billableTypes)
+	config.DebugDumpRedact = splitList(*flags.debugDumpRedact)
+	config.UpdateCheck = !*flags.noUpdateCheck && !update.Disabled(os.Getenv)
+	if *flags.encryptKey != "" {
+		key, named, err := sink.ReadKey(*flags.encryptKey)
+		if err != nil {
+			return nil, err
+		}
+		config.EncryptKey = key
+		if named {
+			config.EncryptKeySource = *flags.encryptKey
+		}
+	}
+
+	// Settings from the config file apply unless overridden by a flag
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	if positional != "" && !setFlags["provider"] {
+		config.Provider = positional
+		setFlags["provider"] = true
+	}
+	savePath, required := *flags.configPath, true
+	if savePath == "" {
+		savePath, required = DefaultConfigPath(), false
+	}
+	if savePath != "" {
+		file, err := loadConfigFile(savePath, required)
+		if err != nil {
+			return nil, err
+		}
+		if file != nil {
+			file.apply(config, setFlags)
+		}
+	}
+
+	// Without a provider, ask for the settings of a first run; the wizard
+	// needs a terminal and makes no sense for a resident scheduler
+	if config.Provider == "" && config.Schedule == "" {
+		if !c.interactive {
+			return nil, fmt.Errorf("a provider is required when not running interactively, as the first argument or with --provider")
+		}
+		if err := newWizard(c.reader, os.Stderr).run(context.Background(), config, setFlags, savePath); err != nil {
+			return nil, err
+		}
+	}
+	config.Provider = providers.Normalize(config.Provider)
 
 	// Show debug info if verbose
 	if config.Verbose {
 		c.printDebugInfo(config)
 	}
 
-	// If no provider specified, prompt for it
-	if config.Provider == "" {
-		provider, err := c.promptForProvider()
-		if err != nil {
+	if err := c.validate(config, setFlags); err != nil {
+		return nil, err
+	}
+	if config.MFASerial != "" {
+		config.MFATokenProvider = c.readMFACode(config.MFASerial)
+	}
+
+	return config, nil
+}
+
+// scanFlags holds the flags of a scan that are parsed further before they
+// are set on the configuration
+type scanFlags struct {
+	configPath      *string
+	profiles        *string
+	encryptKey      *string
+	debugDumpRedact *string
+	regions         *string
+	subscriptions   *string
+	contexts        *string
+	billableTypes   *string
+	noUpdateCheck   *bool
+}
+
+// defineScanFlags defines the flags of a scan on fs, setting config
+func defineScanFlags(fs *flag.FlagSet, config *agent.Config) *scanFlags {
+	flags := &scanFlags{}
+	flags.configPath = fs.String("config", "", "Config file with default settings (default: "+DefaultConfigPath()+" if it exists)")
+	fs.StringVar(&config.Provider, "provider", "", "Cloud provider ("+strings.Join(providers.ListProviders(), ", ")+"), also accepted as the first argument")
+	fs.StringVar(&config.Profile, "profile", "", "AWS shared config profile (default: AWS_PROFILE, then the default profile)")
+	flags.profiles = fs.String("profiles", "", "Comma-separated AWS profiles to scan one by one and merge into one result")
+	fs.StringVar(&config.OutputFormat, "format", "table", "Output format (json, yaml, table, csv, ndjson, markdown)")
+	fs.BoolVar(&config.Quiet, "quiet", false, "Suppress banners and progress; only results, warnings and errors are written")
+	fs.BoolVar(&config.LegacyJSON, "legacy-json", false, "Write the deprecated version 1 JSON layout (PascalCase keys, no schema_version)")
+	fs.StringVar(&config.OutputFile, "output", "", "Output file path")
+	fs.BoolVar(&config.SplitByAccount, "split-by-account", false, "Also write each account's/subscription's results to <output-base>-<account-id>.<ext>")
+	fs.BoolVar(&config.Compress, "compress", false, "Gzip the output and inventory files, appending .gz to their names")
+	flags.encryptKey = fs.String("encrypt-key", "", "Encrypt the output and inventory files with this passphrase, file:PATH's first line or env:NAME's value, appending .enc")
+	fs.BoolVar(&config.Verbose, "verbose", false, "Enable verbose output")
+	fs.StringVar(&config.LogLevel, "log-level", "", "Log level (debug, info, warn, error) (default: info, warn with --quiet)")
+	fs.BoolVar(&config.NoRedact, "no-redact", false, "Log account IDs, GUIDs, ARNs, names and tags unmasked, for internal debugging")
+	fs.BoolVar(&config.ASCII, "ascii", false, "Write the table and progress without emoji or other non-ASCII symbols")
+	fs.BoolVar(&config.DryRun, "dry-run", false, "Authenticate and discover, then print the scan plan instead of counting")
+	fs.BoolVar(&config.Stats, "stats", false, "Include per-type scan durations, API pages and retries in JSON and YAML output")
+	fs.BoolVar(&config.AccountsOnly, "accounts-only", false, "Authenticate and list the accounts/subscriptions the credentials can see, without counting")
+	fs.StringVar(&config.ResourceDefinitions, "resource-definitions", "", "YAML file that merges with or replaces the built-in resource definitions")
+	fs.BoolVar(&config.StrictDefinitions, "strict-definitions", false, "Fail instead of warning on resource types the provider cannot count, such as unknown AWS tagging API filters")
+	fs.BoolVar(&config.Inventory, "inventory", false, "List individual resources in addition to counts")
+	fs.StringVar(&config.InventoryFormat, "inventory-format", "ndjson", "Inventory file format (ndjson, csv)")
+	fs.StringVar(&config.InventoryOutput, "inventory-output", "", "Inventory file path (default: derived from --output)")
+	fs.StringVar(&config.DebugDump, "debug-dump", "", "Write every raw AWS and Azure API response to this new directory, tag values redacted, to debug wrong counts")
+	fs.IntVar(&config.DebugDumpMaxMB, "debug-dump-max-mb", debugdump.DefaultMaxBytes>>20, "Stop writing the debug dump once it reaches this size in megabytes")
+	flags.debugDumpRedact = fs.String("debug-dump-redact", "", "Comma-separated response fields whose values the debug dump redacts, in addition to tag values")
+	fs.BoolVar(&config.Anonymize, "anonymize", false, "Replace account/subscription IDs and names, and inventory resource IDs and names, with anonymous tokens")
+	fs.StringVar(&config.AnonymizeMap, "anonymize-map", "", "Write the anonymization mapping to this local file")
+	fs.StringVar(&config.Weights, "weights", "", "YAML file overriding the workload-unit weights and tiers")
+	fs.IntVar(&config.MaxResources, "max-resources", 0, "Exit with code 3 if the total resource count exceeds this value (0 disables)")
+	fs.IntVar(&config.MaxAccounts, "max-accounts", 0, "Exit with code 3 if the account/subscription count exceeds this value (0 disables)")
+	fs.StringVar(&config.Region, "region", "", "AWS region for discovery calls (default: AWS config file, AWS_REGION, then us-east-1)")
+	flags.regions = fs.String("regions", "", "Comma-separated AWS or OCI regions to scan (default: all enabled and accessible regions)")
+	flags.subscriptions = fs.String("subscriptions", "", "Comma-separated Azure subscription IDs to scan (default: AZURE_SUBSCRIPTION_ID, then all)")
+	fs.StringVar(&config.Kubeconfig, "kubeconfig", "", "Kubeconfig file of a Kubernetes scan (default: KUBECONFIG, then ~/.kube/config)")
+	flags.contexts = fs.String("context", "", "Comma-separated kubeconfig contexts to scan, one cluster each (default: the current context)")
+	fs.StringVar(&config.OCIAuth, "oci-auth", "", "OCI authentication method ("+strings.Join(oci.AuthMethods, ", ")+") (default: config)")
+	fs.StringVar(&config.OCIConfigFile, "oci-config-file", "", "OCI config file (default: OCI_CLI_CONFIG_FILE, then ~/.oci/config)")
+	fs.StringVar(&config.OCIProfile, "oci-profile", "", "Profile of the OCI config file (default: OCI_CLI_PROFILE, then DEFAULT)")
+	fs.StringVar(&config.AssumeRoleARN, "assume-role-arn", "", "AWS role to assume for the scan")
+	fs.StringVar(&config.ExternalID, "external-id", "", "External ID required by the role of --assume-role-arn")
+	fs.StringVar(&config.MFASerial, "mfa-serial", "", "MFA device serial or ARN required by the role of --assume-role-arn; the code is asked for")
+	fs.StringVar(&config.AzureAuth, "azure-auth", azure.AuthDefault, "Azure authentication method ("+strings.Join(azure.AuthMethods, ", ")+")")
+	fs.StringVar(&config.TenantID, "tenant-id", "", "Azure tenant to sign in to (default: the credentials' home tenant)")
+	fs.IntVar(&config.TenantConcurrency, "tenant-concurrency", 2, "Tenants of azure_tenants in the config file counted at once")
+	fs.BoolVar(&config.SplitByTenant, "split-by-tenant", false, "Write each tenant of azure_tenants to <output-base>-<tenant-id>.<ext> instead of one merged result")
+	fs.StringVar(&config.CABundle, "ca-bundle", "", "PEM file of extra root certificates to trust, e.g. of a TLS-intercepting proxy")
+	fs.StringVar(&config.Proxy, "proxy", "", "Proxy URL for cloud API calls (default: HTTPS_PROXY and NO_PROXY)")
+	fs.StringVar(&config.AWSEndpointURL, "aws-endpoint-url", "", "Send every AWS API call to this URL, e.g. LocalStack (per-service URLs: aws_endpoints in the config file)")
+	fs.StringVar(&config.AzureEndpointURL, "azure-endpoint-url", "", "Azure Resource Manager URL to send API calls to (default: the public cloud's)")
+	fs.StringVar(&config.AzureAuthorityHost, "azure-authority-host", "", "Microsoft Entra ID URL to request Azure tokens from (default: the public cloud's)")
+	fs.Var((*tagFlag)(&config.Tags), "tag", "Only count resources with this tag, as key=value (repeatable; all must match)")
+	fs.Var((*patternFlag)(&config.AccountFilter.Names), "account-filter", "Only scan the AWS accounts/Azure subscriptions whose name matches this glob, e.g. 'prod-*' (repeatable; any may match)")
+	fs.Var((*tagFlag)(&config.AccountFilter.Tags), "account-tag", "Only scan the AWS accounts/Azure subscriptions with this tag, as key=value (repeatable; all must match)")
+	fs.StringVar(&config.GroupAccountsByTag, "group-accounts-by-tag", "", "Subtotal the resources per value of this account/subscription tag (Azure subscription tags)")
+	flags.billableTypes = fs.String("billable-types", "", "Comma-separated resource types counted as billable workloads (default: the types marked billable in the definitions)")
+	fs.StringVar(&config.CacheDir, "cache-dir", cache.DefaultDir(), "Directory for the discovery cache")
+	fs.DurationVar(&config.CacheTTL, "cache-ttl", cache.DefaultTTL, "How long cached discovery results are reused")
+	fs.BoolVar(&config.NoCache, "no-cache", false, "Ignore and do not write the account/subscription and region discovery cache")
+	flags.noUpdateCheck = fs.Bool("no-update-check", false, "Do not look up the latest agent release on GitHub (also disabled by "+update.AirGappedEnv+"=true)")
+	fs.BoolVar(&config.Offline, "offline", false, "Call no endpoint but the cloud provider's: no update check, and no --metrics-push-url or --otel-endpoint")
+	fs.StringVar(&config.MetricsPushURL, "metrics-push-url", "", "Push Prometheus metrics to this Pushgateway URL after the scan")
+	fs.StringVar(&config.MetricsListen, "metrics-listen", "", "Serve Prometheus metrics on this address (e.g. :9090) until scraped after the scan")
+	fs.DurationVar(&config.MetricsListenTimeout, "metrics-listen-timeout", 5*time.Minute, "How long --metrics-listen waits for the final scrape")
+	fs.StringVar(&config.OTelEndpoint, "otel-endpoint", "", "Export OpenTelemetry traces to this OTLP/HTTP endpoint (default: OTEL_EXPORTER_OTLP_ENDPOINT)")
+	fs.StringVar(&config.History, "history", "", "Append a summary of each completed scan to this JSON Lines file (see the trend command)")
+	fs.StringVar(&config.Schedule, "schedule", "", "Stay resident and scan on this interval (e.g. 24h) or cron expression (e.g. \"0 2 * * *\")")
+	fs.StringVar(&config.IncludeIdentity, "include-identity", "", "Also count the users, groups and applications of an identity provider ("+strings.Join(identity.Providers, ", ")+"; entra with --provider azure)")
+	fs.StringVar(&config.OktaOrgURL, "okta-org-url", "", "Okta org counted with --include-identity okta, e.g. https://acme.okta.com (default: "+identity.OktaOrgURLEnv+")")
+	fs.BoolVar(&config.ManagementGroups, "management-groups", false, "Place subscriptions in the management group hierarchy and subtotal by group (Azure only)")
+	fs.BoolVar(&config.IncludeSuspended, "include-suspended", false, "Count suspended AWS accounts in the account total")
+	fs.IntVar(&config.PageSize, "page-size", 0, "Resources per AWS tagging API page (up to 100) or rows per Azure Resource Graph page (up to 1000) (default: the provider's)")
+	fs.IntVar(&config.MaxPages, "max-pages", 0, "Stop counting a type after this many pages per region, recording it as truncated (default: no limit on AWS, 10 on Azure)")
+	fs.DurationVar(&config.TypeTimeout, "type-timeout", counting.DefaultTypeTimeout, "Fail a resource type whose count takes longer than this, retrying it with --retry-failed (0 disables)")
+	fs.IntVar(&config.RegionFailureThreshold, "region-failure-threshold", aws.DefaultRegionFailureThreshold, "Skip an AWS region for the remaining types after this many consecutive failed counts in it (0 disables)")
+	fs.IntVar(&config.RetryFailed, "retry-failed", 1, "Passes retrying the resource types whose count failed, e.g. when throttled (0-3)")
+	fs.BoolVar(&config.ExcludeNoise, "exclude-noise", false, "Leave cloud-managed resources such as default security groups and network watchers out of the totals")
+	fs.BoolVar(&config.DeepRegistries, "deep-registries", false, "Count the images in ECR repositories and the repositories in Azure Container Registries (one or more API calls each)")
+	fs.BoolVar(&config.DeepStorage, "deep-storage", false, "Count the blob containers, file shares, queues and tables of storage accounts (Azure only; up to four API calls each)")
+	fs.BoolVar(&config.DeepBackup, "deep-backup", false, "Count the backup items and Site Recovery replicated items of Recovery Services vaults (Azure only; two or more API calls each)")
+	fs.BoolVar(&config.NoRegionPrecheck, "no-region-precheck", false, "Scan every AWS region with the tagging API, even those the region probe found without tagged resources")
+	return flags
+}
+
+// parseInterspersed parses args with fs, allowing arguments between and
+// after the flags since flag parsing stops at the first argument, and
+// returns the arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var arguments []string
+	for {
+		if err := fs.Parse(args); err != nil {
 			return nil, err
 		}
-		config.Provider = provider
+		if fs.NArg() == 0 {
+			return arguments, nil
+		}
+		arguments = append(arguments, fs.Arg(0))
+		args = fs.Args()[1:]
 	}
+}
 
-	return config, nil
+// validate checks the settings before anything connects, reporting every
+// problem found rather than stopping at the first
+func (c *CLI) validate(config *agent.Config, setFlags map[string]bool) error {
+	var problems []error
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if !report.ValidFormat(config.OutputFormat) {
+		fail("unsupported --format %q (supported: %s)", config.OutputFormat, strings.Join(report.Formats, ", "))
+	}
+	if config.LogLevel != "" && !logging.ValidLevel(config.LogLevel) {
+		fail("unsupported --log-level %q (supported: %s)", config.LogLevel, strings.Join(logging.Levels, ", "))
+	}
+	if config.Quiet && config.Verbose {
+		fail("--quiet and --verbose are mutually exclusive")
+	}
+
+	check(config.ApplyOffline())
+
+	if config.LegacyJSON && config.OutputFormat != report.FormatJSON {
+		fail("--legacy-json requires --format json")
+	}
+
+	if config.DryRun {
+		if config.Schedule != "" {
+			fail("--dry-run cannot be used with --schedule")
+		}
+		if !report.ValidPlanFormat(config.OutputFormat) {
+			fail("--dry-run supports --format %s", strings.Join(report.PlanFormats, ", "))
+		}
+	}
+
+	if config.AccountsOnly && !report.ValidAccountFormat(config.OutputFormat) {
+		fail("--accounts-only supports --format %s", strings.Join(report.AccountFormats, ", "))
+	}
+	if config.OutputFormat == report.FormatNDJSON && config.Anonymize {
+		fail("--format ndjson cannot be used with --anonymize, as counts are written before they could be anonymized")
+	}
+
+	if config.AccountsOnly && (config.DryRun || config.Schedule != "" || config.Inventory || config.Anonymize) {
+		fail("--accounts-only cannot be used with --dry-run, --schedule, --inventory or --anonymize")
+	}
+
+	if config.DebugDump != "" {
+		if config.DryRun || config.AccountsOnly || config.Schedule != "" {
+			fail("--debug-dump cannot be used with --dry-run, --accounts-only or --schedule")
+		}
+		if config.DebugDumpMaxMB <= 0 {
+			fail("--debug-dump-max-mb must be positive")
+		}
+	} else if config.DebugDumpMaxMB != debugdump.DefaultMaxBytes>>20 || len(config.DebugDumpRedact) > 0 {
+		fail("--debug-dump-max-mb and --debug-dump-redact require --debug-dump")
+	}
+
+	if config.SplitByAccount {
+		if config.OutputFile == "" {
+			fail("--split-by-account requires --output")
+		}
+		if config.DryRun || config.AccountsOnly {
+			fail("--split-by-account cannot be used with --dry-run or --accounts-only")
+		}
+	}
+
+	if (config.Compress || config.EncryptKey != "") && config.OutputFile == "" {
+		fail("--compress and --encrypt-key require --output")
+	}
+
+	if (config.ExternalID != "" || config.MFASerial != "") && config.AssumeRoleARN == "" {
+		fail("--external-id and --mfa-serial require --assume-role-arn")
+	}
+	if config.MFASerial != "" && (!c.interactive || config.Schedule != "") {
+		fail("--mfa-serial needs an interactive terminal to enter MFA codes and cannot be used with --schedule")
+	}
+
+	if len(config.Profiles) > 0 {
+		if config.Provider != "aws" {
+			fail("--profiles applies to --provider aws only")
+		}
+		if config.Profile != "" {
+			fail("--profile and --profiles are mutually exclusive")
+		}
+		if config.DryRun || config.AccountsOnly {
+			fail("--profiles cannot be used with --dry-run or --accounts-only")
+		}
+	}
+
+	if (config.Kubeconfig != "" || len(config.KubeContexts) > 0) && config.Provider != "k8s" {
+		fail("--kubeconfig and --context apply to --provider k8s only")
+	}
+	if config.OCIAuth != "" || config.OCIConfigFile != "" || config.OCIProfile != "" {
+		if config.Provider != "oci" {
+			fail("--oci-auth, --oci-config-file and --oci-profile apply to --provider oci only")
+		}
+		if config.OCIAuth != "" && !oci.ValidAuthMethod(config.OCIAuth) {
+			fail("unsupported --oci-auth %q (supported: %s)", config.OCIAuth, strings.Join(oci.AuthMethods, ", "))
+		}
+	}
+
+	check(validateIdentity(config))
+
+	check(validateTenants(config))
+	if (len(config.Profiles) > 0 || len(config.AzureTenants) > 0) && config.OutputFormat == report.FormatNDJSON {
+		fail("--format ndjson cannot be used with --profiles or azure_tenants, as each scan's counts would be streamed before they are merged")
+	}
+
+	if !azure.ValidAuthMethod(config.AzureAuth) {
+		fail("unsupported --azure-auth %q (supported: %s)", config.AzureAuth, strings.Join(azure.AuthMethods, ", "))
+	}
+
+	check(validateEndpoints(config, setFlags))
+
+	if config.CacheTTL <= 0 {
+		fail("--cache-ttl must be positive")
+	}
+
+	if config.MaxResources < 0 || config.MaxAccounts < 0 {
+		fail("--max-resources and --max-accounts must not be negative")
+	}
+
+	if config.RetryFailed < 0 || config.RetryFailed > counting.MaxRetries {
+		fail("--retry-failed must be between 0 and %d", counting.MaxRetries)
+	}
+	problems = append(problems, validatePaging(config)...)
+	if config.TypeTimeout < 0 {
+		fail("--type-timeout must not be negative")
+	}
+	if config.RegionFailureThreshold < 0 {
+		fail("--region-failure-threshold must not be negative")
+	}
+
+	if config.Schedule != "" {
+		_, err := agent.ParseSchedule(config.Schedule)
+		check(err)
+		if config.Provider == "" {
+			fail("--schedule requires --provider")
+		}
+	}
+
+	problems = append(problems, validateScope(config)...)
+	// The directory of an --output template is created once expanded
+	outputPath := config.OutputFile
+	if tmpl, err := agent.ParseOutputTemplate(outputPath); err != nil || tmpl != nil {
+		check(err)
+		outputPath = ""
+	}
+	for _, output := range []struct{ name, path string }{
+		{"--output", outputPath},
+		{"--inventory-output", config.InventoryOutput},
+		{"--anonymize-map", config.AnonymizeMap},
+	} {
+		check(validateOutputPath(output.name, output.path))
+	}
+
+	return joinProblems(problems)
+}
+
+// problemsError lists every problem found by validate
+type problemsError []error
+
+func (e problemsError) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("%d problems with the settings:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+func (e problemsError) Unwrap() []error {
+	return e
+}
+
+// joinProblems returns nil without problems, a single problem as is, and
+// several as one error listing them
+func joinProblems(problems []error) error {
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return problems[0]
+	}
+	return problemsError(problems)
+}
+
+// validateScope checks that the regions and subscriptions to scan are
+// shaped like the names and IDs of the provider, so a typo fails before
+// connecting rather than silently scanning nothing
+func validateScope(config *agent.Config) []error {
+	var problems []error
+	if config.Region != "" && !aws.ValidRegion(config.Region) {
+		problems = append(problems, fmt.Errorf("invalid --region %q: want an AWS region such as us-east-1", config.Region))
+	}
+	validRegion := func(string) bool { return true }
+	switch strings.ToLower(config.Provider) {
+	case "aws":
+		validRegion = aws.ValidRegion
+	case "oci":
+		validRegion = oci.ValidRegion
+	}
+	for _, region := range config.Regions {
+		if !validRegion(region) {
+			problems = append(problems, fmt.Errorf("invalid region %q in --regions: want a region name such as us-east-1", region))
+		}
+	}
+	for _, id := range config.Subscriptions {
+		if !azure.ValidSubscriptionID(id) {
+			problems = append(problems, fmt.Errorf("invalid subscription ID %q in --subscriptions: want a GUID", id))
+		}
+	}
+	if !config.AccountFilter.Empty() {
+		if provider := strings.ToLower(config.Provider); provider != "aws" && provider != "azure" {
+			problems = append(problems, fmt.Errorf("--account-filter and --account-tag apply to --provider aws and azure only"))
+		}
+		for _, pattern := range config.AccountFilter.Names {
+			if err := models.ValidateAccountPattern(pattern); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+	return problems
 }
 
-// promptForProvider prompts the user to select a provider
-func (c *CLI) promptForProvider() (string, error) {
-	fmt.Println("=================================")
-	fmt.Println("Secrails Sizing Agent")
-	fmt.Println("=================================")
-	fmt.Println("\nNo provider specified. Please select:")
-	fmt.Println("1. AWS")
-	fmt.Println("2. Azure")
-	fmt.Print("\nEnter your choice (1/2) or type 'aws'/'azure': ")
+// validatePaging checks --page-size against the largest page of the
+// provider's API and that --max-pages is not negative
+func validatePaging(config *agent.Config) []error {
+	var problems []error
+	if config.MaxPages < 0 {
+		problems = append(problems, fmt.Errorf("--max-pages must not be negative"))
+	}
+	if config.PageSize == 0 && config.MaxPages == 0 {
+		return problems
+	}
 
-	input, err := c.reader.ReadString('\n')
+	maxPageSize := 0
+	switch strings.ToLower(config.Provider) {
+	case "aws":
+		maxPageSize = aws.MaxPageSize
+	case "azure":
+		maxPageSize = azure.MaxPageSize
+	default:
+		return append(problems, fmt.Errorf("--page-size and --max-pages apply to --provider aws and azure only"))
+	}
+	if config.PageSize < 0 || config.PageSize > maxPageSize {
+		problems = append(problems, fmt.Errorf("--page-size must be between 1 and %d for --provider %s", maxPageSize, config.Provider))
+	}
+	return problems
+}
+
+// validateOutputPath checks that the directory of an output file exists and
+// is writable, by creating and removing a temporary file in it, so a bad
+// path fails before the scan rather than after it
+func validateOutputPath(name, path string) error {
+	if path == "" {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
 	if err != nil {
-		return "", fmt.Errorf("error reading input: %w", err)
+		return fmt.Errorf("invalid %s %q: directory %s does not exist", name, path, dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid %s %q: %s is not a directory", name, path, dir)
 	}
+	probe, err := os.CreateTemp(dir, ".sizing-agent-*")
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: directory %s is not writable", name, path, dir)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return nil
+}
 
-	input = strings.TrimSpace(strings.ToLower(input))
+// validateIdentity checks --include-identity and reads the Okta settings
+// from the environment
+func validateIdentity(config *agent.Config) error {
+	if config.OktaOrgURL == "" {
+		config.OktaOrgURL = os.Getenv(identity.OktaOrgURLEnv)
+	}
+	config.OktaAPIToken = os.Getenv(identity.OktaTokenEnv)
 
-	switch input {
-	case "1", "aws":
-		return "aws", nil
-	case "2", "azure":
-		return "azure", nil
+	switch config.IncludeIdentity {
+	case "":
+	case identity.ProviderEntra:
+		if config.Provider != "azure" {
+			return fmt.Errorf("--include-identity entra requires --provider azure")
+		}
+	case identity.ProviderOkta:
+		if config.OktaOrgURL == "" || config.OktaAPIToken == "" {
+			return fmt.Errorf("--include-identity okta requires --okta-org-url (or %s) and %s", identity.OktaOrgURLEnv, identity.OktaTokenEnv)
+		}
+		if _, err := identity.ParseOktaOrgURL(config.OktaOrgURL); err != nil {
+			return err
+		}
 	default:
-		return "", fmt.Errorf("invalid choice '%s'", input)
+		return fmt.Errorf("unsupported --include-identity %q (supported: %s)", config.IncludeIdentity, strings.Join(identity.Providers, ", "))
 	}
+	return nil
+}
+
+// validateTenants checks the azure_tenants of the config file and the flags
+// that go with them
+func validateTenants(config *agent.Config) error {
+	if len(config.AzureTenants) == 0 {
+		if config.SplitByTenant {
+			return fmt.Errorf("--split-by-tenant requires azure_tenants in the config file")
+		}
+		return nil
+	}
+
+	if config.Provider != "azure" {
+		return fmt.Errorf("azure_tenants applies to --provider azure only")
+	}
+	if config.TenantID != "" {
+		return fmt.Errorf("--tenant-id cannot be used with azure_tenants; set each tenant's tenant_id")
+	}
+	if config.DryRun || config.AccountsOnly {
+		return fmt.Errorf("azure_tenants cannot be used with --dry-run or --accounts-only")
+	}
+	if config.TenantConcurrency < 1 {
+		return fmt.Errorf("--tenant-concurrency must be at least 1")
+	}
+	if config.SplitByTenant && config.OutputFile == "" {
+		return fmt.Errorf("--split-by-tenant requires --output")
+	}
+
+	seen := make(map[string]bool)
+	for i, tenant := range config.AzureTenants {
+		if tenant.TenantID == "" {
+			return fmt.Errorf("azure_tenants[%d]: tenant_id is required", i)
+		}
+		if seen[strings.ToLower(tenant.TenantID)] {
+			return fmt.Errorf("azure_tenants: tenant %s is listed twice", tenant.TenantID)
+		}
+		seen[strings.ToLower(tenant.TenantID)] = true
+		for _, id := range tenant.Subscriptions {
+			if !azure.ValidSubscriptionID(id) {
+				return fmt.Errorf("azure_tenants[%d]: invalid subscription ID %q: want a GUID", i, id)
+			}
+		}
+		if tenant.AzureAuth != "" && !azure.ValidAuthMethod(tenant.AzureAuth) {
+			return fmt.Errorf("azure_tenants[%d]: unsupported azure_auth %q (supported: %s)",
+				i, tenant.AzureAuth, strings.Join(azure.AuthMethods, ", "))
+		}
+	}
+	return nil
+}
+
+// validateEndpoints checks the endpoint URLs and that the endpoint flags
+// given match the provider
+func validateEndpoints(config *agent.Config, setFlags map[string]bool) error {
+	urls := []struct{ name, value string }{
+		{"--aws-endpoint-url", config.AWSEndpointURL},
+		{"--azure-endpoint-url", config.AzureEndpointURL},
+		{"--azure-authority-host", config.AzureAuthorityHost},
+	}
+	for service, value := range config.AWSEndpoints {
+		if !aws.ValidEndpointService(service) {
+			return fmt.Errorf("unknown service %q in aws_endpoints (supported: %s)",
+				service, strings.Join(aws.EndpointServices, ", "))
+		}
+		urls = append(urls, struct{ name, value string }{"aws_endpoints." + service, value})
+	}
+	for _, u := range urls {
+		if err := validateURL(u.name, u.value); err != nil {
+			return err
+		}
+	}
+
+	provider := strings.ToLower(config.Provider)
+	if setFlags["aws-endpoint-url"] && provider != "aws" {
+		return fmt.Errorf("--aws-endpoint-url requires --provider aws")
+	}
+	if (setFlags["azure-endpoint-url"] || setFlags["azure-authority-host"]) && provider != "azure" {
+		return fmt.Errorf("--azure-endpoint-url and --azure-authority-host require --provider azure")
+	}
+	return nil
+}
+
+// validateURL checks that a URL setting, if set, is an absolute http or
+// https URL
+func validateURL(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s %q: must be an http or https URL", name, value)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// tagFlag collects repeated --tag key=value flags
+type tagFlag []models.TagFilter
+
+func (f *tagFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	parts := make([]string, len(*f))
+	for i, filter := range *f {
+		parts[i] = filter.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *tagFlag) Set(value string) error {
+	filter, err := models.ParseTagFilter(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, filter)
+	return nil
+}
+
+// patternFlag collects repeated --account-filter patterns
+type patternFlag []string
+
+func (f *patternFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *patternFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 // printDebugInfo prints configuration in verbose mode
+// readMFACode returns a token provider that asks for the current code of the
+// MFA device on stderr
+func (c *CLI) readMFACode(serial string) func() (string, error) {
+	return func() (string, error) {
+		fmt.Fprintf(os.Stderr, "MFA code for %s: ", serial)
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("error reading MFA code: %w", err)
+		}
+		return strings.TrimSpace(line), nil
+	}
+}
+
 func (c *CLI) printDebugInfo(config *agent.Config) {
-	fmt.Println("=================================")
-	fmt.Println("Secrails Sizing Agent - Debug")
-	fmt.Println("=================================")
-	fmt.Printf("Provider: %s\n", config.Provider)
-	fmt.Printf("Format: %s\n", config.OutputFormat)
-	fmt.Printf("Output file: %s\n", config.OutputFile)
-	fmt.Printf("Verbose: %v\n", config.Verbose)
-	fmt.Println()
+	fmt.Fprintln(os.Stderr, "=================================")
+	fmt.Fprintln(os.Stderr, "Secrails Sizing Agent - Debug")
+	fmt.Fprintln(os.Stderr, "=================================")
+	fmt.Fprintf(os.Stderr, "Provider: %s\n", config.Provider)
+	if config.Profile != "" {
+		fmt.Fprintf(os.Stderr, "Profile: %s\n", config.Profile)
+	}
+	if config.TenantID != "" {
+		fmt.Fprintf(os.Stderr, "Tenant ID: %s\n", config.TenantID)
+	}
+	if config.AWSEndpointURL != "" {
+		fmt.Fprintf(os.Stderr, "AWS endpoint URL: %s\n", config.AWSEndpointURL)
+	}
+	if config.AzureEndpointURL != "" {
+		fmt.Fprintf(os.Stderr, "Azure endpoint URL: %s\n", config.AzureEndpointURL)
+	}
+	fmt.Fprintf(os.Stderr, "Format: %s\n", config.OutputFormat)
+	fmt.Fprintf(os.Stderr, "Output file: %s\n", config.OutputFile)
+	fmt.Fprintf(os.Stderr, "Verbose: %v\n", config.Verbose)
+	fmt.Fprintf(os.Stderr, "Resource definitions: %s\n", config.ResourceDefinitions)
+	fmt.Fprintf(os.Stderr, "Inventory: %v\n", config.Inventory)
+	fmt.Fprintf(os.Stderr, "Anonymize: %v\n", config.Anonymize)
+	fmt.Fprintln(os.Stderr)
 }
@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	"github.com/secrails/secrails-sizing-agent/internal/cache"
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/azure"
+	"github.com/secrails/secrails-sizing-agent/internal/update"
+)
+
+// validConfig returns the settings of a plain AWS scan, which validate
+// accepts
+func validConfig() *agent.Config {
+	return &agent.Config{
+		Provider:       "aws",
+		OutputFormat:   "table",
+		AzureAuth:      azure.AuthDefault,
+		CacheTTL:       cache.DefaultTTL,
+		RetryFailed:    1,
+		DebugDumpMaxMB: debugdump.DefaultMaxBytes >> 20,
+	}
+}
+
+func TestValidateAcceptsAPlainScan(t *testing.T) {
+	config := validConfig()
+	config.Regions = []string{"us-east-1", "us-gov-west-1"}
+	config.OutputFile = filepath.Join(t.TempDir(), "sizing.json")
+
+	if err := (&CLI{}).validate(config, map[string]bool{}); err != nil {
+		t.Errorf("validate() = %v, want nil", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(config.OutputFile)); len(entries) != 0 {
+		t.Errorf("output directory holds %d files, want the probe removed", len(entries))
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	config := validConfig()
+	config.OutputFormat = "xml"
+	config.OutputFile = filepath.Join(t.TempDir(), "missing", "sizing.json")
+	config.Regions = []string{"us-east-1", "useast1"}
+	config.Quiet, config.Verbose = true, true
+
+	err := (&CLI{}).validate(config, map[string]bool{})
+	var problems problemsError
+	if !errors.As(err, &problems) || len(problems) != 4 {
+		t.Fatalf("validate() = %v, want 4 problems", err)
+	}
+	for _, want := range []string{`unsupported --format "xml"`, "mutually exclusive", `region "useast1"`, "does not exist"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("validate() = %v, want a problem mentioning %s", err, want)
+		}
+	}
+}
+
+func TestValidateScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   agent.Config
+		problems int
+	}{
+		{"aws regions", agent.Config{Provider: "aws", Regions: []string{"eu-west-1", "cn-north-1"}}, 0},
+		{"aws typo", agent.Config{Provider: "aws", Regions: []string{"eu-west1", "EU-WEST-1"}}, 2},
+		{"discovery region", agent.Config{Provider: "aws", Region: "us-east"}, 1},
+		{"oci regions", agent.Config{Provider: "oci", Regions: []string{"us-ashburn-1", "US-PHOENIX-1"}}, 0},
+		{"oci typo", agent.Config{Provider: "oci", Regions: []string{"ashburn"}}, 1},
+		{"subscriptions", agent.Config{Provider: "azure", Subscriptions: []string{"8F2B6C1E-4D3A-4B5C-9E7F-0A1B2C3D4E5F"}}, 0},
+		{"subscription typo", agent.Config{Provider: "azure", Subscriptions: []string{"8f2b6c1e-4d3a-4b5c-9e7f", "prod"}}, 2},
+		{"account filter", agent.Config{Provider: "aws", AccountFilter: models.AccountFilter{Names: []string{"prod-*", "shared-[ab]"}}}, 0},
+		{"bad account filter", agent.Config{Provider: "azure", AccountFilter: models.AccountFilter{Names: []string{"prod-[", ""}}}, 2},
+		{"account tag on k8s", agent.Config{Provider: "k8s", AccountFilter: models.AccountFilter{Tags: []models.TagFilter{{Key: "env", Value: "prod"}}}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if problems := validateScope(&tt.config); len(problems) != tt.problems {
+				t.Errorf("validateScope() = %v, want %d problems", problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestValidateOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, valid := range map[string]bool{
+		"":                                 true,
+		filepath.Join(dir, "sizing.json"):  true,
+		filepath.Join(dir, "no", "x.json"): false,
+		filepath.Join(file, "sizing.json"): false,
+	} {
+		if err := validateOutputPath("--output", path); (err == nil) != valid {
+			t.Errorf("validateOutputPath(%q) = %v, want valid %v", path, err, valid)
+		}
+	}
+}
+
+func TestValidateOutputTemplate(t *testing.T) {
+	dir := t.TempDir()
+	for path, valid := range map[string]bool{
+		filepath.Join(dir, "new", "{{.Provider}}-%d.json"): true,
+		filepath.Join(dir, "{{.Account}}.json"):            false,
+		filepath.Join(dir, "{{.Provider}.json"):            false,
+	} {
+		config := validConfig()
+		config.OutputFile = path
+		if err := (&CLI{}).validate(config, map[string]bool{}); (err == nil) != valid {
+			t.Errorf("validate() with --output %q = %v, want valid %v", path, err, valid)
+		}
+	}
+}
+
+func TestValidatePaging(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   agent.Config
+		problems int
+	}{
+		{"defaults", agent.Config{Provider: "k8s"}, 0},
+		{"aws", agent.Config{Provider: "aws", PageSize: 100, MaxPages: 50}, 0},
+		{"aws page too big", agent.Config{Provider: "aws", PageSize: 500}, 1},
+		{"azure", agent.Config{Provider: "azure", PageSize: 1000}, 0},
+		{"azure page too big", agent.Config{Provider: "azure", PageSize: 1001}, 1},
+		{"negative", agent.Config{Provider: "aws", PageSize: -1, MaxPages: -1}, 2},
+		{"other provider", agent.Config{Provider: "oci", MaxPages: 5}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if problems := validatePaging(&tt.config); len(problems) != tt.problems {
+				t.Errorf("validatePaging() = %v, want %d problems", problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestGetConfigProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		interactive bool
+		// input answers the wizard
+		input   string
+		want    string
+		wantErr string
+	}{
+		{name: "positional", args: []string{"azure"}, want: "azure"},
+		{name: "positional alias", args: []string{"Amazon", "--format", "json"}, want: "aws"},
+		{name: "positional after flags", args: []string{"--format", "json", "az"}, want: "azure"},
+		{name: "flag alias", args: []string{"--provider", "kubernetes"}, want: "k8s"},
+		{name: "flag wins", args: []string{"aws", "--provider", "oci"}, want: "oci"},
+		{name: "positional skips the wizard", args: []string{"k8s"}, interactive: true, want: "k8s"},
+		{name: "wizard", interactive: true, input: "kube\n\n\nn\n", want: "k8s"},
+		{name: "none", wantErr: "a provider is required"},
+		{name: "extra arguments", args: []string{"aws", "azure"}, wantErr: "unexpected arguments: azure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			fs := flag.NewFlagSet("sizing-agent", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			c := &CLI{reader: bufio.NewReader(strings.NewReader(tt.input)), interactive: tt.interactive}
+
+			config, err := c.getConfig(fs, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("getConfig(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getConfig(%q) error = %v", tt.args, err)
+			}
+			if config.Provider != tt.want {
+				t.Errorf("getConfig(%q) provider = %q, want %q", tt.args, config.Provider, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetConfigUpdateCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		airGapped string
+		want      bool
+	}{
+		{name: "default", args: []string{"aws"}, want: true},
+		{name: "flag", args: []string{"aws", "--no-update-check"}},
+		{name: "air-gapped", args: []string{"aws"}, airGapped: "true"},
+		{name: "air-gapped off", args: []string{"aws"}, airGapped: "0", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv(update.AirGappedEnv, tt.airGapped)
+			fs := flag.NewFlagSet("sizing-agent", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+
+			config, err := (&CLI{}).getConfig(fs, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.UpdateCheck != tt.want {
+				t.Errorf("getConfig(%q) UpdateCheck = %v, want %v", tt.args, config.UpdateCheck, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetConfigOffline(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	fs := flag.NewFlagSet("sizing-agent", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config, err := (&CLI{}).getConfig(fs, []string{"aws", "--offline"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Offline || config.UpdateCheck {
+		t.Errorf("--offline gave Offline = %v, UpdateCheck = %v", config.Offline, config.UpdateCheck)
+	}
+
+	fs = flag.NewFlagSet("sizing-agent", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	_, err = (&CLI{}).getConfig(fs, []string{"aws", "--offline", "--metrics-push-url", "https://push.example.com"})
+	if err == nil || !strings.Contains(err.Error(), "--offline cannot be used with --metrics-push-url") {
+		t.Errorf("getConfig(--offline --metrics-push-url) error = %v", err)
+	}
+}
+
+func TestGetConfigThresholds(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	fs := flag.NewFlagSet("sizing-agent", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	config, err := (&CLI{}).getConfig(fs, []string{"aws", "--max-resources", "500", "--max-accounts", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxResources != 500 || config.MaxAccounts != 3 {
+		t.Errorf("MaxResources = %d, MaxAccounts = %d, want 500 and 3", config.MaxResources, config.MaxAccounts)
+	}
+
+	fs = flag.NewFlagSet("sizing-agent", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	_, err = (&CLI{}).getConfig(fs, []string{"aws", "--max-resources", "-1"})
+	if err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Errorf("getConfig(--max-resources -1) error = %v", err)
+	}
+}
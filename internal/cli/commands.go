@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+)
+
+// Commands that main runs itself, as they scan or stay resident
+const (
+	CommandScan     = "scan"
+	CommandAccounts = "accounts"
+	CommandServe    = "serve"
+)
+
+// LegacyNotice is printed when the scan flags are given without a command
+const LegacyNotice = "Warning: flags without a command are deprecated and will stop working in a future release; " +
+	"run \"sizing-agent scan\" with the same flags instead"
+
+// command is a subcommand, listed by help with its summary
+type command struct {
+	name    string
+	summary string
+}
+
+// commands are the subcommands in the order help lists them; the first
+// is the default
+var commands = []command{
+	{name: CommandScan, summary: "Count the resources of a cloud provider (the default)"},
+	{name: CommandAccounts, summary: "List the accounts/subscriptions the credentials can see, without counting"},
+	{name: "validate", summary: "Check a JSON result file against the result schema"},
+	{name: "list-types", summary: "List the resource types counted per provider"},
+	{name: "diff", summary: "Compare the counts of two JSON result files"},
+	{name: "trend", summary: "Show how the counts of a history file grew"},
+	{name: "decrypt", summary: "Restore an output file written with --compress or --encrypt-key"},
+	{name: CommandServe, summary: "Serve the scan API"},
+	{name: "completion", summary: "Print the shell completion script of bash, zsh, fish or powershell"},
+	{name: "version", summary: "Print the version"},
+	{name: "help", summary: "Describe the commands, or the flags of one"},
+}
+
+// commandAliases are the former names of commands, still accepted
+var commandAliases = map[string]string{
+	"validate-result": "validate",
+}
+
+// globalFlags are the flags every command accepts, before or after its
+// name, and whether they take a value
+var globalFlags = map[string]bool{
+	"config":    true,
+	"log-level": true,
+	"verbose":   false,
+}
+
+// commandNames returns the names of the commands, in help order
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return names
+}
+
+// Route splits args, the arguments after the binary, into the command to
+// run and its arguments. Global flags given before the command are passed
+// on to it. Without a command the arguments are those of a scan; legacy
+// reports that they start with flags, the style from before commands,
+// which still works but is deprecated.
+func Route(args []string) (name string, commandArgs []string, legacy bool) {
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		flagName, _, inline := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		takesValue, global := globalFlags[flagName]
+		if !global {
+			break
+		}
+		i++
+		if takesValue && !inline {
+			i++
+		}
+	}
+	i = min(i, len(args))
+
+	if i < len(args) {
+		word := args[i]
+		if alias, ok := commandAliases[word]; ok {
+			word = alias
+		}
+		if slices.Contains(commandNames(), word) || word == CompleteCommand {
+			return word, slices.Concat(args[:i], args[i+1:]), false
+		}
+		switch word {
+		case "-h", "-help", "--help":
+			return "help", nil, false
+		}
+	}
+	return CommandScan, args, len(args) > 0 && strings.HasPrefix(args[0], "-")
+}
+
+// Offline returns the function running the command name when it neither
+// scans nor connects, or nil when main runs it
+func Offline(name string) func(args []string) error {
+	switch name {
+	case "validate":
+		return RunValidateResult
+	case "list-types":
+		return RunListTypes
+	case "diff":
+		return RunDiff
+	case "trend":
+		return RunTrend
+	case "decrypt":
+		return RunDecrypt
+	case "completion":
+		return RunCompletion
+	case "version":
+		return RunVersion
+	case "help":
+		return RunHelp
+	case CompleteCommand:
+		return RunComplete
+	}
+	return nil
+}
+
+// globalOptions holds the global flags of the commands that do not scan
+type globalOptions struct {
+	configPath string
+	logLevel   string
+	verbose    bool
+}
+
+// defineGlobalFlags defines the global flags on fs for a command that does
+// not scan; --config only applies to scans and is accepted for consistency
+func defineGlobalFlags(fs *flag.FlagSet) *globalOptions {
+	options := &globalOptions{}
+	fs.StringVar(&options.configPath, "config", "", "Config file of scans; not used by this command")
+	fs.StringVar(&options.logLevel, "log-level", "", "Log level (debug, info, warn, error) (default: info)")
+	fs.BoolVar(&options.verbose, "verbose", false, "Enable verbose output")
+	return options
+}
+
+// apply sets the log level, if given
+func (o *globalOptions) apply() error {
+	if o.logLevel == "" {
+		return nil
+	}
+	if !logging.ValidLevel(o.logLevel) {
+		return fmt.Errorf("unsupported --log-level %q (supported: %s)", o.logLevel, strings.Join(logging.Levels, ", "))
+	}
+	return logging.InitLogger(o.logLevel)
+}
+
+// RunHelp implements the help command: it lists the commands, or the flags
+// of the command named in args
+func RunHelp(args []string) error {
+	if len(args) > 0 {
+		name := args[0]
+		if alias, ok := commandAliases[name]; ok {
+			name = alias
+		}
+		flags := commandFlags(name)
+		if flags == nil {
+			return fmt.Errorf("unknown command %q (commands: %s)", args[0], strings.Join(commandNames(), ", "))
+		}
+		fmt.Printf("Usage: sizing-agent %s [flags]\n\n", name)
+		flags.SetOutput(os.Stdout)
+		flags.PrintDefaults()
+		return nil
+	}
+	printUsage(os.Stdout)
+	return nil
+}
+
+// printUsage writes the commands and global flags
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: sizing-agent [global flags] <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nGlobal flags, accepted before or after the command:")
+	fmt.Fprintln(w, "  --config string      Config file with default settings of scans")
+	fmt.Fprintln(w, "  --log-level string   Log level (debug, info, warn, error) (default: info)")
+	fmt.Fprintln(w, "  --verbose            Enable verbose output")
+	fmt.Fprintln(w, "\nRun \"sizing-agent help <command>\" for the flags of a command.")
+}
+
+// RunVersion implements the version command
+func RunVersion(args []string) error {
+	flags := flag.NewFlagSet("version", flag.ContinueOnError)
+	globals := defineGlobalFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := globals.apply(); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s\n", version.Product, version.Get())
+	if globals.verbose {
+		fmt.Printf("%s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantName   string
+		wantArgs   []string
+		wantLegacy bool
+	}{
+		{"no arguments", nil, CommandScan, nil, false},
+		{"scan", []string{"scan", "aws", "--format", "json"}, CommandScan, []string{"aws", "--format", "json"}, false},
+		{"provider without command", []string{"azure", "--format", "json"}, CommandScan, []string{"azure", "--format", "json"}, false},
+		{"legacy flags", []string{"--provider", "aws", "--quiet"}, CommandScan, []string{"--provider", "aws", "--quiet"}, true},
+		{"command", []string{"list-types", "--provider", "aws"}, "list-types", []string{"--provider", "aws"}, false},
+		{"global flags before the command", []string{"--log-level", "debug", "--verbose", "accounts", "aws"},
+			CommandAccounts, []string{"--log-level", "debug", "--verbose", "aws"}, false},
+		{"global flag with =", []string{"--config=sizing.yaml", "diff", "a.json", "b.json"},
+			"diff", []string{"--config=sizing.yaml", "a.json", "b.json"}, false},
+		{"global flags only", []string{"--verbose", "--provider", "aws"}, CommandScan, []string{"--verbose", "--provider", "aws"}, true},
+		{"former name", []string{"validate-result", "result.json"}, "validate", []string{"result.json"}, false},
+		{"help flag", []string{"--help"}, "help", nil, false},
+		{"help", []string{"help", "scan"}, "help", []string{"scan"}, false},
+		{"completion", []string{CompleteCommand, "tr"}, CompleteCommand, []string{"tr"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args, legacy := Route(tt.args)
+			if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) || legacy != tt.wantLegacy {
+				t.Errorf("Route(%q) = %q, %q, %v, want %q, %q, %v",
+					tt.args, name, args, legacy, tt.wantName, tt.wantArgs, tt.wantLegacy)
+			}
+		})
+	}
+}
+
+func TestEveryCommandHasFlags(t *testing.T) {
+	for _, name := range commandNames() {
+		flags := commandFlags(name)
+		if flags == nil {
+			t.Errorf("commandFlags(%q) = nil", name)
+			continue
+		}
+		for _, global := range []string{"config", "log-level", "verbose"} {
+			if flags.Lookup(global) == nil {
+				t.Errorf("%s does not accept the global flag --%s", name, global)
+			}
+		}
+	}
+}
+
+func TestGetConfigAccounts(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	config, err := New().GetConfig(CommandAccounts, []string{"aws", "--log-level", "debug"})
+	if err != nil {
+		t.Fatalf("GetConfig() error = %v", err)
+	}
+	if !config.AccountsOnly || config.Provider != "aws" || config.LogLevel != "debug" {
+		t.Errorf("GetConfig() = accounts only %v, provider %q, log level %q, want true, aws, debug",
+			config.AccountsOnly, config.Provider, config.LogLevel)
+	}
+	if _, err := New().GetConfig(CommandScan, []string{"aws", "--log-level", "loud"}); err == nil {
+		t.Error("GetConfig() accepted --log-level loud")
+	}
+}
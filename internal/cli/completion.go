@@ -0,0 +1,320 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	"github.com/secrails/secrails-sizing-agent/internal/identity"
+	"github.com/secrails/secrails-sizing-agent/internal/inventory"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/azure"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/oci"
+	"github.com/secrails/secrails-sizing-agent/internal/report"
+	"github.com/secrails/secrails-sizing-agent/internal/server"
+)
+
+// CompleteCommand is the hidden subcommand the completion scripts call with
+// the words typed so far, the one being completed last; it prints the
+// candidates one per line
+const CompleteCommand = "__complete"
+
+// Shells lists the shells the completion subcommand writes scripts for
+var Shells = []string{"bash", "zsh", "fish", "powershell"}
+
+// RunCompletion implements the completion subcommand: it prints the
+// completion script of a shell
+func RunCompletion(args []string) error {
+	flags := flag.NewFlagSet("completion", flag.ContinueOnError)
+	name := flags.String("name", "sizing-agent", "Name of the installed binary to complete")
+	globals := defineGlobalFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: sizing-agent completion [--name <binary>] <%s>\n", strings.Join(Shells, "|"))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := globals.apply(); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("completion takes exactly one shell (%s)", strings.Join(Shells, ", "))
+	}
+	return writeCompletionScript(os.Stdout, flags.Arg(0), *name)
+}
+
+// RunComplete implements CompleteCommand
+func RunComplete(args []string) error {
+	for _, candidate := range complete(args) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// writeCompletionScript writes the script of shell completing the binary
+// name. The scripts hand the words typed to CompleteCommand, falling back to
+// file names where it offers nothing.
+func writeCompletionScript(w io.Writer, shell, name string) error {
+	function := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	var script string
+	switch shell {
+	case "bash":
+		script = fmt.Sprintf(bashCompletion, function, name, CompleteCommand)
+	case "zsh":
+		script = fmt.Sprintf(zshCompletion, name, function, CompleteCommand)
+	case "fish":
+		script = fmt.Sprintf(fishCompletion, function, CompleteCommand, name)
+	case "powershell":
+		script = fmt.Sprintf(powershellCompletion, name, CompleteCommand)
+	default:
+		return fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(Shells, ", "))
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// bashCompletion takes the function name, the binary name and
+// CompleteCommand. Bash splits words at = and :, so the line is split again
+// and the part of the word before the last break is cut off the candidates.
+const bashCompletion = `# bash completion for %[2]s
+%[1]s() {
+    local line=${COMP_LINE:0:COMP_POINT}
+    local -a words
+    read -ra words <<< "$line"
+    [[ $line == *[[:space:]] ]] && words+=("")
+    local current=${words[${#words[@]}-1]}
+    local cut=$(( ${#current} - ${#COMP_WORDS[COMP_CWORD]} ))
+    local IFS=$'\n'
+    local -a candidates
+    candidates=($("${words[0]}" %[3]s "${words[@]:1}" 2>/dev/null))
+    if (( ${#candidates[@]} == 0 )); then
+        COMPREPLY=($(compgen -f -- "${COMP_WORDS[COMP_CWORD]}"))
+        return
+    fi
+    COMPREPLY=()
+    local candidate
+    for candidate in "${candidates[@]}"; do
+        COMPREPLY+=("${candidate:cut}")
+    done
+}
+complete -o filenames %[1]s %[2]s
+`
+
+// zshCompletion takes the binary name, the function name and
+// CompleteCommand
+const zshCompletion = `#compdef %[1]s
+%[2]s() {
+    local -a candidates
+    candidates=("${(@f)$(${words[1]} %[3]s "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ -z ${candidates[1]} ]]; then
+        _files
+        return
+    fi
+    compadd -Q -- "${candidates[@]}"
+}
+compdef %[2]s %[1]s
+`
+
+// fishCompletion takes the function name, CompleteCommand and the binary
+// name
+const fishCompletion = `# fish completion for %[3]s
+function %[1]s
+    set -l words (commandline -opc) (commandline -ct)
+    $words[1] %[2]s $words[2..-1] 2>/dev/null
+end
+complete -c %[3]s -f -a '(%[1]s)'
+`
+
+// powershellCompletion takes the binary name and CompleteCommand. Older
+// PowerShell versions drop empty arguments to native commands, so an empty
+// word is passed as "".
+const powershellCompletion = `# PowerShell completion for %[1]s
+Register-ArgumentCompleter -Native -CommandName '%[1]s' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+        ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '""' }
+    & $words[0] %[2]s @($words | Select-Object -Skip 1) 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
+
+// complete returns the candidates for the last of words, the arguments
+// typed after the binary: subcommands and providers for the first,
+// the flag names of the command for a word starting with -, and the values
+// of the flag before it, or in "--flag=value", otherwise
+func complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	if current == `""` {
+		current = ""
+	}
+	args := words[:len(words)-1]
+
+	command := CommandScan
+	if len(args) > 0 {
+		if alias, ok := commandAliases[args[0]]; ok {
+			args = slices.Concat([]string{alias}, args[1:])
+		}
+		if slices.Contains(commandNames(), args[0]) {
+			command, args = args[0], args[1:]
+		}
+	} else if !strings.HasPrefix(current, "-") {
+		return matching(slices.Concat(commandNames(), providers.ListProviders()), current)
+	}
+	if !strings.HasPrefix(current, "-") {
+		switch {
+		case command == "completion":
+			return matching(Shells, current)
+		case command == "help" && len(args) == 0:
+			return matching(commandNames(), current)
+		case (command == CommandScan || command == CommandAccounts) && len(args) == 0:
+			return matching(providers.ListProviders(), current)
+		}
+	}
+
+	flags := commandFlags(command)
+	if flags == nil {
+		return nil
+	}
+	if name, value, ok := strings.Cut(current, "="); ok && strings.HasPrefix(name, "-") {
+		return prepend(name+"=", flagValues(strings.TrimLeft(name, "-"), value))
+	}
+	if strings.HasPrefix(current, "-") {
+		var names []string
+		flags.VisitAll(func(f *flag.Flag) { names = append(names, "--"+f.Name) })
+		return matching(names, current)
+	}
+	if len(args) > 0 && strings.HasPrefix(args[len(args)-1], "-") && !strings.Contains(args[len(args)-1], "=") {
+		name := strings.TrimLeft(args[len(args)-1], "-")
+		if f := flags.Lookup(name); f != nil && !isBoolFlag(f) {
+			return flagValues(name, current)
+		}
+	}
+	return nil
+}
+
+// commandFlags returns the flag set of a command, or nil for an unknown one
+func commandFlags(command string) *flag.FlagSet {
+	var flags *flag.FlagSet
+	switch command {
+	case CommandScan, CommandAccounts:
+		flags = flag.NewFlagSet(command, flag.ContinueOnError)
+		defineScanFlags(flags, &agent.Config{})
+		return flags
+	case CommandServe:
+		flags = flag.NewFlagSet(command, flag.ContinueOnError)
+		defineServeFlags(flags, &server.Options{})
+		return flags
+	case "validate":
+		flags, _ = newValidateFlags()
+		return flags
+	case "list-types":
+		flags, _ = newListTypesFlags()
+		return flags
+	case "trend":
+		flags, _, _, _ = newTrendFlags()
+	case "decrypt":
+		flags, _, _ = newDecryptFlags()
+	case "completion":
+		flags = flag.NewFlagSet(command, flag.ContinueOnError)
+		flags.String("name", "sizing-agent", "Name of the installed binary to complete")
+	case "diff", "version", "help":
+		flags = flag.NewFlagSet(command, flag.ContinueOnError)
+	default:
+		return nil
+	}
+	defineGlobalFlags(flags)
+	return flags
+}
+
+// flagValues returns the values of the flag name that start with value. For
+// a comma-separated list, the last element is completed.
+func flagValues(name, value string) []string {
+	var values []string
+	list := false
+	switch name {
+	case "provider":
+		values = providers.ListProviders()
+	case "format":
+		values = report.Formats
+	case "inventory-format":
+		values = []string{inventory.FormatNDJSON, inventory.FormatCSV}
+	case "azure-auth":
+		values = azure.AuthMethods
+	case "oci-auth":
+		values = oci.AuthMethods
+	case "include-identity":
+		values = identity.Providers
+	case "billable-types":
+		values, list = resourceTypes(), true
+	}
+	if !list {
+		return matching(values, value)
+	}
+	done, last := "", value
+	if i := strings.LastIndex(value, ","); i >= 0 {
+		done, last = value[:i+1], value[i+1:]
+	}
+	return prepend(done, matching(values, last))
+}
+
+// resourceTypes returns the resource types of the embedded definitions of
+// every provider, sorted
+func resourceTypes() []string {
+	definitions, err := models.DefaultDefinitions()
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var types []string
+	for _, provider := range []string{"aws", "azure", "k8s", "oci"} {
+		for _, definition := range definitions.ForProvider(provider) {
+			resourceType := string(definition.ResourceType())
+			if !seen[resourceType] {
+				seen[resourceType] = true
+				types = append(types, resourceType)
+			}
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// matching returns the candidates starting with typed
+func matching(candidates []string, typed string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, typed) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// prepend returns the candidates with prefix put in front of each
+func prepend(prefix string, candidates []string) []string {
+	prefixed := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		prefixed[i] = prefix + candidate
+	}
+	return prefixed
+}
+
+// isBoolFlag reports whether f takes no value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
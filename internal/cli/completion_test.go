@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"commands and providers", []string{"a"}, []string{"accounts", "aws", "azure"}},
+		{"command", []string{"tr"}, []string{"trend"}},
+		{"provider after scan", []string{"scan", "o"}, []string{"oci"}},
+		{"scan flags", []string{"accounts", "--form"}, []string{"--format"}},
+		{"flag names", []string{"--form"}, []string{"--format"}},
+		{"flag after provider", []string{"azure", "--azure-a"}, []string{"--azure-auth", "--azure-authority-host"}},
+		{"flag value", []string{"--format", "y"}, []string{"yaml"}},
+		{"empty flag value", []string{"--provider", `""`}, []string{"aws", "azure", "k8s", "oci"}},
+		{"flag value after =", []string{"--inventory-format=c"}, []string{"--inventory-format=csv"}},
+		{"list element", []string{"--billable-types", "s3:bucket,ec2:inst"}, []string{"s3:bucket,ec2:instance"}},
+		{"bool flag takes no value", []string{"--verbose", ""}, nil},
+		{"file flag", []string{"--output", "res"}, nil},
+		{"command flags", []string{"trend", "--la"}, []string{"--last"}},
+		{"global flags", []string{"diff", "--"}, []string{"--config", "--log-level", "--verbose"}},
+		{"serve flags", []string{"serve", "--max-c"}, []string{"--max-concurrent-scans"}},
+		{"shells", []string{"completion", "f"}, []string{"fish"}},
+		{"former name", []string{"validate-result", "--v"}, []string{"--verbose"}},
+		{"help", []string{"help", "li"}, []string{"list-types"}},
+		{"no arguments", []string{"version", "x"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := complete(tt.words); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("complete(%q) = %q, want %q", tt.words, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceTypes(t *testing.T) {
+	types := resourceTypes()
+	for _, want := range []string{"ec2:instance", "s3:bucket", "microsoft.compute/virtualmachines"} {
+		if !slices.Contains(types, want) {
+			t.Errorf("resourceTypes() does not contain %q", want)
+		}
+	}
+	if !slices.IsSorted(types) || len(slices.Compact(slices.Clone(types))) != len(types) {
+		t.Error("resourceTypes() is not sorted and unique")
+	}
+}
+
+func TestWriteCompletionScript(t *testing.T) {
+	for _, shell := range Shells {
+		var out bytes.Buffer
+		if err := writeCompletionScript(&out, shell, "cloud-sizer"); err != nil {
+			t.Fatalf("writeCompletionScript(%s) error = %v", shell, err)
+		}
+		if script := out.String(); !strings.Contains(script, "cloud-sizer") || !strings.Contains(script, CompleteCommand) {
+			t.Errorf("%s script does not complete cloud-sizer with %s:\n%s", shell, CompleteCommand, script)
+		}
+	}
+	if err := writeCompletionScript(&bytes.Buffer{}, "tcsh", "sizing-agent"); err == nil {
+		t.Error("writeCompletionScript(tcsh) error = nil, want an error")
+	}
+}
@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/azure"
+)
+
+// configFileName is the file the wizard saves its answers to
+const configFileName = "config.yaml"
+
+// fileConfig is the layout of the config file. Flags given on the command
+// line take precedence over it.
+type fileConfig struct {
+	Provider      string   `yaml:"provider"`
+	Profile       string   `yaml:"profile,omitempty"`
+	Profiles      []string `yaml:"profiles,omitempty"`
+	Regions       []string `yaml:"regions,omitempty"`
+	Subscriptions []string `yaml:"subscriptions,omitempty"`
+	TenantID      string   `yaml:"tenant_id,omitempty"`
+	AzureAuth     string   `yaml:"azure_auth,omitempty"`
+	Kubeconfig    string   `yaml:"kubeconfig,omitempty"`
+	Contexts      []string `yaml:"contexts,omitempty"`
+	OCIAuth       string   `yaml:"oci_auth,omitempty"`
+	OCIConfigFile string   `yaml:"oci_config_file,omitempty"`
+	OCIProfile    string   `yaml:"oci_profile,omitempty"`
+	OktaOrgURL    string   `yaml:"okta_org_url,omitempty"`
+
+	// Azure service principal settings without secrets
+	AzureClientID              string `yaml:"azure_client_id,omitempty"`
+	AzureClientCertificatePath string `yaml:"azure_client_certificate_path,omitempty"`
+	AzureFederatedTokenFile    string `yaml:"azure_federated_token_file,omitempty"`
+
+	// AzureTenants are the tenants of a multi-tenant Azure scan, each with
+	// its own credentials
+	AzureTenants []fileAzureTenant `yaml:"azure_tenants,omitempty"`
+
+	// AWS endpoints, for LocalStack or VPC interface endpoints without
+	// private DNS; the services of AWSEndpoints are aws.EndpointServices
+	AWSEndpointURL string            `yaml:"aws_endpoint_url,omitempty"`
+	AWSEndpoints   map[string]string `yaml:"aws_endpoints,omitempty"`
+
+	Format string `yaml:"format,omitempty"`
+	Output string `yaml:"output,omitempty"`
+}
+
+// fileAzureTenant is a tenant of azure_tenants. The client secret, if any,
+// is read from the environment variable client_secret_env names.
+type fileAzureTenant struct {
+	TenantID              string   `yaml:"tenant_id"`
+	AzureAuth             string   `yaml:"azure_auth,omitempty"`
+	Subscriptions         []string `yaml:"subscriptions,omitempty"`
+	ClientID              string   `yaml:"client_id,omitempty"`
+	ClientCertificatePath string   `yaml:"client_certificate_path,omitempty"`
+	FederatedTokenFile    string   `yaml:"federated_token_file,omitempty"`
+	ClientSecretEnv       string   `yaml:"client_secret_env,omitempty"`
+}
+
+// DefaultConfigPath returns the config file location,
+// $XDG_CONFIG_HOME/secrails-sizing-agent/config.yaml or its platform equivalent
+func DefaultConfigPath() string {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		var err error
+		if base, err = os.UserConfigDir(); err != nil {
+			return ""
+		}
+	}
+	return filepath.Join(base, "secrails-sizing-agent", configFileName)
+}
+
+// loadConfigFile reads a config file. A missing file yields nil unless
+// required, i.e. unless the path was given explicitly.
+func loadConfigFile(path string, required bool) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file fileConfig
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// saveConfigFile writes file to path, creating its directory
+func saveConfigFile(path string, file *fileConfig) error {
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// apply copies the file's settings into config, except those whose flag was
+// set on the command line
+func (f *fileConfig) apply(config *agent.Config, setFlags map[string]bool) {
+	if f.Provider != "" && !setFlags["provider"] {
+		config.Provider = f.Provider
+	}
+	if f.Profile != "" && !setFlags["profile"] && !setFlags["profiles"] {
+		config.Profile = f.Profile
+	}
+	if len(f.Profiles) > 0 && !setFlags["profiles"] && !setFlags["profile"] {
+		config.Profiles = f.Profiles
+	}
+	if len(f.Regions) > 0 && !setFlags["regions"] {
+		config.Regions = f.Regions
+	}
+	if len(f.Subscriptions) > 0 && !setFlags["subscriptions"] {
+		config.Subscriptions = f.Subscriptions
+	}
+	if f.TenantID != "" && !setFlags["tenant-id"] {
+		config.TenantID = f.TenantID
+	}
+	if f.AzureAuth != "" && !setFlags["azure-auth"] {
+		config.AzureAuth = f.AzureAuth
+	}
+	if f.Kubeconfig != "" && !setFlags["kubeconfig"] {
+		config.Kubeconfig = f.Kubeconfig
+	}
+	if len(f.Contexts) > 0 && !setFlags["context"] {
+		config.KubeContexts = f.Contexts
+	}
+	if f.OCIAuth != "" && !setFlags["oci-auth"] {
+		config.OCIAuth = f.OCIAuth
+	}
+	if f.OCIConfigFile != "" && !setFlags["oci-config-file"] {
+		config.OCIConfigFile = f.OCIConfigFile
+	}
+	if f.OCIProfile != "" && !setFlags["oci-profile"] {
+		config.OCIProfile = f.OCIProfile
+	}
+	if f.OktaOrgURL != "" && !setFlags["okta-org-url"] {
+		config.OktaOrgURL = f.OktaOrgURL
+	}
+	// These have no flags
+	config.AzureClientID = f.AzureClientID
+	config.AzureClientCertificatePath = f.AzureClientCertificatePath
+	config.AzureFederatedTokenFile = f.AzureFederatedTokenFile
+	config.AzureTenants = nil
+	for _, tenant := range f.AzureTenants {
+		config.AzureTenants = append(config.AzureTenants, agent.AzureTenant(tenant))
+	}
+
+	if f.AWSEndpointURL != "" && !setFlags["aws-endpoint-url"] {
+		config.AWSEndpointURL = f.AWSEndpointURL
+	}
+	config.AWSEndpoints = f.AWSEndpoints
+
+	if f.Format != "" && !setFlags["format"] {
+		config.OutputFormat = f.Format
+	}
+	if f.Output != "" && !setFlags["output"] {
+		config.OutputFile = f.Output
+	}
+}
+
+// fileConfigFrom captures the settings the wizard asks for, keeping the
+// ones loaded from an earlier file
+func fileConfigFrom(config *agent.Config) *fileConfig {
+	file := &fileConfig{
+		Provider:      config.Provider,
+		Profile:       config.Profile,
+		Profiles:      config.Profiles,
+		Regions:       config.Regions,
+		Subscriptions: config.Subscriptions,
+		TenantID:      config.TenantID,
+		Kubeconfig:    config.Kubeconfig,
+		Contexts:      config.KubeContexts,
+		OCIAuth:       config.OCIAuth,
+		OCIConfigFile: config.OCIConfigFile,
+		OCIProfile:    config.OCIProfile,
+		OktaOrgURL:    config.OktaOrgURL,
+		Format:        config.OutputFormat,
+		Output:        config.OutputFile,
+
+		AzureClientID:              config.AzureClientID,
+		AzureClientCertificatePath: config.AzureClientCertificatePath,
+		AzureFederatedTokenFile:    config.AzureFederatedTokenFile,
+
+		AWSEndpointURL: config.AWSEndpointURL,
+		AWSEndpoints:   config.AWSEndpoints,
+	}
+	for _, tenant := range config.AzureTenants {
+		file.AzureTenants = append(file.AzureTenants, fileAzureTenant(tenant))
+	}
+	if config.AzureAuth != azure.AuthDefault {
+		file.AzureAuth = config.AzureAuth
+	}
+	return file
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/secrails/secrails-sizing-agent/internal/sink"
+)
+
+// RunDecrypt implements the decrypt subcommand: it restores an output file
+// written with --compress or --encrypt-key to the original output
+func RunDecrypt(args []string) error {
+	flags, key, output := newDecryptFlags()
+	globals := defineGlobalFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := globals.apply(); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("decrypt takes exactly one output file")
+	}
+	path := flags.Arg(0)
+
+	passphrase := ""
+	if *key != "" {
+		var err error
+		if passphrase, _, err = sink.ReadKey(*key); err != nil {
+			return err
+		}
+	}
+	in, _, err := sink.Open(path, passphrase)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if *output == "" {
+		if _, err := io.Copy(os.Stdout, in); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if _, err := io.Copy(file, in); err != nil {
+		_ = file.Close()
+		_ = os.Remove(*output)
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "✓ %s restored to: %s\n", path, *output)
+	return nil
+}
+
+// newDecryptFlags returns the flag set of the decrypt subcommand and its
+// values
+func newDecryptFlags() (flags *flag.FlagSet, key, output *string) {
+	flags = flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	key = flags.String("key", "", "Passphrase given to --encrypt-key: the passphrase itself, file:PATH or env:NAME")
+	output = flags.String("output", "", "File to write the original output to (default: stdout)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: sizing-agent decrypt [--key <key file or passphrase>] [--output <file>] <file.gz|file.enc>")
+		flags.PrintDefaults()
+	}
+	return flags, key, output
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/history"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// RunDiff implements the diff command: it compares the counts of two JSON
+// result files of the same provider, the older one first
+func RunDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	globals := defineGlobalFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: sizing-agent diff <old.json> <new.json>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := globals.apply(); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("diff takes exactly two result files")
+	}
+
+	trend, err := diffResults(flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	printTrend(os.Stdout, trend)
+	return nil
+}
+
+// diffResults returns the change between the result files at oldPath and
+// newPath
+func diffResults(oldPath, newPath string) (history.Trend, error) {
+	first, err := readResult(oldPath)
+	if err != nil {
+		return history.Trend{}, err
+	}
+	last, err := readResult(newPath)
+	if err != nil {
+		return history.Trend{}, err
+	}
+	if !strings.EqualFold(first.Provider, last.Provider) {
+		return history.Trend{}, fmt.Errorf("%s counts %s but %s counts %s; diff compares scans of one provider",
+			oldPath, first.Provider, newPath, last.Provider)
+	}
+	return history.Diff(history.FromResult(first), history.FromResult(last)), nil
+}
+
+// readResult reads the JSON result file at path
+func readResult(path string) (*models.SizingResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result file: %w", err)
+	}
+	var result models.SizingResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON result file: %w", path, err)
+	}
+	if result.SchemaVersion == "" {
+		return nil, fmt.Errorf("%s has no schema_version; diff reads results written with --format json, not --legacy-json", path)
+	}
+	return &result, nil
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// writeResult writes a result of provider with counts to a file in dir
+func writeResult(t *testing.T, dir, name, schemaVersion, provider string, counts map[string]int) string {
+	t.Helper()
+	result := models.SizingResult{SchemaVersion: schemaVersion, Provider: provider, Timestamp: time.Now()}
+	for resourceType, count := range counts {
+		result.ResourceCounts = append(result.ResourceCounts, &models.ResourceCount{
+			Provider: provider, Type: models.ResourceType(resourceType), TotalResources: count,
+		})
+		result.TotalResources += count
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiffResults(t *testing.T) {
+	dir := t.TempDir()
+	old := writeResult(t, dir, "old.json", models.SchemaVersion, "aws", map[string]int{"ec2:instance": 10, "s3:bucket": 4})
+	current := writeResult(t, dir, "new.json", models.SchemaVersion, "aws", map[string]int{"ec2:instance": 15, "lambda:function": 2})
+
+	trend, err := diffResults(old, current)
+	if err != nil {
+		t.Fatalf("diffResults() error = %v", err)
+	}
+	if trend.Resources.First != 14 || trend.Resources.Last != 17 {
+		t.Errorf("Resources = %+v, want 14 to 17", trend.Resources)
+	}
+	if len(trend.Types) != 3 || trend.Types[0].Name != "ec2:instance" || trend.Types[0].Delta() != 5 {
+		t.Errorf("Types = %+v, want ec2:instance +5 first of 3", trend.Types)
+	}
+
+	for _, tt := range []struct{ path, want string }{
+		{writeResult(t, dir, "azure.json", models.SchemaVersion, "azure", nil), "one provider"},
+		{writeResult(t, dir, "legacy.json", "", "aws", nil), "no schema_version"},
+	} {
+		if _, err := diffResults(old, tt.path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("diffResults(%s) error = %v, want %q", filepath.Base(tt.path), err, tt.want)
+		}
+	}
+}
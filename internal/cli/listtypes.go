@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+)
+
+// listedType is a resource type as list-types writes it in JSON
+type listedType struct {
+	Provider    string             `json:"provider"`
+	Type        string             `json:"type"`
+	DisplayName string             `json:"display_name"`
+	Category    string             `json:"category"`
+	CountMethod models.CountMethod `json:"count_method"`
+	Billable    bool               `json:"billable"`
+}
+
+// RunListTypes implements the list-types command: it lists the resource
+// types a scan counts per provider, with the overrides of
+// --resource-definitions applied
+func RunListTypes(args []string) error {
+	flags, globals := newListTypesFlags()
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := globals.apply(); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+
+	provider := providers.Normalize(flags.Lookup("provider").Value.String())
+	if provider != "" && !slices.Contains(providers.ListProviders(), provider) {
+		return fmt.Errorf("unsupported provider %q (supported: %s)", provider, strings.Join(providers.ListProviders(), ", "))
+	}
+	format := flags.Lookup("format").Value.String()
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unsupported --format %q (supported: table, json)", format)
+	}
+	definitions, err := models.LoadDefinitions(flags.Lookup("resource-definitions").Value.String())
+	if err != nil {
+		return err
+	}
+	return listTypes(os.Stdout, definitions, provider, format)
+}
+
+// newListTypesFlags returns the flag set of the list-types command
+func newListTypesFlags() (*flag.FlagSet, *globalOptions) {
+	flags := flag.NewFlagSet("list-types", flag.ContinueOnError)
+	flags.String("provider", "", "Only list the types of this provider ("+strings.Join(providers.ListProviders(), ", ")+")")
+	flags.String("format", "table", "Output format (table, json)")
+	flags.String("resource-definitions", "", "YAML file that merges with or replaces the built-in resource definitions")
+	globals := defineGlobalFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: sizing-agent list-types [--provider <provider>] [--format table|json]")
+		flags.PrintDefaults()
+	}
+	return flags, globals
+}
+
+// listTypes writes the types of definitions, of provider or every provider
+// when empty, in format
+func listTypes(w io.Writer, definitions *models.DefinitionSet, provider, format string) error {
+	var types []listedType
+	for _, p := range providers.ListProviders() {
+		if provider != "" && p != provider {
+			continue
+		}
+		for _, definition := range definitions.ForProvider(p) {
+			types = append(types, listedType{
+				Provider:    p,
+				Type:        string(definition.ResourceType()),
+				DisplayName: definition.DisplayName,
+				Category:    definition.Category,
+				CountMethod: definition.CountMethod,
+				Billable:    definition.Billable,
+			})
+		}
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(types)
+	}
+
+	current := ""
+	for _, t := range types {
+		if t.Provider != current {
+			current = t.Provider
+			fmt.Fprintf(w, "\n%s\n", strings.ToUpper(current))
+		}
+		billable := ""
+		if t.Billable {
+			billable = " (billable)"
+		}
+		fmt.Fprintf(w, "  %-55s %-35s %s%s\n", t.Type, t.DisplayName, t.Category, billable)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestListTypes(t *testing.T) {
+	definitions, err := models.DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := listTypes(&out, definitions, "aws", "json"); err != nil {
+		t.Fatalf("listTypes() error = %v", err)
+	}
+	var types []listedType
+	if err := json.Unmarshal(out.Bytes(), &types); err != nil {
+		t.Fatalf("listTypes() wrote invalid JSON: %v", err)
+	}
+	if len(types) != len(definitions.ForProvider("aws")) {
+		t.Errorf("listTypes() listed %d types, want %d", len(types), len(definitions.ForProvider("aws")))
+	}
+	found := false
+	for _, listed := range types {
+		if listed.Provider != "aws" {
+			t.Errorf("listTypes() listed %s type %s for aws", listed.Provider, listed.Type)
+		}
+		found = found || listed.Type == "ec2:instance"
+	}
+	if !found {
+		t.Error("listTypes() did not list ec2:instance")
+	}
+
+	out.Reset()
+	if err := listTypes(&out, definitions, "", "table"); err != nil {
+		t.Fatalf("listTypes() error = %v", err)
+	}
+	for _, want := range []string{"AWS\n", "AZURE\n", "ec2:instance", "microsoft.compute/virtualmachines"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("table does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/cache"
+	"github.com/secrails/secrails-sizing-agent/internal/identity"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/aws"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/counting"
+	"github.com/secrails/secrails-sizing-agent/internal/server"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+)
+
+// tokenEnv is the environment variable holding the scan API token
+const tokenEnv = "SECRAILS_API_TOKEN"
+
+// ParseServe parses the flags of the serve subcommand
+func ParseServe(args []string) (*server.Options, error) {
+	options := &server.Options{}
+	base := &options.Base
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := defineServeFlags(flags, options)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// The credentials and endpoints of the config file apply to the scans
+	// unless overridden by a flag; requests choose the provider, and results
+	// are returned rather than written
+	if *configPath != "" {
+		file, err := loadConfigFile(*configPath, true)
+		if err != nil {
+			return nil, err
+		}
+		setFlags := make(map[string]bool)
+		flags.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+		file.apply(base, setFlags)
+		base.Provider, base.OutputFormat, base.OutputFile = "", "", ""
+	}
+	if base.LogLevel != "" && !logging.ValidLevel(base.LogLevel) {
+		return nil, fmt.Errorf("unsupported --log-level %q (supported: %s)", base.LogLevel, strings.Join(logging.Levels, ", "))
+	}
+
+	base.OktaAPIToken = os.Getenv(identity.OktaTokenEnv)
+	if base.OktaOrgURL != "" {
+		if _, err := identity.ParseOktaOrgURL(base.OktaOrgURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Token == "" {
+		return nil, fmt.Errorf("--token or %s is required", tokenEnv)
+	}
+	if options.MaxConcurrentScans < 1 {
+		return nil, fmt.Errorf("--max-concurrent-scans must be at least 1")
+	}
+	if err := base.ApplyOffline(); err != nil {
+		return nil, err
+	}
+	if base.CacheTTL <= 0 {
+		return nil, fmt.Errorf("--cache-ttl must be positive")
+	}
+	if base.RetryFailed < 0 || base.RetryFailed > counting.MaxRetries {
+		return nil, fmt.Errorf("--retry-failed must be between 0 and %d", counting.MaxRetries)
+	}
+	if base.TypeTimeout < 0 || base.RegionFailureThreshold < 0 {
+		return nil, fmt.Errorf("--type-timeout and --region-failure-threshold must not be negative")
+	}
+	return options, nil
+}
+
+// defineServeFlags defines the flags of the serve subcommand on flags,
+// setting options, and returns the path of --config
+func defineServeFlags(flags *flag.FlagSet, options *server.Options) *string {
+	base := &options.Base
+	configPath := flags.String("config", "", "Config file with default settings of the scans")
+	flags.StringVar(&options.Addr, "listen", ":8080", "Address to serve the scan API on")
+	flags.StringVar(&options.Token, "token", os.Getenv(tokenEnv), "Bearer token required by /scans (default: "+tokenEnv+")")
+	flags.IntVar(&options.MaxConcurrentScans, "max-concurrent-scans", 2, "Scans allowed to run at once; further requests get 429")
+	flags.DurationVar(&options.ShutdownTimeout, "shutdown-timeout", 10*time.Minute, "How long shutdown waits for running scans before cancelling them")
+	flags.BoolVar(&base.Verbose, "verbose", false, "Enable verbose output")
+	flags.StringVar(&base.LogLevel, "log-level", "", "Log level (debug, info, warn, error) (default: info)")
+	flags.BoolVar(&base.NoRedact, "no-redact", false, "Log account IDs, GUIDs, ARNs, names and tags unmasked, for internal debugging")
+	flags.BoolVar(&base.ASCII, "ascii", false, "Write the table and progress without emoji or other non-ASCII symbols")
+	flags.StringVar(&base.ResourceDefinitions, "resource-definitions", "", "YAML file that merges with or replaces the built-in resource definitions")
+	flags.StringVar(&base.Weights, "weights", "", "YAML file overriding the workload-unit weights and tiers")
+	flags.StringVar(&base.Region, "region", "", "AWS region for discovery calls (default: AWS config file, AWS_REGION, then us-east-1)")
+	flags.StringVar(&base.CacheDir, "cache-dir", cache.DefaultDir(), "Directory for the discovery cache")
+	flags.DurationVar(&base.CacheTTL, "cache-ttl", cache.DefaultTTL, "How long cached discovery results are reused")
+	flags.BoolVar(&base.NoCache, "no-cache", false, "Ignore and do not write the account/subscription and region discovery cache")
+	flags.IntVar(&base.RetryFailed, "retry-failed", 1, "Passes retrying the resource types whose count failed, e.g. when throttled (0-3)")
+	flags.DurationVar(&base.TypeTimeout, "type-timeout", counting.DefaultTypeTimeout, "Fail a resource type whose count takes longer than this, retrying it with --retry-failed (0 disables)")
+	flags.IntVar(&base.RegionFailureThreshold, "region-failure-threshold", aws.DefaultRegionFailureThreshold, "Skip an AWS region for the remaining types after this many consecutive failed counts in it (0 disables)")
+	flags.StringVar(&base.OktaOrgURL, "okta-org-url", os.Getenv(identity.OktaOrgURLEnv), "Okta org scans with include_identity okta count (default: "+identity.OktaOrgURLEnv+")")
+	flags.StringVar(&base.OTelEndpoint, "otel-endpoint", "", "Export OpenTelemetry traces to this OTLP/HTTP endpoint (default: OTEL_EXPORTER_OTLP_ENDPOINT)")
+	flags.BoolVar(&base.Offline, "offline", false, "Call no endpoint but the cloud providers': no --otel-endpoint or OTEL_EXPORTER_OTLP_ENDPOINT trace export")
+	return configPath
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/history"
+)
+
+// RunTrend implements the trend subcommand: it reads a history file and
+// prints the growth of each count over the most recent scans
+func RunTrend(args []string) error {
+	flags, path, last, provider := newTrendFlags()
+	globals := defineGlobalFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := globals.apply(); err != nil {
+		return err
+	}
+	if *last < 0 {
+		return fmt.Errorf("--last must not be negative")
+	}
+
+	entries, skipped, err := history.Read(*path)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: skipped %d unreadable lines in %s\n", skipped, *path)
+	}
+
+	var trends []history.Trend
+	for _, trend := range history.Compute(entries, *last) {
+		if *provider == "" || strings.EqualFold(trend.Provider, *provider) {
+			trends = append(trends, trend)
+		}
+	}
+	if len(trends) == 0 {
+		return fmt.Errorf("no scans found in %s", *path)
+	}
+
+	for _, trend := range trends {
+		printTrend(os.Stdout, trend)
+	}
+	return nil
+}
+
+// newTrendFlags returns the flag set of the trend subcommand and its values
+func newTrendFlags() (flags *flag.FlagSet, path *string, last *int, provider *string) {
+	flags = flag.NewFlagSet("trend", flag.ContinueOnError)
+	path = flags.String("history", "history.jsonl", "History file written with --history")
+	last = flags.Int("last", 10, "Number of most recent scans per provider to compare (0 for all)")
+	provider = flags.String("provider", "", "Only show this provider (aws or azure)")
+	return flags, path, last, provider
+}
+
+// printTrend prints one provider's trend as a table
+func printTrend(w io.Writer, trend history.Trend) {
+	fmt.Fprintln(w, "\n=================================")
+	fmt.Fprintf(w, "Provider: %s\n", strings.ToUpper(trend.Provider))
+	fmt.Fprintf(w, "Scans: %d, from %s to %s\n", trend.Scans,
+		trend.From.Format("2006-01-02 15:04"), trend.To.Format("2006-01-02 15:04"))
+	fmt.Fprintln(w, "---------------------------------")
+	fmt.Fprintf(w, "  %-50s %10s %10s %10s %9s\n", "", "First", "Last", "Change", "Growth")
+	printChange(w, trend.Resources)
+	printChange(w, trend.Accounts)
+	fmt.Fprintln(w, "---------------------------------")
+	for _, change := range trend.Types {
+		printChange(w, change)
+	}
+}
+
+func printChange(w io.Writer, change history.Change) {
+	growth := "new"
+	if percent, ok := change.Growth(); ok {
+		growth = fmt.Sprintf("%+.1f%%", percent)
+	} else if change.Last == 0 {
+		growth = "-"
+	}
+	fmt.Fprintf(w, "  %-50s %10d %10d %+10d %9s\n", change.Name, change.First, change.Last, change.Delta(), growth)
+}
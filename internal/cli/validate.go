@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// RunValidateResult implements the validate command, formerly
+// validate-result: it checks a JSON result file against the embedded result
+// schema and lists every missing, unexpected or mistyped field
+func RunValidateResult(args []string) error {
+	flags, globals := newValidateFlags()
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := globals.apply(); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("validate takes exactly one result file")
+	}
+	path := flags.Arg(0)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read result file: %w", err)
+	}
+	violations, err := models.ValidateResult(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(violations) > 0 {
+		for _, violation := range violations {
+			fmt.Printf("✗ %s\n", violation)
+		}
+		return fmt.Errorf("%s does not match result schema version %s: %d problems",
+			path, models.SchemaVersion, len(violations))
+	}
+
+	fmt.Printf("✓ %s matches result schema version %s\n", path, models.SchemaVersion)
+	return nil
+}
+
+// newValidateFlags returns the flag set of the validate command
+func newValidateFlags() (*flag.FlagSet, *globalOptions) {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	globals := defineGlobalFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: sizing-agent validate <file.json>")
+		flags.PrintDefaults()
+	}
+	return flags, globals
+}
@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/aws"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/azure"
+	"github.com/secrails/secrails-sizing-agent/internal/report"
+)
+
+// subscriptionListTimeout bounds the Azure auth check of the wizard
+const subscriptionListTimeout = 30 * time.Second
+
+// maxProviderAttempts is how often an invalid provider is asked again
+// before the wizard gives up
+const maxProviderAttempts = 3
+
+// errInputClosed is returned when stdin ends, e.g. with Ctrl-D, before a
+// question was answered
+var errInputClosed = errors.New("input closed before the wizard finished; " +
+	"run again, or give the provider as the first argument or with --provider")
+
+// wizard asks for the settings of a first run. Every question accepts Enter
+// for its default, and questions whose flag was given are skipped.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// providers are the names offered for the provider question
+	providers []string
+
+	// Discovery used to offer choices; tests replace it
+	awsProfiles        func() ([]string, error)
+	azureSubscriptions func(ctx context.Context) ([]models.AccountCount, error)
+}
+
+func newWizard(in *bufio.Reader, out io.Writer) *wizard {
+	return &wizard{
+		in:                 in,
+		out:                out,
+		providers:          providers.ListProviders(),
+		awsProfiles:        aws.Profiles,
+		azureSubscriptions: azure.ListSubscriptions,
+	}
+}
+
+// run fills config from the answers and offers to save them to savePath;
+// an empty savePath skips the offer
+func (w *wizard) run(ctx context.Context, config *agent.Config, setFlags map[string]bool, savePath string) error {
+	fmt.Fprintln(w.out, "=================================")
+	fmt.Fprintln(w.out, "Secrails Sizing Agent")
+	fmt.Fprintln(w.out, "=================================")
+	fmt.Fprintln(w.out, "\nNo provider specified. Answer a few questions, or press Enter to accept the default.")
+
+	provider, err := w.askProvider()
+	if err != nil {
+		return err
+	}
+	config.Provider = provider
+
+	switch provider {
+	case "aws":
+		if !setFlags["profile"] {
+			if config.Profile, err = w.askProfile(); err != nil {
+				return err
+			}
+		}
+		if !setFlags["regions"] {
+			answer, err := w.ask("Regions to scan, comma-separated", "Enter for all enabled regions")
+			if err != nil {
+				return err
+			}
+			config.Regions = splitList(answer)
+		}
+	case "azure":
+		if !setFlags["subscriptions"] {
+			if config.Subscriptions, err = w.askSubscriptions(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !setFlags["format"] {
+		if config.OutputFormat, err = w.askFormat(config.OutputFormat); err != nil {
+			return err
+		}
+	}
+	if !setFlags["output"] {
+		if config.OutputFile, err = w.ask("Output file", "Enter to print to the terminal"); err != nil {
+			return err
+		}
+	}
+
+	return w.offerSave(config, savePath)
+}
+
+// askProvider offers the registered providers by number, name or alias,
+// with aws as the default when it is registered, and gives up after
+// maxProviderAttempts invalid answers
+func (w *wizard) askProvider() (string, error) {
+	if len(w.providers) == 0 {
+		return "", fmt.Errorf("no providers are registered")
+	}
+	fallback := w.providers[0]
+	fmt.Fprintln(w.out, "\nCloud provider:")
+	for i, name := range w.providers {
+		fmt.Fprintf(w.out, "%d. %s\n", i+1, name)
+		if name == "aws" {
+			fallback = name
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		answer, err := w.ask(fmt.Sprintf("Enter your choice (1-%d) or type the name", len(w.providers)), fallback)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			return fallback, nil
+		}
+		if name, ok := pick(providers.Normalize(answer), w.providers); ok {
+			return name, nil
+		}
+		if attempt == maxProviderAttempts {
+			return "", fmt.Errorf("no valid provider after %d attempts (supported: %s)",
+				maxProviderAttempts, strings.Join(w.providers, ", "))
+		}
+		fmt.Fprintf(w.out, "Invalid choice '%s'; enter 1-%d or one of %s\n",
+			answer, len(w.providers), strings.Join(w.providers, ", "))
+	}
+}
+
+// askProfile offers the profiles of the shared AWS config. Enter leaves the
+// profile unset, so AWS_PROFILE or the default profile applies as usual.
+func (w *wizard) askProfile() (string, error) {
+	profiles, err := w.awsProfiles()
+	if err != nil {
+		fmt.Fprintf(w.out, "⚠️  Warning: %v\n", err)
+	}
+	if len(profiles) == 0 {
+		fmt.Fprintln(w.out, "\nNo AWS profiles found; the default credential chain will be used.")
+		return "", nil
+	}
+
+	fmt.Fprintln(w.out, "\nAWS profiles:")
+	for i, profile := range profiles {
+		fmt.Fprintf(w.out, "%d. %s\n", i+1, profile)
+	}
+	hint := "Enter for the default profile"
+	if env := os.Getenv("AWS_PROFILE"); env != "" {
+		hint = "Enter for AWS_PROFILE (" + env + ")"
+	}
+	for {
+		answer, err := w.ask("AWS profile, number or name", hint)
+		if err != nil || answer == "" {
+			return "", err
+		}
+		if choice, ok := pick(answer, profiles); ok {
+			return choice, nil
+		}
+		fmt.Fprintf(w.out, "Unknown profile '%s'\n", answer)
+	}
+}
+
+// askSubscriptions lists the subscriptions the credentials can see, which
+// doubles as an auth check. Enter selects all of them.
+func (w *wizard) askSubscriptions(ctx context.Context) ([]string, error) {
+	fmt.Fprintln(w.out, "\nChecking Azure credentials...")
+	ctx, cancel := context.WithTimeout(ctx, subscriptionListTimeout)
+	subscriptions, err := w.azureSubscriptions(ctx)
+	cancel()
+	if err != nil || len(subscriptions) == 0 {
+		if err != nil {
+			fmt.Fprintf(w.out, "⚠️  Warning: could not list subscriptions: %v\n", err)
+		}
+		answer, err := w.ask("Subscription IDs to scan, comma-separated", "Enter for all")
+		return splitList(answer), err
+	}
+
+	ids := make([]string, len(subscriptions))
+	fmt.Fprintln(w.out, "\nSubscriptions:")
+	for i, subscription := range subscriptions {
+		ids[i] = subscription.ID
+		fmt.Fprintf(w.out, "%d. %s (%s)\n", i+1, subscription.Name, subscription.ID)
+	}
+	for {
+		answer, err := w.ask("Subscriptions to scan, numbers or IDs, comma-separated", "Enter for all")
+		if err != nil || answer == "" {
+			return nil, err
+		}
+		selected, invalid := pickAll(splitList(answer), ids)
+		if invalid == "" {
+			return selected, nil
+		}
+		fmt.Fprintf(w.out, "Unknown subscription '%s'\n", invalid)
+	}
+}
+
+func (w *wizard) askFormat(current string) (string, error) {
+	for {
+		answer, err := w.ask("Output format ("+strings.Join(report.Formats, ", ")+")", current)
+		if err != nil || answer == "" {
+			return current, err
+		}
+		if format := strings.ToLower(answer); report.ValidFormat(format) {
+			return format, nil
+		}
+		fmt.Fprintf(w.out, "Unsupported format '%s'\n", answer)
+	}
+}
+
+func (w *wizard) offerSave(config *agent.Config, savePath string) error {
+	if savePath == "" {
+		return nil
+	}
+	answer, err := w.ask("Save these answers to "+savePath+" for next time? (y/n)", "y")
+	if err != nil {
+		return err
+	}
+	if answer != "" && !strings.HasPrefix(strings.ToLower(answer), "y") {
+		return nil
+	}
+	if err := saveConfigFile(savePath, fileConfigFrom(config)); err != nil {
+		return err
+	}
+	fmt.Fprintf(w.out, "✓ Saved to %s; later runs use it unless flags override it\n\n", savePath)
+	return nil
+}
+
+// ask prints question with hint describing the default and returns the
+// trimmed answer, empty when Enter was pressed. Input ending before an
+// answer returns errInputClosed.
+func (w *wizard) ask(question, hint string) (string, error) {
+	fmt.Fprintf(w.out, "%s [%s]: ", question, hint)
+	line, err := w.in.ReadString('\n')
+	switch {
+	case errors.Is(err, io.EOF) && line == "":
+		fmt.Fprintln(w.out)
+		return "", errInputClosed
+	case err != nil && !errors.Is(err, io.EOF):
+		return "", fmt.Errorf("error reading input: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// pick resolves an answer given as a 1-based number or as one of choices
+func pick(answer string, choices []string) (string, bool) {
+	if n, err := strconv.Atoi(answer); err == nil {
+		if n >= 1 && n <= len(choices) {
+			return choices[n-1], true
+		}
+		return "", false
+	}
+	for _, choice := range choices {
+		if strings.EqualFold(choice, answer) {
+			return choice, true
+		}
+	}
+	return "", false
+}
+
+// pickAll resolves every answer with pick, returning the first invalid one
+func pickAll(answers, choices []string) (selected []string, invalid string) {
+	for _, answer := range answers {
+		choice, ok := pick(answer, choices)
+		if !ok {
+			return nil, answer
+		}
+		selected = append(selected, choice)
+	}
+	return selected, ""
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func newTestWizard(input string) *wizard {
+	w := newWizard(bufio.NewReader(strings.NewReader(input)), io.Discard)
+	w.awsProfiles = func() ([]string, error) { return []string{"default", "prod"}, nil }
+	w.azureSubscriptions = func(context.Context) ([]models.AccountCount, error) {
+		return []models.AccountCount{{ID: "sub-a", Name: "Prod"}, {ID: "sub-b", Name: "Dev"}}, nil
+	}
+	return w
+}
+
+func TestWizard(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		setFlags map[string]bool
+		config   agent.Config
+		want     agent.Config
+	}{
+		{
+			name:   "all defaults",
+			input:  "\n\n\n\n\n\n",
+			config: agent.Config{OutputFormat: "table"},
+			want:   agent.Config{Provider: "aws", OutputFormat: "table"},
+		},
+		{
+			name:   "aws answers",
+			input:  "1\n2\nus-east-1, eu-west-1\njson\nout.json\nn\n",
+			config: agent.Config{OutputFormat: "table"},
+			want: agent.Config{Provider: "aws", Profile: "prod", Regions: []string{"us-east-1", "eu-west-1"},
+				OutputFormat: "json", OutputFile: "out.json"},
+		},
+		{
+			name:   "invalid answers are asked again",
+			input:  "gcp\naws\nstaging\ndefault\n\nxml\ncsv\n\nn\n",
+			config: agent.Config{OutputFormat: "table"},
+			want:   agent.Config{Provider: "aws", Profile: "default", OutputFormat: "csv"},
+		},
+		{
+			name:   "azure subscriptions",
+			input:  "azure\n2,sub-a\n\n\nn\n",
+			config: agent.Config{OutputFormat: "table"},
+			want:   agent.Config{Provider: "azure", Subscriptions: []string{"sub-b", "sub-a"}, OutputFormat: "table"},
+		},
+		{
+			name:     "flags are not asked for",
+			input:    "2\n\nn\n",
+			setFlags: map[string]bool{"subscriptions": true, "format": true},
+			config:   agent.Config{OutputFormat: "yaml", Subscriptions: []string{"sub-z"}},
+			want:     agent.Config{Provider: "azure", Subscriptions: []string{"sub-z"}, OutputFormat: "yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			savePath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := newTestWizard(tt.input).run(context.Background(), &config, tt.setFlags, savePath); err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if !reflect.DeepEqual(config, tt.want) {
+				t.Errorf("config = %+v, want %+v", config, tt.want)
+			}
+		})
+	}
+}
+
+func TestWizardFallbacks(t *testing.T) {
+	// Without profiles the question is skipped; a failed auth check still
+	// allows typing subscription IDs
+	w := newTestWizard("aws\n\n\n\n")
+	w.awsProfiles = func() ([]string, error) { return nil, nil }
+	config := agent.Config{OutputFormat: "table"}
+	if err := w.run(context.Background(), &config, nil, ""); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if config.Profile != "" || config.Provider != "aws" {
+		t.Errorf("config = %+v", config)
+	}
+
+	w = newTestWizard("azure\nsub-x,sub-y\n\n\n")
+	w.azureSubscriptions = func(context.Context) ([]models.AccountCount, error) {
+		return nil, errors.New("run az login")
+	}
+	config = agent.Config{OutputFormat: "table"}
+	if err := w.run(context.Background(), &config, nil, ""); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if !reflect.DeepEqual(config.Subscriptions, []string{"sub-x", "sub-y"}) {
+		t.Errorf("subscriptions = %v", config.Subscriptions)
+	}
+
+	// Registered providers beyond the built-in ones are offered too, and
+	// skip the provider-specific questions
+	w = newTestWizard("3\n\n\n")
+	w.providers = []string{"aws", "azure", "memory"}
+	config = agent.Config{OutputFormat: "table"}
+	if err := w.run(context.Background(), &config, nil, ""); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if config.Provider != "memory" {
+		t.Errorf("provider = %q, want memory", config.Provider)
+	}
+
+	// Running out of input is an error rather than a silent default
+	if err := newTestWizard("aws\n").run(context.Background(), &agent.Config{}, nil, ""); err == nil {
+		t.Error("run() with truncated input error = nil")
+	}
+}
+
+func TestAskProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "default", input: "\n", want: "aws"},
+		{name: "number", input: "3\n", want: "k8s"},
+		{name: "name in any case", input: "Azure\n", want: "azure"},
+		{name: "alias", input: " Kubernetes \n", want: "k8s"},
+		{name: "last line without newline", input: "oracle", want: "oci"},
+		{name: "typo asked again", input: "asw\n9\noci\n", want: "oci"},
+		{name: "gives up", input: "gcp\n0\nibm\naws\n"},
+		{name: "ctrl-d", input: "", wantErr: errInputClosed},
+		{name: "ctrl-d after a typo", input: "gcp\n", wantErr: errInputClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			w := newWizard(bufio.NewReader(strings.NewReader(tt.input)), &out)
+			w.providers = []string{"aws", "azure", "k8s", "oci"}
+
+			got, err := w.askProvider()
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("askProvider() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.want == "":
+				if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+					t.Fatalf("askProvider() = %q, %v, want to give up after 3 attempts", got, err)
+				}
+			case err != nil || got != tt.want:
+				t.Fatalf("askProvider() = %q, %v, want %q", got, err, tt.want)
+			}
+			if !strings.Contains(out.String(), "4. oci") {
+				t.Errorf("askProvider() did not list the registered providers:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestWizardSavesConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrails-sizing-agent", "config.yaml")
+	config := agent.Config{OutputFormat: "table"}
+	if err := newTestWizard("aws\nprod\neu-west-1\njson\n\ny\n").run(context.Background(), &config, nil, path); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	file, err := loadConfigFile(path, true)
+	if err != nil {
+		t.Fatalf("loadConfigFile() error = %v", err)
+	}
+	want := &fileConfig{Provider: "aws", Profile: "prod", Regions: []string{"eu-west-1"}, Format: "json"}
+	if !reflect.DeepEqual(file, want) {
+		t.Errorf("saved config = %+v, want %+v", file, want)
+	}
+
+	// Flags given on the command line win over the file
+	next := agent.Config{OutputFormat: "csv"}
+	file.apply(&next, map[string]bool{"format": true})
+	if next.Provider != "aws" || next.Profile != "prod" || next.OutputFormat != "csv" {
+		t.Errorf("applied config = %+v", next)
+	}
+
+	if file, err := loadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), false); file != nil || err != nil {
+		t.Errorf("loadConfigFile() of a missing optional file = %v, %v", file, err)
+	}
+	if _, err := loadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), true); err == nil {
+		t.Error("loadConfigFile() of a missing explicit file error = nil")
+	}
+}
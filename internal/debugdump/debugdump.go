@@ -0,0 +1,204 @@
+// Package debugdump writes the raw API responses of a scan to a directory,
+// to see what the cloud APIs returned when counts look wrong. Responses are
+// captured by SDK middleware, so collectors are unaware of it.
+package debugdump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// ManifestFile lists every dumped response with what it was for
+	ManifestFile = "manifest.json"
+
+	// DefaultMaxBytes caps the size of a dump
+	DefaultMaxBytes = 100 << 20
+)
+
+// resourceTypeKey holds the resource type being counted in a context
+type resourceTypeKey struct{}
+
+// WithResourceType returns ctx recording that its calls count resourceType,
+// for the manifest
+func WithResourceType(ctx context.Context, resourceType string) context.Context {
+	return context.WithValue(ctx, resourceTypeKey{}, resourceType)
+}
+
+// ResourceType returns the resource type ctx counts, or "" for discovery
+// calls
+func ResourceType(ctx context.Context) string {
+	resourceType, _ := ctx.Value(resourceTypeKey{}).(string)
+	return resourceType
+}
+
+// Response is one API response and the call it answered
+type Response struct {
+	Provider  string
+	Service   string
+	Operation string
+	Region    string
+	Method    string
+	URL       string
+	Status    int
+	Body      []byte
+}
+
+// Entry is the manifest record of one dumped response
+type Entry struct {
+	File         string `json:"file"`
+	Provider     string `json:"provider"`
+	Service      string `json:"service"`
+	Operation    string `json:"operation"`
+	ResourceType string `json:"resource_type,omitempty"`
+	Region       string `json:"region,omitempty"`
+	Status       int    `json:"status"`
+	Bytes        int    `json:"bytes"`
+}
+
+// Manifest maps the numbered response files to their calls
+type Manifest struct {
+	Started time.Time `json:"started"`
+	Entries []Entry   `json:"entries"`
+
+	// Skipped is the number of responses left out once the dump reached
+	// MaxBytes
+	MaxBytes int64 `json:"max_bytes"`
+	Skipped  int   `json:"skipped,omitempty"`
+}
+
+// Dumper writes responses to a directory as numbered JSON files, redacting
+// tag values and the sensitive fields, until their total size reaches the
+// cap. A nil Dumper writes nothing.
+type Dumper struct {
+	dir      string
+	redactor *redactor
+
+	mu       sync.Mutex
+	written  int64
+	manifest Manifest
+}
+
+// New creates dir, which must be empty or missing, and returns a dumper
+// writing at most maxBytes into it; sensitive names fields whose values are
+// redacted wherever they appear, in addition to tag values
+func New(dir string, maxBytes int64, sensitive []string) (*Dumper, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create debug dump directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read debug dump directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("debug dump directory %s is not empty", dir)
+	}
+	return &Dumper{
+		dir:      dir,
+		redactor: newRedactor(sensitive),
+		manifest: Manifest{Started: time.Now().UTC(), Entries: []Entry{}, MaxBytes: maxBytes},
+	}, nil
+}
+
+// dumpFile is the layout of a response file. Body holds JSON responses as
+// JSON and any other response, such as the XML of EC2, as a string.
+type dumpFile struct {
+	Provider     string `json:"provider"`
+	Service      string `json:"service"`
+	Operation    string `json:"operation"`
+	ResourceType string `json:"resource_type,omitempty"`
+	Region       string `json:"region,omitempty"`
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	Status       int    `json:"status"`
+	Body         any    `json:"body"`
+}
+
+// Write dumps one response of a call made with ctx. Failures are logged
+// rather than returned, as the dump must never fail the scan.
+func (d *Dumper) Write(ctx context.Context, response Response) {
+	if d == nil {
+		return
+	}
+	file := dumpFile{
+		Provider:     response.Provider,
+		Service:      response.Service,
+		Operation:    response.Operation,
+		ResourceType: ResourceType(ctx),
+		Region:       response.Region,
+		Method:       response.Method,
+		URL:          d.redactor.url(response.URL),
+		Status:       response.Status,
+		Body:         d.redactor.body(response.Body),
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		logging.Warn("Failed to dump API response", zap.String("operation", response.Operation), zap.Error(err))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.written+int64(len(data)) > d.manifest.MaxBytes {
+		if d.manifest.Skipped == 0 {
+			logging.Warn("Debug dump reached its size cap; further responses are not written",
+				zap.Int64("max_bytes", d.manifest.MaxBytes))
+		}
+		d.manifest.Skipped++
+		return
+	}
+	name := fmt.Sprintf("%06d.json", len(d.manifest.Entries)+1)
+	if err := os.WriteFile(filepath.Join(d.dir, name), data, 0o600); err != nil {
+		logging.Warn("Failed to dump API response", zap.String("file", name), zap.Error(err))
+		return
+	}
+	d.written += int64(len(data))
+	d.manifest.Entries = append(d.manifest.Entries, Entry{
+		File:         name,
+		Provider:     file.Provider,
+		Service:      file.Service,
+		Operation:    file.Operation,
+		ResourceType: file.ResourceType,
+		Region:       file.Region,
+		Status:       file.Status,
+		Bytes:        len(data),
+	})
+}
+
+// Close writes the manifest
+func (d *Dumper) Close() error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(d.manifest); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(d.dir, ManifestFile), buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write debug dump manifest: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of responses written
+func (d *Dumper) Count() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.manifest.Entries)
+}
@@ -0,0 +1,101 @@
+package debugdump
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readManifest(t *testing.T, dir string) Manifest {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	return manifest
+}
+
+func TestWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dump")
+	dumper, err := New(dir, DefaultMaxBytes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithResourceType(context.Background(), "ec2:instance")
+	dumper.Write(ctx, Response{
+		Provider: "aws", Service: "Resource Groups Tagging API", Operation: "GetResources", Region: "eu-west-1",
+		Method: "POST", URL: "https://tagging.eu-west-1.amazonaws.com/", Status: 200,
+		Body: []byte(`{"ResourceTagMappingList":[{"ResourceARN":"arn:aws:ec2:eu-west-1:1:instance/i-1","Tags":[{"Key":"owner","Value":"jane"}]}]}`),
+	})
+	dumper.Write(context.Background(), Response{Provider: "aws", Service: "EC2", Operation: "DescribeRegions", Status: 200,
+		Body: []byte(`<DescribeRegionsResponse><regionInfo/></DescribeRegionsResponse>`)})
+	if err := dumper.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := readManifest(t, dir)
+	if len(manifest.Entries) != 2 || dumper.Count() != 2 {
+		t.Fatalf("manifest = %+v, want 2 entries", manifest)
+	}
+	first := manifest.Entries[0]
+	if first.File != "000001.json" || first.ResourceType != "ec2:instance" || first.Region != "eu-west-1" || first.Operation != "GetResources" {
+		t.Errorf("first entry = %+v", first)
+	}
+	if second := manifest.Entries[1]; second.ResourceType != "" || second.Service != "EC2" {
+		t.Errorf("discovery entry = %+v, want no resource type", second)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, first.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "jane") || !strings.Contains(string(data), `"Key": "owner"`) {
+		t.Errorf("dump = %s, want the tag key kept and its value redacted", data)
+	}
+}
+
+func TestWriteStopsAtTheCap(t *testing.T) {
+	dir := t.TempDir()
+	dumper, err := New(dir, 400, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		dumper.Write(context.Background(), Response{Provider: "azure", Operation: "POST resources", Status: 200,
+			Body: []byte(`{"data":["` + strings.Repeat("x", 100) + `"]}`)})
+	}
+	if err := dumper.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := readManifest(t, dir)
+	if len(manifest.Entries) != 1 || manifest.Skipped != 2 {
+		t.Errorf("entries = %d, skipped = %d, want 1 written and 2 skipped", len(manifest.Entries), manifest.Skipped)
+	}
+}
+
+func TestNewRefusesNonEmptyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "results.json"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(dir, DefaultMaxBytes, nil); err == nil {
+		t.Error("New() accepted a directory that is not empty")
+	}
+}
+
+func TestNilDumper(t *testing.T) {
+	var dumper *Dumper
+	dumper.Write(context.Background(), Response{Body: []byte("{}")})
+	if err := dumper.Close(); err != nil || dumper.Count() != 0 {
+		t.Errorf("nil dumper: Close() = %v, Count() = %d", err, dumper.Count())
+	}
+}
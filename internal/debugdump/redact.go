@@ -0,0 +1,135 @@
+package debugdump
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Redacted replaces every redacted value
+const Redacted = "[REDACTED]"
+
+// tagFields are the fields holding resource tags: a map of key to value on
+// Azure and in Resource Graph rows, a list of Key/Value pairs on AWS
+var tagFields = map[string]bool{
+	"tags":         true,
+	"tagset":       true,
+	"taglist":      true,
+	"resourcetags": true,
+}
+
+// credentialFields are always redacted: the temporary credentials of STS
+// and SSO responses and OAuth tokens
+var credentialFields = []string{"AccessKeyId", "SecretAccessKey", "SessionToken", "access_token", "refresh_token"}
+
+// xmlTagSet matches the tag sets of EC2 XML responses, and xmlTagValue the
+// values inside them
+var (
+	xmlTagSet   = regexp.MustCompile(`(?s)<tagSet>.*?</tagSet>`)
+	xmlTagValue = regexp.MustCompile(`(?s)<value>.*?</value>`)
+)
+
+// redactor removes tag values and sensitive fields from responses
+type redactor struct {
+	// sensitive are the lower-cased names of the sensitive fields
+	sensitive map[string]bool
+	xmlFields []*regexp.Regexp
+}
+
+func newRedactor(fields []string) *redactor {
+	r := &redactor{sensitive: make(map[string]bool, len(fields))}
+	for _, field := range slices.Concat(fields, credentialFields) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		r.sensitive[strings.ToLower(field)] = true
+		r.xmlFields = append(r.xmlFields,
+			regexp.MustCompile(`(?is)(<`+regexp.QuoteMeta(field)+`>).*?(</`+regexp.QuoteMeta(field)+`>)`))
+	}
+	return r
+}
+
+// body returns a JSON body as a redacted JSON value and any other body as
+// a redacted string
+func (r *redactor) body(data []byte) any {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err == nil && !decoder.More() {
+		return r.value(value)
+	}
+
+	text := xmlTagSet.ReplaceAllStringFunc(string(data), func(set string) string {
+		return xmlTagValue.ReplaceAllString(set, "<value>"+Redacted+"</value>")
+	})
+	for _, field := range r.xmlFields {
+		text = field.ReplaceAllString(text, "${1}"+Redacted+"${2}")
+	}
+	return text
+}
+
+// value redacts the sensitive fields and tag values within a JSON value
+func (r *redactor) value(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			switch name := strings.ToLower(key); {
+			case r.sensitive[name]:
+				v[key] = Redacted
+			case tagFields[name]:
+				v[key] = redactTags(field)
+			default:
+				v[key] = r.value(field)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = r.value(v[i])
+		}
+	}
+	return value
+}
+
+// redactTags keeps the tag keys of a tag map or Key/Value list and redacts
+// their values
+func redactTags(tags any) any {
+	switch t := tags.(type) {
+	case map[string]any:
+		for key := range t {
+			t[key] = Redacted
+		}
+	case []any:
+		for _, tag := range t {
+			pair, ok := tag.(map[string]any)
+			if !ok {
+				continue
+			}
+			for key := range pair {
+				if strings.EqualFold(key, "value") {
+					pair[key] = Redacted
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// url redacts the values of sensitive query parameters
+func (r *redactor) url(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	query := u.Query()
+	for key := range query {
+		if r.sensitive[strings.ToLower(key)] {
+			query.Set(key, Redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
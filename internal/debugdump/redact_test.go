@@ -0,0 +1,53 @@
+package debugdump
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	r := newRedactor([]string{"computerName"})
+	body := `{"data":[{"name":"vm-1","tags":{"owner":"jane"},"properties":{"osProfile":{"computerName":"web01"}}}],` +
+		`"Credentials":{"AccessKeyId":"ASIA","SecretAccessKey":"s3cret","SessionToken":"tok"},"count":12345678901234567890}`
+
+	data, err := json.Marshal(r.body([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, secret := range []string{"jane", "web01", "ASIA", "s3cret", "tok"} {
+		if strings.Contains(got, `"`+secret+`"`) {
+			t.Errorf("redacted body %s still contains %q", got, secret)
+		}
+	}
+	for _, kept := range []string{`"name":"vm-1"`, `"owner":"[REDACTED]"`, `"count":12345678901234567890`} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("redacted body %s lacks %s", got, kept)
+		}
+	}
+}
+
+func TestRedactXML(t *testing.T) {
+	r := newRedactor([]string{"privateDnsName"})
+	body := `<DescribeInstancesResponse><privateDnsName>ip-10-0-0-1</privateDnsName>` +
+		`<tagSet><item><key>owner</key><value>jane</value></item></tagSet></DescribeInstancesResponse>`
+
+	got, ok := r.body([]byte(body)).(string)
+	if !ok {
+		t.Fatalf("body() = %T, want the XML as a string", r.body([]byte(body)))
+	}
+	want := `<DescribeInstancesResponse><privateDnsName>[REDACTED]</privateDnsName>` +
+		`<tagSet><item><key>owner</key><value>[REDACTED]</value></item></tagSet></DescribeInstancesResponse>`
+	if got != want {
+		t.Errorf("body() =\n  %s\nwant\n  %s", got, want)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	r := newRedactor([]string{"filter"})
+	got := r.url("https://graph.microsoft.com/v1.0/users?filter=mail+eq+%27jane%40example.com%27&top=10")
+	if strings.Contains(got, "jane") || !strings.Contains(got, "top=10") {
+		t.Errorf("url() = %s, want the filter redacted", got)
+	}
+}
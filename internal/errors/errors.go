@@ -0,0 +1,324 @@
+// Package errors classifies scan failures so that the CLI can exit with a
+// distinct code and tell the user how to fix them. Providers wrap SDK errors
+// into these types; anything unclassified is a generic failure.
+package errors
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Process exit codes; 3 is used by the agent for exceeded thresholds
+const (
+	ExitFailure       = 1
+	ExitAuth          = 4
+	ExitPermission    = 5
+	ExitThrottled     = 6
+	ExitPartialResult = 7
+)
+
+// AuthError means the credentials are missing, invalid or expired
+type AuthError struct {
+	Provider string
+	Err      error
+
+	// Expired is set when the credentials expired during the scan, e.g. an
+	// AWS SSO session or an Azure CLI token, after counting had started
+	Expired bool
+}
+
+func (e *AuthError) Error() string { return e.Err.Error() }
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// Hint tells the user how to authenticate
+func (e *AuthError) Hint() string {
+	if e.Expired {
+		return "the credentials expired during the scan; " + e.signInHint() + ", then run the scan again"
+	}
+	return e.signInHint()
+}
+
+// signInHint tells the user how to get valid credentials of the provider
+func (e *AuthError) signInHint() string {
+	switch e.Provider {
+	case "aws":
+		return "check your AWS credentials: run 'aws sso login' or 'aws configure', or set AWS_PROFILE or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY"
+	case "azure":
+		return "run 'az login', or set AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET for a service principal"
+	case "k8s":
+		return "check that the kubeconfig contexts are valid and their clusters reachable, e.g. with 'kubectl --context <name> version'"
+	case "oci":
+		return "check the profile of your OCI config file (~/.oci/config) with 'oci iam region list', or use --oci-auth instance_principal on an OCI instance"
+	}
+	return "check your cloud credentials"
+}
+
+// expiredTokenMarkers are lower-cased error codes and messages of
+// credentials that have expired, as opposed to ones that are wrong
+var expiredTokenMarkers = []string{
+	"expiredtoken",                // AWS ExpiredToken, ExpiredTokenException
+	"expiredauthenticationtoken",  // Azure Resource Manager
+	"tokenexpired",                // Azure InvalidAuthenticationTokenTokenExpired
+	"aadsts700082", "aadsts50173", // Entra ID refresh token expired or revoked
+	"token is expired", "token has expired",
+	"session has expired", "session associated with this profile has expired", // AWS SSO
+}
+
+// ExpiredAuth returns the AuthError of err marked as Expired when its
+// message names credentials that have expired, or nil when err is no such
+// failure
+func ExpiredAuth(err error) *AuthError {
+	var auth *AuthError
+	if !errors.As(err, &auth) {
+		return nil
+	}
+	message := strings.ToLower(err.Error())
+	for _, marker := range expiredTokenMarkers {
+		if strings.Contains(message, marker) {
+			auth.Expired = true
+			break
+		}
+	}
+	if !auth.Expired {
+		return nil
+	}
+	return auth
+}
+
+// CredentialsExpired reports whether err is, or wraps anywhere, an AuthError
+// of credentials that expired during the scan
+func CredentialsExpired(err error) bool {
+	if auth, ok := err.(*AuthError); ok && auth.Expired {
+		return true
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if CredentialsExpired(inner) {
+				return true
+			}
+		}
+	case interface{ Unwrap() error }:
+		return CredentialsExpired(e.Unwrap())
+	}
+	return false
+}
+
+// PermissionError means the credentials are valid but lack a permission the
+// scan needs. Action names it when known, e.g. "tag:GetResources".
+type PermissionError struct {
+	Provider string
+	Action   string
+	Err      error
+}
+
+func (e *PermissionError) Error() string { return e.Err.Error() }
+func (e *PermissionError) Unwrap() error { return e.Err }
+
+// Hint tells the user which permission to grant
+func (e *PermissionError) Hint() string {
+	switch e.Provider {
+	case "aws":
+		if e.Action != "" {
+			return fmt.Sprintf("grant %s to the IAM identity running the scan (see docs/AWS_SETUP.md)", e.Action)
+		}
+		return "grant the read-only permissions listed in docs/AWS_SETUP.md to the IAM identity running the scan"
+	case "azure":
+		if e.Action != "" {
+			return fmt.Sprintf("grant %s, e.g. through the Reader role, on the subscriptions to scan (see docs/AZURE_SETUP.md)", e.Action)
+		}
+		return "assign the Reader role on the subscriptions to scan (see docs/AZURE_SETUP.md)"
+	case "k8s":
+		if e.Action != "" {
+			return fmt.Sprintf("allow %s cluster-wide to the kubeconfig user (see docs/K8S_SETUP.md)", e.Action)
+		}
+		return "bind the read-only ClusterRole in docs/K8S_SETUP.md to the kubeconfig user"
+	case "oci":
+		if e.Action != "" {
+			return fmt.Sprintf("allow the scanning group to %s in the tenancy (see docs/OCI_SETUP.md)", e.Action)
+		}
+		return "add the read-only policy in docs/OCI_SETUP.md for the scanning group"
+	}
+	return "grant the scanning identity read access"
+}
+
+// ThrottledError means the provider kept rate limiting requests after retries
+type ThrottledError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ThrottledError) Error() string { return e.Err.Error() }
+func (e *ThrottledError) Unwrap() error { return e.Err }
+
+// Hint suggests reducing the request rate
+func (e *ThrottledError) Hint() string {
+	return "the API is rate limiting requests; retry later, or scan fewer regions or subscriptions at a time"
+}
+
+// TLSError means the server certificate was not trusted, which usually means
+// a TLS-intercepting proxy sits between the agent and the cloud provider
+type TLSError struct {
+	Err error
+}
+
+func (e *TLSError) Error() string { return e.Err.Error() }
+func (e *TLSError) Unwrap() error { return e.Err }
+
+// Hint points at the option for trusting the proxy's certificate
+func (e *TLSError) Hint() string {
+	return "the server certificate is not trusted; if a proxy intercepts TLS, pass its root certificate with --ca-bundle"
+}
+
+// IsCertificateError reports whether err is a failed verification of a
+// server certificate
+func IsCertificateError(err error) bool {
+	var (
+		verification *tls.CertificateVerificationError
+		unknown      x509.UnknownAuthorityError
+		invalid      x509.CertificateInvalidError
+		hostname     x509.HostnameError
+	)
+	return errors.As(err, &verification) || errors.As(err, &unknown) ||
+		errors.As(err, &invalid) || errors.As(err, &hostname)
+}
+
+// PartialResultError means the scan finished but some resource types, or
+// whole scans of a multi-scan run, could not be counted, so the totals are
+// too low. The result is still returned.
+type PartialResultError struct {
+	// Failed lists the resource types that could not be counted
+	Failed []string
+	// FailedScans lists the scans of a multi-scan run that failed as a
+	// whole, e.g. "profile dev"
+	FailedScans []string
+	// Errs holds the classified error of each failed type and scan
+	Errs []error
+}
+
+func (e *PartialResultError) Error() string {
+	var parts []string
+	if CredentialsExpired(e) {
+		parts = append(parts, "the credentials expired during the scan")
+	}
+	if len(e.Failed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d resource types could not be counted: %s", len(e.Failed), strings.Join(e.Failed, ", ")))
+	}
+	if len(e.FailedScans) > 0 {
+		parts = append(parts, fmt.Sprintf("%d scans failed: %s", len(e.FailedScans), strings.Join(e.FailedScans, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *PartialResultError) Unwrap() []error { return e.Errs }
+
+// Hint explains that the counts are incomplete, adding the hint of the first
+// classified cause
+func (e *PartialResultError) Hint() string {
+	hint := "the results were written but undercount the failed types"
+	if len(e.Failed) == 0 {
+		hint = "the results were written but leave out the failed scans"
+	}
+	for _, err := range e.Errs {
+		if cause := Hint(err); cause != "" {
+			return hint + "; " + cause
+		}
+	}
+	return hint
+}
+
+// hinter is implemented by every error type of this package
+type hinter interface {
+	Hint() string
+}
+
+// Classified reports whether err already is, or wraps, one of this
+// package's error types
+func Classified(err error) bool {
+	var h hinter
+	return errors.As(err, &h)
+}
+
+// Hint returns the remediation hint for err, or "" when it is not classified
+func Hint(err error) string {
+	var partial *PartialResultError
+	if errors.As(err, &partial) {
+		return partial.Hint()
+	}
+	var h hinter
+	if errors.As(err, &h) {
+		return h.Hint()
+	}
+	return ""
+}
+
+// ExitCode returns the process exit code for err. A partial result takes
+// precedence over the causes it wraps, except for credentials that expired
+// during the scan.
+func ExitCode(err error) int {
+	var (
+		partial    *PartialResultError
+		auth       *AuthError
+		permission *PermissionError
+		throttled  *ThrottledError
+	)
+	switch {
+	case err == nil:
+		return 0
+	case CredentialsExpired(err):
+		return ExitAuth
+	case errors.As(err, &partial):
+		return ExitPartialResult
+	case errors.As(err, &auth):
+		return ExitAuth
+	case errors.As(err, &permission):
+		return ExitPermission
+	case errors.As(err, &throttled):
+		return ExitThrottled
+	}
+	return ExitFailure
+}
+
+// Failures collects the resource types that failed to count during a scan;
+// it is safe for concurrent use
+type Failures struct {
+	mu     sync.Mutex
+	failed []string
+	errs   []error
+}
+
+// Add records that resourceType could not be counted
+func (f *Failures) Add(resourceType string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed = append(f.failed, resourceType)
+	f.errs = append(f.errs, err)
+}
+
+// Partial returns the failures sorted by type, or nil when every type was
+// counted
+func (f *Failures) Partial() *PartialResultError {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.failed) == 0 {
+		return nil
+	}
+
+	order := make([]int, len(f.failed))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return f.failed[order[a]] < f.failed[order[b]] })
+
+	partial := &PartialResultError{}
+	for _, i := range order {
+		partial.Failed = append(partial.Failed, f.failed[i])
+		partial.Errs = append(partial.Errs, f.errs[i])
+	}
+	return partial
+}
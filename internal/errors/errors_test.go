@@ -0,0 +1,127 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExitCodeAndHint(t *testing.T) {
+	cause := errors.New("sdk error")
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantHint string
+	}{
+		{name: "nil", err: nil, wantCode: 0},
+		{name: "generic", err: cause, wantCode: ExitFailure},
+		{name: "aws auth", err: &AuthError{Provider: "aws", Err: cause}, wantCode: ExitAuth, wantHint: "aws sso login"},
+		{name: "azure auth", err: &AuthError{Provider: "azure", Err: cause}, wantCode: ExitAuth, wantHint: "az login"},
+		{
+			name:     "aws permission",
+			err:      &PermissionError{Provider: "aws", Action: "tag:GetResources", Err: cause},
+			wantCode: ExitPermission,
+			wantHint: "grant tag:GetResources",
+		},
+		{name: "azure permission", err: &PermissionError{Provider: "azure", Err: cause}, wantCode: ExitPermission, wantHint: "Reader role"},
+		{name: "throttled", err: &ThrottledError{Provider: "aws", Err: cause}, wantCode: ExitThrottled, wantHint: "retry later"},
+		{name: "tls", err: &TLSError{Err: cause}, wantCode: ExitFailure, wantHint: "--ca-bundle"},
+		{
+			name:     "wrapped",
+			err:      fmt.Errorf("failed to connect: %w", &AuthError{Provider: "azure", Err: cause}),
+			wantCode: ExitAuth,
+			wantHint: "az login",
+		},
+		{
+			name: "partial wins over its causes",
+			err: &PartialResultError{
+				Failed: []string{"guardduty:detector"},
+				Errs:   []error{&PermissionError{Provider: "aws", Action: "guardduty:ListDetectors", Err: cause}},
+			},
+			wantCode: ExitPartialResult,
+			wantHint: "grant guardduty:ListDetectors",
+		},
+		{
+			name: "failed scan",
+			err: &PartialResultError{
+				FailedScans: []string{"profile dev"},
+				Errs:        []error{&AuthError{Provider: "aws", Err: cause}},
+			},
+			wantCode: ExitPartialResult,
+			wantHint: "leave out the failed scans; check your AWS credentials",
+		},
+		{
+			name: "credentials expired",
+			err: &PartialResultError{
+				Failed: []string{"ec2:instance", "s3:bucket"},
+				Errs: []error{
+					&AuthError{Provider: "aws", Err: cause, Expired: true},
+					fmt.Errorf("not counted: %w", &AuthError{Provider: "aws", Err: cause, Expired: true}),
+				},
+			},
+			wantCode: ExitAuth,
+			wantHint: "expired during the scan; check your AWS credentials",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := ExitCode(tt.err); code != tt.wantCode {
+				t.Errorf("ExitCode() = %d, want %d", code, tt.wantCode)
+			}
+			hint := Hint(tt.err)
+			if tt.wantHint == "" && hint != "" {
+				t.Errorf("Hint() = %q, want none", hint)
+			}
+			if !strings.Contains(hint, tt.wantHint) {
+				t.Errorf("Hint() = %q, want it to contain %q", hint, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestExpiredAuth(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"aws sso", &AuthError{Provider: "aws", Err: errors.New("ExpiredToken: The security token included in the request is expired")}, true},
+		{"azure cli", fmt.Errorf("count: %w", &AuthError{Provider: "azure", Err: errors.New("AADSTS700082: The refresh token has expired")}), true},
+		{"azure arm", &AuthError{Provider: "azure", Err: errors.New("InvalidAuthenticationTokenTokenExpired")}, true},
+		{"wrong key", &AuthError{Provider: "aws", Err: errors.New("InvalidClientTokenId")}, false},
+		{"not an auth error", errors.New("ExpiredToken"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := ExpiredAuth(tt.err)
+			if (auth != nil) != tt.want || CredentialsExpired(tt.err) != tt.want {
+				t.Errorf("ExpiredAuth() = %v, CredentialsExpired() = %v, want %v", auth, CredentialsExpired(tt.err), tt.want)
+			}
+		})
+	}
+}
+
+func TestFailures(t *testing.T) {
+	var failures Failures
+	if failures.Partial() != nil {
+		t.Fatal("Partial() without failures is not nil")
+	}
+
+	throttled := &ThrottledError{Provider: "azure", Err: errors.New("429")}
+	failures.Add("s3:bucket", errors.New("boom"))
+	failures.Add("ec2:instance", throttled)
+
+	partial := failures.Partial()
+	if got := strings.Join(partial.Failed, ","); got != "ec2:instance,s3:bucket" {
+		t.Errorf("Failed = %s, want sorted types", got)
+	}
+	if !errors.Is(partial, throttled) {
+		t.Error("partial result does not wrap its causes")
+	}
+	if partial.Error() != "2 resource types could not be counted: ec2:instance, s3:bucket" {
+		t.Errorf("Error() = %q", partial.Error())
+	}
+}
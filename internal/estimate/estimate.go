@@ -0,0 +1,159 @@
+package estimate
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+//go:embed weights.yaml
+var defaultWeightsYAML []byte
+
+// EmbeddedSource is recorded as the weights file when no override is used
+const EmbeddedSource = "embedded"
+
+// Tier is a named sizing tier with an optional upper bound in workload units
+type Tier struct {
+	Name     string   `yaml:"name"`
+	MaxUnits *float64 `yaml:"max_units"`
+}
+
+// Weights maps resource types to workload units and units to tiers.
+// DefaultWeight is a pointer so that an override file can set it to 0.
+type Weights struct {
+	DefaultWeight *float64           `yaml:"default_weight"`
+	Weights       map[string]float64 `yaml:"weights"`
+	Tiers         []Tier             `yaml:"tiers"`
+
+	// Source is the file the weights were loaded from
+	Source string `yaml:"-"`
+}
+
+// LoadWeights returns the embedded weights with the override file at path
+// applied. Weights in the file are merged by type; tiers and the default
+// weight replace the embedded ones when present.
+func LoadWeights(path string) (*Weights, error) {
+	weights, err := parseWeights(defaultWeightsYAML)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded weights: %w", err)
+	}
+	weights.Source = EmbeddedSource
+
+	if path == "" {
+		return weights, weights.validate()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read weights file: %w", err)
+	}
+	override, err := parseWeights(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse weights file %s: %w", path, err)
+	}
+
+	for t, w := range override.Weights {
+		weights.Weights[strings.ToLower(t)] = w
+	}
+	if len(override.Tiers) > 0 {
+		weights.Tiers = override.Tiers
+	}
+	if override.DefaultWeight != nil {
+		weights.DefaultWeight = override.DefaultWeight
+	}
+	weights.Source = path
+
+	if err := weights.validate(); err != nil {
+		return nil, fmt.Errorf("invalid weights file %s: %w", path, err)
+	}
+	return weights, nil
+}
+
+// Estimate computes workload units for the result and selects a tier
+func (w *Weights) Estimate(result *models.SizingResult) *models.Estimate {
+	estimate := &models.Estimate{
+		ByType: make(map[models.ResourceType]float64),
+	}
+
+	for _, rc := range result.ResourceCounts {
+		if rc.TotalResources == 0 {
+			continue
+		}
+		units := float64(rc.TotalResources) * w.weight(rc.Type)
+		estimate.ByType[rc.Type] += units
+		estimate.WorkloadUnits += units
+	}
+	estimate.WorkloadUnits = math.Round(estimate.WorkloadUnits*100) / 100
+	estimate.Tier = w.tier(estimate.WorkloadUnits)
+
+	return estimate
+}
+
+func (w *Weights) weight(resourceType models.ResourceType) float64 {
+	if weight, ok := w.Weights[strings.ToLower(string(resourceType))]; ok {
+		return weight
+	}
+	if w.DefaultWeight == nil {
+		return 0
+	}
+	return *w.DefaultWeight
+}
+
+func (w *Weights) tier(units float64) string {
+	for _, tier := range w.Tiers {
+		if tier.MaxUnits == nil || units <= *tier.MaxUnits {
+			return tier.Name
+		}
+	}
+	return w.Tiers[len(w.Tiers)-1].Name
+}
+
+func (w *Weights) validate() error {
+	if len(w.Tiers) == 0 {
+		return fmt.Errorf("at least one tier is required")
+	}
+	last := math.Inf(-1)
+	for i, tier := range w.Tiers {
+		if tier.Name == "" {
+			return fmt.Errorf("tier %d: name is required", i+1)
+		}
+		if tier.MaxUnits == nil {
+			if i != len(w.Tiers)-1 {
+				return fmt.Errorf("tier %q: only the last tier may omit max_units", tier.Name)
+			}
+			continue
+		}
+		if *tier.MaxUnits <= last {
+			return fmt.Errorf("tier %q: max_units must be increasing", tier.Name)
+		}
+		last = *tier.MaxUnits
+	}
+	if w.DefaultWeight != nil && *w.DefaultWeight < 0 {
+		return fmt.Errorf("default_weight must not be negative")
+	}
+	for t, weight := range w.Weights {
+		if weight < 0 {
+			return fmt.Errorf("weight for %q must not be negative", t)
+		}
+	}
+	return nil
+}
+
+func parseWeights(data []byte) (*Weights, error) {
+	var weights Weights
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&weights); err != nil {
+		return nil, err
+	}
+	if weights.Weights == nil {
+		weights.Weights = make(map[string]float64)
+	}
+	return &weights, nil
+}
@@ -0,0 +1,105 @@
+package estimate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestEstimate(t *testing.T) {
+	weights, err := LoadWeights("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights.Source != EmbeddedSource {
+		t.Errorf("Source = %q, want %q", weights.Source, EmbeddedSource)
+	}
+
+	result := &models.SizingResult{ResourceCounts: []*models.ResourceCount{
+		{Type: "ec2:instance", TotalResources: 100},
+		{Type: "iam:role", TotalResources: 50},
+		{Type: "unknown:thing", TotalResources: 10},
+	}}
+
+	est := weights.Estimate(result)
+	// 100*1 + 50*0.1 + 10*default(1)
+	if est.WorkloadUnits != 115 {
+		t.Errorf("WorkloadUnits = %v, want 115", est.WorkloadUnits)
+	}
+	if est.Tier != "small" {
+		t.Errorf("Tier = %q, want small", est.Tier)
+	}
+	if est.ByType["unknown:thing"] != 10 {
+		t.Errorf("unknown type units = %v, want 10", est.ByType["unknown:thing"])
+	}
+}
+
+func TestLoadWeightsOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weights.yaml")
+	content := `
+weights:
+  EC2:Instance: 10
+tiers:
+  - name: starter
+    max_units: 100
+  - name: unlimited
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	weights, err := LoadWeights(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights.Source != path {
+		t.Errorf("Source = %q, want %q", weights.Source, path)
+	}
+
+	est := weights.Estimate(&models.SizingResult{ResourceCounts: []*models.ResourceCount{
+		{Type: "ec2:instance", TotalResources: 20},
+	}})
+	if est.WorkloadUnits != 200 || est.Tier != "unlimited" {
+		t.Errorf("estimate = %+v, want 200 units in unlimited", est)
+	}
+}
+
+func TestLoadWeightsZeroDefaultWeight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weights.yaml")
+	if err := os.WriteFile(path, []byte("default_weight: 0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	weights, err := LoadWeights(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ec2:instance keeps its embedded weight, the unlisted type counts 0
+	est := weights.Estimate(&models.SizingResult{ResourceCounts: []*models.ResourceCount{
+		{Type: "ec2:instance", TotalResources: 20},
+		{Type: "appsync:apis", TotalResources: 50},
+	}})
+	if est.WorkloadUnits != 20 {
+		t.Errorf("WorkloadUnits = %v, want 20 with default_weight 0", est.WorkloadUnits)
+	}
+}
+
+func TestLoadWeightsInvalidTiers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weights.yaml")
+	content := `
+tiers:
+  - name: big
+    max_units: 1000
+  - name: small
+    max_units: 10
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadWeights(path); err == nil {
+		t.Fatal("expected error for decreasing tiers")
+	}
+}
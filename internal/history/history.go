@@ -0,0 +1,102 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// Entry is one completed scan in the history file. Readers ignore fields they
+// do not know, so fields may be added without breaking older binaries.
+type Entry struct {
+	Timestamp      time.Time      `json:"timestamp"`
+	Provider       string         `json:"provider"`
+	TotalResources int            `json:"total_resources"`
+	TotalAccounts  int            `json:"total_accounts"`
+	Counts         map[string]int `json:"counts"`
+}
+
+// FromResult summarizes a sizing result as a history entry
+func FromResult(result *models.SizingResult) Entry {
+	entry := Entry{
+		Timestamp:      result.Timestamp.UTC(),
+		Provider:       result.Provider,
+		TotalResources: result.TotalResources,
+		TotalAccounts:  result.TotalAccounts,
+		Counts:         make(map[string]int, len(result.ResourceCounts)),
+	}
+	for _, rc := range result.ResourceCounts {
+		entry.Counts[string(rc.Type)] += rc.TotalResources
+	}
+	return entry
+}
+
+// Append adds entry as one JSON line to the file at path, creating it if
+// needed. The file is locked while writing so concurrent runs never
+// interleave lines.
+func Append(path string, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+
+	if err := lockFile(file); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to lock history file: %w", err)
+	}
+	_, err = file.Write(line)
+	if unlockErr := unlockFile(file); err == nil && unlockErr != nil {
+		err = fmt.Errorf("failed to unlock history file: %w", unlockErr)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// Read returns the entries in the history file at path in file order.
+// Unknown fields are ignored; lines that are not valid JSON, such as one cut
+// short by a crash, are skipped and counted.
+func Read(path string) (entries []Entry, skipped int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, 0, fmt.Errorf("history file %s has a line over 16 MiB", path)
+		}
+		return nil, 0, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return entries, skipped, nil
+}
@@ -0,0 +1,165 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	result := &models.SizingResult{
+		Provider:       "AWS",
+		Timestamp:      time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		TotalResources: 7,
+		TotalAccounts:  2,
+		ResourceCounts: []*models.ResourceCount{
+			{Type: "ec2:instance", TotalResources: 5},
+			{Type: "s3:bucket", TotalResources: 2},
+		},
+	}
+
+	if err := Append(path, FromResult(result)); err != nil {
+		t.Fatal(err)
+	}
+	entries, skipped, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 0 || len(entries) != 1 {
+		t.Fatalf("Read() = %d entries, %d skipped, want 1 and 0", len(entries), skipped)
+	}
+	got := entries[0]
+	if got.Provider != "AWS" || got.TotalResources != 7 || got.TotalAccounts != 2 ||
+		got.Counts["ec2:instance"] != 5 || !got.Timestamp.Equal(result.Timestamp) {
+		t.Errorf("Read() entry = %+v", got)
+	}
+}
+
+func TestConcurrentAppendsDoNotInterleave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	// Large entries make an interleaved write show up as a broken line
+	counts := make(map[string]int)
+	for i := 0; i < 500; i++ {
+		counts[string(rune('a'+i%26))+time.Duration(i).String()] = i
+	}
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := Entry{Timestamp: time.Now(), Provider: "azure", TotalResources: i, Counts: counts}
+			if err := Append(path, entry); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, skipped, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 0 || len(entries) != writers {
+		t.Errorf("Read() = %d entries, %d skipped, want %d and 0", len(entries), skipped, writers)
+	}
+}
+
+func TestReadToleratesSchemaChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	content := `{"timestamp":"2024-03-01T00:00:00Z","provider":"aws","total_resources":10,"counts":{"s3:bucket":10},"workload_units":12.5}
+
+{"timestamp":"2024-03-02T00:00:00Z","provider":"aws","total_resources":12,"future":{"nested":true}}
+{"timestamp":"2024-03-03T00:00:00Z","provi
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, skipped, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || skipped != 1 {
+		t.Fatalf("Read() = %d entries, %d skipped, want 2 and 1", len(entries), skipped)
+	}
+	if entries[0].Counts["s3:bucket"] != 10 || entries[1].TotalResources != 12 {
+		t.Errorf("Read() entries = %+v", entries)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	entries := []Entry{
+		{Timestamp: day(1), Provider: "AWS", TotalResources: 100, TotalAccounts: 2,
+			Counts: map[string]int{"ec2:instance": 50, "s3:bucket": 50}},
+		{Timestamp: day(3), Provider: "Azure", TotalResources: 10, TotalAccounts: 1,
+			Counts: map[string]int{"microsoft.storage/storageaccounts": 10}},
+		{Timestamp: day(2), Provider: "AWS", TotalResources: 110, TotalAccounts: 2,
+			Counts: map[string]int{"ec2:instance": 60, "s3:bucket": 50}},
+		{Timestamp: day(4), Provider: "AWS", TotalResources: 130, TotalAccounts: 3,
+			Counts: map[string]int{"ec2:instance": 45, "s3:bucket": 55, "lambda:function": 30}},
+	}
+
+	tests := []struct {
+		name      string
+		last      int
+		wantScans int
+		wantFirst int
+		wantTypes []Change
+	}{
+		{
+			name: "all scans", last: 0, wantScans: 3, wantFirst: 100,
+			wantTypes: []Change{
+				{Name: "lambda:function", First: 0, Last: 30},
+				{Name: "ec2:instance", First: 50, Last: 45},
+				{Name: "s3:bucket", First: 50, Last: 55},
+			},
+		},
+		{
+			name: "last two", last: 2, wantScans: 2, wantFirst: 110,
+			wantTypes: []Change{
+				{Name: "lambda:function", First: 0, Last: 30},
+				{Name: "ec2:instance", First: 60, Last: 45},
+				{Name: "s3:bucket", First: 50, Last: 55},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trends := Compute(entries, tt.last)
+			if len(trends) != 2 || trends[0].Provider != "AWS" || trends[1].Provider != "Azure" {
+				t.Fatalf("Compute() = %+v, want AWS then Azure", trends)
+			}
+			aws := trends[0]
+			if aws.Scans != tt.wantScans || aws.Resources.First != tt.wantFirst || aws.Resources.Last != 130 {
+				t.Errorf("AWS trend = %d scans, resources %+v", aws.Scans, aws.Resources)
+			}
+			if len(aws.Types) != len(tt.wantTypes) {
+				t.Fatalf("Types = %+v, want %+v", aws.Types, tt.wantTypes)
+			}
+			for i, want := range tt.wantTypes {
+				if aws.Types[i] != want {
+					t.Errorf("Types[%d] = %+v, want %+v", i, aws.Types[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestChangeGrowth(t *testing.T) {
+	if percent, ok := (Change{First: 200, Last: 250}).Growth(); !ok || percent != 25 {
+		t.Errorf("Growth() = %v, %v, want 25, true", percent, ok)
+	}
+	if _, ok := (Change{First: 0, Last: 5}).Growth(); ok {
+		t.Error("Growth() from zero reported ok")
+	}
+}
@@ -0,0 +1,120 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change is how one count moved between the first and last scan of a trend
+type Change struct {
+	Name  string
+	First int
+	Last  int
+}
+
+// Delta returns the absolute change
+func (c Change) Delta() int {
+	return c.Last - c.First
+}
+
+// Growth returns the percentage growth from the first to the last scan; ok
+// is false when the first count is zero
+func (c Change) Growth() (percent float64, ok bool) {
+	if c.First == 0 {
+		return 0, false
+	}
+	return float64(c.Last-c.First) / float64(c.First) * 100, true
+}
+
+// Trend is the growth of one provider's counts over its most recent scans
+type Trend struct {
+	Provider string
+	Scans    int
+	From     time.Time
+	To       time.Time
+
+	Resources Change
+	Accounts  Change
+
+	// Types holds every type counted in the first or last scan, largest
+	// absolute change first
+	Types []Change
+}
+
+// Compute returns one trend per provider over its last entries (all of them
+// when last is not positive), ordered by provider
+func Compute(entries []Entry, last int) []Trend {
+	byProvider := make(map[string][]Entry)
+	var providers []string
+	for _, entry := range entries {
+		key := strings.ToLower(entry.Provider)
+		if _, ok := byProvider[key]; !ok {
+			providers = append(providers, key)
+		}
+		byProvider[key] = append(byProvider[key], entry)
+	}
+	sort.Strings(providers)
+
+	trends := make([]Trend, 0, len(providers))
+	for _, provider := range providers {
+		scans := byProvider[provider]
+		sort.SliceStable(scans, func(i, j int) bool {
+			return scans[i].Timestamp.Before(scans[j].Timestamp)
+		})
+		if last > 0 && len(scans) > last {
+			scans = scans[len(scans)-last:]
+		}
+		trends = append(trends, compute(scans))
+	}
+	return trends
+}
+
+// Diff returns the trend between two scans of the same provider, such as
+// two result files
+func Diff(first, last Entry) Trend {
+	return compute([]Entry{first, last})
+}
+
+// compute builds the trend between the first and last of scans
+func compute(scans []Entry) Trend {
+	first, last := scans[0], scans[len(scans)-1]
+	trend := Trend{
+		Provider:  last.Provider,
+		Scans:     len(scans),
+		From:      first.Timestamp,
+		To:        last.Timestamp,
+		Resources: Change{Name: "Total resources", First: first.TotalResources, Last: last.TotalResources},
+		Accounts:  Change{Name: "Accounts/subscriptions", First: first.TotalAccounts, Last: last.TotalAccounts},
+	}
+
+	seen := make(map[string]bool)
+	for _, counts := range []map[string]int{first.Counts, last.Counts} {
+		for name := range counts {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			change := Change{Name: name, First: first.Counts[name], Last: last.Counts[name]}
+			if change.First != 0 || change.Last != 0 {
+				trend.Types = append(trend.Types, change)
+			}
+		}
+	}
+
+	sort.Slice(trend.Types, func(i, j int) bool {
+		di, dj := abs(trend.Types[i].Delta()), abs(trend.Types[j].Delta())
+		if di != dj {
+			return di > dj
+		}
+		return trend.Types[i].Name < trend.Types[j].Name
+	})
+	return trend
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,30 @@
+// Package identity sizes the directory of an identity provider: its users,
+// groups and applications, counted alongside the infrastructure scan
+package identity
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// ProviderOkta counts an Okta org through its management API
+	ProviderOkta = "okta"
+	// ProviderEntra counts the Entra ID tenant of an Azure scan through
+	// Microsoft Graph, with the scan's credential
+	ProviderEntra = "entra"
+
+	// Category is the category of every identity count
+	Category = "Identity"
+
+	// Timeout bounds the identity count so it never holds up the result
+	Timeout = 2 * time.Minute
+)
+
+// Providers are the identity providers --include-identity accepts
+var Providers = []string{ProviderOkta, ProviderEntra}
+
+// ValidProvider reports whether name is a supported identity provider
+func ValidProvider(name string) bool {
+	return slices.Contains(Providers, name)
+}
@@ -0,0 +1,306 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// OktaOrgURLEnv and OktaTokenEnv hold the Okta org and the API token
+	// it is counted with
+	OktaOrgURLEnv = "OKTA_ORG_URL"
+	OktaTokenEnv  = "OKTA_API_TOKEN"
+
+	oktaProvider = "Okta"
+	oktaService  = "Okta Management API"
+
+	// oktaLocation is the ByLocation key of org-wide objects
+	oktaLocation = "org"
+
+	// oktaPageSize is the largest page the users and apps endpoints return
+	oktaPageSize = 200
+
+	// oktaMaxRetries bounds the retries of a rate-limited page, and
+	// oktaMaxWait the wait for the rate limit to reset before each
+	oktaMaxRetries = 3
+	oktaMaxWait    = time.Minute
+)
+
+// oktaCollection is a directory object collection of the Okta management
+// API and the filter selecting the objects counted
+type oktaCollection struct {
+	def       models.ResourceDefinition
+	path      string
+	filter    string
+	operation string
+}
+
+// oktaObjects are the collections counted for an Okta org
+var oktaObjects = []oktaCollection{
+	{
+		def:       models.ResourceDefinition{Type: "okta:users", DisplayName: "Okta Users", Category: Category},
+		path:      "/api/v1/users",
+		filter:    `status eq "ACTIVE"`,
+		operation: "ListUsers",
+	},
+	{
+		def:       models.ResourceDefinition{Type: "okta:groups", DisplayName: "Okta Groups", Category: Category},
+		path:      "/api/v1/groups",
+		operation: "ListGroups",
+	},
+	{
+		def:       models.ResourceDefinition{Type: "okta:apps", DisplayName: "Okta Applications", Category: Category},
+		path:      "/api/v1/apps",
+		filter:    `status eq "ACTIVE"`,
+		operation: "ListApplications",
+	},
+}
+
+// PlanOkta describes how an Okta org is counted: its collections are paged
+// through, one call per page of 200
+func PlanOkta() []models.PlannedType {
+	planned := make([]models.PlannedType, 0, len(oktaObjects))
+	for _, collection := range oktaObjects {
+		planned = append(planned, models.PlannedType{
+			Type:           collection.def.ResourceType(),
+			DisplayName:    collection.def.DisplayName,
+			API:            "Okta GET " + collection.path,
+			EstimatedCalls: 1,
+		})
+	}
+	return planned
+}
+
+// OktaClient counts the active users, groups and active applications of an
+// Okta org with an API token
+type OktaClient struct {
+	org        *url.URL
+	token      string
+	httpClient *http.Client
+	apiCalls   *metrics.APICallCounter
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewOktaClient creates a client for the org at orgURL, such as
+// https://acme.okta.com, sending requests with httpClient (nil for the
+// default client) and counting them in apiCalls
+func NewOktaClient(orgURL, token string, httpClient *http.Client, apiCalls *metrics.APICallCounter) (*OktaClient, error) {
+	org, err := ParseOktaOrgURL(orgURL)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, fmt.Errorf("an Okta API token is required (%s)", OktaTokenEnv)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &OktaClient{
+		org:        org,
+		token:      token,
+		httpClient: httpClient,
+		apiCalls:   apiCalls,
+		now:        time.Now,
+		sleep:      sleep,
+	}, nil
+}
+
+// ParseOktaOrgURL checks that orgURL is the https base URL of an org
+func ParseOktaOrgURL(orgURL string) (*url.URL, error) {
+	org, err := url.Parse(strings.TrimSuffix(orgURL, "/"))
+	if err != nil || org.Scheme != "https" || org.Host == "" || org.Path != "" {
+		return nil, fmt.Errorf("invalid Okta org URL %q: want the https base URL of the org, such as https://acme.okta.com", orgURL)
+	}
+	return org, nil
+}
+
+// CountIdentities counts every collection of the org and returns the org as
+// a directory holding them. Collections that cannot be counted are skipped
+// and reported as warnings; without any count the directory is nil.
+func (c *OktaClient) CountIdentities(ctx context.Context) (*models.AccountCount, []*models.ResourceCount, []string) {
+	var counts []*models.ResourceCount
+	var warnings []string
+
+	directory := &models.AccountCount{
+		ID:     c.org.Host,
+		Name:   c.org.Host,
+		Status: "ACTIVE",
+		ByType: make(map[models.ResourceType]int),
+	}
+	for _, collection := range oktaObjects {
+		start := time.Now()
+		typeCtx, stats := metrics.StartTypeStats(ctx)
+		count, err := c.countObjects(typeCtx, collection)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to count identity objects",
+				zap.String("type", collection.def.Type),
+				zap.Error(err))
+			warnings = append(warnings, fmt.Sprintf("%s not counted: %v", collection.def.DisplayName, err))
+			continue
+		}
+		metrics.ResourceTypeScanned(ProviderOkta, collection.def.Type, time.Since(start))
+
+		counts = append(counts, &models.ResourceCount{
+			Provider:       oktaProvider,
+			Type:           collection.def.ResourceType(),
+			DisplayName:    collection.def.DisplayName,
+			CanonicalType:  collection.def.CanonicalType(),
+			Category:       collection.def.Category,
+			TotalResources: count,
+			ByLocation:     map[string]int{oktaLocation: count},
+			ByAccount:      make(map[string]int),
+			Stats:          stats.Stats(),
+		})
+		directory.ResourceCount += count
+		directory.ByType[collection.def.ResourceType()] = count
+	}
+
+	if len(counts) == 0 {
+		return nil, nil, warnings
+	}
+	return directory, counts, warnings
+}
+
+// countObjects pages through a collection; Okta has no count endpoint
+func (c *OktaClient) countObjects(ctx context.Context, collection oktaCollection) (int, error) {
+	query := url.Values{"limit": {strconv.Itoa(oktaPageSize)}}
+	if collection.filter != "" {
+		query.Set("filter", collection.filter)
+	}
+	next := c.org.JoinPath(collection.path)
+	next.RawQuery = query.Encode()
+
+	total := 0
+	for next != nil {
+		n, link, err := c.getPage(ctx, next, collection.operation)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+		next = link
+	}
+	return total, nil
+}
+
+// getPage fetches one page, waiting out rate limits, and returns its number
+// of objects and the next page, nil on the last
+func (c *OktaClient) getPage(ctx context.Context, page *url.URL, operation string) (int, *url.URL, error) {
+	stats := metrics.TypeStatsFrom(ctx)
+	for attempt := 0; ; attempt++ {
+		resp, err := c.get(ctx, page, operation)
+		if err != nil {
+			return 0, nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusTooManyRequests:
+			metrics.APIThrottled(ProviderOkta, oktaService)
+			if attempt == oktaMaxRetries {
+				return 0, nil, fmt.Errorf("rate limited after %d retries", oktaMaxRetries)
+			}
+			stats.AddRetry()
+			metrics.APIRetry(ProviderOkta, oktaService)
+			if err := c.sleep(ctx, c.rateLimitWait(resp.Header, attempt)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case http.StatusUnauthorized:
+			return 0, nil, fmt.Errorf("the API token was rejected (expired or revoked)")
+		case http.StatusForbidden:
+			return 0, nil, fmt.Errorf("access denied (requires a read-only administrator token)")
+		default:
+			return 0, nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+
+		var objects []json.RawMessage
+		if err := json.Unmarshal(body, &objects); err != nil {
+			return 0, nil, fmt.Errorf("invalid response: %w", err)
+		}
+		stats.AddPage()
+		next, err := c.nextPage(resp.Header)
+		if err != nil {
+			return 0, nil, err
+		}
+		return len(objects), next, nil
+	}
+}
+
+func (c *OktaClient) get(ctx context.Context, page *url.URL, operation string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, page.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "SSWS "+c.token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	c.apiCalls.Add(oktaService, operation)
+	metrics.APICall(ProviderOkta, oktaService)
+	return c.httpClient.Do(req)
+}
+
+// rateLimitWait is how long to wait before retrying a rate-limited request:
+// until X-Rate-Limit-Reset, the Unix time the limit resets, or with
+// exponential backoff without it
+func (c *OktaClient) rateLimitWait(header http.Header, attempt int) time.Duration {
+	wait := time.Second << attempt
+	if reset, err := strconv.ParseInt(header.Get("X-Rate-Limit-Reset"), 10, 64); err == nil {
+		wait = time.Unix(reset, 0).Sub(c.now()) + time.Second
+	}
+	return min(max(wait, time.Second), oktaMaxWait)
+}
+
+// nextPage returns the rel="next" link of a page, nil on the last. The
+// token is only ever sent to the org, so a link elsewhere is an error.
+func (c *OktaClient) nextPage(header http.Header) (*url.URL, error) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !strings.Contains(strings.ReplaceAll(params, " ", ""), `rel="next"`) {
+				continue
+			}
+			next, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid next page link: %w", err)
+			}
+			if next.Scheme != c.org.Scheme || next.Host != c.org.Host {
+				return nil, fmt.Errorf("next page link %s is outside the org", next.Redacted())
+			}
+			return next, nil
+		}
+	}
+	return nil, nil
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
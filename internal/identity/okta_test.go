@@ -0,0 +1,193 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+const testToken = "00abc"
+
+// fakeOkta serves each collection from pages of objects, linking one page
+// to the next. A status for a path replaces its pages.
+type fakeOkta struct {
+	pages    map[string][]int
+	status   map[string]int
+	requests []*http.Request
+}
+
+func (f *fakeOkta) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r)
+	if status, ok := f.status[r.URL.Path]; ok {
+		w.WriteHeader(status)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("after"))
+	pages := f.pages[r.URL.Path]
+	if page+1 < len(pages) {
+		next := *r.URL
+		next.Scheme, next.Host = "https", r.Host
+		query := next.Query()
+		query.Set("after", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="self"`, r.URL))
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+	objects := make([]string, 0, len(pages))
+	for i := 0; i < pages[page]; i++ {
+		objects = append(objects, fmt.Sprintf(`{"id":"%d"}`, i))
+	}
+	fmt.Fprintf(w, "[%s]", strings.Join(objects, ","))
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *OktaClient {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	client, err := NewOktaClient(server.URL, testToken, server.Client(), metrics.NewAPICallCounter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.sleep = func(context.Context, time.Duration) error { return nil }
+	return client
+}
+
+func TestCountIdentities(t *testing.T) {
+	fake := &fakeOkta{pages: map[string][]int{
+		"/api/v1/users":  {200, 200, 13},
+		"/api/v1/groups": {4},
+		"/api/v1/apps":   {7},
+	}}
+	client := newTestClient(t, fake)
+
+	directory, counts, warnings := client.CountIdentities(context.Background())
+	if len(warnings) > 0 {
+		t.Fatalf("warnings = %v", warnings)
+	}
+
+	want := map[models.ResourceType]int{"okta:users": 413, "okta:groups": 4, "okta:apps": 7}
+	for _, count := range counts {
+		if count.TotalResources != want[count.Type] || count.Category != Category {
+			t.Errorf("%s = %d in %q, want %d in %q", count.Type, count.TotalResources, count.Category, want[count.Type], Category)
+		}
+	}
+	if len(counts) != len(want) || directory.ResourceCount != 424 || directory.ByType["okta:users"] != 413 {
+		t.Errorf("directory = %+v, want the 424 objects of the 3 collections", directory)
+	}
+	if users := counts[0]; users.CanonicalType != models.ResourceTypeUser || users.Stats.Pages != 3 {
+		t.Errorf("users = %+v, want 3 pages of canonical users", users)
+	}
+
+	first := fake.requests[0]
+	if got := first.Header.Get("Authorization"); got != "SSWS "+testToken {
+		t.Errorf("Authorization = %q, want the SSWS token", got)
+	}
+	if got := first.URL.Query(); got.Get("filter") != `status eq "ACTIVE"` || got.Get("limit") != "200" {
+		t.Errorf("users query = %v, want active users in pages of 200", got)
+	}
+	if calls := client.apiCalls.Total(); calls != 5 {
+		t.Errorf("API calls = %d, want one per page", calls)
+	}
+}
+
+func TestCountIdentitiesWaitsOutRateLimits(t *testing.T) {
+	limited := 2
+	fake := &fakeOkta{pages: map[string][]int{"/api/v1/users": {3}, "/api/v1/groups": {1}, "/api/v1/apps": {1}}}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/users" && limited > 0 {
+			limited--
+			w.Header().Set("X-Rate-Limit-Reset", "1700000030")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	})
+	client := newTestClient(t, handler)
+	var waits []time.Duration
+	client.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	client.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	_, counts, warnings := client.CountIdentities(context.Background())
+	if len(warnings) > 0 || counts[0].TotalResources != 3 {
+		t.Fatalf("counts = %+v, warnings = %v, want the users counted after the retries", counts, warnings)
+	}
+	if len(waits) != 2 || waits[0] != 31*time.Second {
+		t.Errorf("waits = %v, want two waits of 31s until the reset", waits)
+	}
+	if counts[0].Stats.Retries != 2 {
+		t.Errorf("retries = %d, want 2", counts[0].Stats.Retries)
+	}
+}
+
+func TestCountIdentitiesGivesUpWhenStillRateLimited(t *testing.T) {
+	client := newTestClient(t, &fakeOkta{status: map[string]int{
+		"/api/v1/users":  http.StatusTooManyRequests,
+		"/api/v1/groups": http.StatusTooManyRequests,
+		"/api/v1/apps":   http.StatusTooManyRequests,
+	}})
+
+	directory, counts, warnings := client.CountIdentities(context.Background())
+	if directory != nil || len(counts) != 0 || len(warnings) != 3 {
+		t.Errorf("directory = %v, counts = %d, warnings = %v, want a warning per collection", directory, len(counts), warnings)
+	}
+}
+
+func TestCountIdentitiesSkipsDeniedCollections(t *testing.T) {
+	client := newTestClient(t, &fakeOkta{
+		pages:  map[string][]int{"/api/v1/users": {5}, "/api/v1/apps": {2}},
+		status: map[string]int{"/api/v1/groups": http.StatusForbidden},
+	})
+
+	directory, counts, warnings := client.CountIdentities(context.Background())
+	if len(counts) != 2 || directory.ResourceCount != 7 {
+		t.Errorf("counts = %d, directory = %+v, want users and apps", len(counts), directory)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Okta Groups not counted: access denied") {
+		t.Errorf("warnings = %v, want the groups denied", warnings)
+	}
+}
+
+func TestNextPageStaysInTheOrg(t *testing.T) {
+	client, err := NewOktaClient("https://acme.okta.com", testToken, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{"Link": {`<https://acme.okta.com/api/v1/users?limit=200>; rel="self", <https://acme.okta.com/api/v1/users?after=abc&limit=200>; rel="next"`}}
+	next, err := client.nextPage(header)
+	if err != nil || next.Query().Get("after") != "abc" {
+		t.Errorf("nextPage() = %v, %v, want the after=abc page", next, err)
+	}
+
+	if next, err := client.nextPage(http.Header{"Link": {`<https://acme.okta.com/api/v1/users>; rel="self"`}}); next != nil || err != nil {
+		t.Errorf("nextPage() = %v, %v, want nil on the last page", next, err)
+	}
+	if _, err := client.nextPage(http.Header{"Link": {`<https://evil.example.com/api/v1/users>; rel="next"`}}); err == nil {
+		t.Error("nextPage() followed a link outside the org")
+	}
+}
+
+func TestParseOktaOrgURL(t *testing.T) {
+	for url, valid := range map[string]bool{
+		"https://acme.okta.com":        true,
+		"https://acme.okta.com/":       true,
+		"http://acme.okta.com":         false,
+		"acme.okta.com":                false,
+		"https://acme.okta.com/api/v1": false,
+	} {
+		if _, err := ParseOktaOrgURL(url); (err == nil) != valid {
+			t.Errorf("ParseOktaOrgURL(%q) error = %v, want valid %v", url, err, valid)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/sink"
+)
+
+// Supported inventory formats
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+var csvHeader = []string{"id", "name", "type", "provider", "region", "account", "status", "tags"}
+
+// Writer streams resources to a file as they are discovered. It is safe for
+// concurrent use by multiple collectors.
+type Writer struct {
+	mu      sync.Mutex
+	file    io.WriteCloser
+	buf     *bufio.Writer
+	format  string
+	encoder *json.Encoder
+	csv     *csv.Writer
+	count   int
+	closed  bool
+}
+
+// NewWriter creates the inventory file at path in the given format,
+// compressed and encrypted as options say; the file is at options.Path(path)
+func NewWriter(path, format string, options sink.Options) (*Writer, error) {
+	if format != FormatNDJSON && format != FormatCSV {
+		return nil, fmt.Errorf("unsupported inventory format: %s", format)
+	}
+
+	file, err := sink.Create(path, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inventory file: %w", err)
+	}
+
+	w := &Writer{
+		file:   file,
+		buf:    bufio.NewWriter(file),
+		format: format,
+	}
+
+	switch format {
+	case FormatNDJSON:
+		w.encoder = json.NewEncoder(w.buf)
+	case FormatCSV:
+		w.csv = csv.NewWriter(w.buf)
+		if err := w.csv.Write(csvHeader); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to write inventory header: %w", err)
+		}
+	}
+
+	return w, nil
+}
+
+// Write appends a single resource to the inventory
+func (w *Writer) Write(resource models.Resource) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	switch w.format {
+	case FormatNDJSON:
+		err = w.encoder.Encode(resource)
+	case FormatCSV:
+		err = w.csv.Write([]string{
+			resource.ID,
+			resource.Name,
+			string(resource.Type),
+			resource.Provider,
+			resource.Region,
+			resource.Account,
+			resource.Status,
+			formatTags(resource.Tags),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write inventory record: %w", err)
+	}
+
+	w.count++
+	return nil
+}
+
+// Count returns the number of resources written so far
+func (w *Writer) Count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Close flushes buffered records and closes the file. Closing an already
+// closed writer is a no-op.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			_ = w.file.Close()
+			return fmt.Errorf("failed to flush inventory: %w", err)
+		}
+	}
+	if err := w.buf.Flush(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("failed to flush inventory: %w", err)
+	}
+	return w.file.Close()
+}
+
+// formatTags renders tags as sorted key=value pairs separated by semicolons
+func formatTags(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
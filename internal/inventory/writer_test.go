@@ -0,0 +1,151 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/sink"
+)
+
+var testResources = []models.Resource{
+	{
+		ID:       "arn:aws:s3:::bucket-a",
+		Name:     "bucket-a",
+		Type:     "s3:bucket",
+		Provider: "AWS",
+		Region:   "us-east-1",
+		Account:  "123456789012",
+		Tags:     map[string]string{"env": "prod", "app": "web"},
+	},
+	{
+		ID:       "/subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm1",
+		Name:     "vm1",
+		Type:     "microsoft.compute/virtualmachines",
+		Provider: "Azure",
+		Region:   "eastus",
+		Account:  "sub-1",
+	},
+}
+
+func TestWriterNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.ndjson")
+	w, err := NewWriter(path, FormatNDJSON, sink.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range testResources {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.Count() != len(testResources) {
+		t.Errorf("Count() = %d, want %d", w.Count(), len(testResources))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var got []models.Resource
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r models.Resource
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, r)
+	}
+	if len(got) != len(testResources) || got[0].Tags["env"] != "prod" || got[1].Account != "sub-1" {
+		t.Errorf("unexpected records: %+v", got)
+	}
+}
+
+func TestWriterCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.csv")
+	w, err := NewWriter(path, FormatCSV, sink.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range testResources {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records = %d, want 3 (header + 2)", len(records))
+	}
+	if records[1][7] != "app=web;env=prod" {
+		t.Errorf("tags column = %q", records[1][7])
+	}
+}
+
+func TestNewWriterInvalidFormat(t *testing.T) {
+	if _, err := NewWriter(filepath.Join(t.TempDir(), "x"), "xml", sink.Options{}); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
+
+func TestWriterCompressedEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.ndjson")
+	options := sink.Options{Compress: true, Passphrase: "secret"}
+	w, err := NewWriter(path, FormatNDJSON, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range testResources {
+		if err := w.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	in, _, err := sink.Open(options.Path(path), options.Passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	scanner := bufio.NewScanner(in)
+	lines := 0
+	for scanner.Scan() {
+		var r models.Resource
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if lines != len(testResources) {
+		t.Errorf("lines = %d, want %d", lines, len(testResources))
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// APICallCounter counts the API operations of one scan per service and
+// operation. Unlike the Recorder it is scoped to a scan, so its counts can be
+// reported with the result. A nil counter discards everything.
+type APICallCounter struct {
+	mu     sync.Mutex
+	counts map[apiOperation]int
+}
+
+type apiOperation struct {
+	service   string
+	operation string
+}
+
+// NewAPICallCounter returns an empty counter
+func NewAPICallCounter() *APICallCounter {
+	return &APICallCounter{counts: make(map[apiOperation]int)}
+}
+
+// Add counts one call of operation on service
+func (c *APICallCounter) Add(service, operation string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[apiOperation{service: service, operation: operation}]++
+}
+
+// Counts returns the calls made so far, sorted by service and operation
+func (c *APICallCounter) Counts() []models.APICallCount {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make([]models.APICallCount, 0, len(c.counts))
+	for op, count := range c.counts {
+		counts = append(counts, models.APICallCount{Service: op.service, Operation: op.operation, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Service != counts[j].Service {
+			return counts[i].Service < counts[j].Service
+		}
+		return counts[i].Operation < counts[j].Operation
+	})
+	return counts
+}
+
+// Total returns the number of calls made so far
+func (c *APICallCounter) Total() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, count := range c.counts {
+		total += count
+	}
+	return total
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestAPICallCounter(t *testing.T) {
+	calls := NewAPICallCounter()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			calls.Add("STS", "GetCallerIdentity")
+			calls.Add("EC2", "DescribeRegions")
+		}()
+	}
+	wg.Wait()
+	calls.Add("EC2", "DescribeInstances")
+
+	want := []models.APICallCount{
+		{Service: "EC2", Operation: "DescribeInstances", Count: 1},
+		{Service: "EC2", Operation: "DescribeRegions", Count: 10},
+		{Service: "STS", Operation: "GetCallerIdentity", Count: 10},
+	}
+	if got := calls.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %+v, want %+v", got, want)
+	}
+	if got := calls.Total(); got != 21 {
+		t.Errorf("Total() = %d, want 21", got)
+	}
+
+	// A nil counter discards calls
+	var none *APICallCounter
+	none.Add("EC2", "DescribeRegions")
+	if none.Counts() != nil || none.Total() != 0 {
+		t.Error("nil counter recorded a call")
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import "time"
+
+// Recorder receives scan instrumentation
+type Recorder interface {
+	// ResourceTypeScanned records that a resource type was counted and how long
+	// counting took
+	ResourceTypeScanned(provider, resourceType string, duration time.Duration)
+
+	// APICall records a cloud API operation; APIRetry each further attempt of
+	// it and APIThrottled each attempt rejected for throttling
+	APICall(provider, service string)
+	APIRetry(provider, service string)
+	APIThrottled(provider, service string)
+
+	// ResourcesFound records the resources found for an account, or for the
+	// whole scan when account is AllAccounts
+	ResourcesFound(provider, account string, count int)
+
+	// ScanCompleted records the duration of a complete scan
+	ScanCompleted(provider string, duration time.Duration)
+}
+
+// AllAccounts is the account label used for provider-wide totals
+const AllAccounts = "all"
+
+// Nop discards everything
+type Nop struct{}
+
+func (Nop) ResourceTypeScanned(string, string, time.Duration) {}
+func (Nop) APICall(string, string)                            {}
+func (Nop) APIRetry(string, string)                           {}
+func (Nop) APIThrottled(string, string)                       {}
+func (Nop) ResourcesFound(string, string, int)                {}
+func (Nop) ScanCompleted(string, time.Duration)               {}
+
+// recorder is used by the package-level functions. It defaults to Nop so
+// instrumented code costs nothing when metrics are disabled.
+var recorder Recorder = Nop{}
+
+// SetRecorder replaces the recorder used by the package-level functions. It
+// must be called before scanning starts.
+func SetRecorder(r Recorder) {
+	if r == nil {
+		r = Nop{}
+	}
+	recorder = r
+}
+
+// ResourceTypeScanned records a counted resource type on the current recorder
+func ResourceTypeScanned(provider, resourceType string, duration time.Duration) {
+	recorder.ResourceTypeScanned(provider, resourceType, duration)
+}
+
+// APICall records an API operation on the current recorder
+func APICall(provider, service string) {
+	recorder.APICall(provider, service)
+}
+
+// APIRetry records a retried API attempt on the current recorder
+func APIRetry(provider, service string) {
+	recorder.APIRetry(provider, service)
+}
+
+// APIThrottled records a throttled API attempt on the current recorder
+func APIThrottled(provider, service string) {
+	recorder.APIThrottled(provider, service)
+}
+
+// ResourcesFound records a resource total on the current recorder
+func ResourcesFound(provider, account string, count int) {
+	recorder.ResourcesFound(provider, account, count)
+}
+
+// ScanCompleted records a scan duration on the current recorder
+func ScanCompleted(provider string, duration time.Duration) {
+	recorder.ScanCompleted(provider, duration)
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// gatheredFamilies returns the names of the metric families in gatherer
+func gatheredFamilies(t *testing.T, gatherer prometheus.Gatherer) map[string]bool {
+	t.Helper()
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool, len(families))
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	return names
+}
+
+func TestPrometheusFamilies(t *testing.T) {
+	p := NewPrometheus()
+	SetRecorder(p)
+	defer SetRecorder(nil)
+
+	// A fake scan through the package-level functions
+	APICall("aws", "EC2")
+	APICall("aws", "EC2")
+	APIRetry("aws", "EC2")
+	APIThrottled("aws", "EC2")
+	ResourceTypeScanned("aws", "ec2:instance", 2*time.Second)
+	ResourceTypeScanned("aws", "s3:bucket", time.Second)
+	ResourcesFound("aws", AllAccounts, 42)
+	ResourcesFound("aws", "123456789012", 42)
+	ScanCompleted("aws", time.Minute)
+
+	names := gatheredFamilies(t, p.Gatherer())
+	for _, want := range []string{
+		"secrails_sizing_resource_types_scanned_total",
+		"secrails_sizing_resource_type_duration_seconds",
+		"secrails_sizing_api_calls_total",
+		"secrails_sizing_api_retries_total",
+		"secrails_sizing_api_throttles_total",
+		"secrails_sizing_resources",
+		"secrails_sizing_scan_duration_seconds",
+	} {
+		if !names[want] {
+			t.Errorf("metric family %s not gathered", want)
+		}
+	}
+
+	if got := testutil.ToFloat64(p.apiCalls.WithLabelValues("aws", "EC2")); got != 2 {
+		t.Errorf("api_calls_total = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(p.typesScanned.WithLabelValues("aws")); got != 2 {
+		t.Errorf("resource_types_scanned_total = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(p.resources.WithLabelValues("aws", AllAccounts)); got != 42 {
+		t.Errorf("resources = %v, want 42", got)
+	}
+}
+
+func TestNopDefault(t *testing.T) {
+	if _, ok := recorder.(Nop); !ok {
+		t.Fatalf("default recorder = %T, want Nop", recorder)
+	}
+	// Must not panic without a recorder
+	APICall("azure", "microsoft.resourcegraph")
+	ScanCompleted("azure", time.Second)
+}
+
+func TestServerWaitsForScrapeAfterScan(t *testing.T) {
+	p := NewPrometheus()
+	p.ScanCompleted("aws", time.Minute)
+
+	server, err := Listen("127.0.0.1:0", p.Gatherer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scrape := func() string {
+		resp, err := http.Get("http://" + server.Addr() + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// A scrape during the scan does not release Wait
+	if body := scrape(); body == "" {
+		t.Fatal("empty scrape")
+	}
+
+	done := make(chan bool)
+	go func() { done <- server.Wait(context.Background(), 5*time.Second) }()
+
+	// Poll until Wait has marked the scan finished and a scrape releases it
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case scraped := <-done:
+			if !scraped {
+				t.Fatal("Wait() = false, want a scrape")
+			}
+			return
+		case <-deadline:
+			t.Fatal("Wait() did not return after a scrape")
+		case <-time.After(20 * time.Millisecond):
+			if resp, err := http.Get("http://" + server.Addr() + "/metrics"); err == nil {
+				_ = resp.Body.Close()
+			}
+		}
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	server, err := Listen("127.0.0.1:0", NewPrometheus().Gatherer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Wait(context.Background(), 10*time.Millisecond) {
+		t.Error("Wait() = true without any scrape")
+	}
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// namespace prefixes every metric name
+const namespace = "secrails_sizing"
+
+// pushJob is the Pushgateway job label
+const pushJob = "secrails-sizing-agent"
+
+// Prometheus records scan metrics in a dedicated registry
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	typesScanned *prometheus.CounterVec
+	typeDuration *prometheus.HistogramVec
+	apiCalls     *prometheus.CounterVec
+	apiRetries   *prometheus.CounterVec
+	apiThrottles *prometheus.CounterVec
+	resources    *prometheus.GaugeVec
+	scanDuration *prometheus.GaugeVec
+}
+
+// NewPrometheus creates a recorder with all metric families registered
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		typesScanned: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "resource_types_scanned_total",
+			Help:      "Resource types counted.",
+		}, []string{"provider"}),
+		typeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "resource_type_duration_seconds",
+			Help:      "Time taken to count one resource type across all regions or subscriptions.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+		}, []string{"provider", "type"}),
+		apiCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_calls_total",
+			Help:      "Cloud API operations issued, excluding retries.",
+		}, []string{"provider", "service"}),
+		apiRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_retries_total",
+			Help:      "Cloud API attempts beyond the first.",
+		}, []string{"provider", "service"}),
+		apiThrottles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "api_throttles_total",
+			Help:      "Cloud API attempts rejected for throttling.",
+		}, []string{"provider", "service"}),
+		resources: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "resources",
+			Help:      `Resources found, per account or subscription and in total (account="all").`,
+		}, []string{"provider", "account"}),
+		scanDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "scan_duration_seconds",
+			Help:      "Duration of the last complete scan.",
+		}, []string{"provider"}),
+	}
+
+	p.registry.MustRegister(
+		p.typesScanned,
+		p.typeDuration,
+		p.apiCalls,
+		p.apiRetries,
+		p.apiThrottles,
+		p.resources,
+		p.scanDuration,
+	)
+	return p
+}
+
+// Gatherer returns the registry holding the scan metrics
+func (p *Prometheus) Gatherer() prometheus.Gatherer {
+	return p.registry
+}
+
+func (p *Prometheus) ResourceTypeScanned(provider, resourceType string, duration time.Duration) {
+	p.typesScanned.WithLabelValues(provider).Inc()
+	p.typeDuration.WithLabelValues(provider, resourceType).Observe(duration.Seconds())
+}
+
+func (p *Prometheus) APICall(provider, service string) {
+	p.apiCalls.WithLabelValues(provider, service).Inc()
+}
+
+func (p *Prometheus) APIRetry(provider, service string) {
+	p.apiRetries.WithLabelValues(provider, service).Inc()
+}
+
+func (p *Prometheus) APIThrottled(provider, service string) {
+	p.apiThrottles.WithLabelValues(provider, service).Inc()
+}
+
+func (p *Prometheus) ResourcesFound(provider, account string, count int) {
+	p.resources.WithLabelValues(provider, account).Set(float64(count))
+}
+
+func (p *Prometheus) ScanCompleted(provider string, duration time.Duration) {
+	p.scanDuration.WithLabelValues(provider).Set(duration.Seconds())
+}
+
+// Push sends the metrics to a Prometheus Pushgateway, replacing earlier
+// pushes of the same job
+func (p *Prometheus) Push(ctx context.Context, url string) error {
+	if err := push.New(url, pushJob).Gatherer(p.registry).PushContext(ctx); err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	return nil
+}
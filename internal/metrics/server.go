@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Server serves /metrics for the duration of a run. Scrapes during the scan
+// see partial values; Wait keeps the endpoint open after the scan until it
+// has been scraped once more.
+type Server struct {
+	server   *http.Server
+	listener net.Listener
+
+	mu       sync.Mutex
+	finished bool
+	scraped  chan struct{}
+}
+
+// Listen starts serving gatherer on addr, e.g. ":9090"
+func Listen(addr string, gatherer prometheus.Gatherer) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics scrapes: %w", err)
+	}
+
+	s := &Server{listener: listener, scraped: make(chan struct{})}
+	handler := promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+		s.markScraped()
+	})
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() { _ = s.server.Serve(listener) }()
+	return s, nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// markScraped signals Wait once a scrape completes after the scan finished
+func (s *Server) markScraped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.finished {
+		return
+	}
+	select {
+	case <-s.scraped:
+	default:
+		close(s.scraped)
+	}
+}
+
+// Wait marks the scan as finished, waits until the final metrics are scraped
+// or timeout elapses, and shuts the server down. It reports whether a scrape
+// happened.
+func (s *Server) Wait(ctx context.Context, timeout time.Duration) bool {
+	s.mu.Lock()
+	s.finished = true
+	s.mu.Unlock()
+
+	scraped := false
+	select {
+	case <-s.scraped:
+		scraped = true
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+
+	s.Close()
+	return scraped
+}
+
+// Close shuts the server down, releasing its address. It may be called more
+// than once, and after Wait.
+func (s *Server) Close() {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		_ = s.server.Close()
+	}
+	// Shutdown misses the listener when Serve has not started yet
+	_ = s.listener.Close()
+}
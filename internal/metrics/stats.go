@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// typeStatsKey holds the TypeStats of the resource type being counted in a
+// request context
+type typeStatsKey struct{}
+
+// TypeStats counts the API calls and retries made while counting one
+// resource type. The SDK middleware finds it in the request context; a nil
+// TypeStats discards everything.
+type TypeStats struct {
+	start   time.Time
+	pages   atomic.Int64
+	retries atomic.Int64
+}
+
+// StartTypeStats starts timing a resource type and returns ctx carrying its
+// stats for the API calls made with it
+func StartTypeStats(ctx context.Context) (context.Context, *TypeStats) {
+	stats := &TypeStats{start: time.Now()}
+	return context.WithValue(ctx, typeStatsKey{}, stats), stats
+}
+
+// TypeStatsFrom returns the stats carried by ctx, or nil
+func TypeStatsFrom(ctx context.Context) *TypeStats {
+	stats, _ := ctx.Value(typeStatsKey{}).(*TypeStats)
+	return stats
+}
+
+// AddPage counts one API call, i.e. one page of results
+func (s *TypeStats) AddPage() {
+	if s != nil {
+		s.pages.Add(1)
+	}
+}
+
+// AddRetry counts one retried attempt
+func (s *TypeStats) AddRetry() {
+	if s != nil {
+		s.retries.Add(1)
+	}
+}
+
+// Stats returns the counts so far and the time since the start
+func (s *TypeStats) Stats() *models.ScanStats {
+	if s == nil {
+		return nil
+	}
+	return &models.ScanStats{
+		Start:      s.start.UTC().Truncate(time.Millisecond),
+		DurationMs: time.Since(s.start).Milliseconds(),
+		Pages:      s.pages.Load(),
+		Retries:    s.retries.Load(),
+	}
+}
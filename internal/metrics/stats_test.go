@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestTypeStats(t *testing.T) {
+	ctx, stats := StartTypeStats(context.Background())
+	if TypeStatsFrom(ctx) != stats {
+		t.Fatal("TypeStatsFrom() did not return the started stats")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			TypeStatsFrom(ctx).AddPage()
+		}()
+	}
+	wg.Wait()
+	TypeStatsFrom(ctx).AddRetry()
+
+	got := stats.Stats()
+	if got.Pages != 10 || got.Retries != 1 || got.Start.IsZero() || got.DurationMs < 0 {
+		t.Errorf("Stats() = %+v, want 10 pages and 1 retry", got)
+	}
+
+	// Contexts without stats discard the counts
+	TypeStatsFrom(context.Background()).AddPage()
+	if TypeStatsFrom(context.Background()).Stats() != nil {
+		t.Error("nil TypeStats returned stats")
+	}
+}
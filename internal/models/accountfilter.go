@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrAccountsExcluded is returned by a provider whose account filter leaves
+// nothing to scan; a multi-profile or multi-tenant scan skips the target
+var ErrAccountsExcluded = errors.New("the account filters exclude every account to scan")
+
+// AccountFilter keeps the discovered accounts or subscriptions whose name
+// matches one of Names, glob patterns such as "prod-*", and that carry all
+// of Tags. An empty filter keeps every account.
+type AccountFilter struct {
+	Names []string    `json:"names,omitempty"`
+	Tags  []TagFilter `json:"tags,omitempty"`
+}
+
+// ValidateAccountPattern checks that pattern is a valid --account-filter
+// glob: * matches any run of characters, ? one character and [...] a class
+func ValidateAccountPattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("empty account filter")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid account filter %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// Empty reports whether f keeps every account
+func (f AccountFilter) Empty() bool {
+	return len(f.Names) == 0 && len(f.Tags) == 0
+}
+
+// Match reports whether f keeps account. Names match case-insensitively, as
+// do tag keys; tag values must match exactly, as with --tag.
+func (f AccountFilter) Match(account AccountCount) bool {
+	if len(f.Names) > 0 && !f.matchName(account.Name) {
+		return false
+	}
+	for _, filter := range f.Tags {
+		if !hasTag(account.Tags, filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f AccountFilter) matchName(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range f.Names {
+		// Patterns are validated when parsed; a bad one matches nothing
+		if matched, _ := path.Match(strings.ToLower(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTag reports whether tags carry filter, with the key in any case
+func hasTag(tags map[string]string, filter TagFilter) bool {
+	for key, value := range tags {
+		if strings.EqualFold(key, filter.Key) && value == filter.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply splits accounts into those f keeps and those it excludes, both in
+// their discovery order
+func (f AccountFilter) Apply(accounts []AccountCount) (kept, excluded []AccountCount) {
+	if f.Empty() {
+		return accounts, nil
+	}
+	for _, account := range accounts {
+		if f.Match(account) {
+			kept = append(kept, account)
+		} else {
+			excluded = append(excluded, account)
+		}
+	}
+	return kept, excluded
+}
+
+// String renders f as the flags that set it, e.g.
+// "--account-filter prod-* --account-tag env=prod"
+func (f AccountFilter) String() string {
+	var flags []string
+	for _, name := range f.Names {
+		flags = append(flags, "--account-filter "+name)
+	}
+	for _, tag := range f.Tags {
+		flags = append(flags, "--account-tag "+tag.String())
+	}
+	return strings.Join(flags, " ")
+}
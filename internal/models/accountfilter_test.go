@@ -0,0 +1,67 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccountFilterMatch(t *testing.T) {
+	prod := AccountCount{ID: "1", Name: "Prod-Payments", Tags: map[string]string{"Env": "prod", "team": "payments"}}
+	tests := []struct {
+		name   string
+		filter AccountFilter
+		want   bool
+	}{
+		{name: "empty", filter: AccountFilter{}, want: true},
+		{name: "name glob in any case", filter: AccountFilter{Names: []string{"prod-*"}}, want: true},
+		{name: "any name may match", filter: AccountFilter{Names: []string{"dev-*", "*-payments"}}, want: true},
+		{name: "name mismatch", filter: AccountFilter{Names: []string{"dev-*"}}, want: false},
+		{name: "character class", filter: AccountFilter{Names: []string{"[pq]rod-?ayments"}}, want: true},
+		{name: "tag key in any case", filter: AccountFilter{Tags: []TagFilter{{Key: "env", Value: "prod"}}}, want: true},
+		{name: "tag value is exact", filter: AccountFilter{Tags: []TagFilter{{Key: "env", Value: "Prod"}}}, want: false},
+		{name: "all tags must match", filter: AccountFilter{Tags: []TagFilter{{Key: "env", Value: "prod"}, {Key: "team", Value: "data"}}}, want: false},
+		{
+			name:   "name and tags",
+			filter: AccountFilter{Names: []string{"prod-*"}, Tags: []TagFilter{{Key: "team", Value: "payments"}}},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(prod); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountFilterApply(t *testing.T) {
+	accounts := []AccountCount{{ID: "1", Name: "prod-a"}, {ID: "2", Name: "dev-a"}, {ID: "3", Name: "prod-b"}}
+
+	kept, excluded := AccountFilter{Names: []string{"prod-*"}}.Apply(accounts)
+	if want := []AccountCount{accounts[0], accounts[2]}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept = %v, want %v", kept, want)
+	}
+	if want := []AccountCount{accounts[1]}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("excluded = %v, want %v", excluded, want)
+	}
+
+	if kept, excluded := (AccountFilter{}).Apply(accounts); len(kept) != 3 || excluded != nil {
+		t.Errorf("empty filter kept %d and excluded %d accounts, want all kept", len(kept), len(excluded))
+	}
+}
+
+func TestValidateAccountPattern(t *testing.T) {
+	for pattern, valid := range map[string]bool{"prod-*": true, "team-[ab]": true, "prod-[": false, " ": false} {
+		if err := ValidateAccountPattern(pattern); (err == nil) != valid {
+			t.Errorf("ValidateAccountPattern(%q) = %v, want valid %v", pattern, err, valid)
+		}
+	}
+}
+
+func TestAccountFilterString(t *testing.T) {
+	filter := AccountFilter{Names: []string{"prod-*"}, Tags: []TagFilter{{Key: "env", Value: "prod"}}}
+	if got, want := filter.String(), "--account-filter prod-* --account-tag env=prod"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+// AccountSource says how the accounts of an AccountList were found
+type AccountSource string
+
+const (
+	// AccountSourceOrganizations is the account list of an AWS organization
+	AccountSourceOrganizations AccountSource = "organizations"
+	// AccountSourceCurrentAccount is the caller's AWS account alone, used
+	// outside an organization or when its accounts cannot be listed
+	AccountSourceCurrentAccount AccountSource = "current_account"
+	// AccountSourceSubscriptions is the Azure subscription list of a tenant
+	AccountSourceSubscriptions AccountSource = "subscriptions"
+	// AccountSourceKubeconfig is the clusters of the selected kubeconfig
+	// contexts
+	AccountSourceKubeconfig AccountSource = "kubeconfig"
+	// AccountSourceCompartments is the compartment tree of an OCI tenancy
+	AccountSourceCompartments AccountSource = "compartments"
+)
+
+// AccountList is the outcome of account/subscription discovery alone,
+// without any resource counting
+type AccountList struct {
+	Provider string        `json:"provider"`
+	Source   AccountSource `json:"source"`
+
+	// Cached is set when the list was reused from the discovery cache
+	Cached bool `json:"cached,omitempty"`
+
+	// TenantID is the Azure tenant the subscriptions belong to
+	TenantID string          `json:"tenant_id,omitempty"`
+	Accounts []ListedAccount `json:"accounts"`
+}
+
+// ListedAccount is one discovered account or subscription. Status is the
+// Organizations account state on AWS and the subscription state on Azure.
+type ListedAccount struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+// NewAccountList builds the list of the discovered accounts
+func NewAccountList(provider string, source AccountSource, accounts []AccountCount) *AccountList {
+	list := &AccountList{Provider: provider, Source: source, Accounts: []ListedAccount{}}
+	for _, account := range accounts {
+		list.Accounts = append(list.Accounts, ListedAccount{
+			ID:     account.ID,
+			Name:   account.Name,
+			Email:  account.Email,
+			Status: account.Status,
+		})
+	}
+	return list
+}
@@ -0,0 +1,30 @@
+package models
+
+// BillableTypes returns the resource types of the definitions marked billable
+func BillableTypes(definitions []ResourceDefinition) []ResourceType {
+	var types []ResourceType
+	for _, def := range definitions {
+		if def.Billable {
+			types = append(types, def.ResourceType())
+		}
+	}
+	return types
+}
+
+// CountBillable sums the resources of the counts whose type is one of types.
+// Billable types the scan did not count, e.g. removed by a definitions
+// override, add nothing.
+func CountBillable(counts []*ResourceCount, types []ResourceType) int {
+	billable := make(map[ResourceType]bool, len(types))
+	for _, t := range types {
+		billable[t] = true
+	}
+
+	total := 0
+	for _, rc := range counts {
+		if billable[rc.Type] {
+			total += rc.TotalResources
+		}
+	}
+	return total
+}
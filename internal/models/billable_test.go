@@ -0,0 +1,112 @@
+package models
+
+import "testing"
+
+func TestBillableTypes(t *testing.T) {
+	defaults, err := DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for provider, want := range map[string][]ResourceType{
+		"aws":   {"ec2:instance", "lambda:function", "s3:bucket", "rds:db#aurora"},
+		"azure": {"microsoft.compute/virtualmachines", "microsoft.web/sites#functionapp", "microsoft.storage/storageaccounts"},
+	} {
+		types := BillableTypes(defaults.ForProvider(provider))
+		for _, w := range want {
+			if !containsType(types, w) {
+				t.Errorf("BillableTypes(%s) = %v, missing %s", provider, types, w)
+			}
+		}
+	}
+
+	// Supporting resources are not workloads
+	if types := BillableTypes(defaults.ForProvider("aws")); containsType(types, "ec2:security-group") {
+		t.Errorf("BillableTypes(aws) includes ec2:security-group")
+	}
+}
+
+func TestCountBillable(t *testing.T) {
+	counts := []*ResourceCount{
+		{Type: "ec2:instance", TotalResources: 10},
+		{Type: "lambda:function", TotalResources: 25},
+		{Type: "s3:bucket", TotalResources: 4},
+		{Type: "ec2:security-group", TotalResources: 40},
+	}
+
+	tests := []struct {
+		name   string
+		counts []*ResourceCount
+		types  []ResourceType
+		want   int
+	}{
+		{
+			name:   "billable types only",
+			counts: counts,
+			types:  []ResourceType{"ec2:instance", "lambda:function", "s3:bucket"},
+			want:   39,
+		},
+		{
+			name:   "override narrows the set",
+			counts: counts,
+			types:  []ResourceType{"ec2:instance"},
+			want:   10,
+		},
+		{
+			// rds:db was removed from the scan, so it adds nothing
+			name:   "billable type filtered out of the scan",
+			counts: counts,
+			types:  []ResourceType{"ec2:instance", "rds:db"},
+			want:   10,
+		},
+		{
+			name:   "every billable type filtered out",
+			counts: counts[3:],
+			types:  []ResourceType{"ec2:instance", "lambda:function"},
+			want:   0,
+		},
+		{
+			name:   "no billable types",
+			counts: counts,
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountBillable(tt.counts, tt.types); got != tt.want {
+				t.Errorf("CountBillable() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBillableTypesAfterOverride(t *testing.T) {
+	set, err := DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.merge("aws", []ResourceDefinition{
+		{Type: "lambda:function", Disabled: true},
+		{Type: "s3:bucket", DisplayName: "S3 Buckets", Category: "Storage", CountMethod: CountMethodTaggingAPI},
+	})
+
+	types := BillableTypes(set.ForProvider("aws"))
+	for _, removed := range []ResourceType{"lambda:function", "s3:bucket"} {
+		if containsType(types, removed) {
+			t.Errorf("BillableTypes() = %v, want %s dropped by the override", types, removed)
+		}
+	}
+	if !containsType(types, "ec2:instance") {
+		t.Errorf("BillableTypes() = %v, want ec2:instance kept", types)
+	}
+}
+
+func containsType(types []ResourceType, t ResourceType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
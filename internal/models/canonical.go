@@ -0,0 +1,441 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// canonicalTypes maps the provider-native type of each built-in definition,
+// by its ResourceType (the key for definitions sharing a type), to its
+// canonical type
+var canonicalTypes = map[ResourceType]ResourceType{
+	// AWS
+	"ec2:instance":                       ResourceTypeVirtualMachine,
+	"lambda:function":                    ResourceTypeFunction,
+	"ecs:cluster":                        ResourceTypeContainerCluster,
+	"ecs:service":                        ResourceTypeContainerService,
+	"ecs:task":                           ResourceTypeContainerInstance,
+	"autoscaling:autoScalingGroup":       ResourceTypeScaleSet,
+	"eks:cluster":                        ResourceTypeKubernetesCluster,
+	"eks:nodegroup":                      ResourceTypeKubernetesNodePool,
+	"eks:fargateprofile":                 ResourceTypeKubernetesServerless,
+	"eks:node":                           ResourceTypeKubernetesNode,
+	"ecr:repository":                     ResourceTypeContainerRepository,
+	"sqs:queue":                          ResourceTypeMessageQueue,
+	"sns:topic":                          ResourceTypePubSubTopic,
+	"kinesis:stream":                     ResourceTypeDataStream,
+	"firehose:deliverystream":            ResourceTypeDataDeliveryStream,
+	"glue:job":                           ResourceTypeETLJob,
+	"glue:crawler":                       ResourceTypeDataCrawler,
+	"athena:workgroup":                   ResourceTypeQueryWorkgroup,
+	"elasticmapreduce:cluster":           ResourceTypeBigDataCluster,
+	"kafka:cluster":                      ResourceTypeKafkaCluster,
+	"es:domain":                          ResourceTypeSearchService,
+	"cloudwatch:alarm":                   ResourceTypeMetricAlarm,
+	"logs:log-group":                     ResourceTypeLogGroup,
+	"events:event-bus":                   ResourceTypeEventBus,
+	"events:rule":                        ResourceTypeEventRule,
+	"ssm:managed-instance":               ResourceTypeHybridServer,
+	"batch:compute-environment":          ResourceTypeBatchEnvironment,
+	"batch:job-queue":                    ResourceTypeBatchJobQueue,
+	"apprunner:service":                  ResourceTypeWebApp,
+	"elasticbeanstalk:application":       ResourceTypeApplication,
+	"elasticbeanstalk:environment":       ResourceTypeWebApp,
+	"amplify:apps":                       ResourceTypeWebApp,
+	"iam:user":                           ResourceTypeUser,
+	"iam:role":                           ResourceTypeRole,
+	"iam:group":                          ResourceTypeGroup,
+	"iam:policy":                         ResourceTypePolicy,
+	"states:stateMachine":                ResourceTypeWorkflow,
+	"codecommit:repository":              ResourceTypeCodeRepository,
+	"codebuild:project":                  ResourceTypeBuildProject,
+	"codedeploy:application":             ResourceTypeDeployment,
+	"codepipeline:pipeline":              ResourceTypePipeline,
+	"cloudformation:stack":               ResourceTypeInfrastructureStack,
+	"sagemaker:notebook-instance":        ResourceTypeMLNotebook,
+	"sagemaker:endpoint":                 ResourceTypeMLEndpoint,
+	"s3:bucket":                          ResourceTypeObjectStorage,
+	"rds:db":                             ResourceTypeDatabaseInstance,
+	"rds:cluster":                        ResourceTypeDatabaseCluster,
+	"rds:db#aurora":                      ResourceTypeDatabaseInstance,
+	"dynamodb:table":                     ResourceTypeNoSQLTable,
+	"ec2:volume":                         ResourceTypeBlockVolume,
+	"elasticfilesystem:file-system":      ResourceTypeFileSystem,
+	"backup:backup-vault":                ResourceTypeBackupVault,
+	"backup:backup-plan":                 ResourceTypeBackupPolicy,
+	"backup:recovery-point":              ResourceTypeRecoveryPoint,
+	"ec2:image":                          ResourceTypeMachineImage,
+	"ec2:snapshot":                       ResourceTypeVolumeSnapshot,
+	"rds:snapshot":                       ResourceTypeDatabaseSnapshot,
+	"rds:cluster-snapshot":               ResourceTypeDatabaseSnapshot,
+	"elasticache:cluster":                ResourceTypeCache,
+	"redshift:cluster":                   ResourceTypeDataWarehouse,
+	"rds:cluster#neptune":                ResourceTypeGraphDatabaseCluster,
+	"cloudfront:distribution":            ResourceTypeCDNDistribution,
+	"route53:hostedzone":                 ResourceTypeDNSZone,
+	"apigateway:rest-api":                ResourceTypeAPIGateway,
+	"apigatewayv2:api":                   ResourceTypeAPIGateway,
+	"directconnect:dxcon":                ResourceTypeDedicatedInterconnect,
+	"ec2:vpn-connection":                 ResourceTypeVPNConnection,
+	"dms:rep":                            ResourceTypeMigrationInstance,
+	"workspaces:workspace":               ResourceTypeVirtualDesktop,
+	"ec2:vpc":                            ResourceTypeVirtualNetwork,
+	"elasticloadbalancing:loadbalancer":  ResourceTypeLoadBalancer,
+	"ec2:natgateway":                     ResourceTypeNATGateway,
+	"ec2:internet-gateway":               ResourceTypeInternetGateway,
+	"ec2:security-group":                 ResourceTypeNetworkSecurityGroup,
+	"ec2:subnet":                         ResourceTypeSubnet,
+	"ec2:network-acl":                    ResourceTypeNetworkACL,
+	"ec2:vpc-endpoint":                   ResourceTypePrivateEndpoint,
+	"ec2:vpc-peering-connection":         ResourceTypeNetworkPeering,
+	"ec2:transit-gateway":                ResourceTypeTransitGateway,
+	"ec2:transit-gateway-attachment":     ResourceTypeTransitGatewayAttachment,
+	"ec2:elastic-ip":                     ResourceTypePublicIPAddress,
+	"kms:key":                            ResourceTypeEncryptionKey,
+	"secretsmanager:secret":              ResourceTypeSecret,
+	"acm:certificate":                    ResourceTypeCertificate,
+	"cloudhsm:cluster":                   ResourceTypeHSMCluster,
+	"guardduty:detector":                 ResourceTypeThreatDetector,
+	"securityhub:standards-subscription": ResourceTypeComplianceStandard,
+	"config:configuration-recorder":      ResourceTypeConfigRecorder,
+	"config:config-rule":                 ResourceTypeConfigRule,
+	"cloudtrail:trail":                   ResourceTypeAuditTrail,
+	"inspector2:account":                 ResourceTypeVulnerabilityScan,
+	"wafv2:webacl":                       ResourceTypeWebACL,
+	"wafv2:webacl#cloudfront":            ResourceTypeWebACL,
+
+	// Azure
+	"microsoft.compute/virtualmachines":                       ResourceTypeVirtualMachine,
+	"microsoft.compute/virtualmachinescalesets":               ResourceTypeScaleSet,
+	"microsoft.compute/virtualmachinescalesets#instances":     ResourceTypeScaleSetInstance,
+	"microsoft.compute/virtualmachinescalesets#aks":           ResourceTypeKubernetesNodePool,
+	"microsoft.compute/virtualmachinescalesets#aks-instances": ResourceTypeKubernetesNode,
+	"microsoft.containerservice/managedclusters":              ResourceTypeKubernetesCluster,
+	"microsoft.containerinstance/containergroups":             ResourceTypeContainerInstance,
+	"microsoft.containerregistry/registries":                  ResourceTypeContainerRegistry,
+	"microsoft.web/sites":                                     ResourceTypeWebApp,
+	"microsoft.web/sites#functionapp":                         ResourceTypeFunctionApp,
+	"microsoft.web/sites#workflowapp":                         ResourceTypeWorkflow,
+	"microsoft.web/serverfarms":                               ResourceTypeAppServicePlan,
+	"microsoft.logic/workflows":                               ResourceTypeWorkflow,
+	"microsoft.storage/storageaccounts":                       ResourceTypeObjectStorage,
+	"microsoft.datalakestore/accounts":                        ResourceTypeDataLakeStore,
+	"microsoft.recoveryservices/vaults":                       ResourceTypeBackupVault,
+	"microsoft.recoveryservices/vaults/backuppolicies":        ResourceTypeBackupPolicy,
+	"microsoft.compute/disks":                                 ResourceTypeBlockVolume,
+	"microsoft.compute/snapshots":                             ResourceTypeVolumeSnapshot,
+	"microsoft.sql/servers":                                   ResourceTypeDatabaseServer,
+	"microsoft.sql/servers/databases":                         ResourceTypeDatabase,
+	"microsoft.sql/servers/elasticpools":                      ResourceTypeDatabaseElasticPool,
+	"microsoft.sql/managedinstances":                          ResourceTypeDatabaseInstance,
+	"microsoft.dbformariadb/servers":                          ResourceTypeDatabaseInstance,
+	"microsoft.dbformysql/flexibleservers":                    ResourceTypeDatabaseInstance,
+	"microsoft.dbforpostgresql/flexibleservers":               ResourceTypeDatabaseInstance,
+	"microsoft.documentdb/databaseaccounts":                   ResourceTypeNoSQLAccount,
+	"microsoft.cache/redis":                                   ResourceTypeCache,
+	"microsoft.cache/redisenterprise":                         ResourceTypeCache,
+	"microsoft.synapse/workspaces":                            ResourceTypeDataWarehouse,
+	"microsoft.network/virtualnetworks":                       ResourceTypeVirtualNetwork,
+	"microsoft.network/loadbalancers":                         ResourceTypeLoadBalancer,
+	"microsoft.network/applicationgateways":                   ResourceTypeLoadBalancer,
+	"microsoft.network/networksecuritygroups":                 ResourceTypeNetworkSecurityGroup,
+	"microsoft.network/networkinterfaces":                     ResourceTypeNetworkInterface,
+	"microsoft.network/networkwatchers":                       ResourceTypeNetworkWatcher,
+	"microsoft.network/privateendpoints":                      ResourceTypePrivateEndpoint,
+	"microsoft.network/publicipaddresses":                     ResourceTypePublicIPAddress,
+	"microsoft.network/routetables":                           ResourceTypeRouteTable,
+	"microsoft.network/azurefirewalls":                        ResourceTypeFirewall,
+	"microsoft.network/bastionhosts":                          ResourceTypeBastionHost,
+	"microsoft.network/connections":                           ResourceTypeVPNConnection,
+	"microsoft.network/vpngateways":                           ResourceTypeVPNGateway,
+	"microsoft.network/localnetworkgateways":                  ResourceTypeLocalNetworkGateway,
+	"microsoft.network/natgateways":                           ResourceTypeNATGateway,
+	"microsoft.network/ddosprotectionplans":                   ResourceTypeDDoSProtection,
+	"microsoft.network/expressroutecircuits":                  ResourceTypeDedicatedInterconnect,
+	"microsoft.network/virtualwans":                           ResourceTypeWideAreaNetwork,
+	"microsoft.network/virtualhubs":                           ResourceTypeTransitGateway,
+	"microsoft.network/firewallpolicies":                      ResourceTypeFirewallPolicy,
+	"microsoft.network/frontdoors":                            ResourceTypeCDNDistribution,
+	"microsoft.cdn/profiles/afdendpoints":                     ResourceTypeCDNDistribution,
+	"microsoft.cdn/profiles":                                  ResourceTypeCDNProfile,
+	"microsoft.network/dnszones":                              ResourceTypeDNSZone,
+	"microsoft.network/privatednszones":                       ResourceTypeDNSZone,
+	"microsoft.network/trafficmanagerprofiles":                ResourceTypeTrafficManager,
+	"microsoft.apimanagement/service":                         ResourceTypeAPIManagement,
+	"microsoft.servicebus/namespaces":                         ResourceTypeMessagingNamespace,
+	"microsoft.eventgrid/topics":                              ResourceTypePubSubTopic,
+	"microsoft.signalrservice/signalr":                        ResourceTypeRealtimeMessaging,
+	"microsoft.eventhub/namespaces":                           ResourceTypeEventStreaming,
+	"microsoft.datafactory/factories":                         ResourceTypeDataIntegration,
+	"microsoft.hdinsight/clusters":                            ResourceTypeBigDataCluster,
+	"microsoft.search/searchservices":                         ResourceTypeSearchService,
+	"microsoft.databricks/workspaces":                         ResourceTypeDatabricks,
+	"microsoft.purview/accounts":                              ResourceTypeDataGovernance,
+	"microsoft.operationalinsights/workspaces":                ResourceTypeLogWorkspace,
+	"microsoft.insights/components":                           ResourceTypeApplicationMonitor,
+	"microsoft.machinelearningservices/workspaces":            ResourceTypeMLWorkspace,
+	"microsoft.cognitiveservices/accounts":                    ResourceTypeAIServices,
+	"microsoft.cognitiveservices/accounts#openai":             ResourceTypeOpenAIServices,
+	"microsoft.visualstudio/account/project":                  ResourceTypeDevOpsProject,
+	"microsoft.automation/automationaccounts":                 ResourceTypeAutomationAccount,
+	"microsoft.authorization/roleassignments":                 ResourceTypeRoleAssignment,
+	"microsoft.authorization/roledefinitions":                 ResourceTypeRole,
+	"microsoft.keyvault/vaults":                               ResourceTypeKeyVault,
+	"microsoft.security/pricings":                             ResourceTypeSecurityPlan,
+	"microsoft.operationsmanagement/solutions":                ResourceTypeSIEMWorkspace,
+	"microsoft.graph/users":                                   ResourceTypeUser,
+	"microsoft.graph/groups":                                  ResourceTypeGroup,
+	"microsoft.graph/serviceprincipals":                       ResourceTypeServicePrincipal,
+	"microsoft.graph/applications":                            ResourceTypeAppRegistration,
+
+	// Azure storage account contents, counted with --deep-storage
+	"microsoft.storage/storageaccounts/blobservices/containers": ResourceTypeBlobContainer,
+	"microsoft.storage/storageaccounts/fileservices/shares":     ResourceTypeFileShare,
+	"microsoft.storage/storageaccounts/queueservices/queues":    ResourceTypeMessageQueue,
+	"microsoft.storage/storageaccounts/tableservices/tables":    ResourceTypeNoSQLTable,
+
+	// Recovery Services vault contents, counted with --deep-backup
+	"microsoft.recoveryservices/vaults/backupprotecteditems":      ResourceTypeBackupItem,
+	"microsoft.recoveryservices/vaults/replicationprotecteditems": ResourceTypeReplicatedItem,
+
+	// Kubernetes
+	"k8s:node":        ResourceTypeKubernetesNode,
+	"k8s:namespace":   ResourceTypeKubernetesNamespace,
+	"k8s:deployment":  ResourceTypeKubernetesWorkload,
+	"k8s:daemonset":   ResourceTypeKubernetesWorkload,
+	"k8s:statefulset": ResourceTypeKubernetesWorkload,
+	"k8s:pod":         ResourceTypeKubernetesPod,
+	"k8s:image":       ResourceTypeContainerImage,
+
+	// Oracle Cloud Infrastructure
+	"oci:instance":           ResourceTypeVirtualMachine,
+	"oci:autonomousdatabase": ResourceTypeDatabaseInstance,
+	"oci:bucket":             ResourceTypeObjectStorage,
+	"oci:vcn":                ResourceTypeVirtualNetwork,
+	"oci:functionsfunction":  ResourceTypeFunction,
+	"oci:clusterscluster":    ResourceTypeKubernetesCluster,
+
+	// Okta
+	"okta:users":  ResourceTypeUser,
+	"okta:groups": ResourceTypeGroup,
+	"okta:apps":   ResourceTypeAppRegistration,
+}
+
+// canonicalNames are the display names of the canonical types
+var canonicalNames = map[ResourceType]string{
+	ResourceTypeVirtualMachine:           "Virtual Machines",
+	ResourceTypeScaleSet:                 "Scale Sets",
+	ResourceTypeScaleSetInstance:         "Scale Set Instances",
+	ResourceTypeFunction:                 "Functions",
+	ResourceTypeFunctionApp:              "Function Apps",
+	ResourceTypeWebApp:                   "Web Apps",
+	ResourceTypeAppServicePlan:           "App Service Plans",
+	ResourceTypeVirtualDesktop:           "Virtual Desktops",
+	ResourceTypeHybridServer:             "Hybrid Servers",
+	ResourceTypeBatchEnvironment:         "Batch Compute Environments",
+	ResourceTypeBatchJobQueue:            "Batch Job Queues",
+	ResourceTypeApplication:              "Applications",
+	ResourceTypeKubernetesCluster:        "Kubernetes Clusters",
+	ResourceTypeKubernetesNodePool:       "Kubernetes Node Pools",
+	ResourceTypeKubernetesNode:           "Kubernetes Nodes",
+	ResourceTypeKubernetesServerless:     "Kubernetes Serverless Profiles",
+	ResourceTypeContainerCluster:         "Container Clusters",
+	ResourceTypeContainerService:         "Container Services",
+	ResourceTypeContainerInstance:        "Container Instances",
+	ResourceTypeContainerRegistry:        "Container Registries",
+	ResourceTypeContainerRepository:      "Container Repositories",
+	ResourceTypeContainerImage:           "Container Images",
+	ResourceTypeKubernetesNamespace:      "Kubernetes Namespaces",
+	ResourceTypeKubernetesWorkload:       "Kubernetes Workloads",
+	ResourceTypeKubernetesPod:            "Kubernetes Pods",
+	ResourceTypeObjectStorage:            "Object Storage",
+	ResourceTypeBlockVolume:              "Block Volumes",
+	ResourceTypeFileSystem:               "File Systems",
+	ResourceTypeBlobContainer:            "Blob Containers",
+	ResourceTypeFileShare:                "File Shares",
+	ResourceTypeDataLakeStore:            "Data Lake Stores",
+	ResourceTypeBackupVault:              "Backup Vaults",
+	ResourceTypeBackupPolicy:             "Backup Policies",
+	ResourceTypeMachineImage:             "Machine Images",
+	ResourceTypeVolumeSnapshot:           "Volume Snapshots",
+	ResourceTypeDatabaseSnapshot:         "Database Snapshots",
+	ResourceTypeRecoveryPoint:            "Recovery Points",
+	ResourceTypeBackupItem:               "Backup Items",
+	ResourceTypeReplicatedItem:           "Replicated Items",
+	ResourceTypeDatabaseInstance:         "Database Instances",
+	ResourceTypeDatabaseCluster:          "Database Clusters",
+	ResourceTypeDatabaseServer:           "Database Servers",
+	ResourceTypeDatabase:                 "Databases",
+	ResourceTypeDatabaseElasticPool:      "Database Elastic Pools",
+	ResourceTypeGraphDatabaseCluster:     "Graph Database Clusters",
+	ResourceTypeNoSQLTable:               "NoSQL Tables",
+	ResourceTypeNoSQLAccount:             "NoSQL Accounts",
+	ResourceTypeCache:                    "Caches",
+	ResourceTypeDataWarehouse:            "Data Warehouses",
+	ResourceTypeVirtualNetwork:           "Virtual Networks",
+	ResourceTypeLoadBalancer:             "Load Balancers",
+	ResourceTypeNATGateway:               "NAT Gateways",
+	ResourceTypeInternetGateway:          "Internet Gateways",
+	ResourceTypeNetworkSecurityGroup:     "Network Security Groups",
+	ResourceTypeNetworkACL:               "Network ACLs",
+	ResourceTypeSubnet:                   "Subnets",
+	ResourceTypeNetworkPeering:           "Network Peerings",
+	ResourceTypeTransitGateway:           "Transit Gateways",
+	ResourceTypeTransitGatewayAttachment: "Transit Gateway Attachments",
+	ResourceTypeNetworkInterface:         "Network Interfaces",
+	ResourceTypeNetworkWatcher:           "Network Watchers",
+	ResourceTypePrivateEndpoint:          "Private Endpoints",
+	ResourceTypePublicIPAddress:          "Public IP Addresses",
+	ResourceTypeRouteTable:               "Route Tables",
+	ResourceTypeFirewall:                 "Firewalls",
+	ResourceTypeFirewallPolicy:           "Firewall Policies",
+	ResourceTypeDDoSProtection:           "DDoS Protection Plans",
+	ResourceTypeWideAreaNetwork:          "Wide Area Networks",
+	ResourceTypeBastionHost:              "Bastion Hosts",
+	ResourceTypeVPNConnection:            "VPN Connections",
+	ResourceTypeVPNGateway:               "VPN Gateways",
+	ResourceTypeLocalNetworkGateway:      "Local Network Gateways",
+	ResourceTypeDedicatedInterconnect:    "Dedicated Interconnects",
+	ResourceTypeCDNDistribution:          "CDN Distributions",
+	ResourceTypeCDNProfile:               "CDN Profiles",
+	ResourceTypeDNSZone:                  "DNS Zones",
+	ResourceTypeTrafficManager:           "Traffic Managers",
+	ResourceTypeAPIGateway:               "API Gateways",
+	ResourceTypeAPIManagement:            "API Management Services",
+	ResourceTypeMessageQueue:             "Message Queues",
+	ResourceTypeMessagingNamespace:       "Messaging Namespaces",
+	ResourceTypePubSubTopic:              "Pub/Sub Topics",
+	ResourceTypeWorkflow:                 "Workflows",
+	ResourceTypeRealtimeMessaging:        "Realtime Messaging Services",
+	ResourceTypeEventBus:                 "Event Buses",
+	ResourceTypeEventRule:                "Event Rules",
+	ResourceTypeDataStream:               "Data Streams",
+	ResourceTypeEventStreaming:           "Event Streaming Namespaces",
+	ResourceTypeDataDeliveryStream:       "Data Delivery Streams",
+	ResourceTypeETLJob:                   "ETL Jobs",
+	ResourceTypeDataCrawler:              "Data Crawlers",
+	ResourceTypeDataIntegration:          "Data Integration Factories",
+	ResourceTypeQueryWorkgroup:           "Query Workgroups",
+	ResourceTypeBigDataCluster:           "Big Data Clusters",
+	ResourceTypeKafkaCluster:             "Kafka Clusters",
+	ResourceTypeSearchService:            "Search Services",
+	ResourceTypeDatabricks:               "Databricks Workspaces",
+	ResourceTypeDataGovernance:           "Data Governance Accounts",
+	ResourceTypeLogWorkspace:             "Log Workspaces",
+	ResourceTypeLogGroup:                 "Log Groups",
+	ResourceTypeApplicationMonitor:       "Application Monitors",
+	ResourceTypeMetricAlarm:              "Metric Alarms",
+	ResourceTypeMLNotebook:               "ML Notebooks",
+	ResourceTypeMLEndpoint:               "ML Endpoints",
+	ResourceTypeMLWorkspace:              "ML Workspaces",
+	ResourceTypeAIServices:               "AI Services Accounts",
+	ResourceTypeOpenAIServices:           "OpenAI Accounts",
+	ResourceTypeCodeRepository:           "Code Repositories",
+	ResourceTypeDevOpsProject:            "DevOps Projects",
+	ResourceTypeBuildProject:             "Build Projects",
+	ResourceTypeDeployment:               "Deployment Applications",
+	ResourceTypePipeline:                 "Pipelines",
+	ResourceTypeInfrastructureStack:      "Infrastructure Stacks",
+	ResourceTypeAutomationAccount:        "Automation Accounts",
+	ResourceTypeMigrationInstance:        "Migration Instances",
+	ResourceTypeUser:                     "Users",
+	ResourceTypeGroup:                    "Groups",
+	ResourceTypeRole:                     "Roles",
+	ResourceTypePolicy:                   "Policies",
+	ResourceTypeRoleAssignment:           "Role Assignments",
+	ResourceTypeServicePrincipal:         "Service Principals",
+	ResourceTypeAppRegistration:          "App Registrations",
+	ResourceTypeEncryptionKey:            "Encryption Keys",
+	ResourceTypeSecret:                   "Secrets",
+	ResourceTypeKeyVault:                 "Key Vaults",
+	ResourceTypeCertificate:              "Certificates",
+	ResourceTypeHSMCluster:               "HSM Clusters",
+	ResourceTypeThreatDetector:           "Threat Detectors",
+	ResourceTypeSecurityPlan:             "Security Plans",
+	ResourceTypeComplianceStandard:       "Compliance Standards",
+	ResourceTypeConfigRecorder:           "Config Recorders",
+	ResourceTypeConfigRule:               "Config Rules",
+	ResourceTypeAuditTrail:               "Audit Trails",
+	ResourceTypeVulnerabilityScan:        "Vulnerability Scanning",
+	ResourceTypeWebACL:                   "Web ACLs",
+	ResourceTypeSIEMWorkspace:            "SIEM Workspaces",
+}
+
+// CanonicalType returns the canonical type of a provider-native type, or ""
+// if it has none. Azure types are matched regardless of case.
+func CanonicalType(native ResourceType) ResourceType {
+	if canonical, ok := canonicalTypes[native]; ok {
+		return canonical
+	}
+	return canonicalTypes[ResourceType(strings.ToLower(string(native)))]
+}
+
+// IsCanonicalType reports whether t is one of the canonical types
+func IsCanonicalType(t ResourceType) bool {
+	_, ok := canonicalNames[t]
+	return ok
+}
+
+// CanonicalName returns the display name of a canonical type
+func CanonicalName(t ResourceType) string {
+	if name, ok := canonicalNames[t]; ok {
+		return name
+	}
+	return string(t)
+}
+
+// CanonicalType returns the canonical type counts of this definition are
+// reported under: the definition's own canonical_type, else the mapping of
+// its key, else that of its type ("" for types with no mapping)
+func (d ResourceDefinition) CanonicalType() ResourceType {
+	if d.Canonical != "" {
+		return d.Canonical
+	}
+	if canonical := CanonicalType(d.ResourceType()); canonical != "" {
+		return canonical
+	}
+	return CanonicalType(ResourceType(d.Type))
+}
+
+// CanonicalCount is the count of a canonical type across providers
+type CanonicalCount struct {
+	Type           ResourceType   `json:"type"`
+	DisplayName    string         `json:"display_name"`
+	TotalResources int            `json:"total_resources"`
+	ByProvider     map[string]int `json:"by_provider"`
+}
+
+// CountByCanonicalType adds up the resource counts of results by canonical
+// type, e.g. EC2 instances and Azure virtual machines as virtual machines.
+// Counts without a canonical type are left out. The counts are ordered by
+// display name.
+func CountByCanonicalType(results ...*SizingResult) []*CanonicalCount {
+	byType := make(map[ResourceType]*CanonicalCount)
+	for _, result := range results {
+		for _, rc := range result.ResourceCounts {
+			if rc.CanonicalType == "" {
+				continue
+			}
+			count, ok := byType[rc.CanonicalType]
+			if !ok {
+				count = &CanonicalCount{
+					Type:        rc.CanonicalType,
+					DisplayName: CanonicalName(rc.CanonicalType),
+					ByProvider:  make(map[string]int),
+				}
+				byType[rc.CanonicalType] = count
+			}
+			count.TotalResources += rc.TotalResources
+			count.ByProvider[rc.Provider] += rc.TotalResources
+		}
+	}
+
+	counts := make([]*CanonicalCount, 0, len(byType))
+	for _, count := range byType {
+		counts = append(counts, count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].DisplayName < counts[j].DisplayName
+	})
+	return counts
+}
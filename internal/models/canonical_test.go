@@ -0,0 +1,75 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalTypesCoverDefinitions(t *testing.T) {
+	set, err := DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	definitions := append(set.ForProvider("aws"), set.ForProvider("azure")...)
+	definitions = append(definitions, set.ForProvider("k8s")...)
+	definitions = append(definitions, set.ForProvider("oci")...)
+	for _, def := range definitions {
+		canonical := def.CanonicalType()
+		if canonical == "" {
+			t.Errorf("%s has no canonical type", def.ResourceType())
+			continue
+		}
+		if !IsCanonicalType(canonical) {
+			t.Errorf("%s maps to %q, which has no display name", def.ResourceType(), canonical)
+		}
+	}
+	for native, canonical := range canonicalTypes {
+		if !IsCanonicalType(canonical) {
+			t.Errorf("%s maps to %q, which has no display name", native, canonical)
+		}
+	}
+}
+
+func TestDefinitionCanonicalType(t *testing.T) {
+	tests := []struct {
+		name string
+		def  ResourceDefinition
+		want ResourceType
+	}{
+		{"type", ResourceDefinition{Type: "ec2:instance"}, ResourceTypeVirtualMachine},
+		{"key", ResourceDefinition{Type: "microsoft.web/sites", Key: "microsoft.web/sites#functionapp"}, ResourceTypeFunctionApp},
+		{"unmapped key falls back to type", ResourceDefinition{Type: "s3:bucket", Key: "s3:bucket#logs"}, ResourceTypeObjectStorage},
+		{"azure type in any case", ResourceDefinition{Type: "Microsoft.Compute/virtualMachines"}, ResourceTypeVirtualMachine},
+		{"definition overrides", ResourceDefinition{Type: "ec2:instance", Canonical: ResourceTypeScaleSetInstance}, ResourceTypeScaleSetInstance},
+		{"unmapped", ResourceDefinition{Type: "appsync:apis"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.def.CanonicalType(); got != tt.want {
+				t.Errorf("CanonicalType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountByCanonicalType(t *testing.T) {
+	aws := &SizingResult{ResourceCounts: []*ResourceCount{
+		{Provider: "AWS", Type: "ec2:instance", CanonicalType: ResourceTypeVirtualMachine, TotalResources: 3},
+		{Provider: "AWS", Type: "s3:bucket", CanonicalType: ResourceTypeObjectStorage, TotalResources: 5},
+		{Provider: "AWS", Type: "appsync:apis", TotalResources: 2},
+	}}
+	azure := &SizingResult{ResourceCounts: []*ResourceCount{
+		{Provider: "Azure", Type: "microsoft.compute/virtualmachines", CanonicalType: ResourceTypeVirtualMachine, TotalResources: 4},
+	}}
+
+	want := []*CanonicalCount{
+		{Type: ResourceTypeObjectStorage, DisplayName: "Object Storage", TotalResources: 5, ByProvider: map[string]int{"AWS": 5}},
+		{Type: ResourceTypeVirtualMachine, DisplayName: "Virtual Machines", TotalResources: 7, ByProvider: map[string]int{"AWS": 3, "Azure": 4}},
+	}
+	if got := CountByCanonicalType(aws, azure); !reflect.DeepEqual(got, want) {
+		for _, count := range got {
+			t.Logf("got %+v", *count)
+		}
+		t.Errorf("CountByCanonicalType() differs from %d expected counts", len(want))
+	}
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed definitions.yaml
+var defaultDefinitionsYAML []byte
+
+// Override modes for a resource definitions file
+const (
+	DefinitionsModeMerge   = "merge"
+	DefinitionsModeReplace = "replace"
+)
+
+var (
+	awsTypePattern    = regexp.MustCompile(`^[a-z0-9-]+:[A-Za-z0-9-]+$`)
+	azureTypePattern  = regexp.MustCompile(`^[a-z0-9.]+(/[a-z0-9]+)+$`)
+	k8sTypePattern    = regexp.MustCompile(`^k8s:[a-z]+$`)
+	ociTypePattern    = regexp.MustCompile(`^oci:[A-Za-z]+$`)
+	graphTablePattern = regexp.MustCompile(`^[A-Za-z]+$`)
+)
+
+// DefinitionsFile is the on-disk layout of a resource definitions file
+type DefinitionsFile struct {
+	// Mode is "merge" (default) or "replace". In merge mode entries override
+	// defaults with the same type and new types are appended; entries with
+	// disabled set remove the type. In replace mode the lists given for a
+	// provider replace the defaults for that provider entirely.
+	Mode  string               `yaml:"mode"`
+	AWS   []ResourceDefinition `yaml:"aws"`
+	Azure []ResourceDefinition `yaml:"azure"`
+	K8s   []ResourceDefinition `yaml:"k8s"`
+	OCI   []ResourceDefinition `yaml:"oci"`
+}
+
+// DefinitionSet holds the resource definitions for every provider
+type DefinitionSet struct {
+	byProvider map[string][]ResourceDefinition
+}
+
+// DefaultDefinitions returns the embedded resource definitions
+func DefaultDefinitions() (*DefinitionSet, error) {
+	file, err := parseDefinitions(defaultDefinitionsYAML)
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedded resource definitions: %w", err)
+	}
+	set := &DefinitionSet{byProvider: map[string][]ResourceDefinition{
+		"aws":   file.AWS,
+		"azure": file.Azure,
+		"k8s":   file.K8s,
+		"oci":   file.OCI,
+	}}
+	if err := set.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid embedded resource definitions: %w", err)
+	}
+	return set, nil
+}
+
+// LoadDefinitions returns the embedded definitions with the override file at
+// path applied. An empty path returns the defaults.
+func LoadDefinitions(path string) (*DefinitionSet, error) {
+	set, err := DefaultDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return set, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource definitions file: %w", err)
+	}
+	override, err := parseDefinitions(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse resource definitions file %s: %w", path, err)
+	}
+
+	switch override.Mode {
+	case "", DefinitionsModeMerge:
+		set.merge("aws", override.AWS)
+		set.merge("azure", override.Azure)
+		set.merge("k8s", override.K8s)
+		set.merge("oci", override.OCI)
+	case DefinitionsModeReplace:
+		if override.AWS != nil {
+			set.byProvider["aws"] = override.AWS
+		}
+		if override.Azure != nil {
+			set.byProvider["azure"] = override.Azure
+		}
+		if override.K8s != nil {
+			set.byProvider["k8s"] = override.K8s
+		}
+		if override.OCI != nil {
+			set.byProvider["oci"] = override.OCI
+		}
+	default:
+		return nil, fmt.Errorf("invalid mode %q in %s (must be %s or %s)",
+			override.Mode, path, DefinitionsModeMerge, DefinitionsModeReplace)
+	}
+
+	if err := set.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid resource definitions file %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// ForProvider returns the enabled definitions for a provider
+func (s *DefinitionSet) ForProvider(provider string) []ResourceDefinition {
+	var defs []ResourceDefinition
+	for _, def := range s.byProvider[strings.ToLower(provider)] {
+		if !def.Disabled {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// Validate checks type formats, required fields and count methods
+func (s *DefinitionSet) Validate() error {
+	for provider, defs := range s.byProvider {
+		seen := make(map[string]bool)
+		for i, def := range defs {
+			if err := validateDefinition(provider, def); err != nil {
+				return fmt.Errorf("%s definition %d: %w", provider, i+1, err)
+			}
+			id := strings.ToLower(string(def.ResourceType()))
+			if seen[id] {
+				return fmt.Errorf("%s definition %d: duplicate type %q (set a distinct key)", provider, i+1, id)
+			}
+			seen[id] = true
+		}
+	}
+	return nil
+}
+
+// merge applies override entries on top of the provider's definitions
+func (s *DefinitionSet) merge(provider string, overrides []ResourceDefinition) {
+	defs := s.byProvider[provider]
+	for _, override := range overrides {
+		replaced := false
+		for i := range defs {
+			if strings.EqualFold(string(defs[i].ResourceType()), string(override.ResourceType())) {
+				defs[i] = override
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			defs = append(defs, override)
+		}
+	}
+	s.byProvider[provider] = defs
+}
+
+func validateDefinition(provider string, def ResourceDefinition) error {
+	if def.Type == "" {
+		return fmt.Errorf("type is required")
+	}
+	if def.Disabled {
+		return nil
+	}
+	if def.DisplayName == "" {
+		return fmt.Errorf("display_name is required for %q", def.Type)
+	}
+	if def.Key != "" && !strings.HasPrefix(strings.ToLower(def.Key), strings.ToLower(def.Type)+"#") {
+		return fmt.Errorf("key %q must have the form %s#<variant>", def.Key, def.Type)
+	}
+	if def.SubsetOf != "" && strings.EqualFold(def.SubsetOf, string(def.ResourceType())) {
+		return fmt.Errorf("%q cannot be a subset_of itself", def.ResourceType())
+	}
+	if def.Canonical != "" && !IsCanonicalType(def.Canonical) {
+		return fmt.Errorf("unknown canonical_type %q for %q", def.Canonical, def.Type)
+	}
+
+	switch provider {
+	case "aws":
+		if !awsTypePattern.MatchString(def.Type) {
+			return fmt.Errorf("invalid AWS type %q (expected service:resource)", def.Type)
+		}
+		if def.CountMethod != CountMethodTaggingAPI && def.CountMethod != CountMethodServiceAPI {
+			return fmt.Errorf("unsupported count_method %q for %q", def.CountMethod, def.Type)
+		}
+	case "azure":
+		if !azureTypePattern.MatchString(strings.ToLower(def.Type)) {
+			return fmt.Errorf("invalid Azure type %q (expected namespace/type)", def.Type)
+		}
+		if def.CountMethod != CountMethodResourceGraph && def.CountMethod != CountMethodSecurityPricing {
+			return fmt.Errorf("unsupported count_method %q for %q", def.CountMethod, def.Type)
+		}
+		if def.GraphTable != "" && !graphTablePattern.MatchString(def.GraphTable) {
+			return fmt.Errorf("invalid graph_table %q for %q", def.GraphTable, def.Type)
+		}
+	case "k8s":
+		if !k8sTypePattern.MatchString(def.Type) {
+			return fmt.Errorf("invalid Kubernetes type %q (expected k8s:kind)", def.Type)
+		}
+		if def.CountMethod != CountMethodKubernetesAPI {
+			return fmt.Errorf("unsupported count_method %q for %q", def.CountMethod, def.Type)
+		}
+	case "oci":
+		if !ociTypePattern.MatchString(def.Type) {
+			return fmt.Errorf("invalid OCI type %q (expected oci:SearchResourceType)", def.Type)
+		}
+		if def.CountMethod != CountMethodResourceSearch {
+			return fmt.Errorf("unsupported count_method %q for %q", def.CountMethod, def.Type)
+		}
+	}
+	if provider != "azure" && (def.GraphTable != "" || def.KqlFilter != "" ||
+		def.StateField != "" || def.SKUField != "" || def.PublicField != "" || def.SumField != "" || def.NoiseFilter != "") {
+		return fmt.Errorf("graph_table, kql_filter, state_field, sku_field, public_field, sum_field and noise_filter are only supported for Azure (%q)", def.Type)
+	}
+	return nil
+}
+
+func parseDefinitions(data []byte) (*DefinitionsFile, error) {
+	var file DefinitionsFile
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
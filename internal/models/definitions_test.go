@@ -0,0 +1,312 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeDefinitions(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "definitions.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultDefinitions(t *testing.T) {
+	set, err := DefaultDefinitions()
+	if err != nil {
+		t.Fatalf("DefaultDefinitions() error = %v", err)
+	}
+	if len(set.ForProvider("aws")) == 0 || len(set.ForProvider("azure")) == 0 {
+		t.Fatal("expected default definitions for both providers")
+	}
+
+	// Resources counted again on a line of their own stay out of the totals
+	subsets := map[ResourceType]string{}
+	for _, provider := range []string{"aws", "azure"} {
+		for _, def := range set.ForProvider(provider) {
+			if def.SubsetOf != "" {
+				subsets[def.ResourceType()] = def.SubsetOf
+			}
+		}
+	}
+	want := map[ResourceType]string{
+		"eks:node": "ec2:instance",
+		"microsoft.compute/virtualmachinescalesets#aks":           "microsoft.containerservice/managedclusters",
+		"microsoft.compute/virtualmachinescalesets#aks-instances": "microsoft.containerservice/managedclusters",
+	}
+	if !reflect.DeepEqual(subsets, want) {
+		t.Errorf("subset definitions = %v, want %v", subsets, want)
+	}
+}
+
+// expectedAzureTypes is the full list of built-in Azure types. Keep it in sync
+// with definitions.yaml so additions and removals are deliberate.
+var expectedAzureTypes = []string{
+	"microsoft.containerservice/managedclusters",
+	"microsoft.apimanagement/service",
+	"microsoft.web/sites",
+	"microsoft.network/applicationgateways",
+	"microsoft.insights/components",
+	"microsoft.automation/automationaccounts",
+	"microsoft.network/azurefirewalls",
+	"microsoft.recoveryservices/vaults/backuppolicies",
+	"microsoft.network/bastionhosts",
+	"microsoft.cognitiveservices/accounts",
+	"microsoft.network/connections",
+	"microsoft.containerinstance/containergroups",
+	"microsoft.containerregistry/registries",
+	"microsoft.documentdb/databaseaccounts",
+	"microsoft.datafactory/factories",
+	"microsoft.datalakestore/accounts",
+	"microsoft.visualstudio/account/project",
+	"microsoft.compute/snapshots",
+	"microsoft.eventgrid/topics",
+	"microsoft.eventhub/namespaces",
+	"microsoft.hdinsight/clusters",
+	"microsoft.keyvault/vaults",
+	"microsoft.network/loadbalancers",
+	"microsoft.network/localnetworkgateways",
+	"microsoft.machinelearningservices/workspaces",
+	"microsoft.compute/disks",
+	"microsoft.cache/redisenterprise",
+	"microsoft.dbformariadb/servers",
+	"microsoft.dbformysql/flexibleservers",
+	"microsoft.network/networkinterfaces",
+	"microsoft.network/networkwatchers",
+	"microsoft.dbforpostgresql/flexibleservers",
+	"microsoft.network/privateendpoints",
+	"microsoft.network/publicipaddresses",
+	"microsoft.recoveryservices/vaults",
+	"microsoft.cache/redis",
+	"microsoft.network/routetables",
+	"microsoft.sql/servers/databases",
+	"microsoft.sql/servers",
+	"microsoft.sql/servers/elasticpools",
+	"microsoft.sql/managedinstances",
+	"microsoft.storage/storageaccounts",
+	"microsoft.compute/virtualmachines",
+	"microsoft.network/virtualnetworks",
+	"microsoft.network/networksecuritygroups",
+	"microsoft.network/vpngateways",
+	"microsoft.network/natgateways",
+	"microsoft.network/ddosprotectionplans",
+	"microsoft.network/expressroutecircuits",
+	"microsoft.network/virtualwans",
+	"microsoft.network/virtualhubs",
+	"microsoft.network/firewallpolicies",
+	"microsoft.authorization/roleassignments",
+	"microsoft.authorization/roledefinitions",
+	"microsoft.operationalinsights/workspaces",
+	"microsoft.operationsmanagement/solutions",
+	"microsoft.security/pricings",
+	"microsoft.logic/workflows",
+	"microsoft.servicebus/namespaces",
+	"microsoft.cdn/profiles",
+	"microsoft.network/frontdoors",
+	"microsoft.cdn/profiles/afdendpoints",
+	"microsoft.network/dnszones",
+	"microsoft.network/privatednszones",
+	"microsoft.network/trafficmanagerprofiles",
+	"microsoft.synapse/workspaces",
+	"microsoft.databricks/workspaces",
+	"microsoft.purview/accounts",
+	"microsoft.search/searchservices",
+	"microsoft.signalrservice/signalr",
+	"microsoft.cognitiveservices/accounts#openai",
+	"microsoft.web/sites#functionapp",
+	"microsoft.web/sites#workflowapp",
+	"microsoft.web/serverfarms",
+	"microsoft.compute/virtualmachinescalesets",
+	"microsoft.compute/virtualmachinescalesets#instances",
+	"microsoft.compute/virtualmachinescalesets#aks",
+	"microsoft.compute/virtualmachinescalesets#aks-instances",
+}
+
+func TestDefaultAzureDefinitions(t *testing.T) {
+	set, err := DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]bool)
+	for _, def := range set.ForProvider("azure") {
+		got[string(def.ResourceType())] = true
+	}
+	for _, want := range expectedAzureTypes {
+		if !got[want] {
+			t.Errorf("missing Azure type %q", want)
+		}
+		delete(got, want)
+	}
+	for extra := range got {
+		t.Errorf("unexpected Azure type %q (add it to expectedAzureTypes)", extra)
+	}
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	defaults, err := DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	awsDefaults := len(defaults.ForProvider("aws"))
+	azureDefaults := len(defaults.ForProvider("azure"))
+
+	tests := []struct {
+		name      string
+		content   string
+		wantAWS   int
+		wantAzure int
+		wantErr   string
+	}{
+		{
+			name: "merge adds and overrides",
+			content: `
+aws:
+  - type: ec2:instance
+    display_name: Instances
+    category: Compute
+    count_method: tagging_api
+  - type: appsync:apis
+    display_name: AppSync APIs
+    category: Application Integration
+    count_method: tagging_api
+`,
+			wantAWS:   awsDefaults + 1,
+			wantAzure: azureDefaults,
+		},
+		{
+			name: "merge disables",
+			content: `
+azure:
+  - type: microsoft.compute/virtualmachines
+    disabled: true
+`,
+			wantAWS:   awsDefaults,
+			wantAzure: azureDefaults - 1,
+		},
+		{
+			name: "replace",
+			content: `
+mode: replace
+aws:
+  - type: s3:bucket
+    display_name: S3 Buckets
+    category: Storage
+    count_method: tagging_api
+`,
+			wantAWS:   1,
+			wantAzure: azureDefaults,
+		},
+		{
+			name: "invalid aws type",
+			content: `
+aws:
+  - type: AWS::EC2::Instance
+    display_name: Instances
+    count_method: tagging_api
+`,
+			wantErr: "invalid AWS type",
+		},
+		{
+			name: "invalid azure type",
+			content: `
+azure:
+  - type: virtualmachines
+    display_name: VMs
+    count_method: resource_graph
+`,
+			wantErr: "invalid Azure type",
+		},
+		{
+			name: "wrong count method",
+			content: `
+azure:
+  - type: microsoft.web/sites
+    display_name: Sites
+    count_method: tagging_api
+`,
+			wantErr: "unsupported count_method",
+		},
+		{
+			name: "invalid key",
+			content: `
+azure:
+  - type: microsoft.web/sites
+    key: microsoft.web/serverfarms#plans
+    display_name: Plans
+    count_method: resource_graph
+`,
+			wantErr: "must have the form",
+		},
+		{
+			name: "duplicate key",
+			content: `
+mode: replace
+azure:
+  - type: microsoft.web/sites
+    key: microsoft.web/sites#functions
+    display_name: Functions
+    count_method: resource_graph
+  - type: microsoft.web/sites
+    key: microsoft.web/sites#functions
+    display_name: Functions again
+    count_method: resource_graph
+`,
+			wantErr: "duplicate type",
+		},
+		{
+			name: "unknown canonical type",
+			content: `
+aws:
+  - type: appsync:apis
+    display_name: AppSync APIs
+    count_method: tagging_api
+    canonical_type: GraphQLAPI
+`,
+			wantErr: "unknown canonical_type",
+		},
+		{
+			name:    "unknown field",
+			content: "aws:\n  - type: s3:bucket\n    colour: blue\n",
+			wantErr: "colour",
+		},
+		{
+			name:    "invalid mode",
+			content: "mode: append\n",
+			wantErr: "invalid mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := LoadDefinitions(writeDefinitions(t, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadDefinitions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadDefinitions() error = %v", err)
+			}
+			if got := len(set.ForProvider("aws")); got != tt.wantAWS {
+				t.Errorf("aws definitions = %d, want %d", got, tt.wantAWS)
+			}
+			if got := len(set.ForProvider("azure")); got != tt.wantAzure {
+				t.Errorf("azure definitions = %d, want %d", got, tt.wantAzure)
+			}
+		})
+	}
+}
+
+func TestLoadDefinitionsMissingFile(t *testing.T) {
+	if _, err := LoadDefinitions(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
@@ -1,21 +1,195 @@
 package models
 
+// ResourceType is a resource type string: the provider-native type of a
+// definition (e.g. "ec2:instance") or one of the canonical types below
 type ResourceType string
 
+// Canonical resource types line up the provider-native types of both clouds,
+// so that e.g. EC2 instances and Azure virtual machines can be added up
 const (
-	// Azure Resource Types
-	ResourceTypeAzureVM             ResourceType = "Azure::VM"
-	ResourceTypeAzureStorageAccount ResourceType = "Azure::StorageAccount"
-	ResourceTypeAzureDatabase       ResourceType = "Azure::Database"
-	ResourceTypeAzureNetwork        ResourceType = "Azure::Network"
-	ResourceTypeAzureKeyVault       ResourceType = "Azure::KeyVault"
-	ResourceTypeAzureAppService     ResourceType = "Azure::AppService"
-
-	// AWS Resource Types
-	ResourceTypeAWSEC2      ResourceType = "AWS::EC2"
-	ResourceTypeAWSS3       ResourceType = "AWS::S3"
-	ResourceTypeAWSRDS      ResourceType = "AWS::RDS"
-	ResourceTypeAWSVPC      ResourceType = "AWS::VPC"
-	ResourceTypeAWSLambda   ResourceType = "AWS::Lambda"
-	ResourceTypeAWSDynamoDB ResourceType = "AWS::DynamoDB"
+	// Compute
+	ResourceTypeVirtualMachine   ResourceType = "VirtualMachine"
+	ResourceTypeScaleSet         ResourceType = "ScaleSet"
+	ResourceTypeScaleSetInstance ResourceType = "ScaleSetInstance"
+	ResourceTypeFunction         ResourceType = "Function"
+	ResourceTypeFunctionApp      ResourceType = "FunctionApp"
+	ResourceTypeWebApp           ResourceType = "WebApp"
+	ResourceTypeAppServicePlan   ResourceType = "AppServicePlan"
+	ResourceTypeVirtualDesktop   ResourceType = "VirtualDesktop"
+	ResourceTypeHybridServer     ResourceType = "HybridServer"
+	ResourceTypeBatchEnvironment ResourceType = "BatchComputeEnvironment"
+	ResourceTypeBatchJobQueue    ResourceType = "BatchJobQueue"
+	ResourceTypeApplication      ResourceType = "Application"
+
+	// Containers
+	ResourceTypeKubernetesCluster    ResourceType = "KubernetesCluster"
+	ResourceTypeKubernetesNodePool   ResourceType = "KubernetesNodePool"
+	ResourceTypeKubernetesNode       ResourceType = "KubernetesNode"
+	ResourceTypeKubernetesServerless ResourceType = "KubernetesServerlessProfile"
+	ResourceTypeContainerCluster     ResourceType = "ContainerCluster"
+	ResourceTypeContainerService     ResourceType = "ContainerService"
+	ResourceTypeContainerInstance    ResourceType = "ContainerInstance"
+	ResourceTypeContainerRegistry    ResourceType = "ContainerRegistry"
+	ResourceTypeContainerRepository  ResourceType = "ContainerRepository"
+	ResourceTypeContainerImage       ResourceType = "ContainerImage"
+	ResourceTypeKubernetesNamespace  ResourceType = "KubernetesNamespace"
+	ResourceTypeKubernetesWorkload   ResourceType = "KubernetesWorkload"
+	ResourceTypeKubernetesPod        ResourceType = "KubernetesPod"
+
+	// Storage
+	ResourceTypeObjectStorage ResourceType = "ObjectStorage"
+	ResourceTypeBlockVolume   ResourceType = "BlockVolume"
+	ResourceTypeFileSystem    ResourceType = "FileSystem"
+	ResourceTypeBlobContainer ResourceType = "BlobContainer"
+	ResourceTypeFileShare     ResourceType = "FileShare"
+	ResourceTypeDataLakeStore ResourceType = "DataLakeStore"
+	ResourceTypeBackupVault   ResourceType = "BackupVault"
+	ResourceTypeBackupPolicy  ResourceType = "BackupPolicy"
+
+	// Backup & DR
+	ResourceTypeMachineImage     ResourceType = "MachineImage"
+	ResourceTypeVolumeSnapshot   ResourceType = "VolumeSnapshot"
+	ResourceTypeDatabaseSnapshot ResourceType = "DatabaseSnapshot"
+	ResourceTypeRecoveryPoint    ResourceType = "RecoveryPoint"
+	ResourceTypeBackupItem       ResourceType = "BackupItem"
+	ResourceTypeReplicatedItem   ResourceType = "ReplicatedItem"
+
+	// Databases
+	ResourceTypeDatabaseInstance     ResourceType = "DatabaseInstance"
+	ResourceTypeDatabaseCluster      ResourceType = "DatabaseCluster"
+	ResourceTypeDatabaseServer       ResourceType = "DatabaseServer"
+	ResourceTypeDatabase             ResourceType = "Database"
+	ResourceTypeDatabaseElasticPool  ResourceType = "DatabaseElasticPool"
+	ResourceTypeGraphDatabaseCluster ResourceType = "GraphDatabaseCluster"
+	ResourceTypeNoSQLTable           ResourceType = "NoSQLTable"
+	ResourceTypeNoSQLAccount         ResourceType = "NoSQLAccount"
+	ResourceTypeCache                ResourceType = "Cache"
+	ResourceTypeDataWarehouse        ResourceType = "DataWarehouse"
+
+	// Networking
+	ResourceTypeVirtualNetwork           ResourceType = "VirtualNetwork"
+	ResourceTypeLoadBalancer             ResourceType = "LoadBalancer"
+	ResourceTypeNATGateway               ResourceType = "NATGateway"
+	ResourceTypeInternetGateway          ResourceType = "InternetGateway"
+	ResourceTypeNetworkSecurityGroup     ResourceType = "NetworkSecurityGroup"
+	ResourceTypeNetworkACL               ResourceType = "NetworkACL"
+	ResourceTypeSubnet                   ResourceType = "Subnet"
+	ResourceTypeNetworkPeering           ResourceType = "NetworkPeering"
+	ResourceTypeTransitGateway           ResourceType = "TransitGateway"
+	ResourceTypeTransitGatewayAttachment ResourceType = "TransitGatewayAttachment"
+	ResourceTypeNetworkInterface         ResourceType = "NetworkInterface"
+	ResourceTypeNetworkWatcher           ResourceType = "NetworkWatcher"
+	ResourceTypePrivateEndpoint          ResourceType = "PrivateEndpoint"
+	ResourceTypePublicIPAddress          ResourceType = "PublicIPAddress"
+	ResourceTypeRouteTable               ResourceType = "RouteTable"
+	ResourceTypeFirewall                 ResourceType = "Firewall"
+	ResourceTypeFirewallPolicy           ResourceType = "FirewallPolicy"
+	ResourceTypeDDoSProtection           ResourceType = "DDoSProtectionPlan"
+	ResourceTypeWideAreaNetwork          ResourceType = "WideAreaNetwork"
+	ResourceTypeBastionHost              ResourceType = "BastionHost"
+	ResourceTypeVPNConnection            ResourceType = "VPNConnection"
+	ResourceTypeVPNGateway               ResourceType = "VPNGateway"
+	ResourceTypeLocalNetworkGateway      ResourceType = "LocalNetworkGateway"
+	ResourceTypeDedicatedInterconnect    ResourceType = "DedicatedInterconnect"
+	ResourceTypeCDNDistribution          ResourceType = "CDNDistribution"
+	ResourceTypeCDNProfile               ResourceType = "CDNProfile"
+	ResourceTypeDNSZone                  ResourceType = "DNSZone"
+	ResourceTypeTrafficManager           ResourceType = "TrafficManager"
+	ResourceTypeAPIGateway               ResourceType = "APIGateway"
+	ResourceTypeAPIManagement            ResourceType = "APIManagement"
+
+	// Messaging and application integration
+	ResourceTypeMessageQueue       ResourceType = "MessageQueue"
+	ResourceTypeMessagingNamespace ResourceType = "MessagingNamespace"
+	ResourceTypePubSubTopic        ResourceType = "PubSubTopic"
+	ResourceTypeWorkflow           ResourceType = "Workflow"
+	ResourceTypeRealtimeMessaging  ResourceType = "RealtimeMessaging"
+	ResourceTypeEventBus           ResourceType = "EventBus"
+	ResourceTypeEventRule          ResourceType = "EventRule"
+
+	// Analytics
+	ResourceTypeDataStream         ResourceType = "DataStream"
+	ResourceTypeEventStreaming     ResourceType = "EventStreamingNamespace"
+	ResourceTypeDataDeliveryStream ResourceType = "DataDeliveryStream"
+	ResourceTypeETLJob             ResourceType = "ETLJob"
+	ResourceTypeDataCrawler        ResourceType = "DataCrawler"
+	ResourceTypeDataIntegration    ResourceType = "DataIntegration"
+	ResourceTypeQueryWorkgroup     ResourceType = "QueryWorkgroup"
+	ResourceTypeBigDataCluster     ResourceType = "BigDataCluster"
+	ResourceTypeKafkaCluster       ResourceType = "KafkaCluster"
+	ResourceTypeSearchService      ResourceType = "SearchService"
+	ResourceTypeDatabricks         ResourceType = "DatabricksWorkspace"
+	ResourceTypeDataGovernance     ResourceType = "DataGovernance"
+	ResourceTypeLogWorkspace       ResourceType = "LogWorkspace"
+	ResourceTypeLogGroup           ResourceType = "LogGroup"
+	ResourceTypeApplicationMonitor ResourceType = "ApplicationMonitor"
+	ResourceTypeMetricAlarm        ResourceType = "MetricAlarm"
+
+	// Machine learning
+	ResourceTypeMLNotebook     ResourceType = "MLNotebook"
+	ResourceTypeMLEndpoint     ResourceType = "MLEndpoint"
+	ResourceTypeMLWorkspace    ResourceType = "MLWorkspace"
+	ResourceTypeAIServices     ResourceType = "AIServicesAccount"
+	ResourceTypeOpenAIServices ResourceType = "OpenAIAccount"
+
+	// Developer tools and management
+	ResourceTypeCodeRepository      ResourceType = "CodeRepository"
+	ResourceTypeDevOpsProject       ResourceType = "DevOpsProject"
+	ResourceTypeBuildProject        ResourceType = "BuildProject"
+	ResourceTypeDeployment          ResourceType = "DeploymentApplication"
+	ResourceTypePipeline            ResourceType = "Pipeline"
+	ResourceTypeInfrastructureStack ResourceType = "InfrastructureStack"
+	ResourceTypeAutomationAccount   ResourceType = "AutomationAccount"
+	ResourceTypeMigrationInstance   ResourceType = "MigrationInstance"
+
+	// Identity and security
+	ResourceTypeUser               ResourceType = "User"
+	ResourceTypeGroup              ResourceType = "Group"
+	ResourceTypeRole               ResourceType = "Role"
+	ResourceTypePolicy             ResourceType = "Policy"
+	ResourceTypeRoleAssignment     ResourceType = "RoleAssignment"
+	ResourceTypeServicePrincipal   ResourceType = "ServicePrincipal"
+	ResourceTypeAppRegistration    ResourceType = "AppRegistration"
+	ResourceTypeEncryptionKey      ResourceType = "EncryptionKey"
+	ResourceTypeSecret             ResourceType = "Secret"
+	ResourceTypeKeyVault           ResourceType = "KeyVault"
+	ResourceTypeCertificate        ResourceType = "Certificate"
+	ResourceTypeHSMCluster         ResourceType = "HSMCluster"
+	ResourceTypeThreatDetector     ResourceType = "ThreatDetector"
+	ResourceTypeSecurityPlan       ResourceType = "SecurityPlan"
+	ResourceTypeComplianceStandard ResourceType = "ComplianceStandard"
+	ResourceTypeConfigRecorder     ResourceType = "ConfigRecorder"
+	ResourceTypeConfigRule         ResourceType = "ConfigRule"
+	ResourceTypeAuditTrail         ResourceType = "AuditTrail"
+	ResourceTypeVulnerabilityScan  ResourceType = "VulnerabilityScanning"
+	ResourceTypeWebACL             ResourceType = "WebACL"
+	ResourceTypeSIEMWorkspace      ResourceType = "SIEMWorkspace"
 )
+
+// CountMethod selects the API used to count a resource type
+type CountMethod string
+
+const (
+	CountMethodTaggingAPI    CountMethod = "tagging_api"
+	CountMethodResourceGraph CountMethod = "resource_graph"
+
+	// CountMethodServiceAPI counts an AWS type with a dedicated call to its
+	// own service API, for types the tagging API does not return
+	CountMethodServiceAPI CountMethod = "service_api"
+
+	// CountMethodSecurityPricing counts Defender for Cloud plans on the
+	// Standard tier through the Security Center pricing API
+	CountMethodSecurityPricing CountMethod = "security_pricing"
+
+	// CountMethodKubernetesAPI counts the objects of a Kubernetes kind
+	// through the API server of each cluster
+	CountMethodKubernetesAPI CountMethod = "kubernetes_api"
+
+	// CountMethodResourceSearch counts OCI resources through the Search
+	// service of each subscribed region
+	CountMethodResourceSearch CountMethod = "resource_search"
+)
+
+// DefaultGraphTable is the Resource Graph table queried when a definition does
+// not name one
+const DefaultGraphTable = "Resources"
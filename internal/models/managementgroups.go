@@ -0,0 +1,90 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// UnassignedManagementGroup is the management group total of subscriptions
+// not found under any management group the credentials can see
+const UnassignedManagementGroup = "unassigned"
+
+// ManagementGroupRef names an Azure management group
+type ManagementGroupRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ManagementGroupTotal is the subtotal of the subscriptions under one
+// management group, directly or through its descendants
+type ManagementGroupTotal struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Path holds the names of the group and the groups above it, from the
+	// top visible group down
+	Path []string `json:"path"`
+
+	Subscriptions  int `json:"subscriptions"`
+	TotalResources int `json:"total_resources"`
+}
+
+// GroupByManagementGroup sums the resources of each subscription under every
+// management group of its path. Totals are in hierarchy order, each group
+// followed by its children sorted by name, with UnassignedManagementGroup
+// last when some subscriptions have no path.
+func GroupByManagementGroup(result *SizingResult) []ManagementGroupTotal {
+	perAccount := resourcesPerAccount(result)
+
+	totals := make(map[string]*ManagementGroupTotal)
+	var unassigned *ManagementGroupTotal
+	for _, account := range result.AccountCounts {
+		resources := perAccount[strings.ToLower(account.ID)]
+		if len(account.ManagementGroupPath) == 0 {
+			if unassigned == nil {
+				unassigned = &ManagementGroupTotal{
+					ID: UnassignedManagementGroup, Name: UnassignedManagementGroup,
+					Path: []string{UnassignedManagementGroup},
+				}
+			}
+			unassigned.Subscriptions++
+			unassigned.TotalResources += resources
+			continue
+		}
+
+		var path []string
+		for _, group := range account.ManagementGroupPath {
+			path = append(path, group.Name)
+			total, ok := totals[group.ID]
+			if !ok {
+				total = &ManagementGroupTotal{ID: group.ID, Name: group.Name, Path: append([]string(nil), path...)}
+				totals[group.ID] = total
+			}
+			total.Subscriptions++
+			total.TotalResources += resources
+		}
+	}
+
+	sorted := make([]ManagementGroupTotal, 0, len(totals)+1)
+	for _, total := range totals {
+		sorted = append(sorted, *total)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return comparePaths(sorted[i].Path, sorted[j].Path) < 0
+	})
+	if unassigned != nil {
+		sorted = append(sorted, *unassigned)
+	}
+	return sorted
+}
+
+// comparePaths orders paths depth first: a parent before its children, and
+// siblings by name
+func comparePaths(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupByManagementGroup(t *testing.T) {
+	root := ManagementGroupRef{ID: "tenant", Name: "Tenant Root Group"}
+	prod := ManagementGroupRef{ID: "mg-prod", Name: "Production"}
+	corp := ManagementGroupRef{ID: "mg-corp", Name: "Corp"}
+	result := &SizingResult{
+		ResourceCounts: []*ResourceCount{
+			{Type: "microsoft.compute/virtualmachines", ByAccount: map[string]int{"sub-1": 10, "sub-2": 4, "sub-3": 1}},
+			{Type: "microsoft.storage/storageaccounts", ByAccount: map[string]int{"SUB-1": 2, "sub-4": 7}},
+		},
+		AccountCounts: []AccountCount{
+			{ID: "sub-1", ManagementGroupPath: []ManagementGroupRef{root, prod}},
+			{ID: "sub-2", ManagementGroupPath: []ManagementGroupRef{root, prod, corp}},
+			{ID: "sub-3", ManagementGroupPath: []ManagementGroupRef{root}},
+			{ID: "sub-4"},
+		},
+	}
+
+	got := GroupByManagementGroup(result)
+	want := []ManagementGroupTotal{
+		{ID: "tenant", Name: "Tenant Root Group", Path: []string{"Tenant Root Group"}, Subscriptions: 3, TotalResources: 17},
+		{ID: "mg-prod", Name: "Production", Path: []string{"Tenant Root Group", "Production"}, Subscriptions: 2, TotalResources: 16},
+		{ID: "mg-corp", Name: "Corp", Path: []string{"Tenant Root Group", "Production", "Corp"}, Subscriptions: 1, TotalResources: 4},
+		{ID: UnassignedManagementGroup, Name: UnassignedManagementGroup, Path: []string{UnassignedManagementGroup},
+			Subscriptions: 1, TotalResources: 7},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupByManagementGroup() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// MergeResults combines the results of several scans of one provider, e.g.
+// one per AWS profile, into a single result. An account found by more than
+// one scan keeps the numbers of the first: its resources are taken out of
+// the later scans' per-type totals and account breakdowns, though not out of
+// their location and state breakdowns, which are not kept per account.
+// Derived fields such as the estimate are left for the caller to set.
+func MergeResults(results ...*SizingResult) *SizingResult {
+	if len(results) == 0 {
+		return nil
+	}
+
+	first := results[0]
+	merged := &SizingResult{
+		SchemaVersion:       first.SchemaVersion,
+		Provider:            first.Provider,
+		Timestamp:           first.Timestamp,
+		WeightsFile:         first.WeightsFile,
+		AgentVersion:        first.AgentVersion,
+		LatestVersion:       first.LatestVersion,
+		Offline:             first.Offline,
+		AuthMethod:          first.AuthMethod,
+		AssumedRoleARN:      first.AssumedRoleARN,
+		OrganizationID:      first.OrganizationID,
+		IsManagementAccount: true,
+	}
+
+	seen := make(map[string]bool)
+	counts := make(map[ResourceType]*ResourceCount)
+	for _, result := range results {
+		if result.Timestamp.Before(merged.Timestamp) {
+			merged.Timestamp = result.Timestamp
+		}
+		if result.AssumedRoleARN != merged.AssumedRoleARN {
+			merged.AssumedRoleARN = ""
+		}
+		if result.OrganizationID != merged.OrganizationID {
+			merged.OrganizationID = ""
+		}
+		merged.IsManagementAccount = merged.IsManagementAccount && result.IsManagementAccount
+
+		duplicates := make(map[string]bool)
+		for _, account := range result.AccountCounts {
+			id := strings.ToLower(account.ID)
+			if seen[id] {
+				duplicates[id] = true
+				continue
+			}
+			seen[id] = true
+			merged.AccountCounts = append(merged.AccountCounts, account)
+		}
+		for _, account := range result.ExcludedAccounts {
+			if !slices.ContainsFunc(merged.ExcludedAccounts, func(e AccountCount) bool { return strings.EqualFold(e.ID, account.ID) }) {
+				merged.ExcludedAccounts = append(merged.ExcludedAccounts, account)
+			}
+		}
+		for _, directory := range result.Directories {
+			if !slices.ContainsFunc(merged.Directories, func(d AccountCount) bool { return strings.EqualFold(d.ID, directory.ID) }) {
+				merged.Directories = append(merged.Directories, directory)
+			}
+		}
+		merged.AccountsDiscovered += result.AccountsDiscovered - len(duplicates)
+		merged.AccountsScanned += result.AccountsScanned - len(duplicates)
+
+		for _, rc := range result.ResourceCounts {
+			count, ok := counts[rc.Type]
+			if !ok {
+				count = &ResourceCount{
+					Provider:      rc.Provider,
+					Type:          rc.Type,
+					DisplayName:   rc.DisplayName,
+					CanonicalType: rc.CanonicalType,
+					Category:      rc.Category,
+					Global:        rc.Global,
+					SubsetOf:      rc.SubsetOf,
+				}
+				counts[rc.Type] = count
+				merged.ResourceCounts = append(merged.ResourceCounts, count)
+			}
+			mergeCount(count, rc, duplicates)
+		}
+
+		for _, warning := range result.Warnings {
+			if !slices.Contains(merged.Warnings, warning) {
+				merged.Warnings = append(merged.Warnings, warning)
+			}
+		}
+		merged.SkippedRegions = append(merged.SkippedRegions, result.SkippedRegions...)
+		for _, region := range result.EmptyRegions {
+			if !slices.Contains(merged.EmptyRegions, region) {
+				merged.EmptyRegions = append(merged.EmptyRegions, region)
+			}
+		}
+	}
+	slices.Sort(merged.EmptyRegions)
+
+	merged.TotalResources = Total(merged.ResourceCounts)
+	if slices.ContainsFunc(results, func(r *SizingResult) bool { return r.RawTotalResources > 0 }) {
+		merged.RawTotalResources = RawTotal(merged.ResourceCounts)
+	}
+	merged.TotalAccounts = len(merged.AccountCounts)
+	return merged
+}
+
+// mergeCount adds rc to count, leaving out the accounts in duplicates
+func mergeCount(count, rc *ResourceCount, duplicates map[string]bool) {
+	total := rc.TotalResources
+	for id, n := range rc.ByAccount {
+		if duplicates[strings.ToLower(id)] {
+			total -= n
+			continue
+		}
+		if count.ByAccount == nil {
+			count.ByAccount = make(map[string]int, len(rc.ByAccount))
+		}
+		count.ByAccount[id] += n
+	}
+	count.TotalResources += total
+	count.NoiseExcluded += rc.NoiseExcluded
+	count.Images += rc.Images
+	count.Repositories += rc.Repositories
+	count.ContentUnknown += rc.ContentUnknown
+
+	count.ByLocation = addCounts(count.ByLocation, rc.ByLocation)
+	count.ByState = addCounts(count.ByState, rc.ByState)
+	count.BySKU = addCounts(count.BySKU, rc.BySKU)
+	count.ByLifecycle = addCounts(count.ByLifecycle, rc.ByLifecycle)
+	count.ByEngine = addCounts(count.ByEngine, rc.ByEngine)
+	count.ByKind = addCounts(count.ByKind, rc.ByKind)
+	count.ByExposure = addCounts(count.ByExposure, rc.ByExposure)
+	count.ByRuntime = addCounts(count.ByRuntime, rc.ByRuntime)
+
+	count.RegionsQueried = max(count.RegionsQueried, rc.RegionsQueried)
+	for _, region := range rc.FailedRegions {
+		if !slices.Contains(count.FailedRegions, region) {
+			count.FailedRegions = append(count.FailedRegions, region)
+		}
+	}
+	count.Truncated = count.Truncated || rc.Truncated
+	count.TagFilterNotApplied = count.TagFilterNotApplied || rc.TagFilterNotApplied
+
+	if rc.Stats != nil {
+		if count.Stats == nil {
+			stats := *rc.Stats
+			count.Stats = &stats
+		} else {
+			if rc.Stats.Start.Before(count.Stats.Start) {
+				count.Stats.Start = rc.Stats.Start
+			}
+			count.Stats.DurationMs += rc.Stats.DurationMs
+			count.Stats.Pages += rc.Stats.Pages
+			count.Stats.Retries += rc.Stats.Retries
+		}
+	}
+}
+
+// addCounts adds the counts of from to to, allocating to when needed
+func addCounts(to, from map[string]int) map[string]int {
+	if len(from) == 0 {
+		return to
+	}
+	if to == nil {
+		to = make(map[string]int, len(from))
+	}
+	for key, n := range from {
+		to[key] += n
+	}
+	return to
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeResults(t *testing.T) {
+	prod := &SizingResult{
+		Provider:  "AWS",
+		Timestamp: time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+		ResourceCounts: []*ResourceCount{
+			{Type: "ec2:instance", TotalResources: 3, ByAccount: map[string]int{"111111111111": 3},
+				ByLocation: map[string]int{"us-east-1": 3}, RegionsQueried: 2},
+		},
+		AccountCounts:      []AccountCount{{ID: "111111111111", Profile: "prod"}},
+		AccountsDiscovered: 1,
+		AccountsScanned:    1,
+		Warnings:           []string{"rds:db: access denied"},
+		EmptyRegions:       []string{"me-central-1"},
+	}
+	// The shared account is counted by prod already
+	dev := &SizingResult{
+		Provider:  "AWS",
+		Timestamp: time.Date(2024, 3, 1, 10, 29, 0, 0, time.UTC),
+		ResourceCounts: []*ResourceCount{
+			{Type: "ec2:instance", TotalResources: 5, ByAccount: map[string]int{"111111111111": 3, "222222222222": 2},
+				ByLocation: map[string]int{"eu-west-1": 5}, RegionsQueried: 3, FailedRegions: []string{"eu-west-2"}},
+			{Type: "s3:bucket", TotalResources: 4, ByAccount: map[string]int{"222222222222": 4}},
+		},
+		AccountCounts:      []AccountCount{{ID: "111111111111", Profile: "dev"}, {ID: "222222222222", Profile: "dev"}},
+		AccountsDiscovered: 2,
+		AccountsScanned:    2,
+		Warnings:           []string{"rds:db: access denied"},
+		EmptyRegions:       []string{"ap-south-2", "me-central-1"},
+	}
+
+	got := MergeResults(prod, dev)
+
+	want := &SizingResult{
+		Provider:  "AWS",
+		Timestamp: time.Date(2024, 3, 1, 10, 29, 0, 0, time.UTC),
+		ResourceCounts: []*ResourceCount{
+			{Type: "ec2:instance", TotalResources: 5, ByAccount: map[string]int{"111111111111": 3, "222222222222": 2},
+				ByLocation: map[string]int{"us-east-1": 3, "eu-west-1": 5}, RegionsQueried: 3, FailedRegions: []string{"eu-west-2"}},
+			{Type: "s3:bucket", TotalResources: 4, ByAccount: map[string]int{"222222222222": 4}},
+		},
+		AccountCounts:      []AccountCount{{ID: "111111111111", Profile: "prod"}, {ID: "222222222222", Profile: "dev"}},
+		AccountsDiscovered: 2,
+		AccountsScanned:    2,
+		TotalResources:     9,
+		TotalAccounts:      2,
+		Warnings:           []string{"rds:db: access denied"},
+		EmptyRegions:       []string{"ap-south-2", "me-central-1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeResults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergeResultsKeepsEachDirectoryOnce(t *testing.T) {
+	contoso := AccountCount{ID: "tenant-1", Name: "Contoso", ResourceCount: 40}
+	fabrikam := AccountCount{ID: "tenant-2", Name: "Fabrikam", ResourceCount: 7}
+	got := MergeResults(
+		&SizingResult{Provider: "Azure", Directories: []AccountCount{contoso}},
+		&SizingResult{Provider: "Azure", Directories: []AccountCount{{ID: "TENANT-1"}, fabrikam}},
+	)
+	if want := []AccountCount{contoso, fabrikam}; !reflect.DeepEqual(got.Directories, want) {
+		t.Errorf("Directories = %+v, want %+v", got.Directories, want)
+	}
+	if got.TotalAccounts != 0 {
+		t.Errorf("TotalAccounts = %d, want directories left out", got.TotalAccounts)
+	}
+}
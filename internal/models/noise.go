@@ -0,0 +1,28 @@
+package models
+
+// ExcludeAsNoise moves every resource of rc into NoiseExcluded, for a type
+// whose definition marks all of its resources as noise
+func (rc *ResourceCount) ExcludeAsNoise() {
+	rc.NoiseExcluded += rc.TotalResources
+	rc.TotalResources = 0
+	rc.ByLocation = nil
+	rc.ByAccount = nil
+	rc.ByState = nil
+	rc.BySKU = nil
+	rc.ByLifecycle = nil
+	rc.ByEngine = nil
+	rc.ByKind = nil
+	rc.ByExposure = nil
+	rc.ByRuntime = nil
+}
+
+// RawTotal is the total of counts including the noise excluded from them
+func RawTotal(counts []*ResourceCount) int {
+	total := 0
+	for _, rc := range counts {
+		if rc.SubsetOf == "" {
+			total += rc.TotalResources + rc.NoiseExcluded
+		}
+	}
+	return total
+}
@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestExcludeAsNoise(t *testing.T) {
+	rc := &ResourceCount{
+		Type:           "microsoft.network/networkwatchers",
+		TotalResources: 4,
+		ByLocation:     map[string]int{"eastus": 3, "westeurope": 1},
+		ByAccount:      map[string]int{"sub-1": 4},
+		NoiseExcluded:  1,
+	}
+	rc.ExcludeAsNoise()
+
+	if rc.TotalResources != 0 || rc.NoiseExcluded != 5 {
+		t.Errorf("TotalResources, NoiseExcluded = %d, %d, want 0, 5", rc.TotalResources, rc.NoiseExcluded)
+	}
+	if rc.ByLocation != nil || rc.ByAccount != nil {
+		t.Errorf("breakdowns kept: %v %v", rc.ByLocation, rc.ByAccount)
+	}
+}
+
+func TestRawTotal(t *testing.T) {
+	counts := []*ResourceCount{
+		{TotalResources: 10, NoiseExcluded: 3},
+		{TotalResources: 5},
+	}
+	if got := RawTotal(counts); got != 18 {
+		t.Errorf("RawTotal() = %d, want 18", got)
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TruncatedWarning describes the counts that stopped at the page limit of
+// --max-pages, or returns "" when there are none
+func TruncatedWarning(counts []*ResourceCount) string {
+	var types []string
+	for _, count := range counts {
+		if count.Truncated {
+			types = append(types, string(count.Type))
+		}
+	}
+	if len(types) == 0 {
+		return ""
+	}
+	sort.Strings(types)
+	return fmt.Sprintf("counting stopped at --max-pages for %d types, whose totals are lower bounds: %s",
+		len(types), strings.Join(types, ", "))
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncatedWarning(t *testing.T) {
+	if got := TruncatedWarning([]*ResourceCount{{Type: "a"}}); got != "" {
+		t.Errorf("TruncatedWarning() = %q, want empty", got)
+	}
+
+	got := TruncatedWarning([]*ResourceCount{
+		{Type: "z:type", Truncated: true},
+		{Type: "b:type"},
+		{Type: "a:type", Truncated: true},
+	})
+	if !strings.Contains(got, "2 types") || !strings.HasSuffix(got, "a:type, z:type") {
+		t.Errorf("TruncatedWarning() = %q", got)
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+// ScanPlan is what a scan would do, produced after authentication and
+// discovery without calling any count API
+type ScanPlan struct {
+	Provider      string         `json:"provider"`
+	Accounts      []AccountCount `json:"accounts"`
+	Regions       []string       `json:"regions,omitempty"`
+	ResourceTypes []PlannedType  `json:"resource_types"`
+
+	// EstimatedAPICalls is the least number of calls counting would make,
+	// one page per region, query or subscription; large estates need more
+	// pages
+	EstimatedAPICalls int `json:"estimated_api_calls"`
+
+	// Concurrency is the number of API calls the provider keeps in flight
+	// at most while counting; 0 when unknown
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// Warnings about types or regions the scan could not cover
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PlannedType is how one resource type would be counted
+type PlannedType struct {
+	Type        ResourceType `json:"type"`
+	DisplayName string       `json:"display_name"`
+	CountMethod CountMethod  `json:"count_method,omitempty"`
+
+	// API names the calls the type is counted with: IAM actions on AWS,
+	// the Resource Graph table or REST endpoint on Azure, the listed
+	// resource on Kubernetes and the Search operation on OCI
+	API string `json:"api"`
+
+	// Query is the Resource Graph or OCI Search query, for types counted
+	// with one
+	Query string `json:"query,omitempty"`
+
+	// EstimatedCalls is the least number of calls counting the type makes
+	EstimatedCalls int `json:"estimated_calls"`
+}
+
+// AddType appends planned to the plan and its calls to the estimate
+func (p *ScanPlan) AddType(planned PlannedType) {
+	p.ResourceTypes = append(p.ResourceTypes, planned)
+	p.EstimatedAPICalls += planned.EstimatedCalls
+}
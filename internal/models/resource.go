@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Resource represents a cloud resource
 type Resource struct {
@@ -15,42 +18,354 @@ type Resource struct {
 	Account   string            `json:"account,omitempty"`
 }
 
+// ResourceSink receives individual resources in inventory mode
+type ResourceSink interface {
+	Write(resource Resource) error
+}
+
+// CountSink receives each resource count as soon as its type is counted;
+// counts arrive concurrently
+type CountSink interface {
+	WriteCount(count *ResourceCount) error
+}
+
 // ResourceCount represents count statistics for resources
 type ResourceCount struct {
-	Provider       string         `json:"provider"`
-	Type           ResourceType   `json:"type"`
-	DisplayName    string         `json:"display_name"`
+	Provider    string       `json:"provider"`
+	Type        ResourceType `json:"type"`
+	DisplayName string       `json:"display_name"`
+
+	// CanonicalType lines the provider-native Type up with the other
+	// provider's, e.g. "VirtualMachine" for "ec2:instance"
+	CanonicalType ResourceType `json:"canonical_type,omitempty"`
+
+	Category       string         `json:"category,omitempty"`
 	TotalResources int            `json:"total_resources"`
-	ByLocation     map[string]int `json:"by_location"`
-	ByAccount      map[string]int `json:"by_account"`
+	ByLocation     map[string]int `json:"by_location,omitempty"`
+	ByAccount      map[string]int `json:"by_account,omitempty"`
+
+	// Populated only for types whose definition opts in
+	ByState     map[string]int `json:"by_state,omitempty"`
+	BySKU       map[string]int `json:"by_sku,omitempty"`
+	ByLifecycle map[string]int `json:"by_lifecycle,omitempty"`
+	ByEngine    map[string]int `json:"by_engine,omitempty"`
+
+	// ByKind splits a type into its variants, e.g. application, network,
+	// gateway and classic load balancers or zip and container functions, and
+	// ByExposure into public and internal resources
+	ByKind     map[string]int `json:"by_kind,omitempty"`
+	ByExposure map[string]int `json:"by_exposure,omitempty"`
+
+	// ByRuntime counts serverless functions by language runtime
+	ByRuntime map[string]int `json:"by_runtime,omitempty"`
+
+	// Global marks a type counted once rather than per region
+	Global bool `json:"global,omitempty"`
+
+	// SubsetOf is the type that already counts these resources, such as
+	// ec2:instance for EKS nodes; the count is reported but left out of
+	// the result's TotalResources
+	SubsetOf ResourceType `json:"subset_of,omitempty"`
+
+	// RegionsQueried is the number of regions the type was counted in, and
+	// FailedRegions those of them whose count failed and is missing from
+	// the total; set by providers that count region by region
+	RegionsQueried int      `json:"regions_queried,omitempty"`
+	FailedRegions  []string `json:"failed_regions,omitempty"`
+
+	// NoiseExcluded is the number of cloud-managed resources, such as
+	// default security groups, left out of TotalResources with
+	// --exclude-noise
+	NoiseExcluded int `json:"noise_excluded,omitempty"`
+
+	// Images and Repositories are what --deep-registries finds inside the
+	// counted container repositories and registries. ContentUnknown is the
+	// number of repositories or registries it could not read, which are
+	// counted but missing from Images and Repositories, or of storage
+	// accounts --deep-storage or Recovery Services vaults --deep-backup
+	// could not read.
+	Images         int `json:"images,omitempty"`
+	Repositories   int `json:"repositories,omitempty"`
+	ContentUnknown int `json:"content_unknown,omitempty"`
+
+	// Truncated marks a count that stopped at the page limit of --max-pages
+	// somewhere, so TotalResources is a lower bound
+	Truncated bool `json:"truncated,omitempty"`
+
+	// TagFilterNotApplied marks a count that includes resources regardless
+	// of the scan's tag filters, because its API cannot filter by tag
+	TagFilterNotApplied bool `json:"tag_filter_not_applied,omitempty"`
+
+	// Stats is how counting the type went; written with --stats only
+	Stats *ScanStats `json:"stats,omitempty"`
 }
 
 // AccountCount represents Azure|AWS account resource count
 type AccountCount struct {
 	ID            string               `json:"id"`
 	Name          string               `json:"name"`
+	Email         string               `json:"email,omitempty"`
 	Status        string               `json:"status"`
 	ResourceCount int                  `json:"resource_count"`
-	ByType        map[ResourceType]int `json:"by_type"`
+	ByType        map[ResourceType]int `json:"by_type,omitempty"`
+
+	// Tags are the subscription tags and Offer the subscription's quota ID,
+	// e.g. "EnterpriseAgreement_2014-09-01" (Azure)
+	Tags  map[string]string `json:"tags,omitempty"`
+	Offer string            `json:"offer,omitempty"`
+
+	// ManagementGroupPath is the chain of management groups above the
+	// subscription, from the top visible group down to its parent (Azure,
+	// with --management-groups)
+	ManagementGroupPath []ManagementGroupRef `json:"management_group_path,omitempty"`
+
+	// Profile is the AWS profile whose scan counted the account, with
+	// --profiles
+	Profile string `json:"profile,omitempty"`
+
+	// Tenant is the Azure tenant of the subscription, with azure_tenants;
+	// the account ID is then qualified as <tenant>/<subscription>
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Estimate is the workload-unit estimate derived from the resource counts
+type Estimate struct {
+	WorkloadUnits float64                  `json:"workload_units"`
+	Tier          string                   `json:"tier"`
+	ByType        map[ResourceType]float64 `json:"by_type"`
+}
+
+// Thresholds records the configured limits and whether the result exceeded them.
+// A zero limit is disabled.
+type Thresholds struct {
+	MaxResources      int  `json:"max_resources,omitempty"`
+	MaxAccounts       int  `json:"max_accounts,omitempty"`
+	ResourcesExceeded bool `json:"resources_exceeded"`
+	AccountsExceeded  bool `json:"accounts_exceeded"`
+}
+
+// Exceeded reports whether any limit was exceeded
+func (t *Thresholds) Exceeded() bool {
+	return t.ResourcesExceeded || t.AccountsExceeded
 }
 
+// SchemaVersion is the version of the result JSON format described by
+// result.schema.json. Bump it whenever a field is renamed or removed.
+const SchemaVersion = "2"
+
+// SizingResult is the outcome of a scan
 type SizingResult struct {
 	// Metadata
-	Provider  string
-	Timestamp time.Time
+	SchemaVersion string    `json:"schema_version"`
+	Provider      string    `json:"provider"`
+	Timestamp     time.Time `json:"timestamp"`
+	WeightsFile   string    `json:"weights_file,omitempty"`
+
+	// OutputFile is the file the result was written to, with any --output
+	// template expanded
+	OutputFile string `json:"output_file,omitempty"`
+
+	// AgentVersion is the agent build that produced the result; it also
+	// appears in the User-Agent of every API call
+	AgentVersion string `json:"agent_version,omitempty"`
+
+	// LatestVersion is the latest agent release when the update check found
+	// it, so that results from outdated builds can be spotted
+	LatestVersion string `json:"latest_version,omitempty"`
+
+	// Offline marks a scan run with --offline, which called no endpoint
+	// other than the cloud provider's: no update check, metrics push or
+	// trace export
+	Offline bool `json:"offline,omitempty"`
+
+	// AuthMethod is how the agent authenticated, where the provider offers a
+	// choice (Azure)
+	AuthMethod string `json:"auth_method,omitempty"`
+
+	// AssumedRoleARN is the AWS role the scan ran as, if one was assumed
+	AssumedRoleARN string `json:"assumed_role_arn,omitempty"`
+
+	// OrganizationID is the AWS organization of the scanned account, if it
+	// belongs to one
+	OrganizationID string `json:"organization_id,omitempty"`
+
+	// IsManagementAccount is set when the AWS scan ran in the management
+	// account of its organization
+	IsManagementAccount bool `json:"is_management_account,omitempty"`
+
+	// AccountsDiscovered is the number of accounts or subscriptions found,
+	// and AccountsScanned the number whose resources were counted. Fewer
+	// scanned than discovered means the counts cover part of the estate.
+	AccountsDiscovered int `json:"accounts_discovered,omitempty"`
+	AccountsScanned    int `json:"accounts_scanned,omitempty"`
 
 	// Your existing models
-	ResourceCounts []*ResourceCount
-	AccountCounts  []AccountCount
+	ResourceCounts []*ResourceCount `json:"resource_counts"`
+	AccountCounts  []AccountCount   `json:"account_counts"`
+
+	// Directories are the identity directories whose users, groups and
+	// applications were counted with --include-identity: the Entra ID
+	// tenant or the Okta org. They are not accounts and not in
+	// TotalAccounts.
+	Directories []AccountCount `json:"directories,omitempty"`
+
+	// Totals (calculated from above); TotalResources leaves out the counts
+	// that are a subset of another type
+	TotalResources int `json:"total_resources"`
+	TotalAccounts  int `json:"total_accounts"`
+
+	// RawTotalResources is the total before cloud-managed noise was
+	// excluded from TotalResources, with --exclude-noise
+	RawTotalResources int `json:"raw_total_resources,omitempty"`
+
+	// BillableWorkloads is the total of the BillableTypes counts: the
+	// workloads a license is priced on, as opposed to every resource
+	BillableWorkloads int            `json:"billable_workloads"`
+	BillableTypes     []ResourceType `json:"billable_types,omitempty"`
+
+	// AccountGroups subtotals the resources per value of the account tag
+	// AccountGroupTag, with --group-accounts-by-tag
+	AccountGroupTag string         `json:"account_group_tag,omitempty"`
+	AccountGroups   []AccountGroup `json:"account_groups,omitempty"`
+
+	// ManagementGroups subtotals the resources under each Azure management
+	// group, with --management-groups
+	ManagementGroups []ManagementGroupTotal `json:"management_groups,omitempty"`
+
+	// Estimate derived from the counts
+	Estimate *Estimate `json:"estimate,omitempty"`
+
+	// Thresholds configured for CI usage, if any
+	Thresholds *Thresholds `json:"thresholds,omitempty"`
+
+	// TagFilters limited the scan to resources with these tags, if any
+	TagFilters []TagFilter `json:"tag_filters,omitempty"`
+
+	// AccountFilter limited the scan to the accounts or subscriptions it
+	// matches, with --account-filter and --account-tag, and
+	// ExcludedAccounts are those discovered but left out by it
+	AccountFilter    *AccountFilter `json:"account_filter,omitempty"`
+	ExcludedAccounts []AccountCount `json:"excluded_accounts,omitempty"`
+
+	// Scope is what the scan covered when filters left out resource types
+	// or regions
+	Scope *ScanScope `json:"scope,omitempty"`
+
+	// Warnings about parts of the scan that were skipped or incomplete
+	Warnings []string `json:"warnings,omitempty"`
+
+	// CredentialsExpired marks a result cut short because the credentials
+	// expired during the scan; the types not counted by then are missing
+	CredentialsExpired bool `json:"credentials_expired,omitempty"`
+
+	// LogWarnings are the warnings and errors logged during the scan, the
+	// last of them when LogWarningsDropped earlier ones did not fit
+	LogWarnings        []LogWarning `json:"log_warnings,omitempty"`
+	LogWarningsDropped int          `json:"log_warnings_dropped,omitempty"`
+
+	// SkippedRegions lists regions left out of the scan because they were
+	// not accessible
+	SkippedRegions []SkippedRegion `json:"skipped_regions,omitempty"`
+
+	// EmptyRegions lists regions the region probe found without tagged
+	// resources; types counted with the tagging API skipped them
+	EmptyRegions []string `json:"empty_regions,omitempty"`
 
-	// Totals (calculated from above)
-	TotalResources int
-	TotalAccounts  int
+	// APICalls counts the cloud API calls the scan made
+	APICalls []APICallCount `json:"api_calls,omitempty"`
+}
+
+// Incomplete reports whether resources were counted in fewer accounts or
+// subscriptions than were discovered
+func (r *SizingResult) Incomplete() bool {
+	return r.AccountsScanned < r.AccountsDiscovered
+}
+
+// WithoutStats returns a copy of the result whose resource counts carry no
+// Stats, leaving r unchanged
+func (r *SizingResult) WithoutStats() *SizingResult {
+	stripped := *r
+	stripped.ResourceCounts = make([]*ResourceCount, len(r.ResourceCounts))
+	for i, rc := range r.ResourceCounts {
+		count := *rc
+		count.Stats = nil
+		stripped.ResourceCounts[i] = &count
+	}
+	return &stripped
+}
+
+// MarshalJSON writes the timestamp as RFC 3339 in UTC without fractional
+// seconds and empty lists as [] rather than null
+func (r SizingResult) MarshalJSON() ([]byte, error) {
+	type plain SizingResult
+	out := plain(r)
+	out.Timestamp = r.Timestamp.UTC().Truncate(time.Second)
+	if out.ResourceCounts == nil {
+		out.ResourceCounts = []*ResourceCount{}
+	}
+	if out.AccountCounts == nil {
+		out.AccountCounts = []AccountCount{}
+	}
+	return json.Marshal(out)
+}
+
+// APICallCount is the number of calls of one API operation
+type APICallCount struct {
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+	Count     int    `json:"count"`
+}
+
+// ScanStats is how counting one resource type went: when it started, how
+// long it took, and the API calls (pages) and retries it made
+type ScanStats struct {
+	Start      time.Time `json:"start"`
+	DurationMs int64     `json:"duration_ms"`
+	Pages      int64     `json:"pages"`
+	Retries    int64     `json:"retries"`
+}
+
+// LogWarning is a warning or error logged during the scan, with its fields
+type LogWarning struct {
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// SkippedRegion is a region left out of the scan and the reason
+type SkippedRegion struct {
+	Region string `json:"region"`
+	Reason string `json:"reason"`
 }
 
 type ResourceDefinition struct {
-	Type             string // Azure resource type (e.g., "microsoft.compute/virtualmachines")
-	DisplayName      string // Human-friendly name
-	Category         string // Category for grouping
-	UseResourceGraph bool   // Whether to use Resource Graph for counting
+	Type        string      `yaml:"type"`         // Provider resource type (e.g., "microsoft.compute/virtualmachines")
+	Key         string      `yaml:"key"`          // Distinguishes definitions sharing a type, as "<type>#<variant>"
+	DisplayName string      `yaml:"display_name"` // Human-friendly name
+	Category    string      `yaml:"category"`     // Category for grouping
+	CountMethod CountMethod `yaml:"count_method"` // How the type is counted
+	Global      bool        `yaml:"global"`       // Not regional; count once instead of per region
+	GraphTable  string      `yaml:"graph_table"`  // Resource Graph table to query (default "Resources")
+	KqlFilter   string      `yaml:"kql_filter"`   // Extra Resource Graph "where" condition
+	StateField  string      `yaml:"state_field"`  // Resource Graph expression for the ByState breakdown
+	SKUField    string      `yaml:"sku_field"`    // Resource Graph expression for the BySKU breakdown
+	PublicField string      `yaml:"public_field"` // Resource Graph field set only on public resources, for the ByExposure breakdown
+	SumField    string      `yaml:"sum_field"`    // Resource Graph expression summed instead of counting rows
+	NoiseFilter string      `yaml:"noise_filter"` // Resource Graph condition matching cloud-managed resources, excluded with --exclude-noise
+	Noise       bool        `yaml:"noise"`        // Every resource of the type is cloud-managed; excluded with --exclude-noise
+	Billable    bool        `yaml:"billable"`     // Counts towards the billable workloads headline
+	SubsetOf    string      `yaml:"subset_of"`    // Type already counting these resources; kept out of the totals
+	Disabled    bool        `yaml:"disabled"`     // Removes the type when merging an override file
+
+	// Canonical is the canonical type for a type with no built-in mapping
+	Canonical ResourceType `yaml:"canonical_type"`
+}
+
+// ResourceType returns the type reported for counts of this definition: the
+// key when several definitions share a type, otherwise the type itself
+func (d ResourceDefinition) ResourceType() ResourceType {
+	if d.Key != "" {
+		return ResourceType(d.Key)
+	}
+	return ResourceType(d.Type)
 }
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SanityWarningPrefix starts every warning of SanityWarnings, so that
+// reports can tell them from the other warnings and show them first
+const SanityWarningPrefix = "suspicious result: "
+
+// manyAccounts is the number of scanned accounts or subscriptions from which
+// finding resources in only one of them is suspicious
+const manyAccounts = 10
+
+// SanityWarnings flags results that are more likely wrong than true: nothing
+// found at all, types that failed in most of their regions, resources in
+// only one of many accounts, and global types counted in several regions.
+// The checks need no thresholds and look at the result only.
+func SanityWarnings(result *SizingResult) []string {
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, SanityWarningPrefix+fmt.Sprintf(format, args...))
+	}
+
+	if len(result.ResourceCounts) > 0 && result.TotalResources == 0 {
+		hint := "check that the credentials can read resources and that the right accounts/subscriptions were scanned"
+		if len(result.TagFilters) > 0 {
+			hint += ", and that the tag filters match"
+		}
+		warn("no resources were found in any of the %d types counted although authentication succeeded; %s",
+			len(result.ResourceCounts), hint)
+	}
+
+	for _, rc := range result.ResourceCounts {
+		if failed := len(rc.FailedRegions); failed > 0 && 2*failed > rc.RegionsQueried {
+			warn("%s could not be counted in %d of %d regions (%s); its count is likely too low",
+				rc.DisplayName, failed, rc.RegionsQueried, strings.Join(rc.FailedRegions, ", "))
+		}
+	}
+
+	// No account with resources means the counts carry no attribution to
+	// judge by, rather than that every account is empty
+	if result.AccountsScanned >= manyAccounts && accountsWithResources(result) == 1 {
+		warn("resources were found in only 1 of %d accounts/subscriptions scanned; "+
+			"check that the credentials can read the others", result.AccountsScanned)
+	}
+
+	for _, rc := range result.ResourceCounts {
+		if rc.Global && len(rc.ByLocation) > 1 {
+			regions := make([]string, 0, len(rc.ByLocation))
+			for region := range rc.ByLocation {
+				regions = append(regions, region)
+			}
+			sort.Strings(regions)
+			warn("%s is a global service but was counted in %d regions (%s); it may be counted more than once",
+				rc.DisplayName, len(regions), strings.Join(regions, ", "))
+		}
+	}
+
+	return warnings
+}
+
+// IsSanityWarning reports whether warning was produced by SanityWarnings
+func IsSanityWarning(warning string) bool {
+	return strings.HasPrefix(warning, SanityWarningPrefix)
+}
+
+// accountsWithResources returns the number of accounts any resources are
+// attributed to, through the per-type account breakdowns or the account
+// totals
+func accountsWithResources(result *SizingResult) int {
+	accounts := make(map[string]bool)
+	for _, rc := range result.ResourceCounts {
+		for id, count := range rc.ByAccount {
+			if count > 0 {
+				accounts[strings.ToLower(id)] = true
+			}
+		}
+	}
+	for _, account := range result.AccountCounts {
+		if account.ResourceCount > 0 {
+			accounts[strings.ToLower(account.ID)] = true
+		}
+	}
+	return len(accounts)
+}
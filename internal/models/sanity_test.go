@@ -0,0 +1,170 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSanityWarnings(t *testing.T) {
+	ec2 := func(total int) *ResourceCount {
+		return &ResourceCount{Type: "ec2:instance", DisplayName: "EC2 Instances", TotalResources: total}
+	}
+	// accounts returns n scanned accounts with the resources of the first
+	// `with` attributed to them
+	accounts := func(n, with int) *SizingResult {
+		result := &SizingResult{AccountsScanned: n, AccountsDiscovered: n}
+		rc := &ResourceCount{Type: "s3:bucket", DisplayName: "S3 Buckets", ByAccount: map[string]int{}}
+		for i := range n {
+			id := fmt.Sprintf("%012d", i)
+			result.AccountCounts = append(result.AccountCounts, AccountCount{ID: id})
+			if i < with {
+				rc.ByAccount[id] = 3
+				rc.TotalResources += 3
+			}
+		}
+		result.ResourceCounts = []*ResourceCount{rc}
+		result.TotalResources = rc.TotalResources
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		result *SizingResult
+		// want are substrings of the expected warnings, in order
+		want []string
+	}{
+		{
+			name: "healthy result",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{ec2(5)}, TotalResources: 5, AccountsScanned: 1,
+			},
+		},
+		{
+			name:   "all counts zero",
+			result: &SizingResult{ResourceCounts: []*ResourceCount{ec2(0), {Type: "s3:bucket"}}},
+			want:   []string{"no resources were found in any of the 2 types counted"},
+		},
+		{
+			name: "all counts zero with tag filters",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{ec2(0)},
+				TagFilters:     []TagFilter{{Key: "env", Value: "prod"}},
+			},
+			want: []string{"and that the tag filters match"},
+		},
+		{
+			name:   "nothing counted at all",
+			result: &SizingResult{},
+		},
+		{
+			name: "type failed in most regions",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{{
+					DisplayName: "Lambda Functions", TotalResources: 4,
+					RegionsQueried: 5, FailedRegions: []string{"ap-south-1", "eu-west-1", "us-west-2"},
+				}},
+				TotalResources: 4,
+			},
+			want: []string{"Lambda Functions could not be counted in 3 of 5 regions (ap-south-1, eu-west-1, us-west-2)"},
+		},
+		{
+			name: "type failed in half of its regions",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{{
+					DisplayName: "Lambda Functions", TotalResources: 4,
+					RegionsQueried: 4, FailedRegions: []string{"eu-west-1", "us-west-2"},
+				}},
+				TotalResources: 4,
+			},
+		},
+		{
+			name: "global type failed in its only region",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{
+					ec2(2),
+					{DisplayName: "IAM Roles", Global: true, RegionsQueried: 1, FailedRegions: []string{"us-east-1"}},
+				},
+				TotalResources: 2,
+			},
+			want: []string{"IAM Roles could not be counted in 1 of 1 regions"},
+		},
+		{
+			name:   "resources in one of many accounts",
+			result: accounts(24, 1),
+			want:   []string{"resources were found in only 1 of 24 accounts/subscriptions scanned"},
+		},
+		{
+			name:   "resources in several of many accounts",
+			result: accounts(24, 2),
+		},
+		{
+			name:   "resources in one of few accounts",
+			result: accounts(3, 1),
+		},
+		{
+			name: "no account attribution",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{ec2(7)}, TotalResources: 7, AccountsScanned: 30,
+			},
+		},
+		{
+			name: "global type in several regions",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{{
+					DisplayName: "CloudFront Distributions", Global: true, TotalResources: 6,
+					ByLocation: map[string]int{"us-east-1": 3, "eu-west-1": 3},
+				}},
+				TotalResources: 6,
+			},
+			want: []string{"CloudFront Distributions is a global service but was counted in 2 regions (eu-west-1, us-east-1)"},
+		},
+		{
+			name: "regional type in several regions",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{{
+					DisplayName: "EC2 Instances", TotalResources: 6,
+					ByLocation: map[string]int{"us-east-1": 3, "eu-west-1": 3},
+				}},
+				TotalResources: 6,
+			},
+		},
+		{
+			name: "several problems",
+			result: &SizingResult{
+				ResourceCounts: []*ResourceCount{
+					{DisplayName: "EC2 Instances", RegionsQueried: 3, FailedRegions: []string{"a", "b", "c"}},
+					{DisplayName: "Route 53 Hosted Zones", Global: true, ByLocation: map[string]int{"a": 0, "b": 0}},
+				},
+			},
+			want: []string{
+				"no resources were found",
+				"EC2 Instances could not be counted in 3 of 3 regions",
+				"Route 53 Hosted Zones is a global service",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanityWarnings(tt.result)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SanityWarnings() = %q, want %d warnings", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("warning %d = %q, want it to contain %q", i, got[i], want)
+				}
+				if !IsSanityWarning(got[i]) {
+					t.Errorf("warning %d = %q lacks the sanity prefix", i, got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestIsSanityWarning(t *testing.T) {
+	if IsSanityWarning("2 resource types could not be counted: a, b") {
+		t.Error("IsSanityWarning() = true for a scan warning")
+	}
+}
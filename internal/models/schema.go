@@ -0,0 +1,212 @@
+package models
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ResultSchema is the JSON Schema of the result file written with
+// --format json
+//
+//go:embed result.schema.json
+var ResultSchema []byte
+
+// SchemaViolation is one way a result document departs from ResultSchema
+type SchemaViolation struct {
+	// Path locates the offending value, e.g. "resource_counts[2].type"
+	Path    string
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// ValidateResult checks a result JSON document against ResultSchema and
+// returns every violation, ordered by path. The error is reserved for data
+// that is not JSON at all.
+func ValidateResult(data []byte) ([]SchemaViolation, error) {
+	var root jsonSchema
+	if err := json.Unmarshal(ResultSchema, &root); err != nil {
+		return nil, fmt.Errorf("invalid embedded result schema: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("failed to parse result JSON: %w", err)
+	}
+
+	v := &validator{defs: root.Defs}
+	v.validate(&root, document, "")
+	sort.SliceStable(v.violations, func(i, j int) bool {
+		return v.violations[i].Path < v.violations[j].Path
+	})
+	return v.violations, nil
+}
+
+// jsonSchema is the subset of JSON Schema that result.schema.json uses
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+	Type                 schemaTypes            `json:"type"`
+	Const                any                    `json:"const"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+
+	// forbidden is set for the boolean schema false
+	forbidden bool
+}
+
+// UnmarshalJSON accepts the boolean schemas true and false as well as objects
+func (s *jsonSchema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = jsonSchema{}
+		return nil
+	case "false":
+		*s = jsonSchema{forbidden: true}
+		return nil
+	}
+	type plain jsonSchema
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// schemaTypes is a "type" keyword, given as one name or a list
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = schemaTypes{name}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+type validator struct {
+	defs       map[string]*jsonSchema
+	violations []SchemaViolation
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	v.violations = append(v.violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(schema *jsonSchema, value any, path string) {
+	if schema.Ref != "" {
+		target, ok := v.defs[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+		if !ok {
+			v.fail(path, "schema reference %s not found", schema.Ref)
+			return
+		}
+		schema = target
+	}
+
+	if len(schema.Type) > 0 && !matchesType(schema.Type, value) {
+		v.fail(path, "expected %s, got %s", strings.Join(schema.Type, " or "), typeName(value))
+		return
+	}
+	if schema.Const != nil && !reflect.DeepEqual(schema.Const, value) {
+		v.fail(path, "must be %v, got %v", formatValue(schema.Const), formatValue(value))
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		v.validateObject(schema, value, path)
+	case []any:
+		if schema.Items != nil {
+			for i, item := range value {
+				v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func (v *validator) validateObject(schema *jsonSchema, object map[string]any, path string) {
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			v.fail(joinPath(path, name), "missing required field")
+		}
+	}
+
+	for name, value := range object {
+		if property, ok := schema.Properties[name]; ok {
+			v.validate(property, value, joinPath(path, name))
+			continue
+		}
+		switch {
+		case schema.AdditionalProperties == nil:
+		case schema.AdditionalProperties.forbidden:
+			v.fail(joinPath(path, name), "unexpected field")
+		default:
+			v.validate(schema.AdditionalProperties, value, joinPath(path, name))
+		}
+	}
+}
+
+func matchesType(types schemaTypes, value any) bool {
+	for _, name := range types {
+		switch name {
+		case "integer":
+			if number, ok := value.(json.Number); ok {
+				if _, err := number.Int64(); err == nil {
+					return true
+				}
+			}
+		case "number":
+			if _, ok := value.(json.Number); ok {
+				return true
+			}
+		default:
+			if typeName(value) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// typeName returns the JSON Schema type name of a decoded value
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func formatValue(value any) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(value)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fullResult sets every field, so that a field missing from the schema shows
+// up as an unexpected field
+func fullResult() *SizingResult {
+	return &SizingResult{
+		SchemaVersion:  SchemaVersion,
+		Provider:       "Azure",
+		Timestamp:      time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+		WeightsFile:    "weights.yaml",
+		AgentVersion:   "v1.2.3",
+		AuthMethod:     "cli",
+		AssumedRoleARN: "arn:aws:iam::123456789012:role/Audit",
+
+		OrganizationID:      "o-abc123",
+		IsManagementAccount: true,
+		AccountsDiscovered:  2,
+		AccountsScanned:     1,
+
+		ResourceCounts: []*ResourceCount{{
+			Provider:       "azure",
+			Type:           "microsoft.compute/virtualmachines",
+			DisplayName:    "Virtual Machines",
+			CanonicalType:  ResourceTypeVirtualMachine,
+			Category:       "Compute",
+			TotalResources: 3,
+			ByLocation:     map[string]int{"westeurope": 3},
+			ByAccount:      map[string]int{"sub-1": 3},
+			ByState:        map[string]int{"running": 3},
+			BySKU:          map[string]int{"Standard_B2s": 3},
+			ByLifecycle:    map[string]int{"active": 3},
+			ByEngine:       map[string]int{"postgres": 3},
+			Global:         true,
+			RegionsQueried: 2,
+			FailedRegions:  []string{"northeurope"},
+
+			Truncated:           true,
+			TagFilterNotApplied: true,
+			Stats: &ScanStats{
+				Start: time.Date(2024, 3, 1, 10, 29, 58, 0, time.UTC), DurationMs: 1250, Pages: 3, Retries: 1,
+			},
+		}},
+		AccountCounts: []AccountCount{{
+			ID: "sub-1", Name: "prod", Email: "ops@example.com", Status: "Enabled", ResourceCount: 3,
+			ByType: map[ResourceType]int{"microsoft.compute/virtualmachines": 3},
+			Tags:   map[string]string{"environment": "prod"}, Offer: "EnterpriseAgreement_2014-09-01",
+			ManagementGroupPath: []ManagementGroupRef{{ID: "tenant-1", Name: "Tenant Root Group"}, {ID: "corp", Name: "Corp"}},
+			Profile:             "prod",
+			Tenant:              "tenant-1",
+		}},
+		Directories: []AccountCount{{
+			ID: "tenant-1", Name: "Contoso", Status: "Active", ResourceCount: 40,
+			ByType: map[ResourceType]int{"microsoft.graph/users": 40},
+		}},
+		TotalResources:    3,
+		TotalAccounts:     1,
+		BillableWorkloads: 3,
+		BillableTypes:     []ResourceType{"microsoft.compute/virtualmachines"},
+		AccountGroupTag:   "environment",
+		AccountGroups:     []AccountGroup{{Value: "prod", Accounts: 1, TotalResources: 3}},
+		ManagementGroups: []ManagementGroupTotal{
+			{ID: "tenant-1", Name: "Tenant Root Group", Path: []string{"Tenant Root Group"}, Subscriptions: 1, TotalResources: 3},
+		},
+		Estimate: &Estimate{
+			WorkloadUnits: 4.5,
+			Tier:          "small",
+			ByType:        map[ResourceType]float64{"microsoft.compute/virtualmachines": 4.5},
+		},
+		Thresholds: &Thresholds{MaxResources: 10, MaxAccounts: 2},
+		TagFilters: []TagFilter{{Key: "CostCenter", Value: "42"}},
+		AccountFilter: &AccountFilter{
+			Names: []string{"prod-*"},
+			Tags:  []TagFilter{{Key: "env", Value: "prod"}},
+		},
+		ExcludedAccounts: []AccountCount{{ID: "sub-2", Name: "dev-data", Status: "Enabled"}},
+		Warnings:         []string{"something was skipped"},
+		LogWarnings: []LogWarning{{
+			Level:   "warn",
+			Message: "Reached max pages",
+			Fields:  map[string]string{"region": "us-east-1"},
+		}},
+		LogWarningsDropped: 3,
+		SkippedRegions:     []SkippedRegion{{Region: "me-south-1", Reason: "access denied"}},
+		EmptyRegions:       []string{"sa-east-1"},
+		APICalls:           []APICallCount{{Service: "Resource Groups Tagging API", Operation: "GetResources", Count: 12}},
+	}
+}
+
+func TestResultMatchesSchema(t *testing.T) {
+	for name, result := range map[string]*SizingResult{
+		"full":    fullResult(),
+		"minimal": {SchemaVersion: SchemaVersion, Provider: "AWS"},
+	} {
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		violations, err := ValidateResult(data)
+		if err != nil {
+			t.Fatalf("%s: ValidateResult() error = %v", name, err)
+		}
+		if len(violations) > 0 {
+			t.Errorf("%s: result does not match its own schema: %v", name, violations)
+		}
+	}
+}
+
+func TestMarshalResult(t *testing.T) {
+	result := &SizingResult{
+		SchemaVersion:  SchemaVersion,
+		Provider:       "AWS",
+		Timestamp:      time.Date(2024, 3, 1, 11, 30, 0, 123456789, time.FixedZone("CET", 3600)),
+		ResourceCounts: []*ResourceCount{{Provider: "aws", Type: "s3:bucket", ByLocation: map[string]int{}}},
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"schema_version":"2","provider":"AWS","timestamp":"2024-03-01T10:30:00Z",` +
+		`"resource_counts":[{"provider":"aws","type":"s3:bucket","display_name":"","total_resources":0}],` +
+		`"account_counts":[],"total_resources":0,"total_accounts":0,"billable_workloads":0}`
+	if string(data) != want {
+		t.Errorf("json.Marshal() =\n  %s\nwant\n  %s", data, want)
+	}
+}
+
+func TestSchemaVersionMatchesSchema(t *testing.T) {
+	var schema struct {
+		Properties struct {
+			SchemaVersion struct {
+				Const string `json:"const"`
+			} `json:"schema_version"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(ResultSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Properties.SchemaVersion.Const != SchemaVersion {
+		t.Errorf("schema_version in result.schema.json = %q, SchemaVersion = %q",
+			schema.Properties.SchemaVersion.Const, SchemaVersion)
+	}
+}
+
+func TestValidateResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "version 1 field names",
+			document: `{"Provider":"AWS","TotalResources":3,"schema_version":"2","provider":"AWS","timestamp":"2024-03-01T10:30:00Z",
+				"resource_counts":[],"account_counts":[],"total_resources":3,"total_accounts":1}`,
+			want: []string{"Provider: unexpected field", "TotalResources: unexpected field"},
+		},
+		{
+			name:     "missing fields",
+			document: `{"schema_version":"2","provider":"AWS","timestamp":"2024-03-01T10:30:00Z","resource_counts":[]}`,
+			want: []string{
+				"account_counts: missing required field",
+				"total_accounts: missing required field",
+				"total_resources: missing required field",
+			},
+		},
+		{
+			name: "nested problems",
+			document: `{"schema_version":"1","provider":"AWS","timestamp":"2024-03-01T10:30:00Z","account_counts":[],
+				"total_resources":1.5,"total_accounts":0,
+				"resource_counts":[{"provider":"aws","type":"ec2:instance","total_resources":1,"by_location":{"us-east-1":"1"},"region":"x"}]}`,
+			want: []string{
+				`resource_counts[0].by_location.us-east-1: expected integer, got string`,
+				"resource_counts[0].display_name: missing required field",
+				"resource_counts[0].region: unexpected field",
+				`schema_version: must be "2", got "1"`,
+				"total_resources: expected integer, got number",
+			},
+		},
+		{name: "not an object", document: `[]`, want: []string{"expected object, got array"}},
+		{name: "not JSON", document: `{`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := ValidateResult([]byte(tt.document))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, violation := range violations {
+				got = append(got, violation.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateResult() =\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(tt.want, "\n  "))
+			}
+		})
+	}
+}
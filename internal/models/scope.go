@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions bounds the closest matches Unmatched offers per value
+const maxSuggestions = 3
+
+// ScanScope is what a scan covered once its filters were applied, recorded
+// when they left out part of the defaults
+type ScanScope struct {
+	// ResourceTypes is the number of types counted, out of the
+	// DefaultResourceTypes built in for the provider
+	ResourceTypes        int `json:"resource_types"`
+	DefaultResourceTypes int `json:"default_resource_types"`
+
+	// Regions are the regions counted with --regions, out of the
+	// AvailableRegions enabled for the account or tenancy
+	Regions          []string `json:"regions,omitempty"`
+	AvailableRegions int      `json:"available_regions,omitempty"`
+}
+
+// SmallScanScope describes a scope covering less than a third of the
+// defaults, or returns "" for a broader one
+func SmallScanScope(scope *ScanScope) string {
+	if scope == nil {
+		return ""
+	}
+	var parts []string
+	if scope.ResourceTypes*3 < scope.DefaultResourceTypes {
+		parts = append(parts, fmt.Sprintf("%d of %d resource types", scope.ResourceTypes, scope.DefaultResourceTypes))
+	}
+	if len(scope.Regions)*3 < scope.AvailableRegions {
+		parts = append(parts, fmt.Sprintf("%d of %d regions", len(scope.Regions), scope.AvailableRegions))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "the filters limit the scan to " + strings.Join(parts, " and ")
+}
+
+// Unmatched lists values, such as regions given on the command line that
+// match none of candidates, each followed by the closest candidates, e.g.
+// "eu-wst-1 (did you mean eu-west-1?)"
+func Unmatched(values, candidates []string) string {
+	described := make([]string, len(values))
+	for i, value := range values {
+		described[i] = value
+		if suggestions := Suggest(value, candidates); len(suggestions) > 0 {
+			described[i] += " (did you mean " + strings.Join(suggestions, " or ") + "?)"
+		}
+	}
+	return strings.Join(described, ", ")
+}
+
+// Suggest returns up to maxSuggestions candidates close to value: those it
+// is a prefix of or that are a prefix of it, then those within a small edit
+// distance, nearest first. Case is ignored.
+func Suggest(value string, candidates []string) []string {
+	value = strings.ToLower(value)
+	if value == "" {
+		return nil
+	}
+	limit := max(2, len(value)/4)
+
+	type match struct {
+		candidate string
+		distance  int
+	}
+	var matches []match
+	for _, candidate := range candidates {
+		lower := strings.ToLower(candidate)
+		if lower == "" {
+			continue
+		}
+		distance := levenshtein(value, lower)
+		if strings.HasPrefix(lower, value) || strings.HasPrefix(value, lower) {
+			distance = 0
+		}
+		if distance <= limit {
+			matches = append(matches, match{candidate, distance})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+
+	suggestions := make([]string, 0, min(len(matches), maxSuggestions))
+	for _, m := range matches[:min(len(matches), maxSuggestions)] {
+		suggestions = append(suggestions, m.candidate)
+	}
+	return suggestions
+}
+
+// levenshtein returns the number of single-character edits turning a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	regions := []string{"us-east-1", "us-east-2", "eu-west-1", "eu-west-2", "eu-central-1", "ap-south-1"}
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"eu-wset-1", []string{"eu-west-1"}},
+		{"EU-CENTRAL-1", []string{"eu-central-1"}},
+		{"us-east", []string{"us-east-1", "us-east-2"}},
+		{"sa-east-1", []string{"us-east-1"}},
+		{"mars-north-9", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := Suggest(tt.value, regions); len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("Suggest(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestUnmatched(t *testing.T) {
+	got := Unmatched([]string{"eu-wst-1", "xx-none-1"}, []string{"eu-west-1", "us-east-1"})
+	if want := "eu-wst-1 (did you mean eu-west-1?), xx-none-1"; got != want {
+		t.Errorf("Unmatched() = %q, want %q", got, want)
+	}
+}
+
+func TestSmallScanScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		scope *ScanScope
+		want  string
+	}{
+		{"none", nil, ""},
+		{"most types", &ScanScope{ResourceTypes: 30, DefaultResourceTypes: 40}, ""},
+		{"few types", &ScanScope{ResourceTypes: 5, DefaultResourceTypes: 40}, "the filters limit the scan to 5 of 40 resource types"},
+		{
+			"few regions",
+			&ScanScope{ResourceTypes: 40, DefaultResourceTypes: 40, Regions: []string{"eu-west-1"}, AvailableRegions: 17},
+			"the filters limit the scan to 1 of 17 regions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SmallScanScope(tt.scope); got != tt.want {
+				t.Errorf("SmallScanScope() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
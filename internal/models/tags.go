@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TagFilter limits a scan to resources carrying a tag with this exact value.
+// Several filters must all match.
+type TagFilter struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (f TagFilter) String() string {
+	return f.Key + "=" + f.Value
+}
+
+// ParseTagFilter parses a --tag value of the form key=value. The value may
+// itself contain '='.
+func ParseTagFilter(s string) (TagFilter, error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" || value == "" {
+		return TagFilter{}, fmt.Errorf("invalid tag filter %q, expected key=value", s)
+	}
+	return TagFilter{Key: key, Value: value}, nil
+}
+
+// TagFilterWarning describes the counts a tag filter could not be applied
+// to, or returns "" when there are none
+func TagFilterWarning(counts []*ResourceCount) string {
+	var types []string
+	for _, count := range counts {
+		if count.TagFilterNotApplied {
+			types = append(types, string(count.Type))
+		}
+	}
+	if len(types) == 0 {
+		return ""
+	}
+	sort.Strings(types)
+	return fmt.Sprintf("tag filter not applied to %d types that cannot be filtered by tag: %s",
+		len(types), strings.Join(types, ", "))
+}
+
+// UntaggedGroup is the account group of accounts without the grouping tag
+const UntaggedGroup = "untagged"
+
+// AccountGroup is the subtotal of the accounts sharing one value of a tag
+type AccountGroup struct {
+	Value          string `json:"value"`
+	Accounts       int    `json:"accounts"`
+	TotalResources int    `json:"total_resources"`
+}
+
+// GroupAccountsByTag sums the resources of each account under the value of
+// its tag key, matched case-insensitively as Azure does. Groups are sorted by
+// value with UntaggedGroup last.
+func GroupAccountsByTag(result *SizingResult, key string) []AccountGroup {
+	perAccount := resourcesPerAccount(result)
+
+	groups := make(map[string]*AccountGroup)
+	for _, account := range result.AccountCounts {
+		value := UntaggedGroup
+		for k, v := range account.Tags {
+			if strings.EqualFold(k, key) {
+				value = v
+				break
+			}
+		}
+		group, ok := groups[value]
+		if !ok {
+			group = &AccountGroup{Value: value}
+			groups[value] = group
+		}
+		group.Accounts++
+		group.TotalResources += perAccount[strings.ToLower(account.ID)]
+	}
+
+	sorted := make([]AccountGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if (sorted[i].Value == UntaggedGroup) != (sorted[j].Value == UntaggedGroup) {
+			return sorted[j].Value == UntaggedGroup
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+	return sorted
+}
+
+// resourcesPerAccount sums the per-type account breakdowns of result by
+// lower-cased account ID
+func resourcesPerAccount(result *SizingResult) map[string]int {
+	perAccount := make(map[string]int)
+	for _, rc := range result.ResourceCounts {
+		for id, count := range rc.ByAccount {
+			perAccount[strings.ToLower(id)] += count
+		}
+	}
+	return perAccount
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    TagFilter
+		wantErr bool
+	}{
+		{input: "env=prod", want: TagFilter{Key: "env", Value: "prod"}},
+		{input: "owner=team=platform", want: TagFilter{Key: "owner", Value: "team=platform"}},
+		{input: " env =prod", want: TagFilter{Key: "env", Value: "prod"}},
+		{input: "env", wantErr: true},
+		{input: "env=", wantErr: true},
+		{input: "=prod", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTagFilter(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTagFilter(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTagFilter(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagFilterWarning(t *testing.T) {
+	if got := TagFilterWarning([]*ResourceCount{{Type: "a"}}); got != "" {
+		t.Errorf("TagFilterWarning() = %q, want empty", got)
+	}
+
+	got := TagFilterWarning([]*ResourceCount{
+		{Type: "z:type", TagFilterNotApplied: true},
+		{Type: "b:type"},
+		{Type: "a:type", TagFilterNotApplied: true},
+	})
+	if !strings.Contains(got, "2 types") || !strings.HasSuffix(got, "a:type, z:type") {
+		t.Errorf("TagFilterWarning() = %q", got)
+	}
+}
+
+func TestGroupAccountsByTag(t *testing.T) {
+	result := &SizingResult{
+		ResourceCounts: []*ResourceCount{
+			{Type: "microsoft.compute/virtualmachines", ByAccount: map[string]int{"sub-1": 10, "sub-2": 4, "sub-3": 1}},
+			{Type: "microsoft.storage/storageaccounts", ByAccount: map[string]int{"SUB-1": 2, "sub-4": 7}},
+		},
+		AccountCounts: []AccountCount{
+			{ID: "sub-1", Tags: map[string]string{"Environment": "prod"}},
+			{ID: "sub-2", Tags: map[string]string{"environment": "prod"}},
+			{ID: "sub-3", Tags: map[string]string{"environment": "dev"}},
+			{ID: "sub-4", Tags: map[string]string{"owner": "data"}},
+			{ID: "sub-5"},
+		},
+	}
+
+	got := GroupAccountsByTag(result, "environment")
+	want := []AccountGroup{
+		{Value: "dev", Accounts: 1, TotalResources: 1},
+		{Value: "prod", Accounts: 2, TotalResources: 16},
+		{Value: UntaggedGroup, Accounts: 2, TotalResources: 7},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupAccountsByTag() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+// Total sums the resources of counts, leaving out the counts that are a
+// subset of another type, whose resources are already in that type's count
+func Total(counts []*ResourceCount) int {
+	total := 0
+	for _, rc := range counts {
+		if rc.SubsetOf == "" {
+			total += rc.TotalResources
+		}
+	}
+	return total
+}
@@ -0,0 +1,67 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// readAccountTags sets the Organizations tags of the discovered accounts
+// when the account filter matches tags. Only the accounts listed from the
+// organization carry tags.
+func (p *AWSProvider) readAccountTags(ctx context.Context, client organizations.ListTagsForResourceAPIClient) error {
+	if len(p.config.AccountFilter.Tags) == 0 || p.accountSource != models.AccountSourceOrganizations {
+		return nil
+	}
+	for i, account := range p.accounts {
+		if account.Tags != nil {
+			continue
+		}
+		tags, err := accountTags(ctx, client, account.ID)
+		if err != nil {
+			return fmt.Errorf("failed to read the tags of account %s for --account-tag: %w", account.ID, err)
+		}
+		p.accounts[i].Tags = tags
+	}
+	return nil
+}
+
+// accountTags returns the Organizations tags of an account, empty but not
+// nil when it has none
+func accountTags(ctx context.Context, client organizations.ListTagsForResourceAPIClient, accountID string) (map[string]string, error) {
+	tags := map[string]string{}
+	paginator := organizations.NewListTagsForResourcePaginator(client,
+		&organizations.ListTagsForResourceInput{ResourceId: aws.String(accountID)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// filterAccounts keeps the discovered accounts the account filter matches.
+// Resources are counted in the credentials' account alone, so it must be
+// kept.
+func (p *AWSProvider) filterAccounts() error {
+	filter := p.config.AccountFilter
+	if filter.Empty() {
+		return nil
+	}
+	p.accounts, p.excludedAccounts = filter.Apply(p.accounts)
+	for _, account := range p.accounts {
+		if account.ID == p.currentAccount.AccountID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the resources of account %s, whose credentials are used, would be counted but it does not match %s",
+		models.ErrAccountsExcluded, p.currentAccount.AccountID, filter)
+}
@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgTypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// fakeOrgTags returns the tags of accounts by ID, one tag per page
+type fakeOrgTags struct {
+	tags  map[string]map[string]string
+	calls int
+}
+
+func (f *fakeOrgTags) ListTagsForResource(_ context.Context, params *organizations.ListTagsForResourceInput,
+	_ ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	f.calls++
+	var tags []orgTypes.Tag
+	for key, value := range f.tags[awsSdk.ToString(params.ResourceId)] {
+		tags = append(tags, orgTypes.Tag{Key: awsSdk.String(key), Value: awsSdk.String(value)})
+	}
+	return &organizations.ListTagsForResourceOutput{Tags: tags}, nil
+}
+
+func TestFilterAccounts(t *testing.T) {
+	p := newTestProvider(t)
+	p.accountSource = models.AccountSourceOrganizations
+	p.accounts = []models.AccountCount{
+		{ID: "123456789012", Name: "Prod-Management"},
+		{ID: "210987654321", Name: "prod-payments"},
+		{ID: "111111111111", Name: "prod-sandbox"},
+		{ID: "222222222222", Name: "dev-payments"},
+	}
+	p.config.AccountFilter = models.AccountFilter{
+		Names: []string{"prod-*"},
+		Tags:  []models.TagFilter{{Key: "env", Value: "prod"}},
+	}
+	org := &fakeOrgTags{tags: map[string]map[string]string{
+		"123456789012": {"Env": "prod"},
+		"210987654321": {"env": "prod", "team": "payments"},
+		"111111111111": {"env": "sandbox"},
+		"222222222222": {"env": "prod"},
+	}}
+
+	if err := p.readAccountTags(context.Background(), org); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.filterAccounts(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := accountIDs(p.accounts), []string{"123456789012", "210987654321"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept accounts = %v, want %v", got, want)
+	}
+	if got, want := accountIDs(p.excludedAccounts), []string{"111111111111", "222222222222"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("excluded accounts = %v, want %v", got, want)
+	}
+
+	result, err := p.CountResources(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalAccounts != 2 || len(result.ExcludedAccounts) != 2 {
+		t.Errorf("TotalAccounts = %d with %d excluded, want 2 with 2 excluded", result.TotalAccounts, len(result.ExcludedAccounts))
+	}
+}
+
+func TestFilterAccountsExcludesCaller(t *testing.T) {
+	p := newTestProvider(t)
+	p.config.AccountFilter = models.AccountFilter{Names: []string{"prod-*"}}
+
+	err := p.filterAccounts()
+	if !errors.Is(err, models.ErrAccountsExcluded) {
+		t.Fatalf("filterAccounts() = %v, want ErrAccountsExcluded", err)
+	}
+	if len(p.accounts) != 0 || len(p.excludedAccounts) != 1 {
+		t.Errorf("kept %d and excluded %d accounts, want 0 and 1", len(p.accounts), len(p.excludedAccounts))
+	}
+}
+
+func TestReadAccountTagsSkipsCurrentAccount(t *testing.T) {
+	p := newTestProvider(t)
+	p.accountSource = models.AccountSourceCurrentAccount
+	p.config.AccountFilter = models.AccountFilter{Tags: []models.TagFilter{{Key: "env", Value: "prod"}}}
+	org := &fakeOrgTags{}
+
+	if err := p.readAccountTags(context.Background(), org); err != nil {
+		t.Fatal(err)
+	}
+	if org.calls != 0 {
+		t.Errorf("ListTagsForResource called %d times outside an organization, want 0", org.calls)
+	}
+}
+
+func accountIDs(accounts []models.AccountCount) []string {
+	ids := make([]string, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.ID
+	}
+	return ids
+}
@@ -0,0 +1,175 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/backup"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+// Page sizes of the snapshot APIs: the largest each accepts, as snapshots
+// can run into the tens of thousands per region. Only the number of items
+// of each page is kept.
+const (
+	amiPageSize         = 1000
+	ebsSnapshotPageSize = 1000
+	rdsSnapshotPageSize = 100
+	backupPageSize      = 1000
+)
+
+// selfOwner limits the EC2 image and snapshot listings to the caller's
+// account, leaving out public and shared ones
+const selfOwner = "self"
+
+// countAMIs counts the AMIs the account owns in the client's region, by
+// state
+func countAMIs(ctx context.Context, client ec2.DescribeImagesAPIClient) (regionCount, error) {
+	result := regionCount{byState: make(map[string]int)}
+	paginator := ec2.NewDescribeImagesPaginator(client, &ec2.DescribeImagesInput{
+		Owners:     []string{selfOwner},
+		MaxResults: awsSdk.Int32(amiPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe images: %w", err)
+		}
+		for _, image := range page.Images {
+			result.total++
+			result.byState[string(image.State)]++
+		}
+	}
+	return result, nil
+}
+
+// countEBSSnapshots counts the EBS snapshots the account owns in the
+// client's region, by state and by storage tier (standard or archive)
+func countEBSSnapshots(ctx context.Context, client ec2.DescribeSnapshotsAPIClient) (regionCount, error) {
+	result := regionCount{byState: make(map[string]int), byKind: make(map[string]int)}
+	paginator := ec2.NewDescribeSnapshotsPaginator(client, &ec2.DescribeSnapshotsInput{
+		OwnerIds:   []string{selfOwner},
+		MaxResults: awsSdk.Int32(ebsSnapshotPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe snapshots: %w", err)
+		}
+		for _, snapshot := range page.Snapshots {
+			result.total++
+			result.byState[string(snapshot.State)]++
+			if snapshot.StorageTier != "" {
+				result.byKind[string(snapshot.StorageTier)]++
+			}
+		}
+	}
+	return result, nil
+}
+
+// countDBSnapshots counts the RDS DB instance snapshots in the client's
+// region, by snapshot type (manual, automated or awsbackup) and engine.
+// Snapshots shared by other accounts are not listed.
+func countDBSnapshots(ctx context.Context, client rds.DescribeDBSnapshotsAPIClient) (regionCount, error) {
+	result := regionCount{byKind: make(map[string]int), byEngine: make(map[string]int)}
+	paginator := rds.NewDescribeDBSnapshotsPaginator(client, &rds.DescribeDBSnapshotsInput{
+		MaxRecords: awsSdk.Int32(rdsSnapshotPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe DB snapshots: %w", err)
+		}
+		for _, snapshot := range page.DBSnapshots {
+			result.total++
+			result.byKind[awsSdk.ToString(snapshot.SnapshotType)]++
+			result.byEngine[awsSdk.ToString(snapshot.Engine)]++
+		}
+	}
+	return result, nil
+}
+
+// countDBClusterSnapshots counts the Aurora and other RDS cluster snapshots
+// in the client's region, by snapshot type and engine
+func countDBClusterSnapshots(ctx context.Context, client rds.DescribeDBClusterSnapshotsAPIClient) (regionCount, error) {
+	result := regionCount{byKind: make(map[string]int), byEngine: make(map[string]int)}
+	paginator := rds.NewDescribeDBClusterSnapshotsPaginator(client, &rds.DescribeDBClusterSnapshotsInput{
+		MaxRecords: awsSdk.Int32(rdsSnapshotPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe DB cluster snapshots: %w", err)
+		}
+		for _, snapshot := range page.DBClusterSnapshots {
+			result.total++
+			result.byKind[awsSdk.ToString(snapshot.SnapshotType)]++
+			result.byEngine[awsSdk.ToString(snapshot.Engine)]++
+		}
+	}
+	return result, nil
+}
+
+// backupAPI is the subset of the AWS Backup client used to count recovery
+// points, which are listed per vault
+type backupAPI interface {
+	backup.ListBackupVaultsAPIClient
+	backup.ListRecoveryPointsByBackupVaultAPIClient
+}
+
+// countBackupPlans counts the AWS Backup plans in the client's region
+func countBackupPlans(ctx context.Context, client backup.ListBackupPlansAPIClient) (int, error) {
+	count := 0
+	paginator := backup.NewListBackupPlansPaginator(client, &backup.ListBackupPlansInput{
+		MaxResults: awsSdk.Int32(backupPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list backup plans: %w", err)
+		}
+		count += len(page.BackupPlansList)
+	}
+	return count, nil
+}
+
+// countRecoveryPoints counts the recovery points of every backup vault in
+// the client's region, by status and by the type of the resource backed up
+// (e.g. EBS, RDS)
+func countRecoveryPoints(ctx context.Context, client backupAPI) (regionCount, error) {
+	var vaults []string
+	paginator := backup.NewListBackupVaultsPaginator(client, &backup.ListBackupVaultsInput{
+		MaxResults: awsSdk.Int32(backupPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to list backup vaults: %w", err)
+		}
+		for _, vault := range page.BackupVaultList {
+			vaults = append(vaults, awsSdk.ToString(vault.BackupVaultName))
+		}
+	}
+
+	result := regionCount{byState: make(map[string]int), byKind: make(map[string]int)}
+	for _, vault := range vaults {
+		paginator := backup.NewListRecoveryPointsByBackupVaultPaginator(client, &backup.ListRecoveryPointsByBackupVaultInput{
+			BackupVaultName: awsSdk.String(vault),
+			MaxResults:      awsSdk.Int32(backupPageSize),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return regionCount{}, fmt.Errorf("failed to list the recovery points of vault %s: %w", vault, err)
+			}
+			for _, point := range page.RecoveryPoints {
+				result.total++
+				result.byState[string(point.Status)]++
+				result.byKind[awsSdk.ToString(point.ResourceType)]++
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,235 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/backup"
+	backupTypes "github.com/aws/aws-sdk-go-v2/service/backup/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// fakeSnapshots serves snapshots in pages of pageSize, following tokens
+type fakeSnapshots struct {
+	images    []ec2Types.Image
+	snapshots []ec2Types.Snapshot
+	pageSize  int
+
+	owners []string
+	calls  int
+}
+
+// snapshotPage returns the bounds of the page starting at token and the
+// token of the next page
+func snapshotPage(token *string, pageSize, total int) (start, end int, next *string) {
+	start, _ = strconv.Atoi(awsSdk.ToString(token))
+	end = min(start+pageSize, total)
+	if end < total {
+		next = awsSdk.String(strconv.Itoa(end))
+	}
+	return start, end, next
+}
+
+func (f *fakeSnapshots) DescribeImages(
+	_ context.Context,
+	params *ec2.DescribeImagesInput,
+	_ ...func(*ec2.Options),
+) (*ec2.DescribeImagesOutput, error) {
+	f.calls++
+	f.owners = params.Owners
+	start, end, next := snapshotPage(params.NextToken, f.pageSize, len(f.images))
+	return &ec2.DescribeImagesOutput{Images: f.images[start:end], NextToken: next}, nil
+}
+
+func (f *fakeSnapshots) DescribeSnapshots(
+	_ context.Context,
+	params *ec2.DescribeSnapshotsInput,
+	_ ...func(*ec2.Options),
+) (*ec2.DescribeSnapshotsOutput, error) {
+	f.calls++
+	f.owners = params.OwnerIds
+	start, end, next := snapshotPage(params.NextToken, f.pageSize, len(f.snapshots))
+	return &ec2.DescribeSnapshotsOutput{Snapshots: f.snapshots[start:end], NextToken: next}, nil
+}
+
+func TestCountAMIs(t *testing.T) {
+	client := &fakeSnapshots{pageSize: 2, images: []ec2Types.Image{
+		{State: ec2Types.ImageStateAvailable},
+		{State: ec2Types.ImageStateAvailable},
+		{State: ec2Types.ImageStatePending},
+	}}
+
+	got, err := countAMIs(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.total != 3 || got.byState["available"] != 2 || got.byState["pending"] != 1 {
+		t.Errorf("countAMIs() = %+v, want 3 with 2 available and 1 pending", got)
+	}
+	if !reflect.DeepEqual(client.owners, []string{"self"}) {
+		t.Errorf("owners = %v, want self only", client.owners)
+	}
+}
+
+func TestCountEBSSnapshots(t *testing.T) {
+	client := &fakeSnapshots{pageSize: 1000}
+	for i := range 2500 {
+		snapshot := ec2Types.Snapshot{State: ec2Types.SnapshotStateCompleted, StorageTier: ec2Types.StorageTierStandard}
+		if i%10 == 0 {
+			snapshot.StorageTier = ec2Types.StorageTierArchive
+		}
+		client.snapshots = append(client.snapshots, snapshot)
+	}
+
+	got, err := countEBSSnapshots(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.total != 2500 || client.calls != 3 {
+		t.Errorf("counted %d snapshots in %d calls, want 2500 in 3 pages", got.total, client.calls)
+	}
+	if want := map[string]int{"standard": 2250, "archive": 250}; !reflect.DeepEqual(got.byKind, want) {
+		t.Errorf("byKind = %v, want %v", got.byKind, want)
+	}
+	if !reflect.DeepEqual(client.owners, []string{"self"}) {
+		t.Errorf("owners = %v, want self only", client.owners)
+	}
+}
+
+type fakeRDSSnapshots struct {
+	snapshots        []rdsTypes.DBSnapshot
+	clusterSnapshots []rdsTypes.DBClusterSnapshot
+}
+
+func (f *fakeRDSSnapshots) DescribeDBSnapshots(
+	_ context.Context,
+	_ *rds.DescribeDBSnapshotsInput,
+	_ ...func(*rds.Options),
+) (*rds.DescribeDBSnapshotsOutput, error) {
+	return &rds.DescribeDBSnapshotsOutput{DBSnapshots: f.snapshots}, nil
+}
+
+func (f *fakeRDSSnapshots) DescribeDBClusterSnapshots(
+	_ context.Context,
+	_ *rds.DescribeDBClusterSnapshotsInput,
+	_ ...func(*rds.Options),
+) (*rds.DescribeDBClusterSnapshotsOutput, error) {
+	return &rds.DescribeDBClusterSnapshotsOutput{DBClusterSnapshots: f.clusterSnapshots}, nil
+}
+
+func TestCountDBSnapshots(t *testing.T) {
+	client := &fakeRDSSnapshots{
+		snapshots: []rdsTypes.DBSnapshot{
+			{SnapshotType: awsSdk.String("automated"), Engine: awsSdk.String("postgres")},
+			{SnapshotType: awsSdk.String("manual"), Engine: awsSdk.String("postgres")},
+			{SnapshotType: awsSdk.String("awsbackup"), Engine: awsSdk.String("mysql")},
+		},
+		clusterSnapshots: []rdsTypes.DBClusterSnapshot{
+			{SnapshotType: awsSdk.String("automated"), Engine: awsSdk.String("aurora-postgresql")},
+		},
+	}
+
+	got, err := countDBSnapshots(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"automated": 1, "manual": 1, "awsbackup": 1}; got.total != 3 || !reflect.DeepEqual(got.byKind, want) {
+		t.Errorf("countDBSnapshots() = %d by kind %v, want 3 by kind %v", got.total, got.byKind, want)
+	}
+
+	got, err = countDBClusterSnapshots(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.total != 1 || got.byEngine["aurora-postgresql"] != 1 {
+		t.Errorf("countDBClusterSnapshots() = %+v, want 1 aurora-postgresql snapshot", got)
+	}
+}
+
+// fakeBackup holds the recovery points of each vault
+type fakeBackup struct {
+	plans  int
+	points map[string][]backupTypes.RecoveryPointByBackupVault
+	err    error
+}
+
+func (f *fakeBackup) ListBackupPlans(
+	_ context.Context,
+	_ *backup.ListBackupPlansInput,
+	_ ...func(*backup.Options),
+) (*backup.ListBackupPlansOutput, error) {
+	return &backup.ListBackupPlansOutput{BackupPlansList: make([]backupTypes.BackupPlansListMember, f.plans)}, nil
+}
+
+func (f *fakeBackup) ListBackupVaults(
+	_ context.Context,
+	_ *backup.ListBackupVaultsInput,
+	_ ...func(*backup.Options),
+) (*backup.ListBackupVaultsOutput, error) {
+	var vaults []backupTypes.BackupVaultListMember
+	for _, name := range []string{"Default", "prod-vault"} {
+		vaults = append(vaults, backupTypes.BackupVaultListMember{BackupVaultName: awsSdk.String(name)})
+	}
+	return &backup.ListBackupVaultsOutput{BackupVaultList: vaults}, nil
+}
+
+func (f *fakeBackup) ListRecoveryPointsByBackupVault(
+	_ context.Context,
+	params *backup.ListRecoveryPointsByBackupVaultInput,
+	_ ...func(*backup.Options),
+) (*backup.ListRecoveryPointsByBackupVaultOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &backup.ListRecoveryPointsByBackupVaultOutput{RecoveryPoints: f.points[awsSdk.ToString(params.BackupVaultName)]}, nil
+}
+
+func recoveryPoint(resourceType string, status backupTypes.RecoveryPointStatus) backupTypes.RecoveryPointByBackupVault {
+	return backupTypes.RecoveryPointByBackupVault{ResourceType: awsSdk.String(resourceType), Status: status}
+}
+
+func TestCountBackupPlans(t *testing.T) {
+	got, err := countBackupPlans(context.Background(), &fakeBackup{plans: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 4 {
+		t.Errorf("countBackupPlans() = %d, want 4", got)
+	}
+}
+
+func TestCountRecoveryPoints(t *testing.T) {
+	client := &fakeBackup{points: map[string][]backupTypes.RecoveryPointByBackupVault{
+		"Default": {
+			recoveryPoint("EBS", backupTypes.RecoveryPointStatusCompleted),
+			recoveryPoint("RDS", backupTypes.RecoveryPointStatusCompleted),
+		},
+		"prod-vault": {
+			recoveryPoint("EBS", backupTypes.RecoveryPointStatusCompleted),
+			recoveryPoint("EC2", backupTypes.RecoveryPointStatusPartial),
+		},
+	}}
+
+	got, err := countRecoveryPoints(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"EBS": 2, "RDS": 1, "EC2": 1}; got.total != 4 || !reflect.DeepEqual(got.byKind, want) {
+		t.Errorf("countRecoveryPoints() = %d by kind %v, want 4 by kind %v", got.total, got.byKind, want)
+	}
+	if got.byState["COMPLETED"] != 3 || got.byState["PARTIAL"] != 1 {
+		t.Errorf("byState = %v, want 3 completed and 1 partial", got.byState)
+	}
+
+	client.err = errors.New("AccessDeniedException")
+	if _, err := countRecoveryPoints(context.Background(), client); err == nil {
+		t.Error("countRecoveryPoints() error = nil, want the vault's error")
+	}
+}
@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// DefaultRegionFailureThreshold is the number of consecutive failed counts
+// in a region after which the region is skipped for the remaining types
+const DefaultRegionFailureThreshold = 5
+
+// regionBreaker skips the regions whose counts keep failing, such as a
+// region still being opted in, so that they stop using up the API budget
+// and retries of every type. A region's circuit opens after threshold
+// consecutive failures across types and stays open for the rest of the scan;
+// a success in between closes the streak. A nil breaker never opens.
+type regionBreaker struct {
+	threshold int
+
+	mu       sync.Mutex
+	failures map[string]int
+	open     map[string]error
+}
+
+// newRegionBreaker returns a breaker opening after threshold consecutive
+// failures, or nil when threshold is 0, disabling it
+func newRegionBreaker(threshold int) *regionBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &regionBreaker{
+		threshold: threshold,
+		failures:  make(map[string]int),
+		open:      make(map[string]error),
+	}
+}
+
+// allow reports whether region may still be counted
+func (b *regionBreaker) allow(region string) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open[region] == nil
+}
+
+// record notes the outcome of a count in region made with ctx. Failures
+// because ctx is done say nothing about the region and are ignored.
+func (b *regionBreaker) record(ctx context.Context, region string, err error) {
+	if b == nil || (err != nil && ctx.Err() != nil) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures[region] = 0
+		return
+	}
+	b.failures[region]++
+	if b.failures[region] == b.threshold {
+		b.open[region] = err
+		logging.FromContext(ctx).Warn("Region keeps failing; skipping it for the remaining resource types",
+			zap.String("region", region),
+			zap.Int("consecutive_failures", b.threshold),
+			zap.Error(err))
+	}
+}
+
+// skipped returns the regions whose circuit opened, with the last error
+func (b *regionBreaker) skipped() []models.SkippedRegion {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	skipped := make([]models.SkippedRegion, 0, len(b.open))
+	for region, err := range b.open {
+		skipped = append(skipped, models.SkippedRegion{
+			Region: region,
+			Reason: fmt.Sprintf("%d consecutive failures, the last: %v", b.threshold, err),
+		})
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i].Region < skipped[j].Region })
+	return skipped
+}
+
+// failingRegionsWarning summarizes the regions the breaker skipped in one
+// line
+func failingRegionsWarning(skipped []models.SkippedRegion) string {
+	parts := make([]string, len(skipped))
+	for i, s := range skipped {
+		parts[i] = fmt.Sprintf("%s: %s", s.Region, s.Reason)
+	}
+	return fmt.Sprintf("Skipped %d AWS region(s) for the remaining resource types after repeated failures, "+
+		"their counts are incomplete: %s", len(skipped), strings.Join(parts, "; "))
+}
@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestRegionBreaker(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("UnrecognizedClientException")
+	breaker := newRegionBreaker(3)
+
+	breaker.record(ctx, "ap-east-1", failure)
+	breaker.record(ctx, "ap-east-1", failure)
+	breaker.record(ctx, "ap-east-1", nil)
+	breaker.record(ctx, "ap-east-1", failure)
+	breaker.record(ctx, "ap-east-1", failure)
+	if !breaker.allow("ap-east-1") {
+		t.Fatal("breaker opened although a success broke the streak")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	breaker.record(cancelled, "ap-east-1", context.Canceled)
+	if !breaker.allow("ap-east-1") {
+		t.Fatal("breaker opened on a failure of a cancelled count")
+	}
+
+	breaker.record(ctx, "ap-east-1", failure)
+	if breaker.allow("ap-east-1") || !breaker.allow("us-east-1") {
+		t.Error("breaker did not open for ap-east-1 only")
+	}
+	skipped := breaker.skipped()
+	if len(skipped) != 1 || skipped[0].Region != "ap-east-1" || !strings.Contains(skipped[0].Reason, "UnrecognizedClientException") {
+		t.Errorf("skipped() = %+v, want ap-east-1 with the last error", skipped)
+	}
+
+	disabled := newRegionBreaker(0)
+	disabled.record(ctx, "ap-east-1", failure)
+	if !disabled.allow("ap-east-1") || disabled.skipped() != nil {
+		t.Error("a disabled breaker skipped a region")
+	}
+}
+
+func TestCountResourceTypeSkipsFailingRegion(t *testing.T) {
+	failing := &fakeTaggingAPI{err: errors.New("UnrecognizedClientException: region not enabled")}
+	healthy := &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(2, "")}}
+	clients := map[string]taggingAPI{"us-east-1": healthy, "me-central-1": failing}
+
+	collector := NewResourceCollector(1, nil, nil, nil)
+	collector.breaker = newRegionBreaker(2)
+
+	var last *models.ResourceCount
+	for _, resourceType := range []string{"ec2:instance", "s3:bucket", "sqs:queue", "sns:topic"} {
+		count, err := collector.CountResourceType(context.Background(),
+			models.ResourceDefinition{Type: resourceType}, []string{"us-east-1", "me-central-1"}, clients)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = count
+	}
+
+	if failing.calls != 2 {
+		t.Errorf("failing region called %d times, want 2 before the circuit opened", failing.calls)
+	}
+	if last.TotalResources != 2 || last.RegionsQueried != 1 || len(last.FailedRegions) != 0 {
+		t.Errorf("last count = %+v, want us-east-1 only, without failed regions", last)
+	}
+	if skipped := collector.breaker.skipped(); len(skipped) != 1 || skipped[0].Region != "me-central-1" {
+		t.Errorf("skipped() = %+v, want me-central-1", skipped)
+	}
+}
@@ -3,50 +3,140 @@ package aws
 import (
 	"context"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsConf "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgTypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
 	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
 
+	"github.com/secrails/secrails-sizing-agent/internal/cache"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
 	"github.com/secrails/secrails-sizing-agent/internal/models"
 	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/counting"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
 	"github.com/secrails/secrails-sizing-agent/pkg/logging"
 
 	"go.uber.org/zap"
 )
 
+// maxConcurrency is the number of API calls each collector allows in flight at
+// once across all resource types and regions
+const maxConcurrency = 10
+
+// Sessions of an assumed role last an hour, the longest every role allows,
+// and are renewed five minutes before they expire
+const (
+	assumeRoleSessionName  = "secrails-sizing-agent"
+	assumeRoleDuration     = time.Hour
+	assumeRoleExpiryWindow = 5 * time.Minute
+)
+
+// resourceCounter counts resources of a given type across regions
+type resourceCounter interface {
+	GetResourceTypesToCount() []models.ResourceDefinition
+	CountResourceType(
+		ctx context.Context,
+		resourceDef models.ResourceDefinition,
+		regions []string,
+		taggingClients map[string]taggingAPI,
+	) (*models.ResourceCount, error)
+	PlanResourceType(resourceDef models.ResourceDefinition, regions []string) models.PlannedType
+}
+
+// serviceAPICounter counts resources of a given type through the type's own
+// service API
+type serviceAPICounter interface {
+	CountResourceType(
+		ctx context.Context,
+		resourceDef models.ResourceDefinition,
+		regions []string,
+		cfg aws.Config,
+	) (*models.ResourceCount, error)
+	PlanResourceType(resourceDef models.ResourceDefinition, regions []string) (models.PlannedType, error)
+	CountImages(ctx context.Context, count *models.ResourceCount, regions []string, cfg aws.Config)
+}
+
 // AWSProvider implements the Provider interface for AWS
 type AWSProvider struct {
+	// mu guards the SDK config, clients, accounts and regions that Connect
+	// discovers; counting takes copies through snapshot, and the noise
+	// filter reads awsConfig under the read lock
+	mu sync.RWMutex
+
 	config    config.ProviderConfig
 	awsConfig aws.Config
 
 	// AWS SDK clients
 	stsClient      *sts.Client
 	orgClient      *organizations.Client
-	taggingClients map[string]*resourcegroupstaggingapi.Client
+	taggingClients map[string]taggingAPI
 
 	// Account information
 	currentAccount *CallerIdentity
 	accounts       []models.AccountCount
-	regions        []string
+	accountSource  models.AccountSource
+	accountsCached bool
+
+	// excludedAccounts were discovered but do not match the account filter
+	excludedAccounts []models.AccountCount
 
-	// Resource collector
-	collector *ResourceCollector
+	// organizationID is the caller's organization, if any, and
+	// managementAccount is set when the caller is its management account
+	organizationID    string
+	managementAccount bool
+	regions           []string
+
+	// availableRegions is the number of regions enabled for the account
+	availableRegions int
+
+	// Resource collectors
+	collector resourceCounter
+	services  serviceAPICounter
+
+	// breaker skips the regions whose counts keep failing, for both
+	// collectors
+	breaker *regionBreaker
 }
 
 // NewAWSProvider creates a new AWS provider
 func NewAWSProvider(cfg config.ProviderConfig) (*AWSProvider, error) {
+	if err := checkTaggingTypes(cfg.Log(), cfg.Definitions, cfg.StrictDefinitions); err != nil {
+		return nil, err
+	}
+
+	breaker := newRegionBreaker(cfg.RegionFailureThreshold)
+	collector := NewResourceCollector(maxConcurrency, cfg.Definitions, cfg.Inventory, cfg.TagFilters)
+	collector.pageSize, collector.maxPages = int32(cfg.PageSize), cfg.MaxPages
+	collector.breaker = breaker
+	services := NewServiceCollector(collector.sem)
+	services.breaker = breaker
+	services.excludeNoise = cfg.ExcludeNoise
 	provider := &AWSProvider{
 		config:         cfg,
-		taggingClients: make(map[string]*resourcegroupstaggingapi.Client),
+		taggingClients: make(map[string]taggingAPI),
 		accounts:       []models.AccountCount{},
-		collector:      &ResourceCollector{},
+		collector:      collector,
+		services:       services,
+		breaker:        breaker,
+	}
+	if cfg.ExcludeNoise {
+		collector.noise = newNoiseFilter(func() aws.Config {
+			provider.mu.RLock()
+			defer provider.mu.RUnlock()
+			return provider.awsConfig.Copy()
+		})
 	}
 
 	return provider, nil
@@ -59,6 +149,11 @@ func (p *AWSProvider) Name() string {
 
 // Connect establishes connection to AWS
 func (p *AWSProvider) Connect(ctx context.Context) error {
+	ctx = logging.NewContext(ctx, p.config.Log())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// Step 1: Load AWS configuration
 	if err := p.loadAWSConfig(ctx); err != nil {
 		return fmt.Errorf("failed to load AWS config: %w", err)
@@ -75,15 +170,21 @@ func (p *AWSProvider) Connect(ctx context.Context) error {
 	// Step 4: Initialize Organizations client (for multi-account)
 	p.orgClient = organizations.NewFromConfig(p.awsConfig)
 
-	// Step 5: Discover accounts (if using Organizations)
-	if err := p.discoverAccounts(ctx); err != nil {
-		// Not fatal - might be a single account setup
-		logging.Debug("Could not discover organization accounts (might be single account)", zap.Error(err))
+	// Step 5: Discover accounts (if using Organizations) and enabled regions
+	availableRegions, err := p.discover(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to setup regions: %w", err)
+	}
+	if err := p.readAccountTags(ctx, p.orgClient); err != nil {
+		return err
+	}
+	if err := p.filterAccounts(); err != nil {
+		return err
 	}
 
 	// Step 6: Get regions to scan
-	if err := p.setupRegions(ctx); err != nil {
-		return fmt.Errorf("failed to setup regions: %w", err)
+	if err := p.selectRegions(availableRegions); err != nil {
+		return err
 	}
 
 	// Step 7: Initialize tagging clients for each region
@@ -91,27 +192,51 @@ func (p *AWSProvider) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to initialize tagging clients: %w", err)
 	}
 
-	logging.Info("✓ Connected to AWS successfully")
-	logging.Info("  Account ID", zap.String("account_id", p.currentAccount.AccountID))
-	logging.Info("  Regions to scan", zap.Strings("regions", p.regions))
+	p.config.Log().Info("✓ Connected to AWS successfully")
+	p.config.Log().Info("  Account ID", zap.String("account_id", p.currentAccount.AccountID))
+	p.config.Log().Info("  Regions to scan", zap.Strings("regions", p.regions))
 	if len(p.accounts) > 1 {
-		logging.Info("  Organization accounts found", zap.Int("count", len(p.accounts)))
+		p.config.Log().Info("  Organization accounts found", zap.Int("count", len(p.accounts)))
 	}
 
 	return nil
 }
 
 func (p *AWSProvider) loadAWSConfig(ctx context.Context) error {
-	logging.Debug("Loading AWS configuration...")
+	p.config.Log().Debug("Loading AWS configuration...")
+
+	region, source, err := resolveRegion(p.config.Region, sharedConfigRegion(ctx, p.config.Profile), os.Getenv)
+	if err != nil {
+		return fmt.Errorf("unable to determine AWS region: %w", err)
+	}
+	p.config.Log().Info("Using AWS region", zap.String("region", region), zap.String("source", source))
 
 	var opts []func(*awsConf.LoadOptions) error
 
 	// Set region
-	opts = append(opts, awsConf.WithRegion(p.config.Region))
+	opts = append(opts, awsConf.WithRegion(region))
+
+	// Share one retry and backoff policy across all clients
+	opts = append(opts, awsConf.WithRetryer(newRetryer))
+
+	// Identify the agent and record API calls, retries and throttling
+	apiOptions := []func(*middleware.Stack) error{
+		withUserAgent,
+		withAPIMetrics(p.config.APICalls),
+	}
+	if p.config.DebugDump != nil {
+		apiOptions = append(apiOptions, withDebugDump(p.config.DebugDump))
+	}
+	opts = append(opts, awsConf.WithAPIOptions(apiOptions))
+
+	// Send requests through the configured CA bundle and proxy
+	if p.config.HTTPClient != nil {
+		opts = append(opts, awsConf.WithHTTPClient(p.config.HTTPClient))
+	}
 
 	// Use specific profile if provided
 	if p.config.Profile != "" {
-		logging.Debug("Using AWS profile", zap.String("profile", p.config.Profile))
+		p.config.Log().Debug("Using AWS profile", zap.String("profile", p.config.Profile))
 		opts = append(opts, awsConf.WithSharedConfigProfile(p.config.Profile))
 	}
 
@@ -121,17 +246,61 @@ func (p *AWSProvider) loadAWSConfig(ctx context.Context) error {
 		return fmt.Errorf("unable to load AWS SDK config: %w", err)
 	}
 
+	// Send every service, the role assumption included, to one endpoint,
+	// and individual services to their own
+	if p.config.AWSEndpointURL != "" {
+		p.config.Log().Debug("Using AWS endpoint", zap.String("endpoint", p.config.AWSEndpointURL))
+		cfg.BaseEndpoint = aws.String(p.config.AWSEndpointURL)
+	}
+	if len(p.config.AWSEndpoints) > 0 {
+		p.config.Log().Debug("Using AWS service endpoints", zap.Any("endpoints", p.config.AWSEndpoints))
+		cfg.ConfigSources = append([]any{newServiceEndpoints(p.config.AWSEndpoints)}, cfg.ConfigSources...)
+	}
+
+	if p.config.AssumeRoleARN != "" {
+		if cfg.Credentials, err = p.assumeRoleCredentials(sts.NewFromConfig(cfg)); err != nil {
+			return err
+		}
+	}
+
 	p.awsConfig = cfg
 	return nil
 }
 
+// assumeRoleCredentials returns credentials of the configured role, assumed
+// through client with the base credentials. The cache refreshes them shortly
+// before they expire, asking for a new MFA code if the role needs one.
+func (p *AWSProvider) assumeRoleCredentials(client stscreds.AssumeRoleAPIClient) (aws.CredentialsProvider, error) {
+	if p.config.MFASerial != "" && p.config.MFATokenProvider == nil {
+		return nil, fmt.Errorf("assuming %s needs an MFA code, which can only be entered in an interactive terminal",
+			p.config.AssumeRoleARN)
+	}
+	p.config.Log().Debug("Assuming role", zap.String("role_arn", p.config.AssumeRoleARN))
+
+	provider := stscreds.NewAssumeRoleProvider(client, p.config.AssumeRoleARN,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = assumeRoleSessionName
+			o.Duration = assumeRoleDuration
+			if p.config.ExternalID != "" {
+				o.ExternalID = aws.String(p.config.ExternalID)
+			}
+			if p.config.MFASerial != "" {
+				o.SerialNumber = aws.String(p.config.MFASerial)
+				o.TokenProvider = p.config.MFATokenProvider
+			}
+		})
+	return aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = assumeRoleExpiryWindow
+	}), nil
+}
+
 // verifyCredentials verifies AWS credentials are valid
 func (p *AWSProvider) verifyCredentials(ctx context.Context) error {
-	logging.Debug("Verifying AWS credentials...")
+	p.config.Log().Debug("Verifying AWS credentials...")
 
 	result, err := p.stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 	if err != nil {
-		return fmt.Errorf("failed to get caller identity: %w", err)
+		return fmt.Errorf("failed to get caller identity: %w", classifyError(err, "sts:GetCallerIdentity"))
 	}
 
 	p.currentAccount = &CallerIdentity{
@@ -140,12 +309,12 @@ func (p *AWSProvider) verifyCredentials(ctx context.Context) error {
 		Arn:       *result.Arn,
 	}
 
-	logging.Debug("Authenticated as", zap.String("arn", p.currentAccount.Arn))
+	p.config.Log().Debug("Authenticated as", zap.String("arn", p.currentAccount.Arn))
 	return nil
 }
 
 func (p *AWSProvider) initializeClients() error {
-	logging.Debug("Initializing tagging clients for each region...")
+	p.config.Log().Debug("Initializing tagging clients for each region...")
 
 	for _, region := range p.regions {
 		// Create a new config for this region
@@ -155,14 +324,14 @@ func (p *AWSProvider) initializeClients() error {
 		// Create tagging client for this region
 		p.taggingClients[region] = resourcegroupstaggingapi.NewFromConfig(regionalConfig)
 
-		logging.Debug("Initialized tagging client", zap.String("region", region))
+		p.config.Log().Debug("Initialized tagging client", zap.String("region", region))
 	}
 
 	return nil
 }
 
 func (p *AWSProvider) discoverAccounts(ctx context.Context) error {
-	logging.Info("Discovering AWS accounts in the organization...")
+	p.config.Log().Info("Discovering AWS accounts in the organization...")
 
 	// Check if we're in an organization
 	orgInfo, err := p.orgClient.DescribeOrganization(ctx, &organizations.DescribeOrganizationInput{})
@@ -170,13 +339,17 @@ func (p *AWSProvider) discoverAccounts(ctx context.Context) error {
 		// Not in an organization, just use current account
 		p.accounts = append(p.accounts, models.AccountCount{
 			ID:   p.currentAccount.AccountID,
-			Name: "Current Account",
+			Name: p.accountAlias(ctx),
 		})
-		logging.Debug("Not in an organization, using single account")
+		p.accountSource = models.AccountSourceCurrentAccount
+		p.config.Log().Debug("Not in an organization, using single account")
 		return nil
 	}
 
-	logging.Info("Organization ID", zap.String("organization_id", *orgInfo.Organization.Id))
+	p.organizationID = aws.ToString(orgInfo.Organization.Id)
+	p.managementAccount = aws.ToString(orgInfo.Organization.MasterAccountId) == p.currentAccount.AccountID
+	p.config.Log().Info("Organization ID", zap.String("organization_id", p.organizationID),
+		zap.Bool("management_account", p.managementAccount))
 
 	// Try to list all accounts in the organization (only works for management account)
 	paginator := organizations.NewListAccountsPaginator(p.orgClient, &organizations.ListAccountsInput{})
@@ -186,35 +359,107 @@ func (p *AWSProvider) discoverAccounts(ctx context.Context) error {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
 			// If we can't list accounts (likely a member account, not management account)
-			logging.Warn("Cannot list organization accounts (requires management account permissions)",
+			p.config.Log().Warn("Cannot list organization accounts (requires management account permissions)",
 				zap.Error(err))
 			break // Don't return error, just break the loop
 		}
 
 		for _, account := range page.Accounts {
 			p.accounts = append(p.accounts, models.AccountCount{
-				ID:   *account.Id,
-				Name: *account.Name,
+				ID:     aws.ToString(account.Id),
+				Name:   aws.ToString(account.Name),
+				Email:  aws.ToString(account.Email),
+				Status: accountStatus(account),
 			})
-			logging.Debug("Added account", zap.String("id", *account.Id), zap.String("name", *account.Name))
+			p.config.Log().Debug("Added account",
+				zap.String("id", aws.ToString(account.Id)), zap.String("name", aws.ToString(account.Name)))
 			accountsFound = true
 		}
 	}
 
 	// If no accounts were found (member account scenario), just use current account
+	p.accountSource = models.AccountSourceOrganizations
 	if !accountsFound {
 		p.accounts = append(p.accounts, models.AccountCount{
 			ID:   p.currentAccount.AccountID,
-			Name: "Current Account (Organization Member)",
+			Name: p.accountAlias(ctx),
 		})
-		logging.Info("Using current account only (member account in organization)")
+		p.accountSource = models.AccountSourceCurrentAccount
+		p.config.Log().Info("Using current account only (member account in organization)")
 	}
 
-	logging.Info("Found accounts", zap.Int("count", len(p.accounts)))
+	p.config.Log().Info("Found accounts", zap.Int("count", len(p.accounts)))
 	return nil
 }
 
-func (p *AWSProvider) setupRegions(ctx context.Context) error {
+// accountAlias returns the IAM alias of the current account, or "" when it
+// has none or it cannot be read
+func (p *AWSProvider) accountAlias(ctx context.Context) string {
+	output, err := iam.NewFromConfig(p.awsConfig).ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
+	if err != nil {
+		p.config.Log().Debug("Could not read account alias", zap.Error(err))
+		return ""
+	}
+	if len(output.AccountAliases) == 0 {
+		return ""
+	}
+	return output.AccountAliases[0]
+}
+
+// accountStatus returns an Organizations account's state, falling back to
+// the deprecated status field
+func accountStatus(account orgTypes.Account) string {
+	if account.State != "" {
+		return string(account.State)
+	}
+	return string(account.Status)
+}
+
+// isSuspended reports whether an account is suspended in its organization
+func isSuspended(account models.AccountCount) bool {
+	return account.Status == string(orgTypes.AccountStateSuspended)
+}
+
+// discover finds the organization accounts and the enabled regions. A fresh
+// cached result for the caller's account is reused instead of calling
+// Organizations and EC2 again.
+func (p *AWSProvider) discover(ctx context.Context) ([]string, error) {
+	cacheKey := "aws-" + p.currentAccount.AccountID
+	if cached, ok := p.config.Cache.Load(cacheKey); ok && len(cached.Accounts) > 0 && len(cached.Regions) > 0 {
+		p.config.Log().Info("Using cached account and region discovery (use --no-cache to refresh)")
+		p.accounts = cached.AccountCounts()
+		p.accountSource, p.accountsCached = models.AccountSource(cached.Source), true
+		p.organizationID, p.managementAccount = cached.OrganizationID, cached.ManagementAccount
+		return cached.Regions, nil
+	}
+
+	if err := p.discoverAccounts(ctx); err != nil {
+		// Not fatal - might be a single account setup
+		p.config.Log().Debug("Could not discover organization accounts (might be single account)", zap.Error(err))
+	}
+
+	availableRegions, err := p.describeRegions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery := &cache.Discovery{
+		Accounts: cache.FromAccounts(p.accounts),
+		Regions:  availableRegions,
+		Source:   string(p.accountSource),
+
+		OrganizationID:    p.organizationID,
+		ManagementAccount: p.managementAccount,
+	}
+	if err := p.config.Cache.Store(cacheKey, discovery); err != nil {
+		p.config.Log().Debug("Could not write discovery cache", zap.Error(err))
+	}
+
+	return availableRegions, nil
+}
+
+// describeRegions returns the regions enabled for the account
+func (p *AWSProvider) describeRegions(ctx context.Context) ([]string, error) {
 	ec2Client := ec2.NewFromConfig(p.awsConfig)
 	output, err := ec2Client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{
 		AllRegions: aws.Bool(false), // Changed to false - only opted-in regions
@@ -226,7 +471,7 @@ func (p *AWSProvider) setupRegions(ctx context.Context) error {
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to describe regions: %w", err)
+		return nil, fmt.Errorf("failed to describe regions: %w", classifyError(err, "ec2:DescribeRegions"))
 	}
 
 	var availableRegions []string
@@ -236,89 +481,290 @@ func (p *AWSProvider) setupRegions(ctx context.Context) error {
 		}
 	}
 
-	logging.Debug("Available AWS regions", zap.Strings("regions", availableRegions))
-	if len(p.regions) == 0 {
+	p.config.Log().Debug("Available AWS regions", zap.Strings("regions", availableRegions))
+	return availableRegions, nil
+}
+
+// selectRegions sets the regions to scan: every available region, or the
+// configured allow-list as given. An allow-list without any enabled region
+// fails, naming the closest enabled regions.
+func (p *AWSProvider) selectRegions(availableRegions []string) error {
+	p.availableRegions = len(availableRegions)
+	if len(p.config.Regions) == 0 {
 		p.regions = availableRegions
+		return nil
 	}
 
+	enabled := make(map[string]bool, len(availableRegions))
+	for _, region := range availableRegions {
+		enabled[region] = true
+	}
+	var disabled []string
+	for _, region := range p.config.Regions {
+		if !enabled[region] {
+			p.config.Log().Warn("Requested region is not enabled for this account",
+				zap.String("region", region),
+				zap.Strings("closest_enabled", models.Suggest(region, availableRegions)))
+			disabled = append(disabled, region)
+		}
+	}
+	if len(disabled) == len(p.config.Regions) && len(availableRegions) > 0 {
+		return fmt.Errorf("none of the regions of --regions is enabled for this account, nothing to scan: %s",
+			models.Unmatched(disabled, availableRegions))
+	}
+	p.regions = p.config.Regions
 	return nil
 }
 
 func (p *AWSProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
-	logging.Info("Counting AWS resources...")
+	ctx = logging.NewContext(ctx, p.config.Log())
 
-	if len(p.accounts) == 0 {
+	p.config.Log().Info("Counting AWS resources...")
+
+	accounts, regions, taggingClients, awsConfig := p.snapshot()
+	if len(accounts) == 0 {
 		return nil, fmt.Errorf("no accounts available to scan")
 	}
 
 	// Initialize result
+	p.mu.RLock()
 	result := &models.SizingResult{
-		Provider:  "AWS",
-		Timestamp: time.Now(),
+		SchemaVersion:       models.SchemaVersion,
+		Provider:            "AWS",
+		Timestamp:           time.Now(),
+		AssumedRoleARN:      p.config.AssumeRoleARN,
+		OrganizationID:      p.organizationID,
+		IsManagementAccount: p.managementAccount,
+		ExcludedAccounts:    p.excludedAccounts,
+	}
+	memberOnly := p.organizationID != "" && p.accountSource == models.AccountSourceCurrentAccount
+	if len(p.config.Regions) > 0 {
+		result.Scope = &models.ScanScope{Regions: regions, AvailableRegions: p.availableRegions}
+	}
+	p.mu.RUnlock()
+
+	// Drop regions that deny access or are not enabled, unless the user chose
+	// the regions explicitly. Regions the probe found empty are left out of
+	// the tagging API scans, which could not find anything there either.
+	taggingRegions := regions
+	if len(p.config.Regions) == 0 {
+		var empty []string
+		regions, result.SkippedRegions, empty = probeRegions(ctx, regions, taggingClients)
+		taggingRegions = regions
+		if len(empty) > 0 && !p.config.NoRegionPrecheck {
+			taggingRegions = withoutRegions(regions, empty)
+			result.EmptyRegions = empty
+			p.config.Log().Info("Skipping tagging API scans in regions without tagged resources (use --no-region-precheck to scan them)",
+				zap.Strings("regions", empty))
+		}
+		if len(result.SkippedRegions) > 0 {
+			warning := skippedRegionsWarning(result.SkippedRegions)
+			p.config.Log().Warn(warning)
+			result.Warnings = append(result.Warnings, warning)
+		}
+		if len(regions) == 0 {
+			err := fmt.Errorf("no accessible regions to scan")
+			if allAccessDenied(result.SkippedRegions) {
+				return nil, &sizingerrors.PermissionError{Provider: metricsProvider, Action: "tag:GetResources", Err: err}
+			}
+			return nil, err
+		}
 	}
-
-	// Create semaphore for concurrent operations
-	maxConcurrency := 5
-	semaphore := make(chan struct{}, maxConcurrency)
 
 	// Get resource types to count
 	resourceTypes := p.collector.GetResourceTypesToCount()
-	logging.Debug("Resource types to count", zap.Int("count", len(resourceTypes)))
-
-	var wg sync.WaitGroup
-	resourceCounts := make([]*models.ResourceCount, 0)
-	resultsMu := sync.Mutex{}
-
-	// Count each resource type
-	for _, rt := range resourceTypes {
-		wg.Add(1)
-		go func(resourceDef models.ResourceDefinition) {
-			defer wg.Done()
-
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// Count this resource type
-			count, err := p.collector.CountResourceType(ctx, resourceDef, p.regions, p.taggingClients)
-			if err != nil {
-				logging.Error("Failed to count resource type",
-					zap.String("type", resourceDef.Type),
-					zap.Error(err))
-				return
+	p.config.Log().Debug("Resource types to count", zap.Int("count", len(resourceTypes)))
+
+	// Count each resource type, retrying those that fail; API concurrency
+	// is bounded by the collector
+	countType := func(ctx context.Context, resourceDef models.ResourceDefinition) (*models.ResourceCount, error) {
+		start := time.Now()
+		typeCtx, stats := metrics.StartTypeStats(ctx)
+		typeCtx, span := tracing.Start(typeCtx, "CountResourceType")
+		span.SetString("provider", metricsProvider)
+		span.SetString("resource_type", string(resourceDef.ResourceType()))
+		span.SetString("count_method", string(resourceDef.CountMethod))
+
+		var count *models.ResourceCount
+		var err error
+		switch resourceDef.CountMethod {
+		case models.CountMethodServiceAPI:
+			count, err = p.services.CountResourceType(typeCtx, resourceDef, regions, awsConfig)
+			if count != nil {
+				count.TagFilterNotApplied = len(p.config.TagFilters) > 0
 			}
+		default:
+			count, err = p.collector.CountResourceType(typeCtx, resourceDef, taggingRegions, taggingClients)
+		}
+		span.End(err)
+		if err != nil {
+			return nil, classifyError(err, typeAction(resourceDef))
+		}
+		if p.config.DeepRegistries && count.Type == repositoryType {
+			p.services.CountImages(typeCtx, count, taggingRegions, awsConfig)
+		}
+		metrics.ResourceTypeScanned(metricsProvider, string(count.Type), time.Since(start))
+		count.Stats = stats.Stats()
+		count.Category = resourceDef.Category
+		count.CanonicalType = resourceDef.CanonicalType()
+		count.SubsetOf = models.ResourceType(resourceDef.SubsetOf)
+		count.Global = resourceDef.Global
+		if resourceDef.Noise && p.config.ExcludeNoise {
+			count.ExcludeAsNoise()
+		}
 
-			// Store result
-			resultsMu.Lock()
-			resourceCounts = append(resourceCounts, count)
-			resultsMu.Unlock()
-		}(rt)
+		// The tagging and service APIs only see the credentials' own
+		// account, so every count belongs to it
+		if count.TotalResources > 0 {
+			count.ByAccount = map[string]int{p.currentAccount.AccountID: count.TotalResources}
+		}
+		p.config.StreamCount(count)
+		return count, nil
+	}
+	resourceCounts, failures := counting.Run(ctx, resourceTypes, countType,
+		counting.Options{Retries: p.config.RetryFailed, TypeTimeout: p.config.TypeTimeout})
+	partial := failures.Partial()
+	if partial != nil && len(resourceCounts) == 0 {
+		return nil, fmt.Errorf("no resource types could be counted: %w", partial.Errs[0])
 	}
-
-	// Wait for all goroutines to complete
-	wg.Wait()
 
 	// Populate SizingResult
 	result.ResourceCounts = resourceCounts
-	result.AccountCounts = p.accounts
+	result.AccountCounts = accounts
+	if failing := p.breaker.skipped(); len(failing) > 0 {
+		warning := failingRegionsWarning(failing)
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+		result.SkippedRegions = append(result.SkippedRegions, failing...)
+	}
 
 	// Calculate totals
-	for _, rc := range resourceCounts {
-		result.TotalResources += rc.TotalResources
+	result.TotalResources = models.Total(resourceCounts)
+	if p.config.ExcludeNoise {
+		result.RawTotalResources = models.RawTotal(resourceCounts)
+	}
+	for _, account := range accounts {
+		if isSuspended(account) && !p.config.IncludeSuspended {
+			continue
+		}
+		result.TotalAccounts++
+	}
+	if suspended := len(accounts) - result.TotalAccounts; suspended > 0 {
+		p.config.Log().Info("Suspended accounts excluded from the account total (use --include-suspended to count them)",
+			zap.Int("suspended", suspended))
 	}
-	result.TotalAccounts = len(p.accounts)
 
-	logging.Info("Resource counting completed",
+	// Whatever the organization listing found, one account was counted
+	result.AccountsDiscovered = result.TotalAccounts
+	result.AccountsScanned = 1
+	if result.Incomplete() {
+		p.config.Log().Warn("Resources were counted in one of the organization's accounts only",
+			zap.Int("accounts_discovered", result.AccountsDiscovered))
+	}
+	if memberOnly {
+		// The warning names no account or organization, which --anonymize
+		// could not replace in its text
+		warning := "the scanned account is a member of an organization whose accounts could not be listed; " +
+			"the counts cover this account only, run from the management account to discover the others"
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+
+	p.config.Log().Info("Resource counting completed",
 		zap.Int("total_resources", result.TotalResources),
 		zap.Int("resource_types_counted", len(resourceCounts)),
 		zap.Int("accounts", result.TotalAccounts))
 
+	if warning := models.TagFilterWarning(resourceCounts); warning != "" {
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	if warning := models.TruncatedWarning(resourceCounts); warning != "" {
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+
+	if partial != nil {
+		result.Warnings = append(result.Warnings, partial.Error())
+		return result, partial
+	}
 	return result, nil
 }
 
+// Plan describes what CountResources would do with the discovered accounts
+// and regions
+func (p *AWSProvider) Plan() (*models.ScanPlan, error) {
+	accounts, regions, _, _ := p.snapshot()
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("no accounts available to scan")
+	}
+
+	plan := &models.ScanPlan{Provider: "AWS", Accounts: accounts, Regions: regions, Concurrency: maxConcurrency}
+
+	// Regions are probed once each before counting, unless chosen explicitly
+	if len(p.config.Regions) == 0 {
+		plan.EstimatedAPICalls += len(regions)
+		warning := "regions that deny access or are not enabled are found by the probe and skipped when counting"
+		if !p.config.NoRegionPrecheck {
+			warning += "; tagging API types also skip regions the probe finds without tagged resources"
+		}
+		plan.Warnings = append(plan.Warnings, warning)
+	}
+
+	if p.config.DeepRegistries {
+		plan.Warnings = append(plan.Warnings,
+			"--deep-registries adds ecr:DescribeRepositories calls per region and ecr:DescribeImages calls per repository, not included in the estimate")
+	}
+
+	for _, def := range p.collector.GetResourceTypesToCount() {
+		if def.CountMethod != models.CountMethodServiceAPI {
+			plan.AddType(p.collector.PlanResourceType(def, regions))
+			continue
+		}
+		planned, err := p.services.PlanResourceType(def, regions)
+		if err != nil {
+			plan.Warnings = append(plan.Warnings, err.Error())
+			continue
+		}
+		plan.AddType(planned)
+	}
+	return plan, nil
+}
+
+// Accounts lists the discovered accounts and whether they came from the
+// organization or only the caller's account
+func (p *AWSProvider) Accounts() *models.AccountList {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	list := models.NewAccountList("AWS", p.accountSource, p.accounts)
+	list.Cached = p.accountsCached
+	return list
+}
+
+// snapshot returns copies of the discovery state so that counting never
+// shares mutable slices or maps with Connect
+func (p *AWSProvider) snapshot() ([]models.AccountCount, []string, map[string]taggingAPI, aws.Config) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	accounts := make([]models.AccountCount, len(p.accounts))
+	copy(accounts, p.accounts)
+
+	regions := make([]string, len(p.regions))
+	copy(regions, p.regions)
+
+	taggingClients := make(map[string]taggingAPI, len(p.taggingClients))
+	for region, client := range p.taggingClients {
+		taggingClients[region] = client
+	}
+
+	return accounts, regions, taggingClients, p.awsConfig.Copy()
+}
+
 // Close closes any open connections
 func (p *AWSProvider) Close() error {
-	logging.Info("Closing AWS provider connections")
+	p.config.Log().Info("Closing AWS provider connections")
 	// AWS SDK clients don't require explicit closing
 	return nil
 }
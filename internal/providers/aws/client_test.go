@@ -0,0 +1,627 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	stsTypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/secrails/secrails-sizing-agent/internal/anonymize"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"golang.org/x/sync/semaphore"
+)
+
+// fakeCollector returns one resource per region for every resource type
+type fakeCollector struct {
+	types []models.ResourceDefinition
+}
+
+func (f *fakeCollector) GetResourceTypesToCount() []models.ResourceDefinition {
+	return f.types
+}
+
+func (f *fakeCollector) CountResourceType(
+	_ context.Context,
+	resourceDef models.ResourceDefinition,
+	regions []string,
+	taggingClients map[string]taggingAPI,
+) (*models.ResourceCount, error) {
+	result := &models.ResourceCount{
+		Provider:    "AWS",
+		Type:        resourceDef.ResourceType(),
+		DisplayName: resourceDef.DisplayName,
+		ByLocation:  make(map[string]int),
+		ByAccount:   make(map[string]int),
+	}
+	for _, region := range regions {
+		if _, ok := taggingClients[region]; !ok {
+			continue
+		}
+		result.ByLocation[region]++
+		result.TotalResources++
+	}
+	return result, nil
+}
+
+func (f *fakeCollector) PlanResourceType(resourceDef models.ResourceDefinition, regions []string) models.PlannedType {
+	return (&ResourceCollector{}).PlanResourceType(resourceDef, regions)
+}
+
+// fakeServices counts one resource for every service_api type
+type fakeServices struct{}
+
+func (fakeServices) PlanResourceType(resourceDef models.ResourceDefinition, regions []string) (models.PlannedType, error) {
+	return NewServiceCollector(semaphore.NewWeighted(1)).PlanResourceType(resourceDef, regions)
+}
+
+func (fakeServices) CountResourceType(
+	_ context.Context,
+	resourceDef models.ResourceDefinition,
+	_ []string,
+	_ awsSdk.Config,
+) (*models.ResourceCount, error) {
+	return &models.ResourceCount{
+		Provider:       "AWS",
+		Type:           resourceDef.ResourceType(),
+		DisplayName:    resourceDef.DisplayName,
+		TotalResources: 1,
+	}, nil
+}
+
+// CountImages finds 10 images in every region
+func (fakeServices) CountImages(_ context.Context, count *models.ResourceCount, regions []string, _ awsSdk.Config) {
+	count.Images += 10 * len(regions)
+}
+
+func newTestProvider(t *testing.T) *AWSProvider {
+	t.Helper()
+
+	p, err := NewAWSProvider(config.ProviderConfig{Provider: "aws"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.collector = &fakeCollector{types: []models.ResourceDefinition{
+		{Type: "ec2:instance", DisplayName: "EC2 Instances"},
+		{Type: "s3:bucket", DisplayName: "S3 Buckets"},
+		{Type: "lambda:function", DisplayName: "Lambda Functions"},
+		{Type: "guardduty:detector", DisplayName: "GuardDuty Detectors", CountMethod: models.CountMethodServiceAPI},
+	}}
+	p.services = fakeServices{}
+	p.currentAccount = &CallerIdentity{AccountID: "123456789012"}
+	p.accounts = []models.AccountCount{{ID: "123456789012", Name: "Test Account"}}
+	p.regions = []string{"us-east-1", "eu-west-1"}
+	for _, region := range p.regions {
+		p.taggingClients[region] = &fakeTaggingAPI{}
+	}
+	return p
+}
+
+func TestCountResources(t *testing.T) {
+	p := newTestProvider(t)
+
+	result, err := p.CountResources(context.Background())
+	if err != nil {
+		t.Fatalf("CountResources() error = %v", err)
+	}
+	if result.TotalResources != 7 {
+		t.Errorf("TotalResources = %d, want 7", result.TotalResources)
+	}
+	if result.TotalAccounts != 1 {
+		t.Errorf("TotalAccounts = %d, want 1", result.TotalAccounts)
+	}
+	if len(result.ResourceCounts) != 4 {
+		t.Errorf("ResourceCounts = %d, want 4", len(result.ResourceCounts))
+	}
+	for _, rc := range result.ResourceCounts {
+		if rc.Stats == nil || rc.Stats.Start.IsZero() {
+			t.Errorf("%s has no scan stats", rc.Type)
+		}
+		if rc.TotalResources > 0 && rc.ByAccount["123456789012"] != rc.TotalResources {
+			t.Errorf("%s ByAccount = %v, want all %d resources in the caller's account", rc.Type, rc.ByAccount, rc.TotalResources)
+		}
+	}
+}
+
+func TestCountResourcesSubsetNotInTotal(t *testing.T) {
+	p := newTestProvider(t)
+	collector := p.collector.(*fakeCollector)
+	collector.types = append(collector.types, models.ResourceDefinition{
+		Type: "eks:node", DisplayName: "EKS Nodes", CountMethod: models.CountMethodServiceAPI, SubsetOf: "ec2:instance",
+	})
+
+	result, err := p.CountResources(context.Background())
+	if err != nil {
+		t.Fatalf("CountResources() error = %v", err)
+	}
+	// The EKS node is reported on its own line, but is one of the EC2
+	// instances already in the total
+	if result.TotalResources != 7 {
+		t.Errorf("TotalResources = %d, want 7 without the EKS node", result.TotalResources)
+	}
+	i := slices.IndexFunc(result.ResourceCounts, func(rc *models.ResourceCount) bool { return rc.Type == "eks:node" })
+	if i < 0 {
+		t.Fatal("no EKS node count")
+	}
+	if nodes := result.ResourceCounts[i]; nodes.TotalResources != 1 || nodes.SubsetOf != "ec2:instance" {
+		t.Errorf("EKS node count = %+v, want 1 node, a subset of ec2:instance", nodes)
+	}
+}
+
+func TestCountResourcesSuspendedAccounts(t *testing.T) {
+	accounts := []models.AccountCount{
+		{ID: "111111111111", Name: "prod", Status: "ACTIVE"},
+		{ID: "222222222222", Name: "old", Status: "SUSPENDED"},
+		{ID: "333333333333", Name: "dev", Status: "ACTIVE"},
+	}
+
+	for _, includeSuspended := range []bool{false, true} {
+		p := newTestProvider(t)
+		p.config.IncludeSuspended = includeSuspended
+		p.accounts = accounts
+
+		result, err := p.CountResources(context.Background())
+		if err != nil {
+			t.Fatalf("CountResources() error = %v", err)
+		}
+
+		want := 2
+		if includeSuspended {
+			want = 3
+		}
+		if result.TotalAccounts != want {
+			t.Errorf("includeSuspended=%v: TotalAccounts = %d, want %d", includeSuspended, result.TotalAccounts, want)
+		}
+		if len(result.AccountCounts) != 3 {
+			t.Errorf("AccountCounts = %d, want all 3 listed", len(result.AccountCounts))
+		}
+	}
+}
+
+func TestCountResourcesDeepRegistries(t *testing.T) {
+	for _, deep := range []bool{false, true} {
+		p := newTestProvider(t)
+		p.config.DeepRegistries = deep
+		p.collector = &fakeCollector{types: []models.ResourceDefinition{
+			{Type: "ecr:repository", DisplayName: "ECR Repositories"},
+			{Type: "s3:bucket", DisplayName: "S3 Buckets"},
+		}}
+
+		result, err := p.CountResources(context.Background())
+		if err != nil {
+			t.Fatalf("CountResources() error = %v", err)
+		}
+
+		wantImages := 0
+		if deep {
+			wantImages = 20
+		}
+		for _, rc := range result.ResourceCounts {
+			want := 0
+			if rc.Type == repositoryType {
+				want = wantImages
+			}
+			if rc.Images != want {
+				t.Errorf("deep=%v: %s Images = %d, want %d", deep, rc.Type, rc.Images, want)
+			}
+		}
+	}
+}
+
+func TestCountResourcesOrganization(t *testing.T) {
+	tests := []struct {
+		name           string
+		source         models.AccountSource
+		accounts       []models.AccountCount
+		management     bool
+		wantDiscovered int
+		wantWarning    bool
+	}{
+		{
+			name:   "management account",
+			source: models.AccountSourceOrganizations,
+			accounts: []models.AccountCount{
+				{ID: "111111111111", Status: "ACTIVE"}, {ID: "222222222222", Status: "ACTIVE"}, {ID: "333333333333", Status: "ACTIVE"},
+			},
+			management:     true,
+			wantDiscovered: 3,
+		},
+		{
+			name:           "member account that cannot list the organization",
+			source:         models.AccountSourceCurrentAccount,
+			accounts:       []models.AccountCount{{ID: "222222222222"}},
+			wantDiscovered: 1,
+			wantWarning:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t)
+			p.accounts = tt.accounts
+			p.accountSource = tt.source
+			p.organizationID, p.managementAccount = "o-abc123def4", tt.management
+
+			result, err := p.CountResources(context.Background())
+			if err != nil {
+				t.Fatalf("CountResources() error = %v", err)
+			}
+			if result.OrganizationID != "o-abc123def4" || result.IsManagementAccount != tt.management {
+				t.Errorf("organization = %q, management = %v", result.OrganizationID, result.IsManagementAccount)
+			}
+			if result.AccountsDiscovered != tt.wantDiscovered || result.AccountsScanned != 1 {
+				t.Errorf("accounts discovered = %d, scanned = %d, want %d and 1",
+					result.AccountsDiscovered, result.AccountsScanned, tt.wantDiscovered)
+			}
+			warned := len(result.Warnings) == 1 && strings.Contains(result.Warnings[0], "management account")
+			if warned != tt.wantWarning {
+				t.Errorf("Warnings = %q, want member warning: %v", result.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestCountResourcesMemberOnlyAnonymized(t *testing.T) {
+	p := newTestProvider(t)
+	p.accounts = []models.AccountCount{{ID: "222222222222"}}
+	p.accountSource = models.AccountSourceCurrentAccount
+	p.organizationID = "o-abc123def4"
+
+	result, err := p.CountResources(context.Background())
+	if err != nil {
+		t.Fatalf("CountResources() error = %v", err)
+	}
+	anonymize.NewWithSalt([]byte("salt")).Apply(result)
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"222222222222", "o-abc123def4"} {
+		if strings.Contains(string(data), id) {
+			t.Errorf("anonymized result still names %s: %s", id, data)
+		}
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("Warnings = %q, want the member warning", result.Warnings)
+	}
+}
+
+func TestCountResourcesNoAccounts(t *testing.T) {
+	p := newTestProvider(t)
+	p.accounts = nil
+
+	if _, err := p.CountResources(context.Background()); err == nil {
+		t.Fatal("expected error when no accounts are available")
+	}
+}
+
+// TestCountResourcesConcurrent runs several scans while the discovery state is
+// being rewritten. Run with -race to detect unsynchronized access.
+func TestCountResourcesConcurrent(t *testing.T) {
+	p := newTestProvider(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.CountResources(context.Background()); err != nil {
+				t.Errorf("CountResources() error = %v", err)
+			}
+		}()
+	}
+
+	// Simulate Connect-time discovery mutating state mid-scan
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			p.mu.Lock()
+			p.regions = append(p.regions, "ap-south-1")
+			p.taggingClients["ap-south-1"] = &fakeTaggingAPI{}
+			p.accounts = append(p.accounts, models.AccountCount{ID: "210987654321"})
+			p.mu.Unlock()
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestCountResourcesSkipsInaccessibleRegions(t *testing.T) {
+	denied := &fakeTaggingAPI{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}}
+
+	p := newTestProvider(t)
+	p.taggingClients["eu-west-1"] = denied
+
+	result, err := p.CountResources(context.Background())
+	if err != nil {
+		t.Fatalf("CountResources() error = %v", err)
+	}
+	if len(result.SkippedRegions) != 1 || result.SkippedRegions[0].Region != "eu-west-1" {
+		t.Errorf("SkippedRegions = %v, want eu-west-1", result.SkippedRegions)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want a single summary", result.Warnings)
+	}
+	// Three tagging types in us-east-1 only, plus one service_api count
+	if result.TotalResources != 4 {
+		t.Errorf("TotalResources = %d, want 4", result.TotalResources)
+	}
+	if denied.calls != 1 {
+		t.Errorf("denied region called %d times, want only the probe", denied.calls)
+	}
+}
+
+func TestCountResourcesAllowListSkipsProbe(t *testing.T) {
+	denied := &fakeTaggingAPI{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}}
+
+	p := newTestProvider(t)
+	p.config.Regions = []string{"us-east-1", "eu-west-1"}
+	p.taggingClients["eu-west-1"] = denied
+
+	result, err := p.CountResources(context.Background())
+	if err != nil {
+		t.Fatalf("CountResources() error = %v", err)
+	}
+	if len(result.SkippedRegions) != 0 {
+		t.Errorf("SkippedRegions = %v, want none with an allow-list", result.SkippedRegions)
+	}
+	if result.TotalResources != 7 {
+		t.Errorf("TotalResources = %d, want 7", result.TotalResources)
+	}
+}
+
+func TestCountResourcesSkipsEmptyRegions(t *testing.T) {
+	for _, noPrecheck := range []bool{false, true} {
+		p := newTestProvider(t)
+		p.config.NoRegionPrecheck = noPrecheck
+		p.taggingClients["eu-west-1"] = &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(0, "")}}
+
+		result, err := p.CountResources(context.Background())
+		if err != nil {
+			t.Fatalf("CountResources() error = %v", err)
+		}
+
+		// Three tagging types in us-east-1 only, plus one service_api count
+		wantTotal, wantEmpty := 4, []string{"eu-west-1"}
+		if noPrecheck {
+			wantTotal, wantEmpty = 7, nil
+		}
+		if result.TotalResources != wantTotal {
+			t.Errorf("noPrecheck=%v: TotalResources = %d, want %d", noPrecheck, result.TotalResources, wantTotal)
+		}
+		if !reflect.DeepEqual(result.EmptyRegions, wantEmpty) {
+			t.Errorf("noPrecheck=%v: EmptyRegions = %v, want %v", noPrecheck, result.EmptyRegions, wantEmpty)
+		}
+		if len(result.SkippedRegions) != 0 {
+			t.Errorf("noPrecheck=%v: SkippedRegions = %v, want none", noPrecheck, result.SkippedRegions)
+		}
+	}
+}
+
+func TestCountResourcesNoAccessibleRegions(t *testing.T) {
+	p := newTestProvider(t)
+	for region := range p.taggingClients {
+		p.taggingClients[region] = &fakeTaggingAPI{err: &smithy.GenericAPIError{Code: "UnrecognizedClientException"}}
+	}
+
+	if _, err := p.CountResources(context.Background()); err == nil {
+		t.Fatal("expected error when every region is skipped")
+	}
+}
+
+// failingServices fails every service_api count
+type failingServices struct {
+	fakeServices
+	err error
+}
+
+func (f failingServices) CountResourceType(
+	context.Context, models.ResourceDefinition, []string, awsSdk.Config,
+) (*models.ResourceCount, error) {
+	return nil, f.err
+}
+
+func TestPlan(t *testing.T) {
+	p := newTestProvider(t)
+	p.collector.(*fakeCollector).types = append(p.collector.(*fakeCollector).types,
+		models.ResourceDefinition{Type: "unknown:thing", DisplayName: "Unknown", CountMethod: models.CountMethodServiceAPI})
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan.Accounts) != 1 || len(plan.Regions) != 2 {
+		t.Errorf("plan accounts = %d, regions = %d, want 1 and 2", len(plan.Accounts), len(plan.Regions))
+	}
+	if len(plan.ResourceTypes) != 4 {
+		t.Fatalf("plan has %d types, want 4", len(plan.ResourceTypes))
+	}
+	if got := plan.ResourceTypes[3]; got.API != "guardduty:ListDetectors" || got.EstimatedCalls != 2 {
+		t.Errorf("planned guardduty = %+v", got)
+	}
+	// 2 region probes, 3 tagging types in 2 regions and 1 service type in 2
+	if plan.EstimatedAPICalls != 10 {
+		t.Errorf("EstimatedAPICalls = %d, want 10", plan.EstimatedAPICalls)
+	}
+	// The type without a counter is reported rather than planned
+	if len(plan.Warnings) != 2 || !strings.Contains(plan.Warnings[1], "unknown:thing") {
+		t.Errorf("Warnings = %q", plan.Warnings)
+	}
+
+	for region, client := range p.taggingClients {
+		if calls := client.(*fakeTaggingAPI).calls; calls != 0 {
+			t.Errorf("Plan() made %d tagging calls in %s", calls, region)
+		}
+	}
+}
+
+func TestCountResourcesPartialResult(t *testing.T) {
+	p := newTestProvider(t)
+	p.services = failingServices{err: &smithy.GenericAPIError{
+		Code:    "AccessDeniedException",
+		Message: "User: arn:aws:iam::123456789012:user/scan is not authorized to perform: guardduty:ListDetectors",
+	}}
+
+	result, err := p.CountResources(context.Background())
+	var partial *sizingerrors.PartialResultError
+	if !errors.As(err, &partial) {
+		t.Fatalf("CountResources() error = %v, want a partial result", err)
+	}
+	if result == nil || result.TotalResources != 6 {
+		t.Fatalf("CountResources() result = %+v, want the 6 counted resources", result)
+	}
+	if len(partial.Failed) != 1 || partial.Failed[0] != "guardduty:detector" {
+		t.Errorf("Failed = %v, want guardduty:detector", partial.Failed)
+	}
+	var permission *sizingerrors.PermissionError
+	if !errors.As(err, &permission) || permission.Action != "guardduty:ListDetectors" {
+		t.Errorf("cause = %v, want a permission error for guardduty:ListDetectors", err)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want the failed types", result.Warnings)
+	}
+}
+
+func TestCountResourcesAllRegionsDenied(t *testing.T) {
+	p := newTestProvider(t)
+	for region := range p.taggingClients {
+		p.taggingClients[region] = &fakeTaggingAPI{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}}
+	}
+
+	_, err := p.CountResources(context.Background())
+	if code := sizingerrors.ExitCode(err); code != sizingerrors.ExitPermission {
+		t.Fatalf("ExitCode(%v) = %d, want %d", err, code, sizingerrors.ExitPermission)
+	}
+	if hint := sizingerrors.Hint(err); !strings.Contains(hint, "tag:GetResources") {
+		t.Errorf("Hint() = %q, want it to name tag:GetResources", hint)
+	}
+}
+
+// fakeAssumeRole issues credentials that expire after the given lifetime and
+// records the requests
+type fakeAssumeRole struct {
+	lifetime time.Duration
+	inputs   []*sts.AssumeRoleInput
+}
+
+func (f *fakeAssumeRole) AssumeRole(_ context.Context, params *sts.AssumeRoleInput, _ ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	f.inputs = append(f.inputs, params)
+	return &sts.AssumeRoleOutput{Credentials: &stsTypes.Credentials{
+		AccessKeyId:     awsSdk.String("ASIA" + strconv.Itoa(len(f.inputs))),
+		SecretAccessKey: awsSdk.String("secret"),
+		SessionToken:    awsSdk.String("token"),
+		Expiration:      awsSdk.Time(time.Now().Add(f.lifetime)),
+	}}, nil
+}
+
+func TestAssumeRoleCredentials(t *testing.T) {
+	codes := 0
+	p, err := NewAWSProvider(config.ProviderConfig{
+		Provider:      "aws",
+		AssumeRoleARN: "arn:aws:iam::123456789012:role/Audit",
+		ExternalID:    "customer-1",
+		MFASerial:     "arn:aws:iam::111111111111:mfa/alice",
+		MFATokenProvider: func() (string, error) {
+			codes++
+			return "123456", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeAssumeRole{lifetime: time.Hour}
+	credentials, err := p.assumeRoleCredentials(fake)
+	if err != nil {
+		t.Fatalf("assumeRoleCredentials() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := credentials.Retrieve(context.Background()); err != nil {
+			t.Fatalf("Retrieve() error = %v", err)
+		}
+	}
+
+	// Valid credentials are cached, so the code is asked for once
+	if len(fake.inputs) != 1 || codes != 1 {
+		t.Fatalf("AssumeRole calls = %d, MFA prompts = %d, want 1 each", len(fake.inputs), codes)
+	}
+	input := fake.inputs[0]
+	if awsSdk.ToString(input.RoleArn) != "arn:aws:iam::123456789012:role/Audit" ||
+		awsSdk.ToString(input.ExternalId) != "customer-1" ||
+		awsSdk.ToString(input.SerialNumber) != "arn:aws:iam::111111111111:mfa/alice" ||
+		awsSdk.ToString(input.TokenCode) != "123456" ||
+		awsSdk.ToInt32(input.DurationSeconds) != int32(assumeRoleDuration.Seconds()) {
+		t.Errorf("unexpected AssumeRole input: %+v", input)
+	}
+}
+
+func TestAssumeRoleCredentialsRefresh(t *testing.T) {
+	p, err := NewAWSProvider(config.ProviderConfig{Provider: "aws", AssumeRoleARN: "arn:aws:iam::123456789012:role/Audit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Credentials inside the expiry window are renewed on the next use
+	fake := &fakeAssumeRole{lifetime: assumeRoleExpiryWindow / 2}
+	credentials, err := p.assumeRoleCredentials(fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.inputs) != 2 || first.AccessKeyID == second.AccessKeyID {
+		t.Errorf("AssumeRole calls = %d, want the expiring session to be renewed", len(fake.inputs))
+	}
+	if fake.inputs[0].ExternalId != nil || fake.inputs[0].SerialNumber != nil {
+		t.Errorf("unexpected external ID or MFA in %+v", fake.inputs[0])
+	}
+}
+
+func TestAssumeRoleCredentialsMFAWithoutTerminal(t *testing.T) {
+	p, err := NewAWSProvider(config.ProviderConfig{
+		Provider:      "aws",
+		AssumeRoleARN: "arn:aws:iam::123456789012:role/Audit",
+		MFASerial:     "arn:aws:iam::111111111111:mfa/alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeAssumeRole{lifetime: time.Hour}
+	if _, err := p.assumeRoleCredentials(fake); err == nil {
+		t.Error("assumeRoleCredentials() without an MFA token provider error = nil")
+	}
+	if len(fake.inputs) != 0 {
+		t.Errorf("AssumeRole calls = %d, want none", len(fake.inputs))
+	}
+}
+
+func TestCollectorsShareConcurrencyBudget(t *testing.T) {
+	provider, err := NewAWSProvider(config.ProviderConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.services.(*ServiceCollector).sem != provider.collector.(*ResourceCollector).sem {
+		t.Error("the service collector has a concurrency budget of its own")
+	}
+}
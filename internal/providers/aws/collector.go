@@ -4,95 +4,112 @@ package aws
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
 	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
 	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
 	"github.com/secrails/secrails-sizing-agent/pkg/logging"
 	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
+	"golang.org/x/time/rate"
 )
 
+// taggingAPI is the subset of the Resource Groups Tagging API client used by
+// the collector
+type taggingAPI interface {
+	GetResources(
+		ctx context.Context,
+		params *resourcegroupstaggingapi.GetResourcesInput,
+		optFns ...func(*resourcegroupstaggingapi.Options),
+	) (*resourcegroupstaggingapi.GetResourcesOutput, error)
+}
+
+// inventoryRequestsPerSecond paces tagging API page requests in inventory mode
+const inventoryRequestsPerSecond = 5
+
+// MaxPageSize is the largest page the tagging API returns, and its default
+const MaxPageSize = 100
+
 type ResourceCollector struct {
+	// sem bounds the number of in-flight tagging API calls. It is shared
+	// across all resource types, and with the ServiceCollector, so that
+	// type-level and region-level parallelism together stay within the
+	// throttling budget.
+	sem *semaphore.Weighted
+
+	definitions []models.ResourceDefinition
+
+	// inventory receives every resource seen when inventory mode is enabled.
+	// limiter paces page requests in that mode since every page is consumed.
+	inventory models.ResourceSink
+	limiter   *rate.Limiter
+
+	// tagFilters are passed to the tagging API, which applies them server-side
+	tagFilters []types.TagFilter
+
+	// noise lists the cloud-managed resources left out of the counts as
+	// NoiseExcluded; nil counts them like any other
+	noise noiseFilter
+
+	// pageSize is the number of resources asked for per tagging API page,
+	// MaxPageSize when 0, and maxPages the pages read per type and region
+	// before the count stops as truncated, unlimited when 0
+	pageSize int32
+	maxPages int
+
+	// breaker skips the regions that keep failing; nil never skips
+	breaker *regionBreaker
+}
+
+// NewResourceCollector creates a collector for the given definitions that
+// issues at most maxConcurrency API calls at a time. If inventory is non-nil
+// every resource found is also written to it. Only resources carrying all
+// tagFilters are counted.
+func NewResourceCollector(
+	maxConcurrency int64,
+	definitions []models.ResourceDefinition,
+	inventory models.ResourceSink,
+	tagFilters []models.TagFilter,
+) *ResourceCollector {
+	c := &ResourceCollector{
+		sem:         semaphore.NewWeighted(maxConcurrency),
+		definitions: definitions,
+		inventory:   inventory,
+	}
+	for _, filter := range tagFilters {
+		c.tagFilters = append(c.tagFilters, types.TagFilter{
+			Key:    awsSdk.String(filter.Key),
+			Values: []string{filter.Value},
+		})
+	}
+	if inventory != nil {
+		c.limiter = rate.NewLimiter(rate.Limit(inventoryRequestsPerSecond), 1)
+	}
+	return c
 }
 
+// GetResourceTypesToCount returns the resource definitions this collector counts
 func (c *ResourceCollector) GetResourceTypesToCount() []models.ResourceDefinition {
-	return []models.ResourceDefinition{
-		// Compute
-		{Type: "ec2:instance", DisplayName: "EC2 Instances", Category: "Compute", UseResourceGraph: false},
-		{Type: "lambda:function", DisplayName: "Lambda Functions", Category: "Compute", UseResourceGraph: false},
-		{Type: "ecs:cluster", DisplayName: "ECS Clusters", Category: "Containers", UseResourceGraph: false},
-		{Type: "ecs:service", DisplayName: "ECS Services", Category: "Containers", UseResourceGraph: false},
-		{Type: "ec2:autoscaling", DisplayName: "Auto Scaling Groups", Category: "Compute", UseResourceGraph: false},
-		{Type: "lightsail:instance", DisplayName: "Lightsail Instances", Category: "Compute", UseResourceGraph: false},
-		{Type: "eks:cluster", DisplayName: "EKS Clusters", Category: "Containers", UseResourceGraph: false},
-
-		// Messaging
-		{Type: "sqs:queue", DisplayName: "SQS Queues", Category: "Messaging", UseResourceGraph: false},
-		{Type: "sns:topic", DisplayName: "SNS Topics", Category: "Messaging", UseResourceGraph: false},
-
-		// Analytics
-		{Type: "kinesis:stream", DisplayName: "Kinesis Streams", Category: "Analytics", UseResourceGraph: false},
-		{Type: "firehose:delivery-stream", DisplayName: "Kinesis Firehose Delivery Streams", Category: "Analytics", UseResourceGraph: false},
-
-		// Monitoring
-		{Type: "cloudwatch:alarm", DisplayName: "CloudWatch Alarms", Category: "Monitoring", UseResourceGraph: false},
-
-		// Identity & Access Management
-		{Type: "iam:user", DisplayName: "IAM Users", Category: "IAM", UseResourceGraph: false},
-		{Type: "iam:role", DisplayName: "IAM Roles", Category: "IAM", UseResourceGraph: false},
-		{Type: "iam:group", DisplayName: "IAM Groups", Category: "IAM", UseResourceGraph: false},
-		{Type: "iam:policy", DisplayName: "IAM Policies", Category: "IAM", UseResourceGraph: false},
-
-		// Application Integration
-		{Type: "stepfunctions:state-machine", DisplayName: "Step Functions State Machines", Category: "Application Integration", UseResourceGraph: false},
-
-		// Developer Tools
-		{Type: "codecommit:repository", DisplayName: "CodeCommit Repositories", Category: "Developer Tools", UseResourceGraph: false},
-		{Type: "codebuild:project", DisplayName: "CodeBuild Projects", Category: "Developer Tools", UseResourceGraph: false},
-		{Type: "codedeploy:application", DisplayName: "CodeDeploy Applications", Category: "Developer Tools", UseResourceGraph: false},
-		{Type: "codepipeline:pipeline", DisplayName: "CodePipeline Pipelines", Category: "Developer Tools", UseResourceGraph: false},
-
-		// Machine Learning
-		{Type: "sagemaker:notebook-instance", DisplayName: "SageMaker Notebook Instances", Category: "Machine Learning", UseResourceGraph: false},
-		{Type: "sagemaker:endpoint", DisplayName: "SageMaker Endpoints", Category: "Machine Learning", UseResourceGraph: false},
-
-		// Storage
-		{Type: "s3:bucket", DisplayName: "S3 Buckets", Category: "Storage", UseResourceGraph: false},
-		{Type: "rds:db", DisplayName: "RDS Databases", Category: "Databases", UseResourceGraph: false},
-		{Type: "dynamodb:table", DisplayName: "DynamoDB Tables", Category: "Databases", UseResourceGraph: false},
-		{Type: "ebs:volume", DisplayName: "EBS Volumes", Category: "Storage", UseResourceGraph: false},
-		{Type: "efs:file-system", DisplayName: "EFS File Systems", Category: "Storage", UseResourceGraph: false},
-		{Type: "backup:backup-vault", DisplayName: "Backup Vaults", Category: "Storage", UseResourceGraph: false},
-		{Type: "elasticache:cluster", DisplayName: "ElastiCache Clusters", Category: "Databases", UseResourceGraph: false},
-		{Type: "redshift:cluster", DisplayName: "Redshift Clusters", Category: "Databases", UseResourceGraph: false},
-		{Type: "neptune:db-cluster", DisplayName: "Neptune Clusters", Category: "Databases", UseResourceGraph: false},
-
-		// Networking & Content Delivery
-		{Type: "cloudfront:distribution", DisplayName: "CloudFront Distributions", Category: "Networking", UseResourceGraph: false},
-		{Type: "route53:hosted-zone", DisplayName: "Route 53 Hosted Zones", Category: "Networking", UseResourceGraph: false},
-		{Type: "apigateway:rest-api", DisplayName: "API Gateway REST APIs", Category: "Networking", UseResourceGraph: false},
-		{Type: "apigatewayv2:api", DisplayName: "API Gateway HTTP/WebSocket APIs", Category: "Networking", UseResourceGraph: false},
-		{Type: "directconnect:connection", DisplayName: "Direct Connect Connections", Category: "Networking", UseResourceGraph: false},
-		{Type: "vpn:connection", DisplayName: "VPN Connections", Category: "Networking", UseResourceGraph: false},
-
-		// Migration & Transfer
-		{Type: "dms:replication-instance", DisplayName: "DMS Replication Instances", Category: "Migration & Transfer", UseResourceGraph: false},
-
-		// Business Applications
-		{Type: "workspaces:workspace", DisplayName: "WorkSpaces", Category: "Business Applications", UseResourceGraph: false},
-
-		// Networking
-		{Type: "ec2:vpc", DisplayName: "VPCs", Category: "Networking", UseResourceGraph: false},
-		{Type: "elasticloadbalancing:loadbalancer", DisplayName: "Load Balancers", Category: "Networking", UseResourceGraph: false},
-		{Type: "ec2:nat-gateway", DisplayName: "NAT Gateways", Category: "Networking", UseResourceGraph: false},
-		{Type: "ec2:internet-gateway", DisplayName: "Internet Gateways", Category: "Networking", UseResourceGraph: false},
-		{Type: "ec2:security-group", DisplayName: "Security Groups", Category: "Networking", UseResourceGraph: false},
-
-		// Security
-		{Type: "kms:key", DisplayName: "KMS Keys", Category: "Security", UseResourceGraph: false},
-		{Type: "secretsmanager:secret", DisplayName: "Secrets Manager Secrets", Category: "Security", UseResourceGraph: false},
-		{Type: "acm:certificate", DisplayName: "ACM Certificates", Category: "Security", UseResourceGraph: false},
-		{Type: "cloudhsm:v2-cluster", DisplayName: "CloudHSM Clusters", Category: "Security", UseResourceGraph: false},
+	return c.definitions
+}
+
+// PlanResourceType describes how CountResourceType counts resourceDef in
+// regions: a GetResources call per region
+func (c *ResourceCollector) PlanResourceType(resourceDef models.ResourceDefinition, regions []string) models.PlannedType {
+	if resourceDef.Global {
+		regions = globalRegion(regions)
+	}
+	return models.PlannedType{
+		Type:           resourceDef.ResourceType(),
+		DisplayName:    resourceDef.DisplayName,
+		CountMethod:    resourceDef.CountMethod,
+		API:            "tag:GetResources",
+		EstimatedCalls: len(regions),
 	}
 }
 
@@ -100,43 +117,100 @@ func (c *ResourceCollector) CountResourceType(
 	ctx context.Context,
 	resourceDef models.ResourceDefinition,
 	regions []string,
-	taggingClients map[string]*resourcegroupstaggingapi.Client,
+	taggingClients map[string]taggingAPI,
 ) (*models.ResourceCount, error) {
 
 	// Initialize result
 	result := &models.ResourceCount{
 		Provider:    "AWS",
-		Type:        models.ResourceType(resourceDef.Type),
+		Type:        resourceDef.ResourceType(),
 		DisplayName: resourceDef.DisplayName,
 		ByLocation:  make(map[string]int),
 		ByAccount:   make(map[string]int),
 	}
 
-	// Query each region
+	// Global resources are reported by a single region; scanning every
+	// region would count them once per region
+	if resourceDef.Global {
+		regions = globalRegion(regions)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	result.RegionsQueried = len(regions)
+	failed := func(region string) {
+		mu.Lock()
+		result.FailedRegions = append(result.FailedRegions, region)
+		mu.Unlock()
+	}
+	skipped := func() {
+		mu.Lock()
+		result.RegionsQueried--
+		mu.Unlock()
+	}
+
+	// Query each region concurrently; failures are isolated per region and
+	// recorded in FailedRegions
 	for _, region := range regions {
 		client, exists := taggingClients[region]
 		if !exists {
-			logging.Warn("No tagging client for region", zap.String("region", region))
+			logging.FromContext(ctx).Warn("No tagging client for region", zap.String("region", region))
+			failed(region)
 			continue
 		}
 
-		// Count resources in this region - directly use resourceDef.Type
-		count, err := c.countInRegion(ctx, client, resourceDef.Type)
-		if err != nil {
-			logging.Error("Failed to count in region",
-				zap.String("region", region),
-				zap.String("type", resourceDef.Type),
-				zap.Error(err))
-			continue
-		}
+		wg.Add(1)
+		go func(region string, client taggingAPI) {
+			defer wg.Done()
 
-		if count > 0 {
-			result.ByLocation[region] = count
-			result.TotalResources += count
-		}
+			// Acquire a slot from the shared budget
+			if err := c.sem.Acquire(ctx, 1); err != nil {
+				logging.FromContext(ctx).Error("Failed to count in region",
+					zap.String("region", region),
+					zap.String("type", resourceDef.Type),
+					zap.Error(err))
+				failed(region)
+				return
+			}
+			defer c.sem.Release(1)
+
+			// A region that kept failing is no longer counted
+			if !c.breaker.allow(region) {
+				skipped()
+				return
+			}
+
+			// Count resources in this region - directly use resourceDef.Type
+			regionCtx, span := tracing.Start(ctx, "CountRegion")
+			span.SetString("region", region)
+			count, noise, truncated, err := c.countInRegion(regionCtx, client, resourceDef, region)
+			span.SetInt("count", count)
+			span.End(err)
+			c.breaker.record(ctx, region, err)
+			if err != nil {
+				logging.FromContext(ctx).Error("Failed to count in region",
+					zap.String("region", region),
+					zap.String("type", resourceDef.Type),
+					zap.Error(err))
+				failed(region)
+				return
+			}
+
+			mu.Lock()
+			if count > 0 {
+				result.ByLocation[region] = count
+				result.TotalResources += count
+			}
+			result.NoiseExcluded += noise
+			result.Truncated = result.Truncated || truncated
+			mu.Unlock()
+		}(region, client)
 	}
 
-	logging.Debug("Completed counting",
+	wg.Wait()
+	sort.Strings(result.FailedRegions)
+
+	logging.FromContext(ctx).Debug("Completed counting",
 		zap.String("type", resourceDef.Type),
 		zap.Int("total", result.TotalResources),
 		zap.Int("regions", len(result.ByLocation)))
@@ -144,36 +218,139 @@ func (c *ResourceCollector) CountResourceType(
 	return result, nil
 }
 
-// Count resources in a specific region
+// Count resources in a specific region, and separately those the noise
+// filter marks as cloud-managed. truncated reports that maxPages stopped the
+// count before the last page.
 func (c *ResourceCollector) countInRegion(
 	ctx context.Context,
-	client *resourcegroupstaggingapi.Client,
-	resourceType string,
-) (int, error) {
+	client taggingAPI,
+	resourceDef models.ResourceDefinition,
+	region string,
+) (count, noise int, truncated bool, err error) {
 
-	count := 0
+	// Without the noise IDs the region is still counted, noise included
+	var noiseIDs map[string]bool
+	if c.noise != nil {
+		var err error
+		if noiseIDs, err = c.noise(ctx, resourceDef.ResourceType(), region); err != nil {
+			logging.FromContext(ctx).Warn("Failed to list cloud-managed resources; counting them",
+				zap.String("region", region),
+				zap.String("type", resourceDef.Type),
+				zap.Error(err))
+		}
+	}
+
+	pageSize := c.pageSize
+	if pageSize == 0 {
+		pageSize = MaxPageSize
+	}
+	page := 0
 	var paginationToken *string
 
 	for {
+		page++
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return 0, 0, false, err
+			}
+		}
+
 		input := &resourcegroupstaggingapi.GetResourcesInput{
-			ResourceTypeFilters: []string{resourceType},
+			ResourceTypeFilters: []string{resourceDef.Type},
+			TagFilters:          c.tagFilters,
 			PaginationToken:     paginationToken,
-			ResourcesPerPage:    awsSdk.Int32(100),
+			ResourcesPerPage:    awsSdk.Int32(pageSize),
 		}
 
-		output, err := client.GetResources(ctx, input)
+		pageCtx, span := tracing.Start(ctx, "GetResources")
+		span.SetString("region", region)
+		span.SetInt("page", page)
+		output, err := client.GetResources(pageCtx, input)
+		span.End(err)
 		if err != nil {
-			return 0, fmt.Errorf("failed to get resources: %w", err)
+			return 0, 0, false, fmt.Errorf("failed to get resources: %w", err)
 		}
 
-		count += len(output.ResourceTagMappingList)
+		for _, mapping := range output.ResourceTagMappingList {
+			if noiseIDs[nameFromARN(awsSdk.ToString(mapping.ResourceARN))] {
+				noise++
+				continue
+			}
+			count++
+			if c.inventory != nil {
+				if err := c.inventory.Write(toResource(mapping, resourceDef, region)); err != nil {
+					return 0, 0, false, err
+				}
+			}
+		}
 
 		// Check for more pages
 		if output.PaginationToken == nil || *output.PaginationToken == "" {
 			break
 		}
+		if c.maxPages > 0 && page >= c.maxPages {
+			logging.FromContext(ctx).Info("Reached max pages for resource type",
+				zap.String("region", region),
+				zap.String("type", resourceDef.Type),
+				zap.Int("pages", page))
+			return count, noise, true, nil
+		}
 		paginationToken = output.PaginationToken
 	}
 
-	return count, nil
+	return count, noise, false, nil
+}
+
+// toResource converts a tagging API mapping into an inventory resource
+func toResource(mapping types.ResourceTagMapping, resourceDef models.ResourceDefinition, region string) models.Resource {
+	arn := awsSdk.ToString(mapping.ResourceARN)
+	resource := models.Resource{
+		ID:       arn,
+		Name:     nameFromARN(arn),
+		Type:     resourceDef.ResourceType(),
+		Provider: "AWS",
+		Region:   region,
+	}
+
+	// arn:partition:service:region:account-id:resource
+	if parts := strings.SplitN(arn, ":", 6); len(parts) == 6 {
+		resource.Account = parts[4]
+		if parts[3] != "" {
+			resource.Region = parts[3]
+		}
+	}
+
+	if len(mapping.Tags) > 0 {
+		resource.Tags = make(map[string]string, len(mapping.Tags))
+		for _, tag := range mapping.Tags {
+			resource.Tags[awsSdk.ToString(tag.Key)] = awsSdk.ToString(tag.Value)
+		}
+		if name, ok := resource.Tags["Name"]; ok && name != "" {
+			resource.Name = name
+		}
+	}
+
+	return resource
+}
+
+// nameFromARN returns the last path or colon separated segment of an ARN
+func nameFromARN(arn string) string {
+	if i := strings.LastIndexAny(arn, "/:"); i >= 0 {
+		return arn[i+1:]
+	}
+	return arn
+}
+
+// globalRegion returns the region used to count global resources, preferring
+// us-east-1 where AWS homes them
+func globalRegion(regions []string) []string {
+	for _, region := range regions {
+		if region == "us-east-1" {
+			return []string{region}
+		}
+	}
+	if len(regions) > 0 {
+		return regions[:1]
+	}
+	return regions
 }
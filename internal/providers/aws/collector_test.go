@@ -0,0 +1,414 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
+)
+
+// fakeTaggingAPI serves pre-canned pages keyed by pagination token. The first
+// page is keyed by the empty string.
+type fakeTaggingAPI struct {
+	pages map[string]*resourcegroupstaggingapi.GetResourcesOutput
+	err   error
+
+	mu    sync.Mutex
+	calls int
+	input *resourcegroupstaggingapi.GetResourcesInput
+}
+
+func (f *fakeTaggingAPI) GetResources(
+	_ context.Context,
+	params *resourcegroupstaggingapi.GetResourcesInput,
+	_ ...func(*resourcegroupstaggingapi.Options),
+) (*resourcegroupstaggingapi.GetResourcesOutput, error) {
+	f.mu.Lock()
+	f.calls++
+	f.input = params
+	f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	token := awsSdk.ToString(params.PaginationToken)
+	page, ok := f.pages[token]
+	if !ok {
+		return nil, errors.New("unexpected pagination token " + token)
+	}
+	return page, nil
+}
+
+// page builds a tagging API response with n resources and the given next token
+func page(n int, next string) *resourcegroupstaggingapi.GetResourcesOutput {
+	out := &resourcegroupstaggingapi.GetResourcesOutput{}
+	for i := 0; i < n; i++ {
+		out.ResourceTagMappingList = append(out.ResourceTagMappingList, types.ResourceTagMapping{
+			ResourceARN: awsSdk.String("arn:aws:ec2:us-east-1:123456789012:instance/i-0"),
+		})
+	}
+	if next != "" {
+		out.PaginationToken = awsSdk.String(next)
+	}
+	return out
+}
+
+func TestCountResourceType(t *testing.T) {
+	throttled := errors.New("ThrottlingException: Rate exceeded")
+
+	tests := []struct {
+		name      string
+		regions   []string
+		clients   map[string]taggingAPI
+		wantTotal int
+		wantByLoc map[string]int
+		// wantFailed are the regions reported in FailedRegions
+		wantFailed []string
+		wantErr    bool
+	}{
+		{
+			name:    "single page",
+			regions: []string{"us-east-1"},
+			clients: map[string]taggingAPI{
+				"us-east-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{
+					"": page(3, ""),
+				}},
+			},
+			wantTotal: 3,
+			wantByLoc: map[string]int{"us-east-1": 3},
+		},
+		{
+			name:    "multiple pages",
+			regions: []string{"us-east-1"},
+			clients: map[string]taggingAPI{
+				"us-east-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{
+					"":   page(100, "p2"),
+					"p2": page(100, "p3"),
+					"p3": page(7, ""),
+				}},
+			},
+			wantTotal: 207,
+			wantByLoc: map[string]int{"us-east-1": 207},
+		},
+		{
+			name:    "empty pagination token ends the loop",
+			regions: []string{"eu-west-1"},
+			clients: map[string]taggingAPI{
+				"eu-west-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{
+					"": {
+						ResourceTagMappingList: page(2, "").ResourceTagMappingList,
+						PaginationToken:        awsSdk.String(""),
+					},
+				}},
+			},
+			wantTotal: 2,
+			wantByLoc: map[string]int{"eu-west-1": 2},
+		},
+		{
+			name:    "empty response",
+			regions: []string{"us-east-1", "us-west-2"},
+			clients: map[string]taggingAPI{
+				"us-east-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(0, "")}},
+				"us-west-2": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(0, "")}},
+			},
+			wantTotal: 0,
+			wantByLoc: map[string]int{},
+		},
+		{
+			name:    "throttled region is isolated",
+			regions: []string{"us-east-1", "us-west-2"},
+			clients: map[string]taggingAPI{
+				"us-east-1": &fakeTaggingAPI{err: throttled},
+				"us-west-2": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(4, "")}},
+			},
+			wantTotal:  4,
+			wantByLoc:  map[string]int{"us-west-2": 4},
+			wantFailed: []string{"us-east-1"},
+		},
+		{
+			name:    "error on a later page drops the region",
+			regions: []string{"us-east-1", "ap-south-1"},
+			clients: map[string]taggingAPI{
+				"us-east-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{
+					"": page(100, "missing"),
+				}},
+				"ap-south-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(1, "")}},
+			},
+			wantTotal:  1,
+			wantByLoc:  map[string]int{"ap-south-1": 1},
+			wantFailed: []string{"us-east-1"},
+		},
+		{
+			name:    "region without client is skipped",
+			regions: []string{"us-east-1", "me-south-1"},
+			clients: map[string]taggingAPI{
+				"us-east-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(5, "")}},
+			},
+			wantTotal:  5,
+			wantByLoc:  map[string]int{"us-east-1": 5},
+			wantFailed: []string{"me-south-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewResourceCollector(2, nil, nil, nil)
+			def := models.ResourceDefinition{Type: "ec2:instance", DisplayName: "EC2 Instances"}
+
+			got, err := collector.CountResourceType(context.Background(), def, tt.regions, tt.clients)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CountResourceType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.TotalResources != tt.wantTotal {
+				t.Errorf("TotalResources = %d, want %d", got.TotalResources, tt.wantTotal)
+			}
+			if len(got.ByLocation) != len(tt.wantByLoc) {
+				t.Errorf("ByLocation = %v, want %v", got.ByLocation, tt.wantByLoc)
+			}
+			for loc, want := range tt.wantByLoc {
+				if got.ByLocation[loc] != want {
+					t.Errorf("ByLocation[%s] = %d, want %d", loc, got.ByLocation[loc], want)
+				}
+			}
+			if got.RegionsQueried != len(tt.regions) || !reflect.DeepEqual(got.FailedRegions, tt.wantFailed) {
+				t.Errorf("RegionsQueried = %d, FailedRegions = %v, want %d and %v",
+					got.RegionsQueried, got.FailedRegions, len(tt.regions), tt.wantFailed)
+			}
+			if got.Type != def.ResourceType() || got.DisplayName != def.DisplayName {
+				t.Errorf("unexpected type metadata: %+v", got)
+			}
+		})
+	}
+}
+
+func TestCountResourceTypeCancelledContext(t *testing.T) {
+	collector := NewResourceCollector(1, nil, nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Exhaust the semaphore so that acquiring it observes the cancelled context
+	if err := collector.sem.Acquire(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	defer collector.sem.Release(1)
+
+	fake := &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(1, "")}}
+	got, err := collector.CountResourceType(ctx,
+		models.ResourceDefinition{Type: "s3:bucket"},
+		[]string{"us-east-1"},
+		map[string]taggingAPI{"us-east-1": fake})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.TotalResources != 0 {
+		t.Errorf("TotalResources = %d, want 0", got.TotalResources)
+	}
+	if fake.calls != 0 {
+		t.Errorf("expected no API calls, got %d", fake.calls)
+	}
+}
+
+func TestCountResourceTypeGlobal(t *testing.T) {
+	clients := map[string]taggingAPI{}
+	fakes := map[string]*fakeTaggingAPI{}
+	for _, region := range []string{"eu-west-1", "us-east-1", "us-west-2"} {
+		fake := &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(3, "")}}
+		fakes[region] = fake
+		clients[region] = fake
+	}
+
+	collector := NewResourceCollector(2, nil, nil, nil)
+	got, err := collector.CountResourceType(context.Background(),
+		models.ResourceDefinition{Type: "iam:role", Global: true},
+		[]string{"eu-west-1", "us-east-1", "us-west-2"}, clients)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.TotalResources != 3 {
+		t.Errorf("TotalResources = %d, want 3", got.TotalResources)
+	}
+	if fakes["us-east-1"].calls != 1 || fakes["eu-west-1"].calls != 0 || fakes["us-west-2"].calls != 0 {
+		t.Errorf("expected only us-east-1 to be queried")
+	}
+}
+
+func TestCountResourceTypeSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracing.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer tracing.SetTracerProvider(nil)
+
+	clients := map[string]taggingAPI{
+		"us-east-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{
+			"":   page(2, "t1"),
+			"t1": page(1, ""),
+		}},
+	}
+
+	collector := NewResourceCollector(2, nil, nil, nil)
+	def := models.ResourceDefinition{Type: "s3:bucket"}
+	if _, err := collector.CountResourceType(context.Background(), def, []string{"us-east-1"}, clients); err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]int{}
+	for _, span := range recorder.Ended() {
+		names[span.Name()]++
+	}
+	if names["CountRegion"] != 1 || names["GetResources"] != 2 {
+		t.Errorf("spans = %v, want one CountRegion and two GetResources", names)
+	}
+}
+
+func TestCountResourceTypeTagFilters(t *testing.T) {
+	fake := &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(1, "")}}
+	collector := NewResourceCollector(1, nil, nil, []models.TagFilter{{Key: "env", Value: "prod"}})
+	if _, err := collector.CountResourceType(context.Background(),
+		models.ResourceDefinition{Type: "ec2:instance"},
+		[]string{"us-east-1"},
+		map[string]taggingAPI{"us-east-1": fake}); err != nil {
+		t.Fatal(err)
+	}
+
+	filters := fake.input.TagFilters
+	if len(filters) != 1 || awsSdk.ToString(filters[0].Key) != "env" ||
+		len(filters[0].Values) != 1 || filters[0].Values[0] != "prod" {
+		t.Errorf("TagFilters = %+v, want env=prod", filters)
+	}
+}
+
+func TestCountResourceTypeExcludesNoise(t *testing.T) {
+	output := &resourcegroupstaggingapi.GetResourcesOutput{}
+	for _, id := range []string{"sg-default", "sg-web", "sg-db"} {
+		output.ResourceTagMappingList = append(output.ResourceTagMappingList, types.ResourceTagMapping{
+			ResourceARN: awsSdk.String("arn:aws:ec2:us-east-1:123456789012:security-group/" + id),
+		})
+	}
+	clients := map[string]taggingAPI{
+		"us-east-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": output}},
+	}
+
+	collector := NewResourceCollector(1, nil, nil, nil)
+	collector.noise = func(_ context.Context, resourceType models.ResourceType, region string) (map[string]bool, error) {
+		if resourceType != "ec2:security-group" || region != "us-east-1" {
+			t.Errorf("noise filter called for %s in %s", resourceType, region)
+		}
+		return map[string]bool{"sg-default": true}, nil
+	}
+
+	got, err := collector.CountResourceType(context.Background(),
+		models.ResourceDefinition{Type: "ec2:security-group"}, []string{"us-east-1"}, clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TotalResources != 2 || got.NoiseExcluded != 1 || got.ByLocation["us-east-1"] != 2 {
+		t.Errorf("TotalResources = %d, NoiseExcluded = %d, ByLocation = %v, want 2, 1 and 2 in us-east-1",
+			got.TotalResources, got.NoiseExcluded, got.ByLocation)
+	}
+}
+
+func TestCountResourceTypeCountsNoiseWhenListingFails(t *testing.T) {
+	clients := map[string]taggingAPI{
+		"us-east-1": &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(3, "")}},
+	}
+	collector := NewResourceCollector(1, nil, nil, nil)
+	collector.noise = func(context.Context, models.ResourceType, string) (map[string]bool, error) {
+		return nil, errors.New("UnauthorizedOperation")
+	}
+
+	got, err := collector.CountResourceType(context.Background(),
+		models.ResourceDefinition{Type: "ec2:vpc"}, []string{"us-east-1"}, clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.TotalResources != 3 || got.NoiseExcluded != 0 || len(got.FailedRegions) != 0 {
+		t.Errorf("TotalResources = %d, NoiseExcluded = %d, FailedRegions = %v, want 3, 0 and none",
+			got.TotalResources, got.NoiseExcluded, got.FailedRegions)
+	}
+}
+
+// pagedTaggingAPI serves total resources in pages of the requested size,
+// with the offset of the next page as its pagination token
+type pagedTaggingAPI struct {
+	total int
+	sizes []int32
+}
+
+func (f *pagedTaggingAPI) GetResources(
+	_ context.Context,
+	params *resourcegroupstaggingapi.GetResourcesInput,
+	_ ...func(*resourcegroupstaggingapi.Options),
+) (*resourcegroupstaggingapi.GetResourcesOutput, error) {
+	size := awsSdk.ToInt32(params.ResourcesPerPage)
+	f.sizes = append(f.sizes, size)
+	offset, _ := strconv.Atoi(awsSdk.ToString(params.PaginationToken))
+	n := min(int(size), f.total-offset)
+	next := ""
+	if offset+n < f.total {
+		next = strconv.Itoa(offset + n)
+	}
+	return page(n, next), nil
+}
+
+func TestCountResourceTypePageSizes(t *testing.T) {
+	tests := []struct {
+		pageSize      int32
+		maxPages      int
+		wantTotal     int
+		wantPages     int
+		wantTruncated bool
+	}{
+		{pageSize: 0, wantTotal: 230, wantPages: 3},
+		{pageSize: 100, wantTotal: 230, wantPages: 3},
+		{pageSize: 25, wantTotal: 230, wantPages: 10},
+		{pageSize: 7, wantTotal: 230, wantPages: 33},
+		{pageSize: 50, maxPages: 5, wantTotal: 230, wantPages: 5},
+		{pageSize: 50, maxPages: 2, wantTotal: 100, wantPages: 2, wantTruncated: true},
+		{pageSize: 0, maxPages: 1, wantTotal: 100, wantPages: 1, wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("size %d max %d", tt.pageSize, tt.maxPages), func(t *testing.T) {
+			fake := &pagedTaggingAPI{total: 230}
+			collector := NewResourceCollector(1, nil, nil, nil)
+			collector.pageSize, collector.maxPages = tt.pageSize, tt.maxPages
+
+			got, err := collector.CountResourceType(context.Background(),
+				models.ResourceDefinition{Type: "ec2:instance"},
+				[]string{"us-east-1"},
+				map[string]taggingAPI{"us-east-1": fake})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.TotalResources != tt.wantTotal || got.Truncated != tt.wantTruncated {
+				t.Errorf("total = %d, truncated = %v, want %d, %v", got.TotalResources, got.Truncated, tt.wantTotal, tt.wantTruncated)
+			}
+			if len(fake.sizes) != tt.wantPages {
+				t.Errorf("pages = %d, want %d", len(fake.sizes), tt.wantPages)
+			}
+			wantSize := tt.pageSize
+			if wantSize == 0 {
+				wantSize = MaxPageSize
+			}
+			if fake.sizes[0] != wantSize {
+				t.Errorf("ResourcesPerPage = %d, want %d", fake.sizes[0], wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"golang.org/x/sync/errgroup"
+)
+
+// ecsDescribeClustersBatch is the maximum number of clusters DescribeClusters
+// accepts per call
+const ecsDescribeClustersBatch = 100
+
+// eksAPI is the subset of the EKS client used to count node groups and
+// Fargate profiles
+type eksAPI interface {
+	eks.ListClustersAPIClient
+	eks.ListNodegroupsAPIClient
+	eks.ListFargateProfilesAPIClient
+}
+
+// ecsAPI is the subset of the ECS client used to count services and tasks
+type ecsAPI interface {
+	ecs.ListClustersAPIClient
+	ecs.ListServicesAPIClient
+	DescribeClusters(
+		ctx context.Context,
+		params *ecs.DescribeClustersInput,
+		optFns ...func(*ecs.Options),
+	) (*ecs.DescribeClustersOutput, error)
+}
+
+func listEKSClusters(ctx context.Context, client eks.ListClustersAPIClient) ([]string, error) {
+	var clusters []string
+	paginator := eks.NewListClustersPaginator(client, &eks.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list EKS clusters: %w", err)
+		}
+		clusters = append(clusters, page.Clusters...)
+	}
+	return clusters, nil
+}
+
+func listECSClusters(ctx context.Context, client ecs.ListClustersAPIClient) ([]string, error) {
+	var clusters []string
+	paginator := ecs.NewListClustersPaginator(client, &ecs.ListClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ECS clusters: %w", err)
+		}
+		clusters = append(clusters, page.ClusterArns...)
+	}
+	return clusters, nil
+}
+
+// sumPerCluster runs count for every cluster concurrently and adds up the
+// results. Request concurrency is bounded by the client's budget.
+func sumPerCluster(
+	ctx context.Context,
+	clusters []string,
+	count func(ctx context.Context, cluster string) (int, error),
+) (int, error) {
+	var total atomic.Int64
+	g, ctx := errgroup.WithContext(ctx)
+	for _, cluster := range clusters {
+		g.Go(func() error {
+			n, err := count(ctx, cluster)
+			if err != nil {
+				return fmt.Errorf("cluster %s: %w", cluster, err)
+			}
+			total.Add(int64(n))
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+	return int(total.Load()), nil
+}
+
+func countEKSNodegroups(ctx context.Context, client eksAPI) (int, error) {
+	clusters, err := listEKSClusters(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	return sumPerCluster(ctx, clusters, func(ctx context.Context, cluster string) (int, error) {
+		count := 0
+		paginator := eks.NewListNodegroupsPaginator(client, &eks.ListNodegroupsInput{
+			ClusterName: awsSdk.String(cluster),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return 0, fmt.Errorf("failed to list node groups: %w", err)
+			}
+			count += len(page.Nodegroups)
+		}
+		return count, nil
+	})
+}
+
+func countEKSFargateProfiles(ctx context.Context, client eksAPI) (int, error) {
+	clusters, err := listEKSClusters(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	return sumPerCluster(ctx, clusters, func(ctx context.Context, cluster string) (int, error) {
+		count := 0
+		paginator := eks.NewListFargateProfilesPaginator(client, &eks.ListFargateProfilesInput{
+			ClusterName: awsSdk.String(cluster),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return 0, fmt.Errorf("failed to list Fargate profiles: %w", err)
+			}
+			count += len(page.FargateProfileNames)
+		}
+		return count, nil
+	})
+}
+
+// countEKSNodes counts running EC2 worker nodes, attributed to clusters by the
+// kubernetes.io/cluster/<name> tag that managed and self-managed node groups
+// carry
+func countEKSNodes(ctx context.Context, eksClient eks.ListClustersAPIClient, ec2Client ec2.DescribeInstancesAPIClient) (int, error) {
+	clusters, err := listEKSClusters(ctx, eksClient)
+	if err != nil {
+		return 0, err
+	}
+	return sumPerCluster(ctx, clusters, func(ctx context.Context, cluster string) (int, error) {
+		count := 0
+		paginator := ec2.NewDescribeInstancesPaginator(ec2Client, &ec2.DescribeInstancesInput{
+			Filters: []ec2Types.Filter{
+				{
+					Name:   awsSdk.String("tag:kubernetes.io/cluster/" + cluster),
+					Values: []string{"owned", "shared"},
+				},
+				{
+					Name:   awsSdk.String("instance-state-name"),
+					Values: []string{"pending", "running"},
+				},
+			},
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return 0, fmt.Errorf("failed to describe nodes: %w", err)
+			}
+			for _, reservation := range page.Reservations {
+				count += len(reservation.Instances)
+			}
+		}
+		return count, nil
+	})
+}
+
+func countECSServices(ctx context.Context, client ecsAPI) (int, error) {
+	clusters, err := listECSClusters(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+	return sumPerCluster(ctx, clusters, func(ctx context.Context, cluster string) (int, error) {
+		count := 0
+		paginator := ecs.NewListServicesPaginator(client, &ecs.ListServicesInput{
+			Cluster: awsSdk.String(cluster),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return 0, fmt.Errorf("failed to list services: %w", err)
+			}
+			count += len(page.ServiceArns)
+		}
+		return count, nil
+	})
+}
+
+// countECSRunningTasks sums the running task count DescribeClusters reports
+// for every cluster, which covers both service and standalone tasks
+func countECSRunningTasks(ctx context.Context, client ecsAPI) (int, error) {
+	clusters, err := listECSClusters(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for start := 0; start < len(clusters); start += ecsDescribeClustersBatch {
+		end := min(start+ecsDescribeClustersBatch, len(clusters))
+		output, err := client.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+			Clusters: clusters[start:end],
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to describe ECS clusters: %w", err)
+		}
+		for _, cluster := range output.Clusters {
+			count += int(cluster.RunningTasksCount)
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,228 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// fakeEKS serves one region's clusters. Node groups are returned one per
+// page to exercise pagination.
+type fakeEKS struct {
+	nodegroups      map[string][]string
+	fargateProfiles map[string][]string
+}
+
+func (f *fakeEKS) ListClusters(
+	_ context.Context,
+	_ *eks.ListClustersInput,
+	_ ...func(*eks.Options),
+) (*eks.ListClustersOutput, error) {
+	output := &eks.ListClustersOutput{}
+	for cluster := range f.nodegroups {
+		output.Clusters = append(output.Clusters, cluster)
+	}
+	return output, nil
+}
+
+func (f *fakeEKS) ListNodegroups(
+	_ context.Context,
+	params *eks.ListNodegroupsInput,
+	_ ...func(*eks.Options),
+) (*eks.ListNodegroupsOutput, error) {
+	groups, ok := f.nodegroups[awsSdk.ToString(params.ClusterName)]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %s", awsSdk.ToString(params.ClusterName))
+	}
+	i := 0
+	if params.NextToken != nil {
+		fmt.Sscan(*params.NextToken, &i)
+	}
+	output := &eks.ListNodegroupsOutput{}
+	if i < len(groups) {
+		output.Nodegroups = groups[i : i+1]
+	}
+	if i+1 < len(groups) {
+		output.NextToken = awsSdk.String(fmt.Sprint(i + 1))
+	}
+	return output, nil
+}
+
+func (f *fakeEKS) ListFargateProfiles(
+	_ context.Context,
+	params *eks.ListFargateProfilesInput,
+	_ ...func(*eks.Options),
+) (*eks.ListFargateProfilesOutput, error) {
+	return &eks.ListFargateProfilesOutput{
+		FargateProfileNames: f.fargateProfiles[awsSdk.ToString(params.ClusterName)],
+	}, nil
+}
+
+// fakeEC2 returns nodes for the cluster named in the tag filter
+type fakeEC2 struct {
+	nodes map[string]int
+}
+
+func (f *fakeEC2) DescribeInstances(
+	_ context.Context,
+	params *ec2.DescribeInstancesInput,
+	_ ...func(*ec2.Options),
+) (*ec2.DescribeInstancesOutput, error) {
+	for _, filter := range params.Filters {
+		name := awsSdk.ToString(filter.Name)
+		if cluster, ok := strings.CutPrefix(name, "tag:kubernetes.io/cluster/"); ok {
+			return &ec2.DescribeInstancesOutput{Reservations: []ec2Types.Reservation{
+				{Instances: make([]ec2Types.Instance, f.nodes[cluster])},
+			}}, nil
+		}
+	}
+	return nil, fmt.Errorf("missing cluster tag filter")
+}
+
+type fakeECS struct {
+	services     map[string]int
+	runningTasks map[string]int32
+}
+
+func (f *fakeECS) ListClusters(
+	_ context.Context,
+	_ *ecs.ListClustersInput,
+	_ ...func(*ecs.Options),
+) (*ecs.ListClustersOutput, error) {
+	output := &ecs.ListClustersOutput{}
+	for cluster := range f.services {
+		output.ClusterArns = append(output.ClusterArns, cluster)
+	}
+	return output, nil
+}
+
+func (f *fakeECS) ListServices(
+	_ context.Context,
+	params *ecs.ListServicesInput,
+	_ ...func(*ecs.Options),
+) (*ecs.ListServicesOutput, error) {
+	return &ecs.ListServicesOutput{
+		ServiceArns: make([]string, f.services[awsSdk.ToString(params.Cluster)]),
+	}, nil
+}
+
+func (f *fakeECS) DescribeClusters(
+	_ context.Context,
+	params *ecs.DescribeClustersInput,
+	_ ...func(*ecs.Options),
+) (*ecs.DescribeClustersOutput, error) {
+	output := &ecs.DescribeClustersOutput{}
+	for _, cluster := range params.Clusters {
+		output.Clusters = append(output.Clusters, ecsTypes.Cluster{
+			ClusterArn:        awsSdk.String(cluster),
+			RunningTasksCount: f.runningTasks[cluster],
+		})
+	}
+	return output, nil
+}
+
+func TestContainerCountersByRegion(t *testing.T) {
+	eksClients := map[string]*fakeEKS{
+		"us-east-1": {
+			nodegroups:      map[string][]string{"prod": {"system", "app", "gpu"}, "staging": {"default"}},
+			fargateProfiles: map[string][]string{"prod": {"fp-default"}},
+		},
+		"eu-west-1": {
+			nodegroups: map[string][]string{"eu-prod": {"default", "batch"}},
+		},
+	}
+	ec2Clients := map[string]*fakeEC2{
+		"us-east-1": {nodes: map[string]int{"prod": 12, "staging": 2}},
+		"eu-west-1": {nodes: map[string]int{"eu-prod": 5}},
+	}
+	ecsClients := map[string]*fakeECS{
+		"us-east-1": {
+			services:     map[string]int{"web": 4, "jobs": 1},
+			runningTasks: map[string]int32{"web": 10, "jobs": 3},
+		},
+		"eu-west-1": {
+			services:     map[string]int{"eu-web": 2},
+			runningTasks: map[string]int32{"eu-web": 4},
+		},
+	}
+
+	collector := &ServiceCollector{
+		sem: semaphore.NewWeighted(2),
+		counters: map[models.ResourceType]serviceCounter{
+			"eks:nodegroup": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+				return counted(countEKSNodegroups(ctx, eksClients[cfg.Region]))
+			},
+			"eks:fargateprofile": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+				return counted(countEKSFargateProfiles(ctx, eksClients[cfg.Region]))
+			},
+			"eks:node": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+				return counted(countEKSNodes(ctx, eksClients[cfg.Region], ec2Clients[cfg.Region]))
+			},
+			"ecs:service": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+				return counted(countECSServices(ctx, ecsClients[cfg.Region]))
+			},
+			"ecs:task": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+				return counted(countECSRunningTasks(ctx, ecsClients[cfg.Region]))
+			},
+		},
+	}
+
+	tests := []struct {
+		resourceType string
+		want         map[string]int
+	}{
+		{"eks:nodegroup", map[string]int{"us-east-1": 4, "eu-west-1": 2}},
+		{"eks:fargateprofile", map[string]int{"us-east-1": 1}},
+		{"eks:node", map[string]int{"us-east-1": 14, "eu-west-1": 5}},
+		{"ecs:service", map[string]int{"us-east-1": 5, "eu-west-1": 2}},
+		{"ecs:task", map[string]int{"us-east-1": 13, "eu-west-1": 4}},
+	}
+
+	regions := []string{"us-east-1", "eu-west-1"}
+	for _, tt := range tests {
+		t.Run(tt.resourceType, func(t *testing.T) {
+			def := models.ResourceDefinition{Type: tt.resourceType, DisplayName: tt.resourceType}
+			got, err := collector.CountResourceType(context.Background(), def, regions, awsSdk.Config{})
+			if err != nil {
+				t.Fatalf("CountResourceType() error = %v", err)
+			}
+
+			wantTotal := 0
+			for region, count := range tt.want {
+				wantTotal += count
+				if got.ByLocation[region] != count {
+					t.Errorf("ByLocation[%s] = %d, want %d", region, got.ByLocation[region], count)
+				}
+			}
+			if len(got.ByLocation) != len(tt.want) {
+				t.Errorf("ByLocation = %v, want %v", got.ByLocation, tt.want)
+			}
+			if got.TotalResources != wantTotal {
+				t.Errorf("TotalResources = %d, want %d", got.TotalResources, wantTotal)
+			}
+		})
+	}
+}
+
+func TestSumPerClusterError(t *testing.T) {
+	_, err := sumPerCluster(context.Background(), []string{"a", "b"}, func(_ context.Context, cluster string) (int, error) {
+		if cluster == "b" {
+			return 0, fmt.Errorf("access denied")
+		}
+		return 1, nil
+	})
+	if err == nil {
+		t.Fatal("expected error from failing cluster")
+	}
+}
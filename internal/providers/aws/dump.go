@@ -0,0 +1,51 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	awsMiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+)
+
+// withDebugDump returns middleware that passes every raw response to
+// dumper. It runs last in the deserialize step, next to the transport, so it
+// reads each attempt's body before the SDK decodes it and puts it back.
+func withDebugDump(dumper *debugdump.Dumper) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		dump := middleware.DeserializeMiddlewareFunc("DebugDump", func(
+			ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler,
+		) (middleware.DeserializeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleDeserialize(ctx, in)
+			response, ok := out.RawResponse.(*smithyhttp.Response)
+			if !ok || response.Body == nil {
+				return out, metadata, err
+			}
+			body, readErr := io.ReadAll(response.Body)
+			response.Body.Close()
+			response.Body = io.NopCloser(bytes.NewReader(body))
+			if readErr != nil {
+				return out, metadata, readErr
+			}
+
+			dumped := debugdump.Response{
+				Provider:  metricsProvider,
+				Service:   awsMiddleware.GetServiceID(ctx),
+				Operation: awsMiddleware.GetOperationName(ctx),
+				Region:    awsMiddleware.GetRegion(ctx),
+				Status:    response.StatusCode,
+				Body:      body,
+			}
+			if request, ok := in.Request.(*smithyhttp.Request); ok {
+				dumped.Method, dumped.URL = request.Method, request.URL.String()
+			}
+			dumper.Write(ctx, dumped)
+			return out, metadata, err
+		})
+		return stack.Deserialize.Add(dump, middleware.After)
+	}
+}
@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+)
+
+func TestDebugDump(t *testing.T) {
+	dir := t.TempDir()
+	dumper, err := debugdump.New(dir, debugdump.DefaultMaxBytes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpClient := &scriptedHTTPClient{responses: []*http.Response{
+		xmlResponse(http.StatusOK, `<GetCallerIdentityResponse><GetCallerIdentityResult>`+
+			`<Account>123456789012</Account><Arn>arn:aws:iam::123456789012:user/test</Arn>`+
+			`<UserId>AIDA</UserId></GetCallerIdentityResult></GetCallerIdentityResponse>`),
+	}}
+	cfg := awsSdk.Config{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		HTTPClient:  httpClient,
+		APIOptions:  []func(*middleware.Stack) error{withDebugDump(dumper)},
+	}
+
+	ctx := debugdump.WithResourceType(context.Background(), "iam:user")
+	output, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		t.Fatalf("GetCallerIdentity() error = %v", err)
+	}
+	// The SDK still decodes the response the dump read
+	if awsSdk.ToString(output.Account) != "123456789012" {
+		t.Errorf("Account = %q, want the decoded response", awsSdk.ToString(output.Account))
+	}
+	if err := dumper.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, debugdump.ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest debugdump.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	want := debugdump.Entry{File: "000001.json", Provider: "aws", Service: "STS", Operation: "GetCallerIdentity",
+		ResourceType: "iam:user", Region: "eu-west-1", Status: http.StatusOK}
+	if len(manifest.Entries) != 1 {
+		t.Fatalf("entries = %+v, want one", manifest.Entries)
+	}
+	got := manifest.Entries[0]
+	got.Bytes = 0
+	if got != want {
+		t.Errorf("entry = %+v, want %+v", got, want)
+	}
+
+	dumped, err := os.ReadFile(filepath.Join(dir, got.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dumped), "arn:aws:iam::123456789012:user/test") {
+		t.Errorf("dump = %s, want the raw response", dumped)
+	}
+}
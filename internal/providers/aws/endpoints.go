@@ -0,0 +1,61 @@
+package aws
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// EndpointServices are the keys of the services the agent calls, for
+// per-service endpoint overrides: each SDK service ID in lower case with
+// underscores for spaces, as in the services sections of the AWS config file
+var EndpointServices = []string{
+	"cloudtrail",
+	"config_service",
+	"ec2",
+	"ecr",
+	"ecs",
+	"eks",
+	"guardduty",
+	"iam",
+	"inspector2",
+	"organizations",
+	"rds",
+	"resource_groups_tagging_api",
+	"securityhub",
+	"sts",
+	"wafv2",
+}
+
+// EndpointServiceKey returns the key of a service given by SDK service ID or
+// by key, e.g. "resource_groups_tagging_api" for "Resource Groups Tagging API"
+func EndpointServiceKey(service string) string {
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(service)))
+}
+
+// ValidEndpointService reports whether service names a service the agent calls
+func ValidEndpointService(service string) bool {
+	return slices.Contains(EndpointServices, EndpointServiceKey(service))
+}
+
+// serviceEndpoints maps service keys to endpoint URLs. Put first among the
+// SDK's config sources, it is consulted by every client as it resolves its
+// endpoint, ahead of AWS_ENDPOINT_URL_<SERVICE> and the shared config file,
+// and takes precedence over the base endpoint.
+type serviceEndpoints map[string]string
+
+// newServiceEndpoints normalizes the keys of endpoints
+func newServiceEndpoints(endpoints map[string]string) serviceEndpoints {
+	normalized := make(serviceEndpoints, len(endpoints))
+	for service, url := range endpoints {
+		normalized[EndpointServiceKey(service)] = url
+	}
+	return normalized
+}
+
+// GetServiceBaseEndpoint returns the endpoint of the service with the given
+// SDK service ID
+func (e serviceEndpoints) GetServiceBaseEndpoint(_ context.Context, sdkID string) (string, bool, error) {
+	url, ok := e[EndpointServiceKey(sdkID)]
+	return url, ok, nil
+}
@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+func TestServiceEndpoints(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		endpoints map[string]string
+		// want is the host each service's call goes to
+		want map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{
+				"STS":     "sts.eu-west-1.amazonaws.com",
+				"EC2":     "ec2.eu-west-1.amazonaws.com",
+				"Tagging": "tagging.eu-west-1.amazonaws.com",
+			},
+		},
+		{
+			name: "base endpoint",
+			base: "http://localhost:4566",
+			want: map[string]string{"STS": "localhost:4566", "EC2": "localhost:4566", "Tagging": "localhost:4566"},
+		},
+		{
+			name: "service endpoints",
+			endpoints: map[string]string{
+				"ec2":                         "https://vpce-1.ec2.eu-west-1.vpce.amazonaws.com",
+				"Resource Groups Tagging API": "https://vpce-2.tagging.eu-west-1.vpce.amazonaws.com",
+			},
+			want: map[string]string{
+				"STS":     "sts.eu-west-1.amazonaws.com",
+				"EC2":     "vpce-1.ec2.eu-west-1.vpce.amazonaws.com",
+				"Tagging": "vpce-2.tagging.eu-west-1.vpce.amazonaws.com",
+			},
+		},
+		{
+			name:      "service endpoints take precedence over the base endpoint",
+			base:      "http://localhost:4566",
+			endpoints: map[string]string{"sts": "https://sts.internal.example.com"},
+			want:      map[string]string{"STS": "sts.internal.example.com", "EC2": "localhost:4566", "Tagging": "localhost:4566"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := &scriptedHTTPClient{responses: []*http.Response{
+				xmlResponse(http.StatusOK, `<GetCallerIdentityResponse><GetCallerIdentityResult>`+
+					`<Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`),
+				xmlResponse(http.StatusOK, `<DescribeRegionsResponse><regionInfo/></DescribeRegionsResponse>`),
+				{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody},
+			}}
+			cfg := awsSdk.Config{
+				Region:      "eu-west-1",
+				Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+				HTTPClient:  httpClient,
+			}
+			if tt.base != "" {
+				cfg.BaseEndpoint = awsSdk.String(tt.base)
+			}
+			if tt.endpoints != nil {
+				cfg.ConfigSources = []any{newServiceEndpoints(tt.endpoints)}
+			}
+
+			ctx := context.Background()
+			_, _ = sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			_, _ = ec2.NewFromConfig(cfg).DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
+			_, _ = resourcegroupstaggingapi.NewFromConfig(cfg).GetResources(ctx, &resourcegroupstaggingapi.GetResourcesInput{})
+
+			if len(httpClient.requests) != 3 {
+				t.Fatalf("%d requests sent, want 3", len(httpClient.requests))
+			}
+			for i, service := range []string{"STS", "EC2", "Tagging"} {
+				if got := httpClient.requests[i].URL.Host; got != tt.want[service] {
+					t.Errorf("%s call sent to %s, want %s", service, got, tt.want[service])
+				}
+			}
+		})
+	}
+}
+
+func TestValidEndpointService(t *testing.T) {
+	for _, service := range []string{"ec2", "EC2", "resource_groups_tagging_api", "Resource Groups Tagging API", "config-service"} {
+		if !ValidEndpointService(service) {
+			t.Errorf("ValidEndpointService(%q) = false", service)
+		}
+	}
+	for _, service := range []string{"", "s3", "tagging"} {
+		if ValidEndpointService(service) {
+			t.Errorf("ValidEndpointService(%q) = true", service)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// Error codes of invalid, expired or missing credentials, and of calls the
+// credentials are not allowed to make
+var (
+	authErrorCodes = []string{
+		"ExpiredToken", "ExpiredTokenException", "InvalidClientTokenId", "UnrecognizedClientException",
+		"SignatureDoesNotMatch", "IncompleteSignature", "InvalidAccessKeyId", "MissingAuthenticationToken",
+		"AuthFailure",
+	}
+	permissionErrorCodes = append([]string{"AuthorizationError", "AccessDeniedFault"}, accessDeniedCodes...)
+)
+
+// deniedActionPattern extracts the IAM action from an access denied message
+// such as "... is not authorized to perform: ec2:DescribeInstances on ..."
+var deniedActionPattern = regexp.MustCompile(`not authorized to perform:? ([a-zA-Z0-9-]+:[a-zA-Z0-9]+)`)
+
+// classifyError wraps an SDK error into the error taxonomy. action is the IAM
+// permission the failed call needs, used when the error message does not name
+// it. Unrecognized errors are returned unchanged.
+func classifyError(err error, action string) error {
+	if err == nil || sizingerrors.Classified(err) {
+		return err
+	}
+
+	var signingErr *v4.SigningError
+	switch {
+	case sizingerrors.IsCertificateError(err):
+		return &sizingerrors.TLSError{Err: err}
+	case errors.As(err, &signingErr), hasErrorCode(err, authErrorCodes...):
+		return &sizingerrors.AuthError{Provider: metricsProvider, Err: err}
+	case hasErrorCode(err, permissionErrorCodes...):
+		return permissionError(err, action)
+	case isThrottle(err):
+		return &sizingerrors.ThrottledError{Provider: metricsProvider, Err: err}
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusUnauthorized:
+			return &sizingerrors.AuthError{Provider: metricsProvider, Err: err}
+		case http.StatusForbidden:
+			return permissionError(err, action)
+		case http.StatusTooManyRequests:
+			return &sizingerrors.ThrottledError{Provider: metricsProvider, Err: err}
+		}
+	}
+	return err
+}
+
+// permissionError names the denied action from the message when possible
+func permissionError(err error, action string) error {
+	if match := deniedActionPattern.FindStringSubmatch(err.Error()); match != nil {
+		action = match[1]
+	}
+	return &sizingerrors.PermissionError{Provider: metricsProvider, Action: action, Err: err}
+}
+
+// typeAction returns the IAM permission counting a type needs, when it is the
+// same for every type of its count method
+func typeAction(def models.ResourceDefinition) string {
+	if def.CountMethod == models.CountMethodServiceAPI {
+		return ""
+	}
+	return "tag:GetResources"
+}
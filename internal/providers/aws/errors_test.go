@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+)
+
+// operationError wraps err the way SDK clients return it
+func operationError(err error) error {
+	return &smithy.OperationError{ServiceID: "STS", OperationName: "GetCallerIdentity", Err: err}
+}
+
+// responseError returns an SDK HTTP response error with the given status
+func responseError(status int) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+			Err:      errors.New("http error"),
+		},
+		RequestID: "req-1",
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		action     string
+		wantCode   int
+		wantAction string
+	}{
+		{
+			name:     "expired token",
+			err:      operationError(&smithy.GenericAPIError{Code: "ExpiredToken"}),
+			wantCode: sizingerrors.ExitAuth,
+		},
+		{
+			name:     "invalid access key",
+			err:      operationError(&smithy.GenericAPIError{Code: "InvalidClientTokenId"}),
+			wantCode: sizingerrors.ExitAuth,
+		},
+		{
+			name:     "no credentials",
+			err:      operationError(&v4.SigningError{Err: errors.New("failed to retrieve credentials")}),
+			wantCode: sizingerrors.ExitAuth,
+		},
+		{
+			name:       "access denied uses the given action",
+			err:        operationError(&smithy.GenericAPIError{Code: "AccessDeniedException"}),
+			action:     "tag:GetResources",
+			wantCode:   sizingerrors.ExitPermission,
+			wantAction: "tag:GetResources",
+		},
+		{
+			name: "access denied names the action",
+			err: operationError(&smithy.GenericAPIError{
+				Code:    "UnauthorizedOperation",
+				Message: "You are not authorized to perform: ec2:DescribeInstances on resource *",
+			}),
+			action:     "ec2:DescribeRegions",
+			wantCode:   sizingerrors.ExitPermission,
+			wantAction: "ec2:DescribeInstances",
+		},
+		{
+			name:     "throttling",
+			err:      operationError(&smithy.GenericAPIError{Code: "ThrottlingException"}),
+			wantCode: sizingerrors.ExitThrottled,
+		},
+		{name: "HTTP 401", err: operationError(responseError(http.StatusUnauthorized)), wantCode: sizingerrors.ExitAuth},
+		{name: "HTTP 403", err: operationError(responseError(http.StatusForbidden)), wantCode: sizingerrors.ExitPermission},
+		{name: "HTTP 429", err: operationError(responseError(http.StatusTooManyRequests)), wantCode: sizingerrors.ExitThrottled},
+		{name: "HTTP 500", err: operationError(responseError(http.StatusInternalServerError)), wantCode: sizingerrors.ExitFailure},
+		{name: "network", err: operationError(errors.New("dial tcp: i/o timeout")), wantCode: sizingerrors.ExitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("failed to count: %w", classifyError(tt.err, tt.action))
+			if code := sizingerrors.ExitCode(err); code != tt.wantCode {
+				t.Errorf("ExitCode() = %d, want %d", code, tt.wantCode)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Error("classified error does not wrap the SDK error")
+			}
+
+			var permission *sizingerrors.PermissionError
+			if errors.As(err, &permission) && permission.Action != tt.wantAction {
+				t.Errorf("Action = %q, want %q", permission.Action, tt.wantAction)
+			}
+			if tt.wantCode != sizingerrors.ExitFailure && sizingerrors.Hint(err) == "" {
+				t.Error("classified error has no hint")
+			}
+		})
+	}
+}
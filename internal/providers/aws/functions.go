@@ -0,0 +1,47 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// Function package types reported in ByKind
+const (
+	packageZip       = "zip"
+	packageContainer = "container"
+)
+
+// countFunctions counts Lambda functions by package type and, for zip
+// packages, by runtime. Container images carry their own runtime and report
+// none. ListFunctions returns every function, tagged or not.
+func countFunctions(ctx context.Context, client lambda.ListFunctionsAPIClient) (regionCount, error) {
+	result := regionCount{
+		byKind:    make(map[string]int),
+		byRuntime: make(map[string]int),
+	}
+
+	paginator := lambda.NewListFunctionsPaginator(client, &lambda.ListFunctionsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to list functions: %w", err)
+		}
+
+		for _, function := range page.Functions {
+			result.total++
+			if function.PackageType == lambdaTypes.PackageTypeImage {
+				result.byKind[packageContainer]++
+				continue
+			}
+			result.byKind[packageZip]++
+			if function.Runtime != "" {
+				result.byRuntime[string(function.Runtime)]++
+			}
+		}
+	}
+
+	return result, nil
+}
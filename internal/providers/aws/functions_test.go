@@ -0,0 +1,75 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// fakeFunctions serves functions in pages keyed by marker
+type fakeFunctions struct {
+	pages map[string]*lambda.ListFunctionsOutput
+	err   error
+}
+
+func (f *fakeFunctions) ListFunctions(
+	_ context.Context,
+	params *lambda.ListFunctionsInput,
+	_ ...func(*lambda.Options),
+) (*lambda.ListFunctionsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.pages[awsSdk.ToString(params.Marker)], nil
+}
+
+func TestCountFunctions(t *testing.T) {
+	client := &fakeFunctions{pages: map[string]*lambda.ListFunctionsOutput{
+		"": {
+			Functions: []lambdaTypes.FunctionConfiguration{
+				{PackageType: lambdaTypes.PackageTypeZip, Runtime: lambdaTypes.RuntimePython312},
+				{PackageType: lambdaTypes.PackageTypeZip, Runtime: lambdaTypes.RuntimeNodejs20x},
+				{PackageType: lambdaTypes.PackageTypeImage},
+			},
+			NextMarker: awsSdk.String("page-2"),
+		},
+		"page-2": {
+			Functions: []lambdaTypes.FunctionConfiguration{
+				{PackageType: lambdaTypes.PackageTypeZip, Runtime: lambdaTypes.RuntimePython312},
+				// Old functions may omit the package type
+				{Runtime: lambdaTypes.RuntimeJava21},
+			},
+		},
+	}}
+
+	got, err := countFunctions(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.total != 5 {
+		t.Errorf("total = %d, want 5", got.total)
+	}
+	if got.byKind[packageZip] != 4 || got.byKind[packageContainer] != 1 {
+		t.Errorf("byKind = %v, want 4 zip and 1 container", got.byKind)
+	}
+	want := map[string]int{"python3.12": 2, "nodejs20.x": 1, "java21": 1}
+	if len(got.byRuntime) != len(want) {
+		t.Errorf("byRuntime = %v, want %v", got.byRuntime, want)
+	}
+	for runtime, count := range want {
+		if got.byRuntime[runtime] != count {
+			t.Errorf("byRuntime[%s] = %d, want %d", runtime, got.byRuntime[runtime], count)
+		}
+	}
+}
+
+func TestCountFunctionsError(t *testing.T) {
+	client := &fakeFunctions{err: errors.New("AccessDeniedException")}
+	if _, err := countFunctions(context.Background(), client); err == nil {
+		t.Fatal("expected an error")
+	}
+}
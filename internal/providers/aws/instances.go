@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// lifecycleOnDemand is reported for instances without an InstanceLifecycle
+const lifecycleOnDemand = "on-demand"
+
+// countInstances counts EC2 instances by state, instance type and lifecycle.
+// Terminated instances stay visible for about an hour and are excluded.
+func countInstances(ctx context.Context, client ec2.DescribeInstancesAPIClient) (regionCount, error) {
+	result := regionCount{
+		byState:     make(map[string]int),
+		bySKU:       make(map[string]int),
+		byLifecycle: make(map[string]int),
+	}
+
+	paginator := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe instances: %w", err)
+		}
+
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				state := ec2Types.InstanceStateNameRunning
+				if instance.State != nil {
+					state = instance.State.Name
+				}
+				if state == ec2Types.InstanceStateNameTerminated {
+					continue
+				}
+
+				lifecycle := string(instance.InstanceLifecycle)
+				if lifecycle == "" {
+					lifecycle = lifecycleOnDemand
+				}
+
+				result.total++
+				result.byState[string(state)]++
+				result.bySKU[string(instance.InstanceType)]++
+				result.byLifecycle[lifecycle]++
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,110 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// fakeInstances serves DescribeInstances pages keyed by next token
+type fakeInstances struct {
+	pages map[string][]ec2Types.Instance
+	next  map[string]string
+}
+
+func (f *fakeInstances) DescribeInstances(
+	_ context.Context,
+	params *ec2.DescribeInstancesInput,
+	_ ...func(*ec2.Options),
+) (*ec2.DescribeInstancesOutput, error) {
+	token := awsSdk.ToString(params.NextToken)
+	output := &ec2.DescribeInstancesOutput{
+		Reservations: []ec2Types.Reservation{{Instances: f.pages[token]}},
+	}
+	if next, ok := f.next[token]; ok {
+		output.NextToken = awsSdk.String(next)
+	}
+	return output, nil
+}
+
+func instance(state ec2Types.InstanceStateName, instanceType ec2Types.InstanceType, lifecycle ec2Types.InstanceLifecycleType) ec2Types.Instance {
+	return ec2Types.Instance{
+		State:             &ec2Types.InstanceState{Name: state},
+		InstanceType:      instanceType,
+		InstanceLifecycle: lifecycle,
+	}
+}
+
+func TestCountInstances(t *testing.T) {
+	client := &fakeInstances{
+		pages: map[string][]ec2Types.Instance{
+			"": {
+				instance(ec2Types.InstanceStateNameRunning, ec2Types.InstanceTypeM5Large, ""),
+				instance(ec2Types.InstanceStateNameRunning, ec2Types.InstanceTypeM5Large, ec2Types.InstanceLifecycleTypeSpot),
+				instance(ec2Types.InstanceStateNameTerminated, ec2Types.InstanceTypeM5Large, ""),
+			},
+			"page-2": {
+				instance(ec2Types.InstanceStateNameStopped, ec2Types.InstanceTypeT3Micro, ""),
+				instance(ec2Types.InstanceStateNameTerminated, ec2Types.InstanceTypeT3Micro, ec2Types.InstanceLifecycleTypeSpot),
+			},
+		},
+		next: map[string]string{"": "page-2"},
+	}
+
+	got, err := countInstances(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countInstances() error = %v", err)
+	}
+	if got.total != 3 {
+		t.Errorf("total = %d, want 3 (terminated excluded)", got.total)
+	}
+	assertBreakdown(t, "byState", got.byState, map[string]int{"running": 2, "stopped": 1})
+	assertBreakdown(t, "bySKU", got.bySKU, map[string]int{"m5.large": 2, "t3.micro": 1})
+	assertBreakdown(t, "byLifecycle", got.byLifecycle, map[string]int{"on-demand": 2, "spot": 1})
+}
+
+func TestServiceCollectorMergesBreakdowns(t *testing.T) {
+	collector := &ServiceCollector{
+		sem: semaphore.NewWeighted(2),
+		counters: map[models.ResourceType]serviceCounter{
+			"ec2:instance": func(_ context.Context, cfg awsSdk.Config) (regionCount, error) {
+				if cfg.Region == "us-east-1" {
+					return regionCount{total: 3, byState: map[string]int{"running": 2, "stopped": 1}}, nil
+				}
+				return regionCount{total: 1, byState: map[string]int{"running": 1}}, nil
+			},
+		},
+	}
+
+	def := models.ResourceDefinition{Type: "ec2:instance", DisplayName: "EC2 Instances"}
+	got, err := collector.CountResourceType(context.Background(), def, []string{"us-east-1", "eu-west-1"}, awsSdk.Config{})
+	if err != nil {
+		t.Fatalf("CountResourceType() error = %v", err)
+	}
+	if got.TotalResources != 4 {
+		t.Errorf("TotalResources = %d, want 4", got.TotalResources)
+	}
+	assertBreakdown(t, "ByState", got.ByState, map[string]int{"running": 3, "stopped": 1})
+	if got.BySKU != nil {
+		t.Errorf("BySKU = %v, want nil when no region reports it", got.BySKU)
+	}
+}
+
+func assertBreakdown(t *testing.T, name string, got, want map[string]int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+		return
+	}
+	for key, count := range want {
+		if got[key] != count {
+			t.Errorf("%s[%s] = %d, want %d", name, key, got[key], count)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package aws
+
+import (
+	"context"
+	"errors"
+
+	awsMiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+)
+
+// metricsProvider is the provider label of AWS metrics
+const metricsProvider = "aws"
+
+// attemptsKey holds the attempt counter of one operation in the middleware
+// stack values
+type attemptsKey struct{}
+
+// withUserAgent adds the agent's product and version to the User-Agent of
+// every request, so calls can be told apart in CloudTrail
+func withUserAgent(stack *middleware.Stack) error {
+	return awsMiddleware.AddUserAgentKeyValue(version.Product, version.Get())(stack)
+}
+
+// withAPIMetrics returns middleware that records every operation, retry and
+// throttled attempt, and the retry count on the active trace span, and counts
+// operations in calls and in the resource type's stats. The counter is set up once per operation and counted
+// after the retry middleware, once per attempt.
+func withAPIMetrics(calls *metrics.APICallCounter) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		start := middleware.InitializeMiddlewareFunc("APIMetricsStart", func(
+			ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+		) (middleware.InitializeOutput, middleware.Metadata, error) {
+			return next.HandleInitialize(middleware.WithStackValue(ctx, attemptsKey{}, new(int)), in)
+		})
+
+		attempt := middleware.FinalizeMiddlewareFunc("APIMetricsAttempt", func(
+			ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+		) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			service := awsMiddleware.GetServiceID(ctx)
+			if attempts, ok := middleware.GetStackValue(ctx, attemptsKey{}).(*int); ok {
+				if *attempts++; *attempts == 1 {
+					metrics.APICall(metricsProvider, service)
+					calls.Add(service, awsMiddleware.GetOperationName(ctx))
+					metrics.TypeStatsFrom(ctx).AddPage()
+				} else {
+					metrics.APIRetry(metricsProvider, service)
+					metrics.TypeStatsFrom(ctx).AddRetry()
+					tracing.SetRetryCount(ctx, *attempts-1)
+				}
+			}
+
+			out, metadata, err := next.HandleFinalize(ctx, in)
+			if isThrottle(err) {
+				metrics.APIThrottled(metricsProvider, service)
+			}
+			return out, metadata, err
+		})
+
+		if err := stack.Initialize.Add(start, middleware.Before); err != nil {
+			return err
+		}
+		return stack.Finalize.Add(attempt, middleware.After)
+	}
+}
+
+// isThrottle reports whether err is one of the SDK's throttling error codes
+func isThrottle(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	_, ok := retry.DefaultThrottleErrorCodes[apiErr.ErrorCode()]
+	return ok
+}
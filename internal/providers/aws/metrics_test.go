@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+)
+
+// countingRecorder counts API metrics per kind
+type countingRecorder struct {
+	metrics.Nop
+
+	mu        sync.Mutex
+	calls     int
+	retries   int
+	throttles int
+}
+
+func (r *countingRecorder) APICall(_, _ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+}
+
+func (r *countingRecorder) APIRetry(_, _ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries++
+}
+
+func (r *countingRecorder) APIThrottled(_, _ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.throttles++
+}
+
+// scriptedHTTPClient returns the given responses in order and keeps the
+// requests
+type scriptedHTTPClient struct {
+	responses []*http.Response
+	requests  []*http.Request
+}
+
+func (c *scriptedHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.requests = append(c.requests, req)
+	resp := c.responses[0]
+	c.responses = c.responses[1:]
+	return resp, nil
+}
+
+func xmlResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestAPIMetrics(t *testing.T) {
+	recorder := &countingRecorder{}
+	metrics.SetRecorder(recorder)
+	defer metrics.SetRecorder(nil)
+
+	calls := metrics.NewAPICallCounter()
+	httpClient := &scriptedHTTPClient{responses: []*http.Response{
+		xmlResponse(http.StatusBadRequest, `<ErrorResponse><Error><Type>Sender</Type>`+
+			`<Code>Throttling</Code><Message>Rate exceeded</Message></Error></ErrorResponse>`),
+		xmlResponse(http.StatusOK, `<GetCallerIdentityResponse><GetCallerIdentityResult>`+
+			`<Account>123456789012</Account><Arn>arn:aws:iam::123456789012:user/test</Arn>`+
+			`<UserId>AIDA</UserId></GetCallerIdentityResult></GetCallerIdentityResponse>`),
+	}}
+	cfg := awsSdk.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		HTTPClient:  httpClient,
+		Retryer: func() awsSdk.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				o.Backoff = retry.BackoffDelayerFunc(func(int, error) (time.Duration, error) { return 0, nil })
+			})
+		},
+		APIOptions: []func(*middleware.Stack) error{withUserAgent, withAPIMetrics(calls)},
+	}
+
+	ctx, stats := metrics.StartTypeStats(context.Background())
+	if _, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+		t.Fatalf("GetCallerIdentity() error = %v", err)
+	}
+
+	if recorder.calls != 1 || recorder.retries != 1 || recorder.throttles != 1 {
+		t.Errorf("calls=%d retries=%d throttles=%d, want 1 each",
+			recorder.calls, recorder.retries, recorder.throttles)
+	}
+
+	// Retries are not counted as calls
+	want := []models.APICallCount{{Service: "STS", Operation: "GetCallerIdentity", Count: 1}}
+	if got := calls.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %+v, want %+v", got, want)
+	}
+	if got := stats.Stats(); got.Pages != 1 || got.Retries != 1 {
+		t.Errorf("type stats = %+v, want 1 page and 1 retry", got)
+	}
+
+	for _, req := range httpClient.requests {
+		if ua := req.Header.Get("User-Agent"); !strings.Contains(ua, version.UserAgent()) {
+			t.Errorf("User-Agent = %q, want it to contain %q", ua, version.UserAgent())
+		}
+	}
+}
+
+func TestCountResourcesRecordsTypeMetrics(t *testing.T) {
+	prom := metrics.NewPrometheus()
+	metrics.SetRecorder(prom)
+	defer metrics.SetRecorder(nil)
+
+	if _, err := newTestProvider(t).CountResources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := prom.Gatherer().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, family := range families {
+		found[family.GetName()] = true
+	}
+	for _, want := range []string{
+		"secrails_sizing_resource_types_scanned_total",
+		"secrails_sizing_resource_type_duration_seconds",
+	} {
+		if !found[want] {
+			t.Errorf("metric family %s not recorded by a fake scan", want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+)
+
+// addressAPI is the subset of the EC2 client used to count Elastic IPs
+type addressAPI interface {
+	DescribeAddresses(
+		ctx context.Context,
+		params *ec2.DescribeAddressesInput,
+		optFns ...func(*ec2.Options),
+	) (*ec2.DescribeAddressesOutput, error)
+}
+
+// Elastic IP states reported in ByState
+const (
+	addressAssociated   = "associated"
+	addressUnassociated = "unassociated"
+)
+
+// countElasticIPs counts Elastic IPs by whether they are associated with an
+// instance or network interface. Addresses are rarely tagged, so the tagging
+// API would miss most of them. DescribeAddresses is not paginated.
+func countElasticIPs(ctx context.Context, client addressAPI) (regionCount, error) {
+	output, err := client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return regionCount{}, fmt.Errorf("failed to describe addresses: %w", err)
+	}
+
+	result := regionCount{byState: make(map[string]int)}
+	for _, address := range output.Addresses {
+		result.total++
+		if address.AssociationId != nil {
+			result.byState[addressAssociated]++
+		} else {
+			result.byState[addressUnassociated]++
+		}
+	}
+	return result, nil
+}
+
+// Load balancer kinds reported in ByKind, besides the ELBv2 types
+// application, network and gateway
+const loadBalancerClassic = "classic"
+
+// Load balancer exposures reported in ByExposure
+const (
+	exposurePublic   = "public"
+	exposureInternal = "internal"
+)
+
+// loadBalancerExposure maps an ELB scheme to an exposure. Gateway load
+// balancers have no scheme and are internal.
+func loadBalancerExposure(scheme string) string {
+	if scheme == "internet-facing" {
+		return exposurePublic
+	}
+	return exposureInternal
+}
+
+// countLoadBalancers counts ELBv2 (application, network and gateway) and
+// classic load balancers by kind and by whether they are internet-facing
+func countLoadBalancers(
+	ctx context.Context,
+	client elasticloadbalancingv2.DescribeLoadBalancersAPIClient,
+	classicClient elasticloadbalancing.DescribeLoadBalancersAPIClient,
+) (regionCount, error) {
+	result := regionCount{
+		byKind:     make(map[string]int),
+		byExposure: make(map[string]int),
+	}
+
+	paginator := elasticloadbalancingv2.NewDescribeLoadBalancersPaginator(client, &elasticloadbalancingv2.DescribeLoadBalancersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe load balancers: %w", err)
+		}
+		for _, lb := range page.LoadBalancers {
+			result.total++
+			result.byKind[string(lb.Type)]++
+			result.byExposure[loadBalancerExposure(string(lb.Scheme))]++
+		}
+	}
+
+	classicPaginator := elasticloadbalancing.NewDescribeLoadBalancersPaginator(classicClient, &elasticloadbalancing.DescribeLoadBalancersInput{})
+	for classicPaginator.HasMorePages() {
+		page, err := classicPaginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe classic load balancers: %w", err)
+		}
+		for _, lb := range page.LoadBalancerDescriptions {
+			result.total++
+			result.byKind[loadBalancerClassic]++
+			result.byExposure[loadBalancerExposure(awsSdk.ToString(lb.Scheme))]++
+		}
+	}
+
+	return result, nil
+}
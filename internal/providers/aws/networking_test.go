@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	elbTypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2Types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+type fakeAddresses struct {
+	addresses []ec2Types.Address
+	err       error
+}
+
+func (f *fakeAddresses) DescribeAddresses(
+	_ context.Context,
+	_ *ec2.DescribeAddressesInput,
+	_ ...func(*ec2.Options),
+) (*ec2.DescribeAddressesOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ec2.DescribeAddressesOutput{Addresses: f.addresses}, nil
+}
+
+func TestCountElasticIPs(t *testing.T) {
+	client := &fakeAddresses{addresses: []ec2Types.Address{
+		{AllocationId: awsSdk.String("eipalloc-1"), AssociationId: awsSdk.String("eipassoc-1")},
+		{AllocationId: awsSdk.String("eipalloc-2")},
+		{AllocationId: awsSdk.String("eipalloc-3")},
+	}}
+
+	got, err := countElasticIPs(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.total != 3 {
+		t.Errorf("total = %d, want 3", got.total)
+	}
+	if got.byState[addressAssociated] != 1 || got.byState[addressUnassociated] != 2 {
+		t.Errorf("byState = %v, want 1 associated and 2 unassociated", got.byState)
+	}
+}
+
+func TestCountElasticIPsError(t *testing.T) {
+	client := &fakeAddresses{err: errors.New("boom")}
+	if _, err := countElasticIPs(context.Background(), client); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+type fakeLoadBalancers struct {
+	loadBalancers []elbv2Types.LoadBalancer
+}
+
+func (f *fakeLoadBalancers) DescribeLoadBalancers(
+	_ context.Context,
+	_ *elasticloadbalancingv2.DescribeLoadBalancersInput,
+	_ ...func(*elasticloadbalancingv2.Options),
+) (*elasticloadbalancingv2.DescribeLoadBalancersOutput, error) {
+	return &elasticloadbalancingv2.DescribeLoadBalancersOutput{LoadBalancers: f.loadBalancers}, nil
+}
+
+type fakeClassicLoadBalancers struct {
+	loadBalancers []elbTypes.LoadBalancerDescription
+	err           error
+}
+
+func (f *fakeClassicLoadBalancers) DescribeLoadBalancers(
+	_ context.Context,
+	_ *elasticloadbalancing.DescribeLoadBalancersInput,
+	_ ...func(*elasticloadbalancing.Options),
+) (*elasticloadbalancing.DescribeLoadBalancersOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &elasticloadbalancing.DescribeLoadBalancersOutput{LoadBalancerDescriptions: f.loadBalancers}, nil
+}
+
+func TestCountLoadBalancers(t *testing.T) {
+	client := &fakeLoadBalancers{loadBalancers: []elbv2Types.LoadBalancer{
+		{Type: elbv2Types.LoadBalancerTypeEnumApplication, Scheme: elbv2Types.LoadBalancerSchemeEnumInternetFacing},
+		{Type: elbv2Types.LoadBalancerTypeEnumApplication, Scheme: elbv2Types.LoadBalancerSchemeEnumInternal},
+		{Type: elbv2Types.LoadBalancerTypeEnumNetwork, Scheme: elbv2Types.LoadBalancerSchemeEnumInternetFacing},
+		{Type: elbv2Types.LoadBalancerTypeEnumGateway},
+	}}
+	classic := &fakeClassicLoadBalancers{loadBalancers: []elbTypes.LoadBalancerDescription{
+		{Scheme: awsSdk.String("internet-facing")},
+	}}
+
+	got, err := countLoadBalancers(context.Background(), client, classic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.total != 5 {
+		t.Errorf("total = %d, want 5", got.total)
+	}
+	assertBreakdown(t, "byKind", got.byKind, map[string]int{"application": 2, "network": 1, "gateway": 1, "classic": 1})
+	assertBreakdown(t, "byExposure", got.byExposure, map[string]int{"public": 3, "internal": 2})
+}
+
+func TestCountLoadBalancersClassicError(t *testing.T) {
+	classic := &fakeClassicLoadBalancers{err: errors.New("boom")}
+	if _, err := countLoadBalancers(context.Background(), &fakeLoadBalancers{}, classic); err == nil {
+		t.Fatal("expected an error")
+	}
+}
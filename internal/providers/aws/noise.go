@@ -0,0 +1,81 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// noiseLister lists the IDs of the cloud-managed resources of one type in
+// the region of cfg, e.g. the default security group of every VPC
+type noiseLister func(ctx context.Context, cfg awsSdk.Config) (map[string]bool, error)
+
+// noiseListers holds the lister for every tagging API type with resources
+// that AWS creates on its own, keyed by resource type
+var noiseListers = map[models.ResourceType]noiseLister{
+	"ec2:security-group": func(ctx context.Context, cfg awsSdk.Config) (map[string]bool, error) {
+		return listDefaultSecurityGroups(ctx, ec2.NewFromConfig(cfg))
+	},
+	"ec2:vpc": func(ctx context.Context, cfg awsSdk.Config) (map[string]bool, error) {
+		return listDefaultVPCs(ctx, ec2.NewFromConfig(cfg))
+	},
+}
+
+// noiseFilter returns the IDs of the cloud-managed resources of a type in a
+// region, or nil when the type has none
+type noiseFilter func(ctx context.Context, resourceType models.ResourceType, region string) (map[string]bool, error)
+
+// newNoiseFilter returns a noise filter calling the listers with cfg set to
+// each region
+func newNoiseFilter(cfg func() awsSdk.Config) noiseFilter {
+	return func(ctx context.Context, resourceType models.ResourceType, region string) (map[string]bool, error) {
+		lister, ok := noiseListers[resourceType]
+		if !ok {
+			return nil, nil
+		}
+		regionalConfig := cfg()
+		regionalConfig.Region = region
+		return lister(ctx, regionalConfig)
+	}
+}
+
+// listDefaultSecurityGroups lists the IDs of the "default" security group
+// every VPC is created with
+func listDefaultSecurityGroups(ctx context.Context, client ec2.DescribeSecurityGroupsAPIClient) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(client, &ec2.DescribeSecurityGroupsInput{
+		Filters: []ec2Types.Filter{{Name: awsSdk.String("group-name"), Values: []string{"default"}}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe default security groups: %w", err)
+		}
+		for _, group := range page.SecurityGroups {
+			ids[awsSdk.ToString(group.GroupId)] = true
+		}
+	}
+	return ids, nil
+}
+
+// listDefaultVPCs lists the IDs of the default VPC AWS creates in each region
+func listDefaultVPCs(ctx context.Context, client ec2.DescribeVpcsAPIClient) (map[string]bool, error) {
+	ids := make(map[string]bool)
+	paginator := ec2.NewDescribeVpcsPaginator(client, &ec2.DescribeVpcsInput{
+		Filters: []ec2Types.Filter{{Name: awsSdk.String("is-default"), Values: []string{"true"}}},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe default VPCs: %w", err)
+		}
+		for _, vpc := range page.Vpcs {
+			ids[awsSdk.ToString(vpc.VpcId)] = true
+		}
+	}
+	return ids, nil
+}
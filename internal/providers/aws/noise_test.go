@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// fakeDefaults serves default security groups and VPCs, recording the
+// filters they were asked for
+type fakeDefaults struct {
+	filters []ec2Types.Filter
+}
+
+func (f *fakeDefaults) DescribeSecurityGroups(
+	_ context.Context,
+	params *ec2.DescribeSecurityGroupsInput,
+	_ ...func(*ec2.Options),
+) (*ec2.DescribeSecurityGroupsOutput, error) {
+	f.filters = params.Filters
+	return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []ec2Types.SecurityGroup{
+		{GroupId: awsSdk.String("sg-1")}, {GroupId: awsSdk.String("sg-2")},
+	}}, nil
+}
+
+func (f *fakeDefaults) DescribeVpcs(
+	_ context.Context,
+	params *ec2.DescribeVpcsInput,
+	_ ...func(*ec2.Options),
+) (*ec2.DescribeVpcsOutput, error) {
+	f.filters = params.Filters
+	return &ec2.DescribeVpcsOutput{Vpcs: []ec2Types.Vpc{{VpcId: awsSdk.String("vpc-1")}}}, nil
+}
+
+func assertFilter(t *testing.T, filters []ec2Types.Filter, name, value string) {
+	t.Helper()
+	if len(filters) != 1 || awsSdk.ToString(filters[0].Name) != name ||
+		len(filters[0].Values) != 1 || filters[0].Values[0] != value {
+		t.Errorf("Filters = %+v, want %s=%s", filters, name, value)
+	}
+}
+
+func TestListDefaultSecurityGroups(t *testing.T) {
+	client := &fakeDefaults{}
+	ids, err := listDefaultSecurityGroups(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || !ids["sg-1"] || !ids["sg-2"] {
+		t.Errorf("ids = %v, want sg-1 and sg-2", ids)
+	}
+	assertFilter(t, client.filters, "group-name", "default")
+}
+
+func TestListDefaultVPCs(t *testing.T) {
+	client := &fakeDefaults{}
+	ids, err := listDefaultVPCs(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || !ids["vpc-1"] {
+		t.Errorf("ids = %v, want vpc-1", ids)
+	}
+	assertFilter(t, client.filters, "is-default", "true")
+}
+
+func TestNoiseFilterSkipsTypesWithoutLister(t *testing.T) {
+	filter := newNoiseFilter(func() awsSdk.Config { return awsSdk.Config{} })
+	ids, err := filter(context.Background(), "s3:bucket", "us-east-1")
+	if err != nil || ids != nil {
+		t.Errorf("filter() = %v, %v, want nil, nil", ids, err)
+	}
+}
@@ -0,0 +1,175 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// Page sizes of the operations APIs: the largest each accepts, as log
+// groups and managed instances can run into the tens of thousands per region
+const (
+	logGroupPageSize     = 50
+	eventBridgePageSize  = 100
+	instanceInfoPageSize = 50
+)
+
+// serviceLogGroupPrefix starts the names of the log groups AWS services
+// create for their own logs
+const serviceLogGroupPrefix = "/aws/"
+
+// defaultEventBusName is the event bus every region comes with
+const defaultEventBusName = "default"
+
+// countLogGroups counts CloudWatch Logs log groups. The groups AWS
+// services create for their own logs, under /aws/ (e.g. /aws/lambda/<name>),
+// are noise.
+func countLogGroups(ctx context.Context, client cloudwatchlogs.DescribeLogGroupsAPIClient) (regionCount, error) {
+	result := regionCount{}
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(client, &cloudwatchlogs.DescribeLogGroupsInput{
+		Limit: awsSdk.Int32(logGroupPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe log groups: %w", err)
+		}
+		for _, group := range page.LogGroups {
+			result.total++
+			if strings.HasPrefix(awsSdk.ToString(group.LogGroupName), serviceLogGroupPrefix) {
+				result.noise++
+			}
+		}
+	}
+	return result, nil
+}
+
+// eventBridgeAPI is the subset of the EventBridge client used to count buses
+// and rules. EventBridge has no paginators, so pages are followed by token.
+type eventBridgeAPI interface {
+	ListEventBuses(
+		ctx context.Context,
+		params *eventbridge.ListEventBusesInput,
+		optFns ...func(*eventbridge.Options),
+	) (*eventbridge.ListEventBusesOutput, error)
+	ListRules(
+		ctx context.Context,
+		params *eventbridge.ListRulesInput,
+		optFns ...func(*eventbridge.Options),
+	) (*eventbridge.ListRulesOutput, error)
+}
+
+// listEventBuses lists the names of the event buses in the client's region
+func listEventBuses(ctx context.Context, client eventBridgeAPI) ([]string, error) {
+	var names []string
+	var token *string
+	for {
+		output, err := client.ListEventBuses(ctx, &eventbridge.ListEventBusesInput{
+			Limit:     awsSdk.Int32(eventBridgePageSize),
+			NextToken: token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list event buses: %w", err)
+		}
+		for _, bus := range output.EventBuses {
+			names = append(names, awsSdk.ToString(bus.Name))
+		}
+
+		// Also stop on an empty page so a stray token cannot loop forever
+		if len(output.EventBuses) == 0 || awsSdk.ToString(output.NextToken) == "" {
+			return names, nil
+		}
+		token = output.NextToken
+	}
+}
+
+// countEventBuses counts EventBridge event buses. The default bus exists in
+// every region and is noise.
+func countEventBuses(ctx context.Context, client eventBridgeAPI) (regionCount, error) {
+	names, err := listEventBuses(ctx, client)
+	if err != nil {
+		return regionCount{}, err
+	}
+
+	result := regionCount{total: len(names)}
+	for _, name := range names {
+		if name == defaultEventBusName {
+			result.noise++
+		}
+	}
+	return result, nil
+}
+
+// countEventRules counts the EventBridge rules of every event bus. Managed
+// rules, which AWS services create on the account's behalf, are noise.
+func countEventRules(ctx context.Context, client eventBridgeAPI) (regionCount, error) {
+	buses, err := listEventBuses(ctx, client)
+	if err != nil {
+		return regionCount{}, err
+	}
+
+	result := regionCount{}
+	for _, bus := range buses {
+		var token *string
+		for {
+			output, err := client.ListRules(ctx, &eventbridge.ListRulesInput{
+				EventBusName: awsSdk.String(bus),
+				Limit:        awsSdk.Int32(eventBridgePageSize),
+				NextToken:    token,
+			})
+			if err != nil {
+				return regionCount{}, fmt.Errorf("failed to list the rules of event bus %s: %w", bus, err)
+			}
+			for _, rule := range output.Rules {
+				result.total++
+				if awsSdk.ToString(rule.ManagedBy) != "" {
+					result.noise++
+				}
+			}
+
+			if len(output.Rules) == 0 || awsSdk.ToString(output.NextToken) == "" {
+				break
+			}
+			token = output.NextToken
+		}
+	}
+	return result, nil
+}
+
+// countManagedInstances counts the hybrid-activated servers registered with
+// Systems Manager, by ping status and platform. The EC2 instances it
+// manages are left out, as they are counted as EC2 instances.
+func countManagedInstances(ctx context.Context, client ssm.DescribeInstanceInformationAPIClient) (regionCount, error) {
+	result := regionCount{
+		byState: make(map[string]int),
+		byKind:  make(map[string]int),
+	}
+
+	paginator := ssm.NewDescribeInstanceInformationPaginator(client, &ssm.DescribeInstanceInformationInput{
+		Filters: []ssmTypes.InstanceInformationStringFilter{{
+			Key:    awsSdk.String("ResourceType"),
+			Values: []string{string(ssmTypes.ResourceTypeManagedInstance)},
+		}},
+		MaxResults: awsSdk.Int32(instanceInfoPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe managed instances: %w", err)
+		}
+		for _, instance := range page.InstanceInformationList {
+			result.total++
+			result.byState[string(instance.PingStatus)]++
+			if instance.PlatformType != "" {
+				result.byKind[string(instance.PlatformType)]++
+			}
+		}
+	}
+	return result, nil
+}
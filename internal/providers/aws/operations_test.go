@@ -0,0 +1,164 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logsTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+type fakeLogGroupsAPI struct {
+	pages [][]string
+	limit int32
+}
+
+func (f *fakeLogGroupsAPI) DescribeLogGroups(
+	_ context.Context,
+	params *cloudwatchlogs.DescribeLogGroupsInput,
+	_ ...func(*cloudwatchlogs.Options),
+) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	f.limit = awsSdk.ToInt32(params.Limit)
+	page := 0
+	if params.NextToken != nil {
+		page = 1
+	}
+	output := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	for _, name := range f.pages[page] {
+		output.LogGroups = append(output.LogGroups, logsTypes.LogGroup{LogGroupName: awsSdk.String(name)})
+	}
+	if page == 0 {
+		output.NextToken = awsSdk.String("page-2")
+	}
+	return output, nil
+}
+
+func TestCountLogGroups(t *testing.T) {
+	client := &fakeLogGroupsAPI{pages: [][]string{
+		{"/aws/lambda/checkout", "app/orders", "/aws/rds/instance/db/error"},
+		{"/ecs/payments", "aws-waf-logs-edge"},
+	}}
+
+	got, err := countLogGroups(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countLogGroups() error = %v", err)
+	}
+	if got.total != 5 || got.noise != 2 {
+		t.Errorf("countLogGroups() = %d with %d noise, want 5 with 2", got.total, got.noise)
+	}
+	if client.limit != logGroupPageSize {
+		t.Errorf("page size = %d, want %d", client.limit, logGroupPageSize)
+	}
+}
+
+type fakeEventBridgeAPI struct {
+	buses []string
+	// rules are the pages of rules of each bus, their ManagedBy
+	rules map[string][][]string
+}
+
+func (f *fakeEventBridgeAPI) ListEventBuses(
+	_ context.Context,
+	_ *eventbridge.ListEventBusesInput,
+	_ ...func(*eventbridge.Options),
+) (*eventbridge.ListEventBusesOutput, error) {
+	output := &eventbridge.ListEventBusesOutput{}
+	for _, name := range f.buses {
+		output.EventBuses = append(output.EventBuses, eventTypes.EventBus{Name: awsSdk.String(name)})
+	}
+	return output, nil
+}
+
+func (f *fakeEventBridgeAPI) ListRules(
+	_ context.Context,
+	params *eventbridge.ListRulesInput,
+	_ ...func(*eventbridge.Options),
+) (*eventbridge.ListRulesOutput, error) {
+	pages := f.rules[awsSdk.ToString(params.EventBusName)]
+	page := 0
+	if params.NextToken != nil {
+		page = 1
+	}
+	output := &eventbridge.ListRulesOutput{}
+	if page >= len(pages) {
+		return output, nil
+	}
+	for _, managedBy := range pages[page] {
+		rule := eventTypes.Rule{Name: awsSdk.String("rule")}
+		if managedBy != "" {
+			rule.ManagedBy = awsSdk.String(managedBy)
+		}
+		output.Rules = append(output.Rules, rule)
+	}
+	if page+1 < len(pages) {
+		output.NextToken = awsSdk.String("page-2")
+	}
+	return output, nil
+}
+
+func TestCountEventBridge(t *testing.T) {
+	client := &fakeEventBridgeAPI{
+		buses: []string{"default", "orders"},
+		rules: map[string][][]string{
+			"default": {{"", "backup.amazonaws.com"}, {""}},
+			"orders":  {{"", ""}},
+		},
+	}
+
+	buses, err := countEventBuses(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countEventBuses() error = %v", err)
+	}
+	if buses.total != 2 || buses.noise != 1 {
+		t.Errorf("countEventBuses() = %d with %d noise, want 2 with 1", buses.total, buses.noise)
+	}
+
+	rules, err := countEventRules(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countEventRules() error = %v", err)
+	}
+	if rules.total != 5 || rules.noise != 1 {
+		t.Errorf("countEventRules() = %d with %d noise, want 5 with 1", rules.total, rules.noise)
+	}
+}
+
+type fakeInstanceInformationAPI struct {
+	instances []ssmTypes.InstanceInformation
+}
+
+func (f *fakeInstanceInformationAPI) DescribeInstanceInformation(
+	_ context.Context,
+	params *ssm.DescribeInstanceInformationInput,
+	_ ...func(*ssm.Options),
+) (*ssm.DescribeInstanceInformationOutput, error) {
+	output := &ssm.DescribeInstanceInformationOutput{}
+	for _, instance := range f.instances {
+		for _, filter := range params.Filters {
+			if awsSdk.ToString(filter.Key) == "ResourceType" && filter.Values[0] == string(instance.ResourceType) {
+				output.InstanceInformationList = append(output.InstanceInformationList, instance)
+			}
+		}
+	}
+	return output, nil
+}
+
+func TestCountManagedInstances(t *testing.T) {
+	client := &fakeInstanceInformationAPI{instances: []ssmTypes.InstanceInformation{
+		{ResourceType: ssmTypes.ResourceTypeManagedInstance, PingStatus: ssmTypes.PingStatusOnline, PlatformType: ssmTypes.PlatformTypeLinux},
+		{ResourceType: ssmTypes.ResourceTypeManagedInstance, PingStatus: ssmTypes.PingStatusConnectionLost, PlatformType: ssmTypes.PlatformTypeWindows},
+		{ResourceType: ssmTypes.ResourceTypeEc2Instance, PingStatus: ssmTypes.PingStatusOnline, PlatformType: ssmTypes.PlatformTypeLinux},
+	}}
+
+	hybrid, err := countManagedInstances(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countManagedInstances() error = %v", err)
+	}
+	if hybrid.total != 2 || hybrid.byState["ConnectionLost"] != 1 || hybrid.byKind["Windows"] != 1 {
+		t.Errorf("countManagedInstances() = %+v, want the 2 hybrid instances without the EC2 instance", hybrid)
+	}
+}
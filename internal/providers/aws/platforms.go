@@ -0,0 +1,194 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/amplify"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/batch"
+	"github.com/aws/aws-sdk-go-v2/service/elasticbeanstalk"
+	beanstalkTypes "github.com/aws/aws-sdk-go-v2/service/elasticbeanstalk/types"
+)
+
+// Page sizes of the application platform APIs: the largest each accepts
+const (
+	batchPageSize     = 100
+	appRunnerPageSize = 20
+	beanstalkPageSize = 1000
+	amplifyPageSize   = 100
+)
+
+// unmanagedComputeEnvironment is the kind of Batch compute environments
+// whose instances the account manages itself, which have no compute
+// resources
+const unmanagedComputeEnvironment = "UNMANAGED"
+
+// regionUnsupported reports whether err is the failure to resolve the
+// endpoint of a service that is not offered in the region, e.g. App Runner
+// or Amplify, which have no endpoint there at all. The region then has none
+// of its resources rather than failing.
+func regionUnsupported(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// countComputeEnvironments counts Batch compute environments by state
+// (ENABLED or DISABLED) and kind: the compute resource type (EC2, SPOT,
+// FARGATE or FARGATE_SPOT) of managed environments, UNMANAGED otherwise
+func countComputeEnvironments(ctx context.Context, client batch.DescribeComputeEnvironmentsAPIClient) (regionCount, error) {
+	result := regionCount{
+		byState: make(map[string]int),
+		byKind:  make(map[string]int),
+	}
+
+	paginator := batch.NewDescribeComputeEnvironmentsPaginator(client, &batch.DescribeComputeEnvironmentsInput{
+		MaxResults: awsSdk.Int32(batchPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe Batch compute environments: %w", err)
+		}
+		for _, environment := range page.ComputeEnvironments {
+			result.total++
+			if environment.State != "" {
+				result.byState[string(environment.State)]++
+			}
+			kind := unmanagedComputeEnvironment
+			if environment.ComputeResources != nil && environment.ComputeResources.Type != "" {
+				kind = string(environment.ComputeResources.Type)
+			}
+			result.byKind[kind]++
+		}
+	}
+	return result, nil
+}
+
+// countJobQueues counts Batch job queues by state (ENABLED or DISABLED)
+func countJobQueues(ctx context.Context, client batch.DescribeJobQueuesAPIClient) (regionCount, error) {
+	result := regionCount{byState: make(map[string]int)}
+
+	paginator := batch.NewDescribeJobQueuesPaginator(client, &batch.DescribeJobQueuesInput{
+		MaxResults: awsSdk.Int32(batchPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe Batch job queues: %w", err)
+		}
+		for _, queue := range page.JobQueues {
+			result.total++
+			if queue.State != "" {
+				result.byState[string(queue.State)]++
+			}
+		}
+	}
+	return result, nil
+}
+
+// countAppRunnerServices counts App Runner services by status. Regions
+// without App Runner have none.
+func countAppRunnerServices(ctx context.Context, client apprunner.ListServicesAPIClient) (regionCount, error) {
+	result := regionCount{byState: make(map[string]int)}
+
+	paginator := apprunner.NewListServicesPaginator(client, &apprunner.ListServicesInput{
+		MaxResults: awsSdk.Int32(appRunnerPageSize),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			if regionUnsupported(err) {
+				return regionCount{}, nil
+			}
+			return regionCount{}, fmt.Errorf("failed to list App Runner services: %w", err)
+		}
+		for _, service := range page.ServiceSummaryList {
+			result.total++
+			if service.Status != "" {
+				result.byState[string(service.Status)]++
+			}
+		}
+	}
+	return result, nil
+}
+
+// beanstalkAPI is the subset of the Elastic Beanstalk client used to count
+// applications and environments. Elastic Beanstalk has no paginators, so
+// pages are followed by token.
+type beanstalkAPI interface {
+	DescribeApplications(
+		ctx context.Context,
+		params *elasticbeanstalk.DescribeApplicationsInput,
+		optFns ...func(*elasticbeanstalk.Options),
+	) (*elasticbeanstalk.DescribeApplicationsOutput, error)
+	DescribeEnvironments(
+		ctx context.Context,
+		params *elasticbeanstalk.DescribeEnvironmentsInput,
+		optFns ...func(*elasticbeanstalk.Options),
+	) (*elasticbeanstalk.DescribeEnvironmentsOutput, error)
+}
+
+// countBeanstalkApplications counts Elastic Beanstalk applications, which
+// DescribeApplications returns in a single response
+func countBeanstalkApplications(ctx context.Context, client beanstalkAPI) (int, error) {
+	output, err := client.DescribeApplications(ctx, &elasticbeanstalk.DescribeApplicationsInput{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to describe Elastic Beanstalk applications: %w", err)
+	}
+	return len(output.Applications), nil
+}
+
+// countBeanstalkEnvironments counts the Elastic Beanstalk environments that
+// are not terminated, by status. Terminated environments stay visible for an
+// hour and are left out.
+func countBeanstalkEnvironments(ctx context.Context, client beanstalkAPI) (regionCount, error) {
+	result := regionCount{byState: make(map[string]int)}
+
+	var token *string
+	for {
+		output, err := client.DescribeEnvironments(ctx, &elasticbeanstalk.DescribeEnvironmentsInput{
+			IncludeDeleted: awsSdk.Bool(false),
+			MaxRecords:     awsSdk.Int32(beanstalkPageSize),
+			NextToken:      token,
+		})
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe Elastic Beanstalk environments: %w", err)
+		}
+		for _, environment := range output.Environments {
+			if environment.Status == beanstalkTypes.EnvironmentStatusTerminated {
+				continue
+			}
+			result.total++
+			result.byState[string(environment.Status)]++
+		}
+
+		// Also stop on an empty page so a stray token cannot loop forever
+		if len(output.Environments) == 0 || awsSdk.ToString(output.NextToken) == "" {
+			return result, nil
+		}
+		token = output.NextToken
+	}
+}
+
+// countAmplifyApps counts Amplify apps. Regions without Amplify have none.
+func countAmplifyApps(ctx context.Context, client amplify.ListAppsAPIClient) (int, error) {
+	count := 0
+	paginator := amplify.NewListAppsPaginator(client, &amplify.ListAppsInput{
+		MaxResults: amplifyPageSize,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			if regionUnsupported(err) {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("failed to list Amplify apps: %w", err)
+		}
+		count += len(page.Apps)
+	}
+	return count, nil
+}
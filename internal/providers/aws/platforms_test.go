@@ -0,0 +1,248 @@
+package aws
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/amplify"
+	amplifyTypes "github.com/aws/aws-sdk-go-v2/service/amplify/types"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	appRunnerTypes "github.com/aws/aws-sdk-go-v2/service/apprunner/types"
+	"github.com/aws/aws-sdk-go-v2/service/batch"
+	batchTypes "github.com/aws/aws-sdk-go-v2/service/batch/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticbeanstalk"
+	beanstalkTypes "github.com/aws/aws-sdk-go-v2/service/elasticbeanstalk/types"
+	"github.com/aws/smithy-go"
+)
+
+type fakeBatchAPI struct {
+	environments [][]batchTypes.ComputeEnvironmentDetail
+	queues       []batchTypes.JobQueueDetail
+}
+
+func (f *fakeBatchAPI) DescribeComputeEnvironments(
+	_ context.Context,
+	params *batch.DescribeComputeEnvironmentsInput,
+	_ ...func(*batch.Options),
+) (*batch.DescribeComputeEnvironmentsOutput, error) {
+	page := 0
+	if params.NextToken != nil {
+		page = 1
+	}
+	output := &batch.DescribeComputeEnvironmentsOutput{ComputeEnvironments: f.environments[page]}
+	if page+1 < len(f.environments) {
+		output.NextToken = awsSdk.String("page-2")
+	}
+	return output, nil
+}
+
+func (f *fakeBatchAPI) DescribeJobQueues(
+	_ context.Context,
+	_ *batch.DescribeJobQueuesInput,
+	_ ...func(*batch.Options),
+) (*batch.DescribeJobQueuesOutput, error) {
+	return &batch.DescribeJobQueuesOutput{JobQueues: f.queues}, nil
+}
+
+func TestCountBatch(t *testing.T) {
+	client := &fakeBatchAPI{
+		environments: [][]batchTypes.ComputeEnvironmentDetail{
+			{
+				{State: batchTypes.CEStateEnabled, ComputeResources: &batchTypes.ComputeResource{Type: batchTypes.CRTypeFargate}},
+				{State: batchTypes.CEStateEnabled, ComputeResources: &batchTypes.ComputeResource{Type: batchTypes.CRTypeSpot}},
+			},
+			{
+				{State: batchTypes.CEStateDisabled, Type: batchTypes.CETypeUnmanaged},
+			},
+		},
+		queues: []batchTypes.JobQueueDetail{
+			{State: batchTypes.JQStateEnabled},
+			{State: batchTypes.JQStateDisabled},
+		},
+	}
+
+	environments, err := countComputeEnvironments(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countComputeEnvironments() error = %v", err)
+	}
+	if environments.total != 3 || environments.byState["DISABLED"] != 1 ||
+		environments.byKind["FARGATE"] != 1 || environments.byKind[unmanagedComputeEnvironment] != 1 {
+		t.Errorf("countComputeEnvironments() = %+v, want 3 across both pages", environments)
+	}
+
+	queues, err := countJobQueues(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countJobQueues() error = %v", err)
+	}
+	if queues.total != 2 || queues.byState["ENABLED"] != 1 {
+		t.Errorf("countJobQueues() = %+v, want 2 with 1 enabled", queues)
+	}
+}
+
+type fakeAppRunnerAPI struct {
+	services []appRunnerTypes.ServiceSummary
+	err      error
+}
+
+func (f *fakeAppRunnerAPI) ListServices(
+	_ context.Context,
+	_ *apprunner.ListServicesInput,
+	_ ...func(*apprunner.Options),
+) (*apprunner.ListServicesOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &apprunner.ListServicesOutput{ServiceSummaryList: f.services}, nil
+}
+
+func TestCountAppRunnerServices(t *testing.T) {
+	unsupported := &smithy.OperationError{
+		ServiceID: "AppRunner",
+		Err:       &net.DNSError{Err: "no such host", Name: "apprunner.me-central-1.amazonaws.com", IsNotFound: true},
+	}
+	tests := []struct {
+		name      string
+		client    *fakeAppRunnerAPI
+		want      int
+		wantState map[string]int
+		wantErr   bool
+	}{
+		{
+			name: "services by status",
+			client: &fakeAppRunnerAPI{services: []appRunnerTypes.ServiceSummary{
+				{Status: appRunnerTypes.ServiceStatusRunning},
+				{Status: appRunnerTypes.ServiceStatusRunning},
+				{Status: appRunnerTypes.ServiceStatusPaused},
+			}},
+			want:      3,
+			wantState: map[string]int{"RUNNING": 2, "PAUSED": 1},
+		},
+		{
+			name:   "region without App Runner",
+			client: &fakeAppRunnerAPI{err: unsupported},
+			want:   0,
+		},
+		{
+			name:    "access denied",
+			client:  &fakeAppRunnerAPI{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countAppRunnerServices(context.Background(), tt.client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("countAppRunnerServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.total != tt.want {
+				t.Errorf("countAppRunnerServices() = %d, want %d", got.total, tt.want)
+			}
+			for state, want := range tt.wantState {
+				if got.byState[state] != want {
+					t.Errorf("byState[%s] = %d, want %d", state, got.byState[state], want)
+				}
+			}
+		})
+	}
+}
+
+type fakeBeanstalkAPI struct {
+	applications int
+	// environments are the pages of environments, by status
+	environments   [][]beanstalkTypes.EnvironmentStatus
+	includeDeleted bool
+}
+
+func (f *fakeBeanstalkAPI) DescribeApplications(
+	_ context.Context,
+	_ *elasticbeanstalk.DescribeApplicationsInput,
+	_ ...func(*elasticbeanstalk.Options),
+) (*elasticbeanstalk.DescribeApplicationsOutput, error) {
+	return &elasticbeanstalk.DescribeApplicationsOutput{
+		Applications: make([]beanstalkTypes.ApplicationDescription, f.applications),
+	}, nil
+}
+
+func (f *fakeBeanstalkAPI) DescribeEnvironments(
+	_ context.Context,
+	params *elasticbeanstalk.DescribeEnvironmentsInput,
+	_ ...func(*elasticbeanstalk.Options),
+) (*elasticbeanstalk.DescribeEnvironmentsOutput, error) {
+	f.includeDeleted = awsSdk.ToBool(params.IncludeDeleted)
+	page := 0
+	if params.NextToken != nil {
+		page = 1
+	}
+	output := &elasticbeanstalk.DescribeEnvironmentsOutput{}
+	for _, status := range f.environments[page] {
+		output.Environments = append(output.Environments, beanstalkTypes.EnvironmentDescription{Status: status})
+	}
+	if page+1 < len(f.environments) {
+		output.NextToken = awsSdk.String("page-2")
+	}
+	return output, nil
+}
+
+func TestCountBeanstalk(t *testing.T) {
+	client := &fakeBeanstalkAPI{
+		applications: 2,
+		environments: [][]beanstalkTypes.EnvironmentStatus{
+			{beanstalkTypes.EnvironmentStatusReady, beanstalkTypes.EnvironmentStatusTerminated},
+			{beanstalkTypes.EnvironmentStatusReady, beanstalkTypes.EnvironmentStatusUpdating},
+		},
+	}
+
+	applications, err := countBeanstalkApplications(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countBeanstalkApplications() error = %v", err)
+	}
+	if applications != 2 {
+		t.Errorf("countBeanstalkApplications() = %d, want 2", applications)
+	}
+
+	environments, err := countBeanstalkEnvironments(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countBeanstalkEnvironments() error = %v", err)
+	}
+	if environments.total != 3 || environments.byState["Ready"] != 2 || environments.byState["Terminated"] != 0 {
+		t.Errorf("countBeanstalkEnvironments() = %+v, want 3 without the terminated one", environments)
+	}
+	if client.includeDeleted {
+		t.Error("DescribeEnvironments included deleted environments")
+	}
+}
+
+type fakeAmplifyAPI struct {
+	apps int
+	err  error
+}
+
+func (f *fakeAmplifyAPI) ListApps(
+	_ context.Context,
+	_ *amplify.ListAppsInput,
+	_ ...func(*amplify.Options),
+) (*amplify.ListAppsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &amplify.ListAppsOutput{Apps: make([]amplifyTypes.App, f.apps)}, nil
+}
+
+func TestCountAmplifyApps(t *testing.T) {
+	got, err := countAmplifyApps(context.Background(), &fakeAmplifyAPI{apps: 4})
+	if err != nil || got != 4 {
+		t.Errorf("countAmplifyApps() = %d, %v, want 4", got, err)
+	}
+
+	missing := &fakeAmplifyAPI{err: &net.DNSError{Err: "no such host", Name: "amplify.il-central-1.amazonaws.com", IsNotFound: true}}
+	if got, err := countAmplifyApps(context.Background(), missing); err != nil || got != 0 {
+		t.Errorf("countAmplifyApps() in a region without Amplify = %d, %v, want 0", got, err)
+	}
+
+	denied := &fakeAmplifyAPI{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}}
+	if _, err := countAmplifyApps(context.Background(), denied); err == nil {
+		t.Error("countAmplifyApps() error = nil, want access denied")
+	}
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	awsConf "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Profiles lists the profile names defined in the shared config and
+// credentials files, sorted. AWS_CONFIG_FILE and AWS_SHARED_CREDENTIALS_FILE
+// override the default locations; missing files contribute no profiles.
+func Profiles() ([]string, error) {
+	configFile := os.Getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		configFile = awsConf.DefaultSharedConfigFilename()
+	}
+	credentialsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" {
+		credentialsFile = awsConf.DefaultSharedCredentialsFilename()
+	}
+
+	seen := make(map[string]bool)
+	for _, file := range []struct {
+		path        string
+		credentials bool
+	}{{configFile, false}, {credentialsFile, true}} {
+		f, err := os.Open(file.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AWS profiles: %w", err)
+		}
+		names, err := parseProfiles(f, file.credentials)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AWS profiles from %s: %w", file.path, err)
+		}
+		for _, name := range names {
+			seen[name] = true
+		}
+	}
+
+	profiles := make([]string, 0, len(seen))
+	for name := range seen {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+// parseProfiles returns the profile sections of a shared file. The config
+// file names them "[default]" and "[profile name]" next to other section
+// kinds such as "[sso-session name]"; the credentials file uses "[name]".
+func parseProfiles(r io.Reader, credentials bool) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		section := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+		switch {
+		case len(section) == 1 && (credentials || section[0] == "default"):
+			names = append(names, section[0])
+		case len(section) == 2 && !credentials && section[0] == "profile":
+			names = append(names, section[1])
+		}
+	}
+	return names, scanner.Err()
+}
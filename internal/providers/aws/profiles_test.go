@@ -0,0 +1,53 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config")
+	credentialsFile := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(configFile, []byte(`[default]
+region = us-east-1
+
+[profile prod]
+sso_session = corp
+
+[sso-session corp]
+sso_region = eu-west-1
+
+[ profile  staging ]
+[services local]
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(credentialsFile, []byte(`[default]
+aws_access_key_id = AKIA
+[ci]
+aws_access_key_id = AKIA
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+
+	got, err := Profiles()
+	if err != nil {
+		t.Fatalf("Profiles() error = %v", err)
+	}
+	want := []string{"ci", "default", "prod", "staging"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Profiles() = %v, want %v", got, want)
+	}
+
+	// Missing files are not an error
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "missing"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "missing"))
+	if got, err := Profiles(); err != nil || len(got) != 0 {
+		t.Errorf("Profiles() with no files = %v, %v", got, err)
+	}
+}
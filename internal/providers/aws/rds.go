@@ -0,0 +1,151 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+// rdsAPI is the subset of the RDS client used to count instances and
+// clusters
+type rdsAPI interface {
+	rds.DescribeDBInstancesAPIClient
+	rds.DescribeDBClustersAPIClient
+	rds.DescribeGlobalClustersAPIClient
+}
+
+// isAurora reports whether an RDS engine name is an Aurora engine
+func isAurora(engine string) bool {
+	return strings.HasPrefix(engine, "aurora")
+}
+
+// isRDSEngine excludes Neptune and DocumentDB, which share the RDS API
+func isRDSEngine(engine string) bool {
+	return !strings.HasPrefix(engine, "neptune") && !strings.HasPrefix(engine, "docdb")
+}
+
+// countDBInstances counts DB instances in the client's region, either Aurora
+// cluster members or everything else. Read replicas are instances of their
+// own region, so each instance is counted once.
+func countDBInstances(ctx context.Context, client rds.DescribeDBInstancesAPIClient, aurora bool) (regionCount, error) {
+	result := regionCount{byEngine: make(map[string]int)}
+
+	paginator := rds.NewDescribeDBInstancesPaginator(client, &rds.DescribeDBInstancesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe DB instances: %w", err)
+		}
+		for _, instance := range page.DBInstances {
+			engine := awsSdk.ToString(instance.Engine)
+			if !isRDSEngine(engine) || isAurora(engine) != aurora {
+				continue
+			}
+			result.total++
+			result.byEngine[engine]++
+		}
+	}
+
+	return result, nil
+}
+
+// countAuroraClusters counts Aurora clusters owned by the client's region.
+// Cross-region replica clusters and secondary clusters of a global database
+// are left to the primary region so the cluster is counted once.
+func countAuroraClusters(ctx context.Context, client rdsAPI) (regionCount, error) {
+	result := regionCount{byEngine: make(map[string]int)}
+
+	var clusters []clusterInfo
+	inGlobalDatabase := false
+
+	paginator := rds.NewDescribeDBClustersPaginator(client, &rds.DescribeDBClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return regionCount{}, fmt.Errorf("failed to describe DB clusters: %w", err)
+		}
+		for _, cluster := range page.DBClusters {
+			engine := awsSdk.ToString(cluster.Engine)
+			if !isAurora(engine) || cluster.ReplicationSourceIdentifier != nil {
+				continue
+			}
+			info := clusterInfo{
+				arn:    awsSdk.ToString(cluster.DBClusterArn),
+				engine: engine,
+				global: awsSdk.ToString(cluster.GlobalClusterIdentifier) != "",
+			}
+			inGlobalDatabase = inGlobalDatabase || info.global
+			clusters = append(clusters, info)
+		}
+	}
+
+	var writers map[string]bool
+	if inGlobalDatabase {
+		var err error
+		if writers, err = globalWriters(ctx, client); err != nil {
+			return regionCount{}, err
+		}
+	}
+
+	for _, cluster := range clusters {
+		if cluster.global && !writers[cluster.arn] {
+			continue
+		}
+		result.total++
+		result.byEngine[cluster.engine]++
+	}
+
+	return result, nil
+}
+
+// clusterInfo is the part of a DB cluster needed to decide ownership
+type clusterInfo struct {
+	arn    string
+	engine string
+	global bool
+}
+
+// globalWriters returns the ARNs of the primary clusters of every global
+// database
+func globalWriters(ctx context.Context, client rds.DescribeGlobalClustersAPIClient) (map[string]bool, error) {
+	writers := make(map[string]bool)
+
+	paginator := rds.NewDescribeGlobalClustersPaginator(client, &rds.DescribeGlobalClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe global clusters: %w", err)
+		}
+		for _, global := range page.GlobalClusters {
+			for _, member := range global.GlobalClusterMembers {
+				if awsSdk.ToBool(member.IsWriter) {
+					writers[awsSdk.ToString(member.DBClusterArn)] = true
+				}
+			}
+		}
+	}
+
+	return writers, nil
+}
+
+// countNeptuneClusters counts Neptune clusters, which are listed by the RDS
+// API alongside Aurora clusters
+func countNeptuneClusters(ctx context.Context, client rds.DescribeDBClustersAPIClient) (int, error) {
+	count := 0
+	paginator := rds.NewDescribeDBClustersPaginator(client, &rds.DescribeDBClustersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to describe DB clusters: %w", err)
+		}
+		for _, cluster := range page.DBClusters {
+			if awsSdk.ToString(cluster.Engine) == "neptune" {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,157 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+type fakeRDS struct {
+	instances      []rdsTypes.DBInstance
+	clusters       []rdsTypes.DBCluster
+	globalClusters []rdsTypes.GlobalCluster
+
+	globalCalls int
+}
+
+func (f *fakeRDS) DescribeDBInstances(
+	_ context.Context,
+	_ *rds.DescribeDBInstancesInput,
+	_ ...func(*rds.Options),
+) (*rds.DescribeDBInstancesOutput, error) {
+	return &rds.DescribeDBInstancesOutput{DBInstances: f.instances}, nil
+}
+
+func (f *fakeRDS) DescribeDBClusters(
+	_ context.Context,
+	_ *rds.DescribeDBClustersInput,
+	_ ...func(*rds.Options),
+) (*rds.DescribeDBClustersOutput, error) {
+	return &rds.DescribeDBClustersOutput{DBClusters: f.clusters}, nil
+}
+
+func (f *fakeRDS) DescribeGlobalClusters(
+	_ context.Context,
+	_ *rds.DescribeGlobalClustersInput,
+	_ ...func(*rds.Options),
+) (*rds.DescribeGlobalClustersOutput, error) {
+	f.globalCalls++
+	return &rds.DescribeGlobalClustersOutput{GlobalClusters: f.globalClusters}, nil
+}
+
+func dbInstance(engine string) rdsTypes.DBInstance {
+	return rdsTypes.DBInstance{Engine: awsSdk.String(engine)}
+}
+
+func dbCluster(arn, engine, globalID, source string) rdsTypes.DBCluster {
+	cluster := rdsTypes.DBCluster{DBClusterArn: awsSdk.String(arn), Engine: awsSdk.String(engine)}
+	if globalID != "" {
+		cluster.GlobalClusterIdentifier = awsSdk.String(globalID)
+	}
+	if source != "" {
+		cluster.ReplicationSourceIdentifier = awsSdk.String(source)
+	}
+	return cluster
+}
+
+func TestCountDBInstances(t *testing.T) {
+	client := &fakeRDS{instances: []rdsTypes.DBInstance{
+		dbInstance("postgres"),
+		dbInstance("postgres"),
+		dbInstance("mysql"),
+		dbInstance("aurora-postgresql"),
+		dbInstance("aurora-postgresql"),
+		dbInstance("aurora-mysql"),
+		dbInstance("neptune"),
+		dbInstance("docdb"),
+	}}
+
+	standalone, err := countDBInstances(context.Background(), client, false)
+	if err != nil {
+		t.Fatalf("countDBInstances() error = %v", err)
+	}
+	if standalone.total != 3 {
+		t.Errorf("non-Aurora total = %d, want 3", standalone.total)
+	}
+	assertBreakdown(t, "byEngine", standalone.byEngine, map[string]int{"postgres": 2, "mysql": 1})
+
+	aurora, err := countDBInstances(context.Background(), client, true)
+	if err != nil {
+		t.Fatalf("countDBInstances() error = %v", err)
+	}
+	if aurora.total != 3 {
+		t.Errorf("Aurora total = %d, want 3", aurora.total)
+	}
+	assertBreakdown(t, "byEngine", aurora.byEngine, map[string]int{"aurora-postgresql": 2, "aurora-mysql": 1})
+}
+
+func TestCountAuroraClusters(t *testing.T) {
+	const (
+		primary   = "arn:aws:rds:us-east-1:123456789012:cluster:orders"
+		secondary = "arn:aws:rds:us-east-1:123456789012:cluster:orders-dr"
+	)
+
+	t.Run("owned clusters only", func(t *testing.T) {
+		client := &fakeRDS{
+			clusters: []rdsTypes.DBCluster{
+				dbCluster(primary, "aurora-postgresql", "orders-global", ""),
+				dbCluster(secondary, "aurora-postgresql", "billing-global", ""),
+				dbCluster("arn:replica", "aurora-mysql", "", "arn:aws:rds:eu-west-1:123456789012:cluster:source"),
+				dbCluster("arn:plain", "aurora-mysql", "", ""),
+				dbCluster("arn:multi-az", "postgres", "", ""),
+			},
+			globalClusters: []rdsTypes.GlobalCluster{
+				{GlobalClusterMembers: []rdsTypes.GlobalClusterMember{
+					{DBClusterArn: awsSdk.String(primary), IsWriter: awsSdk.Bool(true)},
+				}},
+				{GlobalClusterMembers: []rdsTypes.GlobalClusterMember{
+					{DBClusterArn: awsSdk.String("arn:aws:rds:eu-west-1:123456789012:cluster:billing"), IsWriter: awsSdk.Bool(true)},
+					{DBClusterArn: awsSdk.String(secondary), IsWriter: awsSdk.Bool(false)},
+				}},
+			},
+		}
+
+		got, err := countAuroraClusters(context.Background(), client)
+		if err != nil {
+			t.Fatalf("countAuroraClusters() error = %v", err)
+		}
+		if got.total != 2 {
+			t.Errorf("total = %d, want 2 (primary and plain)", got.total)
+		}
+		assertBreakdown(t, "byEngine", got.byEngine, map[string]int{"aurora-postgresql": 1, "aurora-mysql": 1})
+	})
+
+	t.Run("no global databases", func(t *testing.T) {
+		client := &fakeRDS{clusters: []rdsTypes.DBCluster{dbCluster("arn:plain", "aurora-mysql", "", "")}}
+
+		got, err := countAuroraClusters(context.Background(), client)
+		if err != nil {
+			t.Fatalf("countAuroraClusters() error = %v", err)
+		}
+		if got.total != 1 {
+			t.Errorf("total = %d, want 1", got.total)
+		}
+		if client.globalCalls != 0 {
+			t.Errorf("DescribeGlobalClusters called %d times, want 0", client.globalCalls)
+		}
+	})
+}
+
+func TestCountNeptuneClusters(t *testing.T) {
+	client := &fakeRDS{clusters: []rdsTypes.DBCluster{
+		dbCluster("arn:graph", "neptune", "", ""),
+		dbCluster("arn:orders", "aurora-postgresql", "", ""),
+		dbCluster("arn:docs", "docdb", "", ""),
+	}}
+
+	got, err := countNeptuneClusters(context.Background(), client)
+	if err != nil {
+		t.Fatalf("countNeptuneClusters() error = %v", err)
+	}
+	if got != 1 {
+		t.Errorf("countNeptuneClusters() = %d, want 1", got)
+	}
+}
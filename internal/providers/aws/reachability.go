@@ -0,0 +1,132 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/smithy-go"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+
+	"go.uber.org/zap"
+)
+
+// Error codes that make a whole region unusable for the scan. Access denied
+// usually comes from an SCP restricting regions; the others from a region
+// that is not enabled for the account.
+var (
+	accessDeniedCodes = []string{"AccessDenied", "AccessDeniedException", "UnauthorizedOperation"}
+	notEnabledCodes   = []string{"UnrecognizedClientException", "InvalidClientTokenId", "AuthFailure", "OptInRequired"}
+)
+
+// regionSkipReason reports whether err means the region should be skipped,
+// and why
+func regionSkipReason(err error) (string, bool) {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return "", false
+	}
+	switch {
+	case hasErrorCode(err, accessDeniedCodes...):
+		return "access denied (" + apiErr.ErrorCode() + ")", true
+	case hasErrorCode(err, notEnabledCodes...):
+		return "region not enabled (" + apiErr.ErrorCode() + ")", true
+	}
+	return "", false
+}
+
+// probeRegions makes one minimal tagging API call per region and splits the
+// regions into those that answered and those that refused access or are not
+// enabled. Any other error leaves the region in the scan so that counting
+// reports it. Reachable regions whose probe returned no resource at all are
+// also returned as empty: no typed tagging query can find anything there.
+func probeRegions(
+	ctx context.Context,
+	regions []string,
+	taggingClients map[string]taggingAPI,
+) (reachable []string, skipped []models.SkippedRegion, empty []string) {
+
+	reasons := make([]string, len(regions))
+	isEmpty := make([]bool, len(regions))
+
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		client, exists := taggingClients[region]
+		if !exists {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			output, err := client.GetResources(ctx, &resourcegroupstaggingapi.GetResourcesInput{
+				ResourcesPerPage: awsSdk.Int32(1),
+			})
+			if err == nil {
+				isEmpty[i] = len(output.ResourceTagMappingList) == 0 && awsSdk.ToString(output.PaginationToken) == ""
+			}
+			if reason, skip := regionSkipReason(err); skip {
+				reasons[i] = reason
+			} else if err != nil {
+				logging.FromContext(ctx).Debug("Region probe failed, scanning anyway",
+					zap.String("region", region),
+					zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, region := range regions {
+		if reasons[i] != "" {
+			skipped = append(skipped, models.SkippedRegion{Region: region, Reason: reasons[i]})
+			continue
+		}
+		reachable = append(reachable, region)
+		if isEmpty[i] {
+			empty = append(empty, region)
+		}
+	}
+	return reachable, skipped, empty
+}
+
+// withoutRegions returns regions minus those in drop, keeping the order
+func withoutRegions(regions, drop []string) []string {
+	dropped := make(map[string]bool, len(drop))
+	for _, region := range drop {
+		dropped[region] = true
+	}
+	kept := make([]string, 0, len(regions))
+	for _, region := range regions {
+		if !dropped[region] {
+			kept = append(kept, region)
+		}
+	}
+	return kept
+}
+
+// skippedRegionsWarning summarizes skipped regions in one line
+func skippedRegionsWarning(skipped []models.SkippedRegion) string {
+	parts := make([]string, len(skipped))
+	for i, s := range skipped {
+		parts[i] = fmt.Sprintf("%s: %s", s.Region, s.Reason)
+	}
+	return fmt.Sprintf("Skipped %d AWS region(s) that are not accessible: %s",
+		len(skipped), strings.Join(parts, "; "))
+}
+
+// allAccessDenied reports whether every region was skipped for denying access
+func allAccessDenied(skipped []models.SkippedRegion) bool {
+	for _, region := range skipped {
+		if !strings.HasPrefix(region.Reason, "access denied") {
+			return false
+		}
+	}
+	return len(skipped) > 0
+}
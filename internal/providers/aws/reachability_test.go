@@ -0,0 +1,83 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/smithy-go"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestRegionSkipReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantReason string
+		wantSkip   bool
+	}{
+		{name: "no error"},
+		{
+			name:       "SCP denial",
+			err:        &smithy.GenericAPIError{Code: "AccessDeniedException"},
+			wantReason: "access denied (AccessDeniedException)",
+			wantSkip:   true,
+		},
+		{
+			name:       "region not opted in",
+			err:        fmt.Errorf("failed to get resources: %w", &smithy.GenericAPIError{Code: "UnrecognizedClientException"}),
+			wantReason: "region not enabled (UnrecognizedClientException)",
+			wantSkip:   true,
+		},
+		{
+			name: "throttling is not a reason to skip",
+			err:  &smithy.GenericAPIError{Code: "ThrottlingException"},
+		},
+		{
+			name: "non-API error",
+			err:  errors.New("connection reset"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, skip := regionSkipReason(tt.err)
+			if reason != tt.wantReason || skip != tt.wantSkip {
+				t.Errorf("regionSkipReason() = (%q, %v), want (%q, %v)", reason, skip, tt.wantReason, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestProbeRegions(t *testing.T) {
+	clients := map[string]taggingAPI{
+		"us-east-1":    &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(1, "")}},
+		"eu-west-1":    &fakeTaggingAPI{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{"": page(0, "")}},
+		"ap-east-1":    &fakeTaggingAPI{err: &smithy.GenericAPIError{Code: "UnrecognizedClientException"}},
+		"sa-east-1":    &fakeTaggingAPI{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}},
+		"ca-central-1": &fakeTaggingAPI{err: errors.New("connection reset")},
+	}
+	regions := []string{"us-east-1", "ap-east-1", "eu-west-1", "sa-east-1", "ca-central-1"}
+
+	reachable, skipped, empty := probeRegions(context.Background(), regions, clients)
+
+	wantReachable := []string{"us-east-1", "eu-west-1", "ca-central-1"}
+	if !reflect.DeepEqual(reachable, wantReachable) {
+		t.Errorf("reachable = %v, want %v", reachable, wantReachable)
+	}
+	wantSkipped := []models.SkippedRegion{
+		{Region: "ap-east-1", Reason: "region not enabled (UnrecognizedClientException)"},
+		{Region: "sa-east-1", Reason: "access denied (AccessDeniedException)"},
+	}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("skipped = %v, want %v", skipped, wantSkipped)
+	}
+	// A failed probe says nothing about the region's contents
+	if !reflect.DeepEqual(empty, []string{"eu-west-1"}) {
+		t.Errorf("empty = %v, want [eu-west-1]", empty)
+	}
+}
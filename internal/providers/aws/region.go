@@ -0,0 +1,89 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	awsConf "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// defaultRegion is used for discovery calls when no region is configured
+const defaultRegion = "us-east-1"
+
+// Region sources reported by resolveRegion
+const (
+	regionSourceFlag         = "--region flag"
+	regionSourceSharedConfig = "AWS config file"
+	regionSourceEnv          = "environment"
+	regionSourceDefault      = "default"
+)
+
+// regionPattern matches AWS region names such as us-east-1 or us-gov-west-1
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// ValidRegion reports whether region is shaped like an AWS region name; it
+// does not check that the region exists
+func ValidRegion(region string) bool {
+	return regionPattern.MatchString(region)
+}
+
+// resolveRegion picks the region for discovery calls: the --region flag, then
+// the profile's region in the shared config file, then AWS_REGION or
+// AWS_DEFAULT_REGION, then us-east-1. It returns the region and the source it
+// came from.
+func resolveRegion(
+	flagRegion string,
+	sharedConfigRegion func() (string, error),
+	getenv func(string) string,
+) (string, string, error) {
+	region, source := flagRegion, regionSourceFlag
+
+	if region == "" {
+		configured, err := sharedConfigRegion()
+		if err != nil {
+			return "", "", err
+		}
+		region, source = configured, regionSourceSharedConfig
+	}
+	for _, key := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region != "" {
+			break
+		}
+		region, source = getenv(key), regionSourceEnv+" ("+key+")"
+	}
+	if region == "" {
+		region, source = defaultRegion, regionSourceDefault
+	}
+
+	if !regionPattern.MatchString(region) {
+		return "", "", fmt.Errorf("invalid AWS region %q from %s", region, source)
+	}
+	return region, source, nil
+}
+
+// sharedConfigRegion returns a lookup of the region configured for profile in
+// the shared config file. A missing implicit default profile is not an error.
+func sharedConfigRegion(ctx context.Context, profile string) func() (string, error) {
+	return func() (string, error) {
+		name := profile
+		if name == "" {
+			name = os.Getenv("AWS_PROFILE")
+		}
+		if name == "" {
+			name = "default"
+		}
+
+		sharedConfig, err := awsConf.LoadSharedConfigProfile(ctx, name)
+		if err != nil {
+			var notExist awsConf.SharedConfigProfileNotExistError
+			if errors.As(err, &notExist) && name == "default" {
+				return "", nil
+			}
+			return "", fmt.Errorf("failed to read AWS profile %q: %w", name, err)
+		}
+		return sharedConfig.Region, nil
+	}
+}
@@ -0,0 +1,155 @@
+package aws
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestResolveRegion(t *testing.T) {
+	tests := []struct {
+		name         string
+		flag         string
+		sharedConfig string
+		sharedErr    error
+		env          map[string]string
+		wantRegion   string
+		wantSource   string
+		wantErr      string
+	}{
+		{
+			name:         "flag wins over everything",
+			flag:         "eu-west-1",
+			sharedConfig: "eu-central-1",
+			env:          map[string]string{"AWS_REGION": "us-west-2", "AWS_DEFAULT_REGION": "ap-south-1"},
+			wantRegion:   "eu-west-1",
+			wantSource:   regionSourceFlag,
+		},
+		{
+			name:         "config file wins over environment",
+			sharedConfig: "eu-central-1",
+			env:          map[string]string{"AWS_REGION": "us-west-2"},
+			wantRegion:   "eu-central-1",
+			wantSource:   regionSourceSharedConfig,
+		},
+		{
+			name:       "AWS_REGION wins over AWS_DEFAULT_REGION",
+			env:        map[string]string{"AWS_REGION": "us-west-2", "AWS_DEFAULT_REGION": "ap-south-1"},
+			wantRegion: "us-west-2",
+			wantSource: "environment (AWS_REGION)",
+		},
+		{
+			name:       "AWS_DEFAULT_REGION",
+			env:        map[string]string{"AWS_DEFAULT_REGION": "ap-south-1"},
+			wantRegion: "ap-south-1",
+			wantSource: "environment (AWS_DEFAULT_REGION)",
+		},
+		{
+			name:       "default",
+			wantRegion: defaultRegion,
+			wantSource: regionSourceDefault,
+		},
+		{
+			name:       "GovCloud region",
+			flag:       "us-gov-west-1",
+			wantRegion: "us-gov-west-1",
+			wantSource: regionSourceFlag,
+		},
+		{
+			name:    "invalid flag",
+			flag:    "europe",
+			wantErr: `invalid AWS region "europe" from --region flag`,
+		},
+		{
+			name:    "invalid environment value",
+			env:     map[string]string{"AWS_REGION": "US_EAST_1"},
+			wantErr: `invalid AWS region "US_EAST_1" from environment (AWS_REGION)`,
+		},
+		{
+			name:      "unreadable profile",
+			sharedErr: errors.New(`failed to read AWS profile "prod"`),
+			wantErr:   `failed to read AWS profile "prod"`,
+		},
+		{
+			name:       "flag skips profile lookup",
+			flag:       "eu-west-1",
+			sharedErr:  errors.New("should not be called"),
+			wantRegion: "eu-west-1",
+			wantSource: regionSourceFlag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedConfig := func() (string, error) {
+				return tt.sharedConfig, tt.sharedErr
+			}
+			getenv := func(key string) string {
+				return tt.env[key]
+			}
+
+			region, source, err := resolveRegion(tt.flag, sharedConfig, getenv)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if region != tt.wantRegion || source != tt.wantSource {
+				t.Errorf("got (%q, %q), want (%q, %q)", region, source, tt.wantRegion, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestSelectRegions(t *testing.T) {
+	available := []string{"us-east-1", "eu-west-1", "eu-central-1"}
+	tests := []struct {
+		name    string
+		regions []string
+		want    []string
+		wantErr string
+	}{
+		{name: "all", want: available},
+		{name: "allow-list", regions: []string{"eu-west-1"}, want: []string{"eu-west-1"}},
+		{name: "partly enabled", regions: []string{"eu-west-1", "ap-east-1"}, want: []string{"eu-west-1", "ap-east-1"}},
+		{name: "none enabled", regions: []string{"eu-wset-1"}, wantErr: "eu-wset-1 (did you mean eu-west-1?)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &AWSProvider{}
+			p.config.Regions = tt.regions
+			err := p.selectRegions(available)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectRegions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || strings.Join(p.regions, ",") != strings.Join(tt.want, ",") || p.availableRegions != len(available) {
+				t.Errorf("selectRegions() = %v, %v of %d, want %v", err, p.regions, p.availableRegions, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectRegionsLogger(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	p := &AWSProvider{}
+	p.config.Regions = []string{"eu-west-1", "ap-east-1"}
+	p.config.Logger = zap.New(core)
+
+	if err := p.selectRegions([]string{"us-east-1", "eu-west-1"}); err != nil {
+		t.Fatalf("selectRegions() error = %v", err)
+	}
+	entries := logs.FilterMessage("Requested region is not enabled for this account").All()
+	if len(entries) != 1 || entries[0].ContextMap()["region"] != "ap-east-1" {
+		t.Errorf("logged %+v, want one warning about ap-east-1 on the provider's logger", logs.All())
+	}
+}
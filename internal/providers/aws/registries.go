@@ -0,0 +1,99 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// repositoryType is the resource type whose images --deep-registries counts
+const repositoryType models.ResourceType = "ecr:repository"
+
+// ecrAPI is the subset of the ECR client used to look inside repositories
+type ecrAPI interface {
+	ecr.DescribeRepositoriesAPIClient
+	ecr.DescribeImagesAPIClient
+}
+
+// CountImages adds the images of the ECR repositories in regions to count,
+// calling the API within the collector's concurrency budget. Repositories
+// whose images cannot be listed, and those of regions whose repositories
+// cannot be listed, are added to ContentUnknown instead.
+func (c *ServiceCollector) CountImages(ctx context.Context, count *models.ResourceCount, regions []string, cfg awsSdk.Config) {
+	cfg = withConcurrencyBudget(cfg, c.sem)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, region := range regions {
+		wg.Add(1)
+		go func(region string) {
+			defer wg.Done()
+
+			regionalConfig := cfg.Copy()
+			regionalConfig.Region = region
+			images, unreadable, err := countRepositoryImages(ctx, ecr.NewFromConfig(regionalConfig))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logging.FromContext(ctx).Warn("Failed to list ECR repositories; their images are unknown",
+					zap.String("region", region),
+					zap.Error(err))
+				count.ContentUnknown += count.ByLocation[region]
+				return
+			}
+			count.Images += images
+			count.ContentUnknown += unreadable
+		}(region)
+	}
+	wg.Wait()
+}
+
+// countRepositoryImages sums the images of every repository client can list.
+// A repository whose images cannot be listed, e.g. because a repository
+// policy denies it, is counted as unreadable rather than failing the region.
+func countRepositoryImages(ctx context.Context, client ecrAPI) (images, unreadable int, err error) {
+	paginator := ecr.NewDescribeRepositoriesPaginator(client, &ecr.DescribeRepositoriesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to describe repositories: %w", err)
+		}
+
+		for _, repository := range page.Repositories {
+			count, err := countImages(ctx, client, repository.RepositoryName)
+			if err != nil {
+				if ctx.Err() != nil {
+					return 0, 0, ctx.Err()
+				}
+				logging.FromContext(ctx).Debug("Failed to list repository images",
+					zap.String("repository", awsSdk.ToString(repository.RepositoryName)),
+					zap.Error(err))
+				unreadable++
+				continue
+			}
+			images += count
+		}
+	}
+	return images, unreadable, nil
+}
+
+// countImages counts the images of one repository
+func countImages(ctx context.Context, client ecr.DescribeImagesAPIClient, repositoryName *string) (int, error) {
+	count := 0
+	paginator := ecr.NewDescribeImagesPaginator(client, &ecr.DescribeImagesInput{RepositoryName: repositoryName})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to describe images: %w", err)
+		}
+		count += len(page.ImageDetails)
+	}
+	return count, nil
+}
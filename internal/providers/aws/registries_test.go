@@ -0,0 +1,85 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// fakeRegistry serves repositories and their images, failing for the
+// repositories in denied
+type fakeRegistry struct {
+	images        map[string]int
+	denied        map[string]bool
+	repositoryErr error
+}
+
+func (f *fakeRegistry) DescribeRepositories(
+	_ context.Context,
+	params *ecr.DescribeRepositoriesInput,
+	_ ...func(*ecr.Options),
+) (*ecr.DescribeRepositoriesOutput, error) {
+	if f.repositoryErr != nil {
+		return nil, f.repositoryErr
+	}
+	output := &ecr.DescribeRepositoriesOutput{}
+	for name := range f.images {
+		output.Repositories = append(output.Repositories, ecrTypes.Repository{RepositoryName: awsSdk.String(name)})
+	}
+	return output, nil
+}
+
+// DescribeImages returns one image per page to exercise pagination
+func (f *fakeRegistry) DescribeImages(
+	_ context.Context,
+	params *ecr.DescribeImagesInput,
+	_ ...func(*ecr.Options),
+) (*ecr.DescribeImagesOutput, error) {
+	name := awsSdk.ToString(params.RepositoryName)
+	if f.denied[name] {
+		return nil, errors.New("AccessDeniedException")
+	}
+
+	page := 0
+	if params.NextToken != nil {
+		page = len(*params.NextToken)
+	}
+	output := &ecr.DescribeImagesOutput{}
+	if page < f.images[name] {
+		output.ImageDetails = []ecrTypes.ImageDetail{{RepositoryName: params.RepositoryName}}
+	}
+	if page+1 < f.images[name] {
+		next := make([]byte, page+1)
+		for i := range next {
+			next[i] = 'x'
+		}
+		output.NextToken = awsSdk.String(string(next))
+	}
+	return output, nil
+}
+
+func TestCountRepositoryImages(t *testing.T) {
+	client := &fakeRegistry{
+		images: map[string]int{"web": 3, "api": 2, "empty": 0, "locked": 5},
+		denied: map[string]bool{"locked": true},
+	}
+
+	images, unreadable, err := countRepositoryImages(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if images != 5 || unreadable != 1 {
+		t.Errorf("countRepositoryImages() = %d, %d, want 5 images and 1 unreadable repository", images, unreadable)
+	}
+}
+
+func TestCountRepositoryImagesError(t *testing.T) {
+	client := &fakeRegistry{repositoryErr: errors.New("AccessDeniedException")}
+	if _, _, err := countRepositoryImages(context.Background(), client); err == nil {
+		t.Fatal("expected an error")
+	}
+}
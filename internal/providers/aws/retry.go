@@ -0,0 +1,43 @@
+package aws
+
+import (
+	"errors"
+	"time"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+)
+
+// A scan calls many services in every region at once, so throttling is
+// expected. The SDK's default of three attempts gives up too early.
+const (
+	retryMaxAttempts = 8
+	retryMaxBackoff  = 30 * time.Second
+)
+
+// newRetryer returns the retry and backoff policy shared by every AWS client
+// the provider creates. The client-side retry quota is disabled because
+// throttled calls are already bounded by the collectors' semaphores.
+func newRetryer() awsSdk.Retryer {
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = retryMaxAttempts
+		o.MaxBackoff = retryMaxBackoff
+		o.RateLimiter = ratelimit.None
+	})
+}
+
+// hasErrorCode reports whether err is an AWS API error with one of the codes
+func hasErrorCode(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,566 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/amplify"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/backup"
+	"github.com/aws/aws-sdk-go-v2/service/batch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/configservice"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/elasticbeanstalk"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	"github.com/aws/aws-sdk-go-v2/service/inspector2"
+	inspectorTypes "github.com/aws/aws-sdk-go-v2/service/inspector2/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	securityHubTypes "github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/wafv2"
+	wafTypes "github.com/aws/aws-sdk-go-v2/service/wafv2/types"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
+)
+
+// serviceCounter counts one resource type in the region of cfg using the
+// service's own API
+type serviceCounter func(ctx context.Context, cfg awsSdk.Config) (regionCount, error)
+
+// regionCount is a service counter's result for one region. The breakdowns
+// are optional and merged into the matching ResourceCount maps.
+type regionCount struct {
+	total int
+
+	// noise is the part of total that the cloud manages, such as the log
+	// groups of AWS services, left out of the count with --exclude-noise
+	noise int
+
+	byState     map[string]int
+	bySKU       map[string]int
+	byLifecycle map[string]int
+	byEngine    map[string]int
+	byKind      map[string]int
+	byExposure  map[string]int
+	byRuntime   map[string]int
+}
+
+// counted adapts a plain count to a regionCount
+func counted(total int, err error) (regionCount, error) {
+	return regionCount{total: total}, err
+}
+
+// serviceCounters holds the counter for every type defined with the
+// service_api count method, keyed by resource type
+var serviceCounters = map[models.ResourceType]serviceCounter{
+	"ec2:instance": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countInstances(ctx, ec2.NewFromConfig(cfg))
+	},
+	"ec2:elastic-ip": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countElasticIPs(ctx, ec2.NewFromConfig(cfg))
+	},
+	"elasticloadbalancing:loadbalancer": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countLoadBalancers(ctx, elasticloadbalancingv2.NewFromConfig(cfg), elasticloadbalancing.NewFromConfig(cfg))
+	},
+	"lambda:function": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countFunctions(ctx, lambda.NewFromConfig(cfg))
+	},
+	"rds:db": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countDBInstances(ctx, rds.NewFromConfig(cfg), false)
+	},
+	"rds:db#aurora": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countDBInstances(ctx, rds.NewFromConfig(cfg), true)
+	},
+	"rds:cluster": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countAuroraClusters(ctx, rds.NewFromConfig(cfg))
+	},
+	"rds:cluster#neptune": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countNeptuneClusters(ctx, rds.NewFromConfig(cfg)))
+	},
+	"guardduty:detector": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countGuardDutyDetectors(ctx, guardduty.NewFromConfig(cfg)))
+	},
+	"securityhub:standards-subscription": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countEnabledStandards(ctx, securityhub.NewFromConfig(cfg)))
+	},
+	"config:configuration-recorder": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countConfigRecorders(ctx, configservice.NewFromConfig(cfg)))
+	},
+	"config:config-rule": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countConfigRules(ctx, configservice.NewFromConfig(cfg)))
+	},
+	"cloudtrail:trail": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countTrails(ctx, cloudtrail.NewFromConfig(cfg), cfg.Region))
+	},
+	"inspector2:account": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countInspectorAccounts(ctx, inspector2.NewFromConfig(cfg)))
+	},
+	"wafv2:webacl": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countWebACLs(ctx, wafv2.NewFromConfig(cfg), wafTypes.ScopeRegional))
+	},
+	"wafv2:webacl#cloudfront": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countWebACLs(ctx, wafv2.NewFromConfig(cfg), wafTypes.ScopeCloudfront))
+	},
+	"eks:nodegroup": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countEKSNodegroups(ctx, eks.NewFromConfig(cfg)))
+	},
+	"eks:fargateprofile": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countEKSFargateProfiles(ctx, eks.NewFromConfig(cfg)))
+	},
+	"eks:node": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countEKSNodes(ctx, eks.NewFromConfig(cfg), ec2.NewFromConfig(cfg)))
+	},
+	"ecs:service": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countECSServices(ctx, ecs.NewFromConfig(cfg)))
+	},
+	"ecs:task": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countECSRunningTasks(ctx, ecs.NewFromConfig(cfg)))
+	},
+	"logs:log-group": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countLogGroups(ctx, cloudwatchlogs.NewFromConfig(cfg))
+	},
+	"events:event-bus": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countEventBuses(ctx, eventbridge.NewFromConfig(cfg))
+	},
+	"events:rule": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countEventRules(ctx, eventbridge.NewFromConfig(cfg))
+	},
+	"ssm:managed-instance": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countManagedInstances(ctx, ssm.NewFromConfig(cfg))
+	},
+	"batch:compute-environment": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countComputeEnvironments(ctx, batch.NewFromConfig(cfg))
+	},
+	"batch:job-queue": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countJobQueues(ctx, batch.NewFromConfig(cfg))
+	},
+	"apprunner:service": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countAppRunnerServices(ctx, apprunner.NewFromConfig(cfg))
+	},
+	"elasticbeanstalk:application": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countBeanstalkApplications(ctx, elasticbeanstalk.NewFromConfig(cfg)))
+	},
+	"elasticbeanstalk:environment": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countBeanstalkEnvironments(ctx, elasticbeanstalk.NewFromConfig(cfg))
+	},
+	"amplify:apps": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countAmplifyApps(ctx, amplify.NewFromConfig(cfg)))
+	},
+	"ec2:image": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countAMIs(ctx, ec2.NewFromConfig(cfg))
+	},
+	"ec2:snapshot": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countEBSSnapshots(ctx, ec2.NewFromConfig(cfg))
+	},
+	"rds:snapshot": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countDBSnapshots(ctx, rds.NewFromConfig(cfg))
+	},
+	"rds:cluster-snapshot": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countDBClusterSnapshots(ctx, rds.NewFromConfig(cfg))
+	},
+	"backup:backup-plan": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return counted(countBackupPlans(ctx, backup.NewFromConfig(cfg)))
+	},
+	"backup:recovery-point": func(ctx context.Context, cfg awsSdk.Config) (regionCount, error) {
+		return countRecoveryPoints(ctx, backup.NewFromConfig(cfg))
+	},
+}
+
+// serviceActions lists the IAM actions each service counter calls, keyed
+// like serviceCounters
+var serviceActions = map[models.ResourceType][]string{
+	"ec2:instance":                       {"ec2:DescribeInstances"},
+	"ec2:elastic-ip":                     {"ec2:DescribeAddresses"},
+	"elasticloadbalancing:loadbalancer":  {"elasticloadbalancing:DescribeLoadBalancers"},
+	"lambda:function":                    {"lambda:ListFunctions"},
+	"rds:db":                             {"rds:DescribeDBInstances"},
+	"rds:db#aurora":                      {"rds:DescribeDBInstances"},
+	"rds:cluster":                        {"rds:DescribeDBClusters", "rds:DescribeGlobalClusters"},
+	"rds:cluster#neptune":                {"rds:DescribeDBClusters"},
+	"guardduty:detector":                 {"guardduty:ListDetectors"},
+	"securityhub:standards-subscription": {"securityhub:GetEnabledStandards"},
+	"config:configuration-recorder":      {"config:DescribeConfigurationRecorders"},
+	"config:config-rule":                 {"config:DescribeConfigRules"},
+	"cloudtrail:trail":                   {"cloudtrail:DescribeTrails"},
+	"inspector2:account":                 {"inspector2:BatchGetAccountStatus"},
+	"wafv2:webacl":                       {"wafv2:ListWebACLs"},
+	"wafv2:webacl#cloudfront":            {"wafv2:ListWebACLs"},
+	"eks:nodegroup":                      {"eks:ListClusters", "eks:ListNodegroups"},
+	"eks:fargateprofile":                 {"eks:ListClusters", "eks:ListFargateProfiles"},
+	"eks:node":                           {"eks:ListClusters", "ec2:DescribeInstances"},
+	"ecs:service":                        {"ecs:ListClusters", "ecs:ListServices"},
+	"ecs:task":                           {"ecs:ListClusters", "ecs:DescribeClusters"},
+	"logs:log-group":                     {"logs:DescribeLogGroups"},
+	"events:event-bus":                   {"events:ListEventBuses"},
+	"events:rule":                        {"events:ListEventBuses", "events:ListRules"},
+	"ssm:managed-instance":               {"ssm:DescribeInstanceInformation"},
+	"batch:compute-environment":          {"batch:DescribeComputeEnvironments"},
+	"batch:job-queue":                    {"batch:DescribeJobQueues"},
+	"apprunner:service":                  {"apprunner:ListServices"},
+	"elasticbeanstalk:application":       {"elasticbeanstalk:DescribeApplications"},
+	"elasticbeanstalk:environment":       {"elasticbeanstalk:DescribeEnvironments"},
+	"amplify:apps":                       {"amplify:ListApps"},
+	"ec2:image":                          {"ec2:DescribeImages"},
+	"ec2:snapshot":                       {"ec2:DescribeSnapshots"},
+	"rds:snapshot":                       {"rds:DescribeDBSnapshots"},
+	"rds:cluster-snapshot":               {"rds:DescribeDBClusterSnapshots"},
+	"backup:backup-plan":                 {"backup:ListBackupPlans"},
+	"backup:recovery-point":              {"backup:ListBackupVaults", "backup:ListRecoveryPointsByBackupVault"},
+}
+
+// ServiceCollector counts resource types that the tagging API does not
+// return by calling each service directly. Resources counted this way are not
+// written to the inventory.
+//
+// Counters may fan out further, e.g. per cluster, so the concurrency budget is
+// enforced per API request rather than per region. The budget is the
+// provider's, shared with the ResourceCollector.
+type ServiceCollector struct {
+	sem      *semaphore.Weighted
+	counters map[models.ResourceType]serviceCounter
+
+	// breaker skips the regions that keep failing; nil never skips
+	breaker *regionBreaker
+
+	// excludeNoise leaves the cloud-managed resources counters report out
+	// of the counts, as NoiseExcluded
+	excludeNoise bool
+}
+
+// NewServiceCollector creates a collector whose service API calls each hold
+// a slot of sem, the provider's concurrency budget
+func NewServiceCollector(sem *semaphore.Weighted) *ServiceCollector {
+	return &ServiceCollector{
+		sem:      sem,
+		counters: serviceCounters,
+	}
+}
+
+// PlanResourceType describes how CountResourceType counts resourceDef in
+// regions: each of its actions once per region
+func (c *ServiceCollector) PlanResourceType(resourceDef models.ResourceDefinition, regions []string) (models.PlannedType, error) {
+	resourceType := resourceDef.ResourceType()
+	if _, ok := c.counters[resourceType]; !ok {
+		return models.PlannedType{}, fmt.Errorf("no service API counter for %q", resourceType)
+	}
+	if resourceDef.Global {
+		regions = globalRegion(regions)
+	}
+	actions := serviceActions[resourceType]
+	return models.PlannedType{
+		Type:           resourceType,
+		DisplayName:    resourceDef.DisplayName,
+		CountMethod:    resourceDef.CountMethod,
+		API:            strings.Join(actions, ", "),
+		EstimatedCalls: len(regions) * len(actions),
+	}, nil
+}
+
+// CountResourceType counts a service_api resource type in every region. A
+// type without a registered counter is an error rather than a zero count.
+func (c *ServiceCollector) CountResourceType(
+	ctx context.Context,
+	resourceDef models.ResourceDefinition,
+	regions []string,
+	cfg awsSdk.Config,
+) (*models.ResourceCount, error) {
+
+	resourceType := resourceDef.ResourceType()
+	counter, ok := c.counters[resourceType]
+	if !ok {
+		return nil, fmt.Errorf("no service API counter for %q", resourceType)
+	}
+
+	result := &models.ResourceCount{
+		Provider:    "AWS",
+		Type:        resourceType,
+		DisplayName: resourceDef.DisplayName,
+		ByLocation:  make(map[string]int),
+		ByAccount:   make(map[string]int),
+	}
+
+	if resourceDef.Global {
+		regions = globalRegion(regions)
+	}
+
+	cfg = withConcurrencyBudget(cfg, c.sem)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	result.RegionsQueried = len(regions)
+
+	for _, region := range regions {
+		wg.Add(1)
+		go func(region string) {
+			defer wg.Done()
+
+			// A region that kept failing is no longer counted
+			if !c.breaker.allow(region) {
+				mu.Lock()
+				result.RegionsQueried--
+				mu.Unlock()
+				return
+			}
+
+			regionalConfig := cfg.Copy()
+			regionalConfig.Region = region
+
+			regionCtx, span := tracing.Start(ctx, "CountRegion")
+			span.SetString("region", region)
+			count, err := counter(regionCtx, regionalConfig)
+			span.SetInt("count", count.total)
+			span.End(err)
+			c.breaker.record(ctx, region, err)
+			if err != nil {
+				logging.FromContext(ctx).Error("Failed to count in region",
+					zap.String("region", region),
+					zap.String("type", string(resourceType)),
+					zap.Error(err))
+				mu.Lock()
+				result.FailedRegions = append(result.FailedRegions, region)
+				mu.Unlock()
+				return
+			}
+
+			if c.excludeNoise && count.noise > 0 {
+				count.total -= count.noise
+				mu.Lock()
+				result.NoiseExcluded += count.noise
+				mu.Unlock()
+			}
+			if count.total > 0 {
+				mu.Lock()
+				result.ByLocation[region] = count.total
+				result.TotalResources += count.total
+				result.ByState = mergeCounts(result.ByState, count.byState)
+				result.BySKU = mergeCounts(result.BySKU, count.bySKU)
+				result.ByLifecycle = mergeCounts(result.ByLifecycle, count.byLifecycle)
+				result.ByEngine = mergeCounts(result.ByEngine, count.byEngine)
+				result.ByKind = mergeCounts(result.ByKind, count.byKind)
+				result.ByExposure = mergeCounts(result.ByExposure, count.byExposure)
+				result.ByRuntime = mergeCounts(result.ByRuntime, count.byRuntime)
+				mu.Unlock()
+			}
+		}(region)
+	}
+
+	wg.Wait()
+	sort.Strings(result.FailedRegions)
+
+	logging.FromContext(ctx).Debug("Completed counting",
+		zap.String("type", string(resourceType)),
+		zap.Int("total", result.TotalResources),
+		zap.Int("regions", len(result.ByLocation)))
+
+	return result, nil
+}
+
+// mergeCounts adds src into dst, allocating dst on first use
+func mergeCounts(dst, src map[string]int) map[string]int {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]int, len(src))
+	}
+	for key, count := range src {
+		dst[key] += count
+	}
+	return dst
+}
+
+// withConcurrencyBudget returns a copy of cfg whose clients hold a slot of sem
+// for every request attempt. Retry backoff happens outside the slot.
+func withConcurrencyBudget(cfg awsSdk.Config, sem *semaphore.Weighted) awsSdk.Config {
+	budget := middleware.FinalizeMiddlewareFunc("ConcurrencyBudget", func(
+		ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+	) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+		}
+		defer sem.Release(1)
+		return next.HandleFinalize(ctx, in)
+	})
+
+	cfg = cfg.Copy()
+	cfg.APIOptions = append(append([]func(*middleware.Stack) error{}, cfg.APIOptions...),
+		func(stack *middleware.Stack) error {
+			return stack.Finalize.Add(budget, middleware.After)
+		})
+	return cfg
+}
+
+func countGuardDutyDetectors(ctx context.Context, client guardduty.ListDetectorsAPIClient) (int, error) {
+	count := 0
+	paginator := guardduty.NewListDetectorsPaginator(client, &guardduty.ListDetectorsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list GuardDuty detectors: %w", err)
+		}
+		count += len(page.DetectorIds)
+	}
+	return count, nil
+}
+
+// countEnabledStandards counts Security Hub standards that are enabled or
+// being enabled. A region where Security Hub is off has none.
+func countEnabledStandards(ctx context.Context, client securityhub.GetEnabledStandardsAPIClient) (int, error) {
+	count := 0
+	paginator := securityhub.NewGetEnabledStandardsPaginator(client, &securityhub.GetEnabledStandardsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			if hasErrorCode(err, "InvalidAccessException") {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("failed to get enabled Security Hub standards: %w", err)
+		}
+		for _, subscription := range page.StandardsSubscriptions {
+			switch subscription.StandardsStatus {
+			case securityHubTypes.StandardsStatusDeleting, securityHubTypes.StandardsStatusFailed:
+			default:
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// configRecorderAPI is the subset of the AWS Config client used to count
+// configuration recorders
+type configRecorderAPI interface {
+	DescribeConfigurationRecorders(
+		ctx context.Context,
+		params *configservice.DescribeConfigurationRecordersInput,
+		optFns ...func(*configservice.Options),
+	) (*configservice.DescribeConfigurationRecordersOutput, error)
+}
+
+func countConfigRecorders(ctx context.Context, client configRecorderAPI) (int, error) {
+	output, err := client.DescribeConfigurationRecorders(ctx, &configservice.DescribeConfigurationRecordersInput{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to describe Config recorders: %w", err)
+	}
+	return len(output.ConfigurationRecorders), nil
+}
+
+func countConfigRules(ctx context.Context, client configservice.DescribeConfigRulesAPIClient) (int, error) {
+	count := 0
+	paginator := configservice.NewDescribeConfigRulesPaginator(client, &configservice.DescribeConfigRulesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to describe Config rules: %w", err)
+		}
+		count += len(page.ConfigRules)
+	}
+	return count, nil
+}
+
+// trailAPI is the subset of the CloudTrail client used to count trails
+type trailAPI interface {
+	DescribeTrails(
+		ctx context.Context,
+		params *cloudtrail.DescribeTrailsInput,
+		optFns ...func(*cloudtrail.Options),
+	) (*cloudtrail.DescribeTrailsOutput, error)
+}
+
+// countTrails counts trails whose home region is region. Multi-region and
+// organization trails are visible from every region, so counting them only
+// in their home region counts each trail once.
+func countTrails(ctx context.Context, client trailAPI, region string) (int, error) {
+	output, err := client.DescribeTrails(ctx, &cloudtrail.DescribeTrailsInput{
+		IncludeShadowTrails: awsSdk.Bool(false),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to describe CloudTrail trails: %w", err)
+	}
+
+	count := 0
+	for _, trail := range output.TrailList {
+		if awsSdk.ToString(trail.HomeRegion) == region {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// inspectorAPI is the subset of the Inspector client used to read account
+// status
+type inspectorAPI interface {
+	BatchGetAccountStatus(
+		ctx context.Context,
+		params *inspector2.BatchGetAccountStatusInput,
+		optFns ...func(*inspector2.Options),
+	) (*inspector2.BatchGetAccountStatusOutput, error)
+}
+
+// countInspectorAccounts returns 1 when Inspector is enabled for the calling
+// account in the client's region
+func countInspectorAccounts(ctx context.Context, client inspectorAPI) (int, error) {
+	output, err := client.BatchGetAccountStatus(ctx, &inspector2.BatchGetAccountStatusInput{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Inspector account status: %w", err)
+	}
+
+	count := 0
+	for _, account := range output.Accounts {
+		if account.State != nil && account.State.Status == inspectorTypes.StatusEnabled {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// webACLAPI is the subset of the WAFv2 client used to list web ACLs
+type webACLAPI interface {
+	ListWebACLs(
+		ctx context.Context,
+		params *wafv2.ListWebACLsInput,
+		optFns ...func(*wafv2.Options),
+	) (*wafv2.ListWebACLsOutput, error)
+}
+
+// countWebACLs counts web ACLs in one scope. CLOUDFRONT scope is only
+// served by us-east-1.
+func countWebACLs(ctx context.Context, client webACLAPI, scope wafTypes.Scope) (int, error) {
+	count := 0
+	var marker *string
+	for {
+		output, err := client.ListWebACLs(ctx, &wafv2.ListWebACLsInput{
+			Scope:      scope,
+			NextMarker: marker,
+			Limit:      awsSdk.Int32(100),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list %s web ACLs: %w", scope, err)
+		}
+		count += len(output.WebACLs)
+
+		// Also stop on an empty page so a stray marker cannot loop forever
+		if len(output.WebACLs) == 0 || output.NextMarker == nil || *output.NextMarker == "" {
+			break
+		}
+		marker = output.NextMarker
+	}
+	return count, nil
+}
@@ -0,0 +1,273 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	awsSdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	trailTypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/aws/aws-sdk-go-v2/service/inspector2"
+	inspectorTypes "github.com/aws/aws-sdk-go-v2/service/inspector2/types"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	securityHubTypes "github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+	"github.com/aws/aws-sdk-go-v2/service/wafv2"
+	wafTypes "github.com/aws/aws-sdk-go-v2/service/wafv2/types"
+	"github.com/aws/smithy-go"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+type fakeTrailAPI struct {
+	trails []trailTypes.Trail
+}
+
+func (f *fakeTrailAPI) DescribeTrails(
+	_ context.Context,
+	params *cloudtrail.DescribeTrailsInput,
+	_ ...func(*cloudtrail.Options),
+) (*cloudtrail.DescribeTrailsOutput, error) {
+	return &cloudtrail.DescribeTrailsOutput{TrailList: f.trails}, nil
+}
+
+func trail(name, homeRegion string) trailTypes.Trail {
+	return trailTypes.Trail{Name: awsSdk.String(name), HomeRegion: awsSdk.String(homeRegion)}
+}
+
+func TestCountTrails(t *testing.T) {
+	// A multi-region trail homed in us-east-1 is visible from eu-west-1 too
+	client := &fakeTrailAPI{trails: []trailTypes.Trail{
+		trail("org-trail", "us-east-1"),
+		trail("audit", "eu-west-1"),
+	}}
+
+	for region, want := range map[string]int{"us-east-1": 1, "eu-west-1": 1, "ap-south-1": 0} {
+		got, err := countTrails(context.Background(), client, region)
+		if err != nil {
+			t.Fatalf("countTrails(%s) error = %v", region, err)
+		}
+		if got != want {
+			t.Errorf("countTrails(%s) = %d, want %d", region, got, want)
+		}
+	}
+}
+
+type fakeWebACLAPI struct {
+	pages  map[string][]wafTypes.WebACLSummary
+	scopes []wafTypes.Scope
+}
+
+func (f *fakeWebACLAPI) ListWebACLs(
+	_ context.Context,
+	params *wafv2.ListWebACLsInput,
+	_ ...func(*wafv2.Options),
+) (*wafv2.ListWebACLsOutput, error) {
+	f.scopes = append(f.scopes, params.Scope)
+
+	marker := awsSdk.ToString(params.NextMarker)
+	output := &wafv2.ListWebACLsOutput{WebACLs: f.pages[marker]}
+	if marker == "" {
+		output.NextMarker = awsSdk.String("page-2")
+	} else {
+		output.NextMarker = awsSdk.String("page-3")
+	}
+	return output, nil
+}
+
+func TestCountWebACLs(t *testing.T) {
+	client := &fakeWebACLAPI{pages: map[string][]wafTypes.WebACLSummary{
+		"":       make([]wafTypes.WebACLSummary, 100),
+		"page-2": make([]wafTypes.WebACLSummary, 3),
+	}}
+
+	got, err := countWebACLs(context.Background(), client, wafTypes.ScopeCloudfront)
+	if err != nil {
+		t.Fatalf("countWebACLs() error = %v", err)
+	}
+	if got != 103 {
+		t.Errorf("countWebACLs() = %d, want 103", got)
+	}
+	for _, scope := range client.scopes {
+		if scope != wafTypes.ScopeCloudfront {
+			t.Errorf("requested scope %s, want CLOUDFRONT", scope)
+		}
+	}
+}
+
+type fakeSecurityHubAPI struct {
+	subscriptions []securityHubTypes.StandardsSubscription
+	err           error
+}
+
+func (f *fakeSecurityHubAPI) GetEnabledStandards(
+	_ context.Context,
+	_ *securityhub.GetEnabledStandardsInput,
+	_ ...func(*securityhub.Options),
+) (*securityhub.GetEnabledStandardsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &securityhub.GetEnabledStandardsOutput{StandardsSubscriptions: f.subscriptions}, nil
+}
+
+func TestCountEnabledStandards(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *fakeSecurityHubAPI
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "skips deleting and failed",
+			client: &fakeSecurityHubAPI{subscriptions: []securityHubTypes.StandardsSubscription{
+				{StandardsStatus: securityHubTypes.StandardsStatusReady},
+				{StandardsStatus: securityHubTypes.StandardsStatusIncomplete},
+				{StandardsStatus: securityHubTypes.StandardsStatusDeleting},
+				{StandardsStatus: securityHubTypes.StandardsStatusFailed},
+			}},
+			want: 2,
+		},
+		{
+			name:   "not enabled",
+			client: &fakeSecurityHubAPI{err: &smithy.GenericAPIError{Code: "InvalidAccessException"}},
+			want:   0,
+		},
+		{
+			name:    "access denied",
+			client:  &fakeSecurityHubAPI{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countEnabledStandards(context.Background(), tt.client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("countEnabledStandards() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("countEnabledStandards() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeInspectorAPI struct {
+	status inspectorTypes.Status
+}
+
+func (f *fakeInspectorAPI) BatchGetAccountStatus(
+	_ context.Context,
+	_ *inspector2.BatchGetAccountStatusInput,
+	_ ...func(*inspector2.Options),
+) (*inspector2.BatchGetAccountStatusOutput, error) {
+	return &inspector2.BatchGetAccountStatusOutput{Accounts: []inspectorTypes.AccountState{
+		{AccountId: awsSdk.String("123456789012"), State: &inspectorTypes.State{Status: f.status}},
+	}}, nil
+}
+
+func TestCountInspectorAccounts(t *testing.T) {
+	for status, want := range map[inspectorTypes.Status]int{
+		inspectorTypes.StatusEnabled:  1,
+		inspectorTypes.StatusDisabled: 0,
+	} {
+		got, err := countInspectorAccounts(context.Background(), &fakeInspectorAPI{status: status})
+		if err != nil {
+			t.Fatalf("countInspectorAccounts() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("countInspectorAccounts(%s) = %d, want %d", status, got, want)
+		}
+	}
+}
+
+func TestServiceCollectorCountResourceType(t *testing.T) {
+	var mu sync.Mutex
+	var regionsSeen []string
+	collector := &ServiceCollector{
+		sem: semaphore.NewWeighted(1),
+		counters: map[models.ResourceType]serviceCounter{
+			"guardduty:detector": func(_ context.Context, cfg awsSdk.Config) (regionCount, error) {
+				mu.Lock()
+				regionsSeen = append(regionsSeen, cfg.Region)
+				mu.Unlock()
+				return regionCount{total: 1}, nil
+			},
+		},
+	}
+	regions := []string{"eu-west-1", "us-east-1"}
+
+	t.Run("every region", func(t *testing.T) {
+		def := models.ResourceDefinition{Type: "guardduty:detector", DisplayName: "GuardDuty Detectors"}
+		got, err := collector.CountResourceType(context.Background(), def, regions, awsSdk.Config{})
+		if err != nil {
+			t.Fatalf("CountResourceType() error = %v", err)
+		}
+		if got.TotalResources != 2 || len(got.ByLocation) != 2 {
+			t.Errorf("got %d resources in %d regions, want 2 in 2", got.TotalResources, len(got.ByLocation))
+		}
+	})
+
+	t.Run("global", func(t *testing.T) {
+		regionsSeen = nil
+		def := models.ResourceDefinition{Type: "guardduty:detector", DisplayName: "GuardDuty Detectors", Global: true}
+		if _, err := collector.CountResourceType(context.Background(), def, regions, awsSdk.Config{}); err != nil {
+			t.Fatalf("CountResourceType() error = %v", err)
+		}
+		if len(regionsSeen) != 1 || regionsSeen[0] != "us-east-1" {
+			t.Errorf("regions queried = %v, want [us-east-1]", regionsSeen)
+		}
+	})
+
+	t.Run("noise excluded", func(t *testing.T) {
+		noisy := &ServiceCollector{
+			sem: semaphore.NewWeighted(1),
+			counters: map[models.ResourceType]serviceCounter{
+				"events:event-bus": func(context.Context, awsSdk.Config) (regionCount, error) {
+					return regionCount{total: 3, noise: 1}, nil
+				},
+			},
+			excludeNoise: true,
+		}
+		def := models.ResourceDefinition{Type: "events:event-bus", DisplayName: "EventBridge Event Buses"}
+		got, err := noisy.CountResourceType(context.Background(), def, regions, awsSdk.Config{})
+		if err != nil {
+			t.Fatalf("CountResourceType() error = %v", err)
+		}
+		if got.TotalResources != 4 || got.NoiseExcluded != 2 || got.ByLocation["eu-west-1"] != 2 {
+			t.Errorf("got %d resources, %d noise, %v, want 4, 2 and 2 per region",
+				got.TotalResources, got.NoiseExcluded, got.ByLocation)
+		}
+	})
+
+	t.Run("no counter", func(t *testing.T) {
+		def := models.ResourceDefinition{Type: "macie:session", DisplayName: "Macie"}
+		_, err := collector.CountResourceType(context.Background(), def, regions, awsSdk.Config{})
+		if err == nil || !strings.Contains(err.Error(), "no service API counter") {
+			t.Errorf("CountResourceType() error = %v, want missing counter error", err)
+		}
+	})
+}
+
+// TestServiceCountersCoverDefinitions makes sure every built-in service_api
+// definition has a counter
+func TestServiceCountersCoverDefinitions(t *testing.T) {
+	set, err := models.DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, def := range set.ForProvider("aws") {
+		if def.CountMethod != models.CountMethodServiceAPI {
+			continue
+		}
+		if _, ok := serviceCounters[def.ResourceType()]; !ok {
+			t.Errorf("no service counter for %s", def.ResourceType())
+		}
+		if len(serviceActions[def.ResourceType()]) == 0 {
+			t.Errorf("no IAM actions listed for %s", def.ResourceType())
+		}
+	}
+}
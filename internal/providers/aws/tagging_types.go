@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"go.uber.org/zap"
+)
+
+// taggingResourceTypes lists Resource Groups Tagging API resource type
+// filters known to be valid. A filter is the service prefix and resource type
+// exactly as they appear in the resource's ARN, so several differ from the
+// product name (states:stateMachine, elasticfilesystem:file-system,
+// directconnect:dxcon). An unknown filter is accepted by the API but matches
+// nothing.
+var taggingResourceTypes = map[string]bool{
+	// Compute and containers
+	"autoscaling:autoScalingGroup": true,
+	"ec2:image":                    true,
+	"ec2:instance":                 true,
+	"ec2:launch-template":          true,
+	"ecr:repository":               true,
+	"ecs:cluster":                  true,
+	"ecs:service":                  true,
+	"ecs:task-definition":          true,
+	"eks:cluster":                  true,
+	"eks:nodegroup":                true,
+	"lambda:function":              true,
+
+	// Storage
+	"backup:backup-plan":            true,
+	"backup:backup-vault":           true,
+	"ec2:snapshot":                  true,
+	"ec2:volume":                    true,
+	"elasticfilesystem:file-system": true,
+	"fsx:file-system":               true,
+	"s3:bucket":                     true,
+
+	// Databases
+	"dynamodb:table":               true,
+	"elasticache:cluster":          true,
+	"elasticache:replicationgroup": true,
+	"rds:cluster":                  true,
+	"rds:db":                       true,
+	"redshift:cluster":             true,
+
+	// Networking
+	"apigateway:rest-api":               true,
+	"apigatewayv2:api":                  true,
+	"cloudfront:distribution":           true,
+	"directconnect:dxcon":               true,
+	"ec2:elastic-ip":                    true,
+	"ec2:internet-gateway":              true,
+	"ec2:natgateway":                    true,
+	"ec2:network-acl":                   true,
+	"ec2:network-interface":             true,
+	"ec2:security-group":                true,
+	"ec2:subnet":                        true,
+	"ec2:transit-gateway":               true,
+	"ec2:transit-gateway-attachment":    true,
+	"ec2:vpc":                           true,
+	"ec2:vpc-endpoint":                  true,
+	"ec2:vpc-peering-connection":        true,
+	"ec2:vpn-connection":                true,
+	"ec2:vpn-gateway":                   true,
+	"elasticloadbalancing:loadbalancer": true,
+	"elasticloadbalancing:targetgroup":  true,
+	"route53:hostedzone":                true,
+
+	// Application integration and messaging
+	"appsync:apis":        true,
+	"events:event-bus":    true,
+	"events:rule":         true,
+	"sns:topic":           true,
+	"sqs:queue":           true,
+	"states:stateMachine": true,
+
+	// Analytics
+	"athena:workgroup":         true,
+	"elasticmapreduce:cluster": true,
+	"es:domain":                true,
+	"firehose:deliverystream":  true,
+	"glue:crawler":             true,
+	"glue:job":                 true,
+	"kafka:cluster":            true,
+	"kinesis:stream":           true,
+
+	// Developer tools and management
+	"cloudformation:stack":   true,
+	"codebuild:project":      true,
+	"codecommit:repository":  true,
+	"codedeploy:application": true,
+	"codepipeline:pipeline":  true,
+	"cloudwatch:alarm":       true,
+	"logs:log-group":         true,
+	"ssm:parameter":          true,
+
+	// Identity and security
+	"acm:certificate":       true,
+	"cloudhsm:cluster":      true,
+	"cognito-idp:userpool":  true,
+	"iam:group":             true,
+	"iam:policy":            true,
+	"iam:role":              true,
+	"iam:user":              true,
+	"kms:key":               true,
+	"secretsmanager:secret": true,
+
+	// Machine learning, migration and end-user computing
+	"dms:rep":                     true,
+	"sagemaker:endpoint":          true,
+	"sagemaker:notebook-instance": true,
+	"workspaces:workspace":        true,
+}
+
+// checkTaggingTypes reports every tagging API definition whose filter is
+// not a known tagging API resource type, since it would silently count
+// zero. Such types are logged, so that a type added to the API since this
+// list was last generated still works; with strict, they fail the scan.
+func checkTaggingTypes(log *zap.Logger, definitions []models.ResourceDefinition, strict bool) error {
+	var unknown []string
+	for _, def := range definitions {
+		if def.CountMethod != models.CountMethodTaggingAPI || taggingResourceTypes[def.Type] {
+			continue
+		}
+		unknown = append(unknown, def.Type)
+		if !strict {
+			log.Warn("Resource type is not a known tagging API filter and may always count zero",
+				zap.String("type", def.Type))
+		}
+	}
+	if strict && len(unknown) > 0 {
+		return fmt.Errorf("resource types that are not known tagging API filters: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package aws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestBuiltInTaggingTypesAreKnown guards against filters that the tagging API
+// accepts but never matches, such as the old "ec2:autoscaling"
+func TestBuiltInTaggingTypesAreKnown(t *testing.T) {
+	set, err := models.DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, def := range set.ForProvider("aws") {
+		if def.CountMethod != models.CountMethodTaggingAPI {
+			continue
+		}
+		if !taggingResourceTypes[def.Type] {
+			t.Errorf("%s is not a known tagging API resource type filter", def.Type)
+		}
+	}
+}
+
+func TestCheckTaggingTypes(t *testing.T) {
+	definitions := []models.ResourceDefinition{
+		{Type: "ec2:instance", CountMethod: models.CountMethodTaggingAPI},
+		{Type: "ec2:autoscaling", CountMethod: models.CountMethodTaggingAPI},
+		{Type: "eks:cluster", CountMethod: models.CountMethodServiceAPI},
+	}
+
+	core, logs := observer.New(zap.WarnLevel)
+	if err := checkTaggingTypes(zap.New(core), definitions, false); err != nil {
+		t.Errorf("checkTaggingTypes() error = %v, want only a warning", err)
+	}
+	if logs.Len() != 1 || logs.All()[0].ContextMap()["type"] != "ec2:autoscaling" {
+		t.Errorf("logged %v, want one warning for ec2:autoscaling", logs.All())
+	}
+
+	err := checkTaggingTypes(zap.NewNop(), definitions, true)
+	if err == nil || !strings.Contains(err.Error(), "ec2:autoscaling") || strings.Contains(err.Error(), "ec2:instance") {
+		t.Errorf("strict checkTaggingTypes() error = %v, want one naming ec2:autoscaling only", err)
+	}
+
+	if _, err := NewAWSProvider(config.ProviderConfig{Definitions: definitions, StrictDefinitions: true}); err == nil {
+		t.Error("NewAWSProvider() accepted an unknown tagging type with StrictDefinitions")
+	}
+}
@@ -0,0 +1,173 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// Authentication methods selectable with --azure-auth
+const (
+	AuthDefault          = "default"
+	AuthCLI              = "cli"
+	AuthSP               = "sp"
+	AuthCertificate      = "certificate"
+	AuthWorkloadIdentity = "workload"
+	AuthMSI              = "msi"
+	AuthDeviceCode       = "devicecode"
+	AuthBrowser          = "browser"
+)
+
+// AuthMethods lists the accepted --azure-auth values
+var AuthMethods = []string{
+	AuthDefault, AuthCLI, AuthSP, AuthCertificate, AuthWorkloadIdentity, AuthMSI, AuthDeviceCode, AuthBrowser,
+}
+
+// Environment variables read by the service principal methods, and the one
+// enabling Managed Identity in the default chain
+const (
+	envTenantID            = "AZURE_TENANT_ID"
+	envClientID            = "AZURE_CLIENT_ID"
+	envClientSecret        = "AZURE_CLIENT_SECRET"
+	envCertificatePath     = "AZURE_CLIENT_CERTIFICATE_PATH"
+	envCertificatePassword = "AZURE_CLIENT_CERTIFICATE_PASSWORD"
+	envFederatedTokenFile  = "AZURE_FEDERATED_TOKEN_FILE"
+	envUseManagedIdentity  = "AZURE_USE_MANAGED_IDENTITY"
+)
+
+// ValidAuthMethod reports whether method is one of AuthMethods
+func ValidAuthMethod(method string) bool {
+	for _, m := range AuthMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// configuredMethod returns the service principal method the environment
+// selects, by the variable only that method uses, or "" for none. The other
+// variables it needs are checked when creating the credential, so a partial
+// setup fails instead of falling back to another method.
+func configuredMethod(getenv func(string) string) string {
+	switch {
+	case getenv(envClientSecret) != "":
+		return AuthSP
+	case getenv(envCertificatePath) != "":
+		return AuthCertificate
+	case getenv(envFederatedTokenFile) != "":
+		return AuthWorkloadIdentity
+	}
+	return ""
+}
+
+// newCredential creates the credential of a single authentication method.
+// tenantID, when set, pins the methods that accept a tenant and takes
+// precedence over AZURE_TENANT_ID. options apply to the token requests.
+func newCredential(method, tenantID string, getenv func(string) string, options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	if tenantID == "" {
+		tenantID = getenv(envTenantID)
+	}
+
+	switch method {
+	case AuthSP:
+		if err := requireSettings(tenantID, getenv, envClientSecret); err != nil {
+			return nil, err
+		}
+		return azidentity.NewClientSecretCredential(tenantID, getenv(envClientID), getenv(envClientSecret),
+			&azidentity.ClientSecretCredentialOptions{ClientOptions: options, DisableInstanceDiscovery: customAuthority(options)})
+	case AuthCertificate:
+		if err := requireSettings(tenantID, getenv, envCertificatePath); err != nil {
+			return nil, err
+		}
+		return newCertificateCredential(tenantID, getenv(envClientID),
+			getenv(envCertificatePath), getenv(envCertificatePassword), options)
+	case AuthWorkloadIdentity:
+		if err := requireSettings(tenantID, getenv, envFederatedTokenFile); err != nil {
+			return nil, err
+		}
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientOptions:            options,
+			TenantID:                 tenantID,
+			ClientID:                 getenv(envClientID),
+			TokenFilePath:            getenv(envFederatedTokenFile),
+			DisableInstanceDiscovery: customAuthority(options),
+		})
+	case AuthMSI:
+		return azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{ClientOptions: options})
+	case AuthCLI:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: tenantID})
+	case AuthDeviceCode:
+		return azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{
+			ClientOptions:            options,
+			TenantID:                 tenantID,
+			UserPrompt:               promptDeviceCode,
+			DisableInstanceDiscovery: customAuthority(options),
+		})
+	case AuthBrowser:
+		return azidentity.NewInteractiveBrowserCredential(&azidentity.InteractiveBrowserCredentialOptions{
+			ClientOptions:            options,
+			TenantID:                 tenantID,
+			DisableInstanceDiscovery: customAuthority(options),
+		})
+	}
+	return nil, fmt.Errorf("unsupported method (supported: %s)", strings.Join(AuthMethods, ", "))
+}
+
+// requireSettings checks the tenant, client ID and the method's own setting
+// of a service principal, naming every missing one
+func requireSettings(tenantID string, getenv func(string) string, setting string) error {
+	var missing []string
+	if tenantID == "" {
+		missing = append(missing, envTenantID+" (or --tenant-id)")
+	}
+	for _, key := range []string{envClientID, setting} {
+		if getenv(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// newCertificateCredential loads a PEM or PKCS#12 certificate with its
+// private key, decrypting it with password if one is given
+func newCertificateCredential(tenantID, clientID, path, password string, options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+	var passphrase []byte
+	if password != "" {
+		passphrase = []byte(password)
+	}
+	certs, key, err := azidentity.ParseCertificates(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client certificate %s: %w", path, err)
+	}
+	return azidentity.NewClientCertificateCredential(tenantID, clientID, certs, key,
+		&azidentity.ClientCertificateCredentialOptions{ClientOptions: options, DisableInstanceDiscovery: customAuthority(options)})
+}
+
+// customAuthority reports whether options request tokens from an authority
+// host other than the public cloud's. Instance discovery asks the public
+// cloud about the host, so it is skipped for one it cannot know.
+func customAuthority(options azcore.ClientOptions) bool {
+	host := options.Cloud.ActiveDirectoryAuthorityHost
+	return host != "" && host != cloud.AzurePublic.ActiveDirectoryAuthorityHost
+}
+
+// promptDeviceCode shows the sign-in URL and code on stderr, where it stays
+// visible with --quiet and never mixes with the results. The credential then
+// polls until sign-in completes or the context is cancelled.
+func promptDeviceCode(_ context.Context, message azidentity.DeviceCodeMessage) error {
+	fmt.Fprintf(os.Stderr, "\n%s\n\nWaiting for sign-in (Ctrl-C to cancel)...\n", message.Message)
+	return nil
+}
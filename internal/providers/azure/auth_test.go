@@ -0,0 +1,228 @@
+package azure
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+// writeCertificate writes a self-signed certificate and its key as PEM
+func writeCertificate(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sizing-agent-test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...)
+	path := filepath.Join(t.TempDir(), "client.pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewCredential(t *testing.T) {
+	certificate := writeCertificate(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("federated-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	app := func(extra map[string]string) map[string]string {
+		env := map[string]string{envTenantID: "tenant-a", envClientID: "client"}
+		for k, v := range extra {
+			env[k] = v
+		}
+		return env
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		tenantID string
+		env      map[string]string
+		wantErr  bool
+	}{
+		{name: "service principal", method: AuthSP, env: app(map[string]string{envClientSecret: "secret"})},
+		{name: "service principal with pinned tenant", method: AuthSP, tenantID: "tenant-b",
+			env: map[string]string{envClientID: "client", envClientSecret: "secret"}},
+		{name: "service principal without secret", method: AuthSP, env: app(nil), wantErr: true},
+		{name: "certificate", method: AuthCertificate, env: app(map[string]string{envCertificatePath: certificate})},
+		{name: "certificate without client ID", method: AuthCertificate,
+			env: map[string]string{envTenantID: "tenant-a", envCertificatePath: certificate}, wantErr: true},
+		{name: "missing certificate file", method: AuthCertificate,
+			env: app(map[string]string{envCertificatePath: filepath.Join(t.TempDir(), "missing.pem")}), wantErr: true},
+		{name: "invalid certificate", method: AuthCertificate, env: app(map[string]string{envCertificatePath: tokenFile}), wantErr: true},
+		{name: "workload identity", method: AuthWorkloadIdentity, env: app(map[string]string{envFederatedTokenFile: tokenFile})},
+		{name: "workload identity without tenant", method: AuthWorkloadIdentity,
+			env: map[string]string{envClientID: "client", envFederatedTokenFile: tokenFile}, wantErr: true},
+		{name: "cli", method: AuthCLI},
+		{name: "cli with invalid tenant", method: AuthCLI, tenantID: "not a tenant!", wantErr: true},
+		{name: "device code", method: AuthDeviceCode, tenantID: "tenant-a"},
+		{name: "browser", method: AuthBrowser},
+		{name: "unknown", method: "kerberos", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			credential, err := newCredential(tt.method, tt.tenantID, getenv, azcore.ClientOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newCredential() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && credential == nil {
+				t.Error("newCredential() returned no credential")
+			}
+		})
+	}
+}
+
+func TestConfiguredMethod(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{env: map[string]string{}, want: ""},
+		{env: map[string]string{envClientID: "client", envTenantID: "tenant"}, want: ""},
+		{env: map[string]string{envClientSecret: "secret"}, want: AuthSP},
+		{env: map[string]string{envCertificatePath: "client.pem"}, want: AuthCertificate},
+		{env: map[string]string{envFederatedTokenFile: "token"}, want: AuthWorkloadIdentity},
+	}
+
+	for _, tt := range tests {
+		if got := configuredMethod(func(key string) string { return tt.env[key] }); got != tt.want {
+			t.Errorf("configuredMethod(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestGetenvClientSecretEnv(t *testing.T) {
+	t.Setenv(envClientSecret, "shared")
+	t.Setenv("TENANT_A_SECRET", "tenant-a")
+
+	p := &AzureProvider{}
+	if got := p.getenv(envClientSecret); got != "shared" {
+		t.Errorf("getenv() = %q, want the AZURE_CLIENT_SECRET value", got)
+	}
+	p.config.AzureClientSecretEnv = "TENANT_A_SECRET"
+	if got := p.getenv(envClientSecret); got != "tenant-a" {
+		t.Errorf("getenv() = %q, want the value of the configured variable", got)
+	}
+}
+
+func TestSetupDefaultCredentialsDoesNotFallBack(t *testing.T) {
+	// A configured certificate that cannot be loaded is an error rather than
+	// a silent switch to the Azure CLI
+	t.Setenv(envClientSecret, "")
+	t.Setenv(envTenantID, "tenant-a")
+	t.Setenv(envClientID, "client")
+	t.Setenv(envCertificatePath, filepath.Join(t.TempDir(), "missing.pem"))
+
+	p := &AzureProvider{}
+	err := p.setupCredentials()
+	var authErr *sizingerrors.AuthError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("setupCredentials() error = %v, want an AuthError", err)
+	}
+	if p.credential != nil {
+		t.Errorf("credential = %T, want none", p.credential)
+	}
+
+	// Settings from the config file take precedence over the environment
+	p = &AzureProvider{config: config.ProviderConfig{AzureClientCertificatePath: writeCertificate(t)}}
+	if err := p.setupCredentials(); err != nil {
+		t.Fatalf("setupCredentials() error = %v", err)
+	}
+	if p.authMethod != AuthCertificate {
+		t.Errorf("authMethod = %q, want %q", p.authMethod, AuthCertificate)
+	}
+}
+
+func TestSetupDefaultCredentialsManagedIdentity(t *testing.T) {
+	for _, key := range []string{envClientSecret, envCertificatePath, envFederatedTokenFile} {
+		t.Setenv(key, "")
+	}
+	t.Setenv(envUseManagedIdentity, "true")
+
+	p := &AzureProvider{}
+	if err := p.setupCredentials(); err != nil {
+		t.Fatalf("setupCredentials() error = %v", err)
+	}
+	if p.authMethod != AuthMSI {
+		t.Errorf("authMethod = %q, want %q", p.authMethod, AuthMSI)
+	}
+}
+
+func TestSetupCredentialsRecordsMethod(t *testing.T) {
+	p := &AzureProvider{config: config.ProviderConfig{AzureAuth: AuthDeviceCode}}
+	if err := p.setupCredentials(); err != nil {
+		t.Fatalf("setupCredentials() error = %v", err)
+	}
+	if p.authMethod != AuthDeviceCode {
+		t.Errorf("authMethod = %q, want %q", p.authMethod, AuthDeviceCode)
+	}
+
+	// Selecting a method disables the fallback chain
+	t.Setenv(envClientSecret, "")
+	p = &AzureProvider{config: config.ProviderConfig{AzureAuth: AuthSP}}
+	err := p.setupCredentials()
+	var authErr *sizingerrors.AuthError
+	if !errors.As(err, &authErr) {
+		t.Errorf("setupCredentials() error = %v, want an AuthError", err)
+	}
+}
+
+func TestVerifyCredentialsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &AzureProvider{credential: authErrorCredential{fakeCredential{err: context.Canceled}}}
+	err := p.verifyCredentials(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("verifyCredentials() error = %v, want context.Canceled", err)
+	}
+	var authErr *sizingerrors.AuthError
+	if errors.As(err, &authErr) {
+		t.Errorf("a cancelled sign-in should not be reported as an AuthError: %v", err)
+	}
+}
+
+func TestValidAuthMethod(t *testing.T) {
+	for _, method := range AuthMethods {
+		if !ValidAuthMethod(method) {
+			t.Errorf("ValidAuthMethod(%q) = false", method)
+		}
+	}
+	if ValidAuthMethod("DeviceCode") || ValidAuthMethod("") {
+		t.Error("ValidAuthMethod() accepted an unsupported method")
+	}
+}
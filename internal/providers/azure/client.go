@@ -2,39 +2,75 @@ package azure
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
 
+	"github.com/secrails/secrails-sizing-agent/internal/cache"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/identity"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
 	"github.com/secrails/secrails-sizing-agent/internal/models"
 	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/counting"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
 	"github.com/secrails/secrails-sizing-agent/pkg/logging"
 
 	"go.uber.org/zap"
 )
 
+// typeConcurrency bounds the resource types counted at once
+const typeConcurrency = 5
+
 // AzureProvider implements the Provider interface for Azure
 type AzureProvider struct {
+	// mu guards the credential, SDK clients and subscriptions that Connect
+	// sets up; Plan and CountResources copy what they need under the read
+	// lock before counting starts
+	mu sync.RWMutex
+
 	config     config.ProviderConfig
 	credential azcore.TokenCredential
 
 	// Azure SDK clients
-	tenantsClient       *armsubscriptions.TenantsClient
 	subscriptionClient  *armsubscriptions.Client
 	resourceGraphClient *armresourcegraph.Client
 	resourceClients     map[string]*armresources.Client
 
 	// Account information
-	tenantID      string
-	locations     []string
-	subscriptions []models.AccountCount
+	authMethod          string
+	tenantID            string
+	locations           []string
+	subscriptions       []models.AccountCount
+	subscriptionsCached bool
+
+	// discoveredSubscriptions is the number of enabled subscriptions before
+	// any --subscriptions filter
+	discoveredSubscriptions int
+
+	// excludedSubscriptions were discovered but do not match the account
+	// filter
+	excludedSubscriptions []models.AccountCount
+
+	// managementGroupsWarning explains why subscriptions have no management
+	// group paths although they were asked for
+	managementGroupsWarning string
 
 	// Resource collector
 	collector *ResourceCollector
@@ -45,8 +81,9 @@ func NewAzureProvider(cfg config.ProviderConfig) (*AzureProvider, error) {
 	provider := &AzureProvider{
 		config:        cfg,
 		subscriptions: []models.AccountCount{},
-		collector:     &ResourceCollector{},
+		collector:     NewResourceCollector(cfg.Definitions, cfg.Inventory, cfg.TagFilters, cfg.ExcludeNoise),
 	}
+	provider.collector.pageSize, provider.collector.maxPages = int32(cfg.PageSize), cfg.MaxPages
 
 	return provider, nil
 }
@@ -57,12 +94,18 @@ func (p *AzureProvider) Name() string {
 }
 
 func (p *AzureProvider) Connect(ctx context.Context) error {
-	logging.Info("Connecting to Azure...")
+	ctx = logging.NewContext(ctx, p.config.Log())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.config.Log().Info("Connecting to Azure...")
 
 	// Step 1: Setup Azure credentials
 	if err := p.setupCredentials(); err != nil {
 		return fmt.Errorf("failed to setup Azure credentials: %w", err)
 	}
+	p.credential = authErrorCredential{p.credential}
 
 	// Step 2: Initialize clients
 	if err := p.initializeClients(); err != nil {
@@ -79,282 +122,685 @@ func (p *AzureProvider) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to discover Azure subscriptions: %w", err)
 	}
 
-	logging.Info("Connected to Azure successfully")
-	logging.Info("Tenant ID", zap.String("tenant_id", p.tenantID))
-	logging.Info("Subscriptions found", zap.Int("count", len(p.subscriptions)))
+	// Step 5: Place subscriptions in the management group hierarchy
+	if p.config.ManagementGroups {
+		p.placeSubscriptions(ctx)
+	}
+
+	p.config.Log().Info("Connected to Azure successfully")
+	p.config.Log().Info("Tenant ID", zap.String("tenant_id", p.tenantID))
+	p.config.Log().Info("Subscriptions found", zap.Int("count", len(p.subscriptions)))
 	if len(p.locations) > 0 {
-		logging.Info("Locations to scan", zap.Strings("locations", p.locations))
+		p.config.Log().Info("Locations to scan", zap.Strings("locations", p.locations))
 	}
 
 	return nil
 }
 
-// setupCredentials sets up Azure authentication
+// setupCredentials sets up Azure authentication with the configured method,
+// or with the first usable method of the default chain
 func (p *AzureProvider) setupCredentials() error {
-	logging.Debug("Setting up Azure credentials...")
-
-	var credential azcore.TokenCredential
-	var err error
+	p.config.Log().Debug("Setting up Azure credentials...")
 
-	// Try different authentication methods in order of preference
+	method := p.config.AzureAuth
+	if method == "" || method == AuthDefault {
+		return p.setupDefaultCredentials()
+	}
+	return p.useMethod(method)
+}
 
-	// 1. First, check for Service Principal credentials in environment
-	tenantID := os.Getenv("AZURE_TENANT_ID")
-	clientID := os.Getenv("AZURE_CLIENT_ID")
-	clientSecret := os.Getenv("AZURE_CLIENT_SECRET")
+// useMethod sets up the credential of method. Its errors are returned as
+// they are: a method that was asked for never falls back to another one.
+func (p *AzureProvider) useMethod(method string) error {
+	credential, err := newCredential(method, p.config.TenantID, p.getenv, p.credentialOptions())
+	if err != nil {
+		return &sizingerrors.AuthError{Provider: metricsProvider, Err: fmt.Errorf("%s authentication: %w", method, err)}
+	}
+	p.credential = credential
+	p.authMethod = method
+	return nil
+}
 
-	if tenantID != "" && clientID != "" && clientSecret != "" {
-		logging.Debug("Using Service Principal authentication from environment variables")
-		credential, err = azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, nil)
-		if err == nil {
-			p.tenantID = tenantID
-			p.credential = credential
-			return nil
-		}
-		logging.Debug("Service Principal authentication failed", zap.Error(err))
+// setupDefaultCredentials uses the service principal the environment or
+// config file sets up, else Managed Identity when enabled, else the Azure
+// CLI and finally DefaultAzureCredential
+func (p *AzureProvider) setupDefaultCredentials() error {
+	// 1. A service principal with a secret, certificate or federated token
+	if method := configuredMethod(p.getenv); method != "" {
+		p.config.Log().Debug("Using Service Principal authentication", zap.String("method", method))
+		return p.useMethod(method)
 	}
 
-	// 2. Try Managed Identity (for Azure VMs, App Service, etc.)
-	if os.Getenv("AZURE_USE_MANAGED_IDENTITY") == "true" {
-		logging.Debug("Attempting Managed Identity authentication")
-		credential, err = azidentity.NewManagedIdentityCredential(nil)
-		if err == nil {
-			p.credential = credential
-			// Tenant ID will be discovered during verification
-			return nil
-		}
-		logging.Debug("Managed Identity authentication failed: ", zap.Error(err))
+	// 2. Managed Identity (for Azure VMs, App Service, etc.). Its tenant is
+	// that of the identity; a pinned tenant is checked during verification.
+	if p.getenv(envUseManagedIdentity) == "true" {
+		p.config.Log().Debug("Using Managed Identity authentication")
+		return p.useMethod(AuthMSI)
 	}
 
 	// 3. Try Azure CLI authentication (for local development)
-	logging.Debug("Attempting Azure CLI authentication")
-	credential, err = azidentity.NewAzureCLICredential(nil)
+	p.config.Log().Debug("Attempting Azure CLI authentication")
+	credential, err := newCredential(AuthCLI, p.config.TenantID, p.getenv, p.credentialOptions())
 	if err == nil {
-		p.credential = credential
-		// Tenant ID will be discovered during verification
+		p.credential, p.authMethod = credential, AuthCLI
 		return nil
 	}
-	logging.Debug("Azure CLI authentication failed:", zap.Error(err))
+	p.config.Log().Debug("Azure CLI authentication failed:", zap.Error(err))
 
 	// 4. Try DefaultAzureCredential (tries multiple methods)
-	logging.Debug("Attempting DefaultAzureCredential authentication")
-	credential, err = azidentity.NewDefaultAzureCredential(nil)
+	p.config.Log().Debug("Attempting DefaultAzureCredential authentication")
+	credential, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: p.credentialOptions(),
+		TenantID:      p.config.TenantID,
+	})
 	if err == nil {
-		p.credential = credential
+		p.credential, p.authMethod = credential, AuthDefault
 		return nil
 	}
 
-	return fmt.Errorf("failed to authenticate with Azure. Please ensure you have valid credentials set up. " +
-		"You can use: 1) Service Principal (set AZURE_TENANT_ID, AZURE_CLIENT_ID, AZURE_CLIENT_SECRET), " +
-		"2) Azure CLI (run 'az login'), or 3) Managed Identity (set AZURE_USE_MANAGED_IDENTITY=true)")
+	return &sizingerrors.AuthError{Provider: metricsProvider, Err: fmt.Errorf(
+		"failed to authenticate with Azure. Please ensure you have valid credentials set up. " +
+			"You can use: 1) Service Principal (set AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET, " +
+			"AZURE_CLIENT_CERTIFICATE_PATH or AZURE_FEDERATED_TOKEN_FILE), " +
+			"2) Azure CLI (run 'az login'), 3) Managed Identity (set AZURE_USE_MANAGED_IDENTITY=true), " +
+			"or 4) --azure-auth devicecode or browser")}
+}
+
+// clientOptions returns the options of every Azure API client: the agent's
+// User-Agent, API metrics and call counting, the debug dump if enabled, and
+// the configured cloud and transport
+func (p *AzureProvider) clientOptions() policy.ClientOptions {
+	options := policy.ClientOptions{
+		Cloud:            p.cloud(),
+		PerCallPolicies:  []policy.Policy{userAgentPolicy{}, callMetricsPolicy{calls: p.config.APICalls}},
+		PerRetryPolicies: []policy.Policy{attemptMetricsPolicy{}},
+		Transport:        p.transport(),
+	}
+	if p.config.DebugDump != nil {
+		options.PerRetryPolicies = append(options.PerRetryPolicies, dumpPolicy{dumper: p.config.DebugDump})
+	}
+	return options
+}
+
+// credentialOptions returns the options of credentials, whose token requests
+// carry the agent's User-Agent but are not counted as API calls
+func (p *AzureProvider) credentialOptions() policy.ClientOptions {
+	return policy.ClientOptions{
+		Cloud:           p.cloud(),
+		PerCallPolicies: []policy.Policy{userAgentPolicy{}},
+		Transport:       p.transport(),
+	}
+}
+
+// cloud returns the public cloud with the configured Resource Manager
+// endpoint and authority host in place of its own. The token audience stays
+// that of the public cloud.
+func (p *AzureProvider) cloud() cloud.Configuration {
+	configuration := cloud.AzurePublic
+	if p.config.AzureAuthorityHost != "" {
+		configuration.ActiveDirectoryAuthorityHost = p.config.AzureAuthorityHost
+	}
+	if p.config.AzureEndpointURL != "" {
+		configuration.Services = maps.Clone(configuration.Services)
+		resourceManager := configuration.Services[cloud.ResourceManager]
+		resourceManager.Endpoint = p.config.AzureEndpointURL
+		configuration.Services[cloud.ResourceManager] = resourceManager
+	}
+	return configuration
+}
+
+// transport returns the configured HTTP client, or nil for the SDK default.
+// A nil *http.Client must not become a non-nil Transporter.
+func (p *AzureProvider) transport() policy.Transporter {
+	if p.config.HTTPClient == nil {
+		return nil
+	}
+	return p.config.HTTPClient
+}
+
+// getenv reads a credential setting, preferring the value from the config
+// file over the environment variable of the same meaning. The client secret
+// is read from the variable the config names, if any.
+func (p *AzureProvider) getenv(key string) string {
+	var value string
+	switch key {
+	case envClientID:
+		value = p.config.AzureClientID
+	case envCertificatePath:
+		value = p.config.AzureClientCertificatePath
+	case envFederatedTokenFile:
+		value = p.config.AzureFederatedTokenFile
+	case envClientSecret:
+		if p.config.AzureClientSecretEnv != "" {
+			return os.Getenv(p.config.AzureClientSecretEnv)
+		}
+	}
+	if value != "" {
+		return value
+	}
+	return os.Getenv(key)
 }
 
 func (p *AzureProvider) initializeClients() error {
 	// Initialize subscription client
 	var err error
-	p.subscriptionClient, err = armsubscriptions.NewClient(p.credential, nil)
+	p.subscriptionClient, err = armsubscriptions.NewClient(p.credential, &arm.ClientOptions{ClientOptions: p.clientOptions()})
 	if err != nil {
 		return fmt.Errorf("failed to create subscription client: %w", err)
 	}
 
 	// Initialize Resource Graph client for efficient querying
-	p.resourceGraphClient, err = armresourcegraph.NewClient(p.credential, nil)
+	p.resourceGraphClient, err = armresourcegraph.NewClient(p.credential, &arm.ClientOptions{ClientOptions: p.clientOptions()})
 	if err != nil {
 		return fmt.Errorf("failed to create resource graph client: %w", err)
 	}
 
-	// Initialize Tenants client
-	p.tenantsClient, err = armsubscriptions.NewTenantsClient(p.credential, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create tenants client: %w", err)
-	}
-
 	// Initialize map for resource clients
 	p.resourceClients = make(map[string]*armresources.Client)
 
 	return nil
 }
 
+// verifyCredentials acquires a Resource Manager token, failing when none can
+// be had, and takes the tenant from it. The token names the one tenant the
+// credential actually signs in to, even when it can see several.
 func (p *AzureProvider) verifyCredentials(ctx context.Context) error {
-	logging.Debug("Verifying Azure credentials...")
+	p.config.Log().Debug("Verifying Azure credentials...")
+
+	token, err := p.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{managementScope}})
+	if ctx.Err() != nil {
+		// Interrupted, e.g. while waiting for a device code sign-in
+		return fmt.Errorf("authentication cancelled: %w", ctx.Err())
+	}
+	if err != nil {
+		return err
+	}
 
-	// Get tenant information by listing tenants
-	tenantPager := p.tenantsClient.NewListPager(nil)
+	tenantID, err := tenantFromToken(token.Token)
+	if err != nil {
+		return &sizingerrors.AuthError{Provider: metricsProvider, Err: err}
+	}
+	if p.config.TenantID != "" && !strings.EqualFold(tenantID, p.config.TenantID) {
+		return &sizingerrors.AuthError{Provider: metricsProvider, Err: fmt.Errorf(
+			"credentials signed in to tenant %s, not the requested tenant %s", tenantID, p.config.TenantID)}
+	}
 
-	// Just get the first page to verify connection
-	if tenantPager.More() {
-		page, err := tenantPager.NextPage(ctx)
-		if err != nil {
-			// This might fail for some credential types, not fatal
-			logging.Debug("Could not list tenants (may be normal): ", zap.Error(err))
-			return nil
+	p.tenantID = tenantID
+	p.config.Log().Debug("Found tenant", zap.String("tenant_id", p.tenantID))
+	return nil
+}
+
+// tenantFromToken returns the tid claim of a JWT access token. The token is
+// not validated; it was just issued to us and is only read for its tenant.
+func tenantFromToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("access token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+
+	var claims struct {
+		TenantID string `json:"tid"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+	if claims.TenantID == "" {
+		return "", fmt.Errorf("access token has no tenant claim")
+	}
+	return claims.TenantID, nil
+}
+
+func (p *AzureProvider) discoverSubscriptions(ctx context.Context) error {
+	p.config.Log().Debug("Discovering Azure subscriptions...")
+
+	enabled, err := p.enabledSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	p.discoveredSubscriptions = len(enabled)
+
+	// Check if specific subscriptions are configured
+	wanted := subscriptionFilter(p.config.SubscriptionIDs, os.Getenv)
+	if len(wanted) > 0 {
+		p.config.Log().Info("Limiting scan to configured subscriptions", zap.Int("count", len(wanted)))
+	}
+
+	found := make(map[string]bool)
+	for _, sub := range enabled {
+		id := strings.ToLower(sub.ID)
+		if len(wanted) > 0 && !wanted[id] {
+			continue
 		}
+		found[id] = true
+		p.subscriptions = append(p.subscriptions, sub)
+	}
 
-		// Get the first tenant ID if available
-		for _, tenant := range page.Value {
-			if tenant.TenantID != nil && p.tenantID == "" {
-				p.tenantID = *tenant.TenantID
-				logging.Debug("Found tenant: ", zap.String("tenant_id", p.tenantID))
-				break
-			}
+	var candidates, missing []string
+	for _, sub := range enabled {
+		candidates = append(candidates, sub.ID)
+	}
+	for id := range wanted {
+		if !found[id] {
+			p.config.Log().Warn("Configured subscription not found or not accessible",
+				zap.String("subscription_id", id),
+				zap.Strings("closest_accessible", models.Suggest(id, candidates)))
+			missing = append(missing, id)
 		}
 	}
 
+	if len(p.subscriptions) == 0 && len(wanted) > 0 && len(enabled) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("none of the configured subscriptions is enabled and accessible, nothing to scan: %s",
+			models.Unmatched(missing, candidates))
+	}
+	if len(p.subscriptions) == 0 {
+		return fmt.Errorf("no active Azure subscriptions found")
+	}
+
+	// The account filter narrows the configured subscriptions further
+	if filter := p.config.AccountFilter; !filter.Empty() {
+		p.subscriptions, p.excludedSubscriptions = filter.Apply(p.subscriptions)
+		p.config.Log().Info("Limiting scan to subscriptions matching the account filter",
+			zap.String("filter", filter.String()),
+			zap.Int("kept", len(p.subscriptions)), zap.Int("excluded", len(p.excludedSubscriptions)))
+		if len(p.subscriptions) == 0 {
+			return fmt.Errorf("%w: none of the %d subscriptions matches %s",
+				models.ErrAccountsExcluded, len(p.excludedSubscriptions), filter)
+		}
+	}
+
+	p.config.Log().Debug("Found active subscription(s)", zap.Int("count", len(p.subscriptions)))
 	return nil
 }
 
-func (p *AzureProvider) discoverSubscriptions(ctx context.Context) error {
-	logging.Debug("Discovering Azure subscriptions...")
-
-	// Check if a specific subscription is configured
-	specificSubID := os.Getenv("AZURE_SUBSCRIPTION_ID")
-	if p.config.SubscriptionID != "" {
-		specificSubID = p.config.SubscriptionID
+// enabledSubscriptions lists the enabled subscriptions. A fresh cached list
+// for the tenant is reused instead of enumerating them again.
+func (p *AzureProvider) enabledSubscriptions(ctx context.Context) ([]models.AccountCount, error) {
+	cacheKey := "azure-" + p.tenantID
+	if p.tenantID != "" {
+		if cached, ok := p.config.Cache.Load(cacheKey); ok && len(cached.Accounts) > 0 {
+			p.config.Log().Info("Using cached subscription discovery (use --no-cache to refresh)")
+			p.subscriptionsCached = true
+			return cached.AccountCounts(), nil
+		}
 	}
 
 	// List all accessible subscriptions
 	pager := p.subscriptionClient.NewListPager(nil)
 
-	subscriptionCount := 0
+	var subscriptions []models.AccountCount
 	for pager.More() {
 		page, err := pager.NextPage(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to list subscriptions: %w", err)
+			return nil, fmt.Errorf("failed to list subscriptions: %w", classifyError(err, "Microsoft.Resources/subscriptions/read"))
 		}
 
 		for _, sub := range page.Value {
-			// Skip if we're looking for a specific subscription
-			if specificSubID != "" && sub.SubscriptionID != nil && *sub.SubscriptionID != specificSubID {
-				continue
-			}
-
 			// Only include enabled subscriptions
 			if sub.State != nil && (*sub.State == armsubscriptions.SubscriptionStateEnabled ||
 				*sub.State == armsubscriptions.SubscriptionStateWarned) {
-
-				subscriptionCount++
-
-				// Get safe string values
-				subID := ""
-				subName := ""
-				subState := ""
-
-				if sub.SubscriptionID != nil {
-					subID = *sub.SubscriptionID
-				}
-				if sub.DisplayName != nil {
-					subName = *sub.DisplayName
-				}
-				if sub.State != nil {
-					subState = string(*sub.State)
-				}
-
-				account := models.AccountCount{
-					ID:     subID,
-					Name:   subName,
-					Status: subState,
-				}
-
-				p.subscriptions = append(p.subscriptions, account)
-				logging.Debug("Found subscription: ", zap.String("subscription_id", subID), zap.String("name", subName), zap.String("state", subState))
+				account := subscriptionAccount(sub)
+				subscriptions = append(subscriptions, account)
+				p.config.Log().Debug("Found subscription: ", zap.String("subscription_id", account.ID),
+					zap.String("name", account.Name), zap.String("state", account.Status), zap.String("offer", account.Offer))
 			}
 		}
 	}
 
-	if subscriptionCount == 0 {
-		return fmt.Errorf("no active Azure subscriptions found")
+	if p.tenantID != "" && len(subscriptions) > 0 {
+		if err := p.config.Cache.Store(cacheKey, &cache.Discovery{Accounts: cache.FromAccounts(subscriptions)}); err != nil {
+			p.config.Log().Debug("Could not write discovery cache", zap.Error(err))
+		}
 	}
 
-	logging.Debug("Found active subscription(s)", zap.Int("count", subscriptionCount))
-	return nil
+	return subscriptions, nil
 }
 
-func (p *AzureProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
-	logging.Info("Counting Azure resources...")
+// subscriptionAccount converts a listed subscription, with its tags and
+// offer, into an account count
+func subscriptionAccount(sub *armsubscriptions.Subscription) models.AccountCount {
+	account := models.AccountCount{
+		ID:   stringValue(sub.SubscriptionID),
+		Name: stringValue(sub.DisplayName),
+	}
+	if sub.State != nil {
+		account.Status = string(*sub.State)
+	}
+	if sub.SubscriptionPolicies != nil {
+		account.Offer = stringValue(sub.SubscriptionPolicies.QuotaID)
+	}
+	if len(sub.Tags) > 0 {
+		account.Tags = make(map[string]string, len(sub.Tags))
+		for key, value := range sub.Tags {
+			account.Tags[key] = stringValue(value)
+		}
+	}
+	return account
+}
 
-	if len(p.subscriptions) == 0 {
+// stringValue returns the string s points to, or "" for nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// ListSubscriptions authenticates with the usual credential chain and returns
+// the enabled subscriptions, for interactive selection before a scan
+func ListSubscriptions(ctx context.Context) ([]models.AccountCount, error) {
+	p := &AzureProvider{}
+	if err := p.setupCredentials(); err != nil {
+		return nil, err
+	}
+	p.credential = authErrorCredential{p.credential}
+	if err := p.initializeClients(); err != nil {
+		return nil, err
+	}
+	if err := p.verifyCredentials(ctx); err != nil {
+		return nil, err
+	}
+	return p.enabledSubscriptions(ctx)
+}
+
+// subscriptionIDPattern matches subscription IDs, which are GUIDs
+var subscriptionIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidSubscriptionID reports whether id is shaped like a subscription ID
+func ValidSubscriptionID(id string) bool {
+	return subscriptionIDPattern.MatchString(id)
+}
+
+// subscriptionFilter returns the lower-cased subscription IDs to scan: the
+// configured list, else the comma-separated AZURE_SUBSCRIPTION_ID. An empty
+// filter means every accessible subscription.
+func subscriptionFilter(configured []string, getenv func(string) string) map[string]bool {
+	ids := configured
+	if len(ids) == 0 {
+		ids = strings.Split(getenv("AZURE_SUBSCRIPTION_ID"), ",")
+	}
+
+	filter := make(map[string]bool)
+	for _, id := range ids {
+		if id = strings.ToLower(strings.TrimSpace(id)); id != "" {
+			filter[id] = true
+		}
+	}
+	return filter
+}
+
+// Accounts lists the discovered subscriptions with their state and tenant
+func (p *AzureProvider) Accounts() *models.AccountList {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	list := models.NewAccountList("Azure", models.AccountSourceSubscriptions, p.subscriptions)
+	list.TenantID = p.tenantID
+	list.Cached = p.subscriptionsCached
+	return list
+}
+
+// Plan describes what CountResources would do with the discovered
+// subscriptions
+func (p *AzureProvider) Plan() (*models.ScanPlan, error) {
+	p.mu.RLock()
+	subscriptions := make([]models.AccountCount, len(p.subscriptions))
+	copy(subscriptions, p.subscriptions)
+	defenderCollector := NewDefenderCollector(p.credential, p.clientOptions())
+	p.mu.RUnlock()
+
+	if len(subscriptions) == 0 {
+		return nil, fmt.Errorf("no subscriptions available to scan")
+	}
+	subscriptionIDs := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
+		subscriptionIDs[i] = sub.ID
+	}
+
+	plan := &models.ScanPlan{Provider: "Azure", Accounts: subscriptions, Concurrency: typeConcurrency}
+	if p.config.DeepRegistries {
+		plan.Warnings = append(plan.Warnings,
+			"--deep-registries adds a Resource Graph query and three registry API calls per container registry, not included in the estimate")
+	}
+	if p.config.DeepStorage {
+		plan.Warnings = append(plan.Warnings,
+			"--deep-storage adds a Resource Graph query and up to four storage API calls per storage account, not included in the estimate")
+	}
+	if p.config.DeepBackup {
+		plan.Warnings = append(plan.Warnings,
+			"--deep-backup adds a Resource Graph query and two or more vault API calls per Recovery Services vault, not included in the estimate")
+	}
+	for _, def := range p.collector.GetResourceTypesToCount() {
+		switch def.CountMethod {
+		case models.CountMethodResourceGraph:
+			planned, err := p.collector.PlanResourceType(def)
+			if err != nil {
+				plan.Warnings = append(plan.Warnings, err.Error())
+				continue
+			}
+			plan.AddType(planned)
+		case models.CountMethodSecurityPricing:
+			plan.AddType(defenderCollector.PlanResourceType(def, subscriptionIDs))
+		}
+	}
+	if p.config.IncludeIdentity {
+		for _, planned := range planIdentities() {
+			plan.AddType(planned)
+		}
+	}
+	return plan, nil
+}
+
+func (p *AzureProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
+	ctx = logging.NewContext(ctx, p.config.Log())
+
+	p.config.Log().Info("Counting Azure resources...")
+
+	p.mu.RLock()
+	subscriptions := make([]models.AccountCount, len(p.subscriptions))
+	copy(subscriptions, p.subscriptions)
+	graphClient := p.resourceGraphClient
+	defenderCollector := NewDefenderCollector(p.credential, p.clientOptions())
+	registryCollector := NewRegistryCollector(p.credential, p.clientOptions())
+	storageCollector := NewStorageCollector(p.credential, p.clientOptions())
+	vaultCollector := NewVaultCollector(p.credential, p.clientOptions())
+	authMethod := p.authMethod
+	tenantID := p.tenantID
+	discovered := p.discoveredSubscriptions
+	excluded := p.excludedSubscriptions
+	managementGroupsWarning := p.managementGroupsWarning
+	p.mu.RUnlock()
+
+	if len(subscriptions) == 0 {
 		return nil, fmt.Errorf("no subscriptions available to scan")
 	}
 
 	// Initialize result
 	result := &models.SizingResult{
-		Provider:  "Azure",
-		Timestamp: time.Now(),
+		SchemaVersion:    models.SchemaVersion,
+		Provider:         "Azure",
+		Timestamp:        time.Now(),
+		AuthMethod:       authMethod,
+		ExcludedAccounts: excluded,
+	}
+	if managementGroupsWarning != "" {
+		result.Warnings = append(result.Warnings, managementGroupsWarning)
 	}
-
-	// Create semaphore for concurrent operations
-	maxConcurrency := 5
-	semaphore := make(chan struct{}, maxConcurrency)
 
 	// Get resource types to count
 	resourceTypes := p.collector.GetResourceTypesToCount()
-	logging.Debug("Resource types to count", zap.Int("count", len(resourceTypes)))
+	p.config.Log().Debug("Resource types to count", zap.Int("count", len(resourceTypes)))
 
 	// Get subscription IDs
-	subscriptionIDs := make([]string, len(p.subscriptions))
-	for i, sub := range p.subscriptions {
+	subscriptionIDs := make([]string, len(subscriptions))
+	for i, sub := range subscriptions {
 		subscriptionIDs[i] = sub.ID
 	}
 
-	var wg sync.WaitGroup
-	resourceCounts := make([]*models.ResourceCount, 0)
-	resultsMu := sync.Mutex{}
+	// Count directory objects alongside the ARM scan so a slow or
+	// unauthorized Graph call never holds it up
+	var identityDone chan struct{}
+	var identityCounts []*models.ResourceCount
+	if p.config.IncludeIdentity {
+		identityDone = make(chan struct{})
+		go func() {
+			defer close(identityDone)
+			identityCtx, cancel := context.WithTimeout(ctx, identity.Timeout)
+			defer cancel()
+
+			var warnings []string
+			identityCounts, warnings = NewIdentityCollector(p.credential, p.clientOptions()).CountIdentities(identityCtx)
+			result.Warnings = append(result.Warnings, warnings...)
+		}()
+	}
 
-	// Count Resource Graph types
-	for _, rt := range resourceTypes {
-		if !rt.UseResourceGraph {
-			continue
+	// Count each resource type with the API its definition names, retrying
+	// those that fail. The contents of storage accounts and the items of
+	// vaults are gathered apart, as lines of their own.
+	var storageMu sync.Mutex
+	var storageCounts []*models.ResourceCount
+	storageUnknown, storageAccounts := 0, 0
+	var vaultMu sync.Mutex
+	var vaultCounts []*models.ResourceCount
+	vaultUnknown, vaults := 0, 0
+	countType := func(ctx context.Context, resourceDef models.ResourceDefinition) (*models.ResourceCount, error) {
+		start := time.Now()
+		typeCtx, stats := metrics.StartTypeStats(ctx)
+		typeCtx, span := tracing.Start(typeCtx, "CountResourceType")
+		span.SetString("provider", metricsProvider)
+		span.SetString("resource_type", string(resourceDef.ResourceType()))
+		span.SetString("count_method", string(resourceDef.CountMethod))
+
+		var count *models.ResourceCount
+		var err error
+		switch resourceDef.CountMethod {
+		case models.CountMethodSecurityPricing:
+			count, err = defenderCollector.CountResourceType(typeCtx, resourceDef, subscriptionIDs)
+		default:
+			count, err = p.collector.CountResourceType(typeCtx, resourceDef, subscriptionIDs, graphClient)
 		}
-
-		// Launch goroutine for each resource type
-		wg.Add(1)
-		go func(resourceDef models.ResourceDefinition) {
-			defer wg.Done()
-
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// Count this resource type
-			count, err := p.collector.CountResourceType(ctx, resourceDef, subscriptionIDs, p.resourceGraphClient)
-			if err != nil {
-				logging.Error("Failed to count resource type",
-					zap.String("type", resourceDef.Type),
-					zap.Error(err))
-				return
+		span.End(err)
+		if err != nil {
+			return nil, classifyError(err, countMethodActions[resourceDef.CountMethod])
+		}
+		if p.config.DeepRegistries && resourceDef.Type == registryType {
+			p.countRepositories(typeCtx, registryCollector, count, resourceDef, subscriptionIDs, graphClient)
+		}
+		if p.config.DeepStorage && resourceDef.Type == storageAccountType {
+			contents := p.countStorageContents(typeCtx, storageCollector, count, resourceDef, subscriptionIDs, graphClient)
+			for _, content := range contents {
+				p.config.StreamCount(content)
 			}
-
-			// Store result
-			resultsMu.Lock()
-			resourceCounts = append(resourceCounts, count)
-			resultsMu.Unlock()
-		}(rt)
+			storageMu.Lock()
+			storageCounts = append(storageCounts, contents...)
+			storageUnknown += count.ContentUnknown
+			storageAccounts += count.TotalResources
+			storageMu.Unlock()
+		}
+		if p.config.DeepBackup && resourceDef.Type == vaultType {
+			items := p.countVaultItems(typeCtx, vaultCollector, count, resourceDef, subscriptionIDs, graphClient)
+			for _, item := range items {
+				p.config.StreamCount(item)
+			}
+			vaultMu.Lock()
+			vaultCounts = append(vaultCounts, items...)
+			vaultUnknown += count.ContentUnknown
+			vaults += count.TotalResources
+			vaultMu.Unlock()
+		}
+		metrics.ResourceTypeScanned(metricsProvider, string(count.Type), time.Since(start))
+		count.Stats = stats.Stats()
+		count.Category = resourceDef.Category
+		count.CanonicalType = resourceDef.CanonicalType()
+		count.SubsetOf = models.ResourceType(resourceDef.SubsetOf)
+		if resourceDef.CountMethod != models.CountMethodResourceGraph {
+			count.TagFilterNotApplied = len(p.config.TagFilters) > 0
+		}
+		if resourceDef.Noise && p.config.ExcludeNoise {
+			count.ExcludeAsNoise()
+		}
+		p.config.StreamCount(count)
+		return count, nil
 	}
 
-	// Wait for all goroutines to complete
-	wg.Wait()
+	// Types with a count method Azure does not support are skipped
+	var counted []models.ResourceDefinition
+	for _, rt := range resourceTypes {
+		switch rt.CountMethod {
+		case models.CountMethodResourceGraph, models.CountMethodSecurityPricing:
+			counted = append(counted, rt)
+		}
+	}
+	resourceCounts, failures := counting.Run(ctx, counted, countType,
+		counting.Options{Concurrency: typeConcurrency, Retries: p.config.RetryFailed, TypeTimeout: p.config.TypeTimeout})
+
+	if identityDone != nil {
+		<-identityDone
+		for _, count := range identityCounts {
+			count.TagFilterNotApplied = len(p.config.TagFilters) > 0
+			p.config.StreamCount(count)
+		}
+		resourceCounts = append(resourceCounts, identityCounts...)
+		if len(identityCounts) > 0 {
+			result.Directories = []models.AccountCount{tenantDirectory(tenantID, identityCounts)}
+		}
+	}
+	resourceCounts = append(resourceCounts, storageCounts...)
+	if warning := storageContentsWarning(storageUnknown, storageAccounts); warning != "" {
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	resourceCounts = append(resourceCounts, vaultCounts...)
+	if warning := vaultItemsWarning(vaultUnknown, vaults); warning != "" {
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	partial := failures.Partial()
+	if partial != nil && len(resourceCounts) == 0 {
+		return nil, fmt.Errorf("no resource types could be counted: %w", partial.Errs[0])
+	}
 
 	// Populate SizingResult
 	result.ResourceCounts = resourceCounts
-	result.AccountCounts = p.subscriptions // Already have this from Connect()
+	result.AccountCounts = subscriptions // Already have this from Connect()
 
 	// Calculate totals
-	for _, rc := range resourceCounts {
-		result.TotalResources += rc.TotalResources
+	result.TotalResources = models.Total(resourceCounts)
+	if p.config.ExcludeNoise {
+		result.RawTotalResources = models.RawTotal(resourceCounts)
 	}
-	result.TotalAccounts = len(p.subscriptions)
+	result.TotalAccounts = len(subscriptions)
+	result.AccountsDiscovered = max(discovered, len(subscriptions))
+	result.AccountsScanned = len(subscriptions)
 
-	logging.Info("Resource counting completed",
+	p.config.Log().Info("Resource counting completed",
 		zap.Int("total_resources", result.TotalResources),
 		zap.Int("resource_types_counted", len(resourceCounts)),
 		zap.Int("accounts", result.TotalAccounts))
 
+	if warning := models.TagFilterWarning(resourceCounts); warning != "" {
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+	if warning := models.TruncatedWarning(resourceCounts); warning != "" {
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+
+	if partial != nil {
+		result.Warnings = append(result.Warnings, partial.Error())
+		return result, partial
+	}
 	return result, nil
 }
 
 // Close closes any open connections
 func (p *AzureProvider) Close() error {
-	logging.Info("Closing Azure provider connections")
+	p.config.Log().Info("Closing Azure provider connections")
 	// Azure SDK clients don't require explicit closing
 	return nil
 }
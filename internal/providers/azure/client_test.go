@@ -0,0 +1,273 @@
+package azure
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+
+	"github.com/secrails/secrails-sizing-agent/internal/cache"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+func TestSubscriptionFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured []string
+		env        string
+		want       map[string]bool
+	}{
+		{
+			name:       "flag wins over environment",
+			configured: []string{"AAAA-1"},
+			env:        "bbbb-2",
+			want:       map[string]bool{"aaaa-1": true},
+		},
+		{
+			name: "comma-separated environment list",
+			env:  "aaaa-1, bbbb-2,,",
+			want: map[string]bool{"aaaa-1": true, "bbbb-2": true},
+		},
+		{
+			name: "single environment value",
+			env:  "aaaa-1",
+			want: map[string]bool{"aaaa-1": true},
+		},
+		{
+			name: "nothing configured scans everything",
+			want: map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				if key == "AZURE_SUBSCRIPTION_ID" {
+					return tt.env
+				}
+				return ""
+			}
+			if got := subscriptionFilter(tt.configured, getenv); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subscriptionFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeCredential returns a fixed token or error
+type fakeCredential struct {
+	token string
+	err   error
+}
+
+func (f fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: f.token}, f.err
+}
+
+// jwt builds an unsigned token with the given JSON payload
+func jwt(payload string) string {
+	return "e30." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func TestTenantFromToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "tid claim", token: jwt(`{"tid":"tenant-a","oid":"x"}`), want: "tenant-a"},
+		{name: "padded payload", token: "e30." + base64.URLEncoding.EncodeToString([]byte(`{"tid":"tenant-b"}`)) + ".sig", want: "tenant-b"},
+		{name: "no tid claim", token: jwt(`{"oid":"x"}`), wantErr: true},
+		{name: "not a JWT", token: "opaque-token", wantErr: true},
+		{name: "invalid payload", token: "e30.!!!.sig", wantErr: true},
+		{name: "payload not JSON", token: jwt("tid"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tenantFromToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tenantFromToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("tenantFromToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyCredentials(t *testing.T) {
+	tests := []struct {
+		name       string
+		credential azcore.TokenCredential
+		pinned     string
+		want       string
+		wantErr    bool
+	}{
+		{name: "tenant from token", credential: fakeCredential{token: jwt(`{"tid":"tenant-a"}`)}, want: "tenant-a"},
+		{name: "pinned tenant matches", credential: fakeCredential{token: jwt(`{"tid":"TENANT-A"}`)}, pinned: "tenant-a", want: "TENANT-A"},
+		{name: "pinned tenant differs", credential: fakeCredential{token: jwt(`{"tid":"tenant-b"}`)}, pinned: "tenant-a", wantErr: true},
+		{name: "no token", credential: authErrorCredential{fakeCredential{err: errors.New("az login required")}}, wantErr: true},
+		{name: "token without tenant", credential: fakeCredential{token: "opaque-token"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &AzureProvider{config: config.ProviderConfig{TenantID: tt.pinned}, credential: tt.credential}
+			err := p.verifyCredentials(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var authErr *sizingerrors.AuthError
+				if !errors.As(err, &authErr) {
+					t.Errorf("verifyCredentials() error = %v, want an AuthError", err)
+				}
+				return
+			}
+			if p.tenantID != tt.want {
+				t.Errorf("tenantID = %q, want %q", p.tenantID, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	definitions := []models.ResourceDefinition{
+		{Type: "microsoft.compute/virtualmachines", DisplayName: "Virtual Machines", CountMethod: models.CountMethodResourceGraph},
+		{Type: "microsoft.security/pricings", DisplayName: "Defender Plans", CountMethod: models.CountMethodSecurityPricing},
+		{Type: "microsoft.web/sites", DisplayName: "Bad Filter", CountMethod: models.CountMethodResourceGraph, KqlFilter: `kind == "a" | take 1`},
+	}
+	p := &AzureProvider{
+		config:        config.ProviderConfig{IncludeIdentity: true},
+		collector:     NewResourceCollector(definitions, nil, nil, false),
+		subscriptions: []models.AccountCount{{ID: "sub-1"}, {ID: "sub-2"}},
+	}
+
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	// VMs, Defender plans and the four identity collections
+	if len(plan.ResourceTypes) != 6 {
+		t.Fatalf("plan has %d types, want 6", len(plan.ResourceTypes))
+	}
+	vms := plan.ResourceTypes[0]
+	if vms.API != "Resource Graph table Resources" || !strings.HasPrefix(vms.Query, "Resources\n") {
+		t.Errorf("planned VMs = %+v", vms)
+	}
+	// One query, a pricings call per subscription and four Graph counts
+	if plan.EstimatedAPICalls != 7 {
+		t.Errorf("EstimatedAPICalls = %d, want 7", plan.EstimatedAPICalls)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "microsoft.web/sites") {
+		t.Errorf("Warnings = %q", plan.Warnings)
+	}
+
+	p.subscriptions = nil
+	if _, err := p.Plan(); err == nil {
+		t.Error("Plan() without subscriptions succeeded")
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	p := &AzureProvider{
+		tenantID:      "tenant-1",
+		subscriptions: []models.AccountCount{{ID: "sub-1", Name: "Production", Status: "Enabled"}, {ID: "sub-2", Status: "Warned"}},
+	}
+
+	list := p.Accounts()
+	if list.Provider != "Azure" || list.Source != models.AccountSourceSubscriptions || list.TenantID != "tenant-1" {
+		t.Errorf("Accounts() = %+v", list)
+	}
+	if len(list.Accounts) != 2 || list.Accounts[1].Status != "Warned" {
+		t.Errorf("Accounts() listed %+v", list.Accounts)
+	}
+}
+
+func TestSubscriptionAccount(t *testing.T) {
+	state := armsubscriptions.SubscriptionStateWarned
+	sub := &armsubscriptions.Subscription{
+		SubscriptionID:       to.Ptr("sub-1"),
+		DisplayName:          to.Ptr("Production"),
+		State:                &state,
+		SubscriptionPolicies: &armsubscriptions.SubscriptionPolicies{QuotaID: to.Ptr("EnterpriseAgreement_2014-09-01")},
+		Tags:                 map[string]*string{"environment": to.Ptr("prod"), "empty": nil},
+	}
+
+	got := subscriptionAccount(sub)
+	want := models.AccountCount{
+		ID: "sub-1", Name: "Production", Status: "Warned", Offer: "EnterpriseAgreement_2014-09-01",
+		Tags: map[string]string{"environment": "prod", "empty": ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("subscriptionAccount() = %+v, want %+v", got, want)
+	}
+
+	// Without policies or tags, only the identity and state are set
+	if got := subscriptionAccount(&armsubscriptions.Subscription{SubscriptionID: to.Ptr("sub-2")}); got.Offer != "" || got.Tags != nil {
+		t.Errorf("subscriptionAccount() = %+v", got)
+	}
+}
+
+// cachedProvider returns a provider whose subscription discovery loads
+// subscriptions from the cache
+func cachedProvider(t *testing.T, cfg config.ProviderConfig, subscriptions []models.AccountCount) *AzureProvider {
+	t.Helper()
+	cfg.Cache = cache.New(t.TempDir(), time.Hour)
+	if err := cfg.Cache.Store("azure-tenant-1", &cache.Discovery{Accounts: cache.FromAccounts(subscriptions)}); err != nil {
+		t.Fatal(err)
+	}
+	return &AzureProvider{config: cfg, tenantID: "tenant-1"}
+}
+
+func TestDiscoverSubscriptionsAccountFilter(t *testing.T) {
+	subscriptions := []models.AccountCount{
+		{ID: "aaaa-1", Name: "Prod-Payments", Tags: map[string]string{"Env": "prod"}},
+		{ID: "bbbb-2", Name: "prod-sandbox", Tags: map[string]string{"env": "sandbox"}},
+		{ID: "cccc-3", Name: "prod-data", Tags: map[string]string{"env": "prod"}},
+		{ID: "dddd-4", Name: "dev-payments", Tags: map[string]string{"env": "prod"}},
+	}
+	p := cachedProvider(t, config.ProviderConfig{
+		SubscriptionIDs: []string{"aaaa-1", "bbbb-2", "dddd-4"},
+		AccountFilter: models.AccountFilter{
+			Names: []string{"prod-*"},
+			Tags:  []models.TagFilter{{Key: "env", Value: "prod"}},
+		},
+	}, subscriptions)
+
+	if err := p.discoverSubscriptions(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.subscriptions) != 1 || p.subscriptions[0].ID != "aaaa-1" {
+		t.Errorf("subscriptions = %+v, want aaaa-1 alone", p.subscriptions)
+	}
+	var excluded []string
+	for _, sub := range p.excludedSubscriptions {
+		excluded = append(excluded, sub.ID)
+	}
+	if want := []string{"bbbb-2", "dddd-4"}; !reflect.DeepEqual(excluded, want) {
+		t.Errorf("excluded = %v, want %v", excluded, want)
+	}
+}
+
+func TestDiscoverSubscriptionsAccountFilterExcludesAll(t *testing.T) {
+	p := cachedProvider(t, config.ProviderConfig{
+		AccountFilter: models.AccountFilter{Names: []string{"prod-*"}},
+	}, []models.AccountCount{{ID: "aaaa-1", Name: "dev"}})
+
+	if err := p.discoverSubscriptions(context.Background()); !errors.Is(err, models.ErrAccountsExcluded) {
+		t.Errorf("discoverSubscriptions() = %v, want ErrAccountsExcluded", err)
+	}
+}
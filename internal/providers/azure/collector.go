@@ -3,61 +3,216 @@ package azure
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
 	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
 	"github.com/secrails/secrails-sizing-agent/pkg/logging"
 	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 )
 
+// resourceGraphQuerier is the subset of the Resource Graph client used by the
+// collector
+type resourceGraphQuerier interface {
+	Resources(
+		ctx context.Context,
+		query armresourcegraph.QueryRequest,
+		options *armresourcegraph.ClientResourcesOptions,
+	) (armresourcegraph.ClientResourcesResponse, error)
+}
+
+// Resource Graph allows 15 requests per 5 seconds per user; inventory mode
+// paces itself below that since it pages through every resource
+const (
+	inventoryRequestsPerSecond = 2
+	inventoryPageSize          = 1000
+)
+
+// MaxPageSize is the largest page of rows Resource Graph returns
+const MaxPageSize = 1000
+
+// defaultMaxPages is the safety limit on the pages of a count query, whose
+// rows are already summarized and rarely fill a second page
+const defaultMaxPages = 10
+
 type ResourceCollector struct {
+	definitions []models.ResourceDefinition
+
+	// inventory receives every resource seen when inventory mode is enabled
+	inventory models.ResourceSink
+	limiter   *rate.Limiter
+
+	// tagFilters are appended to every query
+	tagFilters []models.TagFilter
+
+	// excludeNoise leaves the rows matching a definition's noise filter
+	// out of the counts, counting them as NoiseExcluded instead
+	excludeNoise bool
+
+	// pageSize is the number of rows asked for per page, Resource Graph's
+	// default when 0, and maxPages the pages of a count query read before
+	// the count stops as truncated, defaultMaxPages when 0
+	pageSize int32
+	maxPages int
 }
 
+// NewResourceCollector creates a collector for the given definitions. If
+// inventory is non-nil every resource found is also written to it. Only
+// resources carrying all tagFilters are counted, and with excludeNoise only
+// those not matching their definition's noise filter.
+func NewResourceCollector(definitions []models.ResourceDefinition, inventory models.ResourceSink,
+	tagFilters []models.TagFilter, excludeNoise bool) *ResourceCollector {
+	c := &ResourceCollector{
+		definitions:  definitions,
+		inventory:    inventory,
+		tagFilters:   tagFilters,
+		excludeNoise: excludeNoise,
+	}
+	if inventory != nil {
+		c.limiter = rate.NewLimiter(rate.Limit(inventoryRequestsPerSecond), 1)
+	}
+	return c
+}
+
+// GetResourceTypesToCount returns the resource definitions this collector counts
 func (c *ResourceCollector) GetResourceTypesToCount() []models.ResourceDefinition {
-	return []models.ResourceDefinition{
-		{Type: "microsoft.containerservice/managedclusters", DisplayName: "AKS Clusters", Category: "Containers", UseResourceGraph: true},
-		{Type: "microsoft.apimanagement/service", DisplayName: "API Management", Category: "Developer Tools", UseResourceGraph: true},
-		{Type: "microsoft.web/sites", DisplayName: "App Services", Category: "Compute", UseResourceGraph: true},
-		{Type: "microsoft.network/applicationgateways", DisplayName: "Application Gateways", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.insights/components", DisplayName: "Application Insights", Category: "Analytics", UseResourceGraph: true},
-		{Type: "microsoft.automation/automationaccounts", DisplayName: "Automation Accounts", Category: "Developer Tools", UseResourceGraph: true},
-		{Type: "microsoft.network/azurefirewalls", DisplayName: "Azure Firewalls", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.recoveryservices/vaults/backuppolicies", DisplayName: "Backup Policies", Category: "Storage", UseResourceGraph: true},
-		{Type: "microsoft.network/bastionhosts", DisplayName: "Bastion Hosts", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.cognitiveservices/accounts", DisplayName: "Cognitive Services", Category: "Machine Learning", UseResourceGraph: true},
-		{Type: "microsoft.network/connections", DisplayName: "Connections", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.containerinstance/containergroups", DisplayName: "Container Instances", Category: "Containers", UseResourceGraph: true},
-		{Type: "microsoft.containerregistry/registries", DisplayName: "Container Registries", Category: "Containers", UseResourceGraph: true},
-		{Type: "microsoft.documentdb/databaseaccounts", DisplayName: "CosmosDB Accounts", Category: "Databases", UseResourceGraph: true},
-		{Type: "microsoft.datafactory/factories", DisplayName: "Data Factories", Category: "Analytics", UseResourceGraph: true},
-		{Type: "microsoft.datalakestore/accounts", DisplayName: "Data Lake Store Accounts", Category: "Storage", UseResourceGraph: true},
-		{Type: "microsoft.visualstudio/account/project", DisplayName: "DevOps Projects", Category: "Developer Tools", UseResourceGraph: true},
-		{Type: "microsoft.eventgrid/topics", DisplayName: "Event Grid Topics", Category: "Developer Tools", UseResourceGraph: true},
-		{Type: "microsoft.eventhub/namespaces", DisplayName: "Event Hub Namespaces", Category: "Analytics", UseResourceGraph: true},
-		{Type: "microsoft.hdinsight/clusters", DisplayName: "HDInsight Clusters", Category: "Analytics", UseResourceGraph: true},
-		{Type: "microsoft.keyvault/vaults", DisplayName: "Key Vaults", Category: "Security", UseResourceGraph: true},
-		{Type: "microsoft.network/loadbalancers", DisplayName: "Load Balancers", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.network/localnetworkgateways", DisplayName: "Local Network Gateways", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.machinelearningservices/workspaces", DisplayName: "Machine Learning Workspaces", Category: "Machine Learning", UseResourceGraph: true},
-		{Type: "microsoft.cache/redisenterprise", DisplayName: "Managed Redis Cache", Category: "Databases", UseResourceGraph: true},
-		{Type: "microsoft.dbformariadb/servers", DisplayName: "MariaDB Servers", Category: "Databases", UseResourceGraph: true},
-		{Type: "microsoft.dbformysql/flexibleservers", DisplayName: "MySQL Servers", Category: "Databases", UseResourceGraph: true},
-		{Type: "microsoft.network/networkinterfaces", DisplayName: "Network Interfaces", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.network/networkwatchers", DisplayName: "Network Watchers", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.dbforpostgresql/flexibleservers", DisplayName: "PostgreSQL Servers", Category: "Databases", UseResourceGraph: true},
-		{Type: "microsoft.network/privateendpoints", DisplayName: "Private Endpoints", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.network/publicipaddresses", DisplayName: "Public IP Addresses", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.recoveryservices/vaults", DisplayName: "Recovery Services Vaults", Category: "Storage", UseResourceGraph: true},
-		{Type: "microsoft.cache/redis", DisplayName: "Redis Cache", Category: "Databases", UseResourceGraph: true},
-		{Type: "microsoft.network/routetables", DisplayName: "Route Tables", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.sql/servers/databases", DisplayName: "SQL Databases", Category: "Databases", UseResourceGraph: true},
-		{Type: "microsoft.sql/servers", DisplayName: "SQL Servers", Category: "Databases", UseResourceGraph: true},
-		{Type: "microsoft.storage/storageaccounts", DisplayName: "Storage Accounts", Category: "Storage", UseResourceGraph: true},
-		{Type: "microsoft.compute/virtualmachines", DisplayName: "Virtual Machines", Category: "Compute", UseResourceGraph: true},
-		{Type: "microsoft.network/virtualnetworks", DisplayName: "Virtual Networks", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.network/networksecuritygroups", DisplayName: "Network Security Groups", Category: "Networking", UseResourceGraph: true},
-		{Type: "microsoft.network/vpngateways", DisplayName: "VPN Gateways", Category: "Networking", UseResourceGraph: true},
+	return c.definitions
+}
+
+// baseQuery selects the rows of a resource type from its Resource Graph table,
+// applying the definition's extra filter and the tag filters if any
+func baseQuery(resourceDef models.ResourceDefinition, tagFilters []models.TagFilter) *kqlQuery {
+	table := resourceDef.GraphTable
+	if table == "" {
+		table = models.DefaultGraphTable
+	}
+
+	query := newQuery(table, resourceDef.Type)
+	if resourceDef.KqlFilter != "" {
+		query.where(resourceDef.KqlFilter)
 	}
+	for _, filter := range tagFilters {
+		query.whereTag(filter.Key, filter.Value)
+	}
+	return query
+}
+
+// listRows runs queryText over subscriptions and returns every row, paging
+// through the whole result
+func listRows(
+	ctx context.Context,
+	queryText string,
+	subscriptions []string,
+	graphClient resourceGraphQuerier,
+) ([]map[string]interface{}, error) {
+
+	subIDs := make([]*string, len(subscriptions))
+	for i, sub := range subscriptions {
+		subIDs[i] = to.Ptr(sub)
+	}
+
+	var rows []map[string]interface{}
+	var skipToken *string
+	for {
+		resultFormat := armresourcegraph.ResultFormatObjectArray
+		response, err := graphClient.Resources(ctx, armresourcegraph.QueryRequest{
+			Subscriptions: subIDs,
+			Query:         &queryText,
+			Options: &armresourcegraph.QueryRequestOptions{
+				ResultFormat: &resultFormat,
+				SkipToken:    skipToken,
+				Top:          to.Ptr[int32](inventoryPageSize),
+			},
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, ok := response.Data.([]interface{}); ok {
+			for _, item := range data {
+				if row, ok := item.(map[string]interface{}); ok {
+					rows = append(rows, row)
+				}
+			}
+		}
+
+		if response.SkipToken == nil || *response.SkipToken == "" {
+			return rows, nil
+		}
+		skipToken = response.SkipToken
+	}
+}
+
+// countQuery summarizes a resource type by location and subscription, plus
+// state and SKU when the definition opts in and whether the row is noise with
+// excludeNoise. Rows are counted unless the definition names a field to sum.
+func countQuery(resourceDef models.ResourceDefinition, tagFilters []models.TagFilter, excludeNoise bool) (string, error) {
+	query := baseQuery(resourceDef, tagFilters)
+	groupBy := []string{"location", "subscriptionId"}
+
+	if excludeNoise && resourceDef.NoiseFilter != "" {
+		query.extendCondition("noise", resourceDef.NoiseFilter)
+		groupBy = append(groupBy, "noise")
+	}
+
+	if resourceDef.StateField != "" {
+		query.extend("state", resourceDef.StateField)
+		groupBy = append(groupBy, "state")
+	}
+	if resourceDef.SKUField != "" {
+		query.extend("sku", resourceDef.SKUField)
+		groupBy = append(groupBy, "sku")
+	}
+	if resourceDef.PublicField != "" {
+		query.extendExposure("exposure", resourceDef.PublicField)
+		groupBy = append(groupBy, "exposure")
+	}
+
+	if resourceDef.SumField != "" {
+		return query.sumBy(resourceDef.SumField, groupBy...).build()
+	}
+	return query.countBy(groupBy...).build()
+}
+
+// normalizeState reduces values such as "PowerState/running" to "running"
+func normalizeState(value interface{}) string {
+	state := strings.ToLower(normalizeValue(value))
+	if i := strings.LastIndex(state, "/"); i >= 0 {
+		state = state[i+1:]
+	}
+	return state
+}
+
+// normalizeValue returns a breakdown key, using "unknown" for missing values
+func normalizeValue(value interface{}) string {
+	if s, ok := value.(string); ok && s != "" {
+		return s
+	}
+	return "unknown"
+}
+
+// PlanResourceType describes how CountResourceType counts resourceDef: one
+// Resource Graph query across all subscriptions
+func (c *ResourceCollector) PlanResourceType(resourceDef models.ResourceDefinition) (models.PlannedType, error) {
+	query, err := countQuery(resourceDef, c.tagFilters, c.excludeNoise)
+	if err != nil {
+		return models.PlannedType{}, fmt.Errorf("failed to build query for %s: %w", resourceDef.Type, err)
+	}
+	table := resourceDef.GraphTable
+	if table == "" {
+		table = models.DefaultGraphTable
+	}
+	return models.PlannedType{
+		Type:           resourceDef.ResourceType(),
+		DisplayName:    resourceDef.DisplayName,
+		CountMethod:    resourceDef.CountMethod,
+		API:            "Resource Graph table " + table,
+		Query:          query,
+		EstimatedCalls: 1,
+	}, nil
 }
 
 // CountResourceType counts resources for a specific resource type
@@ -65,16 +220,18 @@ func (c *ResourceCollector) CountResourceType(
 	ctx context.Context,
 	resourceDef models.ResourceDefinition,
 	subscriptions []string,
-	graphClient *armresourcegraph.Client,
+	graphClient resourceGraphQuerier,
 ) (*models.ResourceCount, error) {
 
+	if c.inventory != nil {
+		return c.inventoryResourceType(ctx, resourceDef, subscriptions, graphClient)
+	}
+
 	// Build query for this specific resource type
-	query := fmt.Sprintf(`
-		Resources
-		| where type =~ "%s"
-		| summarize count() by location, subscriptionId
-		| project location, subscriptionId, count = count_
-	`, resourceDef.Type)
+	query, err := countQuery(resourceDef, c.tagFilters, c.excludeNoise)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query for %s: %w", resourceDef.Type, err)
+	}
 
 	// Prepare subscription IDs
 	subIDs := make([]*string, len(subscriptions))
@@ -86,16 +243,32 @@ func (c *ResourceCollector) CountResourceType(
 	// Initialize result
 	result := &models.ResourceCount{
 		Provider:    "Azure",
-		Type:        models.ResourceType(resourceDef.Type),
+		Type:        resourceDef.ResourceType(),
 		DisplayName: resourceDef.DisplayName,
 		ByLocation:  make(map[string]int),
 		ByAccount:   make(map[string]int),
 	}
+	if resourceDef.StateField != "" {
+		result.ByState = make(map[string]int)
+	}
+	if resourceDef.SKUField != "" {
+		result.BySKU = make(map[string]int)
+	}
+	if resourceDef.PublicField != "" {
+		result.ByExposure = make(map[string]int)
+	}
 
 	// Pagination loop
 	var skipToken *string
 	pageCount := 0
-	maxPages := 10 // Safety limit
+	maxPages := c.maxPages
+	if maxPages == 0 {
+		maxPages = defaultMaxPages
+	}
+	var top *int32
+	if c.pageSize > 0 {
+		top = to.Ptr(c.pageSize)
+	}
 
 	for {
 		// Create request with pagination
@@ -106,11 +279,16 @@ func (c *ResourceCollector) CountResourceType(
 			Options: &armresourcegraph.QueryRequestOptions{
 				ResultFormat: &resultFormat,
 				SkipToken:    skipToken,
+				Top:          top,
 			},
 		}
 
 		// Execute query
-		response, err := graphClient.Resources(ctx, request, nil)
+		pageCtx, span := tracing.Start(ctx, "QueryResourceGraph")
+		span.SetInt("page", pageCount+1)
+		span.SetInt("subscriptions", len(subscriptions))
+		response, err := graphClient.Resources(pageCtx, request, nil)
+		span.End(err)
 		if err != nil {
 			return nil, fmt.Errorf("failed to query %s (page %d): %w", resourceDef.Type, pageCount+1, err)
 		}
@@ -135,6 +313,11 @@ func (c *ResourceCollector) CountResourceType(
 							count = int(v)
 						}
 
+						if noise, _ := row["noise"].(bool); noise {
+							result.NoiseExcluded += count
+							continue
+						}
+
 						// Update counts
 						result.TotalResources += count
 						if location != "" {
@@ -143,6 +326,15 @@ func (c *ResourceCollector) CountResourceType(
 						if subscriptionId != "" {
 							result.ByAccount[subscriptionId] += count
 						}
+						if result.ByState != nil {
+							result.ByState[normalizeState(row["state"])] += count
+						}
+						if result.BySKU != nil {
+							result.BySKU[normalizeValue(row["sku"])] += count
+						}
+						if result.ByExposure != nil {
+							result.ByExposure[normalizeValue(row["exposure"])] += count
+						}
 					}
 				}
 			}
@@ -155,22 +347,163 @@ func (c *ResourceCollector) CountResourceType(
 			break
 		}
 		if pageCount >= maxPages {
-			logging.Warn("Reached max pages for resource type",
+			logging.FromContext(ctx).Info("Reached max pages for resource type",
 				zap.String("type", resourceDef.Type),
 				zap.Int("pages", maxPages))
+			result.Truncated = true
 			break
 		}
 
 		skipToken = response.SkipToken
-		logging.Debug("Fetching next page",
+		logging.FromContext(ctx).Debug("Fetching next page",
 			zap.String("type", resourceDef.Type),
 			zap.Int("page", pageCount+1))
 	}
 
-	logging.Debug("Completed counting",
+	logging.FromContext(ctx).Debug("Completed counting",
+		zap.String("type", resourceDef.Type),
+		zap.Int("total", result.TotalResources),
+		zap.Int("pages", pageCount))
+
+	return result, nil
+}
+
+// inventoryResourceType lists every resource of a type, writing each one to
+// the inventory and deriving the counts from the listed rows
+func (c *ResourceCollector) inventoryResourceType(
+	ctx context.Context,
+	resourceDef models.ResourceDefinition,
+	subscriptions []string,
+	graphClient resourceGraphQuerier,
+) (*models.ResourceCount, error) {
+
+	query := baseQuery(resourceDef, c.tagFilters)
+	columns := []string{"id", "name", "location", "subscriptionId", "tags"}
+	if c.excludeNoise && resourceDef.NoiseFilter != "" {
+		query.extendCondition("noise", resourceDef.NoiseFilter)
+		columns = append(columns, "noise")
+	}
+	queryText, err := query.project(columns...).build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query for %s: %w", resourceDef.Type, err)
+	}
+
+	subIDs := make([]*string, len(subscriptions))
+	for i, sub := range subscriptions {
+		subID := sub
+		subIDs[i] = &subID
+	}
+
+	result := &models.ResourceCount{
+		Provider:    "Azure",
+		Type:        resourceDef.ResourceType(),
+		DisplayName: resourceDef.DisplayName,
+		ByLocation:  make(map[string]int),
+		ByAccount:   make(map[string]int),
+	}
+
+	// No page limit here: the inventory must be complete
+	var skipToken *string
+	pageCount := 0
+	pageSize := c.pageSize
+	if pageSize == 0 {
+		pageSize = inventoryPageSize
+	}
+
+	for {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resultFormat := armresourcegraph.ResultFormatObjectArray
+		request := armresourcegraph.QueryRequest{
+			Subscriptions: subIDs,
+			Query:         &queryText,
+			Options: &armresourcegraph.QueryRequestOptions{
+				ResultFormat: &resultFormat,
+				SkipToken:    skipToken,
+				Top:          to.Ptr(pageSize),
+			},
+		}
+
+		pageCtx, span := tracing.Start(ctx, "QueryResourceGraph")
+		span.SetInt("page", pageCount+1)
+		span.SetInt("subscriptions", len(subscriptions))
+		response, err := graphClient.Resources(pageCtx, request, nil)
+		span.End(err)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s (page %d): %w", resourceDef.Type, pageCount+1, err)
+		}
+
+		if data, ok := response.Data.([]interface{}); ok {
+			for _, item := range data {
+				row, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if noise, _ := row["noise"].(bool); noise {
+					result.NoiseExcluded++
+					continue
+				}
+
+				resource := toResource(row, resourceDef)
+				if err := c.inventory.Write(resource); err != nil {
+					return nil, err
+				}
+
+				result.TotalResources++
+				if resource.Region != "" {
+					result.ByLocation[resource.Region]++
+				}
+				if resource.Account != "" {
+					result.ByAccount[resource.Account]++
+				}
+			}
+		}
+
+		pageCount++
+
+		if response.SkipToken == nil || *response.SkipToken == "" {
+			break
+		}
+		skipToken = response.SkipToken
+	}
+
+	logging.FromContext(ctx).Debug("Completed inventory",
 		zap.String("type", resourceDef.Type),
 		zap.Int("total", result.TotalResources),
 		zap.Int("pages", pageCount))
 
 	return result, nil
 }
+
+// toResource converts a Resource Graph row into an inventory resource
+func toResource(row map[string]interface{}, resourceDef models.ResourceDefinition) models.Resource {
+	resource := models.Resource{
+		Type:     resourceDef.ResourceType(),
+		Provider: "Azure",
+	}
+
+	if v, ok := row["id"].(string); ok {
+		resource.ID = v
+	}
+	if v, ok := row["name"].(string); ok {
+		resource.Name = v
+	}
+	if v, ok := row["location"].(string); ok {
+		resource.Region = v
+	}
+	if v, ok := row["subscriptionId"].(string); ok {
+		resource.Account = v
+	}
+	if tags, ok := row["tags"].(map[string]interface{}); ok && len(tags) > 0 {
+		resource.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			if s, ok := v.(string); ok {
+				resource.Tags[k] = s
+			}
+		}
+	}
+
+	return resource
+}
@@ -0,0 +1,657 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// fakeResourceGraph serves pre-canned responses keyed by skip token. The first
+// page is keyed by the empty string.
+type fakeResourceGraph struct {
+	pages map[string]armresourcegraph.ClientResourcesResponse
+	err   error
+
+	requests []armresourcegraph.QueryRequest
+}
+
+func (f *fakeResourceGraph) Resources(
+	_ context.Context,
+	query armresourcegraph.QueryRequest,
+	_ *armresourcegraph.ClientResourcesOptions,
+) (armresourcegraph.ClientResourcesResponse, error) {
+	f.requests = append(f.requests, query)
+	if f.err != nil {
+		return armresourcegraph.ClientResourcesResponse{}, f.err
+	}
+
+	token := ""
+	if query.Options != nil && query.Options.SkipToken != nil {
+		token = *query.Options.SkipToken
+	}
+	page, ok := f.pages[token]
+	if !ok {
+		return armresourcegraph.ClientResourcesResponse{}, errors.New("unexpected skip token " + token)
+	}
+	return page, nil
+}
+
+// graphPage builds a Resource Graph response from rows and a next skip token
+func graphPage(next string, rows ...interface{}) armresourcegraph.ClientResourcesResponse {
+	resp := armresourcegraph.ClientResourcesResponse{}
+	resp.Data = rows
+	if next != "" {
+		resp.SkipToken = &next
+	}
+	return resp
+}
+
+func row(location, subscriptionID string, count float64) map[string]interface{} {
+	return map[string]interface{}{
+		"location":       location,
+		"subscriptionId": subscriptionID,
+		"count":          count,
+	}
+}
+
+func TestCountResourceType(t *testing.T) {
+	tests := []struct {
+		name          string
+		graph         *fakeResourceGraph
+		wantTotal     int
+		wantByLoc     map[string]int
+		wantByAccount map[string]int
+		wantRequests  int
+		wantErr       bool
+	}{
+		{
+			name: "single page",
+			graph: &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+				"": graphPage("", row("eastus", "sub-1", 3), row("westeurope", "sub-2", 2)),
+			}},
+			wantTotal:     5,
+			wantByLoc:     map[string]int{"eastus": 3, "westeurope": 2},
+			wantByAccount: map[string]int{"sub-1": 3, "sub-2": 2},
+			wantRequests:  1,
+		},
+		{
+			name: "skip token pagination",
+			graph: &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+				"":   graphPage("t2", row("eastus", "sub-1", 3)),
+				"t2": graphPage("t3", row("eastus", "sub-2", 1)),
+				"t3": graphPage("", row("westus", "sub-1", 4)),
+			}},
+			wantTotal:     8,
+			wantByLoc:     map[string]int{"eastus": 4, "westus": 4},
+			wantByAccount: map[string]int{"sub-1": 7, "sub-2": 1},
+			wantRequests:  3,
+		},
+		{
+			name: "malformed rows are ignored",
+			graph: &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+				"": graphPage("",
+					"not a row",
+					map[string]interface{}{"location": 42, "subscriptionId": "sub-1", "count": 2.0},
+					map[string]interface{}{"location": "eastus", "subscriptionId": "sub-1", "count": "seven"},
+					row("eastus", "sub-1", 1),
+				),
+			}},
+			wantTotal:     3,
+			wantByLoc:     map[string]int{"eastus": 1},
+			wantByAccount: map[string]int{"sub-1": 3},
+			wantRequests:  1,
+		},
+		{
+			name: "empty response",
+			graph: &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+				"": graphPage(""),
+			}},
+			wantTotal:     0,
+			wantByLoc:     map[string]int{},
+			wantByAccount: map[string]int{},
+			wantRequests:  1,
+		},
+		{
+			name: "nil data",
+			graph: &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+				"": {},
+			}},
+			wantTotal:     0,
+			wantByLoc:     map[string]int{},
+			wantByAccount: map[string]int{},
+			wantRequests:  1,
+		},
+		{
+			name:         "throttling error",
+			graph:        &fakeResourceGraph{err: errors.New("RateLimiting: too many requests")},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+		{
+			name: "error on later page",
+			graph: &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+				"": graphPage("missing", row("eastus", "sub-1", 3)),
+			}},
+			wantRequests: 2,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &ResourceCollector{}
+			def := models.ResourceDefinition{Type: "microsoft.compute/virtualmachines", DisplayName: "Virtual Machines"}
+
+			got, err := collector.CountResourceType(context.Background(), def, []string{"sub-1", "sub-2"}, tt.graph)
+			if len(tt.graph.requests) != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", len(tt.graph.requests), tt.wantRequests)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CountResourceType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.TotalResources != tt.wantTotal {
+				t.Errorf("TotalResources = %d, want %d", got.TotalResources, tt.wantTotal)
+			}
+			assertCounts(t, "ByLocation", got.ByLocation, tt.wantByLoc)
+			assertCounts(t, "ByAccount", got.ByAccount, tt.wantByAccount)
+		})
+	}
+}
+
+func TestCountResourceTypeMaxPages(t *testing.T) {
+	// Every page points at itself, so only the page limit stops the loop
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"":     graphPage("loop", row("eastus", "sub-1", 1)),
+		"loop": graphPage("loop", row("eastus", "sub-1", 1)),
+	}}
+
+	collector := &ResourceCollector{}
+	got, err := collector.CountResourceType(context.Background(),
+		models.ResourceDefinition{Type: "microsoft.storage/storageaccounts"}, []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(graph.requests) != 10 {
+		t.Errorf("requests = %d, want 10", len(graph.requests))
+	}
+	if got.TotalResources != 10 || !got.Truncated {
+		t.Errorf("TotalResources = %d, truncated = %v, want 10 truncated", got.TotalResources, got.Truncated)
+	}
+}
+
+// pagedResourceGraph serves one row per resource in pages of the requested
+// size, with the offset of the next page as its skip token
+type pagedResourceGraph struct {
+	total int
+	tops  []int32
+}
+
+func (f *pagedResourceGraph) Resources(
+	_ context.Context,
+	query armresourcegraph.QueryRequest,
+	_ *armresourcegraph.ClientResourcesOptions,
+) (armresourcegraph.ClientResourcesResponse, error) {
+	size := int32(100)
+	if query.Options.Top != nil {
+		size = *query.Options.Top
+	}
+	f.tops = append(f.tops, size)
+	offset := 0
+	if query.Options.SkipToken != nil {
+		offset, _ = strconv.Atoi(*query.Options.SkipToken)
+	}
+	n := min(int(size), f.total-offset)
+	rows := make([]interface{}, n)
+	for i := range rows {
+		rows[i] = row("eastus", "sub-1", 1)
+	}
+	next := ""
+	if offset+n < f.total {
+		next = strconv.Itoa(offset + n)
+	}
+	return graphPage(next, rows...), nil
+}
+
+func TestCountResourceTypePageSizes(t *testing.T) {
+	tests := []struct {
+		pageSize      int32
+		maxPages      int
+		wantTotal     int
+		wantPages     int
+		wantTruncated bool
+	}{
+		{pageSize: 0, wantTotal: 450, wantPages: 5},
+		{pageSize: 1000, wantTotal: 450, wantPages: 1},
+		{pageSize: 200, wantTotal: 450, wantPages: 3},
+		{pageSize: 40, wantTotal: 400, wantPages: 10, wantTruncated: true},
+		{pageSize: 40, maxPages: 20, wantTotal: 450, wantPages: 12},
+		{pageSize: 200, maxPages: 1, wantTotal: 200, wantPages: 1, wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("size %d max %d", tt.pageSize, tt.maxPages), func(t *testing.T) {
+			graph := &pagedResourceGraph{total: 450}
+			collector := &ResourceCollector{pageSize: tt.pageSize, maxPages: tt.maxPages}
+
+			got, err := collector.CountResourceType(context.Background(),
+				models.ResourceDefinition{Type: "microsoft.storage/storageaccounts"}, []string{"sub-1"}, graph)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.TotalResources != tt.wantTotal || got.Truncated != tt.wantTruncated {
+				t.Errorf("total = %d, truncated = %v, want %d, %v", got.TotalResources, got.Truncated, tt.wantTotal, tt.wantTruncated)
+			}
+			if len(graph.tops) != tt.wantPages {
+				t.Errorf("pages = %d, want %d", len(graph.tops), tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestCountResourceTypeRequest(t *testing.T) {
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"": graphPage(""),
+	}}
+
+	collector := &ResourceCollector{}
+	if _, err := collector.CountResourceType(context.Background(),
+		models.ResourceDefinition{Type: "microsoft.keyvault/vaults"}, []string{"sub-1", "sub-2"}, graph); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := graph.requests[0]
+	if len(req.Subscriptions) != 2 || *req.Subscriptions[0] != "sub-1" || *req.Subscriptions[1] != "sub-2" {
+		t.Errorf("unexpected subscriptions in request: %v", req.Subscriptions)
+	}
+	if req.Options == nil || req.Options.ResultFormat == nil ||
+		*req.Options.ResultFormat != armresourcegraph.ResultFormatObjectArray {
+		t.Errorf("expected object array result format")
+	}
+}
+
+func assertCounts(t *testing.T, field string, got, want map[string]int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %v", field, got, want)
+		return
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s[%s] = %d, want %d", field, k, got[k], v)
+		}
+	}
+}
+
+func TestBaseQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		def      models.ResourceDefinition
+		tags     []models.TagFilter
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "default table",
+			def:      models.ResourceDefinition{Type: "microsoft.compute/virtualmachines"},
+			contains: []string{"Resources", `type =~ "microsoft.compute/virtualmachines"`},
+			excludes: []string{"AuthorizationResources"},
+		},
+		{
+			name: "custom table and filter",
+			def: models.ResourceDefinition{
+				Type:       "microsoft.authorization/roledefinitions",
+				GraphTable: "AuthorizationResources",
+				KqlFilter:  `properties.type == "CustomRole"`,
+			},
+			contains: []string{"AuthorizationResources", `| where properties.type == "CustomRole"`},
+		},
+		{
+			// =~ is a whole-string match, so nested types never match their parent
+			name:     "nested type",
+			def:      models.ResourceDefinition{Type: "microsoft.cdn/profiles/afdendpoints"},
+			contains: []string{`type =~ "microsoft.cdn/profiles/afdendpoints"`},
+			excludes: []string{"contains", "startswith"},
+		},
+		{
+			name: "tag filters",
+			def:  models.ResourceDefinition{Type: "microsoft.compute/virtualmachines"},
+			tags: []models.TagFilter{{Key: "env", Value: "prod"}, {Key: "team", Value: "Platform"}},
+			contains: []string{
+				`| where tostring(tags["env"]) =~ "prod"`,
+				`| where tostring(tags["team"]) =~ "Platform"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := baseQuery(tt.def, tt.tags).build()
+			if err != nil {
+				t.Fatalf("baseQuery() error = %v", err)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(query, want) {
+					t.Errorf("query %q does not contain %q", query, want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(query, unwanted) {
+					t.Errorf("query %q unexpectedly contains %q", query, unwanted)
+				}
+			}
+		})
+	}
+}
+
+// definitionsFor returns the built-in Azure definitions whose type matches
+func definitionsFor(t *testing.T, resourceType string) []models.ResourceDefinition {
+	t.Helper()
+	set, err := models.DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defs []models.ResourceDefinition
+	for _, def := range set.ForProvider("azure") {
+		if def.Type == resourceType {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+func site(kind string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":           "Microsoft.Web/sites",
+		"kind":           kind,
+		"location":       "eastus",
+		"subscriptionId": "sub-1",
+	}
+}
+
+func TestWebSitesPartition(t *testing.T) {
+	graph := &fixtureGraph{tables: map[string][]map[string]interface{}{
+		"resources": {
+			site("app"),
+			site("app,linux"),
+			site("app,linux,container"),
+			site("functionapp"),
+			site("functionapp,linux"),
+			site("functionapp,linux,container"),
+			site("functionapp,workflowapp"),
+			site("functionapp,linux,container,workflowapp"),
+		},
+	}}
+
+	want := map[models.ResourceType]int{
+		"microsoft.web/sites":             3,
+		"microsoft.web/sites#functionapp": 3,
+		"microsoft.web/sites#workflowapp": 2,
+	}
+
+	defs := definitionsFor(t, "microsoft.web/sites")
+	if len(defs) != len(want) {
+		t.Fatalf("expected %d microsoft.web/sites definitions, got %d", len(want), len(defs))
+	}
+
+	collector := &ResourceCollector{}
+	total := 0
+	for _, def := range defs {
+		got, err := collector.CountResourceType(context.Background(), def, []string{"sub-1"}, graph)
+		if err != nil {
+			t.Fatalf("CountResourceType(%s) error = %v", def.ResourceType(), err)
+		}
+		if got.TotalResources != want[got.Type] {
+			t.Errorf("%s = %d, want %d", got.Type, got.TotalResources, want[got.Type])
+		}
+		total += got.TotalResources
+	}
+
+	// The split lines must add up to the old combined count
+	if total != len(graph.tables["resources"]) {
+		t.Errorf("partition total = %d, want %d", total, len(graph.tables["resources"]))
+	}
+}
+
+func TestScaleSetPartition(t *testing.T) {
+	scaleSet := func(name, mode string, capacity float64) map[string]interface{} {
+		return map[string]interface{}{
+			"type":           "microsoft.compute/virtualmachinescalesets",
+			"name":           name,
+			"location":       "eastus",
+			"subscriptionId": "sub-1",
+			"sku":            map[string]interface{}{"capacity": capacity},
+			"properties":     map[string]interface{}{"orchestrationMode": mode},
+		}
+	}
+	graph := &fixtureGraph{tables: map[string][]map[string]interface{}{
+		"resources": {
+			scaleSet("web-vmss", "Uniform", 4),
+			scaleSet("batch-vmss", "Uniform", 0),
+			scaleSet("flex-vmss", "Flexible", 5),
+			scaleSet("aks-nodepool1-12345678-vmss", "Uniform", 3),
+			scaleSet("aks-userpool-12345678-vmss", "Uniform", 2),
+		},
+	}}
+
+	// Flexible instances are already counted as virtual machines
+	want := map[models.ResourceType]int{
+		"microsoft.compute/virtualmachinescalesets":               3,
+		"microsoft.compute/virtualmachinescalesets#instances":     4,
+		"microsoft.compute/virtualmachinescalesets#aks":           2,
+		"microsoft.compute/virtualmachinescalesets#aks-instances": 5,
+	}
+
+	defs := definitionsFor(t, "microsoft.compute/virtualmachinescalesets")
+	if len(defs) != len(want) {
+		t.Fatalf("expected %d scale set definitions, got %d", len(want), len(defs))
+	}
+
+	collector := &ResourceCollector{}
+	for _, def := range defs {
+		got, err := collector.CountResourceType(context.Background(), def, []string{"sub-1"}, graph)
+		if err != nil {
+			t.Fatalf("CountResourceType(%s) error = %v", def.ResourceType(), err)
+		}
+		if got.TotalResources != want[got.Type] {
+			t.Errorf("%s = %d, want %d", got.Type, got.TotalResources, want[got.Type])
+		}
+	}
+}
+
+func TestSQLDatabasesExcludeMaster(t *testing.T) {
+	database := func(server, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"type":           "microsoft.sql/servers/databases",
+			"name":           name,
+			"id":             "/subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Sql/servers/" + server + "/databases/" + name,
+			"location":       "eastus",
+			"subscriptionId": "sub-1",
+		}
+	}
+	graph := &fixtureGraph{tables: map[string][]map[string]interface{}{
+		"resources": {
+			database("sql-prod", "master"),
+			database("sql-prod", "orders"),
+			database("sql-prod", "customers"),
+			database("sql-dev", "master"),
+			database("sql-dev", "orders"),
+		},
+	}}
+
+	defs := definitionsFor(t, "microsoft.sql/servers/databases")
+	if len(defs) != 1 {
+		t.Fatalf("expected 1 SQL database definition, got %d", len(defs))
+	}
+
+	collector := &ResourceCollector{}
+	got, err := collector.CountResourceType(context.Background(), defs[0], []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatalf("CountResourceType() error = %v", err)
+	}
+	if got.TotalResources != 3 {
+		t.Errorf("TotalResources = %d, want 3 (master databases excluded)", got.TotalResources)
+	}
+}
+
+func TestCountQuerySumField(t *testing.T) {
+	def := models.ResourceDefinition{
+		Type:     "microsoft.compute/virtualmachinescalesets",
+		SumField: "sku.capacity",
+	}
+	query, err := countQuery(def, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, "summarize total = sum(toint(sku.capacity)) by location, subscriptionId") {
+		t.Errorf("countQuery() = %q, want a sum of sku.capacity", query)
+	}
+	if strings.Contains(query, "count()") {
+		t.Errorf("countQuery() = %q, should not count rows", query)
+	}
+}
+
+func TestCountResourceTypeStateAndSKU(t *testing.T) {
+	vm := func(state, sku string, count float64) map[string]interface{} {
+		r := row("eastus", "sub-1", count)
+		r["state"] = state
+		r["sku"] = sku
+		return r
+	}
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"": graphPage("",
+			vm("PowerState/running", "Standard_D2s_v3", 3),
+			vm("PowerState/running", "Standard_B2ms", 1),
+			vm("PowerState/deallocated", "Standard_D2s_v3", 2),
+			vm("", "", 1),
+		),
+	}}
+
+	def := models.ResourceDefinition{
+		Type:       "microsoft.compute/virtualmachines",
+		StateField: "properties.extended.instanceView.powerState.code",
+		SKUField:   "properties.hardwareProfile.vmSize",
+	}
+	got, err := (&ResourceCollector{}).CountResourceType(context.Background(), def, []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertCounts(t, "ByState", got.ByState, map[string]int{"running": 4, "deallocated": 2, "unknown": 1})
+	assertCounts(t, "BySKU", got.BySKU, map[string]int{"Standard_D2s_v3": 5, "Standard_B2ms": 1, "unknown": 1})
+
+	query := *graph.requests[0].Query
+	for _, want := range []string{"extend state = tostring(properties.extended.instanceView.powerState.code)", "by location, subscriptionId, state, sku"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query %q does not contain %q", query, want)
+		}
+	}
+}
+
+func TestCountResourceTypeByExposure(t *testing.T) {
+	lb := func(sku, exposure string, count float64) map[string]interface{} {
+		r := row("eastus", "sub-1", count)
+		r["sku"] = sku
+		r["exposure"] = exposure
+		return r
+	}
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"": graphPage("",
+			lb("Standard", "public", 3),
+			lb("Standard", "internal", 2),
+			lb("Basic", "public", 1),
+		),
+	}}
+
+	def := models.ResourceDefinition{
+		Type:        "microsoft.network/loadbalancers",
+		SKUField:    "sku.name",
+		PublicField: "properties.frontendIPConfigurations[0].properties.publicIPAddress.id",
+	}
+	got, err := (&ResourceCollector{}).CountResourceType(context.Background(), def, []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.TotalResources != 6 {
+		t.Errorf("TotalResources = %d, want 6", got.TotalResources)
+	}
+	assertCounts(t, "BySKU", got.BySKU, map[string]int{"Standard": 5, "Basic": 1})
+	assertCounts(t, "ByExposure", got.ByExposure, map[string]int{"public": 4, "internal": 2})
+
+	query := *graph.requests[0].Query
+	want := `extend exposure = iff(isnotempty(properties.frontendIPConfigurations[0].properties.publicIPAddress.id), "public", "internal")`
+	if !strings.Contains(query, want) {
+		t.Errorf("query %q does not contain %q", query, want)
+	}
+}
+
+func TestCountResourceTypeExcludesNoise(t *testing.T) {
+	nic := func(noise bool, count float64) map[string]interface{} {
+		r := row("eastus", "sub-1", count)
+		r["noise"] = noise
+		return r
+	}
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"": graphPage("", nic(false, 5), nic(true, 12)),
+	}}
+
+	def := models.ResourceDefinition{
+		Type:        "microsoft.network/networkinterfaces",
+		NoiseFilter: "isnotempty(properties.privateEndpoint.id)",
+	}
+	collector := NewResourceCollector(nil, nil, nil, true)
+	got, err := collector.CountResourceType(context.Background(), def, []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.TotalResources != 5 || got.NoiseExcluded != 12 {
+		t.Errorf("TotalResources, NoiseExcluded = %d, %d, want 5, 12", got.TotalResources, got.NoiseExcluded)
+	}
+	assertCounts(t, "ByAccount", got.ByAccount, map[string]int{"sub-1": 5})
+
+	query := *graph.requests[0].Query
+	for _, want := range []string{"| extend noise = tobool(isnotempty(properties.privateEndpoint.id))", "by location, subscriptionId, noise"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query %q does not contain %q", query, want)
+		}
+	}
+}
+
+func TestCountQueryIgnoresNoiseFilterByDefault(t *testing.T) {
+	def := models.ResourceDefinition{
+		Type:        "microsoft.network/networkinterfaces",
+		NoiseFilter: "isnotempty(properties.privateEndpoint.id)",
+	}
+	query, err := countQuery(def, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(query, "noise") {
+		t.Errorf("countQuery() = %q, want no noise column without excludeNoise", query)
+	}
+}
+
+func TestCountResourceTypeWithoutBreakdown(t *testing.T) {
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"": graphPage("", row("eastus", "sub-1", 2)),
+	}}
+	got, err := (&ResourceCollector{}).CountResourceType(context.Background(),
+		models.ResourceDefinition{Type: "microsoft.keyvault/vaults"}, []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ByState != nil || got.BySKU != nil || got.ByExposure != nil {
+		t.Errorf("expected no breakdown maps, got %v %v %v", got.ByState, got.BySKU, got.ByExposure)
+	}
+}
@@ -0,0 +1,147 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	managementEndpoint = "https://management.azure.com"
+	managementScope    = "https://management.azure.com/.default"
+	pricingAPIVersion  = "2024-01-01"
+
+	// defenderLocation is the ByLocation key used for subscription-wide plans
+	defenderLocation = "global"
+)
+
+// pricingList is the response of the Security Center pricings API
+type pricingList struct {
+	Value []struct {
+		Name       string `json:"name"`
+		Properties struct {
+			PricingTier string `json:"pricingTier"`
+		} `json:"properties"`
+	} `json:"value"`
+}
+
+// DefenderCollector counts enabled Microsoft Defender for Cloud plans through
+// the Security Center pricing API, which Resource Graph does not expose per
+// plan tier
+type DefenderCollector struct {
+	pipeline runtime.Pipeline
+	endpoint string
+}
+
+// NewDefenderCollector creates a collector that authenticates to Azure
+// Resource Manager with the given credential and sends requests with options,
+// to the Resource Manager endpoint of their cloud if it has one
+func NewDefenderCollector(credential azcore.TokenCredential, options policy.ClientOptions) *DefenderCollector {
+	pipeline := runtime.NewPipeline(version.Product, version.Get(), runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(credential, []string{managementScope}, nil)},
+	}, &options)
+
+	endpoint := managementEndpoint
+	if configured := options.Cloud.Services[cloud.ResourceManager].Endpoint; configured != "" {
+		endpoint = strings.TrimSuffix(configured, "/")
+	}
+
+	return &DefenderCollector{
+		pipeline: pipeline,
+		endpoint: endpoint,
+	}
+}
+
+// PlanResourceType describes how CountResourceType counts resourceDef: one
+// pricings call per subscription
+func (c *DefenderCollector) PlanResourceType(resourceDef models.ResourceDefinition, subscriptions []string) models.PlannedType {
+	return models.PlannedType{
+		Type:           resourceDef.ResourceType(),
+		DisplayName:    resourceDef.DisplayName,
+		CountMethod:    resourceDef.CountMethod,
+		API:            "GET Microsoft.Security/pricings per subscription",
+		EstimatedCalls: len(subscriptions),
+	}
+}
+
+// CountResourceType counts Defender plans on the Standard tier in every
+// subscription. A subscription that cannot be read is logged and skipped.
+func (c *DefenderCollector) CountResourceType(
+	ctx context.Context,
+	resourceDef models.ResourceDefinition,
+	subscriptions []string,
+) (*models.ResourceCount, error) {
+
+	result := &models.ResourceCount{
+		Provider:    "Azure",
+		Type:        resourceDef.ResourceType(),
+		DisplayName: resourceDef.DisplayName,
+		ByLocation:  make(map[string]int),
+		ByAccount:   make(map[string]int),
+	}
+
+	for _, subscriptionID := range subscriptions {
+		count, err := c.countEnabledPlans(ctx, subscriptionID)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to read Defender plans",
+				zap.String("subscription_id", subscriptionID),
+				zap.Error(err))
+			continue
+		}
+		if count > 0 {
+			result.ByAccount[subscriptionID] = count
+			result.ByLocation[defenderLocation] += count
+			result.TotalResources += count
+		}
+	}
+
+	logging.FromContext(ctx).Debug("Completed counting",
+		zap.String("type", resourceDef.Type),
+		zap.Int("total", result.TotalResources))
+
+	return result, nil
+}
+
+func (c *DefenderCollector) countEnabledPlans(ctx context.Context, subscriptionID string) (int, error) {
+	url := fmt.Sprintf("%s/subscriptions/%s/providers/Microsoft.Security/pricings?api-version=%s",
+		c.endpoint, subscriptionID, pricingAPIVersion)
+
+	req, err := runtime.NewRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, runtime.NewResponseError(resp)
+	}
+
+	var pricings pricingList
+	if err := json.NewDecoder(resp.Body).Decode(&pricings); err != nil {
+		return 0, fmt.Errorf("failed to decode pricings: %w", err)
+	}
+
+	count := 0
+	for _, pricing := range pricings.Value {
+		if strings.EqualFold(pricing.Properties.PricingTier, "Standard") {
+			count++
+		}
+	}
+	return count, nil
+}
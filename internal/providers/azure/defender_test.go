@@ -0,0 +1,89 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// pricingsPath is the path of the Defender pricings of a subscription
+func pricingsPath(subscription string) string {
+	return "/subscriptions/" + subscription + "/providers/Microsoft.Security/pricings"
+}
+
+var defenderDef = models.ResourceDefinition{
+	Type:        "microsoft.security/pricings",
+	DisplayName: "Defender for Cloud Plans (enabled)",
+	CountMethod: models.CountMethodSecurityPricing,
+}
+
+func TestDefenderCountResourceType(t *testing.T) {
+	// sub-3 is denied and skipped
+	api := &managementGroupsAPI{status: http.StatusForbidden, bodies: map[string]string{
+		pricingsPath("sub-1"): `{"value":[
+			{"name":"VirtualMachines","properties":{"pricingTier":"Standard"}},
+			{"name":"StorageAccounts","properties":{"pricingTier":"standard"}},
+			{"name":"SqlServers","properties":{"pricingTier":"Free"}}]}`,
+		pricingsPath("sub-2"): `{"value":[
+			{"name":"VirtualMachines","properties":{"pricingTier":"Free"}}]}`,
+	}}
+	collector := NewDefenderCollector(fakeCredential{token: "token"},
+		policy.ClientOptions{Transport: &http.Client{Transport: api}})
+
+	count, err := collector.CountResourceType(context.Background(), defenderDef, []string{"sub-1", "sub-2", "sub-3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.TotalResources != 2 || count.ByLocation[defenderLocation] != 2 {
+		t.Errorf("total = %d by location %v, want 2 global plans", count.TotalResources, count.ByLocation)
+	}
+	if len(count.ByAccount) != 1 || count.ByAccount["sub-1"] != 2 {
+		t.Errorf("by account = %v, want only sub-1 with 2 plans", count.ByAccount)
+	}
+	if count.Type != defenderDef.ResourceType() || count.DisplayName != defenderDef.DisplayName {
+		t.Errorf("count type = %s (%s)", count.Type, count.DisplayName)
+	}
+}
+
+// hostRecorder records the host of the last request before passing it on
+type hostRecorder struct {
+	next http.RoundTripper
+	host string
+}
+
+func (r *hostRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.host = req.URL.Host
+	return r.next.RoundTrip(req)
+}
+
+func TestDefenderEndpoint(t *testing.T) {
+	api := &managementGroupsAPI{status: http.StatusNotFound, bodies: map[string]string{
+		pricingsPath("sub-1"): `{"value":[{"name":"VirtualMachines","properties":{"pricingTier":"Standard"}}]}`,
+	}}
+	transport := &hostRecorder{next: api}
+	sovereign := cloud.Configuration{Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+		cloud.ResourceManager: {Endpoint: "https://management.usgovcloudapi.net/"},
+	}}
+	collector := NewDefenderCollector(fakeCredential{token: "token"},
+		policy.ClientOptions{Cloud: sovereign, Transport: &http.Client{Transport: transport}})
+
+	count, err := collector.CountResourceType(context.Background(), defenderDef, []string{"sub-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.TotalResources != 1 || transport.host != "management.usgovcloudapi.net" {
+		t.Errorf("counted %d plans at %s, want 1 at the cloud's Resource Manager endpoint", count.TotalResources, transport.host)
+	}
+}
+
+func TestDefenderPlanResourceType(t *testing.T) {
+	collector := NewDefenderCollector(fakeCredential{token: "token"}, policy.ClientOptions{})
+	planned := collector.PlanResourceType(defenderDef, []string{"sub-1", "sub-2"})
+	if planned.EstimatedCalls != 2 || planned.Type != defenderDef.ResourceType() {
+		t.Errorf("PlanResourceType() = %+v, want 2 calls", planned)
+	}
+}
@@ -0,0 +1,80 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// authErrorCodes are ARM error codes of missing, invalid or expired tokens
+var authErrorCodes = map[string]bool{
+	"InvalidAuthenticationToken":         true,
+	"InvalidAuthenticationTokenTenant":   true,
+	"ExpiredAuthenticationToken":         true,
+	"AuthenticationFailed":               true,
+	"InvalidAuthenticationTokenAudience": true,
+}
+
+// countMethodActions is the RBAC action each count method needs
+var countMethodActions = map[models.CountMethod]string{
+	models.CountMethodResourceGraph:   "Microsoft.ResourceGraph/resources/read",
+	models.CountMethodSecurityPricing: "Microsoft.Security/pricings/read",
+}
+
+// authErrorCredential marks every failure to get a token as an
+// authentication error, whichever client or pipeline requested it
+type authErrorCredential struct {
+	azcore.TokenCredential
+}
+
+func (c authErrorCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := c.TokenCredential.GetToken(ctx, options)
+	switch {
+	case err == nil, sizingerrors.Classified(err):
+	case sizingerrors.IsCertificateError(err):
+		err = &sizingerrors.TLSError{Err: err}
+	default:
+		err = &sizingerrors.AuthError{Provider: metricsProvider, Err: err}
+	}
+	return token, err
+}
+
+// classifyError wraps an SDK error into the error taxonomy. action is the
+// Azure RBAC action the failed call needs. Unrecognized errors are returned
+// unchanged.
+func classifyError(err error, action string) error {
+	if err == nil || sizingerrors.Classified(err) {
+		return err
+	}
+
+	var (
+		authFailed   *azidentity.AuthenticationFailedError
+		authRequired *azidentity.AuthenticationRequiredError
+		respErr      *azcore.ResponseError
+	)
+	switch {
+	case sizingerrors.IsCertificateError(err):
+		return &sizingerrors.TLSError{Err: err}
+	case errors.As(err, &authFailed), errors.As(err, &authRequired):
+		return &sizingerrors.AuthError{Provider: metricsProvider, Err: err}
+	case !errors.As(err, &respErr):
+		return err
+	}
+
+	switch {
+	case respErr.StatusCode == http.StatusUnauthorized, authErrorCodes[respErr.ErrorCode]:
+		return &sizingerrors.AuthError{Provider: metricsProvider, Err: err}
+	case respErr.StatusCode == http.StatusForbidden:
+		return &sizingerrors.PermissionError{Provider: metricsProvider, Action: action, Err: err}
+	case respErr.StatusCode == http.StatusTooManyRequests:
+		return &sizingerrors.ThrottledError{Provider: metricsProvider, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+)
+
+// armError returns a Resource Manager response error
+func armError(status int, code string) error {
+	return &azcore.ResponseError{
+		StatusCode: status,
+		ErrorCode:  code,
+		RawResponse: &http.Response{
+			StatusCode: status,
+			Request:    &http.Request{Method: http.MethodPost, URL: &url.URL{Scheme: "https", Host: "management.azure.com"}},
+		},
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{name: "unauthorized", err: armError(http.StatusUnauthorized, "InvalidAuthenticationToken"), wantCode: sizingerrors.ExitAuth},
+		{name: "expired token", err: armError(http.StatusBadRequest, "ExpiredAuthenticationToken"), wantCode: sizingerrors.ExitAuth},
+		{name: "authorization failed", err: armError(http.StatusForbidden, "AuthorizationFailed"), wantCode: sizingerrors.ExitPermission},
+		{name: "throttled", err: armError(http.StatusTooManyRequests, "RateLimiting"), wantCode: sizingerrors.ExitThrottled},
+		{name: "bad query", err: armError(http.StatusBadRequest, "BadRequest"), wantCode: sizingerrors.ExitFailure},
+		{name: "network", err: errors.New("dial tcp: no such host"), wantCode: sizingerrors.ExitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("failed to query: %w", classifyError(tt.err, "Microsoft.Security/pricings/read"))
+			if code := sizingerrors.ExitCode(err); code != tt.wantCode {
+				t.Errorf("ExitCode() = %d, want %d", code, tt.wantCode)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Error("classified error does not wrap the SDK error")
+			}
+			if tt.wantCode != sizingerrors.ExitFailure && sizingerrors.Hint(err) == "" {
+				t.Error("classified error has no hint")
+			}
+		})
+	}
+}
+
+func TestPermissionHintNamesAction(t *testing.T) {
+	err := classifyError(armError(http.StatusForbidden, "AuthorizationFailed"), "Microsoft.Security/pricings/read")
+	var permission *sizingerrors.PermissionError
+	if !errors.As(err, &permission) || permission.Action != "Microsoft.Security/pricings/read" {
+		t.Fatalf("classifyError() = %v, want a permission error naming the action", err)
+	}
+}
+
+// failingCredential fails every token request
+type failingCredential struct{}
+
+func (failingCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{}, errors.New("AzureCLICredential: Please run 'az login' to set up an account")
+}
+
+func TestAuthErrorCredential(t *testing.T) {
+	_, err := authErrorCredential{failingCredential{}}.GetToken(context.Background(), policy.TokenRequestOptions{})
+	if code := sizingerrors.ExitCode(err); code != sizingerrors.ExitAuth {
+		t.Fatalf("ExitCode(%v) = %d, want %d", err, code, sizingerrors.ExitAuth)
+	}
+
+	// A token failure inside a client call surfaces the same way
+	collector := NewDefenderCollector(authErrorCredential{failingCredential{}}, policy.ClientOptions{})
+	_, err = collector.countEnabledPlans(context.Background(), "sub-1")
+	if code := sizingerrors.ExitCode(err); code != sizingerrors.ExitAuth {
+		t.Errorf("ExitCode(%v) = %d, want %d", err, code, sizingerrors.ExitAuth)
+	}
+}
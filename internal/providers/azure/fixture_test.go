@@ -0,0 +1,145 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+)
+
+// fixtureGraph evaluates the small subset of KQL the collector generates
+// against in-memory rows, so that filters can be tested end to end. Only the
+// table name, "where" clauses joined by "and", and the final count or sum
+// summary are understood.
+type fixtureGraph struct {
+	tables map[string][]map[string]interface{}
+}
+
+var (
+	wherePattern  = regexp.MustCompile(`^\|\s*where\s+(.+)$`)
+	sumPattern    = regexp.MustCompile(`^\|\s*summarize\s+\w+\s*=\s*sum\(toint\(([\w.]+)\)\)`)
+	clausePattern = regexp.MustCompile(`^([\w.]+)\s+(=~|!~|==|!=|!?has|!?startswith|!?endswith)\s+"([^"]*)"$`)
+)
+
+func (f *fixtureGraph) Resources(
+	_ context.Context,
+	query armresourcegraph.QueryRequest,
+	_ *armresourcegraph.ClientResourcesOptions,
+) (armresourcegraph.ClientResourcesResponse, error) {
+	var table string
+	var clauses []string
+	var sumField string
+	for _, line := range strings.Split(*query.Query, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case table == "":
+			table = strings.ToLower(line)
+		case wherePattern.MatchString(line):
+			cond := wherePattern.FindStringSubmatch(line)[1]
+			clauses = append(clauses, strings.Split(cond, " and ")...)
+		case sumPattern.MatchString(line):
+			sumField = sumPattern.FindStringSubmatch(line)[1]
+		}
+	}
+
+	counts := make(map[[2]string]int)
+	for _, row := range f.tables[table] {
+		match := true
+		for _, clause := range clauses {
+			ok, err := evalClause(row, strings.TrimSpace(clause))
+			if err != nil {
+				return armresourcegraph.ClientResourcesResponse{}, err
+			}
+			if !ok {
+				match = false
+				break
+			}
+		}
+		if match {
+			key := [2]string{fmt.Sprint(row["location"]), fmt.Sprint(row["subscriptionId"])}
+			if sumField == "" {
+				counts[key]++
+				continue
+			}
+			if n, ok := lookup(row, sumField).(float64); ok {
+				counts[key] += int(n)
+			}
+		}
+	}
+
+	data := make([]interface{}, 0, len(counts))
+	for key, count := range counts {
+		data = append(data, map[string]interface{}{
+			"location":       key[0],
+			"subscriptionId": key[1],
+			"count":          float64(count),
+		})
+	}
+
+	resp := armresourcegraph.ClientResourcesResponse{}
+	resp.Data = data
+	return resp, nil
+}
+
+func evalClause(row map[string]interface{}, clause string) (bool, error) {
+	m := clausePattern.FindStringSubmatch(clause)
+	if m == nil {
+		return false, fmt.Errorf("fixtureGraph: unsupported clause %q", clause)
+	}
+	field, op, want := m[1], m[2], m[3]
+
+	got := strings.ToLower(fmt.Sprint(lookup(row, field)))
+	lowerWant := strings.ToLower(want)
+
+	negate := strings.HasPrefix(op, "!") && op != "!=" && op != "!~"
+	var result bool
+	switch strings.TrimPrefix(op, "!") {
+	case "=~", "~":
+		result = got == lowerWant
+	case "==", "=":
+		result = fmt.Sprint(lookup(row, field)) == want
+	case "has":
+		result = hasTerm(got, lowerWant)
+	case "startswith":
+		result = strings.HasPrefix(got, lowerWant)
+	case "endswith":
+		result = strings.HasSuffix(got, lowerWant)
+	}
+	if op == "!=" || op == "!~" {
+		result = !result
+	}
+	if negate {
+		result = !result
+	}
+	return result, nil
+}
+
+// lookup resolves a dotted path such as "properties.type" in a row
+func lookup(row map[string]interface{}, path string) interface{} {
+	var current interface{} = row
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+// hasTerm reports whether term appears as a whole alphanumeric token in s
+func hasTerm(s, term string) bool {
+	tokens := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, token := range tokens {
+		if token == term {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/secrails/secrails-sizing-agent/internal/identity"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	graphEndpoint = "https://graph.microsoft.com/v1.0"
+	graphScope    = "https://graph.microsoft.com/.default"
+
+	// graphPageSize is the largest page Microsoft Graph serves for directory
+	// objects, used when a tenant does not support $count
+	graphPageSize = 999
+
+	// identityLocation is the ByLocation key used for tenant-wide objects
+	identityLocation = "tenant"
+)
+
+// identityObjects are the directory object collections counted in identity mode
+var identityObjects = []models.ResourceDefinition{
+	{Type: "microsoft.graph/users", DisplayName: "Entra ID Users", Category: identity.Category},
+	{Type: "microsoft.graph/groups", DisplayName: "Entra ID Groups", Category: identity.Category},
+	{Type: "microsoft.graph/serviceprincipals", DisplayName: "Entra ID Service Principals", Category: identity.Category},
+	{Type: "microsoft.graph/applications", DisplayName: "Entra ID App Registrations", Category: identity.Category},
+}
+
+// graphCollections maps identity types to their Microsoft Graph collection
+var graphCollections = map[string]string{
+	"microsoft.graph/users":             "users",
+	"microsoft.graph/groups":            "groups",
+	"microsoft.graph/serviceprincipals": "servicePrincipals",
+	"microsoft.graph/applications":      "applications",
+}
+
+// planIdentities describes how CountIdentities counts the directory objects:
+// one Microsoft Graph $count call per collection
+func planIdentities() []models.PlannedType {
+	planned := make([]models.PlannedType, 0, len(identityObjects))
+	for _, def := range identityObjects {
+		planned = append(planned, models.PlannedType{
+			Type:           def.ResourceType(),
+			DisplayName:    def.DisplayName,
+			API:            "Microsoft Graph GET /" + graphCollections[def.Type] + "/$count",
+			EstimatedCalls: 1,
+		})
+	}
+	return planned
+}
+
+// IdentityCollector counts Entra ID directory objects through Microsoft Graph.
+//
+// It calls the Graph REST API through an azcore pipeline rather than
+// msgraph-sdk-go: counting needs one endpoint per collection, and the
+// pipeline built from the scan's client options already brings the
+// credential, the proxy, retries of throttled (429) requests honouring
+// Retry-After, and the metrics and debug dump policies, as for the other
+// Azure APIs. The SDK's generated module would add a large dependency tree
+// for two GET requests.
+type IdentityCollector struct {
+	pipeline runtime.Pipeline
+	endpoint string
+}
+
+// NewIdentityCollector creates a collector that authenticates to Microsoft
+// Graph with the given credential and sends requests with options
+func NewIdentityCollector(credential azcore.TokenCredential, options policy.ClientOptions) *IdentityCollector {
+	pipeline := runtime.NewPipeline(version.Product, version.Get(), runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(credential, []string{graphScope}, nil)},
+	}, &options)
+
+	return &IdentityCollector{
+		pipeline: pipeline,
+		endpoint: graphEndpoint,
+	}
+}
+
+// CountIdentities counts every identity object type. Types that cannot be
+// counted are skipped and reported as warnings rather than errors.
+func (c *IdentityCollector) CountIdentities(ctx context.Context) ([]*models.ResourceCount, []string) {
+	var counts []*models.ResourceCount
+	var warnings []string
+
+	for _, def := range identityObjects {
+		typeCtx, stats := metrics.StartTypeStats(ctx)
+		count, err := c.countObjects(typeCtx, graphCollections[def.Type])
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to count identity objects",
+				zap.String("type", def.Type),
+				zap.Error(err))
+			warnings = append(warnings, fmt.Sprintf("%s not counted: %v", def.DisplayName, err))
+			continue
+		}
+
+		counts = append(counts, &models.ResourceCount{
+			Provider:       "Azure",
+			Type:           def.ResourceType(),
+			DisplayName:    def.DisplayName,
+			CanonicalType:  def.CanonicalType(),
+			Category:       def.Category,
+			TotalResources: count,
+			ByLocation:     map[string]int{identityLocation: count},
+			ByAccount:      make(map[string]int),
+			Stats:          stats.Stats(),
+		})
+	}
+
+	return counts, warnings
+}
+
+// tenantDirectory is the Entra ID tenant as the directory holding counts
+func tenantDirectory(tenantID string, counts []*models.ResourceCount) models.AccountCount {
+	directory := models.AccountCount{
+		ID:     tenantID,
+		Name:   "Entra ID tenant " + tenantID,
+		Status: "Enabled",
+		ByType: make(map[models.ResourceType]int, len(counts)),
+	}
+	for _, count := range counts {
+		directory.ResourceCount += count.TotalResources
+		directory.ByType[count.Type] = count.TotalResources
+	}
+	return directory
+}
+
+// countObjects uses the $count endpoint so that no objects are paged
+// through, unless the tenant does not support it, as Azure AD B2C tenants
+// do not
+func (c *IdentityCollector) countObjects(ctx context.Context, collection string) (int, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, c.endpoint+"/"+collection+"/$count")
+	if err != nil {
+		return 0, err
+	}
+	// $count on directory objects is an advanced query
+	req.Raw().Header.Set("ConsistencyLevel", "eventual")
+	req.Raw().Header.Set("Accept", "text/plain")
+
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest:
+		logging.FromContext(ctx).Debug("$count is not supported, paging through the objects",
+			zap.String("collection", collection))
+		return c.pageObjects(ctx, collection)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return 0, errAccessDenied
+	default:
+		return 0, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(string(body), "\ufeff")))
+	if err != nil {
+		return 0, fmt.Errorf("invalid count response: %w", err)
+	}
+	return count, nil
+}
+
+// errAccessDenied is returned when the credential may not read the directory
+var errAccessDenied = errors.New("access denied (requires Directory.Read.All)")
+
+// pageObjects counts the objects of collection by listing their IDs, a
+// page of graphPageSize at a time
+func (c *IdentityCollector) pageObjects(ctx context.Context, collection string) (int, error) {
+	count := 0
+	next := fmt.Sprintf("%s/%s?$select=id&$top=%d", c.endpoint, collection, graphPageSize)
+	for next != "" {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, next)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := c.pipeline.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case runtime.HasStatusCode(resp, http.StatusUnauthorized, http.StatusForbidden):
+			_ = resp.Body.Close()
+			return 0, errAccessDenied
+		case !runtime.HasStatusCode(resp, http.StatusOK):
+			return 0, runtime.NewResponseError(resp)
+		}
+		var page struct {
+			Value    []json.RawMessage `json:"value"`
+			NextLink string            `json:"@odata.nextLink"`
+		}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return 0, err
+		}
+		count += len(page.Value)
+		next = page.NextLink
+	}
+	return count, nil
+}
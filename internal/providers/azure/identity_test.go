@@ -0,0 +1,193 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// fakeGraph is a stub Microsoft Graph serving the $count and list endpoints
+// of each collection. A collection without a count answers $count with 400,
+// as tenants without advanced queries do, and is listed in pages of two
+// objects. throttle requests of each path are answered with 429 first.
+type fakeGraph struct {
+	counts   map[string]int
+	objects  map[string]int
+	denied   map[string]bool
+	throttle int
+
+	mu       sync.Mutex
+	requests []*http.Request
+	attempts map[string]int
+}
+
+func (g *fakeGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	g.requests = append(g.requests, r)
+	if g.attempts == nil {
+		g.attempts = make(map[string]int)
+	}
+	g.attempts[r.URL.String()]++
+	throttled := g.attempts[r.URL.String()] <= g.throttle
+	g.mu.Unlock()
+
+	if throttled {
+		w.Header().Set("retry-after-ms", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	collection, counting := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/$count")
+	if g.denied[collection] {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if counting {
+		count, ok := g.counts[collection]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":"Request_UnsupportedQuery"}}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, "\ufeff%d", count)
+		return
+	}
+
+	// Pages of two objects, the page number in $skiptoken
+	page := 0
+	_, _ = fmt.Sscan(r.URL.Query().Get("$skiptoken"), &page)
+	total := g.objects[collection]
+	var ids []string
+	for i := page * 2; i < total && i < page*2+2; i++ {
+		ids = append(ids, fmt.Sprintf(`{"id":"%d"}`, i))
+	}
+	next := ""
+	if (page+1)*2 < total {
+		next = fmt.Sprintf(`,"@odata.nextLink":"https://%s/%s?$select=id&$skiptoken=%d"`, r.Host, collection, page+1)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(w, `{"value":[%s]%s}`, strings.Join(ids, ","), next)
+}
+
+// newFakeGraphCollector returns a collector calling graph, retrying
+// throttled requests without delay
+func newFakeGraphCollector(t *testing.T, graph *fakeGraph) *IdentityCollector {
+	t.Helper()
+	server := httptest.NewTLSServer(graph)
+	t.Cleanup(server.Close)
+	collector := NewIdentityCollector(fakeCredential{token: "token"}, policy.ClientOptions{
+		Transport: server.Client(),
+		Retry:     policy.RetryOptions{RetryDelay: time.Millisecond, MaxRetryDelay: time.Millisecond},
+	})
+	collector.endpoint = server.URL
+	return collector
+}
+
+func TestCountIdentities(t *testing.T) {
+	graph := &fakeGraph{
+		counts:  map[string]int{"users": 1200, "groups": 40, "applications": 7},
+		objects: map[string]int{"servicePrincipals": 5},
+	}
+	collector := newFakeGraphCollector(t, graph)
+
+	counts, warnings := collector.CountIdentities(context.Background())
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %v, want none", warnings)
+	}
+	got := make(map[string]int)
+	for _, count := range counts {
+		got[string(count.Type)] = count.TotalResources
+		if count.ByLocation[identityLocation] != count.TotalResources || count.Category != "Identity" {
+			t.Errorf("%s by location = %v, category = %q", count.Type, count.ByLocation, count.Category)
+		}
+	}
+	want := map[string]int{
+		"microsoft.graph/users": 1200, "microsoft.graph/groups": 40,
+		"microsoft.graph/serviceprincipals": 5, "microsoft.graph/applications": 7,
+	}
+	for typ, total := range want {
+		if got[typ] != total {
+			t.Errorf("%s = %d, want %d", typ, got[typ], total)
+		}
+	}
+
+	for _, req := range graph.requests {
+		if strings.HasSuffix(req.URL.Path, "/$count") && req.Header.Get("ConsistencyLevel") != "eventual" {
+			t.Errorf("%s sent without ConsistencyLevel: eventual", req.URL)
+		}
+		if req.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("%s sent without the bearer token", req.URL)
+		}
+	}
+}
+
+func TestCountObjectsPaging(t *testing.T) {
+	graph := &fakeGraph{objects: map[string]int{"users": 5}}
+	collector := newFakeGraphCollector(t, graph)
+
+	count, err := collector.countObjects(context.Background(), "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Errorf("countObjects() = %d, want 5", count)
+	}
+	// $count, then three pages
+	if len(graph.requests) != 4 {
+		t.Errorf("got %d requests, want 4", len(graph.requests))
+	}
+	if first := graph.requests[1].URL.Query(); first.Get("$select") != "id" || first.Get("$top") != "999" {
+		t.Errorf("first page query = %v, want $select=id and $top=999", first)
+	}
+}
+
+func TestCountObjectsThrottled(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *fakeGraph
+		want  int
+	}{
+		{name: "count", graph: &fakeGraph{counts: map[string]int{"users": 3}, throttle: 2}, want: 3},
+		{name: "paging", graph: &fakeGraph{objects: map[string]int{"users": 3}, throttle: 1}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := newFakeGraphCollector(t, tt.graph)
+			count, err := collector.countObjects(context.Background(), "users")
+			if err != nil {
+				t.Fatalf("countObjects() error = %v, want the throttled requests retried", err)
+			}
+			if count != tt.want {
+				t.Errorf("countObjects() = %d, want %d", count, tt.want)
+			}
+			for url, attempts := range tt.graph.attempts {
+				if attempts != tt.graph.throttle+1 {
+					t.Errorf("%s requested %d times, want %d", url, attempts, tt.graph.throttle+1)
+				}
+			}
+		})
+	}
+}
+
+func TestCountIdentitiesAccessDenied(t *testing.T) {
+	graph := &fakeGraph{
+		counts: map[string]int{"users": 10, "groups": 2, "servicePrincipals": 4},
+		denied: map[string]bool{"applications": true},
+	}
+	collector := newFakeGraphCollector(t, graph)
+
+	counts, warnings := collector.CountIdentities(context.Background())
+	if len(counts) != 3 {
+		t.Errorf("got %d counts, want 3", len(counts))
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Directory.Read.All") {
+		t.Errorf("warnings = %v, want one naming Directory.Read.All", warnings)
+	}
+}
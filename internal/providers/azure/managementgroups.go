@@ -0,0 +1,157 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// subscriptionDescendant is the type of subscriptions among the descendants
+// of a management group
+const subscriptionDescendant = "/subscriptions"
+
+// managementGroupTree is the part of the management group hierarchy the
+// credentials can see
+type managementGroupTree struct {
+	// groups are the visible groups, by lower-cased group ID
+	groups map[string]models.ManagementGroupRef
+	// parents are the parent group IDs of groups and subscriptions, by
+	// lower-cased ID; the top visible groups have none
+	parents map[string]string
+}
+
+// placeSubscriptions sets the management group path of each subscription.
+// A hierarchy that cannot be read leaves every subscription unassigned.
+func (p *AzureProvider) placeSubscriptions(ctx context.Context) {
+	tree, err := discoverManagementGroups(ctx, p.credential, p.clientOptions(), p.tenantID)
+	if err != nil {
+		p.config.Log().Warn("Could not read the management group hierarchy", zap.Error(err))
+		reason := err.Error()
+		var denied *sizingerrors.PermissionError
+		if errors.As(err, &denied) {
+			reason = fmt.Sprintf("grant %s, e.g. through the Management Group Reader role", denied.Action)
+		}
+		p.managementGroupsWarning = fmt.Sprintf(
+			"management group hierarchy not available (%s); all subscriptions are reported as %s",
+			reason, models.UnassignedManagementGroup)
+		return
+	}
+	for i := range p.subscriptions {
+		p.subscriptions[i].ManagementGroupPath = tree.path(p.subscriptions[i].ID)
+	}
+}
+
+// discoverManagementGroups lists the management groups the credential can
+// see and their descendants, starting from the tenant root group when it is
+// visible so that most trees take one descendants listing
+func discoverManagementGroups(ctx context.Context, credential azcore.TokenCredential, options policy.ClientOptions, tenantID string) (*managementGroupTree, error) {
+	client, err := armmanagementgroups.NewClient(credential, &arm.ClientOptions{ClientOptions: options})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management groups client: %w", err)
+	}
+
+	tree := &managementGroupTree{
+		groups:  make(map[string]models.ManagementGroupRef),
+		parents: make(map[string]string),
+	}
+	var groups []string
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list management groups: %w",
+				classifyError(err, "Microsoft.Management/managementGroups/read"))
+		}
+		for _, group := range page.Value {
+			id := stringValue(group.Name)
+			if id == "" {
+				continue
+			}
+			ref := models.ManagementGroupRef{ID: id}
+			if group.Properties != nil {
+				ref.Name = stringValue(group.Properties.DisplayName)
+			}
+			tree.groups[strings.ToLower(id)] = ref
+			if strings.EqualFold(id, tenantID) {
+				groups = append([]string{id}, groups...)
+			} else {
+				groups = append(groups, id)
+			}
+		}
+	}
+
+	// A group found below one already listed needs no listing of its own
+	listed := make(map[string]bool)
+	for _, group := range groups {
+		if listed[strings.ToLower(group)] {
+			continue
+		}
+		listed[strings.ToLower(group)] = true
+
+		pager := client.NewGetDescendantsPager(group, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the descendants of management group %s: %w", group,
+					classifyError(err, "Microsoft.Management/managementGroups/descendants/read"))
+			}
+			for _, descendant := range page.Value {
+				tree.add(descendant, listed)
+			}
+		}
+	}
+
+	logging.FromContext(ctx).Debug("Found management groups", zap.Int("count", len(tree.groups)))
+	return tree, nil
+}
+
+// add records a descendant and its parent; groups are marked listed, as the
+// listing of their ancestor covers them
+func (t *managementGroupTree) add(descendant *armmanagementgroups.DescendantInfo, listed map[string]bool) {
+	id := strings.ToLower(stringValue(descendant.Name))
+	if id == "" || descendant.Properties == nil {
+		return
+	}
+	if descendant.Properties.Parent != nil {
+		t.parents[id] = strings.ToLower(path.Base(stringValue(descendant.Properties.Parent.ID)))
+	}
+	if stringValue(descendant.Type) != subscriptionDescendant {
+		if _, ok := t.groups[id]; !ok {
+			t.groups[id] = models.ManagementGroupRef{
+				ID:   stringValue(descendant.Name),
+				Name: stringValue(descendant.Properties.DisplayName),
+			}
+		}
+		listed[id] = true
+	}
+}
+
+// path returns the management groups above the subscription, from the top
+// visible group down to its parent, or nil when it is under none
+func (t *managementGroupTree) path(subscriptionID string) []models.ManagementGroupRef {
+	var groups []models.ManagementGroupRef
+	seen := make(map[string]bool)
+	for id, ok := t.parents[strings.ToLower(subscriptionID)]; ok && !seen[id]; id, ok = t.parents[id] {
+		seen[id] = true
+		group, found := t.groups[id]
+		if !found {
+			group = models.ManagementGroupRef{ID: id}
+		}
+		if group.Name == "" {
+			group.Name = group.ID
+		}
+		groups = append([]models.ManagementGroupRef{group}, groups...)
+	}
+	return groups
+}
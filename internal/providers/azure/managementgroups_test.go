@@ -0,0 +1,107 @@
+package azure
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+// managementGroupsAPI answers the management group API calls from canned
+// bodies by URL path, and with status for any other path
+type managementGroupsAPI struct {
+	bodies map[string]string
+	status int
+}
+
+func (f *managementGroupsAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, ok := f.bodies[req.URL.Path]
+	status := http.StatusOK
+	if !ok {
+		status, body = f.status, `{"error":{"code":"AuthorizationFailed","message":"denied"}}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// groupsPath is the path of the management group API
+const groupsPath = "/providers/Microsoft.Management/managementGroups"
+
+func TestPlaceSubscriptions(t *testing.T) {
+	api := &managementGroupsAPI{status: http.StatusNotFound, bodies: map[string]string{
+		groupsPath: `{"value":[
+			{"name":"mg-corp","properties":{"displayName":"Corp"}},
+			{"name":"tenant-a","properties":{"displayName":"Tenant Root Group","tenantId":"tenant-a"}},
+			{"name":"mg-prod","properties":{"displayName":"Production"}}]}`,
+		groupsPath + "/tenant-a/descendants": `{"value":[
+			{"name":"mg-prod","type":"Microsoft.Management/managementGroups",
+				"properties":{"displayName":"Production","parent":{"id":"/providers/Microsoft.Management/managementGroups/tenant-a"}}},
+			{"name":"mg-corp","type":"Microsoft.Management/managementGroups",
+				"properties":{"displayName":"Corp","parent":{"id":"/providers/Microsoft.Management/managementGroups/mg-prod"}}},
+			{"name":"sub-1","type":"/subscriptions",
+				"properties":{"displayName":"prod","parent":{"id":"/providers/Microsoft.Management/managementGroups/mg-corp"}}},
+			{"name":"sub-2","type":"/subscriptions",
+				"properties":{"displayName":"sandbox","parent":{"id":"/providers/Microsoft.Management/managementGroups/tenant-a"}}}]}`,
+	}}
+	p := &AzureProvider{
+		config:        config.ProviderConfig{HTTPClient: &http.Client{Transport: api}},
+		credential:    fakeCredential{token: "token"},
+		tenantID:      "tenant-a",
+		subscriptions: []models.AccountCount{{ID: "SUB-1"}, {ID: "sub-2"}, {ID: "sub-3"}},
+	}
+
+	p.placeSubscriptions(context.Background())
+
+	if p.managementGroupsWarning != "" {
+		t.Fatalf("unexpected warning: %s", p.managementGroupsWarning)
+	}
+	root := models.ManagementGroupRef{ID: "tenant-a", Name: "Tenant Root Group"}
+	want := [][]models.ManagementGroupRef{
+		{root, {ID: "mg-prod", Name: "Production"}, {ID: "mg-corp", Name: "Corp"}},
+		{root},
+		nil,
+	}
+	for i, sub := range p.subscriptions {
+		if !reflect.DeepEqual(sub.ManagementGroupPath, want[i]) {
+			t.Errorf("%s path = %+v, want %+v", sub.ID, sub.ManagementGroupPath, want[i])
+		}
+	}
+}
+
+func TestPlaceSubscriptionsDenied(t *testing.T) {
+	p := &AzureProvider{
+		config:        config.ProviderConfig{HTTPClient: &http.Client{Transport: &managementGroupsAPI{status: http.StatusForbidden}}},
+		credential:    fakeCredential{token: "token"},
+		tenantID:      "tenant-a",
+		subscriptions: []models.AccountCount{{ID: "sub-1"}},
+	}
+
+	p.placeSubscriptions(context.Background())
+
+	if !strings.Contains(p.managementGroupsWarning, "Microsoft.Management/managementGroups/read") {
+		t.Errorf("warning does not name the missing permission: %q", p.managementGroupsWarning)
+	}
+	if p.subscriptions[0].ManagementGroupPath != nil {
+		t.Errorf("unexpected path: %+v", p.subscriptions[0].ManagementGroupPath)
+	}
+}
+
+func TestManagementGroupPathCycle(t *testing.T) {
+	tree := &managementGroupTree{
+		groups:  map[string]models.ManagementGroupRef{"a": {ID: "a", Name: "A"}},
+		parents: map[string]string{"sub-1": "a", "a": "b", "b": "a"},
+	}
+	want := []models.ManagementGroupRef{{ID: "b", Name: "b"}, {ID: "a", Name: "A"}}
+	if got := tree.path("sub-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("path() = %+v, want %+v", got, want)
+	}
+}
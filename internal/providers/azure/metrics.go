@@ -0,0 +1,144 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/tracing"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+)
+
+// metricsProvider is the provider label of Azure metrics
+const metricsProvider = "azure"
+
+// attemptsKey holds the attempt counter of one API call in the request context
+type attemptsKey struct{}
+
+// userAgentPolicy puts the agent's product and version first in the
+// User-Agent, so calls can be told apart in the activity log. The SDK's
+// telemetry application ID cannot carry it, being capped at 24 characters.
+type userAgentPolicy struct{}
+
+func (userAgentPolicy) Do(req *policy.Request) (*http.Response, error) {
+	header := req.Raw().Header
+	userAgent := version.UserAgent()
+	if existing := header.Get("User-Agent"); existing != "" {
+		userAgent += " " + existing
+	}
+	header.Set("User-Agent", userAgent)
+	return req.Next()
+}
+
+// callMetricsPolicy records each API call once, before retries, and counts
+// it in calls and in the resource type's stats
+type callMetricsPolicy struct {
+	calls *metrics.APICallCounter
+}
+
+func (p callMetricsPolicy) Do(req *policy.Request) (*http.Response, error) {
+	service := apiService(req.Raw().URL)
+	metrics.APICall(metricsProvider, service)
+	p.calls.Add(service, apiOperation(req.Raw().Method, req.Raw().URL))
+	metrics.TypeStatsFrom(req.Raw().Context()).AddPage()
+	ctx := context.WithValue(req.Raw().Context(), attemptsKey{}, new(int))
+	return req.WithContext(ctx).Next()
+}
+
+// attemptMetricsPolicy records retries and throttled attempts, and the retry
+// count on the active trace span
+type attemptMetricsPolicy struct{}
+
+func (attemptMetricsPolicy) Do(req *policy.Request) (*http.Response, error) {
+	service := apiService(req.Raw().URL)
+	if attempts, ok := req.Raw().Context().Value(attemptsKey{}).(*int); ok {
+		if *attempts++; *attempts > 1 {
+			metrics.APIRetry(metricsProvider, service)
+			metrics.TypeStatsFrom(req.Raw().Context()).AddRetry()
+			tracing.SetRetryCount(req.Raw().Context(), *attempts-1)
+		}
+	}
+
+	resp, err := req.Next()
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		metrics.APIThrottled(metricsProvider, service)
+	}
+	return resp, err
+}
+
+// dumpPolicy passes the raw response of every attempt to dumper. Token
+// requests go through the credential's own pipeline and are never dumped.
+type dumpPolicy struct {
+	dumper *debugdump.Dumper
+}
+
+func (p dumpPolicy) Do(req *policy.Request) (*http.Response, error) {
+	resp, err := req.Next()
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return resp, err
+	}
+
+	u := req.Raw().URL
+	p.dumper.Write(req.Raw().Context(), debugdump.Response{
+		Provider:  metricsProvider,
+		Service:   apiService(u),
+		Operation: apiOperation(req.Raw().Method, u),
+		Method:    req.Raw().Method,
+		URL:       u.String(),
+		Status:    resp.StatusCode,
+		Body:      body,
+	})
+	return resp, nil
+}
+
+// apiService names the service a request goes to: the resource provider
+// namespace for ARM requests, otherwise the host
+func apiService(u *url.URL) string {
+	segments := strings.Split(u.Path, "/")
+	for i := len(segments) - 2; i >= 0; i-- {
+		if strings.EqualFold(segments[i], "providers") {
+			return strings.ToLower(segments[i+1])
+		}
+	}
+	if u.Host == "management.azure.com" {
+		return "microsoft.resources"
+	}
+	return u.Host
+}
+
+// apiOperation names a request by its method and the resource types of its
+// path, e.g. "GET microsoft.security/pricings" is "GET pricings". Names and
+// IDs alternate with the types, so they are dropped.
+func apiOperation(method string, u *url.URL) string {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	start := 0
+	for i := len(segments) - 2; i >= 0; i-- {
+		if strings.EqualFold(segments[i], "providers") {
+			start = i + 2
+			break
+		}
+	}
+	// Graph paths start with the API version
+	if start == 0 && len(segments) > 0 && (segments[0] == "v1.0" || segments[0] == "beta") {
+		start = 1
+	}
+
+	var types []string
+	for i := start; i < len(segments); i += 2 {
+		types = append(types, strings.ToLower(segments[i]))
+	}
+	return method + " " + strings.Join(types, "/")
+}
@@ -0,0 +1,155 @@
+package azure
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+)
+
+func TestAPIService(t *testing.T) {
+	tests := map[string]string{
+		"https://management.azure.com/providers/Microsoft.ResourceGraph/resources?api-version=2021-03-01":               "microsoft.resourcegraph",
+		"https://management.azure.com/subscriptions/sub-1/providers/Microsoft.Security/pricings?api-version=2024-01-01": "microsoft.security",
+		"https://management.azure.com/subscriptions?api-version=2022-12-01":                                             "microsoft.resources",
+		"https://graph.microsoft.com/v1.0/users/$count":                                                                 "graph.microsoft.com",
+	}
+
+	for raw, want := range tests {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := apiService(u); got != want {
+			t.Errorf("apiService(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestAPIOperation(t *testing.T) {
+	tests := []struct {
+		method string
+		raw    string
+		want   string
+	}{
+		{method: "POST", raw: "https://management.azure.com/providers/Microsoft.ResourceGraph/resources?api-version=2021-03-01", want: "POST resources"},
+		{method: "GET", raw: "https://management.azure.com/subscriptions/sub-1/providers/Microsoft.Security/pricings", want: "GET pricings"},
+		{method: "GET", raw: "https://management.azure.com/subscriptions/sub-1/resourceGroups/rg/providers/Microsoft.Web/sites/app/config", want: "GET sites/config"},
+		{method: "GET", raw: "https://management.azure.com/subscriptions?api-version=2022-12-01", want: "GET subscriptions"},
+		{method: "GET", raw: "https://graph.microsoft.com/v1.0/servicePrincipals/$count", want: "GET serviceprincipals"},
+	}
+
+	for _, tt := range tests {
+		u, err := url.Parse(tt.raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := apiOperation(tt.method, u); got != tt.want {
+			t.Errorf("apiOperation(%s %s) = %q, want %q", tt.method, tt.raw, got, tt.want)
+		}
+	}
+}
+
+// recordingTransport answers every request with 200 and keeps the requests
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) Do(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestClientOptionsIdentifyAndCount(t *testing.T) {
+	transport := &recordingTransport{}
+	calls := metrics.NewAPICallCounter()
+	p := &AzureProvider{config: config.ProviderConfig{APICalls: calls}}
+	options := p.clientOptions()
+	options.Transport = transport
+	pipeline := runtime.NewPipeline("test", "v0", runtime.PipelineOptions{}, &options)
+
+	ctx, stats := metrics.StartTypeStats(context.Background())
+	for i := 0; i < 2; i++ {
+		req, err := runtime.NewRequest(ctx, http.MethodGet,
+			"https://management.azure.com/subscriptions/sub-1/providers/Microsoft.Security/pricings")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pipeline.Do(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []models.APICallCount{{Service: "microsoft.security", Operation: "GET pricings", Count: 2}}
+	if got := calls.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %+v, want %+v", got, want)
+	}
+	if got := stats.Stats(); got.Pages != 2 || got.Retries != 0 {
+		t.Errorf("type stats = %+v, want 2 pages", got)
+	}
+	ua := transport.requests[0].Header.Get("User-Agent")
+	if !strings.HasPrefix(ua, version.UserAgent()+" ") {
+		t.Errorf("User-Agent = %q, want it to start with %q", ua, version.UserAgent())
+	}
+}
+
+// transportFunc answers requests with a function
+type transportFunc func(*http.Request) (*http.Response, error)
+
+func (f transportFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestClientOptionsDumpResponses(t *testing.T) {
+	dir := t.TempDir()
+	dumper, err := debugdump.New(dir, debugdump.DefaultMaxBytes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &AzureProvider{config: config.ProviderConfig{DebugDump: dumper}}
+	options := p.clientOptions()
+	options.Transport = transportFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Request: req,
+			Body: io.NopCloser(strings.NewReader(`{"data":[{"name":"vm-1","tags":{"owner":"jane"}}]}`))}, nil
+	})
+	pipeline := runtime.NewPipeline("test", "v0", runtime.PipelineOptions{}, &options)
+
+	req, err := runtime.NewRequest(debugdump.WithResourceType(context.Background(), "microsoft.compute/virtualmachines"),
+		http.MethodPost, "https://management.azure.com/providers/Microsoft.ResourceGraph/resources?api-version=2021-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := pipeline.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The client still reads the body the dump read
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || !strings.Contains(string(body), "jane") {
+		t.Errorf("body = %s, %v, want the untouched response", body, err)
+	}
+	if err := dumper.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dumped, err := os.ReadFile(filepath.Join(dir, "000001.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"operation": "POST resources"`, `"resource_type": "microsoft.compute/virtualmachines"`, `"owner": "[REDACTED]"`} {
+		if !strings.Contains(string(dumped), want) {
+			t.Errorf("dump = %s, want %s", dumped, want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package azure
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Resource types follow namespace/type(/subtype), e.g.
+	// microsoft.cdn/profiles/afdendpoints
+	resourceTypePattern = regexp.MustCompile(`(?i)^[a-z0-9]+(\.[a-z0-9]+)+(/[a-z0-9]+)+$`)
+	tablePattern        = regexp.MustCompile(`^[A-Za-z]+$`)
+	identifierPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	fieldPathPattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\[[0-9]+\])?(\.[A-Za-z_][A-Za-z0-9_]*(\[[0-9]+\])?)*$`)
+)
+
+// kqlQuery builds a Resource Graph query one operator per line. Literals are
+// always escaped and identifiers are checked against their grammar, so input
+// cannot add operators to the pipeline. The first error is kept and returned
+// by build.
+type kqlQuery struct {
+	lines []string
+	err   error
+}
+
+// newQuery starts a query selecting rows of resourceType from table
+func newQuery(table, resourceType string) *kqlQuery {
+	q := &kqlQuery{}
+	if !tablePattern.MatchString(table) {
+		q.err = fmt.Errorf("invalid Resource Graph table %q", table)
+		return q
+	}
+	if !resourceTypePattern.MatchString(resourceType) {
+		q.err = fmt.Errorf("invalid Azure resource type %q (expected namespace/type)", resourceType)
+		return q
+	}
+	q.lines = append(q.lines, table, "| where type =~ "+kqlString(resourceType))
+	return q
+}
+
+// where appends a filter expression taken from a resource definition. The
+// expression may use any KQL operator but must stay within one where clause.
+func (q *kqlQuery) where(expr string) *kqlQuery {
+	if err := checkExpression(expr); err != nil {
+		return q.fail(fmt.Errorf("invalid filter %q: %w", expr, err))
+	}
+	return q.add("| where " + expr)
+}
+
+// whereTag keeps resources whose tag key has value, ignoring case
+func (q *kqlQuery) whereTag(key, value string) *kqlQuery {
+	return q.add(fmt.Sprintf("| where tostring(tags[%s]) =~ %s", kqlString(key), kqlString(value)))
+}
+
+// extend adds column name holding field as a string
+func (q *kqlQuery) extend(name, field string) *kqlQuery {
+	if err := checkColumns(name); err != nil {
+		return q.fail(err)
+	}
+	if !fieldPathPattern.MatchString(field) {
+		return q.fail(fmt.Errorf("invalid field %q", field))
+	}
+	return q.add(fmt.Sprintf("| extend %s = tostring(%s)", name, field))
+}
+
+// extendCondition adds column name holding whether expr, a condition taken
+// from a resource definition, is true for the row
+func (q *kqlQuery) extendCondition(name, expr string) *kqlQuery {
+	if err := checkColumns(name); err != nil {
+		return q.fail(err)
+	}
+	if err := checkExpression(expr); err != nil {
+		return q.fail(fmt.Errorf("invalid condition %q: %w", expr, err))
+	}
+	return q.add(fmt.Sprintf("| extend %s = tobool(%s)", name, expr))
+}
+
+// extendExposure adds column name holding "public" where field is set and
+// "internal" elsewhere
+func (q *kqlQuery) extendExposure(name, field string) *kqlQuery {
+	if err := checkColumns(name); err != nil {
+		return q.fail(err)
+	}
+	if !fieldPathPattern.MatchString(field) {
+		return q.fail(fmt.Errorf("invalid field %q", field))
+	}
+	return q.add(fmt.Sprintf(`| extend %s = iff(isnotempty(%s), "public", "internal")`, name, field))
+}
+
+// countBy counts rows per group, projecting the count as "count"
+func (q *kqlQuery) countBy(groupBy ...string) *kqlQuery {
+	if err := checkColumns(groupBy...); err != nil {
+		return q.fail(err)
+	}
+	columns := strings.Join(groupBy, ", ")
+	return q.add("| summarize count() by " + columns).
+		add("| project " + columns + ", count = count_")
+}
+
+// sumBy sums field per group, projecting the total as "count"
+func (q *kqlQuery) sumBy(field string, groupBy ...string) *kqlQuery {
+	if !fieldPathPattern.MatchString(field) {
+		return q.fail(fmt.Errorf("invalid field %q", field))
+	}
+	if err := checkColumns(groupBy...); err != nil {
+		return q.fail(err)
+	}
+	columns := strings.Join(groupBy, ", ")
+	return q.add(fmt.Sprintf("| summarize total = sum(toint(%s)) by %s", field, columns)).
+		add("| project " + columns + ", count = total")
+}
+
+// project keeps only columns
+func (q *kqlQuery) project(columns ...string) *kqlQuery {
+	if err := checkColumns(columns...); err != nil {
+		return q.fail(err)
+	}
+	return q.add("| project " + strings.Join(columns, ", "))
+}
+
+// build returns the query text or the first error met while building it
+func (q *kqlQuery) build() (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	return strings.Join(q.lines, "\n"), nil
+}
+
+func (q *kqlQuery) add(line string) *kqlQuery {
+	if q.err == nil {
+		q.lines = append(q.lines, line)
+	}
+	return q
+}
+
+func (q *kqlQuery) fail(err error) *kqlQuery {
+	if q.err == nil {
+		q.err = err
+	}
+	return q
+}
+
+// kqlString quotes s as a KQL string literal so that quotes, backslashes
+// and line breaks cannot end the literal early
+func kqlString(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + replacer.Replace(s) + `"`
+}
+
+func checkColumns(columns ...string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns given")
+	}
+	for _, column := range columns {
+		if !identifierPattern.MatchString(column) {
+			return fmt.Errorf("invalid column %q", column)
+		}
+	}
+	return nil
+}
+
+// checkExpression rejects expressions that could leave their where clause:
+// pipes, statement separators, comments, line breaks and unbalanced
+// parentheses or quotes outside string literals
+func checkExpression(expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		return fmt.Errorf("empty expression")
+	}
+
+	depth := 0
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; c {
+		case '"', '\'':
+			end, err := skipLiteral(expr, i)
+			if err != nil {
+				return err
+			}
+			i = end
+		case '@':
+			if i+1 < len(expr) && (expr[i+1] == '"' || expr[i+1] == '\'') {
+				end, err := skipVerbatim(expr, i+1)
+				if err != nil {
+					return err
+				}
+				i = end
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("unbalanced parentheses")
+			}
+		case '|', ';', '\n', '\r':
+			return fmt.Errorf("%q is not allowed outside string literals", c)
+		case '/':
+			if i+1 < len(expr) && expr[i+1] == '/' {
+				return fmt.Errorf("comments are not allowed")
+			}
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unbalanced parentheses")
+	}
+	return nil
+}
+
+// skipLiteral returns the index of the quote closing the literal opened at
+// start, honouring backslash escapes
+func skipLiteral(expr string, start int) (int, error) {
+	quote := expr[start]
+	for i := start + 1; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case '\n', '\r':
+			return 0, fmt.Errorf("line break in string literal")
+		case quote:
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string literal")
+}
+
+// skipVerbatim returns the index of the quote closing the verbatim literal
+// opened at start, where a doubled quote stands for itself
+func skipVerbatim(expr string, start int) (int, error) {
+	quote := expr[start]
+	for i := start + 1; i < len(expr); i++ {
+		switch expr[i] {
+		case '\n', '\r':
+			return 0, fmt.Errorf("line break in string literal")
+		case quote:
+			if i+1 < len(expr) && expr[i+1] == quote {
+				i++
+				continue
+			}
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string literal")
+}
@@ -0,0 +1,160 @@
+package azure
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestKqlString(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "prod", want: `"prod"`},
+		{input: `a"b`, want: `"a\"b"`},
+		{input: `a\`, want: `"a\\"`},
+		{input: `x\" | take 1`, want: `"x\\\" | take 1"`},
+		{input: "a'b", want: `"a'b"`},
+		{input: "line\nbreak\r", want: `"line\nbreak\r"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := kqlString(tt.input)
+			if got != tt.want {
+				t.Errorf("kqlString(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+			// The literal must close exactly at its last character
+			if end, err := skipLiteral(got, 0); err != nil || end != len(got)-1 {
+				t.Errorf("kqlString(%q) = %s does not form a single literal", tt.input, got)
+			}
+		})
+	}
+}
+
+func TestNewQueryValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		table        string
+		resourceType string
+		wantErr      bool
+	}{
+		{name: "type", table: "Resources", resourceType: "microsoft.compute/virtualmachines"},
+		{name: "subtype", table: "Resources", resourceType: "Microsoft.Sql/servers/databases"},
+		{name: "missing type", table: "Resources", resourceType: "microsoft.compute", wantErr: true},
+		{name: "missing namespace", table: "Resources", resourceType: "compute/virtualmachines", wantErr: true},
+		{name: "quote", table: "Resources", resourceType: `microsoft.compute/vms" or 1==1`, wantErr: true},
+		{name: "pipe", table: "Resources", resourceType: "microsoft.compute/vms|take 1", wantErr: true},
+		{name: "trailing slash", table: "Resources", resourceType: "microsoft.compute/vms/", wantErr: true},
+		{name: "table injection", table: "Resources | take 1", resourceType: "microsoft.compute/virtualmachines", wantErr: true},
+		{name: "empty table", table: "", resourceType: "microsoft.compute/virtualmachines", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newQuery(tt.table, tt.resourceType).build()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckExpression(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: `kind !has "functionapp" and kind !has "workflowapp"`},
+		{expr: `name startswith "SecurityInsights("`},
+		{expr: `properties.type == 'Custom|Role'`},
+		{expr: `name == "a\"; b"`},
+		{expr: `name == @"C:\dir"`},
+		{expr: `name == @'it''s'`},
+		{expr: `(kind =~ "a" or kind =~ "b") and isnotnull(sku)`},
+		{expr: "", wantErr: true},
+		{expr: `name == "a" | take 1`, wantErr: true},
+		{expr: `name == "a"; Resources`, wantErr: true},
+		{expr: `name == "a" // comment`, wantErr: true},
+		{expr: "name == \"a\"\n| take 1", wantErr: true},
+		{expr: `name == "a`, wantErr: true},
+		{expr: `name == "a\"`, wantErr: true},
+		{expr: `name == @"a\" | take 1`, wantErr: true},
+		{expr: `(name == "a"`, wantErr: true},
+		{expr: `name == "a")`, wantErr: true},
+		{expr: "name == \"a\nb\"", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			err := checkExpression(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkExpression(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder(t *testing.T) {
+	query, err := newQuery("Resources", "microsoft.web/sites").
+		whereTag(`env"]) or true or tostring(tags["x`, `prod" or 1==1`).
+		extend("sku", "sku.name").
+		countBy("location", "sku").
+		build()
+	if err != nil {
+		t.Fatalf("build() error = %v", err)
+	}
+
+	want := strings.Join([]string{
+		"Resources",
+		`| where type =~ "microsoft.web/sites"`,
+		`| where tostring(tags["env\"]) or true or tostring(tags[\"x"]) =~ "prod\" or 1==1"`,
+		`| extend sku = tostring(sku.name)`,
+		`| summarize count() by location, sku`,
+		`| project location, sku, count = count_`,
+	}, "\n")
+	if query != want {
+		t.Errorf("build() =\n%s\nwant\n%s", query, want)
+	}
+}
+
+func TestQueryBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query *kqlQuery
+	}{
+		{name: "filter", query: newQuery("Resources", "microsoft.web/sites").where(`kind == "a" | take 1`)},
+		{name: "extend field", query: newQuery("Resources", "microsoft.web/sites").extend("sku", "sku.name) | take 1 | extend x=(1")},
+		{name: "extend column", query: newQuery("Resources", "microsoft.web/sites").extend("sku = 1, x", "sku.name")},
+		{name: "condition", query: newQuery("Resources", "microsoft.web/sites").extendCondition("noise", `true) | take 1 | extend x=(1`)},
+		{name: "exposure field", query: newQuery("Resources", "microsoft.web/sites").extendExposure("exposure", "properties.ips[0]), 1, 2) | take 1 | extend x=(1")},
+		{name: "sum field", query: newQuery("Resources", "microsoft.web/sites").sumBy("sku.capacity))", "location")},
+		{name: "group column", query: newQuery("Resources", "microsoft.web/sites").countBy("location; Resources")},
+		{name: "no columns", query: newQuery("Resources", "microsoft.web/sites").project()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, err := tt.query.build(); err == nil {
+				t.Errorf("build() = %q, want an error", query)
+			}
+		})
+	}
+}
+
+func TestDefaultDefinitionsBuildQueries(t *testing.T) {
+	set, err := models.DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, def := range set.ForProvider("azure") {
+		if def.CountMethod != models.CountMethodResourceGraph {
+			continue
+		}
+		if _, err := countQuery(def, []models.TagFilter{{Key: "env", Value: "prod"}}, true); err != nil {
+			t.Errorf("countQuery(%s) error = %v", def.Type, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	// registryType is the resource type whose repositories --deep-registries
+	// counts
+	registryType = "microsoft.containerregistry/registries"
+
+	// acrScope is the audience of the Microsoft Entra token exchanged for a
+	// registry token
+	acrScope = "https://containerregistry.azure.net/.default"
+
+	// registryConcurrency bounds the registries read at once
+	registryConcurrency = 4
+
+	// catalogPageSize is the number of repositories asked for per page
+	catalogPageSize = 1000
+)
+
+// RegistryCollector counts the repositories of Azure Container Registries
+// through each registry's data-plane API. The scan's credential is exchanged
+// for a registry token with catalog access, which requires a data-plane role
+// such as AcrPull or Container Registry Repository Catalog Lister.
+type RegistryCollector struct {
+	credential azcore.TokenCredential
+	pipeline   runtime.Pipeline
+	sem        *semaphore.Weighted
+
+	// endpoint returns the base URL of the registry with a login server
+	endpoint func(loginServer string) string
+}
+
+// NewRegistryCollector creates a collector that signs in to registries with
+// credential and sends requests with options
+func NewRegistryCollector(credential azcore.TokenCredential, options policy.ClientOptions) *RegistryCollector {
+	return &RegistryCollector{
+		credential: credential,
+		pipeline:   runtime.NewPipeline(version.Product, version.Get(), runtime.PipelineOptions{}, &options),
+		sem:        semaphore.NewWeighted(registryConcurrency),
+		endpoint: func(loginServer string) string {
+			return "https://" + loginServer
+		},
+	}
+}
+
+// CountRepositories counts the repositories of every registry in
+// loginServers. Registries that cannot be read, e.g. because the credential
+// has no data-plane role or a firewall blocks the registry, are returned as
+// unknown rather than failing the count.
+func (c *RegistryCollector) CountRepositories(ctx context.Context, loginServers []string) (repositories, unknown int) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, loginServer := range loginServers {
+		if err := c.sem.Acquire(ctx, 1); err != nil {
+			mu.Lock()
+			unknown++
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(loginServer string) {
+			defer wg.Done()
+			defer c.sem.Release(1)
+
+			count, err := c.countRegistry(ctx, loginServer)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logging.FromContext(ctx).Warn("Failed to list registry repositories; counting the registry without them",
+					zap.String("registry", loginServer),
+					zap.Error(err))
+				unknown++
+				return
+			}
+			repositories += count
+		}(loginServer)
+	}
+	wg.Wait()
+	return repositories, unknown
+}
+
+// countRegistry pages through the catalog of one registry
+func (c *RegistryCollector) countRegistry(ctx context.Context, loginServer string) (int, error) {
+	token, err := c.catalogToken(ctx, loginServer)
+	if err != nil {
+		return 0, err
+	}
+
+	base := c.endpoint(loginServer)
+	next := fmt.Sprintf("%s/acr/v1/_catalog?n=%d", base, catalogPageSize)
+	count := 0
+	for next != "" {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, next)
+		if err != nil {
+			return 0, err
+		}
+		req.Raw().Header.Set("Authorization", "Bearer "+token)
+
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		header, err := c.do(req, &page)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list repositories: %w", err)
+		}
+		count += len(page.Repositories)
+		next = nextCatalogPage(base, header.Get("Link"))
+	}
+	return count, nil
+}
+
+// catalogToken exchanges a Microsoft Entra token for a registry refresh
+// token, and that for an access token allowed to list the catalog
+func (c *RegistryCollector) catalogToken(ctx context.Context, loginServer string) (string, error) {
+	entraToken, err := c.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{acrScope}})
+	if err != nil {
+		return "", fmt.Errorf("failed to get a token for the registry: %w", err)
+	}
+
+	base := c.endpoint(loginServer)
+	var exchanged struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.postForm(ctx, base+"/oauth2/exchange", url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {loginServer},
+		"access_token": {entraToken.Token},
+	}, &exchanged); err != nil {
+		return "", fmt.Errorf("failed to exchange the token for a registry token: %w", err)
+	}
+
+	var access struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := c.postForm(ctx, base+"/oauth2/token", url.Values{
+		"grant_type":    {"refresh_token"},
+		"service":       {loginServer},
+		"scope":         {"registry:catalog:*"},
+		"refresh_token": {exchanged.RefreshToken},
+	}, &access); err != nil {
+		return "", fmt.Errorf("failed to get a registry access token: %w", err)
+	}
+	return access.AccessToken, nil
+}
+
+// postForm posts form to endpoint and decodes the JSON response into v
+func (c *RegistryCollector) postForm(ctx context.Context, endpoint string, form url.Values, v any) error {
+	req, err := runtime.NewRequest(ctx, http.MethodPost, endpoint)
+	if err != nil {
+		return err
+	}
+	body := streaming.NopCloser(strings.NewReader(form.Encode()))
+	if err := req.SetBody(body, "application/x-www-form-urlencoded"); err != nil {
+		return err
+	}
+	_, err = c.do(req, v)
+	return err
+}
+
+// do sends req and decodes a successful JSON response into v, returning its
+// headers
+func (c *RegistryCollector) do(req *policy.Request, v any) (http.Header, error) {
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return nil, runtime.NewResponseError(resp)
+	}
+	return resp.Header, runtime.UnmarshalAsJSON(resp, v)
+}
+
+// nextCatalogPage returns the URL of the page a catalog Link header points
+// to, or "" on the last page. The header holds a path relative to base, as
+// in </acr/v1/_catalog?last=web&n=1000>; rel="next".
+func nextCatalogPage(base, link string) string {
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start < 0 || end < start || !strings.Contains(link[end:], `rel="next"`) {
+		return ""
+	}
+	return base + link[start+1:end]
+}
+
+// ListLoginServers lists the login servers of the registries of resourceDef
+// in subscriptions, applying the definition's filter and the tag filters
+func (c *ResourceCollector) ListLoginServers(
+	ctx context.Context,
+	resourceDef models.ResourceDefinition,
+	subscriptions []string,
+	graphClient resourceGraphQuerier,
+) ([]string, error) {
+
+	queryText, err := baseQuery(resourceDef, c.tagFilters).
+		extend("loginServer", "properties.loginServer").
+		project("loginServer").
+		build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query for %s: %w", resourceDef.Type, err)
+	}
+
+	rows, err := listRows(ctx, queryText, subscriptions, graphClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", resourceDef.Type, err)
+	}
+	var loginServers []string
+	for _, row := range rows {
+		if loginServer, _ := row["loginServer"].(string); loginServer != "" {
+			loginServers = append(loginServers, loginServer)
+		}
+	}
+	return loginServers, nil
+}
+
+// countRepositories adds the repositories of the registries in count, read
+// with registries. When the registries cannot be listed, all of them are
+// counted as unknown.
+func (p *AzureProvider) countRepositories(
+	ctx context.Context,
+	registries *RegistryCollector,
+	count *models.ResourceCount,
+	resourceDef models.ResourceDefinition,
+	subscriptions []string,
+	graphClient resourceGraphQuerier,
+) {
+	loginServers, err := p.collector.ListLoginServers(ctx, resourceDef, subscriptions, graphClient)
+	if err != nil {
+		p.config.Log().Warn("Failed to list container registries; their repositories are unknown", zap.Error(err))
+		count.ContentUnknown = count.TotalResources
+		return
+	}
+	count.Repositories, count.ContentUnknown = registries.CountRepositories(ctx, loginServers)
+}
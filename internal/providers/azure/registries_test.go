@@ -0,0 +1,123 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// fakeRegistries serves the token exchange and catalog of registries keyed
+// by login server, two repositories per catalog page. Registries missing
+// from repositories deny the token exchange.
+func fakeRegistries(t *testing.T, repositories map[string][]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loginServer := strings.SplitN(r.URL.Path, "/", 3)[1]
+		path := strings.TrimPrefix(r.URL.Path, "/"+loginServer)
+		repos, ok := repositories[loginServer]
+
+		switch path {
+		case "/oauth2/exchange":
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("access_token") != "entra-token" || !ok {
+				http.Error(w, `{"errors":[{"code":"UNAUTHORIZED"}]}`, http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"refresh_token":"refresh"}`)
+		case "/oauth2/token":
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("scope") != "registry:catalog:*" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"access"}`)
+		case "/acr/v1/_catalog":
+			if r.Header.Get("Authorization") != "Bearer access" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			start := 0
+			if last := r.URL.Query().Get("last"); last != "" {
+				for i, repo := range repos {
+					if repo == last {
+						start = i + 1
+					}
+				}
+			}
+			end := min(start+2, len(repos))
+			if end < len(repos) {
+				w.Header().Set("Link", fmt.Sprintf(`</acr/v1/_catalog?last=%s&n=2>; rel="next"`, repos[end-1]))
+			}
+			_ = json.NewEncoder(w).Encode(map[string][]string{"repositories": repos[start:end]})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestCountRepositories(t *testing.T) {
+	server := fakeRegistries(t, map[string][]string{
+		"prod.azurecr.io": {"api", "web", "worker"},
+		"dev.azurecr.io":  {"sandbox"},
+	})
+	defer server.Close()
+
+	collector := NewRegistryCollector(fakeCredential{token: "entra-token"}, policy.ClientOptions{})
+	// Each login server is a path prefix of the fake, since Link headers are
+	// relative to the registry
+	collector.endpoint = func(loginServer string) string {
+		return server.URL + "/" + loginServer
+	}
+
+	repositories, unknown := collector.CountRepositories(context.Background(),
+		[]string{"prod.azurecr.io", "dev.azurecr.io", "locked.azurecr.io"})
+	if repositories != 4 || unknown != 1 {
+		t.Errorf("CountRepositories() = %d, %d, want 4 repositories and 1 unknown registry", repositories, unknown)
+	}
+}
+
+func TestNextCatalogPage(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{link: `</acr/v1/_catalog?last=web&n=1000>; rel="next"`, want: "https://r.azurecr.io/acr/v1/_catalog?last=web&n=1000"},
+		{link: "", want: ""},
+		{link: `</acr/v1/_catalog?last=web>; rel="prev"`, want: ""},
+	}
+	for _, tt := range tests {
+		if got := nextCatalogPage("https://r.azurecr.io", tt.link); got != tt.want {
+			t.Errorf("nextCatalogPage(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestListLoginServers(t *testing.T) {
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"":   graphPage("t2", map[string]interface{}{"loginServer": "prod.azurecr.io"}),
+		"t2": graphPage("", map[string]interface{}{"loginServer": "dev.azurecr.io"}, map[string]interface{}{"loginServer": ""}),
+	}}
+	collector := NewResourceCollector(nil, nil, []models.TagFilter{{Key: "env", Value: "prod"}}, false)
+
+	got, err := collector.ListLoginServers(context.Background(),
+		models.ResourceDefinition{Type: registryType}, []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "prod.azurecr.io" || got[1] != "dev.azurecr.io" {
+		t.Errorf("ListLoginServers() = %v, want prod.azurecr.io and dev.azurecr.io", got)
+	}
+
+	query := *graph.requests[0].Query
+	for _, want := range []string{"| extend loginServer = tostring(properties.loginServer)", "env"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query %q does not contain %q", query, want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	// storageAccountType is the resource type whose contents --deep-storage
+	// counts
+	storageAccountType = "microsoft.storage/storageaccounts"
+
+	// storageConcurrency bounds the storage accounts read at once
+	storageConcurrency = 4
+)
+
+// storageObject is a kind of sub-resource --deep-storage counts inside
+// storage accounts
+type storageObject struct {
+	def models.ResourceDefinition
+
+	// kinds are the storage account kinds that offer the service holding
+	// the objects; the others are not asked, as the call would fail
+	kinds []string
+
+	// count counts the objects of one account
+	count func(ctx context.Context, factory *armstorage.ClientFactory, group, account string) (int, error)
+}
+
+// storageObjects are the sub-resources counted in deep storage mode, each
+// reported on its own line
+var storageObjects = []storageObject{
+	{
+		def: models.ResourceDefinition{
+			Type:        "microsoft.storage/storageaccounts/blobservices/containers",
+			DisplayName: "Blob Containers",
+			Category:    "Storage",
+		},
+		kinds: []string{"Storage", "StorageV2", "BlobStorage", "BlockBlobStorage"},
+		count: func(ctx context.Context, factory *armstorage.ClientFactory, group, account string) (int, error) {
+			count := 0
+			pager := factory.NewBlobContainersClient().NewListPager(group, account, nil)
+			for pager.More() {
+				page, err := pager.NextPage(ctx)
+				if err != nil {
+					return 0, fmt.Errorf("failed to list blob containers: %w", err)
+				}
+				count += len(page.Value)
+			}
+			return count, nil
+		},
+	},
+	{
+		def: models.ResourceDefinition{
+			Type:        "microsoft.storage/storageaccounts/fileservices/shares",
+			DisplayName: "File Shares",
+			Category:    "Storage",
+		},
+		kinds: []string{"Storage", "StorageV2", "FileStorage"},
+		count: func(ctx context.Context, factory *armstorage.ClientFactory, group, account string) (int, error) {
+			count := 0
+			pager := factory.NewFileSharesClient().NewListPager(group, account, nil)
+			for pager.More() {
+				page, err := pager.NextPage(ctx)
+				if err != nil {
+					return 0, fmt.Errorf("failed to list file shares: %w", err)
+				}
+				count += len(page.Value)
+			}
+			return count, nil
+		},
+	},
+	{
+		def: models.ResourceDefinition{
+			Type:        "microsoft.storage/storageaccounts/queueservices/queues",
+			DisplayName: "Storage Queues",
+			Category:    "Storage",
+		},
+		kinds: []string{"Storage", "StorageV2"},
+		count: func(ctx context.Context, factory *armstorage.ClientFactory, group, account string) (int, error) {
+			count := 0
+			pager := factory.NewQueueClient().NewListPager(group, account, nil)
+			for pager.More() {
+				page, err := pager.NextPage(ctx)
+				if err != nil {
+					return 0, fmt.Errorf("failed to list queues: %w", err)
+				}
+				count += len(page.Value)
+			}
+			return count, nil
+		},
+	},
+	{
+		def: models.ResourceDefinition{
+			Type:        "microsoft.storage/storageaccounts/tableservices/tables",
+			DisplayName: "Storage Tables",
+			Category:    "Storage",
+		},
+		kinds: []string{"Storage", "StorageV2"},
+		count: func(ctx context.Context, factory *armstorage.ClientFactory, group, account string) (int, error) {
+			count := 0
+			pager := factory.NewTableClient().NewListPager(group, account, nil)
+			for pager.More() {
+				page, err := pager.NextPage(ctx)
+				if err != nil {
+					return 0, fmt.Errorf("failed to list tables: %w", err)
+				}
+				count += len(page.Value)
+			}
+			return count, nil
+		},
+	},
+}
+
+// storageAccount is a storage account whose contents are counted
+type storageAccount struct {
+	ID             string
+	SubscriptionID string
+	Location       string
+	Kind           string
+}
+
+// StorageCollector counts the blob containers, file shares, queues and
+// tables of storage accounts through the Azure Resource Manager storage API,
+// one account at a time per worker
+type StorageCollector struct {
+	credential azcore.TokenCredential
+	options    arm.ClientOptions
+	sem        *semaphore.Weighted
+}
+
+// NewStorageCollector creates a collector that authenticates to Azure
+// Resource Manager with credential and sends requests with options
+func NewStorageCollector(credential azcore.TokenCredential, options policy.ClientOptions) *StorageCollector {
+	return &StorageCollector{
+		credential: credential,
+		options:    arm.ClientOptions{ClientOptions: options},
+		sem:        semaphore.NewWeighted(storageConcurrency),
+	}
+}
+
+// CountContents counts the contents of accounts, returning one count per
+// storage object and the number of accounts that could not be inspected.
+// Such accounts, e.g. ones whose network rules or locks deny the listing,
+// are left out of every count rather than failing it.
+func (c *StorageCollector) CountContents(ctx context.Context, accounts []storageAccount) ([]*models.ResourceCount, int) {
+	counts := make([]*models.ResourceCount, len(storageObjects))
+	for i, object := range storageObjects {
+		counts[i] = &models.ResourceCount{
+			Provider:      "Azure",
+			Type:          object.def.ResourceType(),
+			DisplayName:   object.def.DisplayName,
+			CanonicalType: object.def.CanonicalType(),
+			Category:      object.def.Category,
+			ByLocation:    make(map[string]int),
+			ByAccount:     make(map[string]int),
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	inaccessible := 0
+	for _, account := range accounts {
+		if err := c.sem.Acquire(ctx, 1); err != nil {
+			mu.Lock()
+			inaccessible++
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(account storageAccount) {
+			defer wg.Done()
+			defer c.sem.Release(1)
+
+			contents, err := c.countAccount(ctx, account)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logging.FromContext(ctx).Warn("Failed to inspect storage account; counting the account without its contents",
+					zap.String("account", account.ID),
+					zap.Error(err))
+				inaccessible++
+				return
+			}
+			for i, count := range contents {
+				if count == 0 {
+					continue
+				}
+				counts[i].TotalResources += count
+				counts[i].ByLocation[account.Location] += count
+				counts[i].ByAccount[account.SubscriptionID] += count
+			}
+		}(account)
+	}
+	wg.Wait()
+	return counts, inaccessible
+}
+
+// countAccount counts the objects of each storage object in one account,
+// in the order of storageObjects. Any failed listing fails the account, so
+// that it is never counted in part.
+func (c *StorageCollector) countAccount(ctx context.Context, account storageAccount) ([]int, error) {
+	id, err := arm.ParseResourceID(account.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage account ID: %w", err)
+	}
+	factory, err := armstorage.NewClientFactory(id.SubscriptionID, c.credential, &c.options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+
+	contents := make([]int, len(storageObjects))
+	for i, object := range storageObjects {
+		if !slices.Contains(object.kinds, account.Kind) {
+			continue
+		}
+		contents[i], err = object.count(ctx, factory, id.ResourceGroupName, id.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return contents, nil
+}
+
+// ListStorageAccounts lists the storage accounts of resourceDef in
+// subscriptions, applying the definition's filter and the tag filters
+func (c *ResourceCollector) ListStorageAccounts(
+	ctx context.Context,
+	resourceDef models.ResourceDefinition,
+	subscriptions []string,
+	graphClient resourceGraphQuerier,
+) ([]storageAccount, error) {
+
+	queryText, err := baseQuery(resourceDef, c.tagFilters).
+		project("id", "subscriptionId", "location", "kind").
+		build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query for %s: %w", resourceDef.Type, err)
+	}
+
+	rows, err := listRows(ctx, queryText, subscriptions, graphClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", resourceDef.Type, err)
+	}
+	accounts := make([]storageAccount, 0, len(rows))
+	for _, row := range rows {
+		account := storageAccount{}
+		account.ID, _ = row["id"].(string)
+		account.SubscriptionID, _ = row["subscriptionId"].(string)
+		account.Location, _ = row["location"].(string)
+		account.Kind, _ = row["kind"].(string)
+		if account.ID != "" {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+// countStorageContents counts the contents of the storage accounts in
+// count, read with storage, and records the accounts it could not inspect
+// as ContentUnknown. When the accounts cannot be listed, all of them are
+// unknown and no content lines are returned.
+func (p *AzureProvider) countStorageContents(
+	ctx context.Context,
+	storage *StorageCollector,
+	count *models.ResourceCount,
+	resourceDef models.ResourceDefinition,
+	subscriptions []string,
+	graphClient resourceGraphQuerier,
+) []*models.ResourceCount {
+	accounts, err := p.collector.ListStorageAccounts(ctx, resourceDef, subscriptions, graphClient)
+	if err != nil {
+		p.config.Log().Warn("Failed to list storage accounts; their contents are unknown", zap.Error(err))
+		count.ContentUnknown = count.TotalResources
+		return nil
+	}
+	var contents []*models.ResourceCount
+	contents, count.ContentUnknown = storage.CountContents(ctx, accounts)
+	return contents
+}
+
+// storageContentsWarning explains that --deep-storage could not inspect
+// unknown of total storage accounts, or returns "" when it inspected all
+func storageContentsWarning(unknown, total int) string {
+	if unknown == 0 {
+		return ""
+	}
+	return fmt.Sprintf("--deep-storage could not inspect %d of %d storage accounts; "+
+		"their blob containers, file shares, queues and tables are not counted", unknown, total)
+}
@@ -0,0 +1,101 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// accountPath is the Resource Manager path of a storage account
+func accountPath(subscription, group, name string) string {
+	return "/subscriptions/" + subscription + "/resourceGroups/" + group +
+		"/providers/Microsoft.Storage/storageAccounts/" + name
+}
+
+func TestCountContents(t *testing.T) {
+	general := accountPath("sub-1", "rg-data", "general")
+	files := accountPath("sub-2", "rg-files", "files")
+	// The storage API answers with managementGroupsAPI's canned bodies by
+	// path, and denies the locked account
+	api := &managementGroupsAPI{status: http.StatusForbidden, bodies: map[string]string{
+		general + "/blobServices/default/containers": `{"value":[{"name":"logs"},{"name":"backups"}]}`,
+		general + "/fileServices/default/shares":     `{"value":[{"name":"home"}]}`,
+		general + "/queueServices/default/queues":    `{"value":[{"name":"jobs"}]}`,
+		general + "/tableServices/default/tables":    `{"value":[]}`,
+		files + "/fileServices/default/shares":       `{"value":[{"name":"a"},{"name":"b"},{"name":"c"}]}`,
+	}}
+	collector := NewStorageCollector(fakeCredential{token: "token"},
+		policy.ClientOptions{Transport: &http.Client{Transport: api}})
+
+	counts, inaccessible := collector.CountContents(context.Background(), []storageAccount{
+		{ID: general, SubscriptionID: "sub-1", Location: "westeurope", Kind: "StorageV2"},
+		// Only the file service is asked for a FileStorage account
+		{ID: files, SubscriptionID: "sub-2", Location: "northeurope", Kind: "FileStorage"},
+		{ID: accountPath("sub-1", "rg-data", "locked"), SubscriptionID: "sub-1", Location: "westeurope", Kind: "StorageV2"},
+	})
+	if inaccessible != 1 {
+		t.Errorf("inaccessible = %d, want 1", inaccessible)
+	}
+
+	want := map[models.ResourceType]int{
+		"microsoft.storage/storageaccounts/blobservices/containers": 2,
+		"microsoft.storage/storageaccounts/fileservices/shares":     4,
+		"microsoft.storage/storageaccounts/queueservices/queues":    1,
+		"microsoft.storage/storageaccounts/tableservices/tables":    0,
+	}
+	if len(counts) != len(want) {
+		t.Fatalf("got %d counts, want %d", len(counts), len(want))
+	}
+	for _, count := range counts {
+		if count.TotalResources != want[count.Type] {
+			t.Errorf("%s = %d, want %d", count.Type, count.TotalResources, want[count.Type])
+		}
+		if count.Category != "Storage" || count.CanonicalType == "" {
+			t.Errorf("%s category = %q, canonical type = %q", count.Type, count.Category, count.CanonicalType)
+		}
+	}
+	shares := counts[1]
+	if shares.ByLocation["northeurope"] != 3 || shares.ByAccount["sub-1"] != 1 {
+		t.Errorf("file shares by location %v and account %v", shares.ByLocation, shares.ByAccount)
+	}
+}
+
+func TestListStorageAccounts(t *testing.T) {
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"": graphPage("t2", map[string]interface{}{
+			"id": accountPath("sub-1", "rg", "general"), "subscriptionId": "sub-1", "location": "westeurope", "kind": "StorageV2",
+		}),
+		"t2": graphPage("", map[string]interface{}{"id": ""}),
+	}}
+	collector := NewResourceCollector(nil, nil, []models.TagFilter{{Key: "env", Value: "prod"}}, false)
+
+	got, err := collector.ListStorageAccounts(context.Background(),
+		models.ResourceDefinition{Type: storageAccountType}, []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Kind != "StorageV2" || got[0].Location != "westeurope" {
+		t.Errorf("ListStorageAccounts() = %+v, want the general account only", got)
+	}
+
+	query := *graph.requests[0].Query
+	for _, want := range []string{"| project id, subscriptionId, location, kind", "env"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query %q does not contain %q", query, want)
+		}
+	}
+}
+
+func TestStorageContentsWarning(t *testing.T) {
+	if got := storageContentsWarning(0, 12); got != "" {
+		t.Errorf("storageContentsWarning(0, 12) = %q, want none", got)
+	}
+	if got := storageContentsWarning(2, 12); !strings.Contains(got, "2 of 12 storage accounts") {
+		t.Errorf("storageContentsWarning(2, 12) = %q", got)
+	}
+}
@@ -0,0 +1,319 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	// vaultType is the resource type whose items --deep-backup counts
+	vaultType = "microsoft.recoveryservices/vaults"
+
+	// vaultConcurrency bounds the vaults read at once. Recovery Services
+	// throttles reads per subscription well below Resource Manager's own
+	// limit, so it is lower than that of the other deep modes.
+	vaultConcurrency = 2
+
+	// API versions of the Azure Backup and Site Recovery item listings
+	backupItemsAPIVersion     = "2024-04-01"
+	replicatedItemsAPIVersion = "2023-08-01"
+)
+
+// vaultItem holds the properties of a backup or replicated item that
+// --deep-backup breaks its counts down by
+type vaultItem struct {
+	Properties struct {
+		// Backup items
+		WorkloadType    string `json:"workloadType"`
+		ProtectionState string `json:"protectionState"`
+
+		// Site Recovery replicated items
+		ReplicationHealth       string `json:"replicationHealth"`
+		ProviderSpecificDetails struct {
+			InstanceType string `json:"instanceType"`
+		} `json:"providerSpecificDetails"`
+	} `json:"properties"`
+}
+
+// vaultObject is a kind of item --deep-backup counts inside Recovery
+// Services vaults
+type vaultObject struct {
+	def models.ResourceDefinition
+
+	// path is the collection of the items under the vault's ID, and
+	// apiVersion the version it is read with
+	path       string
+	apiVersion string
+
+	// kind and state give the ByKind and ByState keys of an item
+	kind  func(vaultItem) string
+	state func(vaultItem) string
+}
+
+// vaultObjects are the items counted in deep backup mode, each reported on
+// its own line
+var vaultObjects = []vaultObject{
+	{
+		def: models.ResourceDefinition{
+			Type:        "microsoft.recoveryservices/vaults/backupprotecteditems",
+			DisplayName: "Backup Items",
+			Category:    "Backup & DR",
+		},
+		path:       "/backupProtectedItems",
+		apiVersion: backupItemsAPIVersion,
+		// e.g. VM, SQLDataBase, AzureFileShare; Protected, ProtectionStopped
+		kind:  func(item vaultItem) string { return item.Properties.WorkloadType },
+		state: func(item vaultItem) string { return item.Properties.ProtectionState },
+	},
+	{
+		def: models.ResourceDefinition{
+			Type:        "microsoft.recoveryservices/vaults/replicationprotecteditems",
+			DisplayName: "Site Recovery Replicated Items",
+			Category:    "Backup & DR",
+		},
+		path:       "/replicationProtectedItems",
+		apiVersion: replicatedItemsAPIVersion,
+		// e.g. A2A, HyperVReplicaAzure, InMageRcm; Normal, Warning, Critical
+		kind:  func(item vaultItem) string { return item.Properties.ProviderSpecificDetails.InstanceType },
+		state: func(item vaultItem) string { return item.Properties.ReplicationHealth },
+	},
+}
+
+// recoveryVault is a Recovery Services vault whose items are counted
+type recoveryVault struct {
+	ID             string
+	SubscriptionID string
+	Location       string
+}
+
+// vaultItemCount is the count of one vault object in one vault
+type vaultItemCount struct {
+	total   int
+	byKind  map[string]int
+	byState map[string]int
+}
+
+// VaultCollector counts the backup items and Site Recovery replicated items
+// of Recovery Services vaults through the Resource Manager vault APIs,
+// which Resource Graph does not cover in full
+type VaultCollector struct {
+	pipeline runtime.Pipeline
+	endpoint string
+	sem      *semaphore.Weighted
+}
+
+// NewVaultCollector creates a collector that authenticates to Azure Resource
+// Manager with credential and sends requests with options, to the Resource
+// Manager endpoint of their cloud if it has one
+func NewVaultCollector(credential azcore.TokenCredential, options policy.ClientOptions) *VaultCollector {
+	pipeline := runtime.NewPipeline(version.Product, version.Get(), runtime.PipelineOptions{
+		PerRetry: []policy.Policy{runtime.NewBearerTokenPolicy(credential, []string{managementScope}, nil)},
+	}, &options)
+
+	endpoint := managementEndpoint
+	if configured := options.Cloud.Services[cloud.ResourceManager].Endpoint; configured != "" {
+		endpoint = strings.TrimSuffix(configured, "/")
+	}
+
+	return &VaultCollector{
+		pipeline: pipeline,
+		endpoint: endpoint,
+		sem:      semaphore.NewWeighted(vaultConcurrency),
+	}
+}
+
+// CountItems counts the items of vaults, returning one count per vault
+// object and the number of vaults that could not be read. Such vaults, e.g.
+// ones whose listing is denied or throttled past the retries, are logged
+// and left out of every count rather than failing it.
+func (c *VaultCollector) CountItems(ctx context.Context, vaults []recoveryVault) ([]*models.ResourceCount, int) {
+	counts := make([]*models.ResourceCount, len(vaultObjects))
+	for i, object := range vaultObjects {
+		counts[i] = &models.ResourceCount{
+			Provider:      "Azure",
+			Type:          object.def.ResourceType(),
+			DisplayName:   object.def.DisplayName,
+			CanonicalType: object.def.CanonicalType(),
+			Category:      object.def.Category,
+			ByLocation:    make(map[string]int),
+			ByAccount:     make(map[string]int),
+			ByKind:        make(map[string]int),
+			ByState:       make(map[string]int),
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	unreadable := 0
+	for _, vault := range vaults {
+		if err := c.sem.Acquire(ctx, 1); err != nil {
+			mu.Lock()
+			unreadable++
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(vault recoveryVault) {
+			defer wg.Done()
+			defer c.sem.Release(1)
+
+			items, err := c.countVault(ctx, vault)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logging.FromContext(ctx).Warn("Failed to read Recovery Services vault; counting the vault without its items",
+					zap.String("vault", vault.ID),
+					zap.Error(err))
+				unreadable++
+				return
+			}
+			for i, item := range items {
+				if item.total == 0 {
+					continue
+				}
+				counts[i].TotalResources += item.total
+				counts[i].ByLocation[vault.Location] += item.total
+				counts[i].ByAccount[vault.SubscriptionID] += item.total
+				for kind, n := range item.byKind {
+					counts[i].ByKind[kind] += n
+				}
+				for state, n := range item.byState {
+					counts[i].ByState[state] += n
+				}
+			}
+		}(vault)
+	}
+	wg.Wait()
+	return counts, unreadable
+}
+
+// countVault counts the items of each vault object in one vault, in the
+// order of vaultObjects. Any failed listing fails the vault, so that it is
+// never counted in part.
+func (c *VaultCollector) countVault(ctx context.Context, vault recoveryVault) ([]vaultItemCount, error) {
+	items := make([]vaultItemCount, len(vaultObjects))
+	for i, object := range vaultObjects {
+		count, err := c.countObject(ctx, vault, object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", strings.ToLower(object.def.DisplayName), err)
+		}
+		items[i] = count
+	}
+	return items, nil
+}
+
+// countObject pages through the items of one vault object in a vault
+func (c *VaultCollector) countObject(ctx context.Context, vault recoveryVault, object vaultObject) (vaultItemCount, error) {
+	count := vaultItemCount{byKind: make(map[string]int), byState: make(map[string]int)}
+	next := fmt.Sprintf("%s%s%s?api-version=%s", c.endpoint, vault.ID, object.path, object.apiVersion)
+	for next != "" {
+		req, err := runtime.NewRequest(ctx, http.MethodGet, next)
+		if err != nil {
+			return vaultItemCount{}, err
+		}
+		resp, err := c.pipeline.Do(req)
+		if err != nil {
+			return vaultItemCount{}, err
+		}
+		if !runtime.HasStatusCode(resp, http.StatusOK) {
+			return vaultItemCount{}, runtime.NewResponseError(resp)
+		}
+		var page struct {
+			Value    []vaultItem `json:"value"`
+			NextLink string      `json:"nextLink"`
+		}
+		if err := runtime.UnmarshalAsJSON(resp, &page); err != nil {
+			return vaultItemCount{}, err
+		}
+		for _, item := range page.Value {
+			count.total++
+			if kind := object.kind(item); kind != "" {
+				count.byKind[kind]++
+			}
+			if state := object.state(item); state != "" {
+				count.byState[state]++
+			}
+		}
+		next = page.NextLink
+	}
+	return count, nil
+}
+
+// ListVaults lists the Recovery Services vaults of resourceDef in
+// subscriptions, applying the definition's filter and the tag filters
+func (c *ResourceCollector) ListVaults(
+	ctx context.Context,
+	resourceDef models.ResourceDefinition,
+	subscriptions []string,
+	graphClient resourceGraphQuerier,
+) ([]recoveryVault, error) {
+
+	queryText, err := baseQuery(resourceDef, c.tagFilters).
+		project("id", "subscriptionId", "location").
+		build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build query for %s: %w", resourceDef.Type, err)
+	}
+
+	rows, err := listRows(ctx, queryText, subscriptions, graphClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", resourceDef.Type, err)
+	}
+	vaults := make([]recoveryVault, 0, len(rows))
+	for _, row := range rows {
+		vault := recoveryVault{}
+		vault.ID, _ = row["id"].(string)
+		vault.SubscriptionID, _ = row["subscriptionId"].(string)
+		vault.Location, _ = row["location"].(string)
+		if vault.ID != "" {
+			vaults = append(vaults, vault)
+		}
+	}
+	return vaults, nil
+}
+
+// countVaultItems counts the items of the vaults in count, read with
+// vaults, and records the vaults it could not read as ContentUnknown. When
+// the vaults cannot be listed, all of them are unknown and no item lines
+// are returned.
+func (p *AzureProvider) countVaultItems(
+	ctx context.Context,
+	vaults *VaultCollector,
+	count *models.ResourceCount,
+	resourceDef models.ResourceDefinition,
+	subscriptions []string,
+	graphClient resourceGraphQuerier,
+) []*models.ResourceCount {
+	list, err := p.collector.ListVaults(ctx, resourceDef, subscriptions, graphClient)
+	if err != nil {
+		p.config.Log().Warn("Failed to list Recovery Services vaults; their items are unknown", zap.Error(err))
+		count.ContentUnknown = count.TotalResources
+		return nil
+	}
+	var items []*models.ResourceCount
+	items, count.ContentUnknown = vaults.CountItems(ctx, list)
+	return items
+}
+
+// vaultItemsWarning explains that --deep-backup could not read unknown of
+// total Recovery Services vaults, or returns "" when it read all
+func vaultItemsWarning(unknown, total int) string {
+	if unknown == 0 {
+		return ""
+	}
+	return fmt.Sprintf("--deep-backup could not read %d of %d Recovery Services vaults; "+
+		"their backup items and replicated items are not counted", unknown, total)
+}
@@ -0,0 +1,98 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// vaultPath is the Resource Manager path of a Recovery Services vault
+func vaultPath(subscription, group, name string) string {
+	return "/subscriptions/" + subscription + "/resourceGroups/" + group +
+		"/providers/Microsoft.RecoveryServices/vaults/" + name
+}
+
+func TestCountItems(t *testing.T) {
+	prod := vaultPath("sub-1", "rg-backup", "prod")
+	dr := vaultPath("sub-2", "rg-dr", "dr")
+	// The vault API answers with managementGroupsAPI's canned bodies by
+	// path, and denies the locked vault. The second page of prod's backup
+	// items sits at a path of its own, as the fake ignores queries.
+	api := &managementGroupsAPI{status: http.StatusForbidden, bodies: map[string]string{
+		prod + "/backupProtectedItems": `{"value":[
+			{"properties":{"workloadType":"VM","protectionState":"Protected"}},
+			{"properties":{"workloadType":"VM","protectionState":"ProtectionStopped"}}],
+			"nextLink":"https://management.azure.com` + prod + `/backupProtectedItems/page-2"}`,
+		prod + "/backupProtectedItems/page-2": `{"value":[
+			{"properties":{"workloadType":"SQLDataBase","protectionState":"Protected"}}]}`,
+		prod + "/replicationProtectedItems": `{"value":[]}`,
+		dr + "/backupProtectedItems":        `{"value":[]}`,
+		dr + "/replicationProtectedItems": `{"value":[
+			{"properties":{"replicationHealth":"Normal","providerSpecificDetails":{"instanceType":"A2A"}}},
+			{"properties":{"replicationHealth":"Critical","providerSpecificDetails":{"instanceType":"A2A"}}}]}`,
+	}}
+	collector := NewVaultCollector(fakeCredential{token: "token"},
+		policy.ClientOptions{Transport: &http.Client{Transport: api}})
+
+	counts, unreadable := collector.CountItems(context.Background(), []recoveryVault{
+		{ID: prod, SubscriptionID: "sub-1", Location: "westeurope"},
+		{ID: dr, SubscriptionID: "sub-2", Location: "northeurope"},
+		{ID: vaultPath("sub-1", "rg-backup", "locked"), SubscriptionID: "sub-1", Location: "westeurope"},
+	})
+	if unreadable != 1 {
+		t.Errorf("unreadable = %d, want 1", unreadable)
+	}
+	if len(counts) != 2 {
+		t.Fatalf("got %d counts, want 2", len(counts))
+	}
+
+	backup, replicated := counts[0], counts[1]
+	if backup.TotalResources != 3 || backup.ByKind["VM"] != 2 || backup.ByState["Protected"] != 2 {
+		t.Errorf("backup items = %d by kind %v and state %v, want 3 with 2 VMs and 2 protected",
+			backup.TotalResources, backup.ByKind, backup.ByState)
+	}
+	if replicated.TotalResources != 2 || replicated.ByKind["A2A"] != 2 || replicated.ByAccount["sub-2"] != 2 {
+		t.Errorf("replicated items = %d by kind %v and account %v, want 2 A2A items in sub-2",
+			replicated.TotalResources, replicated.ByKind, replicated.ByAccount)
+	}
+	for _, count := range counts {
+		if count.Category != "Backup & DR" || count.CanonicalType == "" {
+			t.Errorf("%s category = %q, canonical type = %q", count.Type, count.Category, count.CanonicalType)
+		}
+	}
+}
+
+func TestListVaults(t *testing.T) {
+	graph := &fakeResourceGraph{pages: map[string]armresourcegraph.ClientResourcesResponse{
+		"": graphPage("", map[string]interface{}{
+			"id": vaultPath("sub-1", "rg", "prod"), "subscriptionId": "sub-1", "location": "westeurope",
+		}),
+	}}
+	collector := NewResourceCollector(nil, nil, nil, false)
+
+	got, err := collector.ListVaults(context.Background(),
+		models.ResourceDefinition{Type: vaultType}, []string{"sub-1"}, graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].SubscriptionID != "sub-1" || got[0].Location != "westeurope" {
+		t.Errorf("ListVaults() = %+v, want the prod vault", got)
+	}
+	if query := *graph.requests[0].Query; !strings.Contains(query, "| project id, subscriptionId, location") {
+		t.Errorf("query %q does not project the vault's ID, subscription and location", query)
+	}
+}
+
+func TestVaultItemsWarning(t *testing.T) {
+	if got := vaultItemsWarning(0, 5); got != "" {
+		t.Errorf("vaultItemsWarning(0, 5) = %q, want none", got)
+	}
+	if got := vaultItemsWarning(1, 5); !strings.Contains(got, "1 of 5 Recovery Services vaults") {
+		t.Errorf("vaultItemsWarning(1, 5) = %q", got)
+	}
+}
@@ -1,10 +1,195 @@
 package config
 
+import (
+	"net/http"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/cache"
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
 type ProviderConfig struct {
-	Provider       string   `json:"provider" yaml:"provider"`
-	Profile        string   `json:"profile" yaml:"profile"` // AWS profile or Azure credentials
-	Region         string   `json:"region" yaml:"region"`
-	Regions        []string `json:"regions" yaml:"regions"`
-	Resources      []string `json:"resources" yaml:"resources"` // Resource types to count
-	SubscriptionID string   `json:"subscription_id" yaml:"subscription_id"`
+	Provider  string   `json:"provider" yaml:"provider"`
+	Profile   string   `json:"profile" yaml:"profile"` // AWS profile or Azure credentials
+	Region    string   `json:"region" yaml:"region"`
+	Regions   []string `json:"regions" yaml:"regions"`
+	Resources []string `json:"resources" yaml:"resources"` // Resource types to count
+
+	// SubscriptionIDs limits an Azure scan to these subscriptions; when empty,
+	// AZURE_SUBSCRIPTION_ID (comma-separated) applies, then all subscriptions
+	SubscriptionIDs []string `json:"subscription_ids" yaml:"subscription_ids"`
+
+	// TenantID pins Azure credentials that accept a tenant to it, and fails
+	// verification when the credentials sign in to another tenant
+	TenantID string `json:"tenant_id" yaml:"tenant_id"`
+
+	// AzureAuth selects a single Azure authentication method; empty or
+	// "default" tries the usual chain
+	AzureAuth string `json:"azure_auth" yaml:"azure_auth"`
+
+	// Azure service principal settings from the config file. Each one takes
+	// precedence over its environment variable (AZURE_CLIENT_ID,
+	// AZURE_CLIENT_CERTIFICATE_PATH, AZURE_FEDERATED_TOKEN_FILE).
+	AzureClientID              string `json:"azure_client_id" yaml:"azure_client_id"`
+	AzureClientCertificatePath string `json:"azure_client_certificate_path" yaml:"azure_client_certificate_path"`
+	AzureFederatedTokenFile    string `json:"azure_federated_token_file" yaml:"azure_federated_token_file"`
+
+	// AzureClientSecretEnv names the environment variable holding the
+	// client secret instead of AZURE_CLIENT_SECRET, so that every tenant of
+	// a multi-tenant scan can have a service principal of its own
+	AzureClientSecretEnv string `json:"azure_client_secret_env" yaml:"azure_client_secret_env"`
+
+	// Kubeconfig is the kubeconfig file of a Kubernetes scan; empty uses
+	// KUBECONFIG, then ~/.kube/config
+	Kubeconfig string `json:"kubeconfig" yaml:"kubeconfig"`
+
+	// KubeContexts are the kubeconfig contexts to scan, one cluster each;
+	// empty scans the current context
+	KubeContexts []string `json:"kube_contexts" yaml:"kube_contexts"`
+
+	// OCIAuth is how an OCI scan authenticates: "config" (default) with
+	// OCIProfile of the OCI config file OCIConfigFile, or
+	// "instance_principal"
+	OCIAuth       string `json:"oci_auth" yaml:"oci_auth"`
+	OCIConfigFile string `json:"oci_config_file" yaml:"oci_config_file"`
+	OCIProfile    string `json:"oci_profile" yaml:"oci_profile"`
+
+	// IncludeIdentity adds directory object counts (Entra ID users, groups, ...)
+	IncludeIdentity bool `json:"include_identity" yaml:"include_identity"`
+
+	// ManagementGroups places each Azure subscription in the management
+	// group hierarchy
+	ManagementGroups bool `json:"management_groups" yaml:"management_groups"`
+
+	// AssumeRoleARN is an AWS role assumed with the base credentials and
+	// used for the whole scan, optionally with an external ID and MFA
+	AssumeRoleARN string `json:"assume_role_arn" yaml:"assume_role_arn"`
+	ExternalID    string `json:"external_id" yaml:"external_id"`
+	MFASerial     string `json:"mfa_serial" yaml:"mfa_serial"`
+
+	// MFATokenProvider asks for the code of the MFA device; nil when no one
+	// can answer, in which case a role needing MFA fails to be assumed
+	MFATokenProvider func() (string, error) `json:"-" yaml:"-"`
+
+	// IncludeSuspended counts suspended AWS accounts in the account total
+	IncludeSuspended bool `json:"include_suspended" yaml:"include_suspended"`
+
+	// NoRegionPrecheck scans tagging API types in every reachable AWS
+	// region, even those the region probe found without tagged resources
+	NoRegionPrecheck bool `json:"no_region_precheck" yaml:"no_region_precheck"`
+
+	// ExcludeNoise leaves cloud-managed resources, such as default security
+	// groups and private endpoint NICs, out of the counts
+	ExcludeNoise bool `json:"exclude_noise" yaml:"exclude_noise"`
+
+	// StrictDefinitions fails the scan on definitions the provider does not
+	// know how to count, such as unknown AWS tagging API filters, which are
+	// otherwise only logged
+	StrictDefinitions bool `json:"strict_definitions" yaml:"strict_definitions"`
+
+	// DeepRegistries looks inside container registries, counting the images
+	// of every ECR repository and the repositories of every Azure Container
+	// Registry; it costs API calls per repository or registry
+	DeepRegistries bool `json:"deep_registries" yaml:"deep_registries"`
+
+	// DeepStorage counts the blob containers, file shares, queues and tables
+	// of every Azure storage account; it costs API calls per account
+	DeepStorage bool `json:"deep_storage" yaml:"deep_storage"`
+
+	// DeepBackup counts the backup items and Site Recovery replicated items
+	// of every Azure Recovery Services vault; it costs API calls per vault
+	DeepBackup bool `json:"deep_backup" yaml:"deep_backup"`
+
+	// RetryFailed is the number of passes that retry the resource types
+	// whose count failed, at low concurrency and after a backoff
+	RetryFailed int `json:"retry_failed" yaml:"retry_failed"`
+
+	// PageSize is the number of resources or rows asked for per page of the
+	// AWS tagging API and Azure Resource Graph; 0 keeps the provider's
+	// default. MaxPages stops paging a type after that many pages, marking
+	// its count truncated; 0 keeps the provider's default.
+	PageSize int `json:"page_size" yaml:"page_size"`
+	MaxPages int `json:"max_pages" yaml:"max_pages"`
+
+	// TypeTimeout bounds how long counting one resource type may take,
+	// retries aside; 0 leaves it unbounded
+	TypeTimeout time.Duration `json:"type_timeout" yaml:"type_timeout"`
+
+	// RegionFailureThreshold is the number of consecutive failed counts in
+	// an AWS region after which the region is skipped for the remaining
+	// types; 0 never skips
+	RegionFailureThreshold int `json:"region_failure_threshold" yaml:"region_failure_threshold"`
+
+	// TagFilters limits counting to resources carrying all of these tags
+	TagFilters []models.TagFilter `json:"tag_filters" yaml:"tag_filters"`
+
+	// AccountFilter limits the scan to the discovered accounts or
+	// subscriptions it matches, after SubscriptionIDs
+	AccountFilter models.AccountFilter `json:"account_filter" yaml:"account_filter"`
+
+	// Definitions are the resource types to count, loaded before connecting
+	Definitions []models.ResourceDefinition `json:"-" yaml:"-"`
+
+	// AWSEndpointURL sends every AWS API call to this URL instead of the
+	// public service endpoints, e.g. LocalStack or a private gateway
+	AWSEndpointURL string `json:"aws_endpoint_url" yaml:"aws_endpoint_url"`
+
+	// AWSEndpoints sends the calls of individual AWS services to their own
+	// URL, taking precedence over AWSEndpointURL, e.g. VPC interface
+	// endpoints without private DNS. Keys are aws.EndpointServices.
+	AWSEndpoints map[string]string `json:"aws_endpoints" yaml:"aws_endpoints"`
+
+	// AzureEndpointURL replaces the Azure Resource Manager endpoint
+	AzureEndpointURL string `json:"azure_endpoint_url" yaml:"azure_endpoint_url"`
+
+	// AzureAuthorityHost is the Microsoft Entra ID URL Azure credentials
+	// request tokens from; empty keeps the public cloud's
+	AzureAuthorityHost string `json:"azure_authority_host" yaml:"azure_authority_host"`
+
+	// HTTPClient carries every SDK request when a CA bundle or proxy is
+	// configured; nil keeps the SDK defaults
+	HTTPClient *http.Client `json:"-" yaml:"-"`
+
+	// APICalls counts the API calls of the scan per service and operation;
+	// nil disables counting
+	APICalls *metrics.APICallCounter `json:"-" yaml:"-"`
+
+	// DebugDump receives the raw API responses of the scan, with
+	// --debug-dump; nil disables dumping
+	DebugDump *debugdump.Dumper `json:"-" yaml:"-"`
+
+	// Cache reuses account and region discovery between runs; nil disables it
+	Cache *cache.Cache `json:"-" yaml:"-"`
+
+	// Inventory receives individual resources when inventory mode is enabled
+	Inventory models.ResourceSink `json:"-" yaml:"-"`
+
+	// Counts receives each resource count as soon as it is complete, for
+	// streaming output; nil disables it
+	Counts models.CountSink `json:"-" yaml:"-"`
+
+	// Logger receives the provider's log entries; nil keeps the default
+	// logger
+	Logger *zap.Logger `json:"-" yaml:"-"`
+}
+
+// Log returns Logger, or the default logger when it is nil
+func (c *ProviderConfig) Log() *zap.Logger {
+	return logging.OrDefault(c.Logger)
+}
+
+// StreamCount passes a finished count to Counts, if set. A failed write is
+// logged rather than returned, as the count is still part of the result.
+func (c *ProviderConfig) StreamCount(count *models.ResourceCount) {
+	if c.Counts == nil {
+		return
+	}
+	if err := c.Counts.WriteCount(count); err != nil {
+		c.Log().Warn("Failed to stream resource count",
+			zap.String("type", string(count.Type)), zap.Error(err))
+	}
 }
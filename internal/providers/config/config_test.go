@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// countSink records the counts written to it, failing with err
+type countSink struct {
+	counts []*models.ResourceCount
+	err    error
+}
+
+func (s *countSink) WriteCount(count *models.ResourceCount) error {
+	s.counts = append(s.counts, count)
+	return s.err
+}
+
+func TestStreamCount(t *testing.T) {
+	count := &models.ResourceCount{Type: "ec2:instance", TotalResources: 3}
+
+	var unset ProviderConfig
+	unset.StreamCount(count)
+
+	sink := &countSink{}
+	cfg := ProviderConfig{Counts: sink}
+	cfg.StreamCount(count)
+	if len(sink.counts) != 1 || sink.counts[0] != count {
+		t.Errorf("streamed %v, want the count", sink.counts)
+	}
+
+	core, logs := observer.New(zap.WarnLevel)
+	failing := ProviderConfig{Counts: &countSink{err: errors.New("disk full")}, Logger: zap.New(core)}
+	failing.StreamCount(count)
+	if logs.FilterMessage("Failed to stream resource count").Len() != 1 {
+		t.Errorf("failed write logged %v, want one warning", logs.All())
+	}
+}
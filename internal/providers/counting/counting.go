@@ -0,0 +1,209 @@
+// Package counting runs the per-type counts of a scan for every provider:
+// a main pass over all resource types, then retry passes over the types that
+// failed.
+package counting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/debugdump"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+// MaxRetries is the largest number of retry passes allowed
+const MaxRetries = 3
+
+// DefaultTypeTimeout bounds each attempt at counting a type unless set
+// otherwise; generous, as large accounts take minutes for some types
+const DefaultTypeTimeout = 15 * time.Minute
+
+// retryConcurrency bounds the types counted at once in a retry pass, to
+// stay clear of the rate limits that usually made them fail
+const retryConcurrency = 2
+
+// retryDelay is waited before the first retry pass and doubled before each
+// further one, so that throttled APIs have recovered; a variable for tests
+var retryDelay = 5 * time.Second
+
+// CountFunc counts one resource type. Its error is recorded as the type's
+// failure and should already be classified.
+type CountFunc func(ctx context.Context, def models.ResourceDefinition) (*models.ResourceCount, error)
+
+// Options control how Run schedules the counts
+type Options struct {
+	// Concurrency bounds the types counted at once in the main pass;
+	// 0 leaves it to the count function
+	Concurrency int
+
+	// Retries is the number of passes over the types that failed, up to
+	// MaxRetries
+	Retries int
+
+	// TypeTimeout bounds each attempt at counting a type; a type that runs
+	// out of time fails and is retried like any other. 0 leaves it
+	// unbounded.
+	TypeTimeout time.Duration
+}
+
+// Run counts every definition with count and returns the counts, in the
+// order they completed, together with the failures of the types still
+// failing after the last pass. Authentication, permission and TLS failures
+// are not retried, nor is anything once ctx is done. Credentials that expire
+// during the scan stop it at once: the types not counted yet fail with the
+// expired *errors.AuthError instead of one such error each.
+func Run(ctx context.Context, defs []models.ResourceDefinition, count CountFunc, options Options) ([]*models.ResourceCount, *sizingerrors.Failures) {
+	var counts []*models.ResourceCount
+	failures := &sizingerrors.Failures{}
+	retries := min(options.Retries, MaxRetries)
+	log := logging.FromContext(ctx)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	pending, concurrency := defs, options.Concurrency
+	for pass := 0; ; pass++ {
+		passCounts, failed, errs := runPass(ctx, cancel, pending, count, concurrency, options.TypeTimeout)
+		counts = append(counts, passCounts...)
+
+		if expired, ok := context.Cause(ctx).(*sizingerrors.AuthError); ok {
+			for i, def := range failed {
+				err := errs[i]
+				if !sizingerrors.CredentialsExpired(err) {
+					err = fmt.Errorf("not counted after the credentials expired: %w", expired)
+				}
+				failures.Add(string(def.ResourceType()), err)
+			}
+			log.Error("Credentials expired during the scan; stopped counting",
+				zap.Int("types_not_counted", len(failed)),
+				zap.Error(expired))
+			return counts, failures
+		}
+
+		var retry []models.ResourceDefinition
+		var retryErrs []error
+		for i, def := range failed {
+			if pass < retries && retryable(errs[i]) && ctx.Err() == nil {
+				log.Warn("Failed to count resource type, will retry",
+					zap.String("type", def.Type),
+					zap.Error(errs[i]))
+				retry = append(retry, def)
+				retryErrs = append(retryErrs, errs[i])
+				continue
+			}
+			fail(log, failures, def, errs[i])
+		}
+		if len(retry) == 0 {
+			return counts, failures
+		}
+
+		delay := retryDelay << pass
+		log.Info("Retrying the resource types that failed",
+			zap.Int("types", len(retry)),
+			zap.Int("pass", pass+1),
+			zap.Duration("delay", delay))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			for i, def := range retry {
+				fail(log, failures, def, retryErrs[i])
+			}
+			return counts, failures
+		}
+		pending, concurrency = retry, retryConcurrency
+	}
+}
+
+// fail records that def could not be counted
+func fail(log *zap.Logger, failures *sizingerrors.Failures, def models.ResourceDefinition, err error) {
+	log.Error("Failed to count resource type",
+		zap.String("type", def.Type),
+		zap.Error(err))
+	failures.Add(string(def.ResourceType()), err)
+}
+
+// runPass counts defs concurrently, at most concurrency at a time when it
+// is positive and each within timeout when it is, and returns the counts
+// and the failed definitions with their errors. The first failure of
+// expired credentials cancels ctx with it, so the other types stop.
+func runPass(ctx context.Context, cancel context.CancelCauseFunc, defs []models.ResourceDefinition, count CountFunc,
+	concurrency int, timeout time.Duration) (counts []*models.ResourceCount, failed []models.ResourceDefinition, errs []error) {
+	var semaphore chan struct{}
+	if concurrency > 0 {
+		semaphore = make(chan struct{}, concurrency)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, def := range defs {
+		wg.Add(1)
+		go func(def models.ResourceDefinition) {
+			defer wg.Done()
+			if semaphore != nil {
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+			}
+
+			typeCtx := debugdump.WithResourceType(ctx, string(def.ResourceType()))
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				typeCtx, cancel = context.WithTimeout(typeCtx, timeout)
+				defer cancel()
+			}
+			var rc *models.ResourceCount
+			var err error
+			if ctx.Err() != nil {
+				// Waited for its turn while the scan was stopped
+				err = context.Cause(ctx)
+			} else {
+				rc, err = count(typeCtx, def)
+			}
+			if auth := sizingerrors.ExpiredAuth(err); auth != nil {
+				cancel(auth)
+			}
+
+			// Providers that isolate per-region failures may return what
+			// they counted before the deadline, or before the credentials
+			// expired; it is incomplete all the same
+			switch {
+			case ctx.Err() == nil && errors.Is(typeCtx.Err(), context.DeadlineExceeded):
+				if err == nil {
+					err = context.DeadlineExceeded
+				}
+				err = fmt.Errorf("timed out after %s: %w", timeout, err)
+			case err == nil && ctx.Err() != nil:
+				if expired, ok := context.Cause(ctx).(*sizingerrors.AuthError); ok {
+					err = expired
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, def)
+				errs = append(errs, err)
+				return
+			}
+			counts = append(counts, rc)
+		}(def)
+	}
+	wg.Wait()
+	return counts, failed, errs
+}
+
+// retryable reports whether another attempt could count a type that failed
+// with err; missing credentials, permissions or trust do not come back
+func retryable(err error) bool {
+	var (
+		auth       *sizingerrors.AuthError
+		permission *sizingerrors.PermissionError
+		tlsErr     *sizingerrors.TLSError
+	)
+	return !errors.As(err, &auth) && !errors.As(err, &permission) && !errors.As(err, &tlsErr)
+}
@@ -0,0 +1,242 @@
+package counting
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRun(t *testing.T) {
+	retryDelay = time.Millisecond
+	t.Cleanup(func() { retryDelay = 5 * time.Second })
+
+	throttled := &sizingerrors.ThrottledError{Provider: "aws", Err: errors.New("rate exceeded")}
+	denied := &sizingerrors.PermissionError{Provider: "aws", Err: errors.New("access denied")}
+
+	tests := []struct {
+		name    string
+		retries int
+		// failures is how many times each type fails before it is counted,
+		// and errs the error it fails with (throttled by default)
+		failures map[string]int
+		errs     map[string]error
+
+		wantCounted []string
+		wantFailed  []string
+		wantCalls   map[string]int
+	}{
+		{
+			name:        "all counted",
+			retries:     1,
+			wantCounted: []string{"ec2:instance", "rds:db", "s3:bucket"},
+			wantCalls:   map[string]int{"ec2:instance": 1, "rds:db": 1, "s3:bucket": 1},
+		},
+		{
+			name:        "retry recovers a throttled type",
+			retries:     1,
+			failures:    map[string]int{"rds:db": 1},
+			wantCounted: []string{"ec2:instance", "rds:db", "s3:bucket"},
+			wantCalls:   map[string]int{"ec2:instance": 1, "rds:db": 2, "s3:bucket": 1},
+		},
+		{
+			name:        "no retries",
+			failures:    map[string]int{"rds:db": 1},
+			wantCounted: []string{"ec2:instance", "s3:bucket"},
+			wantFailed:  []string{"rds:db"},
+			wantCalls:   map[string]int{"ec2:instance": 1, "rds:db": 1, "s3:bucket": 1},
+		},
+		{
+			name:        "fails every pass",
+			retries:     2,
+			failures:    map[string]int{"rds:db": 5, "s3:bucket": 2},
+			wantCounted: []string{"ec2:instance", "s3:bucket"},
+			wantFailed:  []string{"rds:db"},
+			wantCalls:   map[string]int{"ec2:instance": 1, "rds:db": 3, "s3:bucket": 3},
+		},
+		{
+			name:        "permission errors are not retried",
+			retries:     3,
+			failures:    map[string]int{"rds:db": 1},
+			errs:        map[string]error{"rds:db": denied},
+			wantCounted: []string{"ec2:instance", "s3:bucket"},
+			wantFailed:  []string{"rds:db"},
+			wantCalls:   map[string]int{"ec2:instance": 1, "rds:db": 1, "s3:bucket": 1},
+		},
+		{
+			name:        "retries are capped",
+			retries:     10,
+			failures:    map[string]int{"rds:db": 10},
+			wantCounted: []string{"ec2:instance", "s3:bucket"},
+			wantFailed:  []string{"rds:db"},
+			wantCalls:   map[string]int{"ec2:instance": 1, "rds:db": MaxRetries + 1, "s3:bucket": 1},
+		},
+	}
+
+	defs := []models.ResourceDefinition{{Type: "ec2:instance"}, {Type: "s3:bucket"}, {Type: "rds:db"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := make(map[string]int)
+			count := func(_ context.Context, def models.ResourceDefinition) (*models.ResourceCount, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls[def.Type]++
+				if calls[def.Type] <= tt.failures[def.Type] {
+					if err := tt.errs[def.Type]; err != nil {
+						return nil, err
+					}
+					return nil, throttled
+				}
+				return &models.ResourceCount{Type: def.ResourceType()}, nil
+			}
+
+			counts, failures := Run(context.Background(), defs, count, Options{Concurrency: 2, Retries: tt.retries})
+
+			var counted []string
+			for _, rc := range counts {
+				counted = append(counted, string(rc.Type))
+			}
+			sort.Strings(counted)
+			if !reflect.DeepEqual(counted, tt.wantCounted) {
+				t.Errorf("counted = %v, want %v", counted, tt.wantCounted)
+			}
+			var failed []string
+			if partial := failures.Partial(); partial != nil {
+				failed = partial.Failed
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("failed = %v, want %v", failed, tt.wantFailed)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	retryDelay = time.Hour
+	t.Cleanup(func() { retryDelay = 5 * time.Second })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	throttled := &sizingerrors.ThrottledError{Provider: "azure", Err: errors.New("429")}
+	count := func(context.Context, models.ResourceDefinition) (*models.ResourceCount, error) {
+		cancel()
+		return nil, throttled
+	}
+
+	_, failures := Run(ctx, []models.ResourceDefinition{{Type: "microsoft.web/sites"}}, count, Options{Retries: 3})
+	partial := failures.Partial()
+	if partial == nil || !errors.Is(partial, throttled) {
+		t.Fatalf("Partial() = %v, want the throttling failure", partial)
+	}
+}
+
+func TestRunTypeTimeout(t *testing.T) {
+	retryDelay = time.Millisecond
+	t.Cleanup(func() { retryDelay = 5 * time.Second })
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	count := func(ctx context.Context, def models.ResourceDefinition) (*models.ResourceCount, error) {
+		mu.Lock()
+		calls[def.Type]++
+		mu.Unlock()
+		if def.Type == "ec2:instance" {
+			// Hangs until the deadline, then returns what it has, like a
+			// provider isolating per-region failures
+			<-ctx.Done()
+			return &models.ResourceCount{Type: def.ResourceType(), TotalResources: 3}, nil
+		}
+		return &models.ResourceCount{Type: def.ResourceType()}, nil
+	}
+
+	defs := []models.ResourceDefinition{{Type: "ec2:instance"}, {Type: "s3:bucket"}}
+	counts, failures := Run(context.Background(), defs, count, Options{Retries: 1, TypeTimeout: 10 * time.Millisecond})
+
+	if len(counts) != 1 || counts[0].Type != "s3:bucket" {
+		t.Errorf("counts = %v, want s3:bucket only", counts)
+	}
+	partial := failures.Partial()
+	if partial == nil || !reflect.DeepEqual(partial.Failed, []string{"ec2:instance"}) ||
+		!errors.Is(partial, context.DeadlineExceeded) {
+		t.Fatalf("Partial() = %v, want ec2:instance timed out", partial)
+	}
+	if calls["ec2:instance"] != 2 {
+		t.Errorf("ec2:instance counted %d times, want a retry after the timeout", calls["ec2:instance"])
+	}
+}
+
+func TestRunCredentialsExpired(t *testing.T) {
+	retryDelay = time.Millisecond
+	t.Cleanup(func() { retryDelay = 5 * time.Second })
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	count := func(ctx context.Context, def models.ResourceDefinition) (*models.ResourceCount, error) {
+		mu.Lock()
+		calls[def.Type]++
+		mu.Unlock()
+		switch def.Type {
+		case "ec2:instance":
+			return &models.ResourceCount{Type: def.ResourceType()}, nil
+		case "s3:bucket":
+			return nil, &sizingerrors.AuthError{Provider: "aws", Err: errors.New("ExpiredToken: the security token is expired")}
+		}
+		// Still paging when the credentials expire
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	defs := []models.ResourceDefinition{{Type: "ec2:instance"}, {Type: "s3:bucket"}, {Type: "rds:db"}, {Type: "lambda:function"}}
+	counts, failures := Run(context.Background(), defs, count, Options{Retries: 3})
+
+	if len(counts) != 1 || counts[0].Type != "ec2:instance" {
+		t.Errorf("counts = %v, want ec2:instance only", counts)
+	}
+	partial := failures.Partial()
+	if partial == nil || len(partial.Failed) != 3 {
+		t.Fatalf("Partial() = %v, want the other three types", partial)
+	}
+	for i, err := range partial.Errs {
+		if !sizingerrors.CredentialsExpired(err) {
+			t.Errorf("%s failed with %v, want the expired credentials", partial.Failed[i], err)
+		}
+	}
+	if sizingerrors.ExitCode(partial) != sizingerrors.ExitAuth {
+		t.Errorf("ExitCode() = %d, want %d", sizingerrors.ExitCode(partial), sizingerrors.ExitAuth)
+	}
+	for resourceType, n := range calls {
+		if n > 1 {
+			t.Errorf("%s counted %d times, want no retries after the expiry", resourceType, n)
+		}
+	}
+}
+
+func TestRunLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logging.NewContext(context.Background(), zap.New(core))
+	denied := &sizingerrors.PermissionError{Provider: "aws", Err: errors.New("access denied")}
+
+	_, failures := Run(ctx, []models.ResourceDefinition{{Type: "rds:db"}},
+		func(context.Context, models.ResourceDefinition) (*models.ResourceCount, error) {
+			return nil, denied
+		}, Options{})
+	if failures.Partial() == nil {
+		t.Fatal("Run() failures = none, want rds:db")
+	}
+	entries := logs.FilterMessage("Failed to count resource type").All()
+	if len(entries) != 1 || entries[0].ContextMap()["type"] != "rds:db" {
+		t.Errorf("logged %+v, want the failure on the logger of ctx", logs.All())
+	}
+}
@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+)
+
+func TestNewWithoutOptions(t *testing.T) {
+	client, err := New("", "")
+	if err != nil || client != nil {
+		t.Errorf("New() = %v, %v, want nil, nil", client, err)
+	}
+}
+
+func TestNewOptionErrors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		caBundle string
+		proxy    string
+	}{
+		{name: "missing bundle", caBundle: filepath.Join(t.TempDir(), "missing.pem")},
+		{name: "bundle without certificates", caBundle: empty},
+		{name: "proxy without scheme", proxy: "proxy.example.com:3128"},
+		{name: "unsupported scheme", proxy: "ftp://proxy.example.com"},
+		{name: "proxy without host", proxy: "http://"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.caBundle, tt.proxy); err == nil {
+				t.Error("New() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestNewTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Without the bundle the certificate fails verification
+	_, err := (&http.Client{}).Get(server.URL)
+	if !sizingerrors.IsCertificateError(err) {
+		t.Fatalf("Get() error = %v, want a certificate error", err)
+	}
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := New(bundle, "")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewUsesProxy(t *testing.T) {
+	client, err := New("", "http://proxy.example.com:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://management.azure.com", nil)
+	proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil || proxyURL.String() != "http://proxy.example.com:3128" {
+		t.Errorf("Proxy() = %v, %v", proxyURL, err)
+	}
+}
@@ -0,0 +1,435 @@
+// Package k8s sizes the workloads inside Kubernetes clusters, read through
+// the contexts of a kubeconfig. Each cluster is an account and each workload
+// kind a resource type counted by namespace.
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/counting"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// providerName is the name the provider is registered and labelled with
+	providerName = "k8s"
+
+	// requestTimeout bounds every request to an API server, so that an
+	// unreachable cluster fails fast
+	requestTimeout = 30 * time.Second
+
+	// typeConcurrency bounds the resource types counted at once, and
+	// clusterConcurrency the clusters each type is counted in at once
+	typeConcurrency    = 4
+	clusterConcurrency = 4
+
+	// Cluster states, reported as the account status
+	statusReachable   = "Reachable"
+	statusUnreachable = "Unreachable"
+)
+
+// cluster is the kubeconfig context of one cluster and its client. err
+// says why the cluster is unreachable; it is nil for reachable clusters.
+type cluster struct {
+	account models.AccountCount
+	client  kubernetes.Interface
+	err     error
+
+	pods podLister
+}
+
+// K8sProvider implements the Provider interface for Kubernetes clusters
+type K8sProvider struct {
+	// mu guards clusters; Connect writes it and CountResources reads a
+	// snapshot of it
+	mu       sync.RWMutex
+	config   config.ProviderConfig
+	clusters []*cluster
+
+	// newClient builds the client of a cluster; replaced in tests
+	newClient func(restConfig *rest.Config) (kubernetes.Interface, error)
+}
+
+// NewK8sProvider creates a new Kubernetes provider
+func NewK8sProvider(cfg config.ProviderConfig) (*K8sProvider, error) {
+	return &K8sProvider{
+		config: cfg,
+		newClient: func(restConfig *rest.Config) (kubernetes.Interface, error) {
+			return kubernetes.NewForConfig(restConfig)
+		},
+	}, nil
+}
+
+// Name returns the provider name
+func (p *K8sProvider) Name() string {
+	return providerName
+}
+
+// Connect loads the kubeconfig and checks that the selected clusters answer.
+// Unreachable clusters are kept as accounts and skipped by the count; it is
+// an error only when none answers.
+func (p *K8sProvider) Connect(ctx context.Context) error {
+	ctx = logging.NewContext(ctx, p.config.Log())
+
+	p.config.Log().Info("Connecting to Kubernetes clusters...")
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = p.config.Kubeconfig
+	raw, err := rules.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	contexts, err := selectContexts(raw, p.config.KubeContexts)
+	if err != nil {
+		return err
+	}
+
+	clusters := make([]*cluster, len(contexts))
+	var wg sync.WaitGroup
+	for i, name := range contexts {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			clusters[i] = p.connectCluster(ctx, raw, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	var reachable int
+	for _, c := range clusters {
+		if c.err != nil {
+			p.config.Log().Warn("Cluster is unreachable; it is listed but not counted",
+				zap.String("context", c.account.ID),
+				zap.Error(c.err))
+			continue
+		}
+		reachable++
+	}
+	if reachable == 0 {
+		return &sizingerrors.AuthError{Provider: providerName, Err: fmt.Errorf(
+			"no cluster could be reached: %s: %w", clusters[0].account.ID, clusters[0].err)}
+	}
+
+	p.mu.Lock()
+	p.clusters = clusters
+	p.mu.Unlock()
+
+	p.config.Log().Info("Connected to Kubernetes successfully",
+		zap.Int("clusters", len(clusters)),
+		zap.Int("reachable", reachable))
+	return nil
+}
+
+// selectContexts returns the contexts to scan: those asked for, which must
+// all exist, else the current context
+func selectContexts(raw *clientcmdapi.Config, wanted []string) ([]string, error) {
+	if len(wanted) == 0 {
+		if raw.CurrentContext == "" {
+			return nil, fmt.Errorf("the kubeconfig has no current context; choose one with --context")
+		}
+		return []string{raw.CurrentContext}, nil
+	}
+	for _, name := range wanted {
+		if _, ok := raw.Contexts[name]; !ok {
+			return nil, fmt.Errorf("context %q not found in the kubeconfig", name)
+		}
+	}
+	return wanted, nil
+}
+
+// connectCluster builds the client of context name and asks the API server
+// for its version to see whether it answers
+func (p *K8sProvider) connectCluster(ctx context.Context, raw *clientcmdapi.Config, name string) *cluster {
+	c := &cluster{account: models.AccountCount{ID: name, Name: name, Status: statusUnreachable}}
+	if kubeContext := raw.Contexts[name]; kubeContext != nil && kubeContext.Cluster != "" {
+		c.account.Name = kubeContext.Cluster
+	}
+
+	restConfig, err := clientcmd.NewNonInteractiveClientConfig(*raw, name, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		c.err = fmt.Errorf("invalid context: %w", err)
+		return c
+	}
+	restConfig.Timeout = requestTimeout
+	client, err := p.newClient(restConfig)
+	if err != nil {
+		c.err = fmt.Errorf("failed to create client: %w", err)
+		return c
+	}
+	if err := ctx.Err(); err != nil {
+		c.err = err
+		return c
+	}
+	if _, err := client.Discovery().ServerVersion(); err != nil {
+		c.err = err
+		return c
+	}
+
+	c.client = client
+	c.account.Status = statusReachable
+	return c
+}
+
+// Accounts lists the selected clusters, one per kubeconfig context
+func (p *K8sProvider) Accounts() *models.AccountList {
+	return models.NewAccountList("Kubernetes", models.AccountSourceKubeconfig, p.accounts())
+}
+
+// accounts returns the accounts of the selected clusters
+func (p *K8sProvider) accounts() []models.AccountCount {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	accounts := make([]models.AccountCount, len(p.clusters))
+	for i, c := range p.clusters {
+		accounts[i] = c.account
+	}
+	return accounts
+}
+
+// reachableClusters returns fresh copies of the reachable clusters, so that
+// each scan lists their pods anew
+func (p *K8sProvider) reachableClusters() []*cluster {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var clusters []*cluster
+	for _, c := range p.clusters {
+		if c.err == nil {
+			clusters = append(clusters, &cluster{account: c.account, client: c.client})
+		}
+	}
+	return clusters
+}
+
+// typesToCount returns the definitions the provider has a counter for,
+// warning about the others
+func (p *K8sProvider) typesToCount() []models.ResourceDefinition {
+	var defs []models.ResourceDefinition
+	for _, def := range p.config.Definitions {
+		if _, ok := kinds[models.ResourceType(def.Type)]; !ok {
+			p.config.Log().Warn("No Kubernetes counter for resource type; skipping it", zap.String("type", def.Type))
+			continue
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// Plan describes what CountResources would do with the reachable clusters
+func (p *K8sProvider) Plan() (*models.ScanPlan, error) {
+	clusters := p.reachableClusters()
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no clusters available to scan")
+	}
+
+	plan := &models.ScanPlan{
+		Provider:    "Kubernetes",
+		Accounts:    p.accounts(),
+		Concurrency: typeConcurrency * min(len(clusters), clusterConcurrency),
+	}
+	for _, account := range plan.Accounts {
+		if account.Status == statusUnreachable {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("cluster %s is unreachable and would not be counted", account.ID))
+		}
+	}
+	listed := make(map[string]bool)
+	for _, def := range p.typesToCount() {
+		k := kinds[models.ResourceType(def.Type)]
+		planned := models.PlannedType{
+			Type:        models.ResourceType(def.Type),
+			DisplayName: def.DisplayName,
+			CountMethod: def.CountMethod,
+			API:         "list " + k.qualifiedResource(),
+		}
+		// Pods are listed once for the types counted from them
+		if !listed[k.resource] {
+			planned.EstimatedCalls = len(clusters)
+			listed[k.resource] = true
+		}
+		plan.AddType(planned)
+	}
+	return plan, nil
+}
+
+// qualifiedResource names the listed resource with its API group, as in
+// deployments.apps
+func (k kind) qualifiedResource() string {
+	if k.group == "" {
+		return k.resource
+	}
+	return k.resource + "." + k.group
+}
+
+// CountResources counts every resource type in each reachable cluster
+func (p *K8sProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
+	ctx = logging.NewContext(ctx, p.config.Log())
+
+	p.config.Log().Info("Counting Kubernetes resources...")
+
+	clusters := p.reachableClusters()
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("no clusters available to scan")
+	}
+	accounts := p.accounts()
+
+	result := &models.SizingResult{
+		SchemaVersion: models.SchemaVersion,
+		Provider:      "Kubernetes",
+		Timestamp:     time.Now(),
+		AuthMethod:    "kubeconfig",
+	}
+	for _, account := range accounts {
+		if account.Status == statusUnreachable {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("cluster %s was unreachable and is not counted", account.ID))
+		}
+	}
+
+	var mu sync.Mutex
+	countType := func(ctx context.Context, def models.ResourceDefinition) (*models.ResourceCount, error) {
+		start := time.Now()
+		count, warnings, err := countKind(ctx, clusters, def)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		result.Warnings = append(result.Warnings, warnings...)
+		mu.Unlock()
+
+		metrics.ResourceTypeScanned(providerName, string(count.Type), time.Since(start))
+		count.TagFilterNotApplied = len(p.config.TagFilters) > 0
+		p.config.StreamCount(count)
+		return count, nil
+	}
+	resourceCounts, failures := counting.Run(ctx, p.typesToCount(), countType,
+		counting.Options{Concurrency: typeConcurrency, Retries: p.config.RetryFailed, TypeTimeout: p.config.TypeTimeout})
+
+	partial := failures.Partial()
+	if partial != nil && len(resourceCounts) == 0 {
+		return nil, fmt.Errorf("no resource types could be counted: %w", partial.Errs[0])
+	}
+
+	// Clusters are the accounts, with the resources counted in each
+	for i := range accounts {
+		accounts[i].ByType = make(map[models.ResourceType]int)
+		for _, rc := range resourceCounts {
+			if n := rc.ByAccount[accounts[i].ID]; n > 0 {
+				accounts[i].ResourceCount += n
+				accounts[i].ByType[rc.Type] = n
+			}
+		}
+	}
+
+	result.ResourceCounts = resourceCounts
+	result.AccountCounts = accounts
+	for _, rc := range resourceCounts {
+		result.TotalResources += rc.TotalResources
+	}
+	result.TotalAccounts = len(accounts)
+	result.AccountsDiscovered = len(accounts)
+	result.AccountsScanned = len(clusters)
+
+	p.config.Log().Info("Resource counting completed",
+		zap.Int("total_resources", result.TotalResources),
+		zap.Int("resource_types_counted", len(resourceCounts)),
+		zap.Int("clusters", len(clusters)))
+
+	if warning := models.TagFilterWarning(resourceCounts); warning != "" {
+		p.config.Log().Warn(warning)
+		result.Warnings = append(result.Warnings, warning)
+	}
+
+	if partial != nil {
+		result.Warnings = append(result.Warnings, partial.Error())
+		return result, partial
+	}
+	return result, nil
+}
+
+// countKind counts def in every cluster. A cluster where the kind cannot be
+// listed, e.g. because RBAC forbids it, is left out of the count with a
+// warning; it is an error only when the kind could be listed nowhere.
+func countKind(ctx context.Context, clusters []*cluster, def models.ResourceDefinition) (*models.ResourceCount, []string, error) {
+	k := kinds[models.ResourceType(def.Type)]
+	counts := make([]kindCount, len(clusters))
+	errs := make([]error, len(clusters))
+
+	semaphore := make(chan struct{}, clusterConcurrency)
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func(i int, c *cluster) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			counts[i], errs[i] = k.count(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	count := &models.ResourceCount{
+		Type:          models.ResourceType(def.Type),
+		CanonicalType: def.CanonicalType(),
+		Category:      def.Category,
+		ByAccount:     make(map[string]int),
+	}
+	var warnings []string
+	var firstErr error
+	for i, c := range clusters {
+		if err := errs[i]; err != nil {
+			err = classifyError(err, k)
+			if firstErr == nil {
+				firstErr = err
+			}
+			warnings = append(warnings, fmt.Sprintf("cluster %s: %s not counted: %v", c.account.ID, def.Type, err))
+			continue
+		}
+		count.TotalResources += counts[i].total
+		count.ByAccount[c.account.ID] = counts[i].total
+		for namespace, n := range counts[i].byNamespace {
+			if count.ByLocation == nil {
+				count.ByLocation = make(map[string]int)
+			}
+			count.ByLocation[namespace] += n
+		}
+	}
+	if len(warnings) == len(clusters) {
+		return nil, nil, firstErr
+	}
+	for _, warning := range warnings {
+		logging.FromContext(ctx).Warn(warning)
+	}
+	return count, warnings, nil
+}
+
+// classifyError wraps an RBAC denial of listing k into a permission error,
+// and rejected credentials into an authentication error
+func classifyError(err error, k kind) error {
+	switch {
+	case apierrors.IsForbidden(err):
+		return &sizingerrors.PermissionError{Provider: providerName, Action: "list " + k.qualifiedResource(), Err: err}
+	case apierrors.IsUnauthorized(err):
+		return &sizingerrors.AuthError{Provider: providerName, Err: err}
+	}
+	return err
+}
+
+// Close closes any open connections
+func (p *K8sProvider) Close() error {
+	p.config.Log().Info("Closing Kubernetes provider connections")
+	// Kubernetes clients don't require explicit closing
+	return nil
+}
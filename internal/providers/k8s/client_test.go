@@ -0,0 +1,330 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// testKubeconfig has a reachable and an unreachable cluster, told apart by
+// their server URL
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com
+- name: lab-cluster
+  cluster:
+    server: https://lab.example.com
+contexts:
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: admin
+- name: lab
+  context:
+    cluster: lab-cluster
+    user: admin
+users:
+- name: admin
+  user:
+    token: secret
+`
+
+func k8sDefinitions(t *testing.T) []models.ResourceDefinition {
+	t.Helper()
+	set, err := models.DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set.ForProvider("k8s")
+}
+
+func pod(namespace, name string, phase corev1.PodPhase, images ...string) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	for _, image := range images {
+		p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Name: image, Image: image})
+	}
+	return p
+}
+
+func deployment(namespace, name string) *appsv1.Deployment {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+// prodCluster holds two nodes, two namespaces, two deployments and three
+// running pods running two images between them
+func prodCluster() *fake.Clientset {
+	return fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "web"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "jobs"}},
+		deployment("web", "frontend"),
+		deployment("jobs", "worker"),
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "agent"}},
+		pod("web", "frontend-1", corev1.PodRunning, "nginx:1.27", "envoy:1.31"),
+		pod("web", "frontend-2", corev1.PodRunning, "nginx:1.27"),
+		pod("jobs", "worker-1", corev1.PodRunning, "nginx:1.27"),
+		pod("jobs", "worker-2", corev1.PodPending, "worker:2"),
+	)
+}
+
+func forbid(client *fake.Clientset, resource string) {
+	client.PrependReactor("list", resource, func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: resource}, "", errors.New("RBAC: access denied"))
+	})
+}
+
+func testProvider(t *testing.T, clusters ...*cluster) *K8sProvider {
+	t.Helper()
+	p, err := NewK8sProvider(config.ProviderConfig{Definitions: k8sDefinitions(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.clusters = clusters
+	return p
+}
+
+func reachable(name string, client kubernetes.Interface) *cluster {
+	return &cluster{account: models.AccountCount{ID: name, Name: name, Status: statusReachable}, client: client}
+}
+
+func countOf(result *models.SizingResult, resourceType models.ResourceType) *models.ResourceCount {
+	for _, rc := range result.ResourceCounts {
+		if rc.Type == resourceType {
+			return rc
+		}
+	}
+	return nil
+}
+
+func TestCountResources(t *testing.T) {
+	other := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		pod("web", "frontend-1", corev1.PodRunning, "nginx:1.27"),
+	)
+	unreachable := &cluster{account: models.AccountCount{ID: "lab", Status: statusUnreachable}, err: errors.New("connection refused")}
+	p := testProvider(t, reachable("prod", prodCluster()), reachable("staging", other), unreachable)
+
+	result, err := p.CountResources(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[models.ResourceType]int{
+		nodeType: 3, namespaceType: 2, deploymentType: 2, daemonSetType: 1, statefulSetType: 0, podType: 4, imageType: 3,
+	}
+	for resourceType, total := range want {
+		rc := countOf(result, resourceType)
+		if rc == nil {
+			t.Errorf("%s not counted", resourceType)
+			continue
+		}
+		if rc.TotalResources != total {
+			t.Errorf("%s = %d, want %d", resourceType, rc.TotalResources, total)
+		}
+	}
+
+	pods := countOf(result, podType)
+	if pods.ByLocation["web"] != 3 || pods.ByLocation["jobs"] != 1 {
+		t.Errorf("pods by namespace = %v, want web 3 and jobs 1", pods.ByLocation)
+	}
+	if pods.ByAccount["prod"] != 3 || pods.ByAccount["staging"] != 1 {
+		t.Errorf("pods by cluster = %v, want prod 3 and staging 1", pods.ByAccount)
+	}
+	if images := countOf(result, imageType); images.ByLocation != nil {
+		t.Errorf("images by namespace = %v, want none", images.ByLocation)
+	}
+
+	if result.AccountsDiscovered != 3 || result.AccountsScanned != 2 {
+		t.Errorf("accounts discovered/scanned = %d/%d, want 3/2", result.AccountsDiscovered, result.AccountsScanned)
+	}
+	if got := result.AccountCounts[0]; got.ResourceCount != 2+2+2+1+3+2 || got.ByType[podType] != 3 {
+		t.Errorf("prod account = %+v, want 12 resources with 3 pods", got)
+	}
+	if !containsWarning(result.Warnings, "cluster lab was unreachable") {
+		t.Errorf("warnings = %v, want the unreachable cluster", result.Warnings)
+	}
+}
+
+func TestCountResourcesSkipsForbiddenKinds(t *testing.T) {
+	prod, staging := prodCluster(), prodCluster()
+	forbid(staging, "pods")
+	forbid(prod, "statefulsets")
+	forbid(staging, "statefulsets")
+	p := testProvider(t, reachable("prod", prod), reachable("staging", staging))
+
+	result, err := p.CountResources(context.Background())
+
+	// Statefulsets could be listed nowhere: the type fails
+	var partial *sizingerrors.PartialResultError
+	if !errors.As(err, &partial) {
+		t.Fatalf("error = %v, want a partial result", err)
+	}
+	var permission *sizingerrors.PermissionError
+	if !errors.As(err, &permission) || permission.Action != "list statefulsets.apps" {
+		t.Errorf("error = %v, want a permission error for list statefulsets.apps", err)
+	}
+	if countOf(result, statefulSetType) != nil {
+		t.Error("statefulsets counted although forbidden in every cluster")
+	}
+
+	// Pods are counted where they can be listed, with a warning for the rest
+	if pods := countOf(result, podType); pods == nil || pods.TotalResources != 3 {
+		t.Errorf("pods = %+v, want the 3 of prod", pods)
+	}
+	if images := countOf(result, imageType); images == nil || images.TotalResources != 2 {
+		t.Errorf("images = %+v, want the 2 of prod", images)
+	}
+	if !containsWarning(result.Warnings, "cluster staging: k8s:pod not counted") {
+		t.Errorf("warnings = %v, want the forbidden pods of staging", result.Warnings)
+	}
+}
+
+func TestRunningPodsListedOnce(t *testing.T) {
+	client := prodCluster()
+	c := reachable("prod", client)
+	for range 2 {
+		if _, err := c.runningPods(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lists := 0
+	for _, action := range client.Actions() {
+		if action.Matches("list", "pods") {
+			lists++
+		}
+	}
+	if lists != 1 {
+		t.Errorf("pods listed %d times, want once", lists)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	newClient := func(restConfig *rest.Config) (kubernetes.Interface, error) {
+		client := fake.NewSimpleClientset()
+		if strings.Contains(restConfig.Host, "lab") {
+			client.PrependReactor("get", "version", func(k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("dial tcp: connection refused")
+			})
+		}
+		return client, nil
+	}
+
+	t.Run("current context", func(t *testing.T) {
+		p, _ := NewK8sProvider(config.ProviderConfig{Kubeconfig: path})
+		p.newClient = newClient
+		if err := p.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		accounts := p.Accounts()
+		if accounts.Source != models.AccountSourceKubeconfig || len(accounts.Accounts) != 1 {
+			t.Fatalf("accounts = %+v, want the current context", accounts)
+		}
+		if got := accounts.Accounts[0]; got.ID != "prod" || got.Name != "prod-cluster" || got.Status != statusReachable {
+			t.Errorf("account = %+v, want prod on prod-cluster, reachable", got)
+		}
+	})
+
+	t.Run("unreachable cluster is kept", func(t *testing.T) {
+		p, _ := NewK8sProvider(config.ProviderConfig{Kubeconfig: path, KubeContexts: []string{"prod", "lab"}})
+		p.newClient = newClient
+		if err := p.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		accounts := p.Accounts().Accounts
+		if len(accounts) != 2 || accounts[1].Status != statusUnreachable {
+			t.Errorf("accounts = %+v, want lab listed as unreachable", accounts)
+		}
+		if clusters := p.reachableClusters(); len(clusters) != 1 || clusters[0].account.ID != "prod" {
+			t.Errorf("reachable clusters = %d, want prod alone", len(clusters))
+		}
+	})
+
+	t.Run("no cluster reachable", func(t *testing.T) {
+		p, _ := NewK8sProvider(config.ProviderConfig{Kubeconfig: path, KubeContexts: []string{"lab"}})
+		p.newClient = newClient
+		err := p.Connect(context.Background())
+		var authErr *sizingerrors.AuthError
+		if !errors.As(err, &authErr) {
+			t.Errorf("error = %v, want an authentication error", err)
+		}
+	})
+
+	t.Run("unknown context", func(t *testing.T) {
+		p, _ := NewK8sProvider(config.ProviderConfig{Kubeconfig: path, KubeContexts: []string{"dev"}})
+		p.newClient = newClient
+		if err := p.Connect(context.Background()); err == nil || !strings.Contains(err.Error(), `context "dev" not found`) {
+			t.Errorf("error = %v, want the unknown context", err)
+		}
+	})
+}
+
+func TestSelectContextsNeedsCurrentContext(t *testing.T) {
+	if _, err := selectContexts(clientcmdapi.NewConfig(), nil); err == nil {
+		t.Error("no error without a current context")
+	}
+}
+
+func TestPlanListsPodsOnce(t *testing.T) {
+	p := testProvider(t, reachable("prod", fake.NewSimpleClientset()), reachable("staging", fake.NewSimpleClientset()))
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.ResourceTypes) != len(kinds) {
+		t.Errorf("planned %d types, want %d", len(plan.ResourceTypes), len(kinds))
+	}
+	// Six listings in each of two clusters
+	if plan.EstimatedAPICalls != 12 {
+		t.Errorf("estimated calls = %d, want 12", plan.EstimatedAPICalls)
+	}
+}
+
+// TestKindsCoverDefinitions makes sure every built-in k8s definition has a
+// counter
+func TestKindsCoverDefinitions(t *testing.T) {
+	for _, def := range k8sDefinitions(t) {
+		if _, ok := kinds[def.ResourceType()]; !ok {
+			t.Errorf("no counter for %s", def.Type)
+		}
+	}
+}
+
+func containsWarning(warnings []string, substring string) bool {
+	for _, warning := range warnings {
+		if strings.Contains(warning, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	nodeType        models.ResourceType = "k8s:node"
+	namespaceType   models.ResourceType = "k8s:namespace"
+	deploymentType  models.ResourceType = "k8s:deployment"
+	daemonSetType   models.ResourceType = "k8s:daemonset"
+	statefulSetType models.ResourceType = "k8s:statefulset"
+	podType         models.ResourceType = "k8s:pod"
+	imageType       models.ResourceType = "k8s:image"
+
+	// listPageSize is the number of objects asked for per list call
+	listPageSize = 500
+
+	// runningPods selects the pods whose containers are running
+	runningPods = "status.phase=Running"
+)
+
+// kindCount is what one cluster holds of a kind. byNamespace is nil for
+// cluster-scoped kinds and for images, which are counted cluster-wide.
+type kindCount struct {
+	total       int
+	byNamespace map[string]int
+}
+
+// add counts one object in namespace
+func (c *kindCount) add(namespace string) {
+	c.total++
+	if c.byNamespace == nil {
+		c.byNamespace = make(map[string]int)
+	}
+	c.byNamespace[namespace]++
+}
+
+// kind is how a resource type is counted in a cluster
+type kind struct {
+	// resource is the API resource listed, as named in RBAC rules
+	resource string
+	// group is the API group of resource, "" for the core group
+	group string
+
+	count func(ctx context.Context, c *cluster) (kindCount, error)
+}
+
+// kinds are the resource types the provider knows how to count
+var kinds = map[models.ResourceType]kind{
+	nodeType:        {resource: "nodes", count: countNodes},
+	namespaceType:   {resource: "namespaces", count: countNamespaces},
+	deploymentType:  {resource: "deployments", group: "apps", count: countDeployments},
+	daemonSetType:   {resource: "daemonsets", group: "apps", count: countDaemonSets},
+	statefulSetType: {resource: "statefulsets", group: "apps", count: countStatefulSets},
+	podType: {resource: "pods", count: func(ctx context.Context, c *cluster) (kindCount, error) {
+		pods, err := c.runningPods(ctx)
+		return pods.pods, err
+	}},
+	imageType: {resource: "pods", count: func(ctx context.Context, c *cluster) (kindCount, error) {
+		pods, err := c.runningPods(ctx)
+		return kindCount{total: len(pods.images)}, err
+	}},
+}
+
+// listAll calls list with the continue token of each page until the last
+func listAll(ctx context.Context, opts metav1.ListOptions, list func(context.Context, metav1.ListOptions) (string, error)) error {
+	opts.Limit = listPageSize
+	for {
+		next, err := list(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		opts.Continue = next
+	}
+}
+
+func countNodes(ctx context.Context, c *cluster) (kindCount, error) {
+	var count kindCount
+	err := listAll(ctx, metav1.ListOptions{}, func(ctx context.Context, opts metav1.ListOptions) (string, error) {
+		list, err := c.client.CoreV1().Nodes().List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		count.total += len(list.Items)
+		return list.Continue, nil
+	})
+	return count, err
+}
+
+func countNamespaces(ctx context.Context, c *cluster) (kindCount, error) {
+	var count kindCount
+	err := listAll(ctx, metav1.ListOptions{}, func(ctx context.Context, opts metav1.ListOptions) (string, error) {
+		list, err := c.client.CoreV1().Namespaces().List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		count.total += len(list.Items)
+		return list.Continue, nil
+	})
+	return count, err
+}
+
+func countDeployments(ctx context.Context, c *cluster) (kindCount, error) {
+	var count kindCount
+	err := listAll(ctx, metav1.ListOptions{}, func(ctx context.Context, opts metav1.ListOptions) (string, error) {
+		list, err := c.client.AppsV1().Deployments(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range list.Items {
+			count.add(item.Namespace)
+		}
+		return list.Continue, nil
+	})
+	return count, err
+}
+
+func countDaemonSets(ctx context.Context, c *cluster) (kindCount, error) {
+	var count kindCount
+	err := listAll(ctx, metav1.ListOptions{}, func(ctx context.Context, opts metav1.ListOptions) (string, error) {
+		list, err := c.client.AppsV1().DaemonSets(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range list.Items {
+			count.add(item.Namespace)
+		}
+		return list.Continue, nil
+	})
+	return count, err
+}
+
+func countStatefulSets(ctx context.Context, c *cluster) (kindCount, error) {
+	var count kindCount
+	err := listAll(ctx, metav1.ListOptions{}, func(ctx context.Context, opts metav1.ListOptions) (string, error) {
+		list, err := c.client.AppsV1().StatefulSets(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range list.Items {
+			count.add(item.Namespace)
+		}
+		return list.Continue, nil
+	})
+	return count, err
+}
+
+// podSummary is what the running pods of a cluster add up to: the pods by
+// namespace and the distinct images their containers run
+type podSummary struct {
+	pods   kindCount
+	images map[string]bool
+}
+
+// podLister lists the running pods of a cluster once per scan, however many
+// types are counted from them. A failed listing is not kept, so that a
+// retry lists again.
+type podLister struct {
+	mu      sync.Mutex
+	summary *podSummary
+}
+
+// runningPods returns the summary of the running pods of the cluster,
+// listing them on the first call
+func (c *cluster) runningPods(ctx context.Context) (podSummary, error) {
+	c.pods.mu.Lock()
+	defer c.pods.mu.Unlock()
+	if c.pods.summary == nil {
+		summary, err := summarizePods(ctx, c.client)
+		if err != nil {
+			return podSummary{}, err
+		}
+		c.pods.summary = &summary
+	}
+	return *c.pods.summary, nil
+}
+
+// summarizePods pages through the running pods of every namespace
+func summarizePods(ctx context.Context, client kubernetes.Interface) (podSummary, error) {
+	summary := podSummary{images: make(map[string]bool)}
+	err := listAll(ctx, metav1.ListOptions{FieldSelector: runningPods}, func(ctx context.Context, opts metav1.ListOptions) (string, error) {
+		list, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, pod := range list.Items {
+			// Not every API server honors the field selector
+			if pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			summary.pods.add(pod.Namespace)
+			addImages(summary.images, pod.Spec)
+		}
+		return list.Continue, nil
+	})
+	return summary, err
+}
+
+// addImages adds the images of the containers of spec to images. Init and
+// ephemeral containers are included since they run the same code a scanner
+// would inspect.
+func addImages(images map[string]bool, spec corev1.PodSpec) {
+	for _, container := range spec.InitContainers {
+		images[container.Image] = true
+	}
+	for _, container := range spec.Containers {
+		images[container.Image] = true
+	}
+	for _, container := range spec.EphemeralContainers {
+		images[container.Image] = true
+	}
+}
@@ -7,8 +7,26 @@ import (
 	"github.com/secrails/secrails-sizing-agent/internal/providers/aws"
 	"github.com/secrails/secrails-sizing-agent/internal/providers/azure"
 	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/k8s"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/oci"
 )
 
+// The built-in providers
+func init() {
+	Register("aws", func(cfg config.ProviderConfig) (Provider, error) {
+		return aws.NewAWSProvider(cfg)
+	})
+	Register("azure", func(cfg config.ProviderConfig) (Provider, error) {
+		return azure.NewAzureProvider(cfg)
+	})
+	Register("k8s", func(cfg config.ProviderConfig) (Provider, error) {
+		return k8s.NewK8sProvider(cfg)
+	})
+	Register("oci", func(cfg config.ProviderConfig) (Provider, error) {
+		return oci.NewOCIProvider(cfg)
+	})
+}
+
 type ProviderManager struct {
 	verbose bool
 }
@@ -20,22 +38,20 @@ func NewManager(verbose bool) *ProviderManager {
 	}
 }
 
-// GetProvider returns the appropriate provider based on the name
-func (m *ProviderManager) GetProvider(providerName string) (Provider, error) {
-	// Normalize provider name
-	providerName = strings.ToLower(strings.TrimSpace(providerName))
+// GetProvider builds the provider registered under the configured name
+func (m *ProviderManager) GetProvider(cfg config.ProviderConfig) (Provider, error) {
+	// Normalize provider name, resolving aliases such as "az"
+	cfg.Provider = Normalize(cfg.Provider)
 
-	config := config.ProviderConfig{
-		Provider:  providerName,
-		Regions:   []string{},
-		Resources: []string{},
+	if cfg.Regions == nil {
+		cfg.Regions = []string{}
+	}
+	if cfg.Resources == nil {
+		cfg.Resources = []string{}
 	}
-	switch providerName {
-	case "aws":
-		return aws.NewAWSProvider(config)
-	case "azure":
-		return azure.NewAzureProvider(config)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", providerName)
+	factory, ok := lookup(cfg.Provider)
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider: %s (supported: %s)", cfg.Provider, strings.Join(ListProviders(), ", "))
 	}
+	return factory(cfg)
 }
@@ -0,0 +1,80 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/common/auth"
+)
+
+// Authentication methods selectable with --oci-auth
+const (
+	AuthConfig            = "config"
+	AuthInstancePrincipal = "instance_principal"
+)
+
+// AuthMethods lists the accepted --oci-auth values
+var AuthMethods = []string{AuthConfig, AuthInstancePrincipal}
+
+// Environment variables read by the config file method, as by the OCI CLI
+const (
+	envConfigFile = "OCI_CLI_CONFIG_FILE"
+	envProfile    = "OCI_CLI_PROFILE"
+)
+
+// defaultProfile is the profile of the config file used when none is set
+const defaultProfile = "DEFAULT"
+
+// ValidAuthMethod reports whether method is one of AuthMethods
+func ValidAuthMethod(method string) bool {
+	for _, m := range AuthMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// configurationProvider returns the signing configuration of the configured
+// method, with the method's name
+func (p *OCIProvider) configurationProvider() (common.ConfigurationProvider, string, error) {
+	switch p.config.OCIAuth {
+	case "", AuthConfig:
+		path, profile := p.configFile()
+		provider, err := common.ConfigurationProviderFromFileWithProfile(path, profile, "")
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read profile %s of %s: %w", profile, path, err)
+		}
+		return provider, AuthConfig, nil
+	case AuthInstancePrincipal:
+		provider, err := auth.InstancePrincipalConfigurationProvider()
+		if err != nil {
+			return nil, "", fmt.Errorf("instance principal authentication: %w", err)
+		}
+		return provider, AuthInstancePrincipal, nil
+	}
+	return nil, "", fmt.Errorf("unsupported OCI authentication method %q", p.config.OCIAuth)
+}
+
+// configFile returns the config file and profile to read: the configured
+// ones, else those of the OCI CLI environment variables, else
+// ~/.oci/config and DEFAULT
+func (p *OCIProvider) configFile() (path, profile string) {
+	path, profile = p.config.OCIConfigFile, p.config.OCIProfile
+	if path == "" {
+		path = os.Getenv(envConfigFile)
+	}
+	if path == "" {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, ".oci", "config")
+	}
+	if profile == "" {
+		profile = os.Getenv(envProfile)
+	}
+	if profile == "" {
+		profile = defaultProfile
+	}
+	return path, profile
+}
@@ -0,0 +1,440 @@
+// Package oci counts Oracle Cloud Infrastructure resources with the Search
+// service of each subscribed region. The compartments of the tenancy are the
+// accounts.
+package oci
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/metrics"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// providerName is the name the provider is registered and labelled with
+	providerName = "oci"
+
+	// regionConcurrency bounds the regions searched at once
+	regionConcurrency = 4
+
+	// searchAction is the IAM verb and resource Search needs
+	searchAction = "inspect all-resources"
+)
+
+// identityAPI is the part of the Identity client discovery uses
+type identityAPI interface {
+	GetTenancy(ctx context.Context, request identity.GetTenancyRequest) (identity.GetTenancyResponse, error)
+	ListRegionSubscriptions(ctx context.Context, request identity.ListRegionSubscriptionsRequest) (identity.ListRegionSubscriptionsResponse, error)
+	ListCompartments(ctx context.Context, request identity.ListCompartmentsRequest) (identity.ListCompartmentsResponse, error)
+}
+
+// OCIProvider implements the Provider interface for Oracle Cloud
+// Infrastructure
+type OCIProvider struct {
+	// mu guards the tenancy, regions and compartments that Connect
+	// discovers; Plan and CountResources copy them under the read lock
+	mu sync.RWMutex
+
+	config     config.ProviderConfig
+	authMethod string
+	tenancyID  string
+
+	identity identityAPI
+	// newSearch returns a Search client bound to a region
+	newSearch func(region string) (searchAPI, error)
+
+	regions      []string
+	compartments []models.AccountCount
+
+	// subscribedRegionCount is the number of regions the tenancy is
+	// subscribed to, before --regions limited them
+	subscribedRegionCount int
+}
+
+// NewOCIProvider creates a new OCI provider
+func NewOCIProvider(cfg config.ProviderConfig) (*OCIProvider, error) {
+	return &OCIProvider{config: cfg}, nil
+}
+
+// Name returns the provider name
+func (p *OCIProvider) Name() string {
+	return providerName
+}
+
+// Connect authenticates, then discovers the subscribed regions and the
+// compartments of the tenancy
+func (p *OCIProvider) Connect(ctx context.Context) error {
+	ctx = logging.NewContext(ctx, p.config.Log())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.config.Log().Info("Connecting to OCI...")
+
+	configProvider, authMethod, err := p.configurationProvider()
+	if err != nil {
+		return &sizingerrors.AuthError{Provider: providerName, Err: err}
+	}
+	tenancyID, err := configProvider.TenancyOCID()
+	if err != nil {
+		return &sizingerrors.AuthError{Provider: providerName, Err: fmt.Errorf("failed to read the tenancy: %w", err)}
+	}
+
+	identityClient, err := identity.NewIdentityClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return fmt.Errorf("failed to create OCI identity client: %w", err)
+	}
+	p.setHTTPClient(&identityClient.BaseClient)
+
+	p.authMethod = authMethod
+	p.tenancyID = tenancyID
+	p.identity = identityClient
+	p.newSearch = func(region string) (searchAPI, error) {
+		client, err := resourcesearch.NewResourceSearchClientWithConfigurationProvider(configProvider)
+		if err != nil {
+			return nil, err
+		}
+		client.SetRegion(region)
+		p.setHTTPClient(&client.BaseClient)
+		return client, nil
+	}
+
+	if err := p.discover(ctx); err != nil {
+		return err
+	}
+
+	p.config.Log().Info("Connected to OCI successfully")
+	p.config.Log().Info("Tenancy", zap.String("tenancy_id", p.tenancyID))
+	p.config.Log().Info("Compartments found", zap.Int("count", len(p.compartments)))
+	p.config.Log().Info("Regions to scan", zap.Strings("regions", p.regions))
+	return nil
+}
+
+// setHTTPClient sends the requests of client through the configured HTTP
+// client, when a CA bundle or proxy needs one
+func (p *OCIProvider) setHTTPClient(client *common.BaseClient) {
+	if p.config.HTTPClient != nil {
+		client.HTTPClient = p.config.HTTPClient
+	}
+}
+
+// discover reads the tenancy, its subscribed regions and its compartments
+// with the identity client
+func (p *OCIProvider) discover(ctx context.Context) error {
+	tenancy, err := p.identity.GetTenancy(ctx, identity.GetTenancyRequest{TenancyId: common.String(p.tenancyID)})
+	if err != nil {
+		return fmt.Errorf("failed to verify OCI credentials: %w", classifyError(err, "inspect tenancies"))
+	}
+
+	regions, subscribed, err := p.subscribedRegions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(regions) == 0 && len(p.config.Regions) > 0 && len(subscribed) > 0 {
+		return fmt.Errorf("none of the regions of --regions is subscribed by the tenancy, nothing to scan: %s",
+			models.Unmatched(p.config.Regions, subscribed))
+	}
+	if len(regions) == 0 {
+		return fmt.Errorf("no subscribed OCI regions to scan")
+	}
+
+	compartments, err := p.listCompartments(ctx, stringValue(tenancy.Name))
+	if err != nil {
+		return err
+	}
+
+	p.regions = regions
+	p.subscribedRegionCount = len(subscribed)
+	p.compartments = compartments
+	return nil
+}
+
+// regionPattern matches OCI region names such as us-ashburn-1, in any case
+var regionPattern = regexp.MustCompile(`(?i)^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// ValidRegion reports whether region is shaped like an OCI region name; it
+// does not check that the tenancy is subscribed to it
+func ValidRegion(region string) bool {
+	return regionPattern.MatchString(region)
+}
+
+// subscribedRegions returns the ready regions of the tenancy limited to the
+// configured regions if any, and all of its ready regions
+func (p *OCIProvider) subscribedRegions(ctx context.Context) (regions, subscribed []string, err error) {
+	response, err := p.identity.ListRegionSubscriptions(ctx, identity.ListRegionSubscriptionsRequest{TenancyId: common.String(p.tenancyID)})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list region subscriptions: %w", classifyError(err, "inspect tenancies"))
+	}
+
+	wanted := make(map[string]bool)
+	for _, region := range p.config.Regions {
+		wanted[strings.ToLower(region)] = true
+	}
+	filtered := len(wanted) > 0
+	for _, subscription := range response.Items {
+		region := stringValue(subscription.RegionName)
+		if subscription.Status != identity.RegionSubscriptionStatusReady {
+			continue
+		}
+		subscribed = append(subscribed, region)
+		if filtered && !wanted[strings.ToLower(region)] {
+			continue
+		}
+		delete(wanted, strings.ToLower(region))
+		regions = append(regions, region)
+	}
+	for region := range wanted {
+		p.config.Log().Warn("Region is not subscribed by the tenancy; skipping it",
+			zap.String("region", region),
+			zap.Strings("closest_subscribed", models.Suggest(region, subscribed)))
+	}
+	sort.Strings(regions)
+	sort.Strings(subscribed)
+	return regions, subscribed, nil
+}
+
+// listCompartments returns the root compartment, named after the tenancy,
+// and every active compartment below it the credentials can access
+func (p *OCIProvider) listCompartments(ctx context.Context, tenancyName string) ([]models.AccountCount, error) {
+	compartments := []models.AccountCount{{
+		ID:     p.tenancyID,
+		Name:   tenancyName,
+		Status: string(identity.CompartmentLifecycleStateActive),
+	}}
+
+	request := identity.ListCompartmentsRequest{
+		CompartmentId:          common.String(p.tenancyID),
+		CompartmentIdInSubtree: common.Bool(true),
+		AccessLevel:            identity.ListCompartmentsAccessLevelAccessible,
+		LifecycleState:         identity.CompartmentLifecycleStateActive,
+	}
+	for {
+		response, err := p.identity.ListCompartments(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list compartments: %w", classifyError(err, "inspect compartments"))
+		}
+		for _, compartment := range response.Items {
+			account := models.AccountCount{
+				ID:     stringValue(compartment.Id),
+				Name:   stringValue(compartment.Name),
+				Status: string(compartment.LifecycleState),
+			}
+			if len(compartment.FreeformTags) > 0 {
+				account.Tags = compartment.FreeformTags
+			}
+			compartments = append(compartments, account)
+		}
+		if response.OpcNextPage == nil || *response.OpcNextPage == "" {
+			return compartments, nil
+		}
+		request.Page = response.OpcNextPage
+	}
+}
+
+// Accounts lists the discovered compartments
+func (p *OCIProvider) Accounts() *models.AccountList {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return models.NewAccountList("OCI", models.AccountSourceCompartments, p.compartments)
+}
+
+// Plan describes what CountResources would do with the discovered regions
+func (p *OCIProvider) Plan() (*models.ScanPlan, error) {
+	p.mu.RLock()
+	compartments := make([]models.AccountCount, len(p.compartments))
+	copy(compartments, p.compartments)
+	regions := make([]string, len(p.regions))
+	copy(regions, p.regions)
+	p.mu.RUnlock()
+
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no regions available to scan")
+	}
+
+	plan := &models.ScanPlan{Provider: "OCI", Accounts: compartments, Regions: regions, Concurrency: regionConcurrency}
+	for i, def := range p.config.Definitions {
+		planned := models.PlannedType{
+			Type:        def.ResourceType(),
+			DisplayName: def.DisplayName,
+			CountMethod: def.CountMethod,
+			API:         "resourcesearch:SearchResources",
+			Query:       searchQuery,
+		}
+		// One search per region counts every type
+		if i == 0 {
+			planned.EstimatedCalls = len(regions)
+		}
+		plan.AddType(planned)
+	}
+	return plan, nil
+}
+
+// CountResources searches every region and counts the resources of each
+// defined type by region, compartment and lifecycle state. A region whose
+// search fails is left out with a warning; it is an error only when every
+// region fails.
+func (p *OCIProvider) CountResources(ctx context.Context) (*models.SizingResult, error) {
+	ctx = logging.NewContext(ctx, p.config.Log())
+
+	p.config.Log().Info("Counting OCI resources...")
+
+	p.mu.RLock()
+	compartments := make([]models.AccountCount, len(p.compartments))
+	copy(compartments, p.compartments)
+	regions := make([]string, len(p.regions))
+	copy(regions, p.regions)
+	newSearch := p.newSearch
+	authMethod := p.authMethod
+	subscribedRegionCount := p.subscribedRegionCount
+	p.mu.RUnlock()
+
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no regions available to scan")
+	}
+
+	result := &models.SizingResult{
+		SchemaVersion: models.SchemaVersion,
+		Provider:      "OCI",
+		Timestamp:     time.Now(),
+		AuthMethod:    authMethod,
+	}
+	if len(p.config.Regions) > 0 {
+		result.Scope = &models.ScanScope{Regions: regions, AvailableRegions: subscribedRegionCount}
+	}
+
+	types := make(map[string]bool)
+	for _, def := range p.config.Definitions {
+		types[searchType(def)] = true
+	}
+
+	start := time.Now()
+	tallies := make([]regionTally, len(regions))
+	errs := make([]error, len(regions))
+	semaphore := make(chan struct{}, regionConcurrency)
+	var wg sync.WaitGroup
+	for i, region := range regions {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			client, err := newSearch(region)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			tallies[i], errs[i] = searchRegion(ctx, client, types, p.config.TagFilters)
+		}(i, region)
+	}
+	wg.Wait()
+
+	var failedRegions []string
+	var firstErr error
+	for i, region := range regions {
+		if errs[i] == nil {
+			continue
+		}
+		err := classifyError(errs[i], searchAction)
+		if firstErr == nil {
+			firstErr = err
+		}
+		p.config.Log().Warn("Failed to search region; its resources are not counted",
+			zap.String("region", region),
+			zap.Error(err))
+		result.Warnings = append(result.Warnings, fmt.Sprintf("region %s could not be searched and is not counted: %v", region, err))
+		failedRegions = append(failedRegions, region)
+	}
+	if len(failedRegions) == len(regions) {
+		return nil, fmt.Errorf("no region could be searched: %w", firstErr)
+	}
+
+	for _, def := range p.config.Definitions {
+		count := &models.ResourceCount{
+			Type:           def.ResourceType(),
+			CanonicalType:  def.CanonicalType(),
+			Category:       def.Category,
+			ByLocation:     make(map[string]int),
+			ByAccount:      make(map[string]int),
+			RegionsQueried: len(regions),
+			FailedRegions:  failedRegions,
+		}
+		for i, region := range regions {
+			tally := tallies[i][searchType(def)]
+			if tally == nil {
+				continue
+			}
+			count.TotalResources += tally.total
+			count.ByLocation[region] += tally.total
+			for compartment, n := range tally.byCompartment {
+				count.ByAccount[compartment] += n
+			}
+			for state, n := range tally.byState {
+				if count.ByState == nil {
+					count.ByState = make(map[string]int)
+				}
+				count.ByState[state] += n
+			}
+		}
+		metrics.ResourceTypeScanned(providerName, string(count.Type), time.Since(start))
+		p.config.StreamCount(count)
+		result.ResourceCounts = append(result.ResourceCounts, count)
+		result.TotalResources += count.TotalResources
+	}
+
+	result.AccountCounts = compartments
+	result.TotalAccounts = len(compartments)
+	result.AccountsDiscovered = len(compartments)
+	result.AccountsScanned = len(compartments)
+
+	p.config.Log().Info("Resource counting completed",
+		zap.Int("total_resources", result.TotalResources),
+		zap.Int("resource_types_counted", len(result.ResourceCounts)),
+		zap.Int("compartments", result.TotalAccounts))
+	return result, nil
+}
+
+// classifyError wraps the failures of an OCI call that the user can act on:
+// rejected credentials, a missing IAM policy for action, and throttling
+func classifyError(err error, action string) error {
+	serviceErr, ok := common.IsServiceError(err)
+	if !ok {
+		return err
+	}
+	switch serviceErr.GetHTTPStatusCode() {
+	case http.StatusUnauthorized:
+		return &sizingerrors.AuthError{Provider: providerName, Err: err}
+	case http.StatusForbidden:
+		return &sizingerrors.PermissionError{Provider: providerName, Action: action, Err: err}
+	case http.StatusNotFound:
+		// OCI answers NotAuthorizedOrNotFound when a policy is missing
+		if serviceErr.GetCode() == "NotAuthorizedOrNotFound" {
+			return &sizingerrors.PermissionError{Provider: providerName, Action: action, Err: err}
+		}
+	case http.StatusTooManyRequests:
+		return &sizingerrors.ThrottledError{Provider: providerName, Err: err}
+	}
+	return err
+}
+
+// Close closes any open connections
+func (p *OCIProvider) Close() error {
+	p.config.Log().Info("Closing OCI provider connections")
+	// OCI SDK clients don't require explicit closing
+	return nil
+}
@@ -0,0 +1,307 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/identity"
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+const testTenancy = "ocid1.tenancy.oc1..root"
+
+// serviceError is an OCI service error with a status and code
+type serviceError struct {
+	status int
+	code   string
+}
+
+func (e serviceError) Error() string           { return fmt.Sprintf("%d %s", e.status, e.code) }
+func (e serviceError) GetHTTPStatusCode() int  { return e.status }
+func (e serviceError) GetMessage() string      { return e.code }
+func (e serviceError) GetCode() string         { return e.code }
+func (e serviceError) GetOpcRequestID() string { return "" }
+
+// fakeIdentity serves a tenancy with its region subscriptions and
+// compartments, a page of compartments per call
+type fakeIdentity struct {
+	regions          []identity.RegionSubscription
+	compartmentPages [][]identity.Compartment
+	requests         []identity.ListCompartmentsRequest
+}
+
+func (f *fakeIdentity) GetTenancy(context.Context, identity.GetTenancyRequest) (identity.GetTenancyResponse, error) {
+	return identity.GetTenancyResponse{Tenancy: identity.Tenancy{Name: common.String("acme")}}, nil
+}
+
+func (f *fakeIdentity) ListRegionSubscriptions(context.Context, identity.ListRegionSubscriptionsRequest) (identity.ListRegionSubscriptionsResponse, error) {
+	return identity.ListRegionSubscriptionsResponse{Items: f.regions}, nil
+}
+
+func (f *fakeIdentity) ListCompartments(_ context.Context, request identity.ListCompartmentsRequest) (identity.ListCompartmentsResponse, error) {
+	f.requests = append(f.requests, request)
+	page := len(f.requests) - 1
+	response := identity.ListCompartmentsResponse{Items: f.compartmentPages[page]}
+	if page+1 < len(f.compartmentPages) {
+		response.OpcNextPage = common.String(fmt.Sprint(page + 1))
+	}
+	return response, nil
+}
+
+// fakeSearch returns a page of resources per call, or fails
+type fakeSearch struct {
+	pages    [][]resourcesearch.ResourceSummary
+	err      error
+	requests []resourcesearch.SearchResourcesRequest
+}
+
+func (f *fakeSearch) SearchResources(_ context.Context, request resourcesearch.SearchResourcesRequest) (resourcesearch.SearchResourcesResponse, error) {
+	if f.err != nil {
+		return resourcesearch.SearchResourcesResponse{}, f.err
+	}
+	f.requests = append(f.requests, request)
+	page := len(f.requests) - 1
+	response := resourcesearch.SearchResourcesResponse{
+		ResourceSummaryCollection: resourcesearch.ResourceSummaryCollection{Items: f.pages[page]},
+	}
+	if page+1 < len(f.pages) {
+		response.OpcNextPage = common.String(fmt.Sprint(page + 1))
+	}
+	return response, nil
+}
+
+func resource(resourceType, compartment, state string) resourcesearch.ResourceSummary {
+	return resourcesearch.ResourceSummary{
+		ResourceType:   common.String(resourceType),
+		CompartmentId:  common.String(compartment),
+		LifecycleState: common.String(state),
+	}
+}
+
+func ociDefinitions(t *testing.T) []models.ResourceDefinition {
+	t.Helper()
+	set, err := models.DefaultDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set.ForProvider("oci")
+}
+
+func subscription(region string, status identity.RegionSubscriptionStatusEnum) identity.RegionSubscription {
+	return identity.RegionSubscription{RegionName: common.String(region), Status: status}
+}
+
+func TestDiscover(t *testing.T) {
+	fake := &fakeIdentity{
+		regions: []identity.RegionSubscription{
+			subscription("us-ashburn-1", identity.RegionSubscriptionStatusReady),
+			subscription("eu-frankfurt-1", identity.RegionSubscriptionStatusReady),
+			subscription("uk-london-1", identity.RegionSubscriptionStatusInProgress),
+		},
+		compartmentPages: [][]identity.Compartment{
+			{{Id: common.String("ocid1.compartment.oc1..prod"), Name: common.String("prod"),
+				LifecycleState: identity.CompartmentLifecycleStateActive, FreeformTags: map[string]string{"team": "web"}}},
+			{{Id: common.String("ocid1.compartment.oc1..prod-db"), Name: common.String("prod-db"),
+				LifecycleState: identity.CompartmentLifecycleStateActive}},
+		},
+	}
+	p := &OCIProvider{tenancyID: testTenancy, identity: fake}
+
+	if err := p.discover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"eu-frankfurt-1", "us-ashburn-1"}; !reflect.DeepEqual(p.regions, want) {
+		t.Errorf("regions = %v, want the ready ones %v", p.regions, want)
+	}
+	var names []string
+	for _, compartment := range p.compartments {
+		names = append(names, compartment.Name)
+	}
+	if want := []string{"acme", "prod", "prod-db"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("compartments = %v, want the root and both pages %v", names, want)
+	}
+	if p.compartments[0].ID != testTenancy || p.compartments[1].Tags["team"] != "web" {
+		t.Errorf("compartments = %+v, want the tenancy as root and the compartment tags", p.compartments)
+	}
+	if request := fake.requests[0]; request.CompartmentIdInSubtree == nil || !*request.CompartmentIdInSubtree {
+		t.Error("compartments not listed recursively")
+	}
+}
+
+func TestDiscoverLimitsRegions(t *testing.T) {
+	fake := &fakeIdentity{
+		regions: []identity.RegionSubscription{
+			subscription("us-ashburn-1", identity.RegionSubscriptionStatusReady),
+			subscription("eu-frankfurt-1", identity.RegionSubscriptionStatusReady),
+		},
+		compartmentPages: [][]identity.Compartment{nil},
+	}
+	p := &OCIProvider{
+		config:    config.ProviderConfig{Regions: []string{"eu-frankfurt-1", "ap-tokyo-1"}},
+		tenancyID: testTenancy,
+		identity:  fake,
+	}
+
+	if err := p.discover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"eu-frankfurt-1"}; !reflect.DeepEqual(p.regions, want) {
+		t.Errorf("regions = %v, want %v", p.regions, want)
+	}
+}
+
+func TestDiscoverNoRegionMatches(t *testing.T) {
+	fake := &fakeIdentity{
+		regions: []identity.RegionSubscription{
+			subscription("us-ashburn-1", identity.RegionSubscriptionStatusReady),
+			subscription("eu-frankfurt-1", identity.RegionSubscriptionStatusReady),
+		},
+		compartmentPages: [][]identity.Compartment{nil},
+	}
+	p := &OCIProvider{
+		config:    config.ProviderConfig{Regions: []string{"eu-frankfrut-1"}},
+		tenancyID: testTenancy,
+		identity:  fake,
+	}
+
+	err := p.discover(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "eu-frankfrut-1 (did you mean eu-frankfurt-1?)") {
+		t.Errorf("discover() error = %v, want the closest subscribed region", err)
+	}
+}
+
+func testProvider(t *testing.T, searches map[string]*fakeSearch) *OCIProvider {
+	t.Helper()
+	p := &OCIProvider{
+		config:       config.ProviderConfig{Definitions: ociDefinitions(t)},
+		authMethod:   AuthConfig,
+		tenancyID:    testTenancy,
+		compartments: []models.AccountCount{{ID: testTenancy, Name: "acme"}, {ID: "prod", Name: "prod"}},
+		newSearch: func(region string) (searchAPI, error) {
+			return searches[region], nil
+		},
+	}
+	for region := range searches {
+		p.regions = append(p.regions, region)
+	}
+	return p
+}
+
+func countOf(result *models.SizingResult, resourceType models.ResourceType) *models.ResourceCount {
+	for _, rc := range result.ResourceCounts {
+		if rc.Type == resourceType {
+			return rc
+		}
+	}
+	return nil
+}
+
+func TestCountResources(t *testing.T) {
+	p := testProvider(t, map[string]*fakeSearch{
+		"us-ashburn-1": {pages: [][]resourcesearch.ResourceSummary{
+			{
+				resource("Instance", "prod", "RUNNING"),
+				resource("Instance", "prod", "STOPPED"),
+				resource("Instance", "prod", "TERMINATED"),
+				resource("Bucket", testTenancy, ""),
+				resource("Volume", "prod", "AVAILABLE"),
+			},
+			{resource("instance", testTenancy, "RUNNING")},
+		}},
+		"eu-frankfurt-1": {err: serviceError{status: http.StatusNotFound, code: "NotAuthorizedOrNotFound"}},
+	})
+
+	result, err := p.CountResources(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instances := countOf(result, "oci:Instance")
+	if instances.TotalResources != 3 {
+		t.Errorf("instances = %d, want 3 without the terminated one", instances.TotalResources)
+	}
+	if instances.ByLocation["us-ashburn-1"] != 3 || instances.ByAccount["prod"] != 2 || instances.ByAccount[testTenancy] != 1 {
+		t.Errorf("instances by region %v and compartment %v", instances.ByLocation, instances.ByAccount)
+	}
+	if instances.ByState["RUNNING"] != 2 || instances.ByState["STOPPED"] != 1 {
+		t.Errorf("instances by state = %v, want 2 running and 1 stopped", instances.ByState)
+	}
+	if instances.CanonicalType != models.ResourceTypeVirtualMachine || instances.Category != "Compute" {
+		t.Errorf("instances = %+v, want a Compute virtual machine", instances)
+	}
+	if !reflect.DeepEqual(instances.FailedRegions, []string{"eu-frankfurt-1"}) || instances.RegionsQueried != 2 {
+		t.Errorf("failed regions = %v of %d, want eu-frankfurt-1 of 2", instances.FailedRegions, instances.RegionsQueried)
+	}
+
+	if buckets := countOf(result, "oci:Bucket"); buckets.TotalResources != 1 || buckets.ByState != nil {
+		t.Errorf("buckets = %+v, want 1 without states", buckets)
+	}
+	if result.TotalResources != 4 {
+		t.Errorf("TotalResources = %d, want 4, leaving out the undefined volume", result.TotalResources)
+	}
+	if result.TotalAccounts != 2 || len(result.Warnings) != 1 {
+		t.Errorf("accounts = %d, warnings = %v, want 2 compartments and the failed region", result.TotalAccounts, result.Warnings)
+	}
+}
+
+func TestCountResourcesFailsWhenNoRegionCanBeSearched(t *testing.T) {
+	p := testProvider(t, map[string]*fakeSearch{
+		"us-ashburn-1": {err: serviceError{status: http.StatusNotFound, code: "NotAuthorizedOrNotFound"}},
+	})
+
+	_, err := p.CountResources(context.Background())
+	var permission *sizingerrors.PermissionError
+	if !errors.As(err, &permission) || permission.Action != searchAction {
+		t.Errorf("error = %v, want a permission error for %s", err, searchAction)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want any
+	}{
+		{"unauthorized", serviceError{status: http.StatusUnauthorized, code: "NotAuthenticated"}, &sizingerrors.AuthError{}},
+		{"not authorized", serviceError{status: http.StatusNotFound, code: "NotAuthorizedOrNotFound"}, &sizingerrors.PermissionError{}},
+		{"throttled", serviceError{status: http.StatusTooManyRequests, code: "TooManyRequests"}, &sizingerrors.ThrottledError{}},
+		{"not found", serviceError{status: http.StatusNotFound, code: "NotFound"}, nil},
+		{"network", errors.New("dial tcp: timeout"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError(tt.err, searchAction)
+			if tt.want == nil {
+				if got != tt.err {
+					t.Errorf("classifyError() = %T, want the error unchanged", got)
+				}
+				return
+			}
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.want) {
+				t.Errorf("classifyError() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	p := testProvider(t, map[string]*fakeSearch{"us-ashburn-1": {}, "eu-frankfurt-1": {}})
+	plan, err := p.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.ResourceTypes) != len(p.config.Definitions) || plan.EstimatedAPICalls != 2 {
+		t.Errorf("plan = %d types, %d calls, want %d types and one search per region",
+			len(plan.ResourceTypes), plan.EstimatedAPICalls, len(p.config.Definitions))
+	}
+}
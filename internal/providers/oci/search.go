@@ -0,0 +1,112 @@
+package oci
+
+import (
+	"context"
+	"strings"
+
+	"github.com/oracle/oci-go-sdk/v65/common"
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+const (
+	// searchQuery is the structured query run in every region; resources
+	// of types without a definition are skipped while counting
+	searchQuery = "query all resources"
+
+	// searchPageSize is the number of resources asked for per page
+	searchPageSize = 1000
+)
+
+// goneStates are the lifecycle states of resources Search still returns for
+// a while after they were deleted
+var goneStates = map[string]bool{
+	"TERMINATED": true,
+	"DELETED":    true,
+}
+
+// searchAPI is the part of the Search client counting uses; a client is
+// bound to one region
+type searchAPI interface {
+	SearchResources(ctx context.Context, request resourcesearch.SearchResourcesRequest) (resourcesearch.SearchResourcesResponse, error)
+}
+
+// typeTally is what one region holds of a resource type
+type typeTally struct {
+	total         int
+	byCompartment map[string]int
+	byState       map[string]int
+}
+
+// regionTally is the tally of each counted resource type of one region,
+// keyed by the lower-cased Search resource type
+type regionTally map[string]*typeTally
+
+// add counts one resource of searchType
+func (t regionTally) add(searchType, compartment, state string) {
+	tally, ok := t[searchType]
+	if !ok {
+		tally = &typeTally{byCompartment: make(map[string]int), byState: make(map[string]int)}
+		t[searchType] = tally
+	}
+	tally.total++
+	tally.byCompartment[compartment]++
+	if state != "" {
+		tally.byState[state]++
+	}
+}
+
+// searchType is the lower-cased Search resource type of a definition, as in
+// instance for oci:Instance
+func searchType(def models.ResourceDefinition) string {
+	return strings.ToLower(strings.TrimPrefix(def.Type, "oci:"))
+}
+
+// searchRegion pages through every resource of a region and tallies those
+// whose type is in types, leaving out deleted resources and, with tag
+// filters, those missing a tag
+func searchRegion(ctx context.Context, client searchAPI, types map[string]bool, tagFilters []models.TagFilter) (regionTally, error) {
+	tally := make(regionTally)
+	retryPolicy := common.DefaultRetryPolicy()
+	request := resourcesearch.SearchResourcesRequest{
+		SearchDetails:   resourcesearch.StructuredSearchDetails{Query: common.String(searchQuery)},
+		Limit:           common.Int(searchPageSize),
+		RequestMetadata: common.RequestMetadata{RetryPolicy: &retryPolicy},
+	}
+	for {
+		response, err := client.SearchResources(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range response.Items {
+			resourceType := strings.ToLower(stringValue(resource.ResourceType))
+			state := strings.ToUpper(stringValue(resource.LifecycleState))
+			if !types[resourceType] || goneStates[state] || !hasTags(resource.FreeformTags, tagFilters) {
+				continue
+			}
+			tally.add(resourceType, stringValue(resource.CompartmentId), state)
+		}
+		if response.OpcNextPage == nil || *response.OpcNextPage == "" {
+			return tally, nil
+		}
+		request.Page = response.OpcNextPage
+	}
+}
+
+// hasTags reports whether the freeform tags carry every filter's value
+func hasTags(tags map[string]string, filters []models.TagFilter) bool {
+	for _, filter := range filters {
+		if value, ok := tags[filter.Key]; !ok || value != filter.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// stringValue returns the string s points to, or "" for nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,36 @@
+package oci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oracle/oci-go-sdk/v65/resourcesearch"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestSearchRegionFiltersTags(t *testing.T) {
+	tagged := resource("Instance", "prod", "RUNNING")
+	tagged.FreeformTags = map[string]string{"env": "prod"}
+	other := resource("Instance", "prod", "RUNNING")
+	other.FreeformTags = map[string]string{"env": "dev"}
+	client := &fakeSearch{pages: [][]resourcesearch.ResourceSummary{{tagged, other, resource("Instance", "prod", "RUNNING")}}}
+
+	tally, err := searchRegion(context.Background(), client, map[string]bool{"instance": true},
+		[]models.TagFilter{{Key: "env", Value: "prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tally["instance"].total; got != 1 {
+		t.Errorf("instances = %d, want the 1 tagged env=prod", got)
+	}
+	if query := client.requests[0].SearchDetails.(resourcesearch.StructuredSearchDetails).Query; *query != searchQuery {
+		t.Errorf("query = %q, want %q", *query, searchQuery)
+	}
+}
+
+func TestSearchType(t *testing.T) {
+	def := models.ResourceDefinition{Type: "oci:AutonomousDatabase"}
+	if got := searchType(def); got != "autonomousdatabase" {
+		t.Errorf("searchType() = %q, want autonomousdatabase", got)
+	}
+}
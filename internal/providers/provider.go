@@ -17,6 +17,14 @@ type Provider interface {
 	// CountResources counts all resources and returns complete results
 	CountResources(ctx context.Context) (*models.SizingResult, error)
 
+	// Plan describes what CountResources would do, without calling any
+	// count API. It needs a successful Connect.
+	Plan() (*models.ScanPlan, error)
+
+	// Accounts lists the discovered accounts or subscriptions. It needs a
+	// successful Connect.
+	Accounts() *models.AccountList
+
 	// Close closes any open connections
 	Close() error
 }
@@ -0,0 +1,86 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+// Factory builds a provider from its configuration
+type Factory func(config.ProviderConfig) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+)
+
+// Aliases maps the other names accepted for the built-in providers, matched
+// case-insensitively, to the names they are registered under
+var Aliases = map[string]string{
+	"amazon":     "aws",
+	"az":         "azure",
+	"microsoft":  "azure",
+	"kubernetes": "k8s",
+	"kube":       "k8s",
+	"oracle":     "oci",
+}
+
+// Register makes a provider available to GetProvider under name, matched
+// case-insensitively. Providers register from an init function or before
+// the first scan. It panics when name is empty or already registered, as
+// both are programming errors.
+func Register(name string, factory Factory) {
+	name = normalizeName(name)
+	if name == "" || factory == nil {
+		panic("providers: Register needs a name and a factory")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("providers: %q is already registered", name))
+	}
+	factories[name] = factory
+}
+
+// ListProviders returns the names of the registered providers, sorted
+func ListProviders() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup returns the factory registered under name
+func lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := factories[normalizeName(name)]
+	return factory, ok
+}
+
+// Normalize returns the registered name of a provider given by name or by
+// one of its Aliases; a registered provider named like an alias keeps its
+// name. Unknown names are returned lower-cased.
+func Normalize(name string) string {
+	name = normalizeName(name)
+	if _, ok := lookup(name); ok {
+		return name
+	}
+	if alias, ok := Aliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,137 @@
+package providers_test
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+)
+
+// memoryProvider serves a fixed set of counts from memory, registered from
+// outside the providers package as a third-party provider would be
+type memoryProvider struct {
+	cfg    config.ProviderConfig
+	counts map[models.ResourceType]int
+}
+
+func (p *memoryProvider) Name() string                  { return "Memory" }
+func (p *memoryProvider) Connect(context.Context) error { return nil }
+func (p *memoryProvider) Close() error                  { return nil }
+
+func (p *memoryProvider) CountResources(context.Context) (*models.SizingResult, error) {
+	result := &models.SizingResult{SchemaVersion: models.SchemaVersion, Provider: p.Name()}
+	for resourceType, total := range p.counts {
+		result.ResourceCounts = append(result.ResourceCounts, &models.ResourceCount{
+			Provider: "memory", Type: resourceType, DisplayName: string(resourceType), TotalResources: total,
+		})
+		result.TotalResources += total
+	}
+	return result, nil
+}
+
+func (p *memoryProvider) Plan() (*models.ScanPlan, error) {
+	return &models.ScanPlan{Provider: p.Name()}, nil
+}
+
+func (p *memoryProvider) Accounts() *models.AccountList {
+	return models.NewAccountList(p.Name(), models.AccountSourceCurrentAccount, nil)
+}
+
+func init() {
+	providers.Register("Memory", func(cfg config.ProviderConfig) (providers.Provider, error) {
+		return &memoryProvider{cfg: cfg, counts: map[models.ResourceType]int{"vm": 3, "bucket": 2}}, nil
+	})
+}
+
+func TestListProviders(t *testing.T) {
+	want := []string{"aws", "azure", "k8s", "memory", "oci"}
+	if got := providers.ListProviders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListProviders() = %v, want %v", got, want)
+	}
+}
+
+func TestGetProviderRegistered(t *testing.T) {
+	provider, err := providers.NewManager(false).GetProvider(config.ProviderConfig{Provider: " MEMORY "})
+	if err != nil {
+		t.Fatalf("GetProvider() error = %v", err)
+	}
+	memory, ok := provider.(*memoryProvider)
+	if !ok {
+		t.Fatalf("GetProvider() = %T, want the registered memory provider", provider)
+	}
+	if memory.cfg.Provider != "memory" || memory.cfg.Regions == nil {
+		t.Errorf("factory config = %+v, want the normalized name and defaults", memory.cfg)
+	}
+
+	result, err := provider.CountResources(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalResources != 5 {
+		t.Errorf("TotalResources = %d, want 5", result.TotalResources)
+	}
+}
+
+func TestGetProviderUnknown(t *testing.T) {
+	_, err := providers.NewManager(false).GetProvider(config.ProviderConfig{Provider: "gcp"})
+	if err == nil || !strings.Contains(err.Error(), "supported: aws, azure, k8s, memory, oci") {
+		t.Errorf("GetProvider(gcp) error = %v, want one listing the registered providers", err)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	factory := func(config.ProviderConfig) (providers.Provider, error) { return nil, nil }
+	tests := []struct {
+		name     string
+		provider string
+		factory  providers.Factory
+	}{
+		{name: "duplicate", provider: "aws", factory: factory},
+		{name: "duplicate in another case", provider: "Azure", factory: factory},
+		{name: "empty name", provider: " ", factory: factory},
+		{name: "nil factory", provider: "gcp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", tt.provider)
+				}
+			}()
+			providers.Register(tt.provider, tt.factory)
+		})
+	}
+	if slices.Contains(providers.ListProviders(), "gcp") {
+		t.Error("a rejected provider was registered")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for name, want := range map[string]string{
+		"aws":        "aws",
+		" Amazon ":   "aws",
+		"AZ":         "azure",
+		"microsoft":  "azure",
+		"Kubernetes": "k8s",
+		"oracle":     "oci",
+		"MEMORY":     "memory",
+		"GCP":        "gcp",
+	} {
+		if got := providers.Normalize(name); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGetProviderAlias(t *testing.T) {
+	if _, err := providers.NewManager(false).GetProvider(config.ProviderConfig{Provider: "amazon"}); err != nil &&
+		strings.Contains(err.Error(), "unsupported provider") {
+		t.Errorf("GetProvider(amazon) error = %v, want the alias resolved to aws", err)
+	}
+}
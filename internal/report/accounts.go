@@ -0,0 +1,122 @@
+package report
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// accountsCSVHeader names the columns of the account list CSV: one row per
+// account or subscription
+var accountsCSVHeader = []string{"provider", "source", "tenant_id", "id", "name", "email", "status"}
+
+// AccountFormats lists the output formats an account list can be rendered in
+var AccountFormats = []string{FormatTable, FormatJSON, FormatYAML, FormatCSV}
+
+// WriteAccounts renders an account list to out in format, one of
+// AccountFormats
+func WriteAccounts(out io.Writer, format string, list *models.AccountList) error {
+	switch format {
+	case FormatTable:
+		return writeAccountsTable(out, list)
+	case FormatJSON:
+		jsonData, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal accounts to JSON: %w", err)
+		}
+		if _, err := out.Write(append(jsonData, '\n')); err != nil {
+			return fmt.Errorf("failed to write JSON: %w", err)
+		}
+		return nil
+	case FormatYAML:
+		return writeYAML(out, list)
+	case FormatCSV:
+		return writeAccountsCSV(out, list)
+	}
+	return fmt.Errorf("unsupported account list format %q (supported: %s)", format, strings.Join(AccountFormats, ", "))
+}
+
+// ValidAccountFormat reports whether an account list can be rendered in
+// format
+func ValidAccountFormat(format string) bool {
+	for _, f := range AccountFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func writeAccountsTable(out io.Writer, list *models.AccountList) error {
+	w := bufio.NewWriter(out)
+
+	fmt.Fprintln(w, "\n=================================")
+	fmt.Fprintf(w, "Provider: %s\n", list.Provider)
+	fmt.Fprintf(w, "Source: %s\n", accountSourceLabel(list))
+	if list.TenantID != "" {
+		fmt.Fprintf(w, "Tenant: %s\n", list.TenantID)
+	}
+	fmt.Fprintf(w, "Accounts/Subscriptions: %d\n", len(list.Accounts))
+	fmt.Fprintln(w, "---------------------------------")
+	for _, account := range list.Accounts {
+		name := account.Name
+		if account.Email != "" {
+			name = fmt.Sprintf("%s <%s>", name, account.Email)
+		}
+		fmt.Fprintf(w, "  %-36s  %-12s  %s\n", account.ID, account.Status, name)
+	}
+	fmt.Fprintln(w, "=================================")
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write accounts: %w", err)
+	}
+	return nil
+}
+
+// accountSourceLabel describes where the accounts of list came from
+func accountSourceLabel(list *models.AccountList) string {
+	label := string(list.Source)
+	switch list.Source {
+	case models.AccountSourceOrganizations:
+		label = "AWS Organizations"
+	case models.AccountSourceCurrentAccount:
+		label = "current account only (Organizations listing unavailable)"
+	case models.AccountSourceSubscriptions:
+		label = "Azure subscriptions"
+	}
+	if list.Cached {
+		label += ", from the discovery cache"
+	}
+	return label
+}
+
+func writeAccountsCSV(out io.Writer, list *models.AccountList) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(accountsCSVHeader); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, account := range list.Accounts {
+		row := []string{
+			list.Provider,
+			string(list.Source),
+			list.TenantID,
+			account.ID,
+			account.Name,
+			account.Email,
+			account.Status,
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestWriteAccounts(t *testing.T) {
+	list := models.NewAccountList("AWS", models.AccountSourceCurrentAccount, []models.AccountCount{
+		{ID: "111111111111", Name: "prod-main", Email: "aws-prod@example.com", Status: "ACTIVE"},
+		{ID: "222222222222", Name: "legacy, old", Status: "SUSPENDED"},
+	})
+	list.Cached = true
+
+	for _, format := range AccountFormats {
+		t.Run(format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteAccounts(&out, format, list); err != nil {
+				t.Fatalf("WriteAccounts() error = %v", err)
+			}
+
+			golden := filepath.Join("testdata", "accounts."+format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if got := out.String(); got != string(want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+
+	if err := WriteAccounts(&bytes.Buffer{}, "xml", list); err == nil {
+		t.Error("WriteAccounts(xml) error = nil, want an error")
+	}
+}
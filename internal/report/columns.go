@@ -0,0 +1,137 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+)
+
+// maxLabelWidth is the widest label column of a table section; longer
+// display names and account labels are truncated
+const maxLabelWidth = 40
+
+// columnRow is a line of an aligned table section: a label, its count
+// right-aligned after the labels and a note after the count. A row with text
+// is written as is, indented, and does not take part in the alignment.
+type columnRow struct {
+	indent int
+	label  string
+	count  int
+	note   string
+	text   string
+}
+
+// writeColumns writes rows with the labels padded to a common width and the
+// counts grouped by thousands and right-aligned, followed by a total row
+// summing the unindented counts when total is set
+func (r *tableReporter) writeColumns(w io.Writer, rows []columnRow, total bool) {
+	if total {
+		sum := 0
+		for _, row := range rows {
+			if row.text == "" && row.indent == 0 {
+				sum += row.count
+			}
+		}
+		rows = append(rows, columnRow{label: "Total", count: sum})
+	}
+
+	labelWidth, countWidth := 0, 0
+	for _, row := range rows {
+		if row.text != "" {
+			continue
+		}
+		labelWidth = max(labelWidth, 2*row.indent+utf8.RuneCountInString(row.label))
+		countWidth = max(countWidth, utf8.RuneCountInString(r.number(row.count)))
+	}
+	labelWidth = min(labelWidth, maxLabelWidth)
+
+	for i, row := range rows {
+		indent := strings.Repeat("  ", row.indent)
+		if row.text != "" {
+			fmt.Fprintf(w, "    %s%s\n", indent, row.text)
+			continue
+		}
+		if total && i == len(rows)-1 {
+			fmt.Fprintf(w, "  %s\n", strings.Repeat("-", labelWidth+2+countWidth))
+		}
+		label := r.truncate(row.label, labelWidth-len(indent))
+		fmt.Fprintf(w, "  %s%s  %s%s\n", indent, padRight(label, labelWidth-len(indent)),
+			padLeft(r.number(row.count), countWidth), row.note)
+	}
+}
+
+// number renders n grouped by thousands the way the locale does, e.g.
+// "123,456"
+func (r *tableReporter) number(n int) string {
+	return r.numbers.Sprintf("%d", n)
+}
+
+// truncate shortens s to width characters, ending it with an ellipsis
+func (r *tableReporter) truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	ellipsis := "…"
+	if r.ascii {
+		ellipsis = "..."
+	}
+	runes := []rune(s)
+	return string(runes[:max(width-utf8.RuneCountInString(ellipsis), 0)]) + ellipsis
+}
+
+// padRight pads s with spaces to width characters
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-utf8.RuneCountInString(s), 0))
+}
+
+// padLeft right-aligns s in width characters
+func padLeft(s string, width int) string {
+	return strings.Repeat(" ", max(width-utf8.RuneCountInString(s), 0)) + s
+}
+
+// asciiReplacer replaces the emoji and other non-ASCII symbols of the
+// human-readable output for --ascii, including the group separators some
+// locales use
+var asciiReplacer = strings.NewReplacer(
+	"⚠️", "[!]",
+	"⚠", "[!]",
+	"✗", "[x]",
+	"✓", "[ok]",
+	"★", "*",
+	"…", "...",
+	"🚀 ", "",
+	"🕒 ", "",
+	"🛑 ", "",
+	"\u00a0", " ",
+	"\u202f", " ",
+	"’", "'",
+)
+
+// toASCII replaces the symbols of s that terminals and log systems without
+// Unicode support mangle
+func toASCII(s string) string {
+	return asciiReplacer.Replace(s)
+}
+
+// Locale returns the language whose digit grouping the table uses, from
+// LC_ALL, LC_NUMERIC or LANG such as "de_DE.UTF-8"; English when they are
+// unset, "C" or not understood
+func Locale() language.Tag {
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		value, _, _ = strings.Cut(value, ".")
+		value, _, _ = strings.Cut(value, "@")
+		if tag, err := language.Parse(strings.ReplaceAll(value, "_", "-")); err == nil && value != "C" && value != "POSIX" {
+			return tag
+		}
+		return language.English
+	}
+	return language.English
+}
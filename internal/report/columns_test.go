@@ -0,0 +1,61 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestLocale(t *testing.T) {
+	tests := []struct {
+		lcAll, lcNumeric, lang string
+		want                   language.Tag
+	}{
+		{"", "", "", language.English},
+		{"", "", "C.UTF-8", language.English},
+		{"", "", "de_DE.UTF-8", language.MustParse("de-DE")},
+		{"", "fr_FR@euro", "de_DE.UTF-8", language.MustParse("fr-FR")},
+		{"en_IN", "fr_FR", "de_DE", language.MustParse("en-IN")},
+		{"", "", "not a locale", language.English},
+	}
+	for _, tt := range tests {
+		t.Setenv("LC_ALL", tt.lcAll)
+		t.Setenv("LC_NUMERIC", tt.lcNumeric)
+		t.Setenv("LANG", tt.lang)
+		if got := Locale(); got != tt.want {
+			t.Errorf("Locale() with LC_ALL=%q LC_NUMERIC=%q LANG=%q = %v, want %v",
+				tt.lcAll, tt.lcNumeric, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		ascii bool
+		in    string
+		want  string
+	}{
+		{false, "EBS Volumes", "EBS Volumes"},
+		{false, "Elastic Container Registry", "Elastic Con…"},
+		{true, "Elastic Container Registry", "Elastic C..."},
+	} {
+		r := &tableReporter{ascii: tt.ascii}
+		if got := r.truncate(tt.in, 12); got != tt.want {
+			t.Errorf("truncate(%q, 12) with ascii %v = %q, want %q", tt.in, tt.ascii, got, tt.want)
+		}
+	}
+}
+
+func TestASCIIProgress(t *testing.T) {
+	var progress bytes.Buffer
+	p := NewProgress(&progress, Options{ASCII: true})
+	p.Start("aws")
+	p.Status("✓ Results saved to: %s", "ünïcode.json")
+	p.Warn("region %s skipped", "eu-west-1")
+
+	want := "\nSecrails Sizing Agent\nSelected cloud provider: AWS\n[ok] Results saved to: ünïcode.json\n[!]  Warning: region eu-west-1 skipped\n"
+	if got := progress.String(); got != want {
+		t.Errorf("progress = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// csvHeader names the columns of the CSV output: one row per resource type
+var csvHeader = []string{"provider", "resource_type", "display_name", "total_resources", "regions", "accounts"}
+
+// csvReporter renders the per-type counts as CSV
+type csvReporter struct {
+	Progress
+	out io.Writer
+}
+
+func (r *csvReporter) Write(result *models.SizingResult) error {
+	w := csv.NewWriter(r.out)
+	if err := w.Write(csvHeader); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, rc := range result.ResourceCounts {
+		row := []string{
+			result.Provider,
+			string(rc.Type),
+			rc.DisplayName,
+			strconv.Itoa(rc.TotalResources),
+			strconv.Itoa(len(rc.ByLocation)),
+			strconv.Itoa(len(rc.ByAccount)),
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// jsonReporter renders the full result as indented JSON
+type jsonReporter struct {
+	Progress
+	out    io.Writer
+	legacy bool
+	stats  bool
+}
+
+func (r *jsonReporter) Write(result *models.SizingResult) error {
+	if !r.stats {
+		result = result.WithoutStats()
+	}
+	var value any = result
+	if r.legacy {
+		r.Warn("--legacy-json is deprecated and will be removed in the next release; "+
+			"migrate to schema version %s", models.SchemaVersion)
+		value = toLegacy(result)
+	}
+
+	jsonData, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results to JSON: %w", err)
+	}
+	if _, err := r.out.Write(append(jsonData, '\n')); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+// legacyResult is the version 1 result layout, whose top-level fields had no
+// JSON tags. Remove it together with --legacy-json.
+type legacyResult struct {
+	Provider       string
+	Timestamp      time.Time
+	WeightsFile    string
+	ResourceCounts []legacyResourceCount
+	AccountCounts  []legacyAccountCount
+	TotalResources int
+	TotalAccounts  int
+	Estimate       *models.Estimate
+	Thresholds     *models.Thresholds
+	Warnings       []string
+	SkippedRegions []models.SkippedRegion
+}
+
+// legacyResourceCount is a version 1 resource count, which always wrote
+// by_location and by_account as objects, and none of the fields added since
+type legacyResourceCount struct {
+	Provider       string              `json:"provider"`
+	Type           models.ResourceType `json:"type"`
+	DisplayName    string              `json:"display_name"`
+	TotalResources int                 `json:"total_resources"`
+	ByLocation     map[string]int      `json:"by_location"`
+	ByAccount      map[string]int      `json:"by_account"`
+	ByState        map[string]int      `json:"by_state,omitempty"`
+	BySKU          map[string]int      `json:"by_sku,omitempty"`
+	ByLifecycle    map[string]int      `json:"by_lifecycle,omitempty"`
+	ByEngine       map[string]int      `json:"by_engine,omitempty"`
+}
+
+// legacyAccountCount is a version 1 account count, which always wrote
+// by_type as an object
+type legacyAccountCount struct {
+	ID            string                      `json:"id"`
+	Name          string                      `json:"name"`
+	Email         string                      `json:"email,omitempty"`
+	Status        string                      `json:"status"`
+	ResourceCount int                         `json:"resource_count"`
+	ByType        map[models.ResourceType]int `json:"by_type"`
+}
+
+func toLegacy(result *models.SizingResult) legacyResult {
+	legacy := legacyResult{
+		Provider:       result.Provider,
+		Timestamp:      result.Timestamp,
+		WeightsFile:    result.WeightsFile,
+		TotalResources: result.TotalResources,
+		TotalAccounts:  result.TotalAccounts,
+		Estimate:       result.Estimate,
+		Thresholds:     result.Thresholds,
+		Warnings:       result.Warnings,
+		SkippedRegions: result.SkippedRegions,
+	}
+	for _, rc := range result.ResourceCounts {
+		legacy.ResourceCounts = append(legacy.ResourceCounts, legacyResourceCount{
+			Provider:       rc.Provider,
+			Type:           rc.Type,
+			DisplayName:    rc.DisplayName,
+			TotalResources: rc.TotalResources,
+			ByLocation:     nonNil(rc.ByLocation),
+			ByAccount:      nonNil(rc.ByAccount),
+			ByState:        rc.ByState,
+			BySKU:          rc.BySKU,
+			ByLifecycle:    rc.ByLifecycle,
+			ByEngine:       rc.ByEngine,
+		})
+	}
+	for _, account := range result.AccountCounts {
+		legacy.AccountCounts = append(legacy.AccountCounts, legacyAccountCount{
+			ID:            account.ID,
+			Name:          account.Name,
+			Email:         account.Email,
+			Status:        account.Status,
+			ResourceCount: account.ResourceCount,
+			ByType:        nonNil(account.ByType),
+		})
+	}
+	return legacy
+}
+
+// nonNil returns m, or an empty map when m is nil, so that it is written as
+// {} rather than null
+func nonNil[K comparable](m map[K]int) map[K]int {
+	if m == nil {
+		return map[K]int{}
+	}
+	return m
+}
@@ -0,0 +1,151 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// markdownEscaper escapes the characters that would break a table cell or
+// start inline markup
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"<", "&lt;",
+	">", "&gt;",
+	"\r\n", " ",
+	"\n", " ",
+)
+
+// markdownReporter renders the result as a GitHub-flavored Markdown document
+// for tickets and wiki pages
+type markdownReporter struct {
+	Progress
+	out io.Writer
+}
+
+func (r *markdownReporter) Write(result *models.SizingResult) error {
+	w := bufio.NewWriter(r.out)
+
+	fmt.Fprintf(w, "# Sizing report: %s\n\n", escapeMarkdown(result.Provider))
+	fmt.Fprintln(w, "| | |")
+	fmt.Fprintln(w, "|---|---|")
+	fmt.Fprintf(w, "| Provider | %s |\n", escapeMarkdown(result.Provider))
+	fmt.Fprintf(w, "| Timestamp | %s |\n", result.Timestamp.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "| Total resources | %d |\n", result.TotalResources)
+	if len(result.BillableTypes) > 0 {
+		fmt.Fprintf(w, "| Billable workloads | %d |\n", result.BillableWorkloads)
+	}
+	fmt.Fprintf(w, "| Accounts/subscriptions | %d |\n", len(result.AccountCounts))
+	if result.OrganizationID != "" {
+		fmt.Fprintf(w, "| Organization | %s |\n", escapeMarkdown(result.OrganizationID))
+	}
+	if len(result.TagFilters) > 0 {
+		fmt.Fprintf(w, "| Tag filters | %s |\n", escapeMarkdown(formatTagFilters(result.TagFilters)))
+	}
+	if result.Estimate != nil {
+		fmt.Fprintf(w, "| Workload units | %.2f |\n", result.Estimate.WorkloadUnits)
+		fmt.Fprintf(w, "| Recommended tier | %s |\n", escapeMarkdown(strings.ToUpper(result.Estimate.Tier)))
+	}
+
+	if result.Incomplete() {
+		fmt.Fprintf(w, "\n> ⚠️ **Incomplete sizing:** resources were counted in %d of %d accounts/subscriptions discovered. %s\n",
+			result.AccountsScanned, result.AccountsDiscovered, incompleteHint(result.Provider))
+	}
+	if result.CredentialsExpired {
+		fmt.Fprintln(w, "\n> ⚠️ **Truncated sizing:** the credentials expired during the scan; the types not counted by then are missing. "+
+			"Sign in again and rerun the scan for complete counts.")
+	}
+
+	counted := countedTypes(result.ResourceCounts)
+	fmt.Fprintln(w, "\n## Resource types")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Resource type | Category | Count |")
+	fmt.Fprintln(w, "|---|---|---:|")
+	for _, rc := range counted {
+		name := escapeMarkdown(rc.DisplayName)
+		if rc.TagFilterNotApplied {
+			name += " (tag filter not applied)"
+		}
+		if rc.Truncated {
+			name += " (truncated at --max-pages)"
+		}
+		fmt.Fprintf(w, "| %s | %s | %d |\n", name, escapeMarkdown(rc.Category), rc.TotalResources)
+	}
+
+	if len(result.AccountCounts) > 0 {
+		fmt.Fprintln(w, "\n## Accounts/subscriptions")
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| ID | Name | Status | Resources |")
+		fmt.Fprintln(w, "|---|---|---|---:|")
+		for _, account := range result.AccountCounts {
+			fmt.Fprintf(w, "| %s | %s | %s | %d |\n", escapeMarkdown(account.ID), escapeMarkdown(account.Name),
+				escapeMarkdown(account.Status), account.ResourceCount)
+		}
+	}
+
+	var regional []*models.ResourceCount
+	for _, rc := range counted {
+		if len(rc.ByLocation) > 0 {
+			regional = append(regional, rc)
+		}
+	}
+	if len(regional) > 0 {
+		fmt.Fprintln(w, "\n## Regions")
+		for _, rc := range regional {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "<details>")
+			fmt.Fprintf(w, "<summary>%s by region</summary>\n\n", escapeMarkdown(rc.DisplayName))
+			fmt.Fprintln(w, "| Region | Count |")
+			fmt.Fprintln(w, "|---|---:|")
+			for _, region := range sortByCount(rc.ByLocation) {
+				fmt.Fprintf(w, "| %s | %d |\n", escapeMarkdown(region), rc.ByLocation[region])
+			}
+			fmt.Fprintln(w, "\n</details>")
+		}
+	}
+
+	if len(result.Warnings) > 0 {
+		fmt.Fprintln(w, "\n## Warnings")
+		fmt.Fprintln(w)
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(w, "- %s\n", escapeMarkdown(warning))
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write Markdown: %w", err)
+	}
+	return nil
+}
+
+// countedTypes returns the counts with resources, largest first and by
+// display name among equal counts
+func countedTypes(counts []*models.ResourceCount) []*models.ResourceCount {
+	var counted []*models.ResourceCount
+	for _, rc := range counts {
+		if rc.TotalResources > 0 {
+			counted = append(counted, rc)
+		}
+	}
+	sort.SliceStable(counted, func(i, j int) bool {
+		if counted[i].TotalResources != counted[j].TotalResources {
+			return counted[i].TotalResources > counted[j].TotalResources
+		}
+		return counted[i].DisplayName < counted[j].DisplayName
+	})
+	return counted
+}
+
+// escapeMarkdown makes text safe inside a Markdown table cell
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
@@ -0,0 +1,24 @@
+package report
+
+import "testing"
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "prod-main", want: "prod-main"},
+		{input: "legacy | eu", want: `legacy \| eu`},
+		{input: "a|b|c", want: `a\|b\|c`},
+		{input: `back\slash`, want: `back\\slash`},
+		{input: "team_data *prod*", want: `team\_data \*prod\*`},
+		{input: "<script>", want: "&lt;script&gt;"},
+		{input: "two\nlines", want: "two lines"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeMarkdown(tt.input); got != tt.want {
+			t.Errorf("escapeMarkdown(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
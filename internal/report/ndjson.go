@@ -0,0 +1,88 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// Record values of NDJSON lines
+const (
+	RecordResourceCount = "resource_count"
+	RecordSummary       = "summary"
+)
+
+// ndjsonLine is one line of NDJSON output: a resource count, or the summary
+// closing the stream
+type ndjsonLine struct {
+	Record        string                `json:"record"`
+	ResourceCount *models.ResourceCount `json:"resource_count,omitempty"`
+	Result        *models.SizingResult  `json:"result,omitempty"`
+}
+
+// CountStream writes each resource count as an NDJSON line the moment it is
+// counted, then the summary as the last line. It is safe for concurrent use.
+// Every line is written in one call and unbuffered, so a stream cut short by
+// an aborted scan still parses line by line; only the summary is missing.
+type CountStream struct {
+	mu    sync.Mutex
+	out   io.Writer
+	stats bool
+}
+
+// NewCountStream returns a stream writing lines to out; per-type stats are
+// kept with options.Stats only
+func NewCountStream(out io.Writer, options Options) *CountStream {
+	return &CountStream{out: out, stats: options.Stats}
+}
+
+// WriteCount writes a resource_count line
+func (s *CountStream) WriteCount(count *models.ResourceCount) error {
+	if !s.stats && count.Stats != nil {
+		stripped := *count
+		stripped.Stats = nil
+		count = &stripped
+	}
+	return s.writeLine(ndjsonLine{Record: RecordResourceCount, ResourceCount: count})
+}
+
+// WriteSummary writes the summary line, which holds the complete result
+func (s *CountStream) WriteSummary(result *models.SizingResult) error {
+	if !s.stats {
+		result = result.WithoutStats()
+	}
+	return s.writeLine(ndjsonLine{Record: RecordSummary, Result: result})
+}
+
+func (s *CountStream) writeLine(line ndjsonLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s line: %w", line.Record, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.out.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write NDJSON: %w", err)
+	}
+	return nil
+}
+
+// ndjsonReporter renders a finished result as a count stream: one line per
+// resource count and the summary
+type ndjsonReporter struct {
+	Progress
+	stream *CountStream
+}
+
+func (r *ndjsonReporter) Write(result *models.SizingResult) error {
+	for _, rc := range result.ResourceCounts {
+		if err := r.stream.WriteCount(rc); err != nil {
+			return err
+		}
+	}
+	return r.stream.WriteSummary(result)
+}
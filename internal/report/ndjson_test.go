@@ -0,0 +1,119 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestCountStreamConcurrent(t *testing.T) {
+	var out bytes.Buffer
+	stream := NewCountStream(&out, Options{})
+
+	const types = 50
+	var wg sync.WaitGroup
+	for i := 0; i < types; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			count := &models.ResourceCount{
+				Provider: "aws", Type: models.ResourceType(fmt.Sprintf("svc:type%d", i)), TotalResources: i,
+				Stats: &models.ScanStats{DurationMs: 10},
+			}
+			if err := stream.WriteCount(count); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := stream.WriteSummary(fixture()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every line is a complete record; the summary comes last
+	lines := readLines(t, out.String())
+	if len(lines) != types+1 {
+		t.Fatalf("got %d lines, want %d", len(lines), types+1)
+	}
+	seen := make(map[models.ResourceType]bool)
+	for _, line := range lines[:types] {
+		if line.Record != RecordResourceCount || line.ResourceCount == nil {
+			t.Fatalf("line %+v is not a resource count", line)
+		}
+		if line.ResourceCount.Stats != nil {
+			t.Errorf("%s carries stats without Options.Stats", line.ResourceCount.Type)
+		}
+		seen[line.ResourceCount.Type] = true
+	}
+	if len(seen) != types {
+		t.Errorf("got %d distinct types, want %d", len(seen), types)
+	}
+	summary := lines[types]
+	if summary.Record != RecordSummary || summary.Result == nil || summary.Result.TotalResources != 17 {
+		t.Errorf("last line = %+v, want the summary", summary)
+	}
+}
+
+func TestCountStreamTruncated(t *testing.T) {
+	var out bytes.Buffer
+	stream := NewCountStream(&out, Options{Stats: true})
+	for _, rc := range fixture().ResourceCounts {
+		if err := stream.WriteCount(rc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An aborted scan leaves count lines without a summary, each of which
+	// still parses on its own
+	lines := readLines(t, out.String())
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	for _, line := range lines {
+		if line.Record == RecordSummary {
+			t.Error("summary written before the scan finished")
+		}
+	}
+	if lines[0].ResourceCount.Stats == nil {
+		t.Error("stats dropped with Options.Stats")
+	}
+}
+
+func readLines(t *testing.T, output string) []ndjsonLine {
+	t.Helper()
+	var lines []ndjsonLine
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var line ndjsonLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestNDJSONSummaryMatchesSchema(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "result.ndjson.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := readLines(t, string(data))
+	summary, err := json.Marshal(lines[len(lines)-1].Result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations, err := models.ValidateResult(summary)
+	if err != nil || len(violations) > 0 {
+		t.Errorf("ValidateResult() = %v, %v, want no violations", violations, err)
+	}
+}
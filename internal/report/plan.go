@@ -0,0 +1,90 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// PlanFormats lists the output formats a scan plan can be rendered in
+var PlanFormats = []string{FormatTable, FormatJSON, FormatYAML}
+
+// WritePlan renders plan to out in format, one of PlanFormats. The table
+// shows Resource Graph queries only with options.Verbose.
+func WritePlan(out io.Writer, format string, plan *models.ScanPlan, options Options) error {
+	switch format {
+	case FormatTable:
+		return writePlanTable(out, plan, options)
+	case FormatJSON:
+		jsonData, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal plan to JSON: %w", err)
+		}
+		if _, err := out.Write(append(jsonData, '\n')); err != nil {
+			return fmt.Errorf("failed to write JSON: %w", err)
+		}
+		return nil
+	case FormatYAML:
+		return writeYAML(out, plan)
+	}
+	return fmt.Errorf("unsupported plan format %q (supported: %s)", format, strings.Join(PlanFormats, ", "))
+}
+
+// ValidPlanFormat reports whether a plan can be rendered in format
+func ValidPlanFormat(format string) bool {
+	for _, f := range PlanFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func writePlanTable(out io.Writer, plan *models.ScanPlan, options Options) error {
+	w := bufio.NewWriter(out)
+
+	fmt.Fprintln(w, "\n=================================")
+	fmt.Fprintf(w, "Scan plan for %s (dry run, nothing was counted)\n", plan.Provider)
+	fmt.Fprintln(w, "---------------------------------")
+	fmt.Fprintf(w, "Accounts/Subscriptions: %d\n", len(plan.Accounts))
+	for _, account := range plan.Accounts {
+		fmt.Fprintf(w, "  %s\n", accountLabel(account))
+	}
+	if len(plan.Regions) > 0 {
+		fmt.Fprintf(w, "Regions: %d\n", len(plan.Regions))
+		fmt.Fprintf(w, "  %s\n", strings.Join(plan.Regions, ", "))
+	}
+
+	fmt.Fprintln(w, "---------------------------------")
+	fmt.Fprintf(w, "Resource types: %d\n", len(plan.ResourceTypes))
+	for _, planned := range plan.ResourceTypes {
+		fmt.Fprintf(w, "  %-30s: %s (%d calls)\n", planned.DisplayName, planned.API, planned.EstimatedCalls)
+		if planned.Query != "" && options.Verbose {
+			fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(planned.Query, "\n", "\n    "))
+		}
+	}
+
+	if len(plan.Warnings) > 0 {
+		fmt.Fprintln(w, "---------------------------------")
+		fmt.Fprintln(w, "Warnings:")
+		for _, warning := range plan.Warnings {
+			glyph := "⚠️ "
+			if options.ASCII {
+				glyph = toASCII(glyph)
+			}
+			fmt.Fprintf(w, "  %s %s\n", glyph, warning)
+		}
+	}
+
+	fmt.Fprintln(w, "=================================")
+	fmt.Fprintf(w, "Estimated API calls: at least %d\n", plan.EstimatedAPICalls)
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
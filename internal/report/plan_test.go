@@ -0,0 +1,80 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// planFixture returns a fixed plan with a tagging, a service and a Resource
+// Graph type
+func planFixture() *models.ScanPlan {
+	plan := &models.ScanPlan{
+		Provider: "AWS",
+		Accounts: []models.AccountCount{{ID: "111111111111", Name: "prod-main", Status: "ACTIVE"}},
+		Regions:  []string{"us-east-1", "eu-west-1"},
+		Warnings: []string{"regions that deny access or are not enabled are found by the probe and skipped when counting"},
+	}
+	plan.EstimatedAPICalls = 2
+	plan.AddType(models.PlannedType{
+		Type: "s3:bucket", DisplayName: "S3 Buckets", CountMethod: models.CountMethodTaggingAPI,
+		API: "tag:GetResources", EstimatedCalls: 2,
+	})
+	plan.AddType(models.PlannedType{
+		Type: "eks:node", DisplayName: "EKS Nodes", CountMethod: models.CountMethodServiceAPI,
+		API: "eks:ListClusters, ec2:DescribeInstances", EstimatedCalls: 4,
+	})
+	plan.AddType(models.PlannedType{
+		Type: "microsoft.compute/virtualmachines", DisplayName: "Virtual Machines",
+		CountMethod: models.CountMethodResourceGraph, API: "Resource Graph table Resources",
+		Query:          "Resources\n| where type =~ \"microsoft.compute/virtualmachines\"",
+		EstimatedCalls: 1,
+	})
+	return plan
+}
+
+func TestWritePlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		options Options
+	}{
+		{name: "table", format: FormatTable},
+		{name: "table-verbose", format: FormatTable, options: Options{Verbose: true}},
+		{name: "json", format: FormatJSON},
+		{name: "yaml", format: FormatYAML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WritePlan(&out, tt.format, planFixture(), tt.options); err != nil {
+				t.Fatalf("WritePlan() error = %v", err)
+			}
+
+			golden := filepath.Join("testdata", "plan."+tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if got := out.String(); got != string(want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+
+	if err := WritePlan(&bytes.Buffer{}, FormatCSV, planFixture(), Options{}); err == nil {
+		t.Error("WritePlan(csv) error = nil, want an error")
+	}
+	if ValidPlanFormat(FormatCSV) || !ValidPlanFormat(FormatYAML) {
+		t.Error("ValidPlanFormat() misreports supported formats")
+	}
+}
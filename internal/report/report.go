@@ -0,0 +1,141 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Supported output formats
+const (
+	FormatTable    = "table"
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatYAML     = "yaml"
+	FormatNDJSON   = "ndjson"
+	FormatMarkdown = "markdown"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatTable, FormatJSON, FormatCSV, FormatYAML, FormatNDJSON, FormatMarkdown}
+
+// Progress receives the human-readable chrome around a scan: banners,
+// status lines and warnings. It never writes to the result output.
+type Progress interface {
+	// Start announces a scan of provider
+	Start(provider string)
+	// Status reports a completed step, such as a file written
+	Status(format string, args ...any)
+	// Warn reports a problem that does not fail the scan
+	Warn(format string, args ...any)
+}
+
+// Reporter renders a sizing result to its output and passes progress on
+type Reporter interface {
+	Progress
+	// Write renders result
+	Write(result *models.SizingResult) error
+}
+
+// Options tunes the rendering
+type Options struct {
+	// Verbose adds the top regions of each type to the table
+	Verbose bool
+
+	// LegacyJSON makes the JSON reporter write the version 1 layout
+	LegacyJSON bool
+
+	// Stats keeps the per-type scan statistics in JSON and YAML output
+	Stats bool
+
+	// ASCII replaces the emoji and other symbols of the table and progress
+	// output, for terminals and log systems that mangle them
+	ASCII bool
+
+	// Language sets how the table groups the digits of counts, see Locale;
+	// the zero value groups them by commas
+	Language language.Tag
+}
+
+// New returns the reporter for format, rendering results to out and progress
+// to progress
+func New(format string, out io.Writer, progress Progress, options Options) (Reporter, error) {
+	switch format {
+	case FormatTable:
+		return &tableReporter{Progress: progress, out: out, verbose: options.Verbose,
+			ascii: options.ASCII, numbers: message.NewPrinter(options.Language)}, nil
+	case FormatJSON:
+		return &jsonReporter{Progress: progress, out: out, legacy: options.LegacyJSON, stats: options.Stats}, nil
+	case FormatCSV:
+		return &csvReporter{Progress: progress, out: out}, nil
+	case FormatYAML:
+		return &yamlReporter{Progress: progress, out: out, stats: options.Stats}, nil
+	case FormatNDJSON:
+		return &ndjsonReporter{Progress: progress, stream: NewCountStream(out, options)}, nil
+	case FormatMarkdown:
+		return &markdownReporter{Progress: progress, out: out}, nil
+	}
+	return nil, fmt.Errorf("unsupported output format %q (supported: %s)", format, strings.Join(Formats, ", "))
+}
+
+// ValidFormat reports whether format is supported
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// textProgress writes progress as plain lines
+type textProgress struct {
+	w     io.Writer
+	ascii bool
+}
+
+// NewProgress returns a Progress writing to w, normally stderr so that it
+// never mixes with machine-readable output. Of options only ASCII applies.
+func NewProgress(w io.Writer, options Options) Progress {
+	return &textProgress{w: w, ascii: options.ASCII}
+}
+
+func (p *textProgress) Start(provider string) {
+	p.printf("\n🚀 Secrails Sizing Agent\n")
+	p.printf("Selected cloud provider: %s\n", strings.ToUpper(provider))
+}
+
+func (p *textProgress) Status(format string, args ...any) {
+	p.printf(format+"\n", args...)
+}
+
+func (p *textProgress) Warn(format string, args ...any) {
+	p.printf("⚠️  Warning: "+format+"\n", args...)
+}
+
+// printf writes a line, replacing the symbols of its format with ASCII
+// when asked to
+func (p *textProgress) printf(format string, args ...any) {
+	if p.ascii {
+		format = toASCII(format)
+	}
+	fmt.Fprintf(p.w, format, args...)
+}
+
+// quietProgress drops everything but warnings
+type quietProgress struct {
+	Progress
+}
+
+// Quiet wraps progress so that only warnings get through, for --quiet
+func Quiet(progress Progress) Progress {
+	return quietProgress{Progress: progress}
+}
+
+func (quietProgress) Start(string) {}
+
+func (quietProgress) Status(string, ...any) {}
@@ -0,0 +1,342 @@
+package report
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"golang.org/x/text/language"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fixture returns a fixed result covering accounts, states, regions,
+// warnings and an estimate
+func fixture() *models.SizingResult {
+	return &models.SizingResult{
+		SchemaVersion: models.SchemaVersion,
+		Provider:      "AWS",
+		Timestamp:     time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+		WeightsFile:   "weights.yaml",
+
+		OrganizationID:      "o-abc123def4",
+		IsManagementAccount: true,
+		AccountsDiscovered:  2,
+		AccountsScanned:     1,
+
+		ResourceCounts: []*models.ResourceCount{
+			{
+				Provider:       "aws",
+				Type:           "ec2:instance",
+				DisplayName:    "EC2 Instances",
+				Category:       "Compute",
+				TotalResources: 12,
+				ByLocation:     map[string]int{"us-east-1": 7, "eu-west-1": 3, "eu-central-1": 1, "ap-south-1": 1},
+				ByAccount:      map[string]int{"111111111111": 10, "222222222222": 2},
+				ByState:        map[string]int{"running": 9, "stopped": 3},
+				Stats: &models.ScanStats{
+					Start: time.Date(2024, 3, 1, 10, 29, 40, 0, time.UTC), DurationMs: 3240, Pages: 17, Retries: 2,
+				},
+			},
+			{
+				Provider:       "aws",
+				Type:           "s3:bucket",
+				DisplayName:    "S3 Buckets",
+				Category:       "Storage",
+				TotalResources: 5,
+				ByLocation:     map[string]int{"us-east-1": 5},
+				ByAccount:      map[string]int{"111111111111": 5},
+				Stats: &models.ScanStats{
+					Start: time.Date(2024, 3, 1, 10, 29, 40, 0, time.UTC), DurationMs: 410, Pages: 2,
+				},
+			},
+			{
+				Provider:    "aws",
+				Type:        "rds:db",
+				DisplayName: "RDS Instances",
+				Category:    "Databases",
+			},
+		},
+		AccountCounts: []models.AccountCount{
+			{ID: "111111111111", Name: "prod-main", Status: "ACTIVE", ResourceCount: 15,
+				Tags: map[string]string{"environment": "prod"}, Offer: "EnterpriseAgreement_2014-09-01",
+				ByType: map[models.ResourceType]int{"ec2:instance": 10, "s3:bucket": 5}},
+			{ID: "222222222222", Name: "legacy | eu", Status: "SUSPENDED", ResourceCount: 2,
+				ByType: map[models.ResourceType]int{"ec2:instance": 2}},
+		},
+		TotalResources:    17,
+		TotalAccounts:     2,
+		BillableWorkloads: 12,
+		BillableTypes:     []models.ResourceType{"ec2:instance", "rds:db"},
+		AccountGroupTag:   "environment",
+		AccountGroups: []models.AccountGroup{
+			{Value: "prod", Accounts: 1, TotalResources: 15},
+			{Value: models.UntaggedGroup, Accounts: 1, TotalResources: 2},
+		},
+		ManagementGroups: []models.ManagementGroupTotal{
+			{ID: "tenant-root", Name: "Tenant Root Group", Path: []string{"Tenant Root Group"}, Subscriptions: 1, TotalResources: 15},
+			{ID: "mg-prod", Name: "Production", Path: []string{"Tenant Root Group", "Production"}, Subscriptions: 1, TotalResources: 15},
+			{ID: models.UnassignedManagementGroup, Name: models.UnassignedManagementGroup,
+				Path: []string{models.UnassignedManagementGroup}, Subscriptions: 1, TotalResources: 2},
+		},
+		Estimate: &models.Estimate{
+			WorkloadUnits: 14.5,
+			Tier:          "small",
+			ByType:        map[models.ResourceType]float64{"ec2:instance": 12, "s3:bucket": 2.5},
+		},
+		Warnings: []string{
+			"rds:db: access denied in eu-west-1",
+			models.SanityWarningPrefix + "RDS Instances could not be counted in 3 of 4 regions (ap-south-1, eu-west-1, us-east-1); its count is likely too low",
+		},
+		LogWarnings: []models.LogWarning{
+			{Level: "warn", Message: "Reached max pages", Fields: map[string]string{"region": "us-east-1", "type": "s3:bucket"}},
+			{Level: "error", Message: "Failed to count resource type", Fields: map[string]string{"type": "rds:db"}},
+		},
+		LogWarningsDropped: 1,
+		EmptyRegions:       []string{"ap-south-2", "me-central-1"},
+	}
+}
+
+// wideFixture returns fixture with six-digit counts and a display name too
+// long for the table's label column
+func wideFixture() *models.SizingResult {
+	result := fixture()
+	result.ResourceCounts[0].TotalResources = 412345
+	result.ResourceCounts[0].ByState = map[string]int{"running": 400000, "stopped": 12345}
+	result.ResourceCounts[1].DisplayName = "S3 Buckets including Directory Buckets and Table Buckets"
+	result.ResourceCounts[1].TotalResources = 1250
+	result.AccountCounts[0].ResourceCount = 413593
+	result.TotalResources = 413595
+	return result
+}
+
+func TestReporters(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		options Options
+		// result is the result written, fixture when nil
+		result func() *models.SizingResult
+		// wantProgress is a line Write must report, if any
+		wantProgress string
+	}{
+		{name: "table", format: FormatTable},
+		{name: "table-verbose", format: FormatTable, options: Options{Verbose: true}},
+		{name: "table-wide", format: FormatTable, result: wideFixture},
+		{name: "table-wide-de", format: FormatTable, options: Options{Language: language.German}, result: wideFixture},
+		{name: "table-ascii", format: FormatTable, options: Options{ASCII: true}, result: wideFixture},
+		{name: "json", format: FormatJSON},
+		{name: "json-legacy", format: FormatJSON, options: Options{LegacyJSON: true}, wantProgress: "--legacy-json is deprecated"},
+		{name: "csv", format: FormatCSV},
+		{name: "yaml", format: FormatYAML},
+		{name: "json-stats", format: FormatJSON, options: Options{Stats: true}},
+		{name: "ndjson", format: FormatNDJSON},
+		{name: "markdown", format: FormatMarkdown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, progress bytes.Buffer
+			reporter, err := New(tt.format, &out, NewProgress(&progress, Options{}), tt.options)
+			if err != nil {
+				t.Fatalf("New(%q) error = %v", tt.format, err)
+			}
+			result := fixture()
+			if tt.result != nil {
+				result = tt.result()
+			}
+			if err := reporter.Write(result); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if tt.wantProgress == "" && progress.Len() != 0 {
+				t.Errorf("Write() wrote progress output %q", progress.String())
+			}
+			if !strings.Contains(progress.String(), tt.wantProgress) {
+				t.Errorf("progress output %q does not contain %q", progress.String(), tt.wantProgress)
+			}
+
+			golden := filepath.Join("testdata", "result."+tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if got := out.String(); got != string(want) {
+				t.Errorf("output differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
+
+func TestJSONMatchesSchema(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "result.json.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	violations, err := models.ValidateResult(data)
+	if err != nil || len(violations) > 0 {
+		t.Errorf("ValidateResult() = %v, %v, want no violations", violations, err)
+	}
+}
+
+func TestNewUnknownFormat(t *testing.T) {
+	if _, err := New("xml", &bytes.Buffer{}, NewProgress(&bytes.Buffer{}, Options{}), Options{}); err == nil {
+		t.Error("New(\"xml\") error = nil, want an error")
+	}
+	if ValidFormat("xml") || !ValidFormat(FormatCSV) {
+		t.Error("ValidFormat() misreports supported formats")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var out, progress bytes.Buffer
+	reporter, err := New(FormatJSON, &out, NewProgress(&progress, Options{}), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reporter.Start("aws")
+	reporter.Status("✓ Results saved to: %s", "out.json")
+	reporter.Warn("region %s skipped", "eu-west-1")
+
+	if out.Len() != 0 {
+		t.Errorf("progress leaked into the result output: %q", out.String())
+	}
+	for _, want := range []string{"Selected cloud provider: AWS", "✓ Results saved to: out.json\n", "⚠️  Warning: region eu-west-1 skipped\n"} {
+		if !strings.Contains(progress.String(), want) {
+			t.Errorf("progress output %q does not contain %q", progress.String(), want)
+		}
+	}
+}
+
+func TestTableBreaksDownKinds(t *testing.T) {
+	result := &models.SizingResult{
+		Provider: "AWS",
+		ResourceCounts: []*models.ResourceCount{{
+			Type:           "elasticloadbalancing:loadbalancer",
+			DisplayName:    "Load Balancers",
+			TotalResources: 4,
+			ByKind:         map[string]int{"application": 2, "network": 1, "classic": 1},
+			ByExposure:     map[string]int{"public": 3, "internal": 1},
+		}},
+	}
+
+	var out bytes.Buffer
+	reporter, err := New(FormatTable, &out, NewProgress(&bytes.Buffer{}, Options{}), Options{Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.Write(result); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"  Load Balancers  4",
+		"    application   2",
+		"    classic       1",
+		"    network       1",
+		"    Exposure: 3 public, 1 internal",
+		"  -----------------",
+		"  Total           4",
+	}, "\n")
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("table output does not contain\n%s\ngot\n%s", want, out.String())
+	}
+}
+
+func TestTableShowsExcludedNoise(t *testing.T) {
+	result := &models.SizingResult{
+		Provider:          "Azure",
+		TotalResources:    7,
+		RawTotalResources: 19,
+		ResourceCounts: []*models.ResourceCount{{
+			Type:           "microsoft.network/networkinterfaces",
+			DisplayName:    "Network Interfaces",
+			TotalResources: 7,
+			NoiseExcluded:  12,
+		}},
+	}
+
+	var out bytes.Buffer
+	reporter, err := New(FormatTable, &out, NewProgress(&bytes.Buffer{}, Options{}), Options{Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.Write(result); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Total Resources: 7 (19 before excluding 12 cloud-managed resources)",
+		"    Cloud-managed excluded: 12",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("table output does not contain %q, got\n%s", want, out.String())
+		}
+	}
+}
+
+func TestTableShowsTopRuntimes(t *testing.T) {
+	result := &models.SizingResult{
+		Provider: "AWS",
+		ResourceCounts: []*models.ResourceCount{{
+			Type:           "lambda:function",
+			DisplayName:    "Lambda Functions",
+			TotalResources: 18,
+			ByKind:         map[string]int{"zip": 15, "container": 3},
+			ByRuntime: map[string]int{
+				"python3.12": 6, "nodejs20.x": 4, "java21": 2, "go1.x": 1, "ruby3.3": 1, "dotnet8": 1,
+			},
+		}},
+	}
+
+	var out bytes.Buffer
+	reporter, err := New(FormatTable, &out, NewProgress(&bytes.Buffer{}, Options{}), Options{Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.Write(result); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "    Runtimes: python3.12(6), nodejs20.x(4), java21(2), dotnet8(1), go1.x(1)\n"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("table output does not contain %q, got\n%s", want, out.String())
+	}
+}
+
+func TestTableShowsRegistryContents(t *testing.T) {
+	result := &models.SizingResult{
+		Provider: "AWS",
+		ResourceCounts: []*models.ResourceCount{{
+			Type:           "ecr:repository",
+			DisplayName:    "ECR Repositories",
+			TotalResources: 3,
+			Images:         40000,
+			ContentUnknown: 1,
+		}},
+	}
+
+	var out bytes.Buffer
+	reporter, err := New(FormatTable, &out, NewProgress(&bytes.Buffer{}, Options{}), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reporter.Write(result); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "  ECR Repositories  3\n    Contents: 40,000 images, 1 unreadable\n"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("table output does not contain %q, got\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,193 @@
+package report
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// SplitByAccount returns one result per account or subscription of result,
+// holding only the resources attributed to it through the ByAccount counts.
+// Accounts without resources get a result with zero counts. Location and
+// state breakdowns are kept only where all of a type belongs to the account,
+// and scan-wide data such as the estimate, warnings and API calls is left
+// for the caller to recompute.
+func SplitByAccount(result *models.SizingResult) []*models.SizingResult {
+	unattributed := unattributedTypes(result.ResourceCounts)
+
+	parts := make([]*models.SizingResult, 0, len(result.AccountCounts))
+	for _, account := range result.AccountCounts {
+		part := &models.SizingResult{
+			SchemaVersion:  result.SchemaVersion,
+			Provider:       result.Provider,
+			Timestamp:      result.Timestamp,
+			WeightsFile:    result.WeightsFile,
+			AgentVersion:   result.AgentVersion,
+			LatestVersion:  result.LatestVersion,
+			Offline:        result.Offline,
+			AuthMethod:     result.AuthMethod,
+			AssumedRoleARN: result.AssumedRoleARN,
+			TagFilters:     result.TagFilters,
+			SkippedRegions: result.SkippedRegions,
+			EmptyRegions:   result.EmptyRegions,
+			BillableTypes:  result.BillableTypes,
+		}
+
+		account.ResourceCount = 0
+		account.ByType = make(map[models.ResourceType]int)
+		for _, rc := range result.ResourceCounts {
+			count := partCount(rc, account.ID)
+			part.ResourceCounts = append(part.ResourceCounts, count)
+			if count.TotalResources > 0 {
+				account.ResourceCount += count.TotalResources
+				account.ByType[count.Type] = count.TotalResources
+			}
+		}
+		part.AccountCounts = []models.AccountCount{account}
+		part.TotalResources = account.ResourceCount
+		part.TotalAccounts = 1
+		part.BillableWorkloads = models.CountBillable(part.ResourceCounts, part.BillableTypes)
+
+		// Without attributed resources an account of an incomplete scan was
+		// most likely not counted at all
+		part.AccountsDiscovered = 1
+		if !result.Incomplete() || account.ResourceCount > 0 {
+			part.AccountsScanned = 1
+		}
+
+		if len(unattributed) > 0 {
+			part.Warnings = append(part.Warnings, fmt.Sprintf(
+				"resources of these types could not be attributed to accounts and are not included: %s",
+				strings.Join(unattributed, ", ")))
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// SplitByTenant returns one result per Azure tenant of a multi-tenant
+// result, holding the tenant's subscriptions and the resources attributed
+// to them, with the same limits as SplitByAccount. Tenants are in the order
+// of their first subscription.
+func SplitByTenant(result *models.SizingResult) []*models.SizingResult {
+	unattributed := unattributedTypes(result.ResourceCounts)
+
+	var tenants []string
+	accounts := make(map[string][]models.AccountCount)
+	for _, account := range result.AccountCounts {
+		if _, ok := accounts[account.Tenant]; !ok {
+			tenants = append(tenants, account.Tenant)
+		}
+		accounts[account.Tenant] = append(accounts[account.Tenant], account)
+	}
+
+	parts := make([]*models.SizingResult, 0, len(tenants))
+	for _, tenant := range tenants {
+		part := &models.SizingResult{
+			SchemaVersion:   result.SchemaVersion,
+			Provider:        result.Provider,
+			Timestamp:       result.Timestamp,
+			WeightsFile:     result.WeightsFile,
+			AgentVersion:    result.AgentVersion,
+			LatestVersion:   result.LatestVersion,
+			Offline:         result.Offline,
+			AuthMethod:      result.AuthMethod,
+			TagFilters:      result.TagFilters,
+			BillableTypes:   result.BillableTypes,
+			AccountCounts:   accounts[tenant],
+			TotalAccounts:   len(accounts[tenant]),
+			AccountsScanned: len(accounts[tenant]),
+		}
+		part.AccountsDiscovered = part.AccountsScanned
+
+		ids := make([]string, len(part.AccountCounts))
+		for i, account := range part.AccountCounts {
+			ids[i] = account.ID
+		}
+		for _, rc := range result.ResourceCounts {
+			part.ResourceCounts = append(part.ResourceCounts, partCount(rc, ids...))
+		}
+		part.TotalResources = models.Total(part.ResourceCounts)
+		part.BillableWorkloads = models.CountBillable(part.ResourceCounts, part.BillableTypes)
+
+		if len(unattributed) > 0 {
+			part.Warnings = append(part.Warnings, fmt.Sprintf(
+				"resources of these types could not be attributed to subscriptions and are not included: %s",
+				strings.Join(unattributed, ", ")))
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// SplitPath returns the file an account's or tenant's result is written to
+// beside output, as <output-base>-<id>.<ext>; the slash of tenant-qualified
+// subscription IDs becomes a dash
+func SplitPath(output, id string) string {
+	ext := filepath.Ext(output)
+	return strings.TrimSuffix(output, ext) + "-" + strings.ReplaceAll(id, "/", "-") + ext
+}
+
+// partCount returns the part of rc attributed to the accounts ids
+func partCount(rc *models.ResourceCount, ids ...string) *models.ResourceCount {
+	total := 0
+	byAccount := make(map[string]int)
+	for account, count := range rc.ByAccount {
+		for _, id := range ids {
+			if strings.EqualFold(account, id) {
+				total += count
+				byAccount[id] += count
+			}
+		}
+	}
+
+	count := &models.ResourceCount{
+		Provider:            rc.Provider,
+		Type:                rc.Type,
+		DisplayName:         rc.DisplayName,
+		CanonicalType:       rc.CanonicalType,
+		Category:            rc.Category,
+		SubsetOf:            rc.SubsetOf,
+		TotalResources:      total,
+		Truncated:           rc.Truncated,
+		TagFilterNotApplied: rc.TagFilterNotApplied,
+	}
+	if total == 0 {
+		return count
+	}
+	count.ByAccount = byAccount
+	if total == rc.TotalResources {
+		count.ByLocation = rc.ByLocation
+		count.ByState = rc.ByState
+		count.BySKU = rc.BySKU
+		count.ByLifecycle = rc.ByLifecycle
+		count.ByEngine = rc.ByEngine
+		count.ByKind = rc.ByKind
+		count.ByExposure = rc.ByExposure
+		count.ByRuntime = rc.ByRuntime
+		count.Images = rc.Images
+		count.Repositories = rc.Repositories
+		count.ContentUnknown = rc.ContentUnknown
+	}
+	return count
+}
+
+// unattributedTypes lists the types with resources missing from their
+// ByAccount counts, sorted
+func unattributedTypes(counts []*models.ResourceCount) []string {
+	var types []string
+	for _, rc := range counts {
+		attributed := 0
+		for _, count := range rc.ByAccount {
+			attributed += count
+		}
+		if attributed < rc.TotalResources {
+			types = append(types, string(rc.Type))
+		}
+	}
+	sort.Strings(types)
+	return types
+}
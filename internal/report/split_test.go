@@ -0,0 +1,168 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+func TestSplitByAccount(t *testing.T) {
+	result := fixture()
+	result.AccountCounts = append(result.AccountCounts, models.AccountCount{ID: "333333333333", Name: "empty", Status: "ACTIVE"})
+	result.AccountsDiscovered, result.AccountsScanned = 3, 3
+
+	parts := SplitByAccount(result)
+	if len(parts) != 3 {
+		t.Fatalf("SplitByAccount() returned %d results, want 3", len(parts))
+	}
+
+	tests := []struct {
+		id       string
+		total    int
+		byType   map[models.ResourceType]int
+		billable int
+	}{
+		{id: "111111111111", total: 15, byType: map[models.ResourceType]int{"ec2:instance": 10, "s3:bucket": 5}, billable: 10},
+		{id: "222222222222", total: 2, byType: map[models.ResourceType]int{"ec2:instance": 2}, billable: 2},
+		{id: "333333333333", total: 0, byType: map[models.ResourceType]int{}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			part := parts[i]
+			if len(part.AccountCounts) != 1 || part.AccountCounts[0].ID != tt.id {
+				t.Fatalf("AccountCounts = %+v, want only %s", part.AccountCounts, tt.id)
+			}
+			account := part.AccountCounts[0]
+			if part.TotalResources != tt.total || account.ResourceCount != tt.total {
+				t.Errorf("TotalResources = %d, ResourceCount = %d, want %d", part.TotalResources, account.ResourceCount, tt.total)
+			}
+			if !reflect.DeepEqual(account.ByType, tt.byType) {
+				t.Errorf("ByType = %v, want %v", account.ByType, tt.byType)
+			}
+			if part.BillableWorkloads != tt.billable {
+				t.Errorf("BillableWorkloads = %d, want %d", part.BillableWorkloads, tt.billable)
+			}
+			if part.TotalAccounts != 1 || part.Incomplete() {
+				t.Errorf("TotalAccounts = %d, Incomplete() = %v, want 1 and false", part.TotalAccounts, part.Incomplete())
+			}
+
+			// Every counted type is listed, with zero counts where the
+			// account has none
+			if len(part.ResourceCounts) != len(result.ResourceCounts) {
+				t.Errorf("ResourceCounts = %d, want %d", len(part.ResourceCounts), len(result.ResourceCounts))
+			}
+			for _, rc := range part.ResourceCounts {
+				if rc.TotalResources == 0 && len(rc.ByAccount) != 0 {
+					t.Errorf("%s ByAccount = %v, want empty", rc.Type, rc.ByAccount)
+				}
+				if rc.Stats != nil {
+					t.Errorf("%s carries scan stats", rc.Type)
+				}
+			}
+			if part.Estimate != nil || len(part.APICalls) != 0 || len(part.AccountGroups) != 0 {
+				t.Error("scan-wide data copied into the account's result")
+			}
+		})
+	}
+
+	// Breakdowns survive only where the whole type belongs to the account
+	ec2, s3 := parts[0].ResourceCounts[0], parts[0].ResourceCounts[1]
+	if ec2.ByLocation != nil || ec2.ByState != nil {
+		t.Errorf("ec2:instance keeps the breakdowns of all accounts: %+v", ec2)
+	}
+	if !reflect.DeepEqual(s3.ByLocation, map[string]int{"us-east-1": 5}) {
+		t.Errorf("s3:bucket ByLocation = %v, want the full breakdown", s3.ByLocation)
+	}
+}
+
+func TestSplitByAccountIncomplete(t *testing.T) {
+	result := fixture()
+	result.AccountCounts = append(result.AccountCounts, models.AccountCount{ID: "333333333333", Name: "member", Status: "ACTIVE"})
+	result.ResourceCounts = append(result.ResourceCounts, &models.ResourceCount{
+		Provider: "aws", Type: "iam:role", DisplayName: "IAM Roles", TotalResources: 4,
+	})
+
+	parts := SplitByAccount(result)
+	if len(parts) != 3 {
+		t.Fatalf("SplitByAccount() returned %d results, want 3", len(parts))
+	}
+
+	// The scan counted fewer accounts than it found, so an account without
+	// resources is reported as not counted
+	if parts[0].Incomplete() || parts[1].Incomplete() {
+		t.Error("accounts with resources reported as not counted")
+	}
+	if !parts[2].Incomplete() {
+		t.Error("account without resources in an incomplete scan not reported as incomplete")
+	}
+
+	want := []string{"resources of these types could not be attributed to accounts and are not included: iam:role"}
+	for _, part := range parts {
+		if !reflect.DeepEqual(part.Warnings, want) {
+			t.Errorf("Warnings = %v, want %v", part.Warnings, want)
+		}
+	}
+}
+
+func TestSplitByTenant(t *testing.T) {
+	result := &models.SizingResult{
+		Provider: "Azure",
+		ResourceCounts: []*models.ResourceCount{
+			{Type: "microsoft.compute/virtualmachines", TotalResources: 6,
+				ByAccount:  map[string]int{"tenant-a/sub-1": 3, "tenant-a/sub-2": 1, "tenant-b/sub-3": 2},
+				ByLocation: map[string]int{"westeurope": 6}},
+			{Type: "microsoft.storage/storageaccounts", TotalResources: 4,
+				ByAccount:  map[string]int{"tenant-b/sub-3": 4},
+				ByLocation: map[string]int{"eastus": 4}},
+		},
+		AccountCounts: []models.AccountCount{
+			{ID: "tenant-a/sub-1", Tenant: "tenant-a"},
+			{ID: "tenant-b/sub-3", Tenant: "tenant-b"},
+			{ID: "tenant-a/sub-2", Tenant: "tenant-a"},
+		},
+	}
+
+	parts := SplitByTenant(result)
+	if len(parts) != 2 {
+		t.Fatalf("SplitByTenant() returned %d results, want 2", len(parts))
+	}
+
+	a, b := parts[0], parts[1]
+	if a.TotalAccounts != 2 || a.TotalResources != 4 || b.TotalAccounts != 1 || b.TotalResources != 6 {
+		t.Errorf("totals = %d/%d and %d/%d accounts/resources, want 2/4 and 1/6",
+			a.TotalAccounts, a.TotalResources, b.TotalAccounts, b.TotalResources)
+	}
+	want := map[string]int{"tenant-a/sub-1": 3, "tenant-a/sub-2": 1}
+	if got := a.ResourceCounts[0].ByAccount; !reflect.DeepEqual(got, want) {
+		t.Errorf("tenant-a ByAccount = %v, want %v", got, want)
+	}
+
+	// Breakdowns survive only where the whole type belongs to the tenant
+	if a.ResourceCounts[0].ByLocation != nil {
+		t.Errorf("virtual machines keep the breakdown of both tenants: %v", a.ResourceCounts[0].ByLocation)
+	}
+	if !reflect.DeepEqual(b.ResourceCounts[1].ByLocation, map[string]int{"eastus": 4}) {
+		t.Errorf("storage ByLocation = %v, want the full breakdown", b.ResourceCounts[1].ByLocation)
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		output string
+		id     string
+		want   string
+	}{
+		{output: "sizing.json", id: "123", want: "sizing-123.json"},
+		{output: "out/sizing.report.yaml", id: "123", want: "out/sizing.report-123.yaml"},
+		{output: "sizing", id: "123", want: "sizing-123"},
+		{output: "sizing.json", id: "tenant-a/sub-1", want: "sizing-tenant-a-sub-1.json"},
+	}
+
+	for _, tt := range tests {
+		if got := SplitPath(tt.output, tt.id); got != tt.want {
+			t.Errorf("SplitPath(%q, %q) = %q, want %q", tt.output, tt.id, got, tt.want)
+		}
+	}
+}
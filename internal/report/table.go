@@ -0,0 +1,377 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"golang.org/x/text/message"
+)
+
+// topRegions is the number of regions listed per type in verbose tables
+const topRegions = 3
+
+// topRuntimes is the number of function runtimes listed per type in verbose
+// tables
+const topRuntimes = 5
+
+// slowestTypes is the number of types listed as slowest in verbose tables
+const slowestTypes = 5
+
+// tableReporter renders a human-readable summary
+type tableReporter struct {
+	Progress
+	out     io.Writer
+	verbose bool
+	// ascii replaces the emoji and other symbols, for --ascii
+	ascii bool
+	// numbers groups the digits of counts the way the locale does
+	numbers *message.Printer
+}
+
+func (r *tableReporter) Write(result *models.SizingResult) error {
+	w := &strings.Builder{}
+
+	fmt.Fprintln(w, "\n=================================")
+	fmt.Fprintf(w, "Provider: %s\n", result.Provider)
+	if result.AssumedRoleARN != "" {
+		fmt.Fprintf(w, "Assumed role: %s\n", result.AssumedRoleARN)
+	}
+	if excluded := result.RawTotalResources - result.TotalResources; excluded > 0 {
+		fmt.Fprintf(w, "Total Resources: %s (%s before excluding %s cloud-managed resources)\n",
+			r.number(result.TotalResources), r.number(result.RawTotalResources), r.number(excluded))
+	} else {
+		fmt.Fprintf(w, "Total Resources: %s\n", r.number(result.TotalResources))
+	}
+	if len(result.BillableTypes) > 0 {
+		fmt.Fprintf(w, "Billable Workloads: %s\n", r.number(result.BillableWorkloads))
+	}
+	if result.OrganizationID != "" {
+		role := "member account"
+		if result.IsManagementAccount {
+			role = "management account"
+		}
+		fmt.Fprintf(w, "Organization: %s (%s)\n", result.OrganizationID, role)
+	}
+	fmt.Fprintf(w, "Accounts/Subscriptions: %d\n", len(result.AccountCounts))
+	if len(result.TagFilters) > 0 {
+		fmt.Fprintf(w, "Tag filters: %s\n", formatTagFilters(result.TagFilters))
+	}
+	if result.Scope != nil {
+		fmt.Fprintf(w, "Scope: %s\n", formatScope(result.Scope))
+	}
+
+	if len(result.EmptyRegions) > 0 {
+		fmt.Fprintf(w, "Regions without tagged resources: %s\n", strings.Join(result.EmptyRegions, ", "))
+	}
+	if result.Incomplete() {
+		fmt.Fprintln(w, "---------------------------------")
+		fmt.Fprintf(w, "⚠️  INCOMPLETE SIZING: resources were counted in %d of %d accounts/subscriptions discovered\n",
+			result.AccountsScanned, result.AccountsDiscovered)
+		fmt.Fprintf(w, "    %s\n", incompleteHint(result.Provider))
+	}
+	if result.CredentialsExpired {
+		fmt.Fprintln(w, "---------------------------------")
+		fmt.Fprintln(w, "⚠️  TRUNCATED SIZING: the credentials expired during the scan; the types not counted by then are missing")
+		fmt.Fprintln(w, "    Sign in again and rerun the scan for complete counts")
+	}
+
+	// Suspicious results come first, ahead of the numbers they cast doubt on
+	sanity, warnings := splitSanityWarnings(result.Warnings)
+	if len(sanity) > 0 {
+		fmt.Fprintln(w, "---------------------------------")
+		fmt.Fprintln(w, "⚠️  CHECK THESE RESULTS BEFORE USING THEM:")
+		for _, warning := range sanity {
+			fmt.Fprintf(w, "    - %s\n", strings.TrimPrefix(warning, models.SanityWarningPrefix))
+		}
+	}
+
+	// Show per-account breakdown
+	if len(result.AccountCounts) > 0 {
+		fmt.Fprintln(w, "---------------------------------")
+		fmt.Fprintln(w, "Per Account/Subscription:")
+		rows := make([]columnRow, len(result.AccountCounts))
+		for i, account := range result.AccountCounts {
+			rows[i] = columnRow{label: accountLabel(account), count: account.ResourceCount, note: " resources"}
+		}
+		r.writeColumns(w, rows, len(rows) > 1)
+	}
+
+	if len(result.AccountGroups) > 0 {
+		fmt.Fprintln(w, "---------------------------------")
+		fmt.Fprintf(w, "By %s tag:\n", result.AccountGroupTag)
+		rows := make([]columnRow, len(result.AccountGroups))
+		for i, group := range result.AccountGroups {
+			rows[i] = columnRow{label: group.Value, count: group.TotalResources,
+				note: fmt.Sprintf(" resources (%s accounts)", r.number(group.Accounts))}
+		}
+		r.writeColumns(w, rows, false)
+	}
+
+	// Management groups are indented under their parent, keeping the
+	// counts aligned
+	if len(result.ManagementGroups) > 0 {
+		fmt.Fprintln(w, "---------------------------------")
+		fmt.Fprintln(w, "By management group:")
+		rows := make([]columnRow, len(result.ManagementGroups))
+		for i, group := range result.ManagementGroups {
+			rows[i] = columnRow{indent: max(len(group.Path)-1, 0), label: group.Name, count: group.TotalResources,
+				note: fmt.Sprintf(" resources (%s subscriptions)", r.number(group.Subscriptions))}
+		}
+		r.writeColumns(w, rows, false)
+	}
+
+	// Show resource breakdown with better formatting
+	fmt.Fprintln(w, "---------------------------------")
+	fmt.Fprintln(w, "Resource Breakdown:")
+	var rows []columnRow
+	for _, rc := range result.ResourceCounts {
+		if rc.TotalResources > 0 {
+			unfiltered := ""
+			if rc.TagFilterNotApplied {
+				unfiltered = " [tag filter not applied]"
+			}
+			if rc.Truncated {
+				unfiltered += " [truncated at --max-pages]"
+			}
+			rows = append(rows, columnRow{label: rc.DisplayName, count: rc.TotalResources,
+				note: r.formatStates(rc.ByState) + unfiltered})
+			// Variants such as load balancer kinds get a line each
+			for _, kind := range sortByCount(rc.ByKind) {
+				rows = append(rows, columnRow{indent: 1, label: kind, count: rc.ByKind[kind]})
+			}
+			if contents := r.formatContents(rc); contents != "" {
+				rows = append(rows, columnRow{text: "Contents: " + contents})
+			}
+			if rc.NoiseExcluded > 0 && r.verbose {
+				rows = append(rows, columnRow{text: "Cloud-managed excluded: " + r.number(rc.NoiseExcluded)})
+			}
+			if len(rc.ByRuntime) > 0 && r.verbose {
+				rows = append(rows, columnRow{text: "Runtimes: " + r.formatTopCounts(rc.ByRuntime, topRuntimes)})
+			}
+			if len(rc.ByExposure) > 0 && r.verbose {
+				rows = append(rows, columnRow{text: "Exposure: " + r.formatBreakdown(rc.ByExposure)})
+			}
+			// Optionally show top regions
+			if len(rc.ByLocation) > 0 && r.verbose {
+				rows = append(rows, columnRow{text: "Regions: " + r.formatTopRegions(rc.ByLocation)})
+			}
+		}
+	}
+	r.writeColumns(w, rows, len(rows) > 0)
+
+	if slowest := r.formatSlowestTypes(result.ResourceCounts); slowest != "" && r.verbose {
+		fmt.Fprintf(w, "Slowest types: %s\n", slowest)
+	}
+
+	if total := len(warnings) + len(result.LogWarnings) + result.LogWarningsDropped; total > 0 {
+		fmt.Fprintln(w, "---------------------------------")
+		fmt.Fprintf(w, "Warnings (%d):\n", total)
+		for _, warning := range warnings {
+			fmt.Fprintf(w, "  ⚠️  %s\n", warning)
+		}
+		if result.LogWarningsDropped > 0 {
+			fmt.Fprintf(w, "  … %s earlier logged warnings not kept\n", r.number(result.LogWarningsDropped))
+		}
+		for _, warning := range result.LogWarnings {
+			glyph := "⚠️ "
+			if warning.Level != "warn" {
+				glyph = "✗"
+			}
+			fmt.Fprintf(w, "  %s %s%s\n", glyph, warning.Message, formatLogFields(warning.Fields))
+		}
+	}
+
+	if result.Estimate != nil {
+		fmt.Fprintln(w, "=================================")
+		fmt.Fprintf(w, "★ Estimated Workload Units: %.2f\n", result.Estimate.WorkloadUnits)
+		fmt.Fprintf(w, "★ Recommended Tier: %s\n", strings.ToUpper(result.Estimate.Tier))
+		fmt.Fprintf(w, "  Weights: %s\n", result.WeightsFile)
+	}
+
+	fmt.Fprintln(w, "=================================")
+	fmt.Fprintf(w, "Timestamp: %s\n", result.Timestamp)
+
+	text := w.String()
+	if r.ascii {
+		text = toASCII(text)
+	}
+	if _, err := io.WriteString(r.out, text); err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+	return nil
+}
+
+// formatLogFields renders the fields of a logged warning sorted by key, e.g.
+// " (region=us-east-1, type=ec2:instance)", or "" when it has none
+func formatLogFields(fields map[string]string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + fields[key]
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// incompleteHint tells how to size the accounts or subscriptions an
+// incomplete scan left out
+func incompleteHint(provider string) string {
+	if strings.EqualFold(provider, "aws") {
+		return "Rerun in each remaining account, e.g. from the management account with --assume-role-arn, for the full picture."
+	}
+	return "Only the subscriptions chosen with --subscriptions or AZURE_SUBSCRIPTION_ID were scanned; rerun without them for the full picture."
+}
+
+// splitSanityWarnings separates the warnings of models.SanityWarnings from
+// the others, keeping their order
+func splitSanityWarnings(all []string) (sanity, others []string) {
+	for _, warning := range all {
+		if models.IsSanityWarning(warning) {
+			sanity = append(sanity, warning)
+		} else {
+			others = append(others, warning)
+		}
+	}
+	return sanity, others
+}
+
+// accountLabel renders an account as "123456789012 (prod-main)", flagging
+// suspended accounts
+func accountLabel(account models.AccountCount) string {
+	label := account.ID
+	if account.Name != "" && account.Name != account.ID {
+		label = fmt.Sprintf("%s (%s)", account.ID, account.Name)
+	}
+	if strings.EqualFold(account.Status, "SUSPENDED") {
+		label += " [suspended]"
+	}
+	if account.Profile != "" {
+		label += " [profile " + account.Profile + "]"
+	}
+	return label
+}
+
+// formatSlowestTypes renders the slowest types with their duration, pages
+// and retries, e.g. "EC2 Instances 3.2s (17 pages, 2 retries)", or "" when
+// no type has stats
+func (r *tableReporter) formatSlowestTypes(counts []*models.ResourceCount) string {
+	var timed []*models.ResourceCount
+	for _, rc := range counts {
+		if rc.Stats != nil {
+			timed = append(timed, rc)
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].Stats.DurationMs > timed[j].Stats.DurationMs
+	})
+	if len(timed) > slowestTypes {
+		timed = timed[:slowestTypes]
+	}
+
+	parts := make([]string, len(timed))
+	for i, rc := range timed {
+		duration := (time.Duration(rc.Stats.DurationMs) * time.Millisecond).Round(100 * time.Millisecond)
+		parts[i] = rc.DisplayName + " " + duration.String() +
+			r.numbers.Sprintf(" (%d pages, %d retries)", rc.Stats.Pages, rc.Stats.Retries)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatScope renders a scan scope as "12 of 40 resource types, regions
+// eu-west-1, eu-central-1 of 17"
+func formatScope(scope *models.ScanScope) string {
+	text := fmt.Sprintf("%d of %d resource types", scope.ResourceTypes, scope.DefaultResourceTypes)
+	if len(scope.Regions) > 0 {
+		text += fmt.Sprintf(", regions %s of %d", strings.Join(scope.Regions, ", "), scope.AvailableRegions)
+	}
+	return text
+}
+
+// formatTagFilters renders filters as "CostCenter=42, Team=data"
+func formatTagFilters(filters []models.TagFilter) string {
+	parts := make([]string, len(filters))
+	for i, filter := range filters {
+		parts[i] = filter.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatStates renders a state breakdown as " (412 running, 111 deallocated)",
+// largest first
+func (r *tableReporter) formatStates(byState map[string]int) string {
+	if len(byState) == 0 {
+		return ""
+	}
+	return " (" + r.formatBreakdown(byState) + ")"
+}
+
+// formatContents renders what --deep-registries found inside registries, and
+// the storage accounts or vaults --deep-storage and --deep-backup could not
+// read, as "40,000 images, 2 unreadable", or "" when it did not look
+func (r *tableReporter) formatContents(rc *models.ResourceCount) string {
+	var parts []string
+	if rc.Images > 0 {
+		parts = append(parts, r.number(rc.Images)+" images")
+	}
+	if rc.Repositories > 0 {
+		parts = append(parts, r.number(rc.Repositories)+" repositories")
+	}
+	if rc.ContentUnknown > 0 {
+		parts = append(parts, r.number(rc.ContentUnknown)+" unreadable")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatBreakdown renders counts as "60 public, 22 internal", largest first
+func (r *tableReporter) formatBreakdown(counts map[string]int) string {
+	parts := make([]string, 0, len(counts))
+	for _, key := range sortByCount(counts) {
+		parts = append(parts, r.number(counts[key])+" "+key)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatTopRegions renders the largest regions as "us-east-1(12), eu-west-1(3)"
+func (r *tableReporter) formatTopRegions(byLocation map[string]int) string {
+	return r.formatTopCounts(byLocation, topRegions)
+}
+
+// formatTopCounts lists the n largest counts as "key(count)"
+func (r *tableReporter) formatTopCounts(counts map[string]int, n int) string {
+	keys := sortByCount(counts)
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s(%s)", key, r.number(counts[key]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortByCount returns the keys of counts, largest count first and by name
+// among equal counts
+func sortByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,56 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+// yamlReporter renders the full result as YAML with the same keys and order
+// as the JSON output
+type yamlReporter struct {
+	Progress
+	out   io.Writer
+	stats bool
+}
+
+func (r *yamlReporter) Write(result *models.SizingResult) error {
+	if !r.stats {
+		result = result.WithoutStats()
+	}
+	return writeYAML(r.out, result)
+}
+
+// writeYAML renders value as YAML. Going through JSON keeps the JSON field
+// names, which the models only declare for JSON.
+func writeYAML(out io.Writer, value any) error {
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(jsonData, &node); err != nil {
+		return fmt.Errorf("failed to convert results to YAML: %w", err)
+	}
+	blockStyle(&node)
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return fmt.Errorf("failed to write YAML: %w", err)
+	}
+	return encoder.Close()
+}
+
+// blockStyle drops the flow and quoting styles the JSON input left on node
+// so that it is emitted as regular block YAML
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,400 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	"github.com/secrails/secrails-sizing-agent/internal/identity"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+)
+
+// scanRetention is how long finished scans remain available
+const scanRetention = 24 * time.Hour
+
+// maxRequestBody bounds the size of a POST /scans body
+const maxRequestBody = 1 << 20
+
+// Scan states
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusPartial   = "partial"
+	StatusFailed    = "failed"
+)
+
+// Options configures the scan API server
+type Options struct {
+	// Addr is the listen address, e.g. ":8080"
+	Addr string
+
+	// Token is the bearer token every /scans request must present
+	Token string
+
+	// MaxConcurrentScans rejects new scans with 429 while this many run
+	MaxConcurrentScans int
+
+	// ShutdownTimeout is how long shutdown waits for running scans before
+	// cancelling them
+	ShutdownTimeout time.Duration
+
+	// Base is the agent configuration each scan starts from; requests set
+	// the provider and filters
+	Base agent.Config
+}
+
+// ScanRequest is the body of POST /scans
+type ScanRequest struct {
+	Provider         string   `json:"provider"`
+	Regions          []string `json:"regions,omitempty"`
+	Subscriptions    []string `json:"subscriptions,omitempty"`
+	IncludeIdentity  string   `json:"include_identity,omitempty"`
+	IncludeSuspended bool     `json:"include_suspended,omitempty"`
+	ExcludeNoise     bool     `json:"exclude_noise,omitempty"`
+	DeepRegistries   bool     `json:"deep_registries,omitempty"`
+	DeepStorage      bool     `json:"deep_storage,omitempty"`
+	DeepBackup       bool     `json:"deep_backup,omitempty"`
+	Anonymize        bool     `json:"anonymize,omitempty"`
+}
+
+// Scan is the state of one scan as returned by the API
+type Scan struct {
+	ID         string               `json:"id"`
+	Status     string               `json:"status"`
+	Provider   string               `json:"provider"`
+	StartedAt  time.Time            `json:"started_at"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	Result     *models.SizingResult `json:"result,omitempty"`
+}
+
+// scanFunc runs one scan; tests replace it
+type scanFunc func(ctx context.Context, config *agent.Config) (*models.SizingResult, error)
+
+// Server runs sizing scans on request
+type Server struct {
+	options Options
+	scan    scanFunc
+
+	// scanCtx is cancelled when running scans must stop
+	scanCtx    context.Context
+	cancelScan context.CancelFunc
+	wg         sync.WaitGroup
+
+	mu       sync.Mutex
+	scans    map[string]*Scan
+	running  int
+	draining bool
+}
+
+// New creates a server that runs scans through the agent
+func New(options Options) *Server {
+	if options.MaxConcurrentScans <= 0 {
+		options.MaxConcurrentScans = 1
+	}
+	scanCtx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		options: options,
+		scan: func(ctx context.Context, config *agent.Config) (*models.SizingResult, error) {
+			return agent.New(config).Scan(ctx)
+		},
+		scanCtx:    scanCtx,
+		cancelScan: cancel,
+		scans:      make(map[string]*Scan),
+	}
+}
+
+// Handler returns the HTTP handler of the scan API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.Handle("POST /scans", s.authenticate(http.HandlerFunc(s.handleCreate)))
+	mux.Handle("GET /scans/{id}", s.authenticate(http.HandlerFunc(s.handleGet)))
+	return mux
+}
+
+// ListenAndServe serves the API until ctx is cancelled, then stops accepting
+// scans, waits up to ShutdownTimeout for running scans, cancels the rest and
+// stops the HTTP server
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if s.options.Token == "" {
+		return fmt.Errorf("an API token is required")
+	}
+
+	listener, err := net.Listen("tcp", s.options.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	httpServer := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- httpServer.Serve(listener) }()
+	logging.Info("Scan API listening", zap.String("addr", listener.Addr().String()),
+		zap.Int("max_concurrent_scans", s.options.MaxConcurrentScans))
+
+	select {
+	case err := <-serveErr:
+		s.cancelScan()
+		return fmt.Errorf("scan API server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	logging.Info("Shutting down scan API", zap.Int("running_scans", s.runningScans()))
+	s.drain(s.options.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		_ = httpServer.Close()
+	}
+	return nil
+}
+
+// drain rejects new scans and waits for running ones, cancelling them once
+// timeout elapses. Status requests are still served meanwhile.
+func (s *Server) drain(timeout time.Duration) {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logging.Warn("Cancelling scans still running after the shutdown timeout",
+			zap.Int("running_scans", s.runningScans()), zap.Duration("timeout", timeout))
+		s.cancelScan()
+		<-done
+	}
+	s.cancelScan()
+}
+
+func (s *Server) runningScans() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// authenticate requires the configured bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="secrails-sizing-agent"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var request ScanRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	config, err := s.scanConfig(request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := newScanID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	scan := &Scan{ID: id, Status: StatusRunning, Provider: config.Provider, StartedAt: time.Now().UTC()}
+
+	s.mu.Lock()
+	switch {
+	case s.draining:
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	case s.running >= s.options.MaxConcurrentScans:
+		s.mu.Unlock()
+		writeError(w, http.StatusTooManyRequests,
+			fmt.Sprintf("%d scans are already running", s.options.MaxConcurrentScans))
+		return
+	}
+	s.pruneLocked(scan.StartedAt)
+	s.scans[id] = scan
+	s.running++
+	s.wg.Add(1)
+	response := *scan
+	s.mu.Unlock()
+
+	logging.Info("Scan started", zap.String("scan_id", id), zap.String("provider", config.Provider))
+	go s.run(scan, config)
+
+	w.Header().Set("Location", "/scans/"+id)
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	scan, ok := s.scans[r.PathValue("id")]
+	var response Scan
+	if ok {
+		response = *scan
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// run performs a scan and records its outcome
+func (s *Server) run(scan *Scan, config *agent.Config) {
+	defer s.wg.Done()
+
+	result, err := s.runRecovered(config)
+	finished := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running--
+	scan.FinishedAt = &finished
+	if err != nil && result != nil {
+		scan.Status = StatusPartial
+		scan.Error = err.Error()
+		scan.Result = result
+		logging.Warn("Scan finished with incomplete results", zap.String("scan_id", scan.ID), zap.Error(err))
+		return
+	}
+	if err != nil {
+		scan.Status = StatusFailed
+		scan.Error = err.Error()
+		logging.Error("Scan failed", zap.String("scan_id", scan.ID), zap.Error(err))
+		return
+	}
+	scan.Status = StatusSucceeded
+	scan.Result = result
+	logging.Info("Scan finished", zap.String("scan_id", scan.ID), zap.Int("total_resources", result.TotalResources),
+		zap.Duration("duration", finished.Sub(scan.StartedAt).Round(time.Second)))
+}
+
+// runRecovered runs one scan, turning a panic into an error
+func (s *Server) runRecovered(config *agent.Config) (result *models.SizingResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scan panicked: %v", r)
+		}
+	}()
+	return s.scan(s.scanCtx, config)
+}
+
+// scanConfig validates a request and builds the agent configuration for it
+func (s *Server) scanConfig(request ScanRequest) (*agent.Config, error) {
+	provider := providers.Normalize(request.Provider)
+	switch request.IncludeIdentity {
+	case "":
+	case identity.ProviderEntra:
+		if provider != "azure" {
+			return nil, fmt.Errorf("include_identity entra applies to azure only")
+		}
+	case identity.ProviderOkta:
+		if s.options.Base.OktaOrgURL == "" || s.options.Base.OktaAPIToken == "" {
+			return nil, fmt.Errorf("include_identity okta needs the server started with %s and %s", identity.OktaOrgURLEnv, identity.OktaTokenEnv)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported include_identity %q (supported: %s)", request.IncludeIdentity, strings.Join(identity.Providers, ", "))
+	}
+	switch provider {
+	case "aws":
+		if len(request.Subscriptions) > 0 {
+			return nil, fmt.Errorf("subscriptions apply to azure only")
+		}
+	case "azure":
+		if len(request.Regions) > 0 || request.IncludeSuspended {
+			return nil, fmt.Errorf("regions and include_suspended apply to aws only")
+		}
+	case "k8s":
+		if len(request.Regions) > 0 || len(request.Subscriptions) > 0 || request.IncludeSuspended {
+			return nil, fmt.Errorf("regions, subscriptions and include_suspended do not apply to k8s")
+		}
+	case "oci":
+		if len(request.Subscriptions) > 0 || request.IncludeSuspended {
+			return nil, fmt.Errorf("subscriptions and include_suspended do not apply to oci")
+		}
+	case "":
+		return nil, fmt.Errorf("provider is required")
+	default:
+		if !slices.Contains(providers.ListProviders(), provider) {
+			return nil, fmt.Errorf("unsupported provider %q (supported: %s)", request.Provider, strings.Join(providers.ListProviders(), ", "))
+		}
+	}
+
+	config := s.options.Base
+	config.Provider = provider
+	config.Regions = request.Regions
+	config.Subscriptions = request.Subscriptions
+	config.IncludeIdentity = request.IncludeIdentity
+	config.IncludeSuspended = request.IncludeSuspended
+	config.ExcludeNoise = request.ExcludeNoise
+	config.DeepRegistries = request.DeepRegistries
+	config.DeepStorage = request.DeepStorage
+	config.DeepBackup = request.DeepBackup
+	config.Anonymize = request.Anonymize
+	return &config, nil
+}
+
+// pruneLocked forgets scans that finished more than scanRetention ago
+func (s *Server) pruneLocked(now time.Time) {
+	for id, scan := range s.scans {
+		if scan.FinishedAt != nil && now.Sub(*scan.FinishedAt) > scanRetention {
+			delete(s.scans, id)
+		}
+	}
+}
+
+// newScanID returns a random scan identifier
+func newScanID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate scan ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+)
+
+const testToken = "s3cret"
+
+// newTestServer returns a server whose scans run scan instead of the agent
+func newTestServer(t *testing.T, maxConcurrent int, scan scanFunc) (*Server, *httptest.Server) {
+	t.Helper()
+	s := New(Options{Token: testToken, MaxConcurrentScans: maxConcurrent})
+	s.scan = scan
+	httpServer := httptest.NewServer(s.Handler())
+	t.Cleanup(httpServer.Close)
+	return s, httpServer
+}
+
+func request(t *testing.T, method, url, token, body string) (*http.Response, Scan) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var scan Scan
+	_ = json.NewDecoder(resp.Body).Decode(&scan)
+	return resp, scan
+}
+
+func TestAuthentication(t *testing.T) {
+	_, httpServer := newTestServer(t, 1, nil)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{name: "health needs no token", method: http.MethodGet, path: "/healthz", want: http.StatusOK},
+		{name: "create without token", method: http.MethodPost, path: "/scans", want: http.StatusUnauthorized},
+		{name: "create with wrong token", method: http.MethodPost, path: "/scans", token: "nope", want: http.StatusUnauthorized},
+		{name: "get without token", method: http.MethodGet, path: "/scans/abc", want: http.StatusUnauthorized},
+		{name: "get unknown scan", method: http.MethodGet, path: "/scans/abc", token: testToken, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, _ := request(t, tt.method, httpServer.URL+tt.path, tt.token, "{}")
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	_, httpServer := newTestServer(t, 1, nil)
+
+	for _, body := range []string{
+		`not json`,
+		`{}`,
+		`{"provider":"gcp"}`,
+		`{"provider":"aws","subscriptions":["sub-1"]}`,
+		`{"provider":"azure","regions":["eastus"]}`,
+		`{"provider":"aws","region_filter":["us-east-1"]}`,
+		`{"provider":"aws","include_identity":"entra"}`,
+		`{"provider":"azure","include_identity":"okta"}`,
+		`{"provider":"azure","include_identity":"ping"}`,
+	} {
+		resp, _ := request(t, http.MethodPost, httpServer.URL+"/scans", testToken, body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST %s: status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestScanLifecycle(t *testing.T) {
+	release := make(chan struct{})
+	var got *agent.Config
+	_, httpServer := newTestServer(t, 1, func(_ context.Context, config *agent.Config) (*models.SizingResult, error) {
+		got = config
+		<-release
+		if config.Provider == "azure" {
+			return nil, errors.New("no credentials")
+		}
+		return &models.SizingResult{Provider: "AWS", TotalResources: 42}, nil
+	})
+
+	resp, created := request(t, http.MethodPost, httpServer.URL+"/scans", testToken,
+		`{"provider":"AWS","regions":["us-east-1","eu-west-1"],"include_suspended":true}`)
+	if resp.StatusCode != http.StatusAccepted || created.ID == "" || created.Status != StatusRunning {
+		t.Fatalf("POST /scans = %d %+v", resp.StatusCode, created)
+	}
+	if resp.Header.Get("Location") != "/scans/"+created.ID {
+		t.Errorf("Location = %q", resp.Header.Get("Location"))
+	}
+
+	// The limit of one concurrent scan rejects a second request
+	if resp, _ := request(t, http.MethodPost, httpServer.URL+"/scans", testToken, `{"provider":"azure"}`); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("second POST /scans status = %d, want 429", resp.StatusCode)
+	}
+
+	close(release)
+	scan := waitForScan(t, httpServer.URL, created.ID)
+	if scan.Status != StatusSucceeded || scan.Result == nil || scan.Result.TotalResources != 42 || scan.FinishedAt == nil {
+		t.Errorf("finished scan = %+v", scan)
+	}
+	if got.Provider != "aws" || len(got.Regions) != 2 || !got.IncludeSuspended {
+		t.Errorf("scan config = %+v", got)
+	}
+
+	// Failures are reported on the scan
+	resp, created = request(t, http.MethodPost, httpServer.URL+"/scans", testToken, `{"provider":"azure"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /scans = %d", resp.StatusCode)
+	}
+	scan = waitForScan(t, httpServer.URL, created.ID)
+	if scan.Status != StatusFailed || scan.Error != "no credentials" {
+		t.Errorf("failed scan = %+v", scan)
+	}
+}
+
+// waitForScan polls a scan until it leaves the running state
+func waitForScan(t *testing.T, url, id string) Scan {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, scan := request(t, http.MethodGet, url+"/scans/"+id, testToken, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /scans/%s = %d", id, resp.StatusCode)
+		}
+		if scan.Status != StatusRunning {
+			return scan
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("scan %s still running", id)
+	return Scan{}
+}
+
+func TestDrain(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		wantStatus  string
+		wantErrText string
+	}{
+		{name: "running scans finish", timeout: 5 * time.Second, wantStatus: StatusSucceeded},
+		{name: "timeout cancels scans", timeout: 10 * time.Millisecond, wantStatus: StatusFailed, wantErrText: "context canceled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, httpServer := newTestServer(t, 1, func(ctx context.Context, _ *agent.Config) (*models.SizingResult, error) {
+				select {
+				case <-time.After(100 * time.Millisecond):
+					return &models.SizingResult{}, nil
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			})
+
+			_, created := request(t, http.MethodPost, httpServer.URL+"/scans", testToken, `{"provider":"aws"}`)
+			s.drain(tt.timeout)
+
+			if resp, _ := request(t, http.MethodPost, httpServer.URL+"/scans", testToken, `{"provider":"aws"}`); resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("POST /scans while draining = %d, want 503", resp.StatusCode)
+			}
+			_, scan := request(t, http.MethodGet, httpServer.URL+"/scans/"+created.ID, testToken, "")
+			if scan.Status != tt.wantStatus || scan.Error != tt.wantErrText {
+				t.Errorf("scan after drain = %+v, want status %s", scan, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package sink
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted files start with magic and a random salt, followed by the
+// plaintext in segments of segmentSize bytes, each sealed with AES-256-GCM
+// under a key derived from the passphrase and salt with scrypt. The nonce is
+// the segment number with its last byte set on the final segment, so a
+// truncated file fails to decrypt instead of passing as complete.
+const (
+	magic       = "secrails-enc-v1\n"
+	saltSize    = 16
+	segmentSize = 64 * 1024
+	nonceSize   = 12
+)
+
+// scrypt cost parameters, the interactive-login values recommended by the
+// scrypt paper
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// ErrDecrypt is returned for a wrong passphrase and for corrupted or
+// truncated files alike, as the two cannot be told apart
+var ErrDecrypt = errors.New("failed to decrypt: wrong key, or the file is corrupted or truncated")
+
+// encrypter seals what is written to it segment by segment
+type encrypter struct {
+	out     io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	segment uint64
+	closed  bool
+}
+
+// NewEncrypter returns a writer that encrypts to w with a key derived from
+// passphrase. Close seals the final segment and must be called; it does not
+// close w.
+func NewEncrypter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate encryption salt: %w", err)
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(magic), salt...)); err != nil {
+		return nil, fmt.Errorf("failed to write encryption header: %w", err)
+	}
+	return &encrypter{out: w, aead: aead, buf: make([]byte, 0, segmentSize)}, nil
+}
+
+func (e *encrypter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full segment is sealed only once more data arrives, so that
+		// Close knows which segment is the last
+		if len(e.buf) == segmentSize {
+			if err := e.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):segmentSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encrypter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+func (e *encrypter) seal(final bool) error {
+	sealed := e.aead.Seal(nil, segmentNonce(e.segment, final), e.buf, nil)
+	e.segment++
+	e.buf = e.buf[:0]
+	if _, err := e.out.Write(sealed); err != nil {
+		return fmt.Errorf("failed to write encrypted data: %w", err)
+	}
+	return nil
+}
+
+// decrypter opens the segments read from an encrypted file
+type decrypter struct {
+	in      *bufio.Reader
+	aead    cipher.AEAD
+	sealed  []byte
+	plain   []byte
+	segment uint64
+	done    bool
+}
+
+// NewDecrypter returns a reader of the plaintext of the encrypted data in r.
+// Reads fail with ErrDecrypt when the passphrase is wrong or the data was
+// altered or cut short.
+func NewDecrypter(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, len(magic)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not an encrypted output file")
+	}
+	aead, err := newAEAD(passphrase, header[len(magic):])
+	if err != nil {
+		return nil, err
+	}
+	return &decrypter{
+		in:     bufio.NewReaderSize(r, segmentSize+aead.Overhead()+1),
+		aead:   aead,
+		sealed: make([]byte, segmentSize+aead.Overhead()),
+	}, nil
+}
+
+func (d *decrypter) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// open reads and opens the next segment; a short segment, or a full one
+// with nothing after it, is the last
+func (d *decrypter) open() error {
+	n, err := io.ReadFull(d.in, d.sealed)
+	final := false
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		final = true
+	case err != nil:
+		return fmt.Errorf("failed to read encrypted data: %w", err)
+	default:
+		if _, err := d.in.Peek(1); errors.Is(err, io.EOF) {
+			final = true
+		}
+	}
+
+	plain, err := d.aead.Open(d.sealed[:0], segmentNonce(d.segment, final), d.sealed[:n], nil)
+	if err != nil {
+		return ErrDecrypt
+	}
+	d.segment++
+	d.done = final
+	d.plain = plain
+	return nil
+}
+
+// newAEAD derives the AES-256 key from passphrase and salt
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce returns the nonce of segment number segment
+func segmentNonce(segment uint64, final bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce[nonceSize-9:nonceSize-1], segment)
+	if final {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce
+}
@@ -0,0 +1,165 @@
+// Package sink creates output files, compressing and encrypting them on
+// their way to disk so that every format and writer gets both for free.
+package sink
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// File name suffixes appended for each transformation
+const (
+	CompressedExt = ".gz"
+	EncryptedExt  = ".enc"
+)
+
+// Options selects how output files are transformed. The zero value writes
+// them unchanged.
+type Options struct {
+	// Compress gzips the output and appends CompressedExt to its path
+	Compress bool
+
+	// Passphrase encrypts the output, after compression, and appends
+	// EncryptedExt to its path; empty disables encryption
+	Passphrase string
+}
+
+// Enabled reports whether any transformation is configured
+func (o Options) Enabled() bool {
+	return o.Compress || o.Passphrase != ""
+}
+
+// Path returns the path the output meant for path is written to
+func (o Options) Path(path string) string {
+	if o.Compress {
+		path += CompressedExt
+	}
+	if o.Passphrase != "" {
+		path += EncryptedExt
+	}
+	return path
+}
+
+// Create creates the file for path, at Path(path), and returns a writer that
+// compresses and encrypts as configured. Close finishes the gzip and
+// encryption streams before closing the file and must be called for the
+// file to be complete.
+func Create(path string, options Options) (io.WriteCloser, error) {
+	file, err := os.Create(options.Path(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	out := &chain{closers: []io.Closer{file}, Writer: file}
+	if options.Passphrase != "" {
+		encrypter, err := NewEncrypter(out.Writer, options.Passphrase)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		out.push(encrypter)
+	}
+	if options.Compress {
+		out.push(gzip.NewWriter(out.Writer))
+	}
+	return out, nil
+}
+
+// Key source prefixes of --encrypt-key and decrypt --key values
+const (
+	KeyFilePrefix = "file:"
+	KeyEnvPrefix  = "env:"
+)
+
+// ReadKey returns the passphrase given to --encrypt-key. A value of
+// file:PATH names a file whose first line is the passphrase, env:NAME an
+// environment variable holding it; any other value is the passphrase
+// itself. named reports whether value names a file or variable, and so can
+// be repeated without revealing the passphrase. A missing file or unset
+// variable is an error, never taken for the passphrase.
+func ReadKey(value string) (key string, named bool, err error) {
+	if path, ok := strings.CutPrefix(value, KeyFilePrefix); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+		key, _, _ = strings.Cut(string(data), "\n")
+		key = strings.TrimSuffix(key, "\r")
+		if key == "" {
+			return "", false, fmt.Errorf("encryption key file %s is empty", path)
+		}
+		return key, true, nil
+	}
+	if name, ok := strings.CutPrefix(value, KeyEnvPrefix); ok {
+		key = os.Getenv(name)
+		if key == "" {
+			return "", false, fmt.Errorf("encryption key variable %s is not set", name)
+		}
+		return key, true, nil
+	}
+	return value, false, nil
+}
+
+// chain is a stack of writers, each writing to the one below it, closed
+// from the top down
+type chain struct {
+	io.Writer
+	closers []io.Closer
+}
+
+func (c *chain) push(w io.WriteCloser) {
+	c.Writer = w
+	c.closers = append(c.closers, w)
+}
+
+func (c *chain) Close() error {
+	var first error
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		if err := c.closers[i].Close(); err != nil && first == nil {
+			first = fmt.Errorf("failed to write output file: %w", err)
+		}
+	}
+	return first
+}
+
+// Open opens a file written by Create and returns a reader of the original
+// output, decrypting with passphrase if the path ends in EncryptedExt and
+// decompressing if it then ends in CompressedExt. It also returns the path
+// the output was meant for.
+func Open(path, passphrase string) (io.ReadCloser, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open output file: %w", err)
+	}
+
+	original := path
+	var r io.Reader = file
+	if strings.HasSuffix(original, EncryptedExt) {
+		original = strings.TrimSuffix(original, EncryptedExt)
+		if passphrase == "" {
+			_ = file.Close()
+			return nil, "", fmt.Errorf("%s is encrypted; a key is required", path)
+		}
+		if r, err = NewDecrypter(r, passphrase); err != nil {
+			_ = file.Close()
+			return nil, "", fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if strings.HasSuffix(original, CompressedExt) {
+		original = strings.TrimSuffix(original, CompressedExt)
+		if r, err = gzip.NewReader(r); err != nil {
+			_ = file.Close()
+			return nil, "", fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+	}
+	return readCloser{Reader: r, Closer: file}, original, nil
+}
+
+// readCloser closes the file under a chain of readers
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,199 @@
+package sink
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	json := []byte(`{"schema_version":"2","provider":"AWS","total_resources":17}` + "\n")
+	large := make([]byte, 2*segmentSize+7)
+	if _, err := rand.Read(large); err != nil {
+		t.Fatal(err)
+	}
+
+	contents := map[string][]byte{
+		"empty":        {},
+		"json":         json,
+		"one segment":  bytes.Repeat([]byte("x"), segmentSize),
+		"two segments": large,
+	}
+	optionSets := map[string]Options{
+		"plain":              {},
+		"compress":           {Compress: true},
+		"encrypt":            {Passphrase: "correct horse"},
+		"compress + encrypt": {Compress: true, Passphrase: "correct horse"},
+	}
+
+	for optionsName, options := range optionSets {
+		for contentName, content := range contents {
+			t.Run(optionsName+"/"+contentName, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "results.json")
+				out, err := Create(path, options)
+				if err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+				// Small writes exercise the segment buffering
+				for chunk := range slices(content, 1000) {
+					if _, err := out.Write(chunk); err != nil {
+						t.Fatalf("Write() error = %v", err)
+					}
+				}
+				if err := out.Close(); err != nil {
+					t.Fatalf("Close() error = %v", err)
+				}
+
+				written := options.Path(path)
+				if _, err := os.Stat(written); err != nil {
+					t.Fatalf("output not written to %s: %v", written, err)
+				}
+				if options.Passphrase != "" {
+					raw, _ := os.ReadFile(written)
+					if len(content) > 16 && bytes.Contains(raw, content[:16]) {
+						t.Error("encrypted file contains plaintext")
+					}
+				}
+
+				in, original, err := Open(written, options.Passphrase)
+				if err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				defer in.Close()
+				got, err := io.ReadAll(in)
+				if err != nil {
+					t.Fatalf("ReadAll() error = %v", err)
+				}
+				if !bytes.Equal(got, content) {
+					t.Errorf("round trip changed the output: got %d bytes, want %d", len(got), len(content))
+				}
+				if original != path {
+					t.Errorf("Open() path = %q, want %q", original, path)
+				}
+			})
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		options Options
+		want    string
+	}{
+		{options: Options{}, want: "out.json"},
+		{options: Options{Compress: true}, want: "out.json.gz"},
+		{options: Options{Passphrase: "k"}, want: "out.json.enc"},
+		{options: Options{Compress: true, Passphrase: "k"}, want: "out.json.gz.enc"},
+	}
+	for _, tt := range tests {
+		if got := tt.options.Path("out.json"); got != tt.want {
+			t.Errorf("Path(%+v) = %q, want %q", tt.options, got, tt.want)
+		}
+	}
+}
+
+func TestDecryptFailures(t *testing.T) {
+	var encrypted bytes.Buffer
+	w, err := NewEncrypter(&encrypted, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(bytes.Repeat([]byte("a"), 2*segmentSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := encrypted.Bytes()
+	header := len(magic) + saltSize
+	sealedSegment := segmentSize + 16
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+	}{
+		{name: "wrong passphrase", data: data, passphrase: "guess"},
+		{name: "truncated at a segment boundary", data: data[:header+sealedSegment], passphrase: "secret"},
+		{name: "truncated mid-segment", data: data[:header+100], passphrase: "secret"},
+		{name: "final segment dropped", data: data[:header+2*sealedSegment], passphrase: "secret"},
+		{name: "flipped bit", data: flipBit(data, header+10), passphrase: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewDecrypter(bytes.NewReader(tt.data), tt.passphrase)
+			if err != nil {
+				t.Fatalf("NewDecrypter() error = %v", err)
+			}
+			if _, err := io.ReadAll(r); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("ReadAll() error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+
+	if _, err := NewDecrypter(strings.NewReader(`{"provider":"AWS"}`), "secret"); err == nil {
+		t.Error("NewDecrypter() accepted a file that is not encrypted")
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte("from-file\nignored\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIZING_TEST_KEY", "from-env")
+
+	tests := []struct {
+		value   string
+		want    string
+		named   bool
+		wantErr bool
+	}{
+		{value: "file:" + keyFile, want: "from-file", named: true},
+		{value: "env:SIZING_TEST_KEY", want: "from-env", named: true},
+		{value: "a literal passphrase", want: "a literal passphrase"},
+		{value: keyFile, want: keyFile},
+		{value: "file:" + emptyFile, wantErr: true},
+		{value: "file:" + filepath.Join(dir, "missing"), wantErr: true},
+		{value: "env:SIZING_TEST_UNSET_KEY", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, named, err := ReadKey(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ReadKey(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if got != tt.want || named != tt.named {
+			t.Errorf("ReadKey(%q) = %q, %v, want %q, %v", tt.value, got, named, tt.want, tt.named)
+		}
+	}
+}
+
+// slices yields data in chunks of at most size bytes
+func slices(data []byte, size int) func(func([]byte) bool) {
+	return func(yield func([]byte) bool) {
+		for len(data) > 0 {
+			n := min(size, len(data))
+			if !yield(data[:n]) {
+				return
+			}
+			data = data[n:]
+		}
+	}
+}
+
+func flipBit(data []byte, at int) []byte {
+	flipped := bytes.Clone(data)
+	flipped[at] ^= 1
+	return flipped
+}
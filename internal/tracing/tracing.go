@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is the default service.name resource attribute; OTEL_SERVICE_NAME
+// overrides it
+const serviceName = "secrails-sizing-agent"
+
+// instrumentationName identifies the tracer
+const instrumentationName = "github.com/secrails/secrails-sizing-agent"
+
+// tracer is nil while tracing is disabled, so every helper below costs a
+// single nil check
+var tracer trace.Tracer
+
+// Setup enables OTLP/HTTP trace export when endpoint is set or the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT / OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables
+// are; other OTEL_* variables configure the exporter as usual. The returned
+// function flushes and stops the exporter and must be called before exit.
+func Setup(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" &&
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// SetTracerProvider enables tracing with provider, or disables it when
+// provider is nil
+func SetTracerProvider(provider trace.TracerProvider) {
+	if provider == nil {
+		tracer = nil
+		return
+	}
+	tracer = provider.Tracer(instrumentationName)
+}
+
+// Span is an active span. A nil *Span, returned while tracing is disabled,
+// ignores every call.
+type Span struct {
+	span trace.Span
+}
+
+// Start starts a span as a child of the span in ctx
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+	ctx, span := tracer.Start(ctx, name)
+	return ctx, &Span{span: span}
+}
+
+// SetString sets a string attribute
+func (s *Span) SetString(key, value string) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attribute.String(key, value))
+}
+
+// SetInt sets an integer attribute
+func (s *Span) SetInt(key string, value int) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attribute.Int(key, value))
+}
+
+// End ends the span, marking it as failed when err is non-nil
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+// SetRetryCount records on the span in ctx how many times its request has
+// been retried so far
+func SetRetryCount(ctx context.Context, retries int) {
+	if tracer == nil {
+		return
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("retry_count", retries))
+}
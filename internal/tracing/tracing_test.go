@@ -0,0 +1,101 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// enableRecording routes spans to an in-memory recorder for the test
+func enableRecording(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { SetTracerProvider(nil) })
+	return recorder
+}
+
+// attributes returns a span's attributes by key
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestDisabled(t *testing.T) {
+	ctx := context.Background()
+	spanCtx, span := Start(ctx, "CountResourceType")
+	if span != nil {
+		t.Fatal("Start() returned a span while tracing is disabled")
+	}
+	if spanCtx != ctx {
+		t.Error("Start() changed the context while tracing is disabled")
+	}
+
+	// A nil span ignores every call
+	span.SetString("provider", "aws")
+	span.SetInt("page", 1)
+	span.End(errors.New("boom"))
+	SetRetryCount(ctx, 2)
+}
+
+func TestSpans(t *testing.T) {
+	recorder := enableRecording(t)
+
+	ctx, parent := Start(context.Background(), "CountResourceType")
+	parent.SetString("resource_type", "s3:bucket")
+
+	pageCtx, page := Start(ctx, "GetResources")
+	page.SetString("region", "eu-west-1")
+	page.SetInt("page", 2)
+	SetRetryCount(pageCtx, 3)
+	page.End(errors.New("throttled"))
+	parent.End(nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended spans = %d, want 2", len(spans))
+	}
+
+	child, root := spans[0], spans[1]
+	if child.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("page span is not a child of the resource type span")
+	}
+
+	attrs := attributes(child)
+	if attrs["region"].AsString() != "eu-west-1" || attrs["page"].AsInt64() != 2 || attrs["retry_count"].AsInt64() != 3 {
+		t.Errorf("page span attributes = %v", attrs)
+	}
+	if child.Status().Code != codes.Error {
+		t.Errorf("page span status = %v, want error", child.Status().Code)
+	}
+	if root.Status().Code == codes.Error {
+		t.Error("resource type span marked as failed")
+	}
+	if attributes(root)["resource_type"].AsString() != "s3:bucket" {
+		t.Errorf("resource type span attributes = %v", attributes(root))
+	}
+}
+
+func TestSetupDisabledWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	shutdown, err := Setup(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracer != nil {
+		t.Error("Setup() enabled tracing without an endpoint")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() = %v", err)
+	}
+}
@@ -0,0 +1,264 @@
+// Package update checks whether a newer release of the agent has been
+// published, so that users of old builds hear of counting fixes
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/version"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// LatestReleaseURL is the GitHub API endpoint of the latest release
+	LatestReleaseURL = "https://api.github.com/repos/secrails/secrails-sizing-agent/releases/latest"
+
+	// Timeout bounds the release query; a slower answer is given up on
+	Timeout = 2 * time.Second
+
+	// CacheTTL is how long a found latest version is reused before asking
+	// again
+	CacheTTL = 24 * time.Hour
+
+	// AirGappedEnv disables the check when set to a true value, for hosts
+	// without internet access; --no-update-check does the same per run
+	AirGappedEnv = "SECRAILS_AIR_GAPPED"
+
+	// cacheFile holds the last check in the cache directory
+	cacheFile = "update-check.json"
+)
+
+// Disabled reports whether getenv sets AirGappedEnv to a true value, such as
+// "1" or "true"
+func Disabled(getenv func(string) string) bool {
+	disabled, err := strconv.ParseBool(getenv(AirGappedEnv))
+	return err == nil && disabled
+}
+
+// Options configures a check
+type Options struct {
+	// URL is the latest release endpoint; LatestReleaseURL by default
+	URL string
+
+	// CacheDir keeps the outcome of the last check for CacheTTL; none is
+	// kept when empty
+	CacheDir string
+
+	// Client sends the query, so that it goes through the scan's proxy and
+	// CA bundle; http.DefaultClient when nil
+	Client *http.Client
+}
+
+// cached is the on-disk layout of the last check
+type cached struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Latest    string    `json:"latest"`
+}
+
+// Check is a latest version lookup running in the background
+type Check struct {
+	done   chan struct{}
+	latest string
+}
+
+// Start looks up the latest release in the background, from the cache when
+// it was found less than CacheTTL ago. It never fails: an unreachable or
+// slow API leaves the latest version unknown.
+func Start(ctx context.Context, options Options) *Check {
+	check := &Check{done: make(chan struct{})}
+	go func() {
+		defer close(check.done)
+		latest, err := lookup(ctx, options, time.Now())
+		if err != nil {
+			logging.Debug("Failed to check for a newer agent release", zap.Error(err))
+			return
+		}
+		check.latest = latest
+	}()
+	return check
+}
+
+// Latest returns the latest released version once the check is done, or ""
+// while it runs or when the version could not be found; it never waits, so
+// a slow check cannot hold up the scan
+func (c *Check) Latest() string {
+	if c == nil {
+		return ""
+	}
+	select {
+	case <-c.done:
+		return c.latest
+	default:
+		return ""
+	}
+}
+
+// Notice returns the line telling that a newer release than the running
+// version current is available, or "" when current is the latest or either
+// version is unknown
+func (c *Check) Notice(current string) string {
+	latest := c.Latest()
+	if !Newer(latest, current) {
+		return ""
+	}
+	return fmt.Sprintf("A newer release of the agent is available: %s (running %s); "+
+		"download it from https://github.com/secrails/secrails-sizing-agent/releases", latest, current)
+}
+
+// lookup returns the latest version from the cache, or from the API when
+// the cache is missing or stale, storing the answer
+func lookup(ctx context.Context, options Options, now time.Time) (string, error) {
+	path := ""
+	if options.CacheDir != "" {
+		path = filepath.Join(options.CacheDir, cacheFile)
+		if entry, ok := readCache(path); ok {
+			if age := now.Sub(entry.CheckedAt); age >= 0 && age < CacheTTL {
+				return entry.Latest, nil
+			}
+		}
+	}
+
+	latest, err := fetchLatest(ctx, options)
+	if err != nil {
+		return "", err
+	}
+	if path != "" {
+		if err := writeCache(path, cached{CheckedAt: now, Latest: latest}); err != nil {
+			logging.Debug("Failed to cache the update check", zap.String("path", path), zap.Error(err))
+		}
+	}
+	return latest, nil
+}
+
+// fetchLatest asks the releases API for the tag of the latest release
+func fetchLatest(ctx context.Context, options Options) (string, error) {
+	url := options.URL
+	if url == "" {
+		url = LatestReleaseURL
+	}
+	client := options.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("releases API returned %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("failed to decode the latest release: %w", err)
+	}
+	if _, ok := parseVersion(release.TagName); !ok {
+		return "", fmt.Errorf("latest release has an unexpected tag %q", release.TagName)
+	}
+	return release.TagName, nil
+}
+
+func readCache(path string) (cached, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cached{}, false
+	}
+	var entry cached
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Latest == "" {
+		return cached{}, false
+	}
+	return entry, true
+}
+
+// writeCache saves entry atomically, readable only by the current user
+func writeCache(path string, entry cached) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".update-check-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Newer reports whether version a is a later release than b. Versions are
+// of the form v1.2.3, with an optional pre-release suffix that sorts before
+// the release; anything else, such as "dev", is never newer nor older.
+func Newer(a, b string) bool {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range 3 {
+		if va.numbers[i] != vb.numbers[i] {
+			return va.numbers[i] > vb.numbers[i]
+		}
+	}
+	// A release is newer than its pre-releases
+	return va.pre == "" && vb.pre != ""
+}
+
+// semver is a parsed vMAJOR.MINOR.PATCH[-PRE][+BUILD] version
+type semver struct {
+	numbers [3]int
+	pre     string
+}
+
+func parseVersion(s string) (semver, bool) {
+	s, ok := strings.CutPrefix(s, "v")
+	if !ok {
+		return semver{}, false
+	}
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, _ := strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	var v semver
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		v.numbers[i] = n
+	}
+	v.pre = pre
+	return v, true
+}
@@ -0,0 +1,160 @@
+package update
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeReleases serves tag as the latest release, counting the requests
+func fakeReleases(t *testing.T, tag string, calls *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		if r.Header.Get("User-Agent") == "" {
+			t.Error("the release query has no User-Agent")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"tag_name":"` + tag + `","name":"Release ` + tag + `"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLookupCachesForADay(t *testing.T) {
+	calls := 0
+	server := fakeReleases(t, "v1.4.0", &calls)
+	options := Options{URL: server.URL, CacheDir: t.TempDir()}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, at := range []time.Time{now, now.Add(23 * time.Hour)} {
+		latest, err := lookup(context.Background(), options, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest != "v1.4.0" {
+			t.Errorf("lookup() = %q, want v1.4.0", latest)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("releases API called %d times within a day, want 1", calls)
+	}
+
+	if _, err := lookup(context.Background(), options, now.Add(25*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("releases API called %d times after a day, want 2", calls)
+	}
+}
+
+func TestLookupIgnoresCorruptCache(t *testing.T) {
+	calls := 0
+	server := fakeReleases(t, "v1.4.0", &calls)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, cacheFile), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := lookup(context.Background(), Options{URL: server.URL, CacheDir: dir}, time.Now())
+	if err != nil || latest != "v1.4.0" {
+		t.Errorf("lookup() = %q, %v, want v1.4.0 from the API", latest, err)
+	}
+}
+
+func TestLookupFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "rate limited", handler: func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}},
+		{name: "no tag", handler: func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"tag_name":"nightly"}`))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			dir := t.TempDir()
+
+			if latest, err := lookup(context.Background(), Options{URL: server.URL, CacheDir: dir}, time.Now()); err == nil {
+				t.Errorf("lookup() = %q, want an error", latest)
+			}
+			if _, err := os.Stat(filepath.Join(dir, cacheFile)); !os.IsNotExist(err) {
+				t.Errorf("a failed check was cached: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	calls := 0
+	server := fakeReleases(t, "v1.4.0", &calls)
+	check := Start(context.Background(), Options{URL: server.URL})
+	<-check.done
+
+	if got := check.Latest(); got != "v1.4.0" {
+		t.Errorf("Latest() = %q, want v1.4.0", got)
+	}
+	if check.Notice("v1.2.0") == "" {
+		t.Error("Notice(v1.2.0) is empty, want the newer release")
+	}
+	for _, current := range []string{"v1.4.0", "v1.5.0-rc.1", "dev"} {
+		if notice := check.Notice(current); notice != "" {
+			t.Errorf("Notice(%s) = %q, want none", current, notice)
+		}
+	}
+
+	var unchecked *Check
+	if unchecked.Latest() != "" || unchecked.Notice("v1.2.0") != "" {
+		t.Error("a nil Check reports a latest version")
+	}
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	check := Start(context.Background(), Options{URL: "http://127.0.0.1:1/releases/latest"})
+	<-check.done
+	if got := check.Latest(); got != "" {
+		t.Errorf("Latest() = %q, want none", got)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1.4.0", "v1.2.0", true},
+		{"v1.10.0", "v1.9.3", true},
+		{"v2.0.0", "v1.99.99", true},
+		{"v1.4.0", "v1.4.0-rc.1", true},
+		{"v1.4.0-rc.1", "v1.4.0", false},
+		{"v1.4.0", "v1.4.0", false},
+		{"v1.2.0", "v1.4.0", false},
+		{"v1.4.0+build.5", "v1.3.9", true},
+		{"v1.4.0", "dev", false},
+		{"", "v1.2.0", false},
+		{"1.4.0", "v1.2.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "true": true, "1": true, "false": false, "yes": false} {
+		getenv := func(string) string { return value }
+		if got := Disabled(getenv); got != want {
+			t.Errorf("Disabled(%s=%q) = %v, want %v", AirGappedEnv, value, got, want)
+		}
+	}
+}
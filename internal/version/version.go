@@ -0,0 +1,28 @@
+// Package version identifies the agent build to users and cloud providers
+package version
+
+import "runtime/debug"
+
+// Product is the product token of the User-Agent sent to cloud APIs
+const Product = "secrails-sizing-agent"
+
+// Version is set at build time with
+// -ldflags "-X github.com/secrails/secrails-sizing-agent/internal/version.Version=v1.2.3"
+var Version = ""
+
+// Get returns Version, falling back to the module version of builds made
+// with go install, then to "dev"
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// UserAgent returns the product and version, e.g. secrails-sizing-agent/v1.2.3
+func UserAgent() string {
+	return Product + "/" + Get()
+}
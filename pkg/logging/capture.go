@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is a warning or error logged while a capture was active
+type Entry struct {
+	Level   string
+	Message string
+	Fields  map[string]string
+}
+
+// Capture keeps the most recent Warn and Error entries logged between
+// StartCapture and Stop, up to its limit
+type Capture struct {
+	mu      sync.Mutex
+	limit   int
+	entries []Entry
+	next    int
+	dropped int
+}
+
+// active is the capture the logger currently feeds, if any
+var active atomic.Pointer[Capture]
+
+// StartCapture starts capturing Warn and Error entries, keeping the last
+// limit of them. It replaces any capture already active.
+func StartCapture(limit int) *Capture {
+	capture := &Capture{limit: limit}
+	active.Store(capture)
+	return capture
+}
+
+// Stop ends the capture and returns its entries, oldest first, and the
+// number of earlier entries dropped to stay within the limit
+func (c *Capture) Stop() (entries []Entry, dropped int) {
+	active.CompareAndSwap(c, nil)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries = make([]Entry, 0, len(c.entries))
+	entries = append(entries, c.entries[c.next:]...)
+	entries = append(entries, c.entries[:c.next]...)
+	return entries, c.dropped
+}
+
+func (c *Capture) add(entry Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.limit <= 0 {
+		c.dropped++
+		return
+	}
+	if len(c.entries) < c.limit {
+		c.entries = append(c.entries, entry)
+		return
+	}
+	// Full: overwrite the oldest entry
+	c.entries[c.next] = entry
+	c.next = (c.next + 1) % c.limit
+	c.dropped++
+}
+
+// captureCore is teed into the logger and hands Warn and Error entries to
+// the active capture
+type captureCore struct {
+	fields []zapcore.Field
+}
+
+func (c *captureCore) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.WarnLevel && active.Load() != nil
+}
+
+func (c *captureCore) With(fields []zapcore.Field) zapcore.Core {
+	return &captureCore{fields: append(c.fields[:len(c.fields):len(c.fields)], fields...)}
+}
+
+func (c *captureCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *captureCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	capture := active.Load()
+	if capture == nil {
+		return nil
+	}
+
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(encoder)
+	}
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	var values map[string]string
+	if len(encoder.Fields) > 0 {
+		values = make(map[string]string, len(encoder.Fields))
+		for key, value := range encoder.Fields {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+
+	capture.add(Entry{Level: entry.Level.String(), Message: entry.Message, Fields: values})
+	return nil
+}
+
+func (c *captureCore) Sync() error {
+	return nil
+}
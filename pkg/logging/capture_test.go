@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCapture(t *testing.T) {
+	if err := InitLogger("error"); err != nil {
+		t.Fatal(err)
+	}
+
+	Warn("before the capture")
+	capture := StartCapture(2)
+	Info("not captured")
+	Warn("Reached max pages", zap.String("region", "us-east-1"))
+	GetLogger().With(zap.String("type", "ec2:instance")).Error("Failed to count", zap.Error(errors.New("denied")))
+	Warn("No tagging client for region", zap.Int("attempt", 2))
+	entries, dropped := capture.Stop()
+	Warn("after the capture")
+
+	want := []Entry{
+		{Level: "error", Message: "Failed to count", Fields: map[string]string{"type": "ec2:instance", "error": "denied"}},
+		{Level: "warn", Message: "No tagging client for region", Fields: map[string]string{"attempt": "2"}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+	if dropped != 1 {
+		t.Errorf("dropped = %d, want 1", dropped)
+	}
+}
@@ -1,42 +1,115 @@
 package logging
 
 import (
+	"context"
+	"sync/atomic"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-var logger *zap.Logger
+// logger is the default logger of the package-level helpers, set by
+// InitLogger or on first use
+var logger atomic.Pointer[zap.Logger]
+
+// Levels lists the log levels InitLogger accepts
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// ValidLevel reports whether level is one of Levels
+func ValidLevel(level string) bool {
+	for _, l := range Levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
 
-// InitLogger initializes the logger with the specified level
+// InitLogger initializes the default logger with the specified level. It
+// is safe to call from several goroutines; the last call wins.
 func InitLogger(level string) error {
+	l, err := New(level)
+	if err != nil {
+		return err
+	}
+	logger.Store(l)
+	return nil
+}
+
+// New builds a logger writing JSON lines of the specified level and above
+// to stderr, masked and captured like the default one
+func New(level string) (*zap.Logger, error) {
 	config := zap.NewProductionConfig()
 
 	// Parse log level
 	var zapLevel zapcore.Level
 	if err := zapLevel.UnmarshalText([]byte(level)); err != nil {
-		return err
+		return nil, err
 	}
 
 	config.Level = zap.NewAtomicLevelAt(zapLevel)
 	config.EncoderConfig.TimeKey = "timestamp"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	// Never log to stdout, which carries the results
+	config.OutputPaths = []string{"stderr"}
+	config.ErrorOutputPaths = []string{"stderr"}
 
-	var err error
-	logger, err = config.Build()
+	return config.Build(zap.WrapCore(wrapCore))
+}
+
+// Wrap returns l with its entries masked and its warnings and errors fed to
+// the active capture, as those of the default logger are. Callers embedding
+// the agent wrap the logger they hand to it.
+func Wrap(l *zap.Logger) *zap.Logger {
+	return l.WithOptions(zap.WrapCore(wrapCore))
+}
+
+// wrapCore tees core with the active capture; both see entries with account
+// IDs, GUIDs and sensitive fields masked
+func wrapCore(core zapcore.Core) zapcore.Core {
+	return &redactCore{Core: zapcore.NewTee(core, &captureCore{})}
+}
+
+// GetLogger returns the default logger, initializing it at info level when
+// InitLogger was not called
+func GetLogger() *zap.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	l, err := New("info")
 	if err != nil {
-		return err
+		return zap.NewNop()
+	}
+	// Keep a logger set concurrently, e.g. by InitLogger
+	if !logger.CompareAndSwap(nil, l) {
+		return logger.Load()
 	}
+	return l
+}
 
-	return nil
+// OrDefault returns l, or the default logger when l is nil
+func OrDefault(l *zap.Logger) *zap.Logger {
+	if l == nil {
+		return GetLogger()
+	}
+	return l
 }
 
-// GetLogger returns the logger instance
-func GetLogger() *zap.Logger {
-	if logger == nil {
-		// Initialize with default if not already initialized
-		_ = InitLogger("info")
+// contextKey is the context key of the logger of a scan
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l, which FromContext returns
+// to the code run with it
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*zap.Logger); ok && l != nil {
+		return l
 	}
-	return logger
+	return GetLogger()
 }
 
 // Info logs an info message
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestInitLoggerConcurrent(t *testing.T) {
+	// Run with -race: initializing and logging from several goroutines must
+	// not race on the default logger
+	var wg sync.WaitGroup
+	for _, level := range []string{"debug", "info", "warn", "error", "info", "warn"} {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := InitLogger(level); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			Debug("concurrent use of the default logger")
+		}()
+	}
+	wg.Wait()
+
+	if GetLogger() == nil {
+		t.Fatal("GetLogger() = nil after InitLogger")
+	}
+	if err := InitLogger("verbose"); err == nil {
+		t.Error("InitLogger(\"verbose\") error = nil, want an invalid level")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != GetLogger() {
+		t.Error("FromContext() without a logger does not return the default logger")
+	}
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx := NewContext(context.Background(), zap.New(core))
+	FromContext(ctx).Info("counted", zap.Int("total", 3))
+	if logs.Len() != 1 {
+		t.Errorf("logger of ctx received %d entries, want 1", logs.Len())
+	}
+	if OrDefault(nil) != GetLogger() {
+		t.Error("OrDefault(nil) does not return the default logger")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := Wrap(zap.New(core))
+
+	capture := StartCapture(10)
+	logger.Info("not captured")
+	logger.Warn("Cannot assume role", zap.String("account", "123456789012"))
+	entries, _ := capture.Stop()
+
+	observed := logs.AllUntimed()
+	if len(observed) != 2 || observed[1].ContextMap()["account"] != "********9012" {
+		t.Errorf("wrapped logger wrote %+v, want the account masked", observed)
+	}
+	if len(entries) != 1 || entries[0].Message != "Cannot assume role" {
+		t.Errorf("captured %+v, want the warning only", entries)
+	}
+}
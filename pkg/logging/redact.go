@@ -0,0 +1,161 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the values of sensitive fields
+const Redacted = "[REDACTED]"
+
+var (
+	// accountIDPattern matches AWS account IDs, also inside ARNs
+	accountIDPattern = regexp.MustCompile(`\b\d{12}\b`)
+	// guidPattern matches GUIDs such as Azure subscription and tenant IDs
+	guidPattern = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+)
+
+// redactionDisabled turns redaction off, with --no-redact
+var redactionDisabled atomic.Bool
+
+// SetRedaction turns the masking of account IDs, GUIDs and sensitive fields
+// in log entries on or off; it is on unless turned off
+func SetRedaction(enabled bool) {
+	redactionDisabled.Store(!enabled)
+}
+
+// Redact masks the account IDs and GUIDs in s, keeping their last four
+// characters so entries can still be told apart
+func Redact(s string) string {
+	if redactionDisabled.Load() {
+		return s
+	}
+	s = accountIDPattern.ReplaceAllStringFunc(s, maskKeepLast4)
+	return guidPattern.ReplaceAllStringFunc(s, maskKeepLast4)
+}
+
+// maskKeepLast4 replaces every letter and digit but the last four with *
+func maskKeepLast4(s string) string {
+	masked := []byte(s)
+	for i := 0; i < len(masked)-4; i++ {
+		if masked[i] != '-' {
+			masked[i] = '*'
+		}
+	}
+	return string(masked)
+}
+
+// sensitiveField reports whether the values of a field are confidential as a
+// whole: ARNs, names and tags
+func sensitiveField(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range []string{"arn", "name", "tags"} {
+		if key == name || strings.HasSuffix(key, "_"+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactCore masks the message and fields of every entry before the cores
+// it wraps see them
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write hands the masked entry to those of the wrapped cores that are
+// enabled for its level
+func (c *redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = Redact(entry.Message)
+	if checked := c.Core.Check(entry, nil); checked != nil {
+		checked.Write(redactFields(fields)...)
+	}
+	return nil
+}
+
+// redactFields returns fields with sensitive fields replaced and the account
+// IDs and GUIDs of the others masked
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	if redactionDisabled.Load() {
+		return fields
+	}
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = redactField(field)
+	}
+	return redacted
+}
+
+func redactField(field zapcore.Field) zapcore.Field {
+	switch field.Type {
+	case zapcore.BoolType, zapcore.DurationType, zapcore.Float64Type, zapcore.Float32Type,
+		zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type,
+		zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType,
+		zapcore.TimeType, zapcore.TimeFullType, zapcore.SkipType:
+		return field
+	}
+	if sensitiveField(field.Key) {
+		return zap.String(field.Key, Redacted)
+	}
+
+	switch field.Type {
+	case zapcore.StringType:
+		return zap.String(field.Key, Redact(field.String))
+	case zapcore.ErrorType:
+		return zap.String(field.Key, Redact(field.Interface.(error).Error()))
+	case zapcore.StringerType:
+		return zap.String(field.Key, Redact(field.Interface.(fmt.Stringer).String()))
+	}
+
+	// Arrays and objects are encoded and masked as a whole, reflected
+	// values through their JSON form
+	if field.Type == zapcore.ReflectType {
+		var value any
+		data, err := json.Marshal(field.Interface)
+		if err != nil || json.Unmarshal(data, &value) != nil {
+			return zap.String(field.Key, Redacted)
+		}
+		return zap.Any(field.Key, redactValue(value))
+	}
+	encoder := zapcore.NewMapObjectEncoder()
+	field.AddTo(encoder)
+	return zap.Any(field.Key, redactValue(encoder.Fields[field.Key]))
+}
+
+// redactValue masks the strings within an encoded field value
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return Redact(v)
+	case []any:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	case map[string]any:
+		for key, field := range v {
+			if sensitiveField(key) {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redactValue(field)
+		}
+	}
+	return value
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newRedactedLogger returns a logger whose entries pass through a redactCore
+// and the entries it wrote
+func newRedactedLogger() (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	return zap.New(&redactCore{Core: core}), logs
+}
+
+func TestRedactCore(t *testing.T) {
+	logger, logs := newRedactedLogger()
+	endpoint, _ := url.Parse("https://sts.amazonaws.com/?Account=123456789012")
+
+	logger.With(zap.String("account", "123456789012")).Warn(
+		"Failed to assume arn:aws:iam::123456789012:role/Scanner",
+		zap.String("arn", "arn:aws:ec2:us-east-1:123456789012:instance/i-0abc"),
+		zap.String("subscription_name", "Production"),
+		zap.Any("tags", map[string]string{"owner": "alice"}),
+		zap.String("subscription_id", "8f2b6c1e-4d3a-4b5c-9e7f-0a1b2c3d4e5f"),
+		zap.Error(errors.New("access denied for account 210987654321")),
+		zap.Stringer("endpoint", endpoint),
+		zap.Strings("accounts", []string{"111111111111", "222222222222"}),
+		zap.Any("endpoints", map[string]string{"sts": "https://sts.123456789012.example.com"}),
+		zap.Int("attempt", 2),
+		zap.String("region", "us-east-1"),
+	)
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(entries))
+	}
+	if got, want := entries[0].Message, "Failed to assume arn:aws:iam::********9012:role/Scanner"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+
+	fields := entries[0].ContextMap()
+	want := map[string]any{
+		"account":           "********9012",
+		"arn":               Redacted,
+		"subscription_name": Redacted,
+		"tags":              Redacted,
+		"subscription_id":   "********-****-****-****-********4e5f",
+		"error":             "access denied for account ********4321",
+		"endpoint":          "https://sts.amazonaws.com/?Account=********9012",
+		"attempt":           int64(2),
+		"region":            "us-east-1",
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("%s = %v, want %v", key, fields[key], value)
+		}
+	}
+	if got := fields["accounts"].([]any); got[0] != "********1111" || got[1] != "********2222" {
+		t.Errorf("accounts = %v, want both masked", got)
+	}
+	if got := fields["endpoints"].(map[string]any)["sts"]; got != "https://sts.********9012.example.com" {
+		t.Errorf("endpoints = %v, want the account ID masked", got)
+	}
+}
+
+func TestRedactCoreKeepsLevels(t *testing.T) {
+	logger, logs := newRedactedLogger()
+	logger.Debug("Scanning account 123456789012")
+	if logs.Len() != 0 {
+		t.Errorf("entries = %v, want the debug entry dropped", logs.AllUntimed())
+	}
+}
+
+func TestRedactionDisabled(t *testing.T) {
+	SetRedaction(false)
+	t.Cleanup(func() { SetRedaction(true) })
+
+	logger, logs := newRedactedLogger()
+	logger.Info("Scanning account 123456789012", zap.String("name", "Production"))
+
+	entry := logs.AllUntimed()[0]
+	if entry.Message != "Scanning account 123456789012" || entry.ContextMap()["name"] != "Production" {
+		t.Errorf("entry = %q %v, want it unmasked", entry.Message, entry.ContextMap())
+	}
+}
+
+func TestCaptureIsRedacted(t *testing.T) {
+	if err := InitLogger("error"); err != nil {
+		t.Fatal(err)
+	}
+
+	capture := StartCapture(1)
+	Warn("Region denied for account 123456789012", zap.String("account_name", "Production"))
+	entries, _ := capture.Stop()
+
+	if len(entries) != 1 || strings.Contains(entries[0].Message, "1234567") || entries[0].Fields["account_name"] != Redacted {
+		t.Errorf("entries = %+v, want the account ID and name masked", entries)
+	}
+}
+
+func TestSensitiveField(t *testing.T) {
+	for key, sensitive := range map[string]bool{
+		"arn":          true,
+		"role_arn":     true,
+		"name":         true,
+		"account_name": true,
+		"Tags":         true,
+		"region":       false,
+		"type":         false,
+		"filename":     false,
+	} {
+		if got := sensitiveField(key); got != sensitive {
+			t.Errorf("sensitiveField(%q) = %v, want %v", key, got, sensitive)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package sizing_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/pkg/sizing"
+)
+
+// Scan an AWS account with the credentials of a shared config profile,
+// limited to two regions
+func ExampleScan() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	result, err := sizing.Scan(ctx, sizing.Options{
+		Provider: "aws",
+		Profile:  "audit",
+		Regions:  []string{"us-east-1", "eu-west-1"},
+	})
+	var partial *sizing.PartialResultError
+	if errors.As(err, &partial) {
+		log.Printf("some types were not counted: %v", partial.Failed)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, count := range result.ResourceCounts {
+		fmt.Printf("%s: %d\n", count.DisplayName, count.TotalResources)
+	}
+	fmt.Println("workload units:", result.Estimate.WorkloadUnits)
+}
+
+// Scan the Azure subscriptions the Azure CLI is signed in to, counting only
+// resources tagged env=prod
+func ExampleScan_azure() {
+	result, err := sizing.Scan(context.Background(), sizing.Options{
+		Provider:  "azure",
+		AzureAuth: "cli",
+		Tags:      []sizing.TagFilter{{Key: "env", Value: "prod"}},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result.TotalResources, "resources in", result.TotalAccounts, "subscriptions")
+}
@@ -0,0 +1,196 @@
+// Package sizing scans a cloud account, subscription, tenancy or cluster and
+// returns its resource counts, for Go programs that embed the agent rather
+// than run the binary.
+//
+// Scan is the same scan the sizing-agent command and its scan API run, minus
+// writing output: the result carries the workload-unit estimate and is
+// anonymized when asked for. Credentials come from the usual sources of each
+// provider (environment, shared config files, CLI sign-ins and managed
+// identities), selected with Options. Logs go to Options.Logger, or to the
+// default logger of pkg/logging when it is nil.
+//
+// The package follows semantic versioning: fields and functions are only
+// added within a major version. Result and the types it holds are the
+// JSON-tagged sizing result types of the agent, whose layout is versioned by
+// Result.SchemaVersion.
+package sizing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/secrails/secrails-sizing-agent/internal/agent"
+	sizingerrors "github.com/secrails/secrails-sizing-agent/internal/errors"
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/counting"
+	"go.uber.org/zap"
+)
+
+// Result is the outcome of a scan: the resource counts by type, region and
+// account, the accounts scanned, the estimate and any warnings
+type Result = models.SizingResult
+
+// ResourceCount is the count of one resource type in a Result
+type ResourceCount = models.ResourceCount
+
+// AccountCount is one account, subscription, compartment or cluster of a
+// Result and its resource count
+type AccountCount = models.AccountCount
+
+// Estimate is the workload-unit estimate of a Result
+type Estimate = models.Estimate
+
+// ResourceType is a provider resource type, e.g. "ec2:instance", or a
+// canonical type such as "VirtualMachine"
+type ResourceType = models.ResourceType
+
+// TagFilter limits a scan to resources carrying a tag with this exact value
+type TagFilter = models.TagFilter
+
+// AccountFilter limits a scan to the AWS accounts or Azure subscriptions
+// whose name matches one of its glob patterns and that carry all of its
+// tags
+type AccountFilter = models.AccountFilter
+
+// PartialResultError is returned with a Result when some resource types or
+// scans could not be counted; the Result undercounts the types it names
+type PartialResultError = sizingerrors.PartialResultError
+
+// Options selects what Scan counts and with which credentials. Only
+// Provider is required; the other fields left zero keep the provider's
+// defaults.
+type Options struct {
+	// Provider is the cloud to scan: aws, azure, k8s or oci
+	Provider string
+
+	// Profile is the AWS shared config profile, Region the region of the
+	// discovery calls, and AssumeRoleARN a role assumed for the scan with
+	// ExternalID
+	Profile       string
+	Region        string
+	AssumeRoleARN string
+	ExternalID    string
+
+	// TenantID pins Azure credentials to a tenant, and AzureAuth selects
+	// how they sign in, e.g. "cli" or "msi"
+	TenantID  string
+	AzureAuth string
+
+	// Kubeconfig is the kubeconfig file of a Kubernetes scan, and
+	// KubeContexts the contexts to scan instead of the current one
+	Kubeconfig   string
+	KubeContexts []string
+
+	// OCIAuth is the OCI authentication method, and OCIConfigFile and
+	// OCIProfile the config file and profile it reads
+	OCIAuth       string
+	OCIConfigFile string
+	OCIProfile    string
+
+	// Regions limits an AWS scan to these regions, and Subscriptions an
+	// Azure scan to these subscription IDs
+	Regions       []string
+	Subscriptions []string
+
+	// Tags limits the scan to resources carrying all of these tags
+	Tags []TagFilter
+
+	// AccountFilter limits the scan to the discovered accounts or
+	// subscriptions it matches
+	AccountFilter AccountFilter
+
+	// ResourceDefinitions is a YAML file that merges with or replaces the
+	// built-in resource types
+	ResourceDefinitions string
+
+	// ExcludeNoise leaves cloud-managed resources, such as default security
+	// groups, out of the counts
+	ExcludeNoise bool
+
+	// DeepRegistries, DeepStorage and DeepBackup also count what container
+	// registries, Azure storage accounts and Recovery Services vaults hold,
+	// at the cost of API calls per registry, account or vault
+	DeepRegistries bool
+	DeepStorage    bool
+	DeepBackup     bool
+
+	// RetryFailed is the number of passes (0-3) retrying the resource types
+	// whose count failed, none by default unlike the command's one, and
+	// TypeTimeout bounds each attempt at counting a type; 0 leaves it
+	// unbounded. Providers bound their own API concurrency.
+	RetryFailed int
+	TypeTimeout time.Duration
+
+	// Anonymize replaces account identifiers and names in the result
+	Anonymize bool
+
+	// Stats keeps per-type durations, API pages and retries in the result
+	Stats bool
+
+	// Logger receives the log entries of the scan, with account IDs and
+	// GUIDs masked; nil logs JSON lines to stderr at info level
+	Logger *zap.Logger
+}
+
+// Scan connects to the provider of options and counts its resources. When
+// some resource types fail, Scan returns the result together with a
+// *PartialResultError. Cancelling ctx aborts the scan.
+func Scan(ctx context.Context, options Options) (*Result, error) {
+	config, err := options.config()
+	if err != nil {
+		return nil, err
+	}
+	return agent.New(config).Scan(ctx)
+}
+
+// config is the agent configuration of options: a quiet scan that writes
+// nothing
+func (o Options) config() (*agent.Config, error) {
+	provider := strings.ToLower(strings.TrimSpace(o.Provider))
+	if provider == "" {
+		return nil, fmt.Errorf("no provider specified")
+	}
+	if o.RetryFailed < 0 || o.RetryFailed > counting.MaxRetries {
+		return nil, fmt.Errorf("RetryFailed must be between 0 and %d, got %d", counting.MaxRetries, o.RetryFailed)
+	}
+	if o.TypeTimeout < 0 {
+		return nil, fmt.Errorf("TypeTimeout must not be negative, got %s", o.TypeTimeout)
+	}
+	for _, pattern := range o.AccountFilter.Names {
+		if err := models.ValidateAccountPattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	return &agent.Config{
+		Provider:            provider,
+		Quiet:               true,
+		Profile:             o.Profile,
+		Region:              o.Region,
+		AssumeRoleARN:       o.AssumeRoleARN,
+		ExternalID:          o.ExternalID,
+		TenantID:            o.TenantID,
+		AzureAuth:           o.AzureAuth,
+		Kubeconfig:          o.Kubeconfig,
+		KubeContexts:        o.KubeContexts,
+		OCIAuth:             o.OCIAuth,
+		OCIConfigFile:       o.OCIConfigFile,
+		OCIProfile:          o.OCIProfile,
+		Regions:             o.Regions,
+		Subscriptions:       o.Subscriptions,
+		Tags:                o.Tags,
+		AccountFilter:       o.AccountFilter,
+		ResourceDefinitions: o.ResourceDefinitions,
+		ExcludeNoise:        o.ExcludeNoise,
+		DeepRegistries:      o.DeepRegistries,
+		DeepStorage:         o.DeepStorage,
+		DeepBackup:          o.DeepBackup,
+		RetryFailed:         o.RetryFailed,
+		TypeTimeout:         o.TypeTimeout,
+		Anonymize:           o.Anonymize,
+		Stats:               o.Stats,
+		Logger:              o.Logger,
+	}, nil
+}
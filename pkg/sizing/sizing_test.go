@@ -0,0 +1,143 @@
+package sizing_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/secrails/secrails-sizing-agent/internal/models"
+	"github.com/secrails/secrails-sizing-agent/internal/providers"
+	"github.com/secrails/secrails-sizing-agent/internal/providers/config"
+	"github.com/secrails/secrails-sizing-agent/pkg/logging"
+	"github.com/secrails/secrails-sizing-agent/pkg/sizing"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// memoryProvider serves fixed counts from memory, failing one type when
+// partial is set
+type memoryProvider struct {
+	cfg config.ProviderConfig
+}
+
+func (p *memoryProvider) Name() string                  { return "sizingtest" }
+func (p *memoryProvider) Connect(context.Context) error { return nil }
+func (p *memoryProvider) Close() error                  { return nil }
+
+func (p *memoryProvider) CountResources(context.Context) (*models.SizingResult, error) {
+	logging.OrDefault(p.cfg.Logger).Info("Counting in memory", zap.String("account", "123456789012"))
+	result := &models.SizingResult{
+		SchemaVersion: models.SchemaVersion,
+		Provider:      "sizingtest",
+		AccountCounts: []models.AccountCount{{ID: "123456789012", Name: "prod", ResourceCount: 5}},
+		TotalAccounts: 1,
+	}
+	for resourceType, total := range map[models.ResourceType]int{"vm": 3, "bucket": 2} {
+		if len(p.cfg.TagFilters) > 0 && resourceType == "bucket" {
+			continue
+		}
+		result.ResourceCounts = append(result.ResourceCounts, &models.ResourceCount{
+			Provider: "sizingtest", Type: resourceType, DisplayName: string(resourceType), TotalResources: total,
+			ByAccount: map[string]int{"123456789012": total},
+		})
+		result.TotalResources += total
+	}
+	if p.cfg.RetryFailed == 0 {
+		return result, nil
+	}
+	return result, &sizing.PartialResultError{Failed: []string{"disk"}, Errs: []error{errors.New("throttled")}}
+}
+
+func (p *memoryProvider) Plan() (*models.ScanPlan, error) {
+	return &models.ScanPlan{Provider: p.Name()}, nil
+}
+
+func (p *memoryProvider) Accounts() *models.AccountList {
+	return models.NewAccountList(p.Name(), models.AccountSourceCurrentAccount, nil)
+}
+
+func init() {
+	providers.Register("sizingtest", func(cfg config.ProviderConfig) (providers.Provider, error) {
+		return &memoryProvider{cfg: cfg}, nil
+	})
+}
+
+func TestScan(t *testing.T) {
+	result, err := sizing.Scan(context.Background(), sizing.Options{Provider: " SizingTest "})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if result.TotalResources != 5 || len(result.ResourceCounts) != 2 {
+		t.Errorf("Scan() = %d resources in %d types, want 5 in 2", result.TotalResources, len(result.ResourceCounts))
+	}
+	if result.Estimate == nil {
+		t.Error("Scan() result has no estimate")
+	}
+}
+
+func TestScanOptions(t *testing.T) {
+	result, err := sizing.Scan(context.Background(), sizing.Options{
+		Provider:  "sizingtest",
+		Tags:      []sizing.TagFilter{{Key: "env", Value: "prod"}},
+		Anonymize: true,
+	})
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if result.TotalResources != 3 {
+		t.Errorf("Scan() with a tag filter = %d resources, want 3", result.TotalResources)
+	}
+	if id := result.AccountCounts[0].ID; id == "123456789012" {
+		t.Errorf("account ID %q was not anonymized", id)
+	}
+}
+
+func TestScanLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	if _, err := sizing.Scan(context.Background(), sizing.Options{Provider: "sizingtest", Logger: zap.New(core)}); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+
+	entries := logs.FilterMessage("Counting in memory").All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %+v, want the provider's entry on the injected logger", logs.All())
+	}
+	if account := entries[0].ContextMap()["account"]; account == "123456789012" {
+		t.Errorf("account %q was logged unmasked", account)
+	}
+}
+
+func TestScanPartial(t *testing.T) {
+	result, err := sizing.Scan(context.Background(), sizing.Options{Provider: "sizingtest", RetryFailed: 1})
+	var partial *sizing.PartialResultError
+	if !errors.As(err, &partial) || len(partial.Failed) != 1 {
+		t.Fatalf("Scan() error = %v, want a partial result", err)
+	}
+	if result == nil || result.TotalResources != 5 {
+		t.Errorf("Scan() = %+v, want the counted types with the error", result)
+	}
+}
+
+func TestScanInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options sizing.Options
+		wantErr string
+	}{
+		{name: "no provider", options: sizing.Options{}, wantErr: "no provider"},
+		{name: "unknown provider", options: sizing.Options{Provider: "gcp"}, wantErr: "gcp"},
+		{name: "too many retries", options: sizing.Options{Provider: "sizingtest", RetryFailed: 4}, wantErr: "RetryFailed"},
+		{name: "negative timeout", options: sizing.Options{Provider: "sizingtest", TypeTimeout: -1}, wantErr: "TypeTimeout"},
+		{name: "bad account filter", options: sizing.Options{Provider: "sizingtest",
+			AccountFilter: sizing.AccountFilter{Names: []string{"prod-["}}}, wantErr: "invalid account filter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sizing.Scan(context.Background(), tt.options)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Scan() error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}